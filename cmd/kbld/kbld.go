@@ -13,6 +13,7 @@ import (
 	uierrs "github.com/cppforlife/go-cli-ui/errors"
 	"github.com/cppforlife/go-cli-ui/ui"
 	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
 )
 
 func main() {
@@ -31,7 +32,8 @@ func main() {
 	err := command.Execute()
 	if err != nil {
 		confUI.ErrorLinef("kbld: Error: %s", uierrs.NewMultiLineError(err))
-		os.Exit(1)
+		// Exit code indicates kind of failure (e.g. config, build, push, resolution, policy)
+		os.Exit(util.ErrorCategoryOf(err).ExitCode())
 	}
 
 	confUI.PrintLinef("Succeeded")
@@ -0,0 +1,130 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package api is a supported entry point for embedding kbld into other tools.
+// Unlike other packages under pkg/kbld, its exported types and functions
+// are kept backwards compatible across minor releases.
+package api
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	regauthn "github.com/google/go-containerregistry/pkg/authn"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+// Origin describes where image came from (same as in kbld.k14s.io/origins annotation)
+type Origin = ctlconf.Origin
+
+// Image is a processed image reference
+type Image struct {
+	// URL is image reference as found in inputs (or configured source image)
+	URL string
+	// ResolvedURL is digest reference
+	ResolvedURL string
+	Origins     []Origin
+}
+
+// Inputs specifies resources and kbld configuration
+type Inputs struct {
+	// Files are file paths, directories, URLs or - for stdin (same as -f flag)
+	Files []string
+	// Documents are YAML streams of resources and kbld configuration
+	Documents [][]byte
+}
+
+// RegistryOpts configures access to registries
+type RegistryOpts struct {
+	CACertPaths []string
+	// SkipCertVerify disables TLS certificate verification
+	SkipCertVerify bool
+	// Insecure allows plain HTTP registries
+	Insecure bool
+	// EnvAuthPrefix sets prefix of environment variables with registry credentials
+	// (defaults to KBLD_REGISTRY)
+	EnvAuthPrefix string
+	// Keychain optionally provides credentials that take precedence over
+	// environment variables and Docker configuration
+	Keychain regauthn.Keychain
+}
+
+// Logger receives kbld log output (e.g. build output and progress)
+type Logger interface {
+	io.Writer
+}
+
+func (o Inputs) resourcesAndConf() ([]ctlres.Resource, ctlconf.Conf, error) {
+	fileFlags := ctlcmd.FileFlags{Files: o.Files}
+
+	rs, err := fileFlags.AllResources()
+	if err != nil {
+		return nil, ctlconf.Conf{}, err
+	}
+
+	for i, doc := range o.Documents {
+		docRs, err := ctlres.NewFileResource(ctlres.NewBytesSource(doc), "document.yml").Resources()
+		if err != nil {
+			return nil, ctlconf.Conf{}, fmt.Errorf("Parsing document %d: %s", i+1, err)
+		}
+		rs = append(rs, docRs...)
+	}
+
+	return ctlconf.NewConfFromResources(rs)
+}
+
+func (o RegistryOpts) registry(includeNonDistributable bool) (ctlreg.Registry, error) {
+	envAuthPrefix := o.EnvAuthPrefix
+	if len(envAuthPrefix) == 0 {
+		envAuthPrefix = "KBLD_REGISTRY"
+	}
+
+	registry, err := ctlreg.NewRegistry(ctlreg.Opts{
+		CACertPaths:   o.CACertPaths,
+		VerifyCerts:   !o.SkipCertVerify,
+		Insecure:      o.Insecure,
+		EnvAuthPrefix: envAuthPrefix,
+
+		IncludeNonDistributable: includeNonDistributable,
+	})
+	if err != nil {
+		return ctlreg.Registry{}, err
+	}
+
+	if o.Keychain != nil {
+		registry = registry.WithKeychain(o.Keychain)
+	}
+
+	return registry, nil
+}
+
+func newLogger(logger Logger) ctllog.Logger {
+	if logger == nil {
+		return ctllog.NewLogger(io.Discard)
+	}
+	return ctllog.NewLogger(logger)
+}
+
+func newUI() ui.UI {
+	return ui.NewNoopUI()
+}
+
+func images(processedImages *ctlcmd.ProcessedImages) []Image {
+	var result []Image
+	if processedImages == nil {
+		return result
+	}
+	for _, item := range processedImages.All() {
+		result = append(result, Image{
+			URL:         item.UnprocessedImageURL.URL,
+			ResolvedURL: item.Image.URL,
+			Origins:     item.Image.Origins,
+		})
+	}
+	return result
+}
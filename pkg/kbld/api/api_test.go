@@ -0,0 +1,99 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package api_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/api"
+)
+
+func TestResolve(t *testing.T) {
+	resources := `
+kind: Object
+metadata:
+  name: app
+spec:
+- image: nginx:1.17
+`
+	config := `
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+overrides:
+- image: nginx:1.17
+  newImage: index.docker.io/library/nginx@sha256:0000000000000000000000000000000000000000000000000000000000000001
+  preresolved: true
+`
+
+	result, err := api.Resolve(api.ResolveOpts{
+		Inputs: api.Inputs{Documents: [][]byte{[]byte(resources), []byte(config)}},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, result.Resources, 1)
+	assert.Contains(t, string(result.Resources[0]),
+		"- image: index.docker.io/library/nginx@sha256:0000000000000000000000000000000000000000000000000000000000000001")
+	assert.Contains(t, string(result.Resources[0]), "kbld.k14s.io/images")
+
+	require.Len(t, result.Images, 1)
+	assert.Equal(t, "nginx:1.17", result.Images[0].URL)
+	assert.Equal(t, "index.docker.io/library/nginx@sha256:0000000000000000000000000000000000000000000000000000000000000001",
+		result.Images[0].ResolvedURL)
+	require.Len(t, result.Images[0].Origins, 1)
+	assert.NotNil(t, result.Images[0].Origins[0].Preresolved)
+}
+
+func TestResolveWithImagesAnnotationConf(t *testing.T) {
+	resources := `
+kind: Object
+metadata:
+  name: app
+spec:
+- image: nginx:1.17
+`
+	excludedResources := `
+apiVersion: example.com/v1
+kind: StrictObject
+metadata:
+  name: app
+spec:
+- image: nginx:1.17
+`
+	config := `
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+overrides:
+- image: nginx:1.17
+  newImage: index.docker.io/library/nginx@sha256:0000000000000000000000000000000000000000000000000000000000000001
+  preresolved: true
+imagesAnnotation:
+  key: example.com/images
+  include:
+    tag: true
+  exclude:
+  - kind: StrictObject
+`
+
+	result, err := api.Resolve(api.ResolveOpts{
+		Inputs: api.Inputs{Documents: [][]byte{[]byte(resources), []byte(excludedResources), []byte(config)}},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, result.Resources, 2)
+	assert.Contains(t, string(result.Resources[0]), "example.com/images: |")
+	assert.Contains(t, string(result.Resources[0]), "tag: \"1.17\"")
+	assert.NotContains(t, string(result.Resources[0]), "kbld.k14s.io/images")
+
+	assert.NotContains(t, string(result.Resources[1]), "example.com/images")
+	assert.Contains(t, string(result.Resources[1]),
+		"- image: index.docker.io/library/nginx@sha256:0000000000000000000000000000000000000000000000000000000000000001")
+}
+
+func TestBuildRequiresConfiguredSource(t *testing.T) {
+	_, err := api.Build(api.BuildOpts{Images: []string{"app"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Expected source to be configured for image 'app'")
+}
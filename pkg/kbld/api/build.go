@@ -0,0 +1,68 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	"fmt"
+
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+)
+
+type BuildOpts struct {
+	// Inputs provide kbld configuration with sources (other resources are ignored)
+	Inputs   Inputs
+	Registry RegistryOpts
+	Logger   Logger
+
+	// Images limits building to given source images (all sources are built when empty)
+	Images []string
+	// BuildConcurrency defaults to 4
+	BuildConcurrency int
+}
+
+// Build builds (and pushes to configured destinations) images from configured sources
+func Build(opts BuildOpts) ([]Image, error) {
+	_, conf, err := opts.Inputs.resourcesAndConf()
+	if err != nil {
+		return nil, err
+	}
+
+	registry, err := opts.Registry.registry(false)
+	if err != nil {
+		return nil, err
+	}
+
+	configured := map[string]bool{}
+	for _, src := range conf.Sources() {
+		if len(src.Image) > 0 {
+			configured[src.Image] = true
+		}
+	}
+
+	imageURLs := ctlcmd.NewUnprocessedImageURLs()
+
+	if len(opts.Images) > 0 {
+		for _, img := range opts.Images {
+			if !configured[img] {
+				return nil, fmt.Errorf("Expected source to be configured for image '%s'", img)
+			}
+			imageURLs.Add(ctlcmd.UnprocessedImageURL{URL: img})
+		}
+	} else {
+		// Sources matched by repository only do not specify which image to build
+		for img := range configured {
+			imageURLs.Add(ctlcmd.UnprocessedImageURL{URL: img})
+		}
+	}
+
+	imgFactory := ctlimg.NewFactory(ctlimg.FactoryOpts{Conf: conf, AllowedToBuild: true}, registry, newLogger(opts.Logger))
+
+	builtImages, err := ctlcmd.NewImageQueue(imgFactory).Run(imageURLs, buildConcurrencyWithDefaults(opts.BuildConcurrency))
+	if err != nil {
+		return nil, err
+	}
+
+	return images(builtImages), nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	"fmt"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+type PackageOpts struct {
+	// Inputs provide resources referencing images by digest (and optional lock files)
+	Inputs   Inputs
+	Registry RegistryOpts
+	Logger   Logger
+
+	OutputPath string
+	// Concurrency defaults to 5
+	Concurrency int
+
+	IncludeNonDistributable bool
+}
+
+// Package exports images referenced by inputs into a tarball
+func Package(opts PackageOpts) error {
+	if len(opts.OutputPath) == 0 {
+		return fmt.Errorf("Expected output path to be non-empty")
+	}
+
+	rs, conf, err := opts.Inputs.resourcesAndConf()
+	if err != nil {
+		return err
+	}
+
+	foundImages, err := ctlcmd.FindImages(rs, conf)
+	if err != nil {
+		return err
+	}
+
+	registry, err := opts.Registry.registry(opts.IncludeNonDistributable)
+	if err != nil {
+		return err
+	}
+
+	packageOpts := ctlcmd.NewPackageOptions(newUI())
+	packageOpts.OutputPath = opts.OutputPath
+	packageOpts.Concurrency = concurrencyWithDefaults(opts.Concurrency)
+	packageOpts.IncludeNonDistributable = opts.IncludeNonDistributable
+
+	return packageOpts.PackageImages(foundImages, registry, newLogger(opts.Logger))
+}
+
+type UnpackageOpts struct {
+	// Inputs provide resources referencing packaged images
+	Inputs   Inputs
+	Registry RegistryOpts
+	Logger   Logger
+
+	InputPath string
+	// Repository receives imported images
+	Repository string
+	// LockOutput optionally writes kbld lock file with imported images
+	LockOutput string
+	// Concurrency defaults to 5
+	Concurrency int
+
+	IncludeNonDistributable bool
+}
+
+type UnpackageResult struct {
+	// Resources are updated to refer to imported images
+	Resources [][]byte
+}
+
+// Unpackage imports images from a tarball into a repository
+func Unpackage(opts UnpackageOpts) (UnpackageResult, error) {
+	if len(opts.InputPath) == 0 {
+		return UnpackageResult{}, fmt.Errorf("Expected input path to be non-empty")
+	}
+
+	importRepo, err := regname.NewRepository(opts.Repository)
+	if err != nil {
+		return UnpackageResult{}, fmt.Errorf("Building import repository ref: %s", err)
+	}
+
+	nonConfigRs, conf, err := opts.Inputs.resourcesAndConf()
+	if err != nil {
+		return UnpackageResult{}, err
+	}
+
+	registry, err := opts.Registry.registry(opts.IncludeNonDistributable)
+	if err != nil {
+		return UnpackageResult{}, err
+	}
+
+	unpackageOpts := ctlcmd.NewUnpackageOptions(newUI())
+	unpackageOpts.InputPath = opts.InputPath
+	unpackageOpts.Repository = opts.Repository
+	unpackageOpts.LockOutput = opts.LockOutput
+	unpackageOpts.Concurrency = concurrencyWithDefaults(opts.Concurrency)
+	unpackageOpts.IncludeNonDistributable = opts.IncludeNonDistributable
+
+	resBss, err := unpackageOpts.UnpackageResources(nonConfigRs, conf, importRepo, registry, newLogger(opts.Logger))
+	if err != nil {
+		return UnpackageResult{}, err
+	}
+
+	return UnpackageResult{Resources: resBss}, nil
+}
+
+func concurrencyWithDefaults(concurrency int) int {
+	if concurrency > 0 {
+		return concurrency
+	}
+	return 5
+}
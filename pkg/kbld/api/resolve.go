@@ -0,0 +1,69 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+type ResolveOpts struct {
+	Inputs   Inputs
+	Registry RegistryOpts
+	Logger   Logger
+
+	// AllowedToBuild allows building images for configured sources
+	AllowedToBuild bool
+	// BuildConcurrency defaults to 4
+	BuildConcurrency int
+	// SkipImagesAnnotation and SkipOriginsAnnotation omit kbld.k14s.io/images annotation
+	// (or only origins within it) from resolved resources
+	SkipImagesAnnotation  bool
+	SkipOriginsAnnotation bool
+	// Platform optionally selects platform from image indexes (e.g. linux/amd64)
+	Platform string
+	// LockOutput optionally writes kbld lock file with resolved images
+	LockOutput string
+}
+
+type ResolveResult struct {
+	// Resources are resolved resources (as YAML) in the same order as inputs
+	Resources [][]byte
+	Images    []Image
+}
+
+// Resolve builds (if allowed) and resolves images found in inputs
+// and returns resources updated to refer to images by digest
+func Resolve(opts ResolveOpts) (ResolveResult, error) {
+	nonConfigRs, conf, err := opts.Inputs.resourcesAndConf()
+	if err != nil {
+		return ResolveResult{}, err
+	}
+
+	registry, err := opts.Registry.registry(false)
+	if err != nil {
+		return ResolveResult{}, err
+	}
+
+	resolveOpts := ctlcmd.NewResolveOptions(newUI()).WithRegistry(registry)
+	resolveOpts.AllowedToBuild = opts.AllowedToBuild
+	resolveOpts.BuildConcurrency = buildConcurrencyWithDefaults(opts.BuildConcurrency)
+	resolveOpts.ImagesAnnotation = !opts.SkipImagesAnnotation
+	resolveOpts.OriginsAnnotation = !opts.SkipOriginsAnnotation
+	resolveOpts.Platform = opts.Platform
+	resolveOpts.LockOutput = opts.LockOutput
+
+	resBss, resolvedImages, err := resolveOpts.ResolveConfiguredResources(nonConfigRs, conf, newLogger(opts.Logger))
+	if err != nil {
+		return ResolveResult{}, err
+	}
+
+	return ResolveResult{Resources: resBss, Images: images(resolvedImages)}, nil
+}
+
+func buildConcurrencyWithDefaults(concurrency int) int {
+	if concurrency > 0 {
+		return concurrency
+	}
+	return 4
+}
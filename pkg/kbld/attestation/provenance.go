@@ -0,0 +1,91 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package attestation
+
+import (
+	"time"
+
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/version"
+)
+
+const (
+	ProvenancePredicateType = "https://slsa.dev/provenance/v1"
+	ProvenanceBuildType     = "https://carvel.dev/kbld/build/v1"
+	ProvenanceBuilderID     = "https://carvel.dev/kbld"
+)
+
+// Provenance is a SLSA v1 provenance predicate
+type Provenance struct {
+	BuildDefinition ProvenanceBuildDefinition `json:"buildDefinition"`
+	RunDetails      ProvenanceRunDetails      `json:"runDetails"`
+}
+
+type ProvenanceBuildDefinition struct {
+	BuildType            string                 `json:"buildType"`
+	ExternalParameters   map[string]interface{} `json:"externalParameters"`
+	ResolvedDependencies []ResourceDescriptor   `json:"resolvedDependencies,omitempty"`
+}
+
+type ResourceDescriptor struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+	Name   string            `json:"name,omitempty"`
+}
+
+type ProvenanceRunDetails struct {
+	Builder  ProvenanceBuilder  `json:"builder"`
+	Metadata ProvenanceMetadata `json:"metadata"`
+}
+
+type ProvenanceBuilder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version,omitempty"`
+}
+
+type ProvenanceMetadata struct {
+	StartedOn  string `json:"startedOn"`
+	FinishedOn string `json:"finishedOn"`
+}
+
+// ProvenanceOpts describes a single image build
+type ProvenanceOpts struct {
+	// Builder is a name of builder used (e.g. docker, pack)
+	Builder string
+	// BuilderOpts are builder specific options as configured
+	BuilderOpts interface{}
+	SourcePath  string
+
+	Dependencies []ResourceDescriptor
+
+	StartedOn  time.Time
+	FinishedOn time.Time
+}
+
+func NewProvenance(opts ProvenanceOpts) Provenance {
+	params := map[string]interface{}{
+		"source":  opts.SourcePath,
+		"builder": opts.Builder,
+	}
+	if opts.BuilderOpts != nil {
+		params["builderOptions"] = opts.BuilderOpts
+	}
+
+	return Provenance{
+		BuildDefinition: ProvenanceBuildDefinition{
+			BuildType:            ProvenanceBuildType,
+			ExternalParameters:   params,
+			ResolvedDependencies: opts.Dependencies,
+		},
+		RunDetails: ProvenanceRunDetails{
+			Builder: ProvenanceBuilder{
+				ID:      ProvenanceBuilderID,
+				Version: map[string]string{"kbld": version.Version},
+			},
+			Metadata: ProvenanceMetadata{
+				StartedOn:  opts.StartedOn.UTC().Format(time.RFC3339),
+				FinishedOn: opts.FinishedOn.UTC().Format(time.RFC3339),
+			},
+		},
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package attestation
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+)
+
+// SBOMGenerator produces SBOMs using syft or configured command
+type SBOMGenerator struct {
+	logger ctllog.Logger
+}
+
+func NewSBOMGenerator(logger ctllog.Logger) SBOMGenerator {
+	return SBOMGenerator{logger}
+}
+
+// Generate writes SBOM for given digest url to outputPath
+func (g SBOMGenerator) Generate(url string, opts ctlconf.SourceSBOMOpts, outputPath string) error {
+	prefixedLogger := g.logger.NewPrefixedWriter(url + " | ")
+
+	format := opts.FormatWithDefaults()
+
+	var cmd *exec.Cmd
+
+	if len(opts.Command) > 0 {
+		prefixedLogger.Write([]byte(fmt.Sprintf("starting SBOM generation (using %s)\n", opts.Command[0])))
+		cmd = exec.Command(opts.Command[0], opts.Command[1:]...)
+	} else {
+		prefixedLogger.Write([]byte("starting SBOM generation (using syft)\n"))
+		cmd = exec.Command("syft", "registry:"+url, "--output", SyftFormat(format)+"="+outputPath)
+	}
+
+	defer prefixedLogger.Write([]byte("finished SBOM generation\n"))
+
+	cmd.Env = append(os.Environ(),
+		"KBLD_SBOM_IMAGE="+url,
+		"KBLD_SBOM_FORMAT="+format,
+		"KBLD_SBOM_OUTPUT="+outputPath,
+	)
+	cmd.Stdout = prefixedLogger
+	cmd.Stderr = prefixedLogger
+
+	err := cmd.Run()
+	if err != nil {
+		prefixedLogger.Write([]byte(fmt.Sprintf("error: %s\n", err)))
+		return fmt.Errorf("Generating SBOM for '%s': %s", url, err)
+	}
+
+	if _, err := os.Stat(outputPath); err != nil {
+		return fmt.Errorf("Expected SBOM for '%s' to be written to '%s': %s", url, outputPath, err)
+	}
+
+	return nil
+}
+
+// SyftFormat returns syft output format name
+func SyftFormat(format string) string {
+	if format == ctlconf.SBOMFormatSPDX {
+		return "spdx-json"
+	}
+	return "cyclonedx-json"
+}
+
+// SBOMPredicateType returns cosign attestation type
+func SBOMPredicateType(format string) string {
+	if format == ctlconf.SBOMFormatSPDX {
+		return "spdxjson"
+	}
+	return "cyclonedx"
+}
+
+// SBOMFileSuffix returns file suffix used for SBOMs in output directory
+func SBOMFileSuffix(format string) string {
+	if format == ctlconf.SBOMFormatSPDX {
+		return "sbom.spdx.json"
+	}
+	return "sbom.cdx.json"
+}
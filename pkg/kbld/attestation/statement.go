@@ -0,0 +1,91 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package attestation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+)
+
+const (
+	StatementType = "https://in-toto.io/Statement/v1"
+)
+
+// Statement is an in-toto attestation statement
+type Statement struct {
+	Type          string      `json:"_type"`
+	Subject       []Subject   `json:"subject"`
+	PredicateType string      `json:"predicateType"`
+	Predicate     interface{} `json:"predicate"`
+}
+
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+func NewStatement(url, predicateType string, predicate interface{}) (Statement, error) {
+	subject, err := NewSubject(url)
+	if err != nil {
+		return Statement{}, err
+	}
+
+	return Statement{
+		Type:          StatementType,
+		Subject:       []Subject{subject},
+		PredicateType: predicateType,
+		Predicate:     predicate,
+	}, nil
+}
+
+func NewSubject(url string) (Subject, error) {
+	ref, err := regname.NewDigest(url, regname.WeakValidation)
+	if err != nil {
+		return Subject{}, fmt.Errorf("Expected image '%s' to be a digest reference: %s", url, err)
+	}
+
+	pieces := strings.SplitN(ref.DigestStr(), ":", 2)
+
+	return Subject{
+		Name:   ref.Context().Name(),
+		Digest: map[string]string{pieces[0]: pieces[1]},
+	}, nil
+}
+
+// FileName returns file name derived from image digest
+// (e.g. sha256-<hex>.<suffix>)
+func FileName(url, suffix string) (string, error) {
+	ref, err := regname.NewDigest(url, regname.WeakValidation)
+	if err != nil {
+		return "", fmt.Errorf("Expected image '%s' to be a digest reference: %s", url, err)
+	}
+	return strings.Replace(ref.DigestStr(), ":", "-", 1) + "." + suffix, nil
+}
+
+// WriteJSON writes value into given directory (creating it if necessary)
+func WriteJSON(dirPath, fileName string, val interface{}) (string, error) {
+	bs, err := json.MarshalIndent(val, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	err = os.MkdirAll(dirPath, 0700)
+	if err != nil {
+		return "", fmt.Errorf("Creating directory '%s': %s", dirPath, err)
+	}
+
+	path := filepath.Join(dirPath, fileName)
+
+	err = os.WriteFile(path, append(bs, '\n'), 0600)
+	if err != nil {
+		return "", fmt.Errorf("Writing file '%s': %s", path, err)
+	}
+
+	return path, nil
+}
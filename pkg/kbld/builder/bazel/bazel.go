@@ -7,7 +7,6 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"os/exec"
 	"regexp"
 
 	ctlbdk "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/docker"
@@ -52,12 +51,16 @@ func (b *Bazel) Run(image, directory string, opts config.SourceBazelRunOpts) (ct
 			cmdArgs = append(cmdArgs, *opts.RawOptions...)
 		}
 
-		cmd := exec.Command("bazel", cmdArgs...)
+		cmd := b.docker.Env().Command("bazel", cmdArgs...)
 		cmd.Dir = directory
-		cmd.Stdout = io.MultiWriter(&stdoutBuf, prefixedLogger)
-		cmd.Stderr = io.MultiWriter(&stderrBuf, prefixedLogger)
+		cmdOutput := prefixedLogger.NewCommandOutputWriter()
+		cmd.Stdout = io.MultiWriter(&stdoutBuf, cmdOutput)
+		cmd.Stderr = io.MultiWriter(&stderrBuf, cmdOutput)
+
+		b.docker.Env().RecordInvocation(cmd)
 
 		err := cmd.Run()
+		cmdOutput.Finish(err)
 		if err != nil {
 			prefixedLogger.Write([]byte(fmt.Sprintf("error: %s\n", err)))
 			return ctlbdk.TmpRef{}, err
@@ -0,0 +1,194 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cloudbuild
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	ctlb "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+)
+
+/*
+
+Example:
+
+$ gcloud builds submit . --tag us-docker.pkg.dev/proj/apps/app:tag --format=json
+...snip (build logs are streamed to stderr)...
+{
+  "id": "c3e1b4b8-...",
+  "status": "SUCCESS",
+  "results": {
+    "images": [
+      {
+        "digest": "sha256:cd6d662fcf06854810c83e4bff197ef52e65b39c13baa143107ebd32cc6f8636",
+        "name": "us-docker.pkg.dev/proj/apps/app:tag"
+      }
+    ]
+  }
+}
+
+*/
+
+const (
+	// CloudBuildImageSubstitution is given to build configs
+	CloudBuildImageSubstitution = "_KBLD_IMAGE"
+)
+
+type CloudBuild struct {
+	env    ctlb.Env
+	logger ctllog.Logger
+}
+
+func NewCloudBuild(env ctlb.Env, logger ctllog.Logger) CloudBuild {
+	return CloudBuild{env, logger}
+}
+
+// BuildAndPush submits directory to Cloud Build and
+// returns digest reference of image pushed by the build
+func (d CloudBuild) BuildAndPush(image, directory string,
+	imgDst ctlconf.ImageDestination, opts ctlconf.SourceCloudBuildOpts) (string, error) {
+
+	tagRef, err := d.tagRef(image, imgDst)
+	if err != nil {
+		return "", err
+	}
+
+	prefixedLogger := d.logger.NewPrefixedWriter(image + " | ")
+
+	prefixedLogger.Write([]byte(fmt.Sprintf("starting build (using Cloud Build): %s -> %s\n", directory, tagRef)))
+	defer prefixedLogger.Write([]byte("finished build (using Cloud Build)\n"))
+
+	var stdoutBuf bytes.Buffer
+
+	cmdArgs := []string{"builds", "submit", ".", "--format=json"}
+
+	if opts.Project != nil {
+		cmdArgs = append(cmdArgs, "--project", *opts.Project)
+	}
+	if opts.Region != nil {
+		cmdArgs = append(cmdArgs, "--region", *opts.Region)
+	}
+
+	if opts.Config != nil {
+		// Since gcloud command is executed with cwd of directory,
+		// config path doesnt need to be joined with it
+		cmdArgs = append(cmdArgs, "--config", *opts.Config)
+
+		substitutions := []string{CloudBuildImageSubstitution + "=" + tagRef}
+		for key, val := range opts.Substitutions {
+			substitutions = append(substitutions, key+"="+val)
+		}
+		sort.Strings(substitutions[1:])
+
+		// Custom delimiter since values may contain commas
+		cmdArgs = append(cmdArgs, "--substitutions", "^;^"+strings.Join(substitutions, ";"))
+	} else {
+		cmdArgs = append(cmdArgs, "--tag", tagRef)
+	}
+
+	if opts.RawOptions != nil {
+		cmdArgs = append(cmdArgs, *opts.RawOptions...)
+	}
+
+	cmd := d.env.Command("gcloud", cmdArgs...)
+	cmd.Dir = directory
+	cmd.Stdout = &stdoutBuf
+	cmdOutput := prefixedLogger.NewCommandOutputWriter()
+	cmd.Stderr = cmdOutput
+
+	d.env.RecordInvocation(cmd)
+
+	err = cmd.Run()
+	cmdOutput.Finish(err)
+	if err != nil {
+		prefixedLogger.Write([]byte(fmt.Sprintf("error: %s\n", err)))
+		return "", err
+	}
+
+	digest, err := CloudBuildImageDigest(stdoutBuf.Bytes(), tagRef)
+	if err != nil {
+		return "", err
+	}
+
+	digestRefStr := imgDst.NewImage + "@" + digest
+
+	digestRef, err := regname.NewDigest(digestRefStr, regname.WeakValidation)
+	if err != nil {
+		return "", fmt.Errorf("Validating destination digest ref '%s': %s", digestRefStr, err)
+	}
+
+	return digestRef.Name(), nil
+}
+
+type cloudBuildResult struct {
+	ID      string `json:"id"`
+	Status  string `json:"status"`
+	Results struct {
+		Images []struct {
+			Name   string `json:"name"`
+			Digest string `json:"digest"`
+		} `json:"images"`
+	} `json:"results"`
+}
+
+// CloudBuildImageDigest returns digest of given image pushed by build
+// (build is described by 'gcloud builds submit --format=json' output)
+func CloudBuildImageDigest(buildBs []byte, tagRef string) (string, error) {
+	var result cloudBuildResult
+
+	// Output may be preceded by messages (e.g. about uploaded sources)
+	start := bytes.IndexByte(buildBs, '{')
+	if start < 0 {
+		return "", fmt.Errorf("Expected to find build description in gcloud output but did not")
+	}
+
+	err := json.Unmarshal(buildBs[start:], &result)
+	if err != nil {
+		return "", fmt.Errorf("Unmarshaling build description: %s", err)
+	}
+
+	for _, img := range result.Results.Images {
+		if img.Name == tagRef && len(img.Digest) > 0 {
+			return img.Digest, nil
+		}
+	}
+
+	return "", fmt.Errorf("Expected build '%s' to push image '%s' but did not "+
+		"(hint: list %s in images of build config)", result.ID, tagRef, "$"+CloudBuildImageSubstitution)
+}
+
+func (d CloudBuild) tagRef(image string, imgDst ctlconf.ImageDestination) (string, error) {
+	tb := ctlb.TagBuilder{}
+
+	randPrefix50, err := tb.RandomStr50()
+	if err != nil {
+		return "", fmt.Errorf("Generating tmp image suffix: %s", err)
+	}
+
+	tag := tb.CheckTagLen128(fmt.Sprintf(
+		"%s-%s",
+		randPrefix50,
+		tb.TrimStr(tb.CleanStr(image), 50),
+	))
+
+	if intermediateTag := d.env.IntermediateTag(); len(intermediateTag) > 0 {
+		tag = intermediateTag
+	}
+
+	tagRef := imgDst.NewImage + ":" + tag
+
+	_, err = regname.NewTag(tagRef, regname.WeakValidation)
+	if err != nil {
+		return "", fmt.Errorf("Validating destination tag ref '%s': %s", tagRef, err)
+	}
+
+	return tagRef, nil
+}
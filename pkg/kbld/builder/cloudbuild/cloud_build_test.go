@@ -0,0 +1,40 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cloudbuild_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlbcb "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/cloudbuild"
+)
+
+func TestCloudBuildImageDigest(t *testing.T) {
+	output := []byte(`Creating temporary archive of 3 file(s) totalling 1.2 KiB before compression.
+{
+  "id": "c3e1b4b8",
+  "status": "SUCCESS",
+  "results": {
+    "images": [
+      {"digest": "sha256:0000000000000000000000000000000000000000000000000000000000000001", "name": "us-docker.pkg.dev/proj/apps/other:tag"},
+      {"digest": "sha256:0000000000000000000000000000000000000000000000000000000000000002", "name": "us-docker.pkg.dev/proj/apps/app:tag"}
+    ]
+  }
+}
+`)
+
+	digest, err := ctlbcb.CloudBuildImageDigest(output, "us-docker.pkg.dev/proj/apps/app:tag")
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:0000000000000000000000000000000000000000000000000000000000000002", digest)
+
+	_, err = ctlbcb.CloudBuildImageDigest(output, "us-docker.pkg.dev/proj/apps/missing:tag")
+	require.Error(t, err)
+	assert.Equal(t, "Expected build 'c3e1b4b8' to push image 'us-docker.pkg.dev/proj/apps/missing:tag' "+
+		"but did not (hint: list $_KBLD_IMAGE in images of build config)", err.Error())
+
+	_, err = ctlbcb.CloudBuildImageDigest([]byte("ERROR: build failed"), "us-docker.pkg.dev/proj/apps/app:tag")
+	require.Error(t, err)
+	assert.Equal(t, "Expected to find build description in gcloud output but did not", err.Error())
+}
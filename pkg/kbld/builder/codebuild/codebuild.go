@@ -0,0 +1,365 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package codebuild
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	ctlb "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+)
+
+/*
+
+Example:
+
+$ aws s3 cp /tmp/src.zip s3://bucket/kbld/src.zip
+$ aws codebuild start-build --project-name apps --source-type-override S3 \
+    --source-location-override bucket/kbld/src.zip \
+    --environment-variables-override name=KBLD_IMAGE,value=registry.corp/app:tag,type=PLAINTEXT
+{"build": {"id": "apps:9a7c...", "buildStatus": "IN_PROGRESS", ...}}
+
+$ aws codebuild batch-get-builds --ids apps:9a7c...
+{
+  "builds": [
+    {
+      "id": "apps:9a7c...",
+      "buildStatus": "SUCCEEDED",
+      "currentPhase": "COMPLETED",
+      "exportedEnvironmentVariables": [
+        {"name": "KBLD_IMAGE_DIGEST", "value": "sha256:cd6d662f..."}
+      ],
+      "logs": {"deepLink": "https://console.aws.amazon.com/cloudwatch/..."}
+    }
+  ]
+}
+
+*/
+
+const (
+	// CodeBuildImageEnv is given to builds
+	CodeBuildImageEnv = "KBLD_IMAGE"
+	// CodeBuildImageDigestEnv is expected to be exported by builds
+	CodeBuildImageDigestEnv = "KBLD_IMAGE_DIGEST"
+
+	codeBuildStatusInProgress = "IN_PROGRESS"
+	codeBuildStatusSucceeded  = "SUCCEEDED"
+)
+
+type CodeBuild struct {
+	env          ctlb.Env
+	logger       ctllog.Logger
+	pollInterval time.Duration
+}
+
+func NewCodeBuild(env ctlb.Env, logger ctllog.Logger) CodeBuild {
+	return CodeBuild{env, logger, 10 * time.Second}
+}
+
+// BuildAndPush uploads directory to S3, starts build of CodeBuild project,
+// waits for it to complete and returns digest reference of pushed image
+func (d CodeBuild) BuildAndPush(image, directory string,
+	imgDst ctlconf.ImageDestination, opts ctlconf.SourceCodeBuildOpts) (string, error) {
+
+	tb := ctlb.TagBuilder{}
+
+	randPrefix50, err := tb.RandomStr50()
+	if err != nil {
+		return "", fmt.Errorf("Generating tmp image suffix: %s", err)
+	}
+
+	tag := tb.CheckTagLen128(fmt.Sprintf(
+		"%s-%s",
+		randPrefix50,
+		tb.TrimStr(tb.CleanStr(image), 50),
+	))
+
+	if intermediateTag := d.env.IntermediateTag(); len(intermediateTag) > 0 {
+		tag = intermediateTag
+	}
+
+	tagRef := imgDst.NewImage + ":" + tag
+
+	_, err = regname.NewTag(tagRef, regname.WeakValidation)
+	if err != nil {
+		return "", fmt.Errorf("Validating destination tag ref '%s': %s", tagRef, err)
+	}
+
+	prefixedLogger := d.logger.NewPrefixedWriter(image + " | ")
+
+	prefixedLogger.Write([]byte(fmt.Sprintf("starting build (using CodeBuild): %s -> %s\n", directory, tagRef)))
+	defer prefixedLogger.Write([]byte("finished build (using CodeBuild)\n"))
+
+	sourceLocation := strings.TrimSuffix(opts.SourceBucket, "/") + "/kbld-" + randPrefix50 + ".zip"
+
+	err = d.uploadSource(directory, sourceLocation, opts, prefixedLogger)
+	if err != nil {
+		return "", err
+	}
+	defer d.aws(opts, nil, prefixedLogger, "s3", "rm", "s3://"+sourceLocation)
+
+	cmdArgs := []string{"codebuild", "start-build", "--project-name", opts.Project,
+		"--source-type-override", "S3", "--source-location-override", sourceLocation}
+
+	if opts.Buildspec != nil {
+		cmdArgs = append(cmdArgs, "--buildspec-override", *opts.Buildspec)
+	}
+
+	cmdArgs = append(cmdArgs, "--environment-variables-override")
+	cmdArgs = append(cmdArgs, d.envOverrides(tagRef, opts.Env)...)
+
+	if opts.RawOptions != nil {
+		cmdArgs = append(cmdArgs, *opts.RawOptions...)
+	}
+
+	var stdoutBuf bytes.Buffer
+
+	err = d.aws(opts, &stdoutBuf, prefixedLogger, cmdArgs...)
+	if err != nil {
+		return "", err
+	}
+
+	var started struct {
+		Build codeBuildBuild `json:"build"`
+	}
+
+	err = json.Unmarshal(stdoutBuf.Bytes(), &started)
+	if err != nil {
+		return "", fmt.Errorf("Unmarshaling started build: %s", err)
+	}
+
+	build, err := d.wait(started.Build, opts, prefixedLogger)
+	if err != nil {
+		return "", err
+	}
+
+	digest, err := build.ImageDigest()
+	if err != nil {
+		return "", err
+	}
+
+	digestRefStr := imgDst.NewImage + "@" + digest
+
+	digestRef, err := regname.NewDigest(digestRefStr, regname.WeakValidation)
+	if err != nil {
+		return "", fmt.Errorf("Validating destination digest ref '%s': %s", digestRefStr, err)
+	}
+
+	return digestRef.Name(), nil
+}
+
+func (d CodeBuild) uploadSource(directory, sourceLocation string,
+	opts ctlconf.SourceCodeBuildOpts, logger *ctllog.PrefixWriter) error {
+
+	zipFile, err := os.CreateTemp("", "kbld-codebuild-source")
+	if err != nil {
+		return fmt.Errorf("Creating source archive: %s", err)
+	}
+	defer os.Remove(zipFile.Name())
+
+	err = ZipDirectory(directory, zipFile)
+	zipFile.Close()
+	if err != nil {
+		return fmt.Errorf("Archiving source directory '%s': %s", directory, err)
+	}
+
+	return d.aws(opts, nil, logger, "s3", "cp", "--quiet", zipFile.Name(), "s3://"+sourceLocation)
+}
+
+func (d CodeBuild) wait(build codeBuildBuild, opts ctlconf.SourceCodeBuildOpts,
+	logger *ctllog.PrefixWriter) (codeBuildBuild, error) {
+
+	lastPhase := ""
+
+	for {
+		if build.CurrentPhase != lastPhase {
+			logger.Write([]byte(fmt.Sprintf("build %s: %s\n", build.ID, build.CurrentPhase)))
+			lastPhase = build.CurrentPhase
+		}
+
+		if build.BuildStatus != codeBuildStatusInProgress && len(build.BuildStatus) > 0 {
+			break
+		}
+
+		time.Sleep(d.pollInterval)
+
+		var stdoutBuf bytes.Buffer
+
+		err := d.aws(opts, &stdoutBuf, logger, "codebuild", "batch-get-builds", "--ids", build.ID)
+		if err != nil {
+			return codeBuildBuild{}, err
+		}
+
+		build, err = newCodeBuildBuild(stdoutBuf.Bytes())
+		if err != nil {
+			return codeBuildBuild{}, err
+		}
+	}
+
+	if len(build.Logs.DeepLink) > 0 {
+		logger.Write([]byte(fmt.Sprintf("build logs: %s\n", build.Logs.DeepLink)))
+	}
+
+	if build.BuildStatus != codeBuildStatusSucceeded {
+		return codeBuildBuild{}, fmt.Errorf("Expected build '%s' to succeed, but was '%s'", build.ID, build.BuildStatus)
+	}
+
+	return build, nil
+}
+
+func (d CodeBuild) envOverrides(tagRef string, env map[string]string) []string {
+	var names []string
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := []string{fmt.Sprintf("name=%s,value=%s,type=PLAINTEXT", CodeBuildImageEnv, tagRef)}
+	for _, name := range names {
+		result = append(result, fmt.Sprintf("name=%s,value=%s,type=PLAINTEXT", name, env[name]))
+	}
+	return result
+}
+
+func (d CodeBuild) aws(opts ctlconf.SourceCodeBuildOpts, stdout io.Writer,
+	logger *ctllog.PrefixWriter, args ...string) error {
+
+	args = append(args, "--output", "json")
+	if opts.Region != nil {
+		args = append(args, "--region", *opts.Region)
+	}
+
+	cmd := d.env.Command("aws", args...)
+	cmd.Stdout = stdout
+	cmdOutput := logger.NewCommandOutputWriter()
+	cmd.Stderr = cmdOutput
+
+	// Other commands (e.g. uploading source) are not part of build itself
+	if args[0] == "codebuild" && args[1] == "start-build" {
+		d.env.RecordInvocation(cmd)
+	}
+
+	err := cmd.Run()
+	cmdOutput.Finish(err)
+	if err != nil {
+		logger.Write([]byte(fmt.Sprintf("error: %s\n", err)))
+		return fmt.Errorf("Running 'aws %s': %s", strings.Join(args[:2], " "), err)
+	}
+
+	return nil
+}
+
+type codeBuildBuild struct {
+	ID           string `json:"id"`
+	BuildStatus  string `json:"buildStatus"`
+	CurrentPhase string `json:"currentPhase"`
+
+	ExportedEnvironmentVariables []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"exportedEnvironmentVariables"`
+
+	Logs struct {
+		DeepLink string `json:"deepLink"`
+	} `json:"logs"`
+}
+
+func (b codeBuildBuild) ImageDigest() (string, error) {
+	for _, envVar := range b.ExportedEnvironmentVariables {
+		if envVar.Name == CodeBuildImageDigestEnv && len(envVar.Value) > 0 {
+			return envVar.Value, nil
+		}
+	}
+	return "", fmt.Errorf("Expected build '%s' to export %s variable but did not "+
+		"(hint: add it to env.exported-variables of buildspec)", b.ID, CodeBuildImageDigestEnv)
+}
+
+// CodeBuildImageDigest returns digest exported by build
+// (build is described by 'aws codebuild batch-get-builds' output)
+func CodeBuildImageDigest(buildsBs []byte) (string, error) {
+	build, err := newCodeBuildBuild(buildsBs)
+	if err != nil {
+		return "", err
+	}
+	return build.ImageDigest()
+}
+
+func newCodeBuildBuild(buildsBs []byte) (codeBuildBuild, error) {
+	var builds struct {
+		Builds []codeBuildBuild `json:"builds"`
+	}
+
+	err := json.Unmarshal(buildsBs, &builds)
+	if err != nil {
+		return codeBuildBuild{}, fmt.Errorf("Unmarshaling build: %s", err)
+	}
+	if len(builds.Builds) != 1 {
+		return codeBuildBuild{}, fmt.Errorf("Expected to find one build, but found %d", len(builds.Builds))
+	}
+
+	return builds.Builds[0], nil
+}
+
+// ZipDirectory archives regular files of directory (except .git)
+func ZipDirectory(directory string, w io.Writer) error {
+	zipWriter := zip.NewWriter(w)
+
+	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(directory, path)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+		header.Method = zip.Deflate
+
+		fileWriter, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(fileWriter, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return zipWriter.Close()
+}
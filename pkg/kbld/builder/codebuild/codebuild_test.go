@@ -0,0 +1,60 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package codebuild_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlbcd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/codebuild"
+)
+
+func TestCodeBuildImageDigest(t *testing.T) {
+	digest, err := ctlbcd.CodeBuildImageDigest([]byte(`{
+  "builds": [
+    {
+      "id": "apps:9a7c",
+      "buildStatus": "SUCCEEDED",
+      "exportedEnvironmentVariables": [
+        {"name": "OTHER", "value": "val"},
+        {"name": "KBLD_IMAGE_DIGEST", "value": "sha256:0000000000000000000000000000000000000000000000000000000000000001"}
+      ]
+    }
+  ]
+}`))
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:0000000000000000000000000000000000000000000000000000000000000001", digest)
+
+	_, err = ctlbcd.CodeBuildImageDigest([]byte(`{"builds": [{"id": "apps:9a7c", "buildStatus": "SUCCEEDED"}]}`))
+	require.Error(t, err)
+	assert.Equal(t, "Expected build 'apps:9a7c' to export KBLD_IMAGE_DIGEST variable but did not "+
+		"(hint: add it to env.exported-variables of buildspec)", err.Error())
+}
+
+func TestZipDirectory(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "src"), 0700))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".git"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM scratch\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("package main\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref: refs/heads/main\n"), 0600))
+
+	var buf bytes.Buffer
+	require.NoError(t, ctlbcd.ZipDirectory(dir, &buf))
+
+	zipReader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	var names []string
+	for _, file := range zipReader.File {
+		names = append(names, file.Name)
+	}
+	assert.Equal(t, []string{"Dockerfile", "src/main.go"}, names)
+}
@@ -0,0 +1,234 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package depot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	ctlb "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder"
+	ctlbdk "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/docker"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+)
+
+/*
+
+Example:
+
+$ DEPOT_TOKEN=... depot build . --project 1x2y3z --platform linux/amd64,linux/arm64 \
+    --tag registry.corp/app:tag --push --metadata-file /tmp/metadata.json --progress=plain
+...snip...
+[+] Building 32.1s (24/24) FINISHED
+ => pushing manifest for registry.corp/app:tag@sha256:8db4e06667f35cc30b101936f2f8f4a6a846f71ef4a7e4112602fd324f63fd65
+
+$ cat /tmp/metadata.json
+{
+  "containerimage.descriptor": {...},
+  "containerimage.digest": "sha256:8db4e06667f35cc30b101936f2f8f4a6a846f71ef4a7e4112602fd324f63fd65",
+  "depot.build": {"buildID": "...", "projectID": "1x2y3z"},
+  "image.name": "registry.corp/app:tag"
+}
+
+*/
+
+const (
+	depotTokenEnv       = "DEPOT_TOKEN"
+	depotMetadataDigest = "containerimage.digest"
+)
+
+type Depot struct {
+	docker ctlbdk.Docker
+	logger ctllog.Logger
+}
+
+func NewDepot(docker ctlbdk.Docker, logger ctllog.Logger) Depot {
+	return Depot{docker, logger}
+}
+
+// BuildAndOptionallyPush either loads built image into Docker daemon
+// or pushes it (pushing from Depot builders) to specified registry.
+func (d Depot) BuildAndOptionallyPush(
+	image, directory string, imgDst *ctlconf.ImageDestination,
+	opts ctlconf.SourceDepotOpts) (string, error) {
+
+	if imgDst == nil && len(opts.Platforms) > 1 {
+		return "", fmt.Errorf("Expected image destination to be configured for multi-platform builds " +
+			"since they cannot be loaded into Docker daemon")
+	}
+
+	env, err := d.env(opts)
+	if err != nil {
+		return "", err
+	}
+
+	tagRef, err := d.tagRef(image, imgDst)
+	if err != nil {
+		return "", err
+	}
+
+	metadataFile, err := os.CreateTemp("", "kbld-depot-metadata")
+	if err != nil {
+		return "", fmt.Errorf("Creating depot metadata file: %s", err)
+	}
+	metadataFile.Close()
+	defer os.Remove(metadataFile.Name())
+
+	prefixedLogger := d.logger.NewPrefixedWriter(image + " | ")
+
+	prefixedLogger.Write([]byte(fmt.Sprintf("starting build (using Depot): %s -> %s\n", directory, tagRef)))
+	defer prefixedLogger.Write([]byte("finished build (using Depot)\n"))
+
+	{
+		cmdArgs := []string{"build", "--progress=plain", "--metadata-file", metadataFile.Name()}
+
+		if opts.Project != nil {
+			cmdArgs = append(cmdArgs, "--project", *opts.Project)
+		}
+		if len(opts.Platforms) > 0 {
+			cmdArgs = append(cmdArgs, "--platform", strings.Join(opts.Platforms, ","))
+		}
+		if opts.Target != nil {
+			cmdArgs = append(cmdArgs, "--target", *opts.Target)
+		}
+		if opts.NoCache != nil && *opts.NoCache {
+			cmdArgs = append(cmdArgs, "--no-cache")
+		}
+		if opts.File != nil {
+			// Since depot command is executed with cwd of directory,
+			// Dockerfile path doesnt need to be joined with it
+			cmdArgs = append(cmdArgs, "--file", *opts.File)
+		}
+		if opts.RawOptions != nil {
+			cmdArgs = append(cmdArgs, *opts.RawOptions...)
+		}
+
+		cmdArgs = append(cmdArgs, "--tag", tagRef, ".")
+
+		// Load built image into Docker daemon, otherwise it's not being used anywhere
+		if imgDst != nil {
+			cmdArgs = append(cmdArgs, "--push")
+		} else {
+			cmdArgs = append(cmdArgs, "--load")
+		}
+
+		cmd := d.docker.Env().Command("depot", cmdArgs...)
+		cmd.Dir = directory
+		cmd.Env = env
+		cmdOutput := prefixedLogger.NewCommandOutputWriter()
+		cmd.Stdout = cmdOutput
+		cmd.Stderr = cmdOutput
+
+		d.docker.Env().RecordInvocation(cmd)
+
+		err := cmd.Run()
+		cmdOutput.Finish(err)
+		if err != nil {
+			prefixedLogger.Write([]byte(fmt.Sprintf("error: %s\n", err)))
+			return "", err
+		}
+	}
+
+	if imgDst != nil {
+		metadataBs, err := os.ReadFile(metadataFile.Name())
+		if err != nil {
+			return "", fmt.Errorf("Reading depot metadata file: %s", err)
+		}
+
+		digest, err := DepotMetadataDigest(metadataBs)
+		if err != nil {
+			return "", err
+		}
+
+		digestRefStr := imgDst.NewImage + "@" + digest
+
+		digestRef, err := regname.NewDigest(digestRefStr, regname.WeakValidation)
+		if err != nil {
+			return "", fmt.Errorf("Validating destination digest ref '%s': %s", digestRefStr, err)
+		}
+
+		return digestRef.Name(), nil
+	}
+
+	// Work with locally stored image in Docker daemon
+	inspectData, err := d.docker.Inspect(tagRef)
+	if err != nil {
+		prefixedLogger.Write([]byte(fmt.Sprintf("inspect error: %s\n", err)))
+		return "", err
+	}
+
+	tmpRef, err := d.docker.RetagStable(ctlbdk.NewTmpRef(tagRef), image, inspectData.ID, prefixedLogger)
+	if err != nil {
+		return "", err
+	}
+
+	return tmpRef.AsString(), nil
+}
+
+// env provides API token to depot CLI from configured env variable
+func (d Depot) env(opts ctlconf.SourceDepotOpts) ([]string, error) {
+	if opts.TokenEnv == nil {
+		return d.docker.Env().Vars(), nil
+	}
+
+	token := os.Getenv(*opts.TokenEnv)
+	if len(token) == 0 {
+		return nil, fmt.Errorf("Expected env variable '%s' to provide Depot API token, but was empty", *opts.TokenEnv)
+	}
+
+	return d.docker.Env().With(depotTokenEnv + "=" + token), nil
+}
+
+func (d Depot) tagRef(image string, imgDst *ctlconf.ImageDestination) (string, error) {
+	tb := ctlb.TagBuilder{}
+
+	randPrefix50, err := tb.RandomStr50()
+	if err != nil {
+		return "", fmt.Errorf("Generating tmp image suffix: %s", err)
+	}
+
+	tag := tb.CheckTagLen128(fmt.Sprintf(
+		"%s-%s",
+		randPrefix50,
+		tb.TrimStr(tb.CleanStr(image), 50),
+	))
+
+	if imgDst != nil {
+		if intermediateTag := d.docker.Env().IntermediateTag(); len(intermediateTag) > 0 {
+			tag = intermediateTag
+		}
+
+		tagRef := imgDst.NewImage + ":" + tag
+
+		_, err := regname.NewTag(tagRef, regname.WeakValidation)
+		if err != nil {
+			return "", fmt.Errorf("Validating destination tag ref '%s': %s", tagRef, err)
+		}
+
+		return tagRef, nil
+	}
+
+	return "kbld:" + tag, nil
+}
+
+// DepotMetadataDigest returns digest of pushed image (index for
+// multi-platform builds) recorded in depot metadata (--metadata-file)
+func DepotMetadataDigest(metadataBs []byte) (string, error) {
+	var metadata map[string]interface{}
+
+	err := json.Unmarshal(metadataBs, &metadata)
+	if err != nil {
+		return "", fmt.Errorf("Unmarshaling depot metadata: %s", err)
+	}
+
+	digest, _ := metadata[depotMetadataDigest].(string)
+	if len(digest) == 0 {
+		return "", fmt.Errorf("Expected to find image digest in depot metadata but did not")
+	}
+
+	return digest, nil
+}
@@ -0,0 +1,27 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package depot_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlbdp "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/depot"
+)
+
+func TestDepotMetadataDigest(t *testing.T) {
+	digest, err := ctlbdp.DepotMetadataDigest([]byte(`{
+  "buildx.build.warnings": [],
+  "containerimage.descriptor": {"mediaType": "application/vnd.oci.image.index.v1+json"},
+  "containerimage.digest": "sha256:8db4e06667f35cc30b101936f2f8f4a6a846f71ef4a7e4112602fd324f63fd65",
+  "depot.build": {"buildID": "b1", "projectID": "1x2y3z"},
+  "image.name": "registry.corp/app:tag"
+}`))
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:8db4e06667f35cc30b101936f2f8f4a6a846f71ef4a7e4112602fd324f63fd65", digest)
+
+	_, err = ctlbdp.DepotMetadataDigest([]byte(`{"image.name": "registry.corp/app:tag"}`))
+	require.EqualError(t, err, "Expected to find image digest in depot metadata but did not")
+}
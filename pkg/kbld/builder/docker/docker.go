@@ -9,7 +9,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"strings"
 
 	regname "github.com/google/go-containerregistry/pkg/name"
@@ -18,6 +17,7 @@ import (
 )
 
 type Docker struct {
+	env    ctlb.Env
 	logger ctllog.Logger
 }
 
@@ -47,10 +47,13 @@ type ImageDigest struct {
 
 func (r ImageDigest) AsString() string { return r.val }
 
-func New(logger ctllog.Logger) Docker {
-	return Docker{logger}
+func New(env ctlb.Env, logger ctllog.Logger) Docker {
+	return Docker{env, logger}
 }
 
+// Env returns environment of docker commands (also used by builders relying on Docker)
+func (d Docker) Env() ctlb.Env { return d.env }
+
 func (d Docker) Build(image, directory string, opts BuildOpts) (TmpRef, error) {
 	err := d.ensureDirectory(directory)
 	if err != nil {
@@ -100,16 +103,20 @@ func (d Docker) Build(image, directory string, opts BuildOpts) (TmpRef, error) {
 
 		cmdArgs = append(cmdArgs, "--tag", tmpRef.AsString(), ".")
 
-		cmd := exec.Command("docker", cmdArgs...)
+		cmd := d.env.Command("docker", cmdArgs...)
 		cmd.Dir = directory
-		cmd.Stdout = io.MultiWriter(&stdoutBuf, prefixedLogger)
-		cmd.Stderr = io.MultiWriter(&stderrBuf, prefixedLogger)
+		cmdOutput := prefixedLogger.NewCommandOutputWriter()
+		cmd.Stdout = io.MultiWriter(&stdoutBuf, cmdOutput)
+		cmd.Stderr = io.MultiWriter(&stderrBuf, cmdOutput)
 
 		if opts.Buildkit != nil {
-			cmd.Env = append(os.Environ(), "DOCKER_BUILDKIT=1")
+			cmd.Env = d.env.With("DOCKER_BUILDKIT=1")
 		}
 
+		d.env.RecordInvocation(cmd)
+
 		err := cmd.Run()
+		cmdOutput.Finish(err)
 		if err != nil {
 			prefixedLogger.Write([]byte(fmt.Sprintf("error: %s\n", err)))
 			return TmpRef{}, err
@@ -142,11 +149,13 @@ func (d Docker) RetagStable(tmpRef TmpRef, image, imageID string,
 	{
 		var stdoutBuf, stderrBuf bytes.Buffer
 
-		cmd := exec.Command("docker", "tag", tmpRef.AsString(), stableTmpRef.AsString())
-		cmd.Stdout = io.MultiWriter(&stdoutBuf, prefixedLogger)
-		cmd.Stderr = io.MultiWriter(&stderrBuf, prefixedLogger)
+		cmd := d.env.Command("docker", "tag", tmpRef.AsString(), stableTmpRef.AsString())
+		cmdOutput := prefixedLogger.NewCommandOutputWriter()
+		cmd.Stdout = io.MultiWriter(&stdoutBuf, cmdOutput)
+		cmd.Stderr = io.MultiWriter(&stderrBuf, cmdOutput)
 
 		err := cmd.Run()
+		cmdOutput.Finish(err)
 		if err != nil {
 			prefixedLogger.Write([]byte(fmt.Sprintf("tag error: %s\n", err)))
 			return TmpRef{}, err
@@ -158,11 +167,13 @@ func (d Docker) RetagStable(tmpRef TmpRef, image, imageID string,
 	if !strings.HasPrefix(tmpRef.AsString(), "sha256:") {
 		var stdoutBuf, stderrBuf bytes.Buffer
 
-		cmd := exec.Command("docker", "rmi", tmpRef.AsString())
-		cmd.Stdout = io.MultiWriter(&stdoutBuf, prefixedLogger)
-		cmd.Stderr = io.MultiWriter(&stderrBuf, prefixedLogger)
+		cmd := d.env.Command("docker", "rmi", tmpRef.AsString())
+		cmdOutput := prefixedLogger.NewCommandOutputWriter()
+		cmd.Stdout = io.MultiWriter(&stdoutBuf, cmdOutput)
+		cmd.Stderr = io.MultiWriter(&stderrBuf, cmdOutput)
 
 		err := cmd.Run()
+		cmdOutput.Finish(err)
 		if err != nil {
 			prefixedLogger.Write([]byte(fmt.Sprintf("untag error: %s\n", err)))
 			return TmpRef{}, err
@@ -172,12 +183,34 @@ func (d Docker) RetagStable(tmpRef TmpRef, image, imageID string,
 	return stableTmpRef, nil
 }
 
+// Tag adds given tag to locally stored image (e.g. to make it available
+// under the name expected by tools such as Skaffold or Tilt)
+func (d Docker) Tag(tmpRef TmpRef, imageDst string) error {
+	prefixedLogger := d.logger.NewPrefixedWriter(imageDst + " | ")
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+
+	cmd := d.env.Command("docker", "tag", tmpRef.AsString(), imageDst)
+	cmdOutput := prefixedLogger.NewCommandOutputWriter()
+	cmd.Stdout = io.MultiWriter(&stdoutBuf, cmdOutput)
+	cmd.Stderr = io.MultiWriter(&stderrBuf, cmdOutput)
+
+	err := cmd.Run()
+	cmdOutput.Finish(err)
+	if err != nil {
+		prefixedLogger.Write([]byte(fmt.Sprintf("tag error: %s\n", err)))
+		return err
+	}
+
+	return nil
+}
+
 func (d Docker) Push(tmpRef TmpRef, imageDst string) (ImageDigest, error) {
 	prefixedLogger := d.logger.NewPrefixedWriter(imageDst + " | ")
 
 	tb := ctlb.TagBuilder{}
 
-	// Generate random tag for pushed image.
+	// Generate random tag for pushed image (unless intermediate tag is configured).
 	// TODO we are technically polluting registry with new tags.
 	// Unfortunately we do not know digest upfront so cannot use kbld-sha256-... format.
 	imageDstTagged, err := regname.NewTag(imageDst, regname.WeakValidation)
@@ -189,6 +222,10 @@ func (d Docker) Push(tmpRef TmpRef, imageDst string) (ImageDigest, error) {
 
 		imageDstTag := fmt.Sprintf("kbld-%s", randSuffix)
 
+		if intermediateTag := d.env.IntermediateTag(); len(intermediateTag) > 0 {
+			imageDstTag = intermediateTag
+		}
+
 		imageDstTagged, err = regname.NewTag(imageDst+":"+imageDstTag, regname.WeakValidation)
 		if err != nil {
 			return ImageDigest{}, fmt.Errorf("Generating image dst tag '%s': %s", imageDst, err)
@@ -209,11 +246,13 @@ func (d Docker) Push(tmpRef TmpRef, imageDst string) (ImageDigest, error) {
 	{
 		var stdoutBuf, stderrBuf bytes.Buffer
 
-		cmd := exec.Command("docker", "tag", tmpRef.AsString(), imageDst)
-		cmd.Stdout = io.MultiWriter(&stdoutBuf, prefixedLogger)
-		cmd.Stderr = io.MultiWriter(&stderrBuf, prefixedLogger)
+		cmd := d.env.Command("docker", "tag", tmpRef.AsString(), imageDst)
+		cmdOutput := prefixedLogger.NewCommandOutputWriter()
+		cmd.Stdout = io.MultiWriter(&stdoutBuf, cmdOutput)
+		cmd.Stderr = io.MultiWriter(&stderrBuf, cmdOutput)
 
 		err := cmd.Run()
+		cmdOutput.Finish(err)
 		if err != nil {
 			prefixedLogger.Write([]byte(fmt.Sprintf("tag error: %s\n", err)))
 			return ImageDigest{}, err
@@ -223,11 +262,13 @@ func (d Docker) Push(tmpRef TmpRef, imageDst string) (ImageDigest, error) {
 	{
 		var stdoutBuf, stderrBuf bytes.Buffer
 
-		cmd := exec.Command("docker", "push", imageDst)
-		cmd.Stdout = io.MultiWriter(&stdoutBuf, prefixedLogger)
-		cmd.Stderr = io.MultiWriter(&stderrBuf, prefixedLogger)
+		cmd := d.env.Command("docker", "push", imageDst)
+		cmdOutput := prefixedLogger.NewCommandOutputWriter()
+		cmd.Stdout = io.MultiWriter(&stdoutBuf, cmdOutput)
+		cmd.Stderr = io.MultiWriter(&stderrBuf, cmdOutput)
 
 		err := cmd.Run()
+		cmdOutput.Finish(err)
 		if err != nil {
 			prefixedLogger.Write([]byte(fmt.Sprintf("push error: %s\n", err)))
 			return ImageDigest{}, err
@@ -304,7 +345,7 @@ type InspectData struct {
 func (d Docker) Inspect(ref string) (InspectData, error) {
 	var stdoutBuf, stderrBuf bytes.Buffer
 
-	cmd := exec.Command("docker", "inspect", ref)
+	cmd := d.env.Command("docker", "inspect", ref)
 	cmd.Stdout = &stdoutBuf
 	cmd.Stderr = &stderrBuf
 
@@ -0,0 +1,184 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+)
+
+/*
+
+Example:
+
+$ docker buildx bake --file docker-bake.hcl --set api.tags=registry.corp/api:tag \
+    --set api.output=type=registry --metadata-file /tmp/metadata.json --progress=plain api
+
+$ cat /tmp/metadata.json
+{
+  "api": {
+    "containerimage.config.digest": "sha256:e3bdd21522d99c37f355c70bef342c99cb1e19ee4cf8014001d750a73a5b8d42",
+    "containerimage.digest": "sha256:cd6d662fcf06854810c83e4bff197ef52e65b39c13baa143107ebd32cc6f8636",
+    "image.name": "registry.corp/api:tag"
+  }
+}
+
+*/
+
+const (
+	dockerBakeMetadataDigest = "containerimage.digest"
+)
+
+type Bake struct {
+	docker Docker
+	buildx Buildx
+	logger ctllog.Logger
+}
+
+func NewBake(docker Docker, logger ctllog.Logger) Bake {
+	return Bake{docker, NewBuildx(docker, logger), logger}
+}
+
+// BuildAndOptionallyPush builds bake target and either loads
+// built image into Docker daemon or pushes it to specified registry.
+func (d Bake) BuildAndOptionallyPush(
+	image, directory string, imgDst *ctlconf.ImageDestination,
+	opts ctlconf.SourceDockerBakeOpts) (string, error) {
+
+	err := d.buildx.ensureDirectory(directory)
+	if err != nil {
+		return "", err
+	}
+
+	tagRef, err := d.buildx.tagRef(image, imgDst)
+	if err != nil {
+		return "", err
+	}
+
+	metadataFile, err := os.CreateTemp("", "kbld-bake-metadata")
+	if err != nil {
+		return "", fmt.Errorf("Creating bake metadata file: %s", err)
+	}
+	metadataFile.Close()
+	defer os.Remove(metadataFile.Name())
+
+	prefixedLogger := d.logger.NewPrefixedWriter(image + " | ")
+
+	prefixedLogger.Write([]byte(fmt.Sprintf("starting build (using Docker buildx bake): %s (target %s) -> %s\n", directory, opts.Target, tagRef)))
+	defer prefixedLogger.Write([]byte("finished build (using Docker buildx bake)\n"))
+
+	{
+		cmdArgs := []string{"buildx", "bake", "--progress=plain", "--metadata-file", metadataFile.Name()}
+
+		for _, file := range opts.Files {
+			// Since docker command is executed with cwd of directory,
+			// bake file path doesnt need to be joined with it
+			cmdArgs = append(cmdArgs, "--file", file)
+		}
+		for _, set := range opts.Set {
+			cmdArgs = append(cmdArgs, "--set", set)
+		}
+		if opts.RawOptions != nil {
+			cmdArgs = append(cmdArgs, *opts.RawOptions...)
+		}
+
+		cmdArgs = append(cmdArgs, "--set", opts.Target+".tags="+tagRef)
+
+		// Load built image into Docker daemon, otherwise it's not being used anywhere
+		if imgDst != nil {
+			cmdArgs = append(cmdArgs, "--set", opts.Target+".output=type=registry")
+		} else {
+			cmdArgs = append(cmdArgs, "--set", opts.Target+".output=type=docker")
+		}
+
+		cmdArgs = append(cmdArgs, opts.Target)
+
+		cmd := d.docker.env.Command("docker", cmdArgs...)
+		cmd.Dir = directory
+		cmdOutput := prefixedLogger.NewCommandOutputWriter()
+		cmd.Stdout = cmdOutput
+		cmd.Stderr = cmdOutput
+
+		d.docker.env.RecordInvocation(cmd)
+
+		err := cmd.Run()
+		cmdOutput.Finish(err)
+		if err != nil {
+			prefixedLogger.Write([]byte(fmt.Sprintf("error: %s\n", err)))
+			return "", err
+		}
+	}
+
+	if imgDst != nil {
+		digest, err := d.metadataDigest(metadataFile.Name(), opts.Target)
+		if err != nil {
+			return "", err
+		}
+
+		digestRefStr := imgDst.NewImage + "@" + digest
+
+		digestRef, err := regname.NewDigest(digestRefStr, regname.WeakValidation)
+		if err != nil {
+			return "", fmt.Errorf("Validating destination digest ref '%s': %s", digestRefStr, err)
+		}
+
+		return digestRef.Name(), nil
+	}
+
+	// Work with locally stored image in Docker daemon
+	inspectData, err := d.docker.Inspect(tagRef)
+	if err != nil {
+		prefixedLogger.Write([]byte(fmt.Sprintf("inspect error: %s\n", err)))
+		return "", err
+	}
+
+	tmpRef, err := d.docker.RetagStable(TmpRef{tagRef}, image, inspectData.ID, prefixedLogger)
+	if err != nil {
+		return "", err
+	}
+
+	return tmpRef.AsString(), nil
+}
+
+// metadataDigest returns digest of pushed image recorded by bake for target
+func (d Bake) metadataDigest(path, target string) (string, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Reading bake metadata file: %s", err)
+	}
+
+	return BakeMetadataDigest(bs, target)
+}
+
+// BakeMetadataDigest returns image digest of target in bake metadata (--metadata-file)
+func BakeMetadataDigest(metadataBs []byte, target string) (string, error) {
+	// Metadata also includes non-target keys (e.g. buildx.build.warnings)
+	var metadata map[string]json.RawMessage
+
+	err := json.Unmarshal(metadataBs, &metadata)
+	if err != nil {
+		return "", fmt.Errorf("Unmarshaling bake metadata: %s", err)
+	}
+
+	var targetMetadata map[string]interface{}
+
+	if targetBs, found := metadata[target]; found {
+		err := json.Unmarshal(targetBs, &targetMetadata)
+		if err != nil {
+			return "", fmt.Errorf("Unmarshaling bake metadata of target '%s': %s", target, err)
+		}
+	}
+
+	digest, _ := targetMetadata[dockerBakeMetadataDigest].(string)
+	if len(digest) == 0 {
+		return "", fmt.Errorf("Expected to find image digest of target '%s' in bake metadata but did not", target)
+	}
+
+	return digest, nil
+}
@@ -0,0 +1,31 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package docker_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlbdk "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/docker"
+)
+
+func TestBakeMetadataDigest(t *testing.T) {
+	metadata := []byte(`{
+  "api": {
+    "containerimage.config.digest": "sha256:e3bdd21522d99c37f355c70bef342c99cb1e19ee4cf8014001d750a73a5b8d42",
+    "containerimage.digest": "sha256:cd6d662fcf06854810c83e4bff197ef52e65b39c13baa143107ebd32cc6f8636",
+    "image.name": "registry.corp/api:tag"
+  },
+  "buildx.build.warnings": []
+}`)
+
+	digest, err := ctlbdk.BakeMetadataDigest(metadata, "api")
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:cd6d662fcf06854810c83e4bff197ef52e65b39c13baa143107ebd32cc6f8636", digest)
+
+	_, err = ctlbdk.BakeMetadataDigest(metadata, "worker")
+	require.Error(t, err)
+	assert.Equal(t, "Expected to find image digest of target 'worker' in bake metadata but did not", err.Error())
+}
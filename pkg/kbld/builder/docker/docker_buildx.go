@@ -8,7 +8,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"regexp"
 	"strings"
 
@@ -109,55 +108,20 @@ func (d Buildx) BuildAndOptionallyPush(
 	prefixedLogger.Write([]byte(fmt.Sprintf("starting build (using Docker buildx): %s -> %s\n", directory, tagRef)))
 	defer prefixedLogger.Write([]byte("finished build (using Docker buildx)\n"))
 
-	var stdoutBuf, stderrBuf bytes.Buffer
-	{
-		cmdArgs := []string{"buildx", "build", "--progress=plain"}
-
-		if opts.Target != nil {
-			cmdArgs = append(cmdArgs, "--target", *opts.Target)
-		}
-		if opts.Pull != nil && *opts.Pull {
-			cmdArgs = append(cmdArgs, "--pull")
-		}
-		if opts.NoCache != nil && *opts.NoCache {
-			cmdArgs = append(cmdArgs, "--no-cache")
-		}
-		if opts.File != nil {
-			// Since docker command is executed with cwd of directory,
-			// Dockerfile path doesnt need to be joined with it
-			cmdArgs = append(cmdArgs, "--file", *opts.File)
-		}
-		if opts.RawOptions != nil {
-			cmdArgs = append(cmdArgs, *opts.RawOptions...)
-		}
-
-		cmdArgs = append(cmdArgs, "--tag", tagRef, ".")
-
-		// Load built image into Docker daemon, otherwise it's not being used anywhere
-		if imgDst != nil {
-			cmdArgs = append(cmdArgs, "--push")
-		} else {
-			cmdArgs = append(cmdArgs, "--load")
-		}
-
-		cmd := exec.Command("docker", cmdArgs...)
-		cmd.Dir = directory
-		cmd.Stdout = io.MultiWriter(&stdoutBuf, prefixedLogger)
-		cmd.Stderr = io.MultiWriter(&stderrBuf, prefixedLogger)
+	// Load built image into Docker daemon, otherwise it's not being used anywhere
+	outputArg := "--load"
+	if imgDst != nil {
+		outputArg = "--push"
+	}
 
-		err := cmd.Run()
-		if err != nil {
-			if strings.Contains(stderrBuf.String(), dockerBuildxPushErr) {
-				prefixedLogger.Write([]byte("(hint: Specify image destination as multi-platform builds are not supported on local Docker)\n"))
-			}
-			prefixedLogger.Write([]byte(fmt.Sprintf("error: %s\n", err)))
-			return "", err
-		}
+	stderr, err := d.build(directory, tagRef, outputArg, opts, prefixedLogger)
+	if err != nil {
+		return "", err
 	}
 
 	if imgDst != nil {
 		// Digest is only printed when push option was selected
-		digestMatches := dockerBuildxPushDigest.FindStringSubmatch(stderrBuf.String())
+		digestMatches := dockerBuildxPushDigest.FindStringSubmatch(stderr)
 		if len(digestMatches) != 2 {
 			return "", fmt.Errorf("Expected to find image digest in build output but did not")
 		}
@@ -187,6 +151,89 @@ func (d Buildx) BuildAndOptionallyPush(
 	return tmpRef.AsString(), nil
 }
 
+// BuildArchive builds image and exports it as OCI image layout tarball
+// (caller is responsible for pushing and removing returned archive)
+func (d Buildx) BuildArchive(image, directory string, opts ctlconf.SourceDockerBuildxOpts) (string, error) {
+	err := d.ensureDirectory(directory)
+	if err != nil {
+		return "", err
+	}
+
+	tagRef, err := d.tagRef(image, nil)
+	if err != nil {
+		return "", err
+	}
+
+	archiveFile, err := os.CreateTemp("", "kbld-buildx-oci")
+	if err != nil {
+		return "", fmt.Errorf("Creating OCI archive file: %s", err)
+	}
+	archiveFile.Close()
+
+	prefixedLogger := d.logger.NewPrefixedWriter(image + " | ")
+
+	prefixedLogger.Write([]byte(fmt.Sprintf("starting build (using Docker buildx): %s -> %s\n", directory, archiveFile.Name())))
+	defer prefixedLogger.Write([]byte("finished build (using Docker buildx)\n"))
+
+	_, err = d.build(directory, tagRef, "--output=type=oci,dest="+archiveFile.Name(), opts, prefixedLogger)
+	if err != nil {
+		os.Remove(archiveFile.Name())
+		return "", err
+	}
+
+	return archiveFile.Name(), nil
+}
+
+// build runs buildx with given output option and returns its stderr
+// (buildx prints build progress including pushed digests to stderr)
+func (d Buildx) build(directory, tagRef, outputArg string,
+	opts ctlconf.SourceDockerBuildxOpts, prefixedLogger *ctllog.PrefixWriter) (string, error) {
+
+	cmdArgs := []string{"buildx", "build", "--progress=plain"}
+
+	if opts.Target != nil {
+		cmdArgs = append(cmdArgs, "--target", *opts.Target)
+	}
+	if opts.Pull != nil && *opts.Pull {
+		cmdArgs = append(cmdArgs, "--pull")
+	}
+	if opts.NoCache != nil && *opts.NoCache {
+		cmdArgs = append(cmdArgs, "--no-cache")
+	}
+	if opts.File != nil {
+		// Since docker command is executed with cwd of directory,
+		// Dockerfile path doesnt need to be joined with it
+		cmdArgs = append(cmdArgs, "--file", *opts.File)
+	}
+	if opts.RawOptions != nil {
+		cmdArgs = append(cmdArgs, *opts.RawOptions...)
+	}
+
+	cmdArgs = append(cmdArgs, "--tag", tagRef, ".", outputArg)
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+
+	cmd := d.docker.env.Command("docker", cmdArgs...)
+	cmd.Dir = directory
+	cmdOutput := prefixedLogger.NewCommandOutputWriter()
+	cmd.Stdout = io.MultiWriter(&stdoutBuf, cmdOutput)
+	cmd.Stderr = io.MultiWriter(&stderrBuf, cmdOutput)
+
+	d.docker.env.RecordInvocation(cmd)
+
+	err := cmd.Run()
+	cmdOutput.Finish(err)
+	if err != nil {
+		if strings.Contains(stderrBuf.String(), dockerBuildxPushErr) {
+			prefixedLogger.Write([]byte("(hint: Specify image destination as multi-platform builds are not supported on local Docker)\n"))
+		}
+		prefixedLogger.Write([]byte(fmt.Sprintf("error: %s\n", err)))
+		return "", err
+	}
+
+	return stderrBuf.String(), nil
+}
+
 func (d Buildx) tagRef(image string, imgDst *ctlconf.ImageDestination) (string, error) {
 	tb := ctlb.TagBuilder{}
 
@@ -202,6 +249,10 @@ func (d Buildx) tagRef(image string, imgDst *ctlconf.ImageDestination) (string,
 	))
 
 	if imgDst != nil {
+		if intermediateTag := d.docker.env.IntermediateTag(); len(intermediateTag) > 0 {
+			tag = intermediateTag
+		}
+
 		tagRef := imgDst.NewImage + ":" + tag
 
 		_, err := regname.NewTag(tagRef, regname.WeakValidation)
@@ -0,0 +1,290 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package docker
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DockerfileFrom is a FROM instruction found in a Dockerfile
+type DockerfileFrom struct {
+	Line  int
+	Image string
+	// Stage is a name given via 'AS'
+	Stage string
+}
+
+// DockerfileArg is an ARG instruction declared before first FROM
+// (only such arguments could be used within FROM instructions)
+type DockerfileArg struct {
+	Name    string
+	Default string
+}
+
+// DockerfilePath returns path of Dockerfile used for build
+// (docker is executed within build directory, hence file is relative to it)
+func DockerfilePath(directory string, file *string) string {
+	if file != nil {
+		if filepath.IsAbs(*file) {
+			return *file
+		}
+		return filepath.Join(directory, *file)
+	}
+	return filepath.Join(directory, "Dockerfile")
+}
+
+// ParseDockerfileFroms returns FROM instructions in order.
+// Line continuations are not supported within FROM instructions.
+func ParseDockerfileFroms(path string) ([]DockerfileFrom, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Opening Dockerfile '%s': %s", path, err)
+	}
+	defer file.Close()
+
+	var result []DockerfileFrom
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "FROM") {
+			continue
+		}
+
+		fields = fields[1:]
+		// Skip flags such as --platform=...
+		for len(fields) > 0 && strings.HasPrefix(fields[0], "--") {
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		from := DockerfileFrom{Line: lineNum, Image: fields[0]}
+		if len(fields) >= 3 && strings.EqualFold(fields[1], "AS") {
+			from.Stage = fields[2]
+		}
+
+		result = append(result, from)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Reading Dockerfile '%s': %s", path, err)
+	}
+
+	return result, nil
+}
+
+// ParseDockerfileGlobalArgs returns ARG instructions declared before first FROM
+func ParseDockerfileGlobalArgs(path string) ([]DockerfileArg, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Opening Dockerfile '%s': %s", path, err)
+	}
+	defer file.Close()
+
+	var result []DockerfileArg
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if strings.EqualFold(fields[0], "FROM") {
+			break
+		}
+		if !strings.EqualFold(fields[0], "ARG") {
+			continue
+		}
+
+		for _, field := range fields[1:] {
+			name, val, _ := strings.Cut(field, "=")
+			result = append(result, DockerfileArg{Name: name, Default: strings.Trim(val, `"'`)})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Reading Dockerfile '%s': %s", path, err)
+	}
+
+	return result, nil
+}
+
+// DockerfileBaseImages returns external images referenced by FROM instructions
+// (excludes scratch, previous stages and images with build arguments)
+func DockerfileBaseImages(path string) ([]string, error) {
+	froms, err := ParseDockerfileFroms(path)
+	if err != nil {
+		return nil, err
+	}
+
+	stages := map[string]struct{}{}
+	seen := map[string]struct{}{}
+	var result []string
+
+	for _, from := range froms {
+		_, isStage := stages[strings.ToLower(from.Image)]
+		_, isSeen := seen[from.Image]
+
+		if from.Stage != "" {
+			stages[strings.ToLower(from.Stage)] = struct{}{}
+		}
+
+		if isStage || isSeen || from.Image == "scratch" || strings.Contains(from.Image, "$") {
+			continue
+		}
+
+		seen[from.Image] = struct{}{}
+		result = append(result, from.Image)
+	}
+
+	return result, nil
+}
+
+// DockerfileArgBaseImages returns external images referenced by FROM instructions
+// via single global build argument (e.g. FROM ${BASE_IMAGE}) keyed by argument name.
+// Given build arguments take precedence over defaults declared in Dockerfile.
+func DockerfileArgBaseImages(path string, buildArgs map[string]string) (map[string]string, error) {
+	froms, err := ParseDockerfileFroms(path)
+	if err != nil {
+		return nil, err
+	}
+
+	args, err := ParseDockerfileGlobalArgs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	for _, arg := range args {
+		values[arg.Name] = arg.Default
+		if val, found := buildArgs[arg.Name]; found {
+			values[arg.Name] = val
+		}
+	}
+
+	stages := map[string]struct{}{}
+	result := map[string]string{}
+
+	for _, from := range froms {
+		name, isArg := dockerfileArgRef(from.Image)
+
+		if from.Stage != "" {
+			stages[strings.ToLower(from.Stage)] = struct{}{}
+		}
+		if !isArg {
+			continue
+		}
+
+		image := values[name]
+		_, isStage := stages[strings.ToLower(image)]
+
+		if len(image) == 0 || isStage || image == "scratch" || strings.Contains(image, "$") {
+			continue
+		}
+
+		result[name] = image
+	}
+
+	return result, nil
+}
+
+// DockerBuildArgs returns build arguments specified via '--build-arg'
+// within raw options (arguments without values are taken from environment)
+func DockerBuildArgs(rawOptions []string) map[string]string {
+	result := map[string]string{}
+
+	for i := 0; i < len(rawOptions); i++ {
+		var arg string
+
+		switch {
+		case rawOptions[i] == "--build-arg" && i+1 < len(rawOptions):
+			i++
+			arg = rawOptions[i]
+		case strings.HasPrefix(rawOptions[i], "--build-arg="):
+			arg = strings.TrimPrefix(rawOptions[i], "--build-arg=")
+		default:
+			continue
+		}
+
+		name, val, hasVal := strings.Cut(arg, "=")
+		if !hasVal {
+			val, hasVal = os.LookupEnv(name)
+		}
+		if hasVal {
+			result[name] = val
+		}
+	}
+
+	return result
+}
+
+// dockerfileArgRef returns argument name when image is
+// a reference to a single argument (e.g. $BASE or ${BASE})
+func dockerfileArgRef(image string) (string, bool) {
+	var name string
+
+	switch {
+	case strings.HasPrefix(image, "${") && strings.HasSuffix(image, "}"):
+		name = image[2 : len(image)-1]
+	case strings.HasPrefix(image, "$"):
+		name = image[1:]
+	default:
+		return "", false
+	}
+
+	if len(name) == 0 || strings.ContainsAny(name, "${}:/") {
+		return "", false
+	}
+	return name, true
+}
+
+// RewriteDockerfileFroms returns Dockerfile contents with FROM images
+// replaced according to given mapping (e.g. tag references to digest references)
+func RewriteDockerfileFroms(path string, mapping map[string]string) ([]byte, error) {
+	froms, err := ParseDockerfileFroms(path)
+	if err != nil {
+		return nil, err
+	}
+
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Reading Dockerfile '%s': %s", path, err)
+	}
+
+	lines := strings.Split(string(bs), "\n")
+
+	for _, from := range froms {
+		newImage, found := mapping[from.Image]
+		if !found {
+			continue
+		}
+
+		line := lines[from.Line-1]
+		// Replace first occurrence that is preceded by whitespace
+		// (image always follows FROM keyword and optional flags)
+		idx := strings.Index(line, " "+from.Image)
+		if idx < 0 {
+			idx = strings.Index(line, "\t"+from.Image)
+		}
+		if idx < 0 {
+			continue
+		}
+		idx++
+
+		lines[from.Line-1] = line[:idx] + newImage + line[idx+len(from.Image):]
+	}
+
+	return []byte(strings.Join(lines, "\n")), nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package docker_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlbdk "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/docker"
+)
+
+func TestDockerfileBaseImages(t *testing.T) {
+	dir := t.TempDir()
+
+	dockerfile := `
+ARG GO_VERSION=1.21
+FROM golang:${GO_VERSION} AS build
+FROM --platform=linux/amd64 golang:1.21 as builder
+RUN go build ./...
+
+FROM builder AS test
+from gcr.io/distroless/static@sha256:0000000000000000000000000000000000000000000000000000000000000001
+FROM scratch
+FROM golang:1.21
+COPY --from=build /out /out
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte(dockerfile), 0600))
+
+	images, err := ctlbdk.DockerfileBaseImages(ctlbdk.DockerfilePath(dir, nil))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"golang:1.21",
+		"gcr.io/distroless/static@sha256:0000000000000000000000000000000000000000000000000000000000000001",
+	}, images)
+}
+
+func TestDockerfilePath(t *testing.T) {
+	file := "build/Dockerfile.prod"
+	assert.Equal(t, filepath.Join("src", "build/Dockerfile.prod"), ctlbdk.DockerfilePath("src", &file))
+
+	absFile := "/tmp/Dockerfile"
+	assert.Equal(t, "/tmp/Dockerfile", ctlbdk.DockerfilePath("src", &absFile))
+
+	assert.Equal(t, filepath.Join("src", "Dockerfile"), ctlbdk.DockerfilePath("src", nil))
+}
+
+func TestRewriteDockerfileFroms(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Dockerfile")
+
+	dockerfile := "FROM --platform=linux/amd64 golang:1.21 AS build\nRUN echo golang:1.21\nFROM\tgolang:1.21\nFROM build\n"
+	require.NoError(t, os.WriteFile(path, []byte(dockerfile), 0600))
+
+	bs, err := ctlbdk.RewriteDockerfileFroms(path, map[string]string{"golang:1.21": "golang@sha256:abc"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "FROM --platform=linux/amd64 golang@sha256:abc AS build\n"+
+		"RUN echo golang:1.21\nFROM\tgolang@sha256:abc\nFROM build\n", string(bs))
+}
+
+func TestDockerfileArgBaseImages(t *testing.T) {
+	dir := t.TempDir()
+
+	dockerfile := `
+ARG BASE_IMAGE=golang:1.21 RUNTIME_IMAGE="gcr.io/distroless/static"
+ARG SHELL_IMAGE
+ARG VERSION=1.21
+FROM ${BASE_IMAGE} AS build
+FROM golang:${VERSION}
+FROM $RUNTIME_IMAGE
+FROM ${SHELL_IMAGE}
+ARG LATE_IMAGE=alpine
+FROM ${LATE_IMAGE}
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte(dockerfile), 0600))
+
+	images, err := ctlbdk.DockerfileArgBaseImages(ctlbdk.DockerfilePath(dir, nil),
+		map[string]string{"RUNTIME_IMAGE": "gcr.io/distroless/base", "OTHER": "alpine"})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"BASE_IMAGE":    "golang:1.21",
+		"RUNTIME_IMAGE": "gcr.io/distroless/base",
+	}, images)
+}
+
+func TestDockerBuildArgs(t *testing.T) {
+	t.Setenv("KBLD_TEST_BUILD_ARG", "from-env")
+
+	buildArgs := ctlbdk.DockerBuildArgs([]string{
+		"--build-arg", "BASE_IMAGE=golang:1.21",
+		"--build-arg=EMPTY=",
+		"--pull",
+		"--build-arg", "KBLD_TEST_BUILD_ARG",
+		"--build-arg", "KBLD_TEST_UNSET_BUILD_ARG",
+	})
+
+	assert.Equal(t, map[string]string{
+		"BASE_IMAGE":          "golang:1.21",
+		"EMPTY":               "",
+		"KBLD_TEST_BUILD_ARG": "from-env",
+	}, buildArgs)
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package builder
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+)
+
+var (
+	envAlwaysAllowed = []string{"PATH", "HOME"}
+)
+
+const (
+	// envCancelWaitDelay is how long cancelled commands have to exit before being killed
+	envCancelWaitDelay = 10 * time.Second
+)
+
+// Env is an environment of builder commands (variables and cancellation).
+// Zero value inherits host environment as is and is never cancelled.
+type Env struct {
+	vars []string
+	ctx  context.Context
+
+	// setVars are names of variables set by configuration
+	setVars         []string
+	invocations     *Invocations
+	intermediateTag *IntermediateTag
+}
+
+// NewEnv selects variables from host environment (e.g. os.Environ())
+func NewEnv(opts *ctlconf.SourceEnvOpts, hostVars []string) Env {
+	if opts == nil {
+		return Env{}
+	}
+
+	var allowed []string
+	if opts.Allow != nil {
+		allowed = append(append(allowed, envAlwaysAllowed...), *opts.Allow...)
+	}
+
+	result := []string{}
+
+	for _, kv := range hostVars {
+		name := strings.SplitN(kv, "=", 2)[0]
+		if _, found := opts.Set[name]; found {
+			continue
+		}
+		if allowed != nil && !envMatches(allowed, name) {
+			continue
+		}
+		if opts.Deny != nil && envMatches(*opts.Deny, name) {
+			continue
+		}
+		result = append(result, kv)
+	}
+
+	var names []string
+	for name := range opts.Set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		result = append(result, name+"="+opts.Set[name])
+	}
+
+	return Env{vars: result, setVars: names}
+}
+
+// WithContext returns environment of commands cancelled once ctx is done
+func (e Env) WithContext(ctx context.Context) Env {
+	e.ctx = ctx
+	return e
+}
+
+// Command returns command running in this environment. When cancelled,
+// whole process group of command is terminated since builders
+// (e.g. docker, pack) tend to spawn their own subprocesses.
+func (e Env) Command(name string, args ...string) *exec.Cmd {
+	ctx := e.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = e.Vars()
+	cmd.WaitDelay = envCancelWaitDelay
+	configureProcessGroup(cmd)
+
+	return cmd
+}
+
+// Vars returns variables for exec.Cmd.Env
+// (nil value makes command inherit host environment)
+func (e Env) Vars() []string {
+	if e.vars == nil {
+		return nil
+	}
+	return append([]string{}, e.vars...)
+}
+
+// With returns environment variables extended with given ones
+func (e Env) With(vars ...string) []string {
+	if e.vars == nil {
+		return append(os.Environ(), vars...)
+	}
+	return append(e.Vars(), vars...)
+}
+
+func envMatches(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package builder_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ctlb "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+)
+
+func TestNewEnv(t *testing.T) {
+	hostVars := []string{
+		"PATH=/usr/bin",
+		"HOME=/home/ci",
+		"GOPROXY=https://proxy.corp",
+		"NPM_TOKEN=npm-secret",
+		"NPM_REGISTRY=https://npm.corp",
+		"CI_SECRET=ci-secret",
+		"LANG=C",
+	}
+
+	t.Run("inherits host environment when not configured", func(t *testing.T) {
+		env := ctlb.NewEnv(nil, hostVars)
+		assert.Nil(t, env.Vars())
+	})
+
+	t.Run("passes only allowed variables", func(t *testing.T) {
+		env := ctlb.NewEnv(&ctlconf.SourceEnvOpts{
+			Allow: &[]string{"GOPROXY", "NPM_*"},
+			Deny:  &[]string{"*_TOKEN"},
+			Set:   map[string]string{"LANG": "en_US.UTF-8", "BUILD_ENV": "ci"},
+		}, hostVars)
+
+		assert.Equal(t, []string{
+			"PATH=/usr/bin",
+			"HOME=/home/ci",
+			"GOPROXY=https://proxy.corp",
+			"NPM_REGISTRY=https://npm.corp",
+			"BUILD_ENV=ci",
+			"LANG=en_US.UTF-8",
+		}, env.Vars())
+	})
+
+	t.Run("passes everything except denied variables", func(t *testing.T) {
+		env := ctlb.NewEnv(&ctlconf.SourceEnvOpts{Deny: &[]string{"CI_*", "NPM_TOKEN"}}, hostVars)
+
+		assert.Equal(t, []string{
+			"PATH=/usr/bin",
+			"HOME=/home/ci",
+			"GOPROXY=https://proxy.corp",
+			"NPM_REGISTRY=https://npm.corp",
+			"LANG=C",
+		}, env.Vars())
+	})
+
+	t.Run("passes nothing but PATH and HOME with empty allow list", func(t *testing.T) {
+		env := ctlb.NewEnv(&ctlconf.SourceEnvOpts{Allow: &[]string{}}, hostVars)
+
+		assert.Equal(t, []string{"PATH=/usr/bin", "HOME=/home/ci"}, env.Vars())
+		assert.Equal(t, []string{"PATH=/usr/bin", "HOME=/home/ci", "DOCKER_BUILDKIT=1"}, env.With("DOCKER_BUILDKIT=1"))
+	})
+}
@@ -0,0 +1,19 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package builder
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+func configureProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		// Negative pid signals process group led by command
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	}
+}
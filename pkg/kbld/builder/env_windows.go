@@ -0,0 +1,14 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build windows
+
+package builder
+
+import (
+	"os/exec"
+)
+
+func configureProcessGroup(cmd *exec.Cmd) {
+	// Process groups are not supported hence only command itself is killed
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package builder
+
+import (
+	"sync"
+)
+
+// IntermediateTag holds tag builders use when pushing built image to destination.
+// It's set for each build since it may depend on sources (e.g. their git SHA).
+type IntermediateTag struct {
+	lock sync.Mutex
+	tag  string
+}
+
+func NewIntermediateTag() *IntermediateTag { return &IntermediateTag{} }
+
+func (t *IntermediateTag) Set(tag string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.tag = tag
+}
+
+// Tag returns configured tag or empty string when random tag should be used
+func (t *IntermediateTag) Tag() string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.tag
+}
+
+// WithIntermediateTag returns environment of builders that push
+// built images with tag held by given value
+func (e Env) WithIntermediateTag(tag *IntermediateTag) Env {
+	e.intermediateTag = tag
+	return e
+}
+
+// IntermediateTag returns tag to push built image with
+// or empty string when builder should use random tag
+func (e Env) IntermediateTag() string {
+	if e.intermediateTag == nil {
+		return ""
+	}
+	return e.intermediateTag.Tag()
+}
@@ -0,0 +1,165 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package builder
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+)
+
+// invocationTool describes how to determine version of builder tool
+type invocationTool struct {
+	Name        string
+	Args        []string
+	VersionArgs []string
+}
+
+var (
+	// More specific tools (e.g. CLI plugins) are listed first
+	invocationTools = []invocationTool{
+		{"docker buildx", []string{"docker", "buildx"}, []string{"docker", "buildx", "version"}},
+		{"docker", []string{"docker"}, []string{"docker", "version", "--format", "{{.Client.Version}}"}},
+		{"kubectl buildkit", []string{"kubectl", "buildkit"}, []string{"kubectl", "buildkit", "--version"}},
+		{"pack", []string{"pack"}, []string{"pack", "--version"}},
+		{"ko", []string{"ko"}, []string{"ko", "version"}},
+		{"bazel", []string{"bazel"}, []string{"bazel", "--version"}},
+		{"gcloud", []string{"gcloud"}, []string{"gcloud", "--version"}},
+		{"aws", []string{"aws"}, []string{"aws", "--version"}},
+		{"depot", []string{"depot"}, []string{"depot", "--version"}},
+	}
+
+	// Variables that affect builds are recorded in addition to
+	// variables set by configuration (other variables may contain secrets)
+	invocationRecordedVars = []string{
+		"DOCKER_BUILDKIT", "DOCKER_DEFAULT_PLATFORM", "BUILDX_BUILDER", "BUILDKIT_PROGRESS",
+		"SOURCE_DATE_EPOCH", "KO_DOCKER_REPO", "KO_DEFAULTBASEIMAGE", "KO_DEFAULTPLATFORMS",
+		"GOFLAGS", "CGO_ENABLED",
+	}
+
+	// Versions are determined once per tool binary since they do not change during kbld run
+	invocationVersions     = map[string]string{}
+	invocationVersionsLock sync.Mutex
+)
+
+// Invocations records builder commands that built image
+type Invocations struct {
+	lock        sync.Mutex
+	invocations []ctlconf.OriginBuiltInvocation
+}
+
+func NewInvocations() *Invocations { return &Invocations{} }
+
+// Reset forgets previously recorded commands (e.g. of failed build attempt)
+func (i *Invocations) Reset() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.invocations = nil
+}
+
+func (i *Invocations) List() []ctlconf.OriginBuiltInvocation {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	return append([]ctlconf.OriginBuiltInvocation{}, i.invocations...)
+}
+
+func (i *Invocations) add(invocation ctlconf.OriginBuiltInvocation) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.invocations = append(i.invocations, invocation)
+}
+
+// WithInvocations returns environment recording builder commands into invocations
+func (e Env) WithInvocations(invocations *Invocations) Env {
+	e.invocations = invocations
+	return e
+}
+
+// RecordInvocation records command that builds image. It should be
+// called right before running command once its variables are set.
+func (e Env) RecordInvocation(cmd *exec.Cmd) {
+	if e.invocations == nil {
+		return
+	}
+
+	vars := cmd.Env
+	if vars == nil {
+		vars = os.Environ()
+	}
+
+	recordedVars := append(append([]string{}, invocationRecordedVars...), e.setVars...)
+
+	var invocationVars []string
+	for _, kv := range vars {
+		if envMatches(recordedVars, strings.SplitN(kv, "=", 2)[0]) {
+			invocationVars = append(invocationVars, kv)
+		}
+	}
+	sort.Strings(invocationVars)
+
+	invocation := ctlconf.OriginBuiltInvocation{
+		Tool: cmd.Args[0],
+		Args: append([]string{}, cmd.Args[1:]...),
+		Env:  invocationVars,
+	}
+
+	if tool, found := e.invocationTool(cmd.Args); found {
+		invocation.Tool = tool.Name
+		invocation.Version = e.toolVersion(tool)
+	}
+
+	e.invocations.add(invocation)
+}
+
+func (e Env) invocationTool(args []string) (invocationTool, bool) {
+	for _, tool := range invocationTools {
+		if len(args) < len(tool.Args) {
+			continue
+		}
+		matched := true
+		for i, arg := range tool.Args {
+			matched = matched && args[i] == arg
+		}
+		if matched {
+			return tool, true
+		}
+	}
+	return invocationTool{}, false
+}
+
+// toolVersion returns first line of version output
+// (version is omitted if it cannot be determined)
+func (e Env) toolVersion(tool invocationTool) string {
+	invocationVersionsLock.Lock()
+	defer invocationVersionsLock.Unlock()
+
+	var stdoutBuf bytes.Buffer
+
+	cmd := e.Command(tool.VersionArgs[0], tool.VersionArgs[1:]...)
+	cmd.Stdout = &stdoutBuf
+
+	// Command path is resolved via PATH hence same tool name may refer to different binaries
+	versionKey := tool.Name + " " + cmd.Path
+
+	if version, found := invocationVersions[versionKey]; found {
+		return version
+	}
+
+	// Failures (e.g. build timeout) are not remembered so that later builds could determine version
+	if err := cmd.Run(); err != nil {
+		return ""
+	}
+
+	version := strings.TrimSpace(strings.SplitN(strings.TrimSpace(stdoutBuf.String()), "\n", 2)[0])
+	invocationVersions[versionKey] = version
+	return version
+}
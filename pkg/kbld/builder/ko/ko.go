@@ -7,20 +7,21 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"os/exec"
 	"strings"
 
+	ctlb "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder"
 	ctlbdk "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/docker"
 	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
 	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
 )
 
 type Ko struct {
+	env    ctlb.Env
 	logger ctllog.Logger
 }
 
-func NewKo(logger ctllog.Logger) Ko {
-	return Ko{logger: logger}
+func NewKo(env ctlb.Env, logger ctllog.Logger) Ko {
+	return Ko{env: env, logger: logger}
 }
 
 func (k *Ko) Build(image, directory string, opts config.SourceKoBuildOpts) (ctlbdk.TmpRef, error) {
@@ -37,12 +38,16 @@ func (k *Ko) Build(image, directory string, opts config.SourceKoBuildOpts) (ctlb
 		cmdArgs = append(cmdArgs, *opts.RawOptions...)
 	}
 
-	cmd := exec.Command("ko", cmdArgs...)
+	cmd := k.env.Command("ko", cmdArgs...)
 	cmd.Dir = directory
-	cmd.Stdout = io.MultiWriter(&stdoutBuf, prefixedLogger)
-	cmd.Stderr = io.MultiWriter(&stderrBuf, prefixedLogger)
+	cmdOutput := prefixedLogger.NewCommandOutputWriter()
+	cmd.Stdout = io.MultiWriter(&stdoutBuf, cmdOutput)
+	cmd.Stderr = io.MultiWriter(&stderrBuf, cmdOutput)
+
+	k.env.RecordInvocation(cmd)
 
 	err := cmd.Run()
+	cmdOutput.Finish(err)
 	if err != nil {
 		prefixedLogger.Write([]byte(fmt.Sprintf("error: %s\n", err)))
 		return ctlbdk.TmpRef{}, err
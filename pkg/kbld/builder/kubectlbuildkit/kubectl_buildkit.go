@@ -7,7 +7,6 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"os/exec"
 	"regexp"
 
 	regname "github.com/google/go-containerregistry/pkg/name"
@@ -31,11 +30,12 @@ var (
 )
 
 type KubectlBuildkit struct {
+	env    ctlb.Env
 	logger ctllog.Logger
 }
 
-func NewKubectlBuildkit(logger ctllog.Logger) KubectlBuildkit {
-	return KubectlBuildkit{logger}
+func NewKubectlBuildkit(env ctlb.Env, logger ctllog.Logger) KubectlBuildkit {
+	return KubectlBuildkit{env, logger}
 }
 
 func (d KubectlBuildkit) BuildAndPush(image, directory string,
@@ -84,12 +84,16 @@ func (d KubectlBuildkit) BuildAndPush(image, directory string,
 
 	cmdArgs = append(cmdArgs, "--tag", tagRef, ".")
 
-	cmd := exec.Command("kubectl", cmdArgs...)
+	cmd := d.env.Command("kubectl", cmdArgs...)
 	cmd.Dir = directory
-	cmd.Stdout = io.MultiWriter(&stdoutBuf, prefixedLogger)
-	cmd.Stderr = io.MultiWriter(&stderrBuf, prefixedLogger)
+	cmdOutput := prefixedLogger.NewCommandOutputWriter()
+	cmd.Stdout = io.MultiWriter(&stdoutBuf, cmdOutput)
+	cmd.Stderr = io.MultiWriter(&stderrBuf, cmdOutput)
+
+	d.env.RecordInvocation(cmd)
 
 	err = cmd.Run()
+	cmdOutput.Finish(err)
 	if err != nil {
 		prefixedLogger.Write([]byte(fmt.Sprintf("error: %s\n", err)))
 		return "", err
@@ -133,6 +137,10 @@ func (d KubectlBuildkit) tagRef(image string, imgDst *ctlconf.ImageDestination)
 	))
 
 	if imgDst != nil {
+		if intermediateTag := d.env.IntermediateTag(); len(intermediateTag) > 0 {
+			tag = intermediateTag
+		}
+
 		tagRef := imgDst.NewImage + ":" + tag
 
 		_, err := regname.NewTag(tagRef, regname.WeakValidation)
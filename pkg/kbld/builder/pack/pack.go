@@ -7,7 +7,6 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"os/exec"
 	"regexp"
 
 	ctlbdk "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/docker"
@@ -70,12 +69,16 @@ func (d Pack) Build(image, directory string, opts PackBuildOpts) (ctlbdk.TmpRef,
 			cmdArgs = append(cmdArgs, *opts.RawOptions...)
 		}
 
-		cmd := exec.Command("pack", cmdArgs...)
+		cmd := d.docker.Env().Command("pack", cmdArgs...)
 		cmd.Dir = directory
-		cmd.Stdout = io.MultiWriter(&stdoutBuf, prefixedLogger)
-		cmd.Stderr = io.MultiWriter(&stderrBuf, prefixedLogger)
+		cmdOutput := prefixedLogger.NewCommandOutputWriter()
+		cmd.Stdout = io.MultiWriter(&stdoutBuf, cmdOutput)
+		cmd.Stderr = io.MultiWriter(&stderrBuf, cmdOutput)
+
+		d.docker.Env().RecordInvocation(cmd)
 
 		err := cmd.Run()
+		cmdOutput.Finish(err)
 		if err != nil {
 			prefixedLogger.Write([]byte(fmt.Sprintf("error: %s\n", err)))
 			return ctlbdk.TmpRef{}, err
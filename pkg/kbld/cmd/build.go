@@ -0,0 +1,141 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	regname "github.com/google/go-containerregistry/pkg/name"
+	"github.com/spf13/cobra"
+	ctlb "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder"
+	ctlbdk "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/docker"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+type BuildOptions struct {
+	ui ui.UI
+
+	FileFlags     FileFlags
+	RegistryFlags RegistryFlags
+	LoggerFlags   LoggerFlags
+
+	Contract     string
+	Image        string
+	Push         bool
+	RefOutput    string
+	BuildTimeout time.Duration
+}
+
+func NewBuildOptions(ui ui.UI) *BuildOptions {
+	return &BuildOptions{ui: ui}
+}
+
+func NewBuildCmd(o *BuildOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "build",
+		Short: "Build single image as a custom builder for Skaffold or Tilt",
+		Example: `
+  # Skaffold (build.artifacts[].custom.buildCommand)
+  kbld build --contract skaffold -f kbld.yml --image app
+
+  # Tilt (custom_build with outputs_image_ref_to='ref.txt')
+  kbld build --contract tilt -f kbld.yml --image app --ref-output ref.txt`,
+		RunE: func(_ *cobra.Command, _ []string) error { return o.Run() },
+	}
+	o.FileFlags.Set(cmd)
+	o.RegistryFlags.Set(cmd)
+	o.LoggerFlags.Set(cmd)
+	cmd.Flags().StringVar(&o.Contract, "contract", "", "Set build contract used to read expected image from env variables (skaffold, tilt)")
+	cmd.Flags().StringVar(&o.Image, "image", "", "Set image name configured in sources (defaults to repository of expected image)")
+	cmd.Flags().BoolVar(&o.Push, "push", false, "Push image to registry (Skaffold requests pushes via PUSH_IMAGE)")
+	cmd.Flags().StringVar(&o.RefOutput, "ref-output", "", "File path to write built image reference to (e.g. Tilt's outputs_image_ref_to)")
+	cmd.Flags().DurationVar(&o.BuildTimeout, "build-timeout", 0, "Terminate build taking longer than duration (e.g. 15m) unless source specifies its own timeout (0 disables)")
+	return cmd
+}
+
+func (o *BuildOptions) Run() error {
+	contract, err := NewBuildContract(o.Contract, os.Getenv, o.Push)
+	if err != nil {
+		return util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+
+	_, conf, err := o.FileFlags.ResourcesAndConfig()
+	if err != nil {
+		return util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+
+	image := o.Image
+	if len(image) == 0 {
+		image = contract.Ref.Context().Name()
+	}
+
+	if contract.Push {
+		// Expected image takes precedence over configured destinations
+		dstConfig := ctlconf.NewConfig()
+		dstConfig.Destinations = []ctlconf.ImageDestination{{
+			ImageRef: ctlconf.ImageRef{Image: image},
+			NewImage: contract.Ref.Context().Name(),
+			Tags:     []string{contract.Ref.TagStr()},
+		}}
+		conf = conf.WithPrecedingConfig(dstConfig)
+	}
+
+	logger, closeLogger, err := o.LoggerFlags.NewLogger()
+	if err != nil {
+		return err
+	}
+	defer closeLogger()
+
+	registryOpts, err := o.RegistryFlags.AsRegistryOpts()
+	if err != nil {
+		return err
+	}
+
+	registry, err := ctlreg.NewRegistry(registryOpts)
+	if err != nil {
+		return err
+	}
+
+	imgFactory := ctlimg.NewFactory(ctlimg.FactoryOpts{Conf: conf, AllowedToBuild: true, BuildTimeout: o.BuildTimeout}, registry, logger)
+
+	plan, err := imgFactory.Plan(image)
+	if err != nil {
+		return util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+	if plan.Action != ctlimg.PlanActionBuild {
+		return util.NewCategorizedError(util.ErrorCategoryConfig,
+			fmt.Errorf("Expected source to be configured for image '%s'", image))
+	}
+
+	url, _, err := imgFactory.New(image).URL()
+	if err != nil {
+		return err
+	}
+
+	// Locally built images are made available under expected name
+	if _, err := regname.NewDigest(url, regname.WeakValidation); err != nil {
+		err := ctlbdk.New(ctlb.Env{}, logger).Tag(ctlbdk.NewTmpRef(url), contract.Ref.Name())
+		if err != nil {
+			return fmt.Errorf("Tagging built image: %s", err)
+		}
+		url = contract.Ref.Name()
+	}
+
+	if len(o.RefOutput) > 0 {
+		err := os.WriteFile(o.RefOutput, []byte(url+"\n"), 0600)
+		if err != nil {
+			return fmt.Errorf("Writing image reference: %s", err)
+		}
+	}
+
+	o.ui.PrintBlock([]byte(url + "\n"))
+
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+)
+
+const (
+	BuildContractSkaffold = "skaffold"
+	BuildContractTilt     = "tilt"
+)
+
+// BuildContract describes image that dev tool expects to be built
+// (https://skaffold.dev/docs/builders/builder-types/custom/,
+// https://docs.tilt.dev/custom_build.html)
+type BuildContract struct {
+	// Ref is tagged reference that should refer to built image
+	Ref regname.Tag
+	// Push indicates that image should be pushed to registry
+	// (otherwise it's only made available in local Docker daemon)
+	Push bool
+}
+
+// NewBuildContract reads build contract from env variables set by dev tool
+func NewBuildContract(name string, getenv func(string) string, push bool) (BuildContract, error) {
+	var refEnvVar string
+
+	switch name {
+	case BuildContractSkaffold:
+		refEnvVar = "IMAGE"
+
+		if pushStr := getenv("PUSH_IMAGE"); len(pushStr) > 0 {
+			skaffoldPush, err := strconv.ParseBool(pushStr)
+			if err != nil {
+				return BuildContract{}, fmt.Errorf("Parsing PUSH_IMAGE env variable: %s", err)
+			}
+			push = push || skaffoldPush
+		}
+
+	case BuildContractTilt:
+		refEnvVar = "EXPECTED_REF"
+
+	default:
+		return BuildContract{}, fmt.Errorf("Unknown build contract '%s' (supported: %s, %s)",
+			name, BuildContractSkaffold, BuildContractTilt)
+	}
+
+	refStr := getenv(refEnvVar)
+	if len(refStr) == 0 {
+		return BuildContract{}, fmt.Errorf("Expected %s env variable to be set (by %s)", refEnvVar, name)
+	}
+
+	ref, err := regname.NewTag(refStr, regname.WeakValidation)
+	if err != nil {
+		return BuildContract{}, fmt.Errorf("Parsing %s env variable: %s", refEnvVar, err)
+	}
+
+	return BuildContract{Ref: ref, Push: push}, nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestNewBuildContract(t *testing.T) {
+	envFunc := func(env map[string]string) func(string) string {
+		return func(key string) string { return env[key] }
+	}
+
+	t.Run("skaffold", func(t *testing.T) {
+		contract, err := ctlcmd.NewBuildContract("skaffold", envFunc(map[string]string{
+			"IMAGE":      "registry.example.com/app:v1-dirty",
+			"PUSH_IMAGE": "true",
+		}), false)
+		require.NoError(t, err)
+		assert.Equal(t, "registry.example.com/app:v1-dirty", contract.Ref.Name())
+		assert.True(t, contract.Push)
+
+		contract, err = ctlcmd.NewBuildContract("skaffold", envFunc(map[string]string{
+			"IMAGE":      "app:v1",
+			"PUSH_IMAGE": "false",
+		}), false)
+		require.NoError(t, err)
+		assert.Equal(t, "index.docker.io/library/app:v1", contract.Ref.Name())
+		assert.False(t, contract.Push)
+
+		_, err = ctlcmd.NewBuildContract("skaffold", envFunc(nil), false)
+		require.EqualError(t, err, "Expected IMAGE env variable to be set (by skaffold)")
+	})
+
+	t.Run("tilt", func(t *testing.T) {
+		contract, err := ctlcmd.NewBuildContract("tilt", envFunc(map[string]string{
+			"EXPECTED_REF": "localhost:5000/app:tilt-2f3a",
+		}), true)
+		require.NoError(t, err)
+		assert.Equal(t, "localhost:5000/app:tilt-2f3a", contract.Ref.Name())
+		assert.True(t, contract.Push)
+
+		_, err = ctlcmd.NewBuildContract("tilt", envFunc(map[string]string{"IMAGE": "app:v1"}), false)
+		require.EqualError(t, err, "Expected EXPECTED_REF env variable to be set (by tilt)")
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := ctlcmd.NewBuildContract("garden", envFunc(nil), false)
+		require.EqualError(t, err, "Unknown build contract 'garden' (supported: skaffold, tilt)")
+	})
+}
@@ -0,0 +1,287 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+const (
+	CIAnnotationsGitHub = "github"
+)
+
+// GitHubAnnotations emits GitHub Actions workflow commands
+// for failed images and logged warnings, and writes job summary
+// of processed images (https://docs.github.com/en/actions/using-workflows/workflow-commands-for-github-actions)
+type GitHubAnnotations struct {
+	out         io.Writer
+	outLock     sync.Mutex
+	summaryPath string
+	report      *RunReport
+}
+
+var _ ImageProgress = &GitHubAnnotations{}
+
+// NewGitHubAnnotations writes workflow commands to given writer
+// and appends job summary to summaryPath (skipped when empty)
+func NewGitHubAnnotations(out io.Writer, summaryPath string) *GitHubAnnotations {
+	return &GitHubAnnotations{out: out, summaryPath: summaryPath, report: NewRunReport()}
+}
+
+func (a *GitHubAnnotations) Started(url, action string)     { a.report.Started(url, action) }
+func (a *GitHubAnnotations) Finished(url string, err error) { a.report.Finished(url, err) }
+
+// Complete records final image references
+func (a *GitHubAnnotations) Complete(resolvedImages *ProcessedImages) {
+	a.report.Complete(resolvedImages)
+}
+
+// Writer returns writer that passes through log lines
+// and additionally emits warning command for each logged warning
+func (a *GitHubAnnotations) Writer(w io.Writer) io.Writer {
+	return &githubWarningsWriter{w: w, annotations: a}
+}
+
+// Finish emits error commands pointing at manifests that
+// reference failed images and writes job summary
+func (a *GitHubAnnotations) Finish(runErr error, files []string) error {
+	var imgFailed bool
+
+	for _, img := range a.report.Images() {
+		if len(img.Error) == 0 {
+			continue
+		}
+		imgFailed = true
+
+		msg := fmt.Sprintf("Resolving image '%s': %s", img.URL, img.Error)
+		props := map[string]string{"title": "kbld"}
+
+		if path, line, found := findImageLocation(files, img.URL); found {
+			props["file"] = path
+			props["line"] = fmt.Sprintf("%d", line)
+		}
+		a.writeCommand("error", props, msg)
+	}
+
+	if runErr != nil && !imgFailed {
+		a.writeCommand("error", map[string]string{"title": "kbld"}, runErr.Error())
+	}
+
+	if len(a.summaryPath) == 0 {
+		return nil
+	}
+
+	file, err := os.OpenFile(a.summaryPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("Opening job summary '%s': %s", a.summaryPath, err)
+	}
+	defer file.Close()
+
+	_, err = file.Write(a.Summary(runErr))
+	if err != nil {
+		return fmt.Errorf("Writing job summary '%s': %s", a.summaryPath, err)
+	}
+
+	return nil
+}
+
+// Summary returns markdown table of processed images
+func (a *GitHubAnnotations) Summary(runErr error) []byte {
+	images := a.report.Images()
+
+	var failed int
+	for _, img := range images {
+		if len(img.Error) > 0 {
+			failed++
+		}
+	}
+
+	var buf bytes.Buffer
+
+	buf.WriteString("### kbld\n\n")
+
+	switch {
+	case runErr != nil && failed > 0:
+		fmt.Fprintf(&buf, "Failed to process %d of %d image(s).\n\n", failed, len(images))
+	case runErr != nil:
+		fmt.Fprintf(&buf, "Failed: %s\n\n", markdownCell(runErr.Error()))
+	default:
+		fmt.Fprintf(&buf, "Processed %d image(s).\n\n", len(images))
+	}
+
+	if len(images) > 0 {
+		buf.WriteString("| Image | Action | Result | Duration |\n")
+		buf.WriteString("| --- | --- | --- | --- |\n")
+
+		for _, img := range images {
+			result := "`" + markdownCell(img.FinalURL) + "`"
+			if len(img.Error) > 0 {
+				result = "failed: " + markdownCell(img.Error)
+			} else if len(img.FinalURL) == 0 {
+				result = ""
+			}
+
+			duration := time.Duration(img.DurationMs) * time.Millisecond
+
+			fmt.Fprintf(&buf, "| `%s` | %s | %s | %s |\n",
+				markdownCell(img.URL), img.Action, result, duration)
+		}
+		buf.WriteString("\n")
+	}
+
+	return buf.Bytes()
+}
+
+func (a *GitHubAnnotations) writeCommand(name string, props map[string]string, msg string) {
+	a.outLock.Lock()
+	defer a.outLock.Unlock()
+
+	fmt.Fprintf(a.out, "%s\n", githubCommand(name, props, msg))
+}
+
+func githubCommand(name string, props map[string]string, msg string) string {
+	var propStrs []string
+
+	// Keep properties in a stable order
+	for _, key := range []string{"file", "line", "title"} {
+		if val, found := props[key]; found {
+			propStrs = append(propStrs, key+"="+githubEscapeProperty(val))
+		}
+	}
+
+	cmd := "::" + name
+	if len(propStrs) > 0 {
+		cmd += " " + strings.Join(propStrs, ",")
+	}
+
+	return cmd + "::" + githubEscapeData(msg)
+}
+
+func githubEscapeData(str string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(str)
+}
+
+func githubEscapeProperty(str string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(str)
+}
+
+func markdownCell(str string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ", "`", "'").Replace(str)
+}
+
+// findImageLocation returns first line in local files that references given image.
+// Lines that look like image fields (e.g. "image: nginx") are preferred
+// over other lines containing the same value (e.g. "name: nginx").
+func findImageLocation(files []string, url string) (string, int, bool) {
+	var fallbackPath string
+	var fallbackLine int
+
+	for _, file := range files {
+		fileRs, err := ctlres.NewFileResources(file)
+		if err != nil {
+			continue
+		}
+
+		for _, fileRes := range fileRs {
+			path, isLocal := fileRes.LocalPath()
+			if !isLocal {
+				continue
+			}
+
+			bs, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+
+			for i, line := range strings.Split(string(bs), "\n") {
+				if !lineReferencesImage(line, url) {
+					continue
+				}
+				if strings.Contains(strings.ToLower(line), "image") {
+					return path, i + 1, true
+				}
+				if fallbackLine == 0 {
+					fallbackPath, fallbackLine = path, i+1
+				}
+			}
+		}
+	}
+
+	return fallbackPath, fallbackLine, fallbackLine > 0
+}
+
+// lineReferencesImage checks that image url is present in a line
+// as a whole value (e.g. "nginx" does not match "nginx:1.17")
+func lineReferencesImage(line, url string) bool {
+	isRefChar := func(b byte) bool {
+		return b == '.' || b == '/' || b == ':' || b == '@' || b == '_' || b == '-' ||
+			(b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+	}
+
+	for offset := 0; offset < len(line); {
+		idx := strings.Index(line[offset:], url)
+		if idx == -1 {
+			return false
+		}
+		start := offset + idx
+		end := start + len(url)
+
+		if (start == 0 || !isRefChar(line[start-1])) && (end == len(line) || !isRefChar(line[end])) {
+			return true
+		}
+		offset = start + 1
+	}
+
+	return false
+}
+
+type githubWarningsWriter struct {
+	w           io.Writer
+	annotations *GitHubAnnotations
+	buf         []byte
+	lock        sync.Mutex
+}
+
+func (w *githubWarningsWriter) Write(data []byte) (int, error) {
+	n, err := w.w.Write(data)
+	if err != nil {
+		return n, err
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.buf = append(w.buf, data...)
+
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx == -1 {
+			break
+		}
+		line := string(w.buf[:idx])
+		w.buf = w.buf[idx+1:]
+
+		// Log lines are prefixed with their source (e.g. "resolve | warning: ...")
+		msg := line
+		if prefixIdx := strings.Index(line, " | "); prefixIdx != -1 {
+			msg = line[prefixIdx+len(" | "):]
+		}
+
+		if ctllog.LevelFromMessage(msg) == ctllog.LevelWarn {
+			msg = strings.TrimSpace(strings.TrimSpace(msg)[len("warning:"):])
+			w.annotations.writeCommand("warning", map[string]string{"title": "kbld"}, msg)
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestGitHubAnnotationsErrorsAndSummary(t *testing.T) {
+	dir := t.TempDir()
+
+	manifestPath := filepath.Join(dir, "app.yml")
+	manifest := `kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - name: nginx
+    image: nginx:1.17
+  - name: app
+    image: app
+`
+	require.NoError(t, os.WriteFile(manifestPath, []byte(manifest), 0600))
+
+	summaryPath := filepath.Join(dir, "summary.md")
+
+	var out bytes.Buffer
+	annotations := ctlcmd.NewGitHubAnnotations(&out, summaryPath)
+
+	annotations.Started("nginx:1.17", "resolving")
+	annotations.Started("app", "building")
+	annotations.Finished("nginx:1.17", nil)
+	annotations.Finished("app", fmt.Errorf("build failed:\nexit 1"))
+
+	resolvedImages := ctlcmd.NewProcessedImages()
+	resolvedImages.Add(ctlcmd.UnprocessedImageURL{URL: "nginx:1.17"}, ctlcmd.Image{
+		URL: "index.docker.io/library/nginx@sha256:1",
+	})
+	annotations.Complete(resolvedImages)
+
+	err := annotations.Finish(fmt.Errorf("failed"), []string{dir})
+	require.NoError(t, err)
+
+	expectedOut := fmt.Sprintf("::error file=%s,line=9,title=kbld::Resolving image 'app': build failed:%%0Aexit 1\n", manifestPath)
+	assert.Equal(t, expectedOut, out.String())
+
+	summary, err := os.ReadFile(summaryPath)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(summary), "Failed to process 1 of 2 image(s).")
+	assert.Contains(t, string(summary), "| `app` | building | failed: build failed: exit 1 |")
+	assert.Contains(t, string(summary), "| `nginx:1.17` | resolving | `index.docker.io/library/nginx@sha256:1` |")
+}
+
+func TestGitHubAnnotationsRunError(t *testing.T) {
+	var out bytes.Buffer
+	annotations := ctlcmd.NewGitHubAnnotations(&out, "")
+
+	err := annotations.Finish(fmt.Errorf("Parsing config: bad, value"), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "::error title=kbld::Parsing config: bad, value\n", out.String())
+}
+
+func TestGitHubAnnotationsWarnings(t *testing.T) {
+	var out bytes.Buffer
+	annotations := ctlcmd.NewGitHubAnnotations(&out, "")
+
+	writer := annotations.Writer(&out)
+
+	_, err := writer.Write([]byte("resolve | final: nginx -> nginx@sha256:1\nresolve | warn"))
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("ing: image 'nginx' is 100% stale\n"))
+	require.NoError(t, err)
+
+	expectedOut := "resolve | final: nginx -> nginx@sha256:1\nresolve | warning: image 'nginx' is 100% stale\n" +
+		"::warning title=kbld::image 'nginx' is 100%25 stale\n"
+	assert.Equal(t, expectedOut, out.String())
+}
@@ -0,0 +1,255 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/spf13/cobra"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/version"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// cmpPluginName is name of the plugin as referenced by
+	// Argo CD applications (spec.source.plugin.name)
+	cmpPluginName = "kbld"
+	// cmpPlatformParamEnvVar is set by Argo CD from 'platform' plugin parameter
+	cmpPlatformParamEnvVar = "PARAM_PLATFORM"
+)
+
+// NewCmpServerCmd provides commands run by argocd-cmp-server within
+// Argo CD repo server sidecar (commands are run in app source directory)
+func NewCmpServerCmd(ui ui.UI) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cmp-server",
+		Short: "Run as Argo CD Config Management Plugin (via argocd-cmp-server sidecar)",
+	}
+	cmd.AddCommand(NewCmpServerPluginCmd(NewCmpServerPluginOptions(ui)))
+	cmd.AddCommand(NewCmpServerDiscoverCmd(NewCmpServerDiscoverOptions(ui)))
+	cmd.AddCommand(NewCmpServerGenerateCmd(NewCmpServerGenerateOptions(ui)))
+	return cmd
+}
+
+type CmpServerPluginOptions struct {
+	ui ui.UI
+}
+
+func NewCmpServerPluginOptions(ui ui.UI) *CmpServerPluginOptions {
+	return &CmpServerPluginOptions{ui}
+}
+
+func NewCmpServerPluginCmd(o *CmpServerPluginOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "plugin",
+		Short: "Print plugin configuration (mount as /home/argocd/cmp-server/config/plugin.yaml in sidecar)",
+		RunE:  func(_ *cobra.Command, _ []string) error { return o.Run() },
+	}
+	return cmd
+}
+
+func (o *CmpServerPluginOptions) Run() error {
+	plugin := map[string]interface{}{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "ConfigManagementPlugin",
+		"metadata":   map[string]interface{}{"name": cmpPluginName},
+		"spec": map[string]interface{}{
+			"version": version.Version,
+			"discover": map[string]interface{}{
+				"find": map[string]interface{}{
+					"command": []string{"kbld", "cmp-server", "discover"},
+				},
+			},
+			"generate": map[string]interface{}{
+				"command": []string{"kbld", "cmp-server", "generate"},
+			},
+			"parameters": map[string]interface{}{
+				"static": []interface{}{
+					map[string]interface{}{
+						"name":  "platform",
+						"title": "Platform selection for image indexes (e.g. linux/amd64)",
+					},
+				},
+			},
+		},
+	}
+
+	bs, err := yaml.Marshal(plugin)
+	if err != nil {
+		return err
+	}
+
+	o.ui.PrintBlock(append([]byte("---\n"), bs...))
+
+	return nil
+}
+
+type CmpServerDiscoverOptions struct {
+	ui ui.UI
+
+	Directory string
+}
+
+func NewCmpServerDiscoverOptions(ui ui.UI) *CmpServerDiscoverOptions {
+	return &CmpServerDiscoverOptions{ui: ui}
+}
+
+func NewCmpServerDiscoverCmd(o *CmpServerDiscoverOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "discover",
+		Short: "Print path of kbld configuration found in app directory (prints nothing when not found)",
+		RunE:  func(_ *cobra.Command, _ []string) error { return o.Run() },
+	}
+	cmd.Flags().StringVar(&o.Directory, "dir", ".", "Set app directory")
+	return cmd
+}
+
+func (o *CmpServerDiscoverOptions) Run() error {
+	paths, err := cmpManifestPaths(o.Directory)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		fileRs, err := ctlres.NewFileResources(path)
+		if err != nil {
+			return err
+		}
+
+		for _, fileRes := range fileRs {
+			var found bool
+
+			// App directories may contain YAML files that are not resources
+			// (e.g. Helm values); such files are not considered kbld configuration
+			_ = fileRes.VisitResources(func(res ctlres.Resource) error {
+				found = found || !ctlconf.IsNonConfigResource(res)
+				return nil
+			})
+
+			if found {
+				relPath, err := filepath.Rel(o.Directory, path)
+				if err != nil {
+					return err
+				}
+				o.ui.PrintBlock([]byte(relPath + "\n"))
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+type CmpServerGenerateOptions struct {
+	ui ui.UI
+
+	RegistryFlags RegistryFlags
+	LoggerFlags   LoggerFlags
+
+	Directory string
+	Platform  string
+}
+
+func NewCmpServerGenerateOptions(ui ui.UI) *CmpServerGenerateOptions {
+	return &CmpServerGenerateOptions{ui: ui}
+}
+
+func NewCmpServerGenerateCmd(o *CmpServerGenerateOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Print resources found in app directory with resolved image references",
+		RunE:  func(_ *cobra.Command, _ []string) error { return o.Run() },
+	}
+	o.RegistryFlags.Set(cmd)
+	o.LoggerFlags.Set(cmd)
+	cmd.Flags().StringVar(&o.Directory, "dir", ".", "Set app directory")
+	cmd.Flags().StringVar(&o.Platform, "platform", os.Getenv(cmpPlatformParamEnvVar),
+		fmt.Sprintf("Apply platform selection to image indexes (defaults to $%s set from plugin parameter)", cmpPlatformParamEnvVar))
+	return cmd
+}
+
+func (o *CmpServerGenerateOptions) Run() error {
+	// Only manifests are expected to be written to stdout
+	logger, closeLogger, err := o.LoggerFlags.NewLogger()
+	if err != nil {
+		return err
+	}
+	defer closeLogger()
+
+	paths, err := cmpManifestPaths(o.Directory)
+	if err != nil {
+		return util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+	if len(paths) == 0 {
+		return util.NewCategorizedError(util.ErrorCategoryConfig,
+			fmt.Errorf("Expected to find at least one manifest in directory '%s'", o.Directory))
+	}
+
+	fileFlags := FileFlags{Files: paths}
+
+	nonConfigRs, conf, err := fileFlags.ResourcesAndConfig()
+	if err != nil {
+		return util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+
+	resolveOpts := &ResolveOptions{
+		ui:                o.ui,
+		RegistryFlags:     o.RegistryFlags,
+		BuildConcurrency:  1, // building is not allowed
+		ImagesAnnotation:  true,
+		OriginsAnnotation: true,
+		Platform:          o.Platform,
+	}
+
+	resBss, _, err := resolveOpts.resolveConfiguredResources(
+		nonConfigRs, conf, &logger, logger.NewPrefixedWriter("cmp-server | "))
+	if err != nil {
+		return err
+	}
+
+	for _, resBs := range resBss {
+		o.ui.PrintBlock(append([]byte("---\n"), resBs...))
+	}
+
+	return nil
+}
+
+// cmpManifestPaths returns sorted paths of manifest files within app
+// directory; hidden directories (e.g. .git, .github) are skipped since
+// they contain YAML files that are not meant to be deployed
+func cmpManifestPaths(dir string) ([]string, error) {
+	var paths []string
+
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if path != dir && strings.HasPrefix(fi.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".yml", ".yaml", ".json":
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Listing files in directory '%s': %s", dir, err)
+	}
+
+	sort.Strings(paths)
+
+	return paths, nil
+}
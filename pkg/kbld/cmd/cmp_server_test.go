@@ -0,0 +1,82 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestCmpServerDiscover(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFile := func(path, content string) {
+		path = filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	}
+
+	discover := func(t *testing.T) string {
+		var stdout bytes.Buffer
+		cmd := ctlcmd.NewCmpServerDiscoverCmd(ctlcmd.NewCmpServerDiscoverOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+		cmd.SetArgs([]string{"--dir", dir})
+		require.NoError(t, cmd.Execute())
+		return stdout.String()
+	}
+
+	writeFile("deploy.yml", "kind: Deployment\nmetadata:\n  name: app\n")
+	writeFile("values.yml", "not: [a resource")
+	writeFile(".github/kbld.yml", "apiVersion: kbld.k14s.io/v1alpha1\nkind: Config\n")
+	assert.Equal(t, "", discover(t))
+
+	writeFile("config/kbld.yml", "---\nkind: ConfigMap\n---\napiVersion: kbld.k14s.io/v1alpha1\nkind: Config\n")
+	assert.Equal(t, "config/kbld.yml\n", discover(t))
+}
+
+func TestCmpServerGenerate(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "deploy.yml"), []byte(`
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: app
+`), 0600))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "kbld.yml"), []byte(`
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+overrides:
+- image: app
+  newImage: registry.example.com/app@sha256:0000000000000000000000000000000000000000000000000000000000000001
+  preresolved: true
+`), 0600))
+
+	var stdout bytes.Buffer
+	cmd := ctlcmd.NewCmpServerGenerateCmd(ctlcmd.NewCmpServerGenerateOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+	cmd.SetArgs([]string{"--dir", dir, "--log-level", "error"})
+	require.NoError(t, cmd.Execute())
+
+	assert.Contains(t, stdout.String(), "- image: registry.example.com/app@sha256:0000000000000000000000000000000000000000000000000000000000000001\n")
+	assert.NotContains(t, stdout.String(), "kind: Config")
+}
+
+func TestCmpServerPlugin(t *testing.T) {
+	var stdout bytes.Buffer
+	cmd := ctlcmd.NewCmpServerPluginCmd(ctlcmd.NewCmpServerPluginOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+	require.NoError(t, cmd.Execute())
+
+	assert.Contains(t, stdout.String(), "kind: ConfigManagementPlugin\n")
+	assert.Contains(t, stdout.String(), "    - cmp-server\n    - generate\n")
+}
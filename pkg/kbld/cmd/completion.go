@@ -0,0 +1,192 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+var (
+	// completionPlatforms are suggested for '--platform'
+	// in addition to platforms found in configuration
+	completionPlatforms = []string{
+		"linux/amd64", "linux/arm64", "linux/arm/v7", "linux/arm/v6",
+		"linux/386", "linux/ppc64le", "linux/s390x", "windows/amd64",
+	}
+)
+
+func NewCompletionCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "completion",
+		Short: "Print shell completion script (completes image names from kbld configuration in inputs or current directory)",
+	}
+
+	shells := map[string]func(*cobra.Command) error{
+		"bash":       func(cmd *cobra.Command) error { return cmd.Root().GenBashCompletionV2(cmd.OutOrStdout(), true) },
+		"zsh":        func(cmd *cobra.Command) error { return cmd.Root().GenZshCompletion(cmd.OutOrStdout()) },
+		"fish":       func(cmd *cobra.Command) error { return cmd.Root().GenFishCompletion(cmd.OutOrStdout(), true) },
+		"powershell": func(cmd *cobra.Command) error { return cmd.Root().GenPowerShellCompletionWithDesc(cmd.OutOrStdout()) },
+	}
+
+	for _, shell := range []string{"bash", "zsh", "fish", "powershell"} {
+		genFunc := shells[shell]
+		cmd.AddCommand(&cobra.Command{
+			Use:   shell,
+			Short: fmt.Sprintf("Print %s completion script", shell),
+			RunE:  func(cmd *cobra.Command, _ []string) error { return genFunc(cmd) },
+		})
+	}
+
+	return cmd
+}
+
+// RegisterCompletions adds dynamic completion for flags that
+// take files, image names, platforms or cluster profiles (if command has such flags)
+func RegisterCompletions(cmd *cobra.Command) {
+	completions := map[string]func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective){
+		"file":            completeFiles,
+		"config":          completeFiles,
+		"image":           completeImages,
+		"platform":        completePlatforms,
+		"cluster-profile": completeClusterProfiles,
+	}
+
+	for flagName, completeFunc := range completions {
+		if cmd.Flags().Lookup(flagName) != nil {
+			// Error is only returned for missing or already registered flags
+			_ = cmd.RegisterFlagCompletionFunc(flagName, completeFunc)
+		}
+	}
+}
+
+func completeFiles(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	return []string{"yml", "yaml", "json"}, cobra.ShellCompDirectiveFilterFileExt
+}
+
+func completeImages(cmd *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	conf := completionConf(cmd)
+
+	var images []string
+	for _, src := range conf.Sources() {
+		images = append(images, src.Image)
+	}
+	for _, override := range conf.ImageOverrides() {
+		images = append(images, override.Image)
+	}
+	for _, dst := range conf.ImageDestinations() {
+		images = append(images, dst.Image)
+	}
+
+	return completionCandidates(images, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completePlatforms(cmd *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var platforms []string
+
+	conf := completionConf(cmd)
+
+	var selections []*ctlconf.PlatformSelection
+	for _, override := range conf.ImageOverrides() {
+		selections = append(selections, override.PlatformSelection)
+	}
+	for _, profile := range conf.ClusterProfiles() {
+		selections = append(selections, profile.Platform)
+	}
+
+	for _, sel := range selections {
+		if sel != nil && len(sel.OS) > 0 && len(sel.Architecture) > 0 {
+			platform := sel.OS + "/" + sel.Architecture
+			if len(sel.Variant) > 0 {
+				platform += "/" + sel.Variant
+			}
+			platforms = append(platforms, platform)
+		}
+	}
+
+	return completionCandidates(append(platforms, completionPlatforms...), toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeClusterProfiles(cmd *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var names []string
+	for _, profile := range completionConf(cmd).ClusterProfiles() {
+		names = append(names, profile.Name)
+	}
+	return completionCandidates(names, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completionConf returns configuration from inputs specified so far
+// or from files in current directory (remote inputs are not fetched).
+// Files that cannot be parsed are skipped since completion is best effort.
+func completionConf(cmd *cobra.Command) ctlconf.Conf {
+	var files []string
+
+	for _, flagName := range []string{"file", "config"} {
+		if flagFiles, err := cmd.Flags().GetStringSlice(flagName); err == nil {
+			files = append(files, flagFiles...)
+		} else if flagFile, err := cmd.Flags().GetString(flagName); err == nil && len(flagFile) > 0 {
+			files = append(files, flagFile)
+		}
+	}
+
+	if len(files) == 0 {
+		for _, ext := range []string{"*.yml", "*.yaml", "*.json"} {
+			paths, _ := filepath.Glob(ext)
+			files = append(files, paths...)
+		}
+	}
+
+	var confRs []ctlres.Resource
+
+	for _, file := range files {
+		if file == "-" || strings.HasPrefix(file, "http://") || strings.HasPrefix(file, "https://") {
+			continue
+		}
+
+		fileRs, err := ctlres.NewFileResources(file)
+		if err != nil {
+			continue
+		}
+
+		for _, fileRes := range fileRs {
+			_ = fileRes.VisitResources(func(res ctlres.Resource) error {
+				if !ctlconf.IsNonConfigResource(res) {
+					confRs = append(confRs, res)
+				}
+				return nil
+			})
+		}
+	}
+
+	_, conf, err := ctlconf.NewConfFromResources(confRs)
+	if err != nil {
+		return ctlconf.Conf{}
+	}
+
+	return conf
+}
+
+// completionCandidates returns sorted unique values with given prefix
+func completionCandidates(vals []string, toComplete string) []string {
+	seen := map[string]struct{}{}
+
+	var result []string
+	for _, val := range vals {
+		if _, found := seen[val]; found || len(val) == 0 || !strings.HasPrefix(val, toComplete) {
+			continue
+		}
+		seen[val] = struct{}{}
+		result = append(result, val)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
@@ -0,0 +1,54 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestCompletionFromConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kbld.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+sources:
+- image: app
+  path: .
+destinations:
+- image: worker
+  newImage: registry.example.com/worker
+overrides:
+- image: nginx
+  newImage: nginx:1.25
+  platformSelection:
+    os: linux
+    architecture: riscv64
+`), 0600))
+
+	complete := func(t *testing.T, args ...string) []string {
+		var stdout bytes.Buffer
+		confUI := ui.NewWrappingConfUI(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger()), ui.NewNoopLogger())
+
+		cmd := ctlcmd.NewDefaultKbldCmd(confUI)
+		cmd.SetArgs(append([]string{"__complete"}, args...))
+		require.NoError(t, cmd.Execute())
+
+		return strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	}
+
+	assert.Equal(t, []string{"app", "nginx", "worker", ":4"}, complete(t, "build", "-f", path, "--image", ""))
+	assert.Equal(t, []string{"worker", ":4"}, complete(t, "build", "-f", path, "--image", "w"))
+	assert.Equal(t, []string{"linux/riscv64", ":4"}, complete(t, "-f", path, "--platform", "linux/r"))
+	assert.Equal(t, []string{"yml", "yaml", "json", ":8"}, complete(t, "-f", ""))
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"encoding/json"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/spf13/cobra"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	"sigs.k8s.io/yaml"
+)
+
+func NewConfigCmd(ui ui.UI) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Show configuration",
+	}
+	cmd.AddCommand(NewConfigViewCmd(NewConfigViewOptions(ui)))
+	cmd.AddCommand(NewConfigDefaultsCmd(NewConfigDefaultsOptions(ui)))
+	cmd.AddCommand(NewConfigSchemaCmd(NewConfigSchemaOptions(ui)))
+	return cmd
+}
+
+type ConfigViewOptions struct {
+	ui ui.UI
+
+	FileFlags    FileFlags
+	WithDefaults bool
+}
+
+func NewConfigViewOptions(ui ui.UI) *ConfigViewOptions {
+	return &ConfigViewOptions{ui: ui}
+}
+
+func NewConfigViewCmd(o *ConfigViewOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "view",
+		Short: "Print effective configuration merged from all inputs",
+		RunE:  func(_ *cobra.Command, _ []string) error { return o.Run() },
+	}
+	o.FileFlags.Set(cmd)
+	cmd.Flags().BoolVar(&o.WithDefaults, "with-defaults", false, "Include default search rules (matched after configured ones)")
+	return cmd
+}
+
+func (o *ConfigViewOptions) Run() error {
+	_, conf, err := o.FileFlags.ResourcesAndConfig()
+	if err != nil {
+		return err
+	}
+
+	config := conf.EffectiveConfig()
+	if o.WithDefaults {
+		config.SearchRules = conf.SearchRules()
+	}
+
+	bs, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	o.ui.PrintBlock(append([]byte("---\n"), bs...))
+
+	return nil
+}
+
+type ConfigDefaultsOptions struct {
+	ui ui.UI
+}
+
+func NewConfigDefaultsOptions(ui ui.UI) *ConfigDefaultsOptions {
+	return &ConfigDefaultsOptions{ui}
+}
+
+func NewConfigDefaultsCmd(o *ConfigDefaultsOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "defaults",
+		Short: "Print default search rules (used in addition to configured ones)",
+		RunE:  func(_ *cobra.Command, _ []string) error { return o.Run() },
+	}
+	return cmd
+}
+
+func (o *ConfigDefaultsOptions) Run() error {
+	config := ctlconf.NewConfig()
+	config.SearchRules = ctlconf.DefaultSearchRules()
+
+	bs, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	o.ui.PrintBlock(append([]byte("---\n"), bs...))
+
+	return nil
+}
+
+type ConfigSchemaOptions struct {
+	ui ui.UI
+}
+
+func NewConfigSchemaOptions(ui ui.UI) *ConfigSchemaOptions {
+	return &ConfigSchemaOptions{ui}
+}
+
+func NewConfigSchemaCmd(o *ConfigSchemaOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Print JSON schema of configuration (older apiVersions are converted before validation)",
+		RunE:  func(_ *cobra.Command, _ []string) error { return o.Run() },
+	}
+	return cmd
+}
+
+func (o *ConfigSchemaOptions) Run() error {
+	bs, err := json.MarshalIndent(ctlconf.ConfigSchema(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	o.ui.PrintBlock(append(bs, '\n'))
+
+	return nil
+}
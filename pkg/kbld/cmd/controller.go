@@ -0,0 +1,176 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/spf13/cobra"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlctrl "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/controller"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlmetrics "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/metrics"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+type ControllerOptions struct {
+	ui ui.UI
+
+	RegistryFlags RegistryFlags
+	LoggerFlags   LoggerFlags
+
+	Namespace        string
+	Interval         time.Duration
+	AllowedToBuild   bool
+	BuildConcurrency int
+	MetricsAddress   string
+	Pprof            bool
+}
+
+func NewControllerOptions(ui ui.UI) *ControllerOptions {
+	return &ControllerOptions{ui: ui}
+}
+
+func NewControllerCmd(o *ControllerOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:         "controller",
+		Short:       "Run controller reconciling ImageResolution resources in a cluster",
+		Annotations: map[string]string{handlesSignalsAnnotation: ""},
+		RunE:        func(_ *cobra.Command, _ []string) error { return o.Run() },
+	}
+	o.RegistryFlags.Set(cmd)
+	o.LoggerFlags.Set(cmd)
+	cmd.Flags().StringVarP(&o.Namespace, "namespace", "n", "", "Limit to namespace (all namespaces when empty)")
+	cmd.Flags().DurationVar(&o.Interval, "interval", 30*time.Second, "Set interval for checking ImageResolution resources and their inputs")
+	cmd.Flags().BoolVar(&o.AllowedToBuild, "build", true, "Allow building of images")
+	cmd.Flags().IntVar(&o.BuildConcurrency, "build-concurrency", 4, "Set maximum number of concurrent builds")
+	cmd.Flags().StringVar(&o.MetricsAddress, "metrics-address", "", "Set address to expose Prometheus metrics on (format: :9090) (disabled when empty)")
+	cmd.Flags().BoolVar(&o.Pprof, "pprof", false, "Expose runtime profiles at /debug/pprof/ on metrics address")
+	return cmd
+}
+
+func (o *ControllerOptions) Run() error {
+	if o.Pprof && len(o.MetricsAddress) == 0 {
+		return fmt.Errorf("Expected '--metrics-address' to be specified when using '--pprof'")
+	}
+
+	logger, closeLogger, err := o.LoggerFlags.NewLogger()
+	if err != nil {
+		return err
+	}
+	defer closeLogger()
+
+	registryOpts, err := o.RegistryFlags.AsRegistryOpts()
+	if err != nil {
+		return err
+	}
+
+	var metrics *ctlmetrics.Metrics
+	if len(o.MetricsAddress) > 0 {
+		metrics = ctlmetrics.New()
+		registryOpts.Metrics = metrics
+	}
+
+	registry, err := ctlreg.NewRegistry(registryOpts)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if metrics != nil {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		if o.Pprof {
+			registerPprof(mux)
+		}
+
+		server := &http.Server{Addr: o.MetricsAddress, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+		go func() {
+			err := server.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				logger.NewPrefixedWriter("controller | ").WriteStr("error: serving metrics: %s\n", err)
+			}
+		}()
+		defer server.Close()
+	}
+
+	controller := ctlctrl.NewController(ctlctrl.ExecKubectl{}, o.resolveFunc(registry, metrics, logger), o.Namespace, logger)
+
+	return controller.Run(ctx, o.Interval)
+}
+
+func (o *ControllerOptions) resolveFunc(registry ctlreg.Registry,
+	metrics *ctlmetrics.Metrics, logger ctllog.Logger) ctlctrl.ResolveFunc {
+
+	return func(rs []ctlres.Resource, sourcesDir string) (ctlctrl.ResolveResult, error) {
+		started := time.Now()
+
+		result, err := o.resolve(rs, sourcesDir, registry, metrics, logger)
+		metrics.ObserveResolution("controller", time.Since(started), err)
+
+		return result, err
+	}
+}
+
+func (o *ControllerOptions) resolve(rs []ctlres.Resource, sourcesDir string, registry ctlreg.Registry,
+	metrics *ctlmetrics.Metrics, logger ctllog.Logger) (ctlctrl.ResolveResult, error) {
+
+	nonConfigRs, conf, err := ctlconf.NewConfFromResources(rs)
+	if err != nil {
+		return ctlctrl.ResolveResult{}, err
+	}
+
+	if len(sourcesDir) > 0 {
+		conf = conf.WithSourcesDir(sourcesDir)
+	}
+
+	tmpDir, err := util.MkdirTemp("kbld-controller")
+	if err != nil {
+		return ctlctrl.ResolveResult{}, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	resolveOpts := &ResolveOptions{
+		ui:                o.ui,
+		AllowedToBuild:    o.AllowedToBuild,
+		BuildConcurrency:  o.BuildConcurrency,
+		ImagesAnnotation:  true,
+		OriginsAnnotation: true,
+		LockOutput:        filepath.Join(tmpDir, "lock.yml"),
+		registry:          &registry,
+		metrics:           metrics,
+	}
+
+	pLogger := logger.NewPrefixedWriter("controller | ")
+
+	resBss, _, err := resolveOpts.resolveConfiguredResources(nonConfigRs, conf, &logger, pLogger)
+	if err != nil {
+		return ctlctrl.ResolveResult{}, err
+	}
+
+	var result ctlctrl.ResolveResult
+	for _, resBs := range resBss {
+		result.Manifests = append(result.Manifests, append([]byte("---\n"), resBs...)...)
+	}
+
+	result.Lock, err = os.ReadFile(resolveOpts.LockOutput)
+	if err != nil {
+		return ctlctrl.ResolveResult{}, err
+	}
+
+	return result, nil
+}
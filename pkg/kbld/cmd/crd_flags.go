@@ -0,0 +1,114 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+
+	"github.com/spf13/cobra"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+	"sigs.k8s.io/yaml"
+)
+
+// CRDFlags configure deriving search rules from CRD schemas
+// (fields that are documented as image references)
+type CRDFlags struct {
+	FromInputs  bool
+	Files       []string
+	FromCluster bool
+}
+
+func (s *CRDFlags) Set(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&s.FromInputs, "crd-search-rules", false, "Add search rules for image fields found in schemas of CRDs included in inputs")
+	cmd.Flags().StringSliceVar(&s.Files, "crd-file", nil, "Add search rules for image fields found in schemas of CRDs in file (format: /tmp/foo, https://...) (can be specified multiple times)")
+	cmd.Flags().BoolVar(&s.FromCluster, "crd-from-cluster", false, "Add search rules for image fields found in schemas of CRDs installed in cluster (uses kubectl)")
+}
+
+func (s *CRDFlags) WithSearchRules(conf ctlconf.Conf, rs []ctlres.Resource) (ctlconf.Conf, error) {
+	var crds []ctlres.Resource
+
+	if s.FromInputs {
+		crds = append(crds, rs...)
+	}
+
+	for _, file := range s.Files {
+		fileRs, err := ctlres.NewFileResources(file)
+		if err != nil {
+			return ctlconf.Conf{}, err
+		}
+		for _, fileRes := range fileRs {
+			resources, err := fileRes.Resources()
+			if err != nil {
+				return ctlconf.Conf{}, err
+			}
+			crds = append(crds, resources...)
+		}
+	}
+
+	if s.FromCluster {
+		clusterRs, err := s.clusterCRDs()
+		if err != nil {
+			return ctlconf.Conf{}, err
+		}
+		crds = append(crds, clusterRs...)
+	}
+
+	additionalConfig := ctlconf.Config{}
+
+	for _, res := range crds {
+		if !ctlconf.IsCRD(res) {
+			continue
+		}
+		rules, err := ctlconf.NewSearchRulesFromCRD(res)
+		if err != nil {
+			return ctlconf.Conf{}, err
+		}
+		additionalConfig.SearchRules = append(additionalConfig.SearchRules, rules...)
+	}
+
+	if len(additionalConfig.SearchRules) == 0 {
+		return conf, nil
+	}
+
+	return conf.WithAdditionalConfig(additionalConfig), nil
+}
+
+func (s *CRDFlags) clusterCRDs() ([]ctlres.Resource, error) {
+	var stdoutBuf, stderrBuf bytes.Buffer
+
+	cmd := exec.Command("kubectl", "get", "customresourcedefinitions", "-o", "yaml")
+	cmd.Stdout = &stdoutBuf
+	cmd.Stderr = &stderrBuf
+
+	err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("Listing CRDs in cluster: %s (stderr: %s)", err, stderrBuf.String())
+	}
+
+	// kubectl returns List kind with CRDs as items
+	list, err := ctlres.NewResourceFromBytes(stdoutBuf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("Parsing CRDs from cluster: %s", err)
+	}
+
+	items, _ := list.DeepCopyRaw()["items"].([]interface{})
+
+	var rs []ctlres.Resource
+	for _, item := range items {
+		itemBs, err := yaml.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		res, err := ctlres.NewResourceFromBytes(itemBs)
+		if err != nil {
+			return nil, fmt.Errorf("Parsing CRDs from cluster: %s", err)
+		}
+		rs = append(rs, res)
+	}
+
+	return rs, nil
+}
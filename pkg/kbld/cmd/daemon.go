@@ -0,0 +1,178 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/spf13/cobra"
+	ctlmetrics "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/metrics"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+const (
+	daemonSocketEnvVar = "KBLD_DAEMON_SOCKET"
+)
+
+type DaemonOptions struct {
+	ui ui.UI
+
+	RegistryFlags RegistryFlags
+	LoggerFlags   LoggerFlags
+
+	Socket           string
+	AllowedToBuild   bool
+	BuildConcurrency int
+	DigestCache      string
+
+	AllowRestrictedConfig bool
+}
+
+func NewDaemonOptions(ui ui.UI) *DaemonOptions {
+	return &DaemonOptions{ui: ui}
+}
+
+func NewDaemonCmd(o *DaemonOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:         "daemon",
+		Short:       "Run local daemon that resolves images for CLI invocations using --daemon-socket (keeps registry connections and caches warm)",
+		Annotations: map[string]string{handlesSignalsAnnotation: ""},
+		RunE:        func(_ *cobra.Command, _ []string) error { return o.Run() },
+	}
+	o.RegistryFlags.Set(cmd)
+	o.LoggerFlags.Set(cmd)
+	cmd.Flags().StringVar(&o.Socket, "socket", DefaultDaemonSocketPath(), "Set unix socket path to listen on")
+	cmd.Flags().BoolVar(&o.AllowedToBuild, "build", true, "Allow building of images (relative source paths are relative to daemon's working directory)")
+	cmd.Flags().IntVar(&o.BuildConcurrency, "build-concurrency", 4, "Set maximum number of concurrent builds per invocation")
+	cmd.Flags().StringVar(&o.DigestCache, "digest-cache", "auto", "Set file path to cache platform selections of image indexes (auto uses user cache directory; empty disables)")
+	cmd.Flags().BoolVar(&o.AllowRestrictedConfig, "allow-restricted-config", false,
+		"Allow invocation configuration to run commands, push images (e.g. destinations) and access local files")
+	return cmd
+}
+
+// DefaultDaemonSocketPath returns socket path shared by daemon and CLI
+// (KBLD_DAEMON_SOCKET env var when set)
+func DefaultDaemonSocketPath() string {
+	if path := os.Getenv(daemonSocketEnvVar); len(path) > 0 {
+		return path
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("kbld-%d.sock", os.Getuid()))
+}
+
+func (o *DaemonOptions) Run() error {
+	logger, closeLogger, err := o.LoggerFlags.NewLogger()
+	if err != nil {
+		return err
+	}
+	defer closeLogger()
+
+	registryOpts, err := o.RegistryFlags.AsRegistryOpts()
+	if err != nil {
+		return err
+	}
+
+	metrics := ctlmetrics.New()
+	registryOpts.Metrics = metrics
+
+	// Registry (with its connections and credentials) and
+	// digest cache are shared by all invocations
+	registry, err := ctlreg.NewRegistry(registryOpts)
+	if err != nil {
+		return err
+	}
+
+	digestCache, err := (&ResolveOptions{DigestCache: o.DigestCache}).digestCache()
+	if err != nil {
+		return err
+	}
+
+	serveOpts := ServeOptions{
+		AllowedToBuild:        o.AllowedToBuild,
+		BuildConcurrency:      o.BuildConcurrency,
+		AllowRestrictedConfig: o.AllowRestrictedConfig,
+		// Inputs come from local CLI invocations
+		MaxRequestBytes: 1024 * 1024 * 1024,
+	}
+
+	resolveServer := NewResolveServer(o.ui, registry, logger, serveOpts).
+		WithMetrics(metrics).WithDigestCache(digestCache)
+
+	listener, err := ListenDaemonSocket(o.Socket)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Handler:           resolveServer,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	prefixedLogger := logger.NewPrefixedWriter("daemon | ")
+	prefixedLogger.WriteStr("listening on %s\n", o.Socket)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.Serve(listener) }()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		prefixedLogger.WriteStr("shutting down\n")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		return server.Shutdown(shutdownCtx)
+	}
+}
+
+// ListenDaemonSocket listens on unix socket only accessible by current user.
+// Socket left behind by daemon that is no longer running is removed.
+func ListenDaemonSocket(path string) (net.Listener, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("Expected no other daemon to listen on '%s'", path)
+	}
+
+	// Only remove stale socket (never other files given by mistake)
+	info, err := os.Lstat(path)
+	switch {
+	case err == nil:
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("Expected '%s' to be a socket, but was not", path)
+		}
+		err = os.Remove(path)
+		if err != nil {
+			return nil, fmt.Errorf("Removing stale socket: %s", err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("Checking socket: %s", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("Listening on socket: %s", err)
+	}
+
+	err = os.Chmod(path, 0600)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("Restricting socket permissions: %s", err)
+	}
+
+	return listener, nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+func TestResolveViaDaemon(t *testing.T) {
+	registry, err := ctlreg.NewRegistry(ctlreg.Opts{})
+	require.NoError(t, err)
+
+	dir, err := os.MkdirTemp("", "kbld-daemon-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Unix socket paths are limited in length
+	socketPath := filepath.Join(dir, "kbld.sock")
+
+	listener, err := ctlcmd.ListenDaemonSocket(socketPath)
+	require.NoError(t, err)
+
+	server := &http.Server{Handler: ctlcmd.NewResolveServer(ui.NewConfUI(ui.NewNoopLogger()), registry,
+		ctllog.NewLogger(io.Discard), ctlcmd.ServeOptions{BuildConcurrency: 1, MaxRequestBytes: 1024 * 1024})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	info, err := os.Stat(socketPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	_, err = ctlcmd.ListenDaemonSocket(socketPath)
+	require.EqualError(t, err, "Expected no other daemon to listen on '"+socketPath+"'")
+
+	// Other files are not removed
+	filePath := filepath.Join(dir, "config.yml")
+	require.NoError(t, os.WriteFile(filePath, []byte("kind: Config"), 0600))
+
+	_, err = ctlcmd.ListenDaemonSocket(filePath)
+	require.EqualError(t, err, "Expected '"+filePath+"' to be a socket, but was not")
+
+	bs, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "kind: Config", string(bs))
+
+	resolve := func(input string, args ...string) (string, error) {
+		path := filepath.Join(t.TempDir(), "input.yml")
+		require.NoError(t, os.WriteFile(path, []byte(input), 0600))
+
+		var stdout bytes.Buffer
+
+		cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+		cmd.SetArgs(append([]string{"-f", path, "--daemon-socket", socketPath}, args...))
+
+		err := cmd.Execute()
+		return stdout.String(), err
+	}
+
+	t.Run("resolves inputs", func(t *testing.T) {
+		out, err := resolve(`
+kind: Object
+spec:
+- image: app
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+overrides:
+- image: app
+  newImage: registry.example.com/app@sha256:0000000000000000000000000000000000000000000000000000000000000001
+`)
+		require.NoError(t, err)
+		assert.Contains(t, out, "- image: registry.example.com/app@sha256:0000000000000000000000000000000000000000000000000000000000000001")
+		assert.NotContains(t, out, "kind: Config")
+	})
+
+	t.Run("returns daemon errors", func(t *testing.T) {
+		_, err := resolve("kind: [unclosed")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Parsing")
+	})
+
+	t.Run("rejects flags that are not forwarded", func(t *testing.T) {
+		_, err := resolve("kind: Object", "--lock-output", filepath.Join(t.TempDir(), "lock.yml"))
+		require.EqualError(t, err, "Expected '--daemon-socket' to not be used with '--lock-output'")
+	})
+}
@@ -0,0 +1,247 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	uitable "github.com/cppforlife/go-cli-ui/ui/table"
+	regname "github.com/google/go-containerregistry/pkg/name"
+	"github.com/spf13/cobra"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+	ctlser "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/search"
+)
+
+type DiffOptions struct {
+	ui ui.UI
+
+	OldLock  string
+	NewLock  string
+	OldFiles []string
+	NewFiles []string
+	Markdown bool
+}
+
+func NewDiffOptions(ui ui.UI) *DiffOptions {
+	return &DiffOptions{ui: ui}
+}
+
+func NewDiffCmd(o *DiffOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Show image reference changes between two lock files or rendered outputs",
+		RunE:  func(_ *cobra.Command, _ []string) error { return o.Run() },
+	}
+	cmd.Flags().StringVar(&o.OldLock, "lock-old", "", "Old lock file (kbld or imgpkg lock)")
+	cmd.Flags().StringVar(&o.NewLock, "lock-new", "", "New lock file (kbld or imgpkg lock)")
+	cmd.Flags().StringSliceVar(&o.OldFiles, "old", nil, "Old rendered output (format: /tmp/foo, https://..., -) (can be specified multiple times)")
+	cmd.Flags().StringSliceVar(&o.NewFiles, "new", nil, "New rendered output (format: /tmp/foo, https://..., -) (can be specified multiple times)")
+	cmd.Flags().BoolVar(&o.Markdown, "markdown", false, "Print changes as Markdown table (e.g. for pull request comments)")
+	return cmd
+}
+
+func (o *DiffOptions) Run() error {
+	var oldImages, newImages map[string]string
+	var err error
+
+	switch {
+	case len(o.OldLock) > 0 || len(o.NewLock) > 0:
+		if len(o.OldLock) == 0 || len(o.NewLock) == 0 || len(o.OldFiles) > 0 || len(o.NewFiles) > 0 {
+			return fmt.Errorf("Expected both '--lock-old' and '--lock-new' (and not '--old' or '--new') to be specified")
+		}
+		oldImages, err = o.lockImages(o.OldLock)
+		if err != nil {
+			return err
+		}
+		newImages, err = o.lockImages(o.NewLock)
+		if err != nil {
+			return err
+		}
+
+	case len(o.OldFiles) > 0 && len(o.NewFiles) > 0:
+		oldImages, err = o.renderedImages(o.OldFiles)
+		if err != nil {
+			return err
+		}
+		newImages, err = o.renderedImages(o.NewFiles)
+		if err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("Expected either '--lock-old' and '--lock-new' or '--old' and '--new' to be specified")
+	}
+
+	changes := NewImageChanges(oldImages, newImages)
+
+	if o.Markdown {
+		o.ui.PrintBlock([]byte(ImageChangesMarkdown(changes)))
+		return nil
+	}
+
+	table := uitable.Table{
+		Title:   "Image changes",
+		Content: "changes",
+
+		Header: []uitable.Header{
+			uitable.NewHeader("Image"),
+			uitable.NewHeader("Change"),
+			uitable.NewHeader("Old"),
+			uitable.NewHeader("New"),
+		},
+
+		SortBy: []uitable.ColumnSort{{Column: 0, Asc: true}},
+	}
+
+	for _, change := range changes {
+		table.Rows = append(table.Rows, []uitable.Value{
+			uitable.NewValueString(change.Key),
+			change.typeValue(),
+			uitable.NewValueString(change.Old),
+			uitable.NewValueString(change.New),
+		})
+	}
+
+	o.ui.PrintTable(table)
+
+	return nil
+}
+
+// lockImages returns new image keyed by original image reference
+func (o *DiffOptions) lockImages(path string) (map[string]string, error) {
+	rs, err := (&FileFlags{Files: []string{path}}).AllResources()
+	if err != nil {
+		return nil, err
+	}
+
+	_, conf, err := ctlconf.NewConfFromResources(rs)
+	if err != nil {
+		return nil, fmt.Errorf("Reading lock file '%s': %s", path, err)
+	}
+
+	result := map[string]string{}
+	for _, override := range conf.ImageOverrides() {
+		key := override.Image
+		if len(key) == 0 {
+			key = override.ImageRepo
+		}
+		result[key] = override.NewImage
+	}
+	return result, nil
+}
+
+// renderedImages returns image references keyed by resource and image repository
+// so that only reference changes (and not YAML formatting changes) are detected
+func (o *DiffOptions) renderedImages(files []string) (map[string]string, error) {
+	rs, conf, err := (&FileFlags{Files: files}).ResourcesAndConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]string{}
+
+	for _, res := range rs {
+		imageRefs := ctlser.NewImageRefs(res.DeepCopyRaw(), conf.SearchRules())
+
+		imageRefs.Visit(func(imgURL string) (string, bool) {
+			result[o.renderedImageKey(res, imgURL)] = imgURL
+			return "", false
+		})
+	}
+
+	return result, nil
+}
+
+func (o *DiffOptions) renderedImageKey(res ctlres.Resource, imgURL string) string {
+	repo := imgURL
+	if ref, err := regname.ParseReference(imgURL, regname.WeakValidation); err == nil {
+		repo = ref.Context().Name()
+	}
+	return res.Description() + ": " + repo
+}
+
+type ImageChangeType string
+
+const (
+	ImageChangeAdded   ImageChangeType = "added"
+	ImageChangeRemoved ImageChangeType = "removed"
+	ImageChangeUpdated ImageChangeType = "updated"
+)
+
+type ImageChange struct {
+	Key  string
+	Type ImageChangeType
+	Old  string
+	New  string
+}
+
+// typeValue highlights added and removed images when color is enabled
+func (c ImageChange) typeValue() uitable.Value {
+	switch c.Type {
+	case ImageChangeAdded:
+		return uitable.NewValueFmt(uitable.NewValueString(string(c.Type)), false)
+	case ImageChangeRemoved:
+		return uitable.NewValueFmt(uitable.NewValueString(string(c.Type)), true)
+	default:
+		return uitable.NewValueString(string(c.Type))
+	}
+}
+
+// NewImageChanges returns sorted list of differences between two sets of images
+func NewImageChanges(oldImages, newImages map[string]string) []ImageChange {
+	var changes []ImageChange
+
+	for key, oldURL := range oldImages {
+		newURL, found := newImages[key]
+		switch {
+		case !found:
+			changes = append(changes, ImageChange{Key: key, Type: ImageChangeRemoved, Old: oldURL})
+		case newURL != oldURL:
+			changes = append(changes, ImageChange{Key: key, Type: ImageChangeUpdated, Old: oldURL, New: newURL})
+		}
+	}
+
+	for key, newURL := range newImages {
+		if _, found := oldImages[key]; !found {
+			changes = append(changes, ImageChange{Key: key, Type: ImageChangeAdded, New: newURL})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+
+	return changes
+}
+
+func ImageChangesMarkdown(changes []ImageChange) string {
+	if len(changes) == 0 {
+		return "No image changes.\n"
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("| Image | Change | Old | New |\n")
+	sb.WriteString("| --- | --- | --- | --- |\n")
+
+	for _, change := range changes {
+		sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n", markdownEscape(change.Key),
+			change.Type, markdownCode(change.Old), markdownCode(change.New)))
+	}
+
+	return sb.String()
+}
+
+func markdownCode(str string) string {
+	if len(str) == 0 {
+		return ""
+	}
+	return "`" + str + "`"
+}
+
+func markdownEscape(str string) string {
+	return strings.ReplaceAll(str, "|", "\\|")
+}
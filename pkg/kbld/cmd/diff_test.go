@@ -0,0 +1,49 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestNewImageChanges(t *testing.T) {
+	oldImages := map[string]string{
+		"nginx":  "index.docker.io/library/nginx@sha256:aaa",
+		"redis":  "index.docker.io/library/redis@sha256:bbb",
+		"postgr": "index.docker.io/library/postgres@sha256:ccc",
+	}
+	newImages := map[string]string{
+		"nginx": "index.docker.io/library/nginx@sha256:ddd",
+		"redis": "index.docker.io/library/redis@sha256:bbb",
+		"app":   "registry.example.com/app@sha256:eee",
+	}
+
+	changes := ctlcmd.NewImageChanges(oldImages, newImages)
+
+	assert.Equal(t, []ctlcmd.ImageChange{
+		{Key: "app", Type: ctlcmd.ImageChangeAdded, New: "registry.example.com/app@sha256:eee"},
+		{Key: "nginx", Type: ctlcmd.ImageChangeUpdated,
+			Old: "index.docker.io/library/nginx@sha256:aaa", New: "index.docker.io/library/nginx@sha256:ddd"},
+		{Key: "postgr", Type: ctlcmd.ImageChangeRemoved, Old: "index.docker.io/library/postgres@sha256:ccc"},
+	}, changes)
+
+	expectedMarkdown := "| Image | Change | Old | New |\n" +
+		"| --- | --- | --- | --- |\n" +
+		"| app | added |  | `registry.example.com/app@sha256:eee` |\n" +
+		"| nginx | updated | `index.docker.io/library/nginx@sha256:aaa` | `index.docker.io/library/nginx@sha256:ddd` |\n" +
+		"| postgr | removed | `index.docker.io/library/postgres@sha256:ccc` |  |\n"
+
+	assert.Equal(t, expectedMarkdown, ctlcmd.ImageChangesMarkdown(changes))
+}
+
+func TestNewImageChangesNoChanges(t *testing.T) {
+	images := map[string]string{"nginx": "index.docker.io/library/nginx@sha256:aaa"}
+
+	changes := ctlcmd.NewImageChanges(images, images)
+	assert.Empty(t, changes)
+	assert.Equal(t, "No image changes.\n", ctlcmd.ImageChangesMarkdown(changes))
+}
@@ -0,0 +1,389 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	uitable "github.com/cppforlife/go-cli-ui/ui/table"
+	regname "github.com/google/go-containerregistry/pkg/name"
+	"github.com/spf13/cobra"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/version"
+)
+
+const (
+	doctorStatusOK   = "ok"
+	doctorStatusWarn = "warn"
+	doctorStatusFail = "fail"
+	doctorStatusSkip = "skip"
+
+	doctorToolTimeout = 10 * time.Second
+)
+
+type DoctorOptions struct {
+	ui ui.UI
+
+	FileFlags     FileFlags
+	RegistryFlags RegistryFlags
+}
+
+type doctorCheck struct {
+	Name    string
+	Status  string
+	Details string
+}
+
+// doctorTool is an external tool used by builders
+type doctorTool struct {
+	Name    string
+	Command []string
+	// UsedBy returns true when source is built with the tool
+	UsedBy func(ctlconf.Source) bool
+}
+
+var (
+	doctorTools = []doctorTool{
+		{"docker", []string{"docker", "version", "--format", "{{.Client.Version}}"}, func(src ctlconf.Source) bool {
+			return src.Pack != nil || src.Bazel != nil || (src.Docker != nil && src.Docker.Buildx == nil) ||
+				(src.Docker == nil && src.KubectlBuildkit == nil && src.Ko == nil &&
+					src.CloudBuild == nil && src.CodeBuild == nil && src.Depot == nil && src.Archive == nil)
+		}},
+		{"docker buildx", []string{"docker", "buildx", "version"}, func(src ctlconf.Source) bool {
+			return src.Docker != nil && (src.Docker.Buildx != nil || src.Docker.Bake != nil)
+		}},
+		{"pack", []string{"pack", "--version"}, func(src ctlconf.Source) bool { return src.Pack != nil }},
+		{"ko", []string{"ko", "version"}, func(src ctlconf.Source) bool { return src.Ko != nil }},
+		{"bazel", []string{"bazel", "--version"}, func(src ctlconf.Source) bool { return src.Bazel != nil }},
+		{"kubectl buildkit", []string{"kubectl", "buildkit", "--version"}, func(src ctlconf.Source) bool {
+			return src.KubectlBuildkit != nil
+		}},
+		{"gcloud", []string{"gcloud", "--version"}, func(src ctlconf.Source) bool { return src.CloudBuild != nil }},
+		{"aws", []string{"aws", "--version"}, func(src ctlconf.Source) bool { return src.CodeBuild != nil }},
+		{"depot", []string{"depot", "--version"}, func(src ctlconf.Source) bool { return src.Depot != nil }},
+	}
+
+	doctorProxyEnvVars = []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "NO_PROXY", "no_proxy"}
+)
+
+func NewDoctorOptions(ui ui.UI) *DoctorOptions {
+	return &DoctorOptions{ui: ui}
+}
+
+func NewDoctorCmd(o *DoctorOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check build tools, Docker daemon, proxy and TLS settings, and access to registries referenced by configuration",
+		RunE:  func(_ *cobra.Command, _ []string) error { return o.Run() },
+	}
+	o.FileFlags.Set(cmd)
+	o.RegistryFlags.Set(cmd)
+	return cmd
+}
+
+func (o *DoctorOptions) Run() error {
+	_, conf, err := o.FileFlags.ResourcesAndConfig()
+	if err != nil {
+		return err
+	}
+
+	checks := []doctorCheck{{Name: "kbld", Status: doctorStatusOK, Details: "version " + version.Version}}
+	checks = append(checks, o.toolChecks(conf)...)
+	checks = append(checks, o.networkChecks(conf)...)
+
+	registryChecks, err := o.registryChecks(conf)
+	if err != nil {
+		return err
+	}
+	checks = append(checks, registryChecks...)
+
+	table := uitable.Table{
+		Title:   "Diagnostics",
+		Content: "checks",
+
+		Header: []uitable.Header{
+			uitable.NewHeader("Check"),
+			uitable.NewHeader("Status"),
+			uitable.NewHeader("Details"),
+		},
+	}
+
+	var failed int
+
+	for _, check := range checks {
+		if check.Status == doctorStatusFail {
+			failed++
+		}
+		table.Rows = append(table.Rows, []uitable.Value{
+			uitable.NewValueString(check.Name),
+			uitable.NewValueFmt(uitable.NewValueString(check.Status), check.Status == doctorStatusFail),
+			uitable.NewValueString(check.Details),
+		})
+	}
+
+	o.ui.PrintTable(table)
+
+	if failed > 0 {
+		return fmt.Errorf("Expected all %d checks to pass, but %d failed", len(checks), failed)
+	}
+
+	return nil
+}
+
+// toolChecks reports versions of builder tools. Missing tools
+// only fail the check when they are used by configured sources.
+func (o *DoctorOptions) toolChecks(conf ctlconf.Conf) []doctorCheck {
+	var checks []doctorCheck
+
+	for _, tool := range doctorTools {
+		var used bool
+		for _, src := range conf.Sources() {
+			// Tools of autodetected builders are checked when detection succeeds
+			// (e.g. path may refer to regexp groups of image pattern)
+			if detectedSrc, err := ctlimg.AutodetectBuilder(src); err == nil {
+				src = detectedSrc
+			}
+			used = used || tool.UsedBy(src)
+		}
+		// aws CLI is also used to ensure that ECR repositories exist
+		if tool.Name == "aws" {
+			for _, dst := range conf.ImageDestinations() {
+				used = used || dst.ECR != nil
+			}
+		}
+
+		failedStatus := doctorStatusSkip
+		if used {
+			failedStatus = doctorStatusFail
+		}
+
+		if _, err := exec.LookPath(tool.Command[0]); err != nil {
+			checks = append(checks, doctorCheck{tool.Name, failedStatus, fmt.Sprintf("'%s' not found in PATH", tool.Command[0])})
+			continue
+		}
+
+		output, err := doctorRunTool(tool.Command)
+		if err != nil {
+			checks = append(checks, doctorCheck{tool.Name, failedStatus, err.Error()})
+			continue
+		}
+
+		checks = append(checks, doctorCheck{tool.Name, doctorStatusOK, output})
+
+		if tool.Name == "docker" {
+			checks = append(checks, o.dockerDaemonCheck(failedStatus))
+		}
+	}
+
+	return checks
+}
+
+func (o *DoctorOptions) dockerDaemonCheck(failedStatus string) doctorCheck {
+	output, err := doctorRunTool([]string{"docker", "info", "--format", "{{.ServerVersion}}"})
+	if err != nil {
+		return doctorCheck{"docker daemon", failedStatus, err.Error()}
+	}
+	return doctorCheck{"docker daemon", doctorStatusOK, "server version " + output}
+}
+
+// networkChecks reports proxy and TLS settings used for registry requests
+func (o *DoctorOptions) networkChecks(conf ctlconf.Conf) []doctorCheck {
+	var checks []doctorCheck
+
+	var proxyVars []string
+	for _, envVar := range doctorProxyEnvVars {
+		if val := os.Getenv(envVar); len(val) > 0 {
+			proxyVars = append(proxyVars, envVar+"="+doctorRedactedURL(val))
+		}
+	}
+	if len(proxyVars) == 0 {
+		proxyVars = append(proxyVars, "no proxy env variables set")
+	}
+	checks = append(checks, doctorCheck{"proxy env", doctorStatusOK, strings.Join(proxyVars, ", ")})
+
+	for _, proxy := range conf.RegistryProxies() {
+		details := "direct connection"
+		status := doctorStatusOK
+
+		proxyURL, err := proxy.ProxyURL()
+		switch {
+		case err != nil:
+			details = err.Error()
+			status = doctorStatusFail
+		case proxyURL != nil:
+			details = "via " + proxyURL.Redacted()
+		}
+		checks = append(checks, doctorCheck{"registry proxy " + proxy.Registry, status, details})
+	}
+
+	for _, path := range o.RegistryFlags.CACertPaths {
+		if _, err := os.ReadFile(path); err != nil {
+			checks = append(checks, doctorCheck{"CA certificates", doctorStatusFail, err.Error()})
+		} else {
+			checks = append(checks, doctorCheck{"CA certificates", doctorStatusOK, path})
+		}
+	}
+
+	if !o.RegistryFlags.VerifyCerts {
+		checks = append(checks, doctorCheck{"TLS verification", doctorStatusWarn, "certificate verification is disabled"})
+	}
+	if o.RegistryFlags.Insecure {
+		checks = append(checks, doctorCheck{"TLS", doctorStatusWarn, "plain HTTP is allowed for registries"})
+	}
+
+	return checks
+}
+
+// registryChecks verifies that repositories of overrides can be read
+// and that destinations can be pushed to (using configured credentials)
+func (o *DoctorOptions) registryChecks(conf ctlconf.Conf) ([]doctorCheck, error) {
+	registryOpts, err := o.RegistryFlags.AsRegistryOpts()
+	if err != nil {
+		return nil, err
+	}
+
+	registryOpts.Proxies, err = registryProxyRules(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	registry, err := ctlreg.NewRegistry(registryOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	factory := ctlimg.NewFactory(ctlimg.FactoryOpts{Conf: conf}, registry, ctllog.NewLogger(io.Discard))
+
+	var checks []doctorCheck
+	seen := map[string]struct{}{}
+
+	check := func(name, url string, newRegistry func() (ctlreg.Registry, error),
+		checkFunc func(ctlreg.Registry, regname.Repository) (string, error)) {
+
+		ref, err := regname.ParseReference(url, regname.WeakValidation)
+		if err != nil {
+			return
+		}
+
+		name = fmt.Sprintf("%s %s", name, ref.Context().Name())
+		if _, found := seen[name]; found {
+			return
+		}
+		seen[name] = struct{}{}
+
+		repoRegistry, err := newRegistry()
+		if err != nil {
+			checks = append(checks, doctorCheck{name, doctorStatusFail, err.Error()})
+			return
+		}
+
+		auth := "anonymous"
+		if hasCreds, err := repoRegistry.HasCredentials(ref.Context()); err != nil {
+			checks = append(checks, doctorCheck{name, doctorStatusFail, fmt.Sprintf("Resolving credentials: %s", err)})
+			return
+		} else if hasCreds {
+			auth = "with credentials"
+		}
+
+		details, err := checkFunc(repoRegistry, ref.Context())
+		if err != nil {
+			checks = append(checks, doctorCheck{name, doctorStatusFail, fmt.Sprintf("%s (%s)", err, auth)})
+			return
+		}
+		checks = append(checks, doctorCheck{name, doctorStatusOK, fmt.Sprintf("%s (%s)", details, auth)})
+	}
+
+	pullCheck := func(registry ctlreg.Registry, repo regname.Repository) (string, error) {
+		_, err := registry.ListTags(repo)
+		if err != nil {
+			if ctlreg.IsNotFoundErr(err) {
+				return "repository does not exist", nil
+			}
+			return "", err
+		}
+		return "pull allowed", nil
+	}
+
+	pushCheck := func(registry ctlreg.Registry, repo regname.Repository) (string, error) {
+		err := registry.CheckPushAccess(repo)
+		if err != nil {
+			return "", err
+		}
+		return "push allowed", nil
+	}
+
+	for _, override := range conf.ImageOverrides() {
+		if len(override.NewImage) > 0 {
+			check("pull", override.NewImage, func() (ctlreg.Registry, error) {
+				return factory.SourceRegistry(override.NewImage)
+			}, pullCheck)
+		}
+	}
+
+	for _, cred := range conf.Credentials() {
+		credURL := cred.Image
+		if len(credURL) == 0 {
+			credURL = cred.ImageRepo
+		}
+		if len(credURL) > 0 {
+			check("pull", credURL, func() (ctlreg.Registry, error) {
+				return factory.SourceRegistry(credURL)
+			}, pullCheck)
+		}
+	}
+
+	for _, dst := range conf.ImageDestinations() {
+		dst := dst // copy
+		for _, newImage := range append([]string{dst.NewImage}, dst.NewImages...) {
+			if len(newImage) > 0 {
+				check("push", newImage, func() (ctlreg.Registry, error) {
+					return factory.DestinationRegistry(dst)
+				}, pushCheck)
+			}
+		}
+	}
+
+	return checks, nil
+}
+
+// doctorRunTool returns first line of tool's output
+func doctorRunTool(command []string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), doctorToolTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, command[0], command[1:]...).CombinedOutput()
+	firstLine := strings.TrimSpace(strings.SplitN(strings.TrimSpace(string(output)), "\n", 2)[0])
+
+	if err != nil {
+		if len(firstLine) > 0 {
+			return "", fmt.Errorf("Running '%s': %s: %s", strings.Join(command, " "), err, firstLine)
+		}
+		return "", fmt.Errorf("Running '%s': %s", strings.Join(command, " "), err)
+	}
+
+	return firstLine, nil
+}
+
+// doctorRedactedURL hides password in proxy URLs
+func doctorRedactedURL(val string) string {
+	var redacted []string
+	for _, part := range strings.Split(val, ",") {
+		if parsedURL, err := url.Parse(part); err == nil && parsedURL.User != nil {
+			part = parsedURL.Redacted()
+		}
+		redacted = append(redacted, part)
+	}
+	return strings.Join(redacted, ",")
+}
@@ -0,0 +1,276 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	regremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRegistry implements subset of distribution API
+// that is used when pushing, pulling, listing and deleting images
+type fakeRegistry struct {
+	Host string
+
+	blobs     map[string][]byte
+	manifests map[string]fakeManifest
+	// tags map repo:tag to digest
+	tags    map[string]string
+	uploads map[string][]byte
+	// requests are method and path of each served request
+	requests []string
+	lock     sync.Mutex
+}
+
+type fakeManifest struct {
+	MediaType string
+	Bytes     []byte
+}
+
+func newFakeRegistry(t *testing.T) *fakeRegistry {
+	reg := &fakeRegistry{
+		blobs:     map[string][]byte{},
+		manifests: map[string]fakeManifest{},
+		tags:      map[string]string{},
+		uploads:   map[string][]byte{},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(reg.serve))
+	t.Cleanup(server.Close)
+
+	reg.Host = strings.TrimPrefix(server.URL, "http://")
+
+	return reg
+}
+
+// PushImage pushes small unique image and returns its digest reference
+func (r *fakeRegistry) PushImage(t *testing.T, ref string) string {
+	img, err := mutate.ConfigFile(empty.Image, &regv1.ConfigFile{
+		Architecture: "amd64",
+		OS:           "linux",
+		Config:       regv1.Config{Labels: map[string]string{"ref": ref}},
+	})
+	require.NoError(t, err)
+
+	parsedRef, err := regname.ParseReference(ref, regname.Insecure)
+	require.NoError(t, err)
+
+	require.NoError(t, regremote.Write(parsedRef, img))
+
+	digest, err := img.Digest()
+	require.NoError(t, err)
+
+	return parsedRef.Context().Name() + "@" + digest.String()
+}
+
+// Digests returns manifest digests stored in repository
+func (r *fakeRegistry) Digests(repo string) []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var result []string
+	for key := range r.manifests {
+		if strings.HasPrefix(key, repo+"@") {
+			result = append(result, strings.TrimPrefix(key, repo+"@"))
+		}
+	}
+	return result
+}
+
+// Tags returns sorted tags of repository
+func (r *fakeRegistry) Tags(repo string) []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.repoTags(repo)
+}
+
+// Requests returns method and path of requests served so far
+func (r *fakeRegistry) Requests() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return append([]string{}, r.requests...)
+}
+
+// HasBlob returns true when blob was uploaded (to any repository)
+func (r *fakeRegistry) HasBlob(digest string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	_, found := r.blobs[digest]
+	return found
+}
+
+// tagDigest returns digest that tag points at (empty when tag does not exist)
+func (r *fakeRegistry) tagDigest(repo, tag string) string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.tags[repo+":"+tag]
+}
+
+func (r *fakeRegistry) repoTags(repo string) []string {
+	result := []string{}
+	for key := range r.tags {
+		if strings.HasPrefix(key, repo+":") {
+			result = append(result, strings.TrimPrefix(key, repo+":"))
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (r *fakeRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	r.requests = append(r.requests, req.Method+" "+req.URL.Path)
+
+	switch {
+	case path == "" || path == "/":
+		w.WriteHeader(http.StatusOK)
+
+	case strings.Contains(path, "/blobs/uploads/"):
+		repo, id, _ := strings.Cut(path, "/blobs/uploads/")
+		r.serveUpload(w, req, repo, id)
+
+	case strings.Contains(path, "/blobs/"):
+		_, digest, _ := strings.Cut(path, "/blobs/")
+		blob, found := r.blobs[digest]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(blob)))
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.WriteHeader(http.StatusOK)
+		if req.Method == http.MethodGet {
+			w.Write(blob)
+		}
+
+	case strings.Contains(path, "/manifests/"):
+		repo, ref, _ := strings.Cut(path, "/manifests/")
+		r.serveManifest(w, req, repo, ref)
+
+	case strings.HasSuffix(path, "/tags/list"):
+		repo := strings.TrimSuffix(path, "/tags/list")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"name": repo, "tags": r.repoTags(repo)})
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (r *fakeRegistry) serveUpload(w http.ResponseWriter, req *http.Request, repo, id string) {
+	switch req.Method {
+	case http.MethodPost:
+		if mount := req.URL.Query().Get("mount"); len(mount) > 0 {
+			if _, found := r.blobs[mount]; found {
+				w.Header().Set("Location", "/v2/"+repo+"/blobs/"+mount)
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+		}
+		id = fmt.Sprintf("%d", len(r.uploads)+1)
+		r.uploads[id] = nil
+		w.Header().Set("Location", "/v2/"+repo+"/blobs/uploads/"+id)
+		w.WriteHeader(http.StatusAccepted)
+
+	case http.MethodPatch, http.MethodPut:
+		body, _ := io.ReadAll(req.Body)
+		r.uploads[id] = append(r.uploads[id], body...)
+
+		if req.Method == http.MethodPatch {
+			w.Header().Set("Location", "/v2/"+repo+"/blobs/uploads/"+id)
+			w.Header().Set("Range", fmt.Sprintf("0-%d", len(r.uploads[id])-1))
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		digest := req.URL.Query().Get("digest")
+		r.blobs[digest] = r.uploads[id]
+		delete(r.uploads, id)
+		w.Header().Set("Location", "/v2/"+repo+"/blobs/"+digest)
+		w.WriteHeader(http.StatusCreated)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (r *fakeRegistry) serveManifest(w http.ResponseWriter, req *http.Request, repo, ref string) {
+	digest := ref
+	if !strings.HasPrefix(ref, "sha256:") {
+		digest = r.tags[repo+":"+ref]
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		manifest, found := r.manifests[repo+"@"+digest]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", manifest.MediaType)
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(manifest.Bytes)))
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.WriteHeader(http.StatusOK)
+		if req.Method == http.MethodGet {
+			w.Write(manifest.Bytes)
+		}
+
+	case http.MethodPut:
+		body, _ := io.ReadAll(req.Body)
+		sum := sha256.Sum256(body)
+		digest = "sha256:" + hex.EncodeToString(sum[:])
+
+		r.manifests[repo+"@"+digest] = fakeManifest{MediaType: req.Header.Get("Content-Type"), Bytes: body}
+		if !strings.HasPrefix(ref, "sha256:") {
+			r.tags[repo+":"+ref] = digest
+		}
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.WriteHeader(http.StatusCreated)
+
+	case http.MethodDelete:
+		if _, found := r.manifests[repo+"@"+digest]; !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// Deleting by tag only removes tag (as allowed by OCI distribution spec)
+		if !strings.HasPrefix(ref, "sha256:") {
+			delete(r.tags, repo+":"+ref)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		delete(r.manifests, repo+"@"+digest)
+		for key, tagDigest := range r.tags {
+			if tagDigest == digest && strings.HasPrefix(key, repo+":") {
+				delete(r.tags, key)
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
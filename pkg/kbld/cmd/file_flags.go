@@ -21,34 +21,51 @@ func (s *FileFlags) Set(cmd *cobra.Command) {
 }
 
 func (s *FileFlags) AllResources() ([]ctlres.Resource, error) {
+	rs, _, err := s.AllResourcesWithPaths()
+	return rs, err
+}
+
+// AllResourcesWithPaths additionally returns relative file path each resource came from
+func (s *FileFlags) AllResourcesWithPaths() ([]ctlres.Resource, map[ctlres.Resource]string, error) {
 	var rs []ctlres.Resource
+	paths := map[ctlres.Resource]string{}
 
 	// TODO do anything with kbld configs?
 	for _, file := range s.Files {
 		fileRs, err := ctlres.NewFileResources(file)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		for _, fileRes := range fileRs {
 			resources, err := fileRes.Resources()
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 
 			for _, res := range resources {
 				rs = append(rs, res)
+				paths[res] = fileRes.RelativePath()
 			}
 		}
 	}
 
-	return rs, nil
+	return rs, paths, nil
 }
 
 func (s *FileFlags) ResourcesAndConfig() ([]ctlres.Resource, ctlconf.Conf, error) {
-	allRs, err := s.AllResources()
+	rs, conf, _, err := s.ResourcesAndConfigWithPaths()
+	return rs, conf, err
+}
+
+func (s *FileFlags) ResourcesAndConfigWithPaths() ([]ctlres.Resource, ctlconf.Conf, map[ctlres.Resource]string, error) {
+	allRs, paths, err := s.AllResourcesWithPaths()
+	if err != nil {
+		return nil, ctlconf.Conf{}, nil, err
+	}
+	rs, conf, err := ctlconf.NewConfFromResources(allRs)
 	if err != nil {
-		return nil, ctlconf.Conf{}, err
+		return nil, ctlconf.Conf{}, nil, err
 	}
-	return ctlconf.NewConfFromResources(allRs)
+	return rs, conf, paths, nil
 }
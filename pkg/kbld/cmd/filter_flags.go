@@ -0,0 +1,77 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// FilterFlags select resources that are processed
+// (other resources are output without changes)
+type FilterFlags struct {
+	Kinds      []string
+	Namespaces []string
+	Labels     string
+}
+
+func (s *FilterFlags) Set(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&s.Kinds, "filter-kind", nil, "Only process resources of given kind (can be specified multiple times)")
+	cmd.Flags().StringSliceVar(&s.Namespaces, "filter-namespace", nil, "Only process resources in given namespace (can be specified multiple times)")
+	cmd.Flags().StringVar(&s.Labels, "filter-labels", "", "Only process resources matching label selector (e.g. 'app=web,tier!=db')")
+}
+
+func (s *FilterFlags) ResourceFilter() (ResourceFilter, error) {
+	filter := ResourceFilter{kinds: s.Kinds, namespaces: s.Namespaces}
+
+	if len(s.Labels) > 0 {
+		selector, err := labels.Parse(s.Labels)
+		if err != nil {
+			return ResourceFilter{}, fmt.Errorf("Parsing '--filter-labels': %s", err)
+		}
+		filter.labels = selector
+	}
+
+	return filter, nil
+}
+
+// ResourceFilter matches all resources when no criteria are specified
+type ResourceFilter struct {
+	kinds      []string
+	namespaces []string
+	labels     labels.Selector
+}
+
+func (f ResourceFilter) Matches(res ctlres.Resource) bool {
+	if len(f.kinds) > 0 {
+		var found bool
+		for _, kind := range f.kinds {
+			// Kinds are matched case insensitively similar to kubectl
+			found = found || strings.EqualFold(kind, res.Kind())
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(f.namespaces) > 0 {
+		var found bool
+		for _, ns := range f.namespaces {
+			found = found || ns == res.Namespace()
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if f.labels != nil && !f.labels.Matches(labels.Set(res.Labels())) {
+		return false
+	}
+
+	return true
+}
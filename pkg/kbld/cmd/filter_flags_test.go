@@ -0,0 +1,115 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestResolveFilterFlags(t *testing.T) {
+	digestRef := fmt.Sprintf("registry.example.com/app@sha256:%064d", 1)
+
+	input := fmt.Sprintf(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: apps
+  labels:
+    app: web
+spec:
+  template:
+    spec:
+      containers:
+      - image: app
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: db
+  namespace: apps
+  labels:
+    app: db
+spec:
+  template:
+    spec:
+      containers:
+      - image: app
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+  namespace: apps
+  labels:
+    app: web
+spec:
+  containers:
+  - image: app
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+overrides:
+- image: app
+  newImage: %s
+  preresolved: true
+`, digestRef)
+
+	inputPath := filepath.Join(t.TempDir(), "input.yml")
+	require.NoError(t, os.WriteFile(inputPath, []byte(input), 0600))
+
+	resolve := func(t *testing.T, args ...string) ([]string, error) {
+		var stdout bytes.Buffer
+
+		cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+		cmd.SetArgs(append([]string{"-f", inputPath, "--progress=plain", "--sort=false"}, args...))
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+
+		err := cmd.Execute()
+
+		return strings.Split(strings.TrimPrefix(stdout.String(), "---\n"), "---\n"), err
+	}
+
+	t.Run("processes only matching resources", func(t *testing.T) {
+		docs, err := resolve(t, "--filter-kind=deployment", "--filter-namespace=apps", "--filter-labels=app in (web)")
+		require.NoError(t, err)
+		require.Len(t, docs, 3)
+
+		assert.Contains(t, docs[0], "image: "+digestRef)
+		assert.Contains(t, docs[0], "kbld.k14s.io/images")
+
+		for _, doc := range docs[1:] {
+			assert.Contains(t, doc, "image: app\n")
+			assert.NotContains(t, doc, "kbld.k14s.io/images")
+		}
+	})
+
+	t.Run("processes all resources without filters", func(t *testing.T) {
+		docs, err := resolve(t)
+		require.NoError(t, err)
+		require.Len(t, docs, 3)
+
+		for _, doc := range docs {
+			assert.Contains(t, doc, "image: "+digestRef)
+		}
+	})
+
+	t.Run("rejects invalid label selector", func(t *testing.T) {
+		_, err := resolve(t, "--filter-labels=app in web")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Parsing '--filter-labels': ")
+	})
+}
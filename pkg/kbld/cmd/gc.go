@@ -0,0 +1,399 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	uitable "github.com/cppforlife/go-cli-ui/ui/table"
+	regname "github.com/google/go-containerregistry/pkg/name"
+	"github.com/spf13/cobra"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+type GCOptions struct {
+	ui ui.UI
+
+	RegistryFlags RegistryFlags
+
+	Manifests []string
+	KeepLocks []string
+	DryRun    bool
+}
+
+func NewGCOptions(ui ui.UI) *GCOptions {
+	return &GCOptions{ui: ui}
+}
+
+func NewGCCmd(o *GCOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Delete pushed images that are not referenced by retained lock files",
+		Long: `
+Delete images recorded in GC manifests (emitted via '--gc-manifest-output'
+by resolve and relocate) unless they are referenced by one of retained lock files
+(kbld or imgpkg lock files). Only images pushed by kbld are ever deleted;
+for images that were only tagged by kbld (e.g. retagged existing images)
+only tags that still point to them are deleted.
+`,
+		RunE: func(_ *cobra.Command, _ []string) error { return o.Run() },
+	}
+	o.RegistryFlags.Set(cmd)
+	cmd.Flags().StringSliceVarP(&o.Manifests, "manifest", "m", nil, "Set GC manifest file (can be specified multiple times)")
+	cmd.Flags().StringSliceVar(&o.KeepLocks, "keep-locks", nil, "Set lock files whose images are retained (format: lock1.yml,lock2.yml)")
+	cmd.Flags().BoolVar(&o.DryRun, "dry-run", false, "Print which images would be deleted without deleting them")
+	return cmd
+}
+
+func (o *GCOptions) Run() error {
+	if len(o.Manifests) == 0 {
+		return util.NewCategorizedError(util.ErrorCategoryConfig, fmt.Errorf("Expected at least one GC manifest"))
+	}
+	// Without lock files everything would be deleted, which is unlikely to be intended
+	if len(o.KeepLocks) == 0 {
+		return util.NewCategorizedError(util.ErrorCategoryConfig, fmt.Errorf("Expected at least one lock file to keep"))
+	}
+
+	pushedImages, taggedImages, err := o.manifestImages()
+	if err != nil {
+		return util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+
+	retained, err := o.retainedDigests()
+	if err != nil {
+		return util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+
+	registryOpts, err := o.RegistryFlags.AsRegistryOpts()
+	if err != nil {
+		return err
+	}
+
+	registry, err := ctlreg.NewRegistry(registryOpts)
+	if err != nil {
+		return err
+	}
+
+	logger := ctllog.NewLogger(os.Stderr)
+	prefixedLogger := logger.NewPrefixedWriter("gc | ")
+
+	allImages := append(append([]ctlreg.PushedImage{}, pushedImages...), taggedImages...)
+
+	err = retained.AddIndexChildren(allImages, registry, prefixedLogger)
+	if err != nil {
+		return err
+	}
+
+	table := uitable.Table{
+		Title:   "Images",
+		Content: "images",
+
+		Header: []uitable.Header{
+			uitable.NewHeader("Image"),
+			uitable.NewHeader("Tags"),
+			uitable.NewHeader("Action"),
+		},
+
+		SortBy: []uitable.ColumnSort{{Column: 0, Asc: true}},
+
+		// Image URLs and other content is too long
+		FillFirstColumn: true,
+		Transpose:       true,
+	}
+
+	var deleted, failed int
+
+	for _, img := range pushedImages {
+		action := "kept"
+		var deleteErr error
+
+		switch {
+		case retained.Retains(img):
+		case o.DryRun:
+			action = "would delete"
+		default:
+			deleteErr = o.delete(img, registry)
+			if deleteErr != nil {
+				action = deleteErr.Error()
+				failed++
+			} else {
+				action = "deleted"
+				deleted++
+			}
+		}
+
+		table.Rows = append(table.Rows, []uitable.Value{
+			uitable.NewValueString(img.URL()),
+			uitable.NewValueStrings(img.Tags),
+			uitable.NewValueFmt(uitable.NewValueString(action), deleteErr != nil),
+		})
+	}
+
+	for _, img := range taggedImages {
+		action := "kept"
+		var deleteErr error
+
+		switch {
+		case retained.Retains(img):
+		case o.DryRun:
+			action = "would delete tags"
+		default:
+			deleteErr = o.deleteTags(img, registry)
+			if deleteErr != nil {
+				action = deleteErr.Error()
+				failed++
+			} else {
+				action = "deleted tags"
+				deleted++
+			}
+		}
+
+		table.Rows = append(table.Rows, []uitable.Value{
+			uitable.NewValueString(img.URL()),
+			uitable.NewValueStrings(img.Tags),
+			uitable.NewValueFmt(uitable.NewValueString(action), deleteErr != nil),
+		})
+	}
+
+	o.ui.PrintTable(table)
+
+	if failed > 0 {
+		return fmt.Errorf("Expected all %d images to be deleted, but %d failed", deleted+failed, failed)
+	}
+
+	return nil
+}
+
+func (o *GCOptions) delete(img ctlreg.PushedImage, registry ctlreg.Registry) error {
+	digestRef, err := regname.NewDigest(img.URL(), regname.WeakValidation)
+	if err != nil {
+		return err
+	}
+	return registry.DeleteImage(digestRef)
+}
+
+// deleteTags deletes tags that still point to image
+// (tags that were since moved to other images or deleted are skipped)
+func (o *GCOptions) deleteTags(img ctlreg.PushedImage, registry ctlreg.Registry) error {
+	for _, tag := range img.Tags {
+		tagRef, err := regname.NewTag(img.Repository+":"+tag, regname.WeakValidation)
+		if err != nil {
+			return err
+		}
+
+		desc, err := registry.Generic(tagRef)
+		if err != nil {
+			if ctlreg.IsNotFoundErr(err) {
+				continue
+			}
+			return fmt.Errorf("Getting image tag '%s': %s", tagRef.Name(), err)
+		}
+
+		if desc.Digest.String() != img.Digest {
+			continue
+		}
+
+		err = registry.DeleteTag(tagRef)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// manifestImages returns pushed and only tagged images recorded in GC manifests
+// sorted by repository and digest. Images recorded in several manifests are merged
+// (image that was pushed by any run is not considered to be only tagged).
+func (o *GCOptions) manifestImages() ([]ctlreg.PushedImage, []ctlreg.PushedImage, error) {
+	pushed := map[string]ctlreg.PushedImage{}
+	tagged := map[string]ctlreg.PushedImage{}
+
+	for _, path := range o.Manifests {
+		manifest, err := NewGCManifestFromFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		err = mergeManifestImages(pushed, manifest.Pushed, path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		err = mergeManifestImages(tagged, manifest.Tagged, path)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for url, img := range tagged {
+		if pushedImg, found := pushed[url]; found {
+			pushedImg.Tags = uniqueStrs(append(pushedImg.Tags, img.Tags...))
+			pushed[url] = pushedImg
+			delete(tagged, url)
+		}
+	}
+
+	return sortedPushedImages(pushed), sortedPushedImages(tagged), nil
+}
+
+func mergeManifestImages(imgsByURL map[string]ctlreg.PushedImage, imgs []ctlreg.PushedImage, path string) error {
+	for _, img := range imgs {
+		digestRef, err := regname.NewDigest(img.URL(), regname.WeakValidation)
+		if err != nil {
+			return fmt.Errorf("Parsing pushed image '%s' in GC manifest '%s': %s", img.URL(), path, err)
+		}
+
+		img.Repository = digestRef.Context().Name()
+
+		if prevImg, found := imgsByURL[img.URL()]; found {
+			img.Tags = uniqueStrs(append(prevImg.Tags, img.Tags...))
+		}
+		imgsByURL[img.URL()] = img
+	}
+	return nil
+}
+
+func sortedPushedImages(imgs map[string]ctlreg.PushedImage) []ctlreg.PushedImage {
+	var result []ctlreg.PushedImage
+	for _, img := range imgs {
+		result = append(result, img)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].URL() < result[j].URL() })
+
+	return result
+}
+
+// retainedDigests returns digest references found in lock files
+func (o *GCOptions) retainedDigests() (GCRetainedDigests, error) {
+	fileFlags := FileFlags{Files: o.KeepLocks}
+
+	_, conf, err := fileFlags.ResourcesAndConfig()
+	if err != nil {
+		return nil, fmt.Errorf("Reading lock files: %s", err)
+	}
+
+	retained := GCRetainedDigests{}
+
+	for _, override := range conf.ImageOverrides() {
+		// Only digest references are immutable, hence tags (e.g. of unresolved overrides) are not retained
+		digestRef, err := regname.NewDigest(override.NewImage, regname.WeakValidation)
+		if err == nil {
+			retained.Add(digestRef.Context().Name(), digestRef.DigestStr())
+		}
+	}
+
+	if len(retained) == 0 {
+		return nil, fmt.Errorf("Expected lock files '%s' to reference at least one image by digest",
+			strings.Join(o.KeepLocks, "', '"))
+	}
+
+	return retained, nil
+}
+
+// GCRetainedDigests maps repositories to digests that are not deleted
+type GCRetainedDigests map[string]map[string]struct{}
+
+func (d GCRetainedDigests) Add(repo, digest string) {
+	if d[repo] == nil {
+		d[repo] = map[string]struct{}{}
+	}
+	d[repo][digest] = struct{}{}
+}
+
+// Retains returns true when pushed image is referenced by a lock file
+// or is a signature, attestation or SBOM (e.g. sha256-<hex>.sig tag) of such image
+func (d GCRetainedDigests) Retains(img ctlreg.PushedImage) bool {
+	digests := d[img.Repository]
+
+	if _, found := digests[img.Digest]; found {
+		return true
+	}
+
+	for _, tag := range img.Tags {
+		for digest := range digests {
+			if strings.HasPrefix(tag, strings.Replace(digest, ":", "-", 1)+".") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// AddIndexChildren retains manifests of retained image indexes
+// in repositories that have pushed images that could be deleted
+func (d GCRetainedDigests) AddIndexChildren(pushedImages []ctlreg.PushedImage,
+	registry ctlreg.Registry, logger *ctllog.PrefixWriter) error {
+
+	repos := map[string]struct{}{}
+	for _, img := range pushedImages {
+		if !d.Retains(img) {
+			repos[img.Repository] = struct{}{}
+		}
+	}
+
+	for repo := range repos {
+		var digests []string
+		for digest := range d[repo] {
+			digests = append(digests, digest)
+		}
+		sort.Strings(digests)
+
+		for _, digest := range digests {
+			ref, err := regname.NewDigest(repo+"@"+digest, regname.WeakValidation)
+			if err != nil {
+				return err
+			}
+
+			desc, err := registry.Generic(ref)
+			if err != nil {
+				if ctlreg.IsNotFoundErr(err) {
+					logger.WriteStr("retained image '%s' was not found\n", ref.Name())
+					continue
+				}
+				return fmt.Errorf("Getting retained image '%s': %s", ref.Name(), err)
+			}
+
+			if !desc.MediaType.IsIndex() {
+				continue
+			}
+
+			idx, err := registry.Index(ref)
+			if err != nil {
+				return fmt.Errorf("Getting retained image index '%s': %s", ref.Name(), err)
+			}
+
+			idxManifest, err := idx.IndexManifest()
+			if err != nil {
+				return fmt.Errorf("Getting retained image index manifest '%s': %s", ref.Name(), err)
+			}
+
+			for _, childDesc := range idxManifest.Manifests {
+				d.Add(repo, childDesc.Digest.String())
+			}
+		}
+	}
+
+	return nil
+}
+
+func uniqueStrs(strs []string) []string {
+	seen := map[string]struct{}{}
+	var result []string
+	for _, str := range strs {
+		if _, found := seen[str]; !found {
+			seen[str] = struct{}{}
+			result = append(result, str)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+// GCManifest lists images and tags pushed by a run (emitted alongside the lock)
+// so that 'kbld gc' could delete ones not referenced by retained lock files
+type GCManifest struct {
+	Pushed []ctlreg.PushedImage `json:"pushed"`
+	// Tagged are images that were only tagged by a run (e.g. retagged existing images),
+	// hence only their tags and never their manifests are deleted
+	Tagged []ctlreg.PushedImage `json:"tagged,omitempty"`
+}
+
+func NewGCManifestFromFile(path string) (GCManifest, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return GCManifest{}, fmt.Errorf("Reading GC manifest '%s': %s", path, err)
+	}
+
+	var manifest GCManifest
+
+	err = json.Unmarshal(bs, &manifest)
+	if err != nil {
+		return GCManifest{}, fmt.Errorf("Unmarshaling GC manifest '%s': %s", path, err)
+	}
+
+	return manifest, nil
+}
+
+// WriteGCManifest writes everything recorded by push manifest
+// (written even when run fails since pushed content may need to be collected)
+func WriteGCManifest(path string, pushManifest *ctlreg.PushManifest) error {
+	manifest := GCManifest{Pushed: pushManifest.All(), Tagged: pushManifest.Tagged()}
+	if manifest.Pushed == nil {
+		manifest.Pushed = []ctlreg.PushedImage{}
+	}
+
+	bs, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(path, append(bs, '\n'), 0600)
+	if err != nil {
+		return fmt.Errorf("Writing GC manifest '%s': %s", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	regname "github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+func TestGCDeletesPushedImagesNotReferencedByRetainedLocks(t *testing.T) {
+	reg := newFakeRegistry(t)
+	dir := t.TempDir()
+
+	resolve := func(t *testing.T, name string) (string, string, string) {
+		archivePath, digest := writeOCIArchive(t, name)
+
+		inputPath := filepath.Join(t.TempDir(), "input.yml")
+		require.NoError(t, os.WriteFile(inputPath, []byte(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: app
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+sources:
+- image: app
+  path: `+filepath.Dir(archivePath)+`
+  archive:
+    file: `+filepath.Base(archivePath)+`
+destinations:
+- image: app
+  newImage: `+reg.Host+`/app
+`), 0600))
+
+		lockPath := filepath.Join(dir, name+"-lock.yml")
+		manifestPath := filepath.Join(dir, name+"-gc.json")
+
+		cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(io.Discard, io.Discard, ui.NewNoopLogger())))
+		cmd.SetArgs([]string{"-f", inputPath, "--registry-insecure", "--progress=plain",
+			"--imgpkg-lock-output", lockPath, "--gc-manifest-output", manifestPath})
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+
+		require.NoError(t, cmd.Execute())
+
+		return digest, lockPath, manifestPath
+	}
+
+	gc := func(t *testing.T, args ...string) (string, error) {
+		var stdout bytes.Buffer
+
+		cmd := ctlcmd.NewGCCmd(ctlcmd.NewGCOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+		cmd.SetArgs(append([]string{"--registry-insecure"}, args...))
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+
+		err := cmd.Execute()
+
+		return stdout.String(), err
+	}
+
+	oldDigest, oldLock, oldManifest := resolve(t, "old")
+	newDigest, newLock, newManifest := resolve(t, "new")
+
+	manifest, err := ctlcmd.NewGCManifestFromFile(newManifest)
+	require.NoError(t, err)
+	require.Len(t, manifest.Pushed, 1)
+	assert.Equal(t, reg.Host+"/app", manifest.Pushed[0].Repository)
+	assert.Equal(t, newDigest, manifest.Pushed[0].Digest)
+	assert.NotEmpty(t, manifest.Pushed[0].Tags)
+
+	t.Run("lists images to delete in dry run", func(t *testing.T) {
+		out, err := gc(t, "-m", oldManifest, "-m", newManifest, "--keep-locks", newLock, "--dry-run")
+		require.NoError(t, err)
+		assert.Contains(t, out, reg.Host+"/app@"+oldDigest)
+		assert.Contains(t, out, "would delete")
+		assert.ElementsMatch(t, []string{oldDigest, newDigest}, reg.Digests("app"))
+	})
+
+	t.Run("keeps images of all retained locks", func(t *testing.T) {
+		out, err := gc(t, "-m", oldManifest, "-m", newManifest, "--keep-locks", oldLock+","+newLock)
+		require.NoError(t, err)
+		assert.NotContains(t, out, "deleted")
+		assert.ElementsMatch(t, []string{oldDigest, newDigest}, reg.Digests("app"))
+	})
+
+	t.Run("deletes images not referenced by retained locks", func(t *testing.T) {
+		out, err := gc(t, "-m", oldManifest, "-m", newManifest, "--keep-locks", newLock)
+		require.NoError(t, err)
+		assert.Contains(t, out, "deleted")
+		assert.Equal(t, []string{newDigest}, reg.Digests("app"))
+
+		// Already deleted images are not considered failures
+		_, err = gc(t, "-m", oldManifest, "--keep-locks", newLock)
+		require.NoError(t, err)
+	})
+
+	t.Run("deletes only tags of images that were only tagged", func(t *testing.T) {
+		baseURL := reg.PushImage(t, reg.Host+"/app:base")
+		baseDigest := strings.TrimPrefix(baseURL, reg.Host+"/app@")
+
+		pushManifest := ctlreg.NewPushManifest()
+
+		registry, err := ctlreg.NewRegistry(ctlreg.Opts{EnvAuthPrefix: "KBLD_TEST_GC", Insecure: true, PushManifest: pushManifest})
+		require.NoError(t, err)
+
+		baseRef, err := regname.NewDigest(baseURL)
+		require.NoError(t, err)
+
+		for _, tag := range []string{"release", "moved"} {
+			tagRef, err := regname.NewTag(reg.Host + "/app:" + tag)
+			require.NoError(t, err)
+			require.NoError(t, registry.WriteTag(tagRef, baseRef))
+		}
+
+		// Tag moved to another image since is not deleted
+		movedURL := reg.PushImage(t, reg.Host+"/app:moved")
+
+		tagManifest := filepath.Join(dir, "tag-gc.json")
+		require.NoError(t, ctlcmd.WriteGCManifest(tagManifest, pushManifest))
+
+		manifest, err := ctlcmd.NewGCManifestFromFile(tagManifest)
+		require.NoError(t, err)
+		assert.Empty(t, manifest.Pushed)
+		assert.Equal(t, []ctlreg.PushedImage{{Repository: reg.Host + "/app", Digest: baseDigest,
+			Tags: []string{"moved", "release"}}}, manifest.Tagged)
+
+		out, err := gc(t, "-m", tagManifest, "--keep-locks", newLock, "--dry-run")
+		require.NoError(t, err)
+		assert.Contains(t, out, "would delete tags")
+		assert.Equal(t, baseDigest, reg.tagDigest("app", "release"))
+
+		out, err = gc(t, "-m", tagManifest, "--keep-locks", newLock)
+		require.NoError(t, err)
+		assert.Contains(t, out, "deleted tags")
+
+		assert.ElementsMatch(t, []string{newDigest, baseDigest, strings.TrimPrefix(movedURL, reg.Host+"/app@")}, reg.Digests("app"))
+		assert.Equal(t, "", reg.tagDigest("app", "release"))
+		assert.Equal(t, baseDigest, reg.tagDigest("app", "base"))
+		assert.Equal(t, strings.TrimPrefix(movedURL, reg.Host+"/app@"), reg.tagDigest("app", "moved"))
+	})
+
+	t.Run("requires lock files to keep", func(t *testing.T) {
+		_, err := gc(t, "-m", oldManifest)
+		require.Error(t, err)
+		assert.Equal(t, "Expected at least one lock file to keep", err.Error())
+	})
+}
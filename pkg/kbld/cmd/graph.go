@@ -0,0 +1,261 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/spf13/cobra"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+	ctlser "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/search"
+)
+
+type GraphOptions struct {
+	ui ui.UI
+
+	FileFlags FileFlags
+	Output    string
+}
+
+func NewGraphOptions(ui ui.UI) *GraphOptions {
+	return &GraphOptions{ui: ui}
+}
+
+func NewGraphCmd(o *GraphOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Show relationships between resources, images, sources and destinations",
+		RunE:  func(_ *cobra.Command, _ []string) error { return o.Run() },
+	}
+	o.FileFlags.Set(cmd)
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "dot", "Set output format (dot, json)")
+	return cmd
+}
+
+func (o *GraphOptions) Run() error {
+	rs, conf, err := o.FileFlags.ResourcesAndConfig()
+	if err != nil {
+		return err
+	}
+
+	graph, err := NewImageGraph(rs, conf)
+	if err != nil {
+		return err
+	}
+
+	switch o.Output {
+	case "dot":
+		o.ui.PrintBlock([]byte(graph.DOT()))
+	case "json":
+		bs, err := json.MarshalIndent(graph, "", "  ")
+		if err != nil {
+			return err
+		}
+		o.ui.PrintBlock(append(bs, '\n'))
+	default:
+		return fmt.Errorf("Expected output to be one of dot or json, but was '%s'", o.Output)
+	}
+
+	return nil
+}
+
+type GraphNodeType string
+
+const (
+	GraphNodeResource    GraphNodeType = "resource"
+	GraphNodeImage       GraphNodeType = "image"
+	GraphNodeOverride    GraphNodeType = "override"
+	GraphNodeSource      GraphNodeType = "source"
+	GraphNodeDestination GraphNodeType = "destination"
+)
+
+type GraphNode struct {
+	ID    string        `json:"id"`
+	Type  GraphNodeType `json:"type"`
+	Label string        `json:"label"`
+	// Unmatched is set for configured sources, overrides and destinations
+	// that none of the images matched
+	Unmatched bool `json:"unmatched,omitempty"`
+}
+
+type GraphEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Label string `json:"label"`
+}
+
+// ImageGraph describes how images found in resources would be processed
+// according to configuration (without performing builds or registry operations)
+type ImageGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+func NewImageGraph(rs []ctlres.Resource, conf ctlconf.Conf) (ImageGraph, error) {
+	var graph ImageGraph
+
+	nodes := map[string]*GraphNode{}
+	addNode := func(node GraphNode) {
+		if _, found := nodes[node.ID]; !found {
+			nodes[node.ID] = &node
+		}
+	}
+	edges := map[GraphEdge]struct{}{}
+	addEdge := func(edge GraphEdge) {
+		edges[edge] = struct{}{}
+		if node, found := nodes[edge.To]; found {
+			node.Unmatched = false
+		}
+	}
+
+	sources := conf.Sources()
+	for i, src := range sources {
+		label := graphImageRefLabel(src.ImageRef)
+		switch {
+		case len(src.ImageRegexp) > 0:
+			label = src.ImageRegexp + " (regexp)"
+		case len(src.ImageGlob) > 0:
+			label = src.ImageGlob + " (glob)"
+		}
+		if len(src.Path) > 0 {
+			label += " (path: " + src.Path + ")"
+		}
+		addNode(GraphNode{ID: fmt.Sprintf("source:%d", i), Type: GraphNodeSource, Label: label, Unmatched: true})
+	}
+
+	overrides := conf.ImageOverrides()
+	for i, override := range overrides {
+		label := graphImageRefLabel(override.ImageRef)
+		if len(override.NewImage) > 0 {
+			label += " -> " + override.NewImage
+		}
+		addNode(GraphNode{ID: fmt.Sprintf("override:%d", i), Type: GraphNodeOverride, Label: label, Unmatched: true})
+	}
+
+	for _, dst := range conf.ImageDestinations() {
+		for _, newImage := range append([]string{dst.NewImage}, dst.AdditionalNewImages()...) {
+			addNode(GraphNode{ID: "destination:" + newImage, Type: GraphNodeDestination, Label: newImage, Unmatched: true})
+		}
+	}
+
+	// Plans do not require registry access
+	imgFactory := ctlimg.NewFactory(ctlimg.FactoryOpts{Conf: conf, AllowedToBuild: true},
+		ctlreg.Registry{}, ctllog.NewLogger(io.Discard))
+
+	for _, res := range rs {
+		resID := "resource:" + res.Description()
+		addNode(GraphNode{ID: resID, Type: GraphNodeResource, Label: res.Description()})
+
+		var urls []string
+
+		ctlser.NewImageRefs(res.DeepCopyRaw(), conf.SearchRules()).Visit(func(url string) (string, bool) {
+			urls = append(urls, url)
+			return "", false
+		})
+
+		for _, url := range urls {
+			imgID := "image:" + url
+			addNode(GraphNode{ID: imgID, Type: GraphNodeImage, Label: url})
+			addEdge(GraphEdge{From: resID, To: imgID, Label: "references"})
+
+			plan, err := imgFactory.Plan(url)
+			if err != nil {
+				return ImageGraph{}, fmt.Errorf("Planning image '%s': %s", url, err)
+			}
+
+			matchedID := imgID
+			matcher := ctlimg.NewMatcher(url)
+
+			for i, override := range overrides {
+				if matcher.Matches(override.ImageRef) {
+					matchedID = fmt.Sprintf("override:%d", i)
+					addEdge(GraphEdge{From: imgID, To: matchedID, Label: "overriddenBy"})
+					break
+				}
+			}
+
+			if plan.Action == ctlimg.PlanActionBuild {
+				matcher = ctlimg.NewMatcher(plan.Image)
+
+				for i, src := range sources {
+					if matcher.MatchesSource(src) {
+						srcID := fmt.Sprintf("source:%d", i)
+						addEdge(GraphEdge{From: matchedID, To: srcID, Label: "builtFrom"})
+						matchedID = srcID
+						break
+					}
+				}
+			}
+
+			for _, dst := range plan.Destinations {
+				addEdge(GraphEdge{From: matchedID, To: "destination:" + dst, Label: "pushedTo"})
+			}
+		}
+	}
+
+	for _, node := range nodes {
+		graph.Nodes = append(graph.Nodes, *node)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		return graph.Nodes[i].ID < graph.Nodes[j].ID
+	})
+
+	for edge := range edges {
+		graph.Edges = append(graph.Edges, edge)
+	}
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].From != graph.Edges[j].From {
+			return graph.Edges[i].From < graph.Edges[j].From
+		}
+		return graph.Edges[i].To < graph.Edges[j].To
+	})
+
+	return graph, nil
+}
+
+// DOT returns graph in Graphviz format
+func (g ImageGraph) DOT() string {
+	shapes := map[GraphNodeType]string{
+		GraphNodeResource:    "note",
+		GraphNodeImage:       "box",
+		GraphNodeOverride:    "diamond",
+		GraphNodeSource:      "folder",
+		GraphNodeDestination: "cylinder",
+	}
+
+	var lines []string
+	lines = append(lines, "digraph kbld {", "  rankdir=LR;")
+
+	for _, node := range g.Nodes {
+		attrs := fmt.Sprintf("label=%q shape=%s", node.Label, shapes[node.Type])
+		if node.Unmatched {
+			attrs += " style=dashed"
+		}
+		lines = append(lines, fmt.Sprintf("  %q [%s];", node.ID, attrs))
+	}
+
+	for _, edge := range g.Edges {
+		lines = append(lines, fmt.Sprintf("  %q -> %q [label=%q];", edge.From, edge.To, edge.Label))
+	}
+
+	lines = append(lines, "}")
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func graphImageRefLabel(ref ctlconf.ImageRef) string {
+	if len(ref.Image) > 0 {
+		return ref.Image
+	}
+	return ref.ImageRepo + " (repo)"
+}
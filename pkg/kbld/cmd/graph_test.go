@@ -0,0 +1,81 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+func TestNewImageGraph(t *testing.T) {
+	resourceYAML := `
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+      - image: app
+      - image: nginx
+`
+
+	configYAML := `
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+sources:
+- image: app-src
+  path: src/app
+- image: unused
+  path: src/unused
+overrides:
+- image: app
+  newImage: app-src
+destinations:
+- image: app-src
+  newImage: registry.example.com/app
+`
+
+	rs, err := ctlres.NewResourcesFromBytes([]byte(resourceYAML))
+	require.NoError(t, err)
+
+	configRs, err := ctlres.NewResourcesFromBytes([]byte(configYAML))
+	require.NoError(t, err)
+
+	rs = append(rs, configRs...)
+
+	rs, conf, err := ctlconf.NewConfFromResources(rs)
+	require.NoError(t, err)
+
+	graph, err := ctlcmd.NewImageGraph(rs, conf)
+	require.NoError(t, err)
+
+	resID := "resource:" + rs[0].Description()
+
+	assert.Equal(t, []ctlcmd.GraphEdge{
+		{From: "image:app", To: "override:0", Label: "overriddenBy"},
+		{From: "override:0", To: "source:0", Label: "builtFrom"},
+		{From: resID, To: "image:app", Label: "references"},
+		{From: resID, To: "image:nginx", Label: "references"},
+		{From: "source:0", To: "destination:registry.example.com/app", Label: "pushedTo"},
+	}, graph.Edges)
+
+	unmatched := map[string]bool{}
+	for _, node := range graph.Nodes {
+		unmatched[node.ID] = node.Unmatched
+	}
+
+	assert.False(t, unmatched["source:0"])
+	assert.True(t, unmatched["source:1"])
+	assert.False(t, unmatched["destination:registry.example.com/app"])
+
+	dot := graph.DOT()
+	assert.Contains(t, dot, `"source:1" [label="unused (path: src/unused)" shape=folder style=dashed];`)
+	assert.Contains(t, dot, `"override:0" -> "source:0" [label="builtFrom"];`)
+}
@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"strings"
 
+	regname "github.com/google/go-containerregistry/pkg/name"
 	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
 	"sigs.k8s.io/yaml"
 )
@@ -17,6 +18,9 @@ type Image struct {
 	URL        string
 	Origins    []ctlconf.Origin // empty when deserialized
 	originsRaw []interface{}    // populated when deserialized
+
+	// unprocessedURL is image reference found in resource
+	unprocessedURL string
 }
 
 func (imgs Images) ForImage(url string) (Image, bool) {
@@ -39,12 +43,15 @@ func (i Image) Description() string {
 }
 
 type imageStruct struct {
-	URL     string        `json:"url"`
-	Origins []interface{} `json:"origins,omitempty"`
+	URL        string        `json:"url"`
+	Tag        string        `json:"tag,omitempty"`
+	OriginFile string        `json:"originFile,omitempty"`
+	Origins    []interface{} `json:"origins,omitempty"`
 }
 
 func (st imageStruct) equal(other imageStruct) bool {
-	return st.URL == other.URL && reflect.DeepEqual(st.Origins, other.Origins)
+	return st.URL == other.URL && st.Tag == other.Tag &&
+		st.OriginFile == other.OriginFile && reflect.DeepEqual(st.Origins, other.Origins)
 }
 
 func contains(structs []imageStruct, st imageStruct) bool {
@@ -57,9 +64,19 @@ func contains(structs []imageStruct, st imageStruct) bool {
 }
 
 func newImageStructs(images []Image) []imageStruct {
+	return newImageStructsWithOpts(images, ctlconf.ImagesAnnotationInclude{}, "")
+}
+
+func newImageStructsWithOpts(images []Image, include ctlconf.ImagesAnnotationInclude, originFile string) []imageStruct {
 	var result []imageStruct
 	for _, img := range images {
 		st := newImageStruct(img)
+		if include.Tag {
+			st.Tag = img.unprocessedTag()
+		}
+		if include.OriginFile {
+			st.OriginFile = originFile
+		}
 		// if Origins is empty then the image was already in digest form and we didn't need to resolve
 		// it, so the annotation isn't very useful
 		if len(st.Origins) > 0 {
@@ -80,6 +97,18 @@ func newImageStruct(image Image) imageStruct {
 	return result
 }
 
+// unprocessedTag returns tag of image reference found in resource (if any)
+func (i Image) unprocessedTag() string {
+	ref, err := regname.ParseReference(i.unprocessedURL, regname.WeakValidation)
+	if err != nil {
+		return ""
+	}
+	if tagRef, ok := ref.(regname.Tag); ok {
+		return tagRef.TagStr()
+	}
+	return ""
+}
+
 func newImages(structs []imageStruct) []Image {
 	var result []Image
 	for _, st := range structs {
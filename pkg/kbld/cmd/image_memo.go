@@ -0,0 +1,55 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"sync"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+)
+
+// imageMemo makes sure that images sharing the same key are processed
+// only once within a run; concurrent callers wait for the first one
+type imageMemo struct {
+	entries   map[string]*imageMemoEntry
+	coalesced int
+	lock      sync.Mutex
+}
+
+type imageMemoEntry struct {
+	once    sync.Once
+	url     string
+	origins []ctlconf.Origin
+	err     error
+}
+
+func newImageMemo() *imageMemo {
+	return &imageMemo{entries: map[string]*imageMemoEntry{}}
+}
+
+func (m *imageMemo) Do(key string, processFunc func() (string, []ctlconf.Origin, error)) (string, []ctlconf.Origin, error) {
+	m.lock.Lock()
+	entry, found := m.entries[key]
+	if found {
+		m.coalesced++
+	} else {
+		entry = &imageMemoEntry{}
+		m.entries[key] = entry
+	}
+	m.lock.Unlock()
+
+	entry.once.Do(func() {
+		entry.url, entry.origins, entry.err = processFunc()
+	})
+
+	return entry.url, entry.origins, entry.err
+}
+
+// Coalesced returns number of times previously processed image was reused
+func (m *imageMemo) Coalesced() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.coalesced
+}
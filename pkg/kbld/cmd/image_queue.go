@@ -4,66 +4,231 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"sync"
 
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
 	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
 )
 
 type ImageQueue struct {
-	imgFactory ctlimg.Factory
+	imgFactory         ctlimg.Factory
+	progress           []ImageProgress
+	state              *RunState
+	resolveConcurrency int
+
+	memo *imageMemo
 
 	outputImages     *ProcessedImages
 	outputImagesLock sync.Mutex
 
-	outputErrs     []error
+	outputErrs     []imageQueueErr
 	outputErrsLock sync.Mutex
 }
 
+type imageQueueErr struct {
+	idx int
+	err error
+}
+
+type imageQueueItem struct {
+	idx int
+	UnprocessedImageURL
+}
+
 func NewImageQueue(imgFactory ctlimg.Factory) *ImageQueue {
 	return &ImageQueue{imgFactory: imgFactory}
 }
 
+// WithProgress reports start and completion of each image
+// (can be called multiple times to add several receivers)
+func (b *ImageQueue) WithProgress(progress ImageProgress) *ImageQueue {
+	if progress != nil {
+		b.progress = append(b.progress, progress)
+	}
+	return b
+}
+
+// WithState skips images recorded in state and records newly processed ones
+func (b *ImageQueue) WithState(state *RunState) *ImageQueue {
+	b.state = state
+	return b
+}
+
+// WithResolveConcurrency limits images that are not built separately
+// from builds (by default both share the same number of workers)
+func (b *ImageQueue) WithResolveConcurrency(num int) *ImageQueue {
+	b.resolveConcurrency = num
+	return b
+}
+
+// Run processes images concurrently. Errors are returned
+// in the order of given images regardless of completion order.
 func (b *ImageQueue) Run(unprocessedImageURLs *UnprocessedImageURLs, numWorkers int) (*ProcessedImages, error) {
 	b.outputImages = NewProcessedImages()
 	b.outputErrs = nil
+	b.memo = newImageMemo()
 
-	queueCh := make(chan UnprocessedImageURL, numWorkers)
-	workWg := sync.WaitGroup{}
+	var items []imageQueueItem
+	var urls []string
+	for i, unprocessedImageURL := range unprocessedImageURLs.All() {
+		items = append(items, imageQueueItem{i, unprocessedImageURL})
+		urls = append(urls, unprocessedImageURL.URL)
+	}
 
-	for i := 0; i < numWorkers; i++ {
-		go b.worker(&workWg, queueCh)
+	// Missing repositories of all images are reported together before anything is built
+	err := b.imgFactory.EnsureECRRepositories(urls)
+	if err != nil {
+		return b.outputImages, util.NewCategorizedError(util.ErrorCategoryPush, err)
 	}
 
-	for _, unprocessedImageURL := range unprocessedImageURLs.All() {
-		workWg.Add(1)
-		queueCh <- unprocessedImageURL
+	workWg := sync.WaitGroup{}
+
+	if b.resolveConcurrency > 0 {
+		// Builds and resolves have their own workers so that
+		// waiting builds never hold up images that are only resolved
+		var buildItems, resolveItems []imageQueueItem
+		for _, item := range items {
+			if b.built(item.URL) {
+				buildItems = append(buildItems, item)
+			} else {
+				resolveItems = append(resolveItems, item)
+			}
+		}
+		b.startWorkers(&workWg, buildItems, numWorkers)
+		b.startWorkers(&workWg, resolveItems, b.resolveConcurrency)
+	} else {
+		b.startWorkers(&workWg, items, numWorkers)
 	}
 
 	workWg.Wait()
+
+	sort.SliceStable(b.outputErrs, func(i, j int) bool {
+		return b.outputErrs[i].idx < b.outputErrs[j].idx
+	})
+
+	var errs []error
+	for _, err := range b.outputErrs {
+		errs = append(errs, err.err)
+	}
+
+	return b.outputImages, errFromErrs(errs)
+}
+
+// Coalesced returns number of images in the last run that were
+// processed identically to another image and hence reused its result
+func (b *ImageQueue) Coalesced() int {
+	if b.memo == nil {
+		return 0
+	}
+	return b.memo.Coalesced()
+}
+
+// startWorkers processes given items with up to numWorkers workers
+// (all items are queued upfront hence queueing never blocks)
+func (b *ImageQueue) startWorkers(workWg *sync.WaitGroup, items []imageQueueItem, numWorkers int) {
+	queueCh := make(chan imageQueueItem, len(items))
+	for _, item := range items {
+		workWg.Add(1)
+		queueCh <- item
+	}
 	close(queueCh)
 
-	return b.outputImages, errFromErrs(b.outputErrs)
+	for i := 0; i < numWorkers && i < len(items); i++ {
+		go b.worker(workWg, queueCh)
+	}
+}
+
+func (b *ImageQueue) worker(workWg *sync.WaitGroup, queueCh <-chan imageQueueItem) {
+	for item := range queueCh {
+		b.work(workWg, item)
+	}
 }
 
-func (b *ImageQueue) worker(workWg *sync.WaitGroup, queueCh <-chan UnprocessedImageURL) {
-	for unprocessedImageURL := range queueCh {
-		b.work(workWg, unprocessedImageURL)
+// progressContext returns context populated by progress receivers for started image
+func (b *ImageQueue) progressContext(url string) (context.Context, bool) {
+	ctx := context.Background()
+	found := false
+	for _, progress := range b.progress {
+		if ctxProgress, ok := progress.(ImageContextProgress); ok {
+			ctx = ctxProgress.Context(ctx, url)
+			found = true
+		}
 	}
+	return ctx, found
 }
 
-func (b *ImageQueue) work(workWg *sync.WaitGroup, unprocessedImageURL UnprocessedImageURL) {
+func (b *ImageQueue) built(url string) bool {
+	plan, err := b.imgFactory.Plan(url)
+	return err == nil && plan.Action == ctlimg.PlanActionBuild
+}
+
+func (b *ImageQueue) addErr(item imageQueueItem, err error) {
+	b.outputErrsLock.Lock()
+	defer b.outputErrsLock.Unlock()
+
+	b.outputErrs = append(b.outputErrs, imageQueueErr{item.idx, err})
+}
+
+func (b *ImageQueue) work(workWg *sync.WaitGroup, item imageQueueItem) {
 	defer workWg.Done()
 
-	imgURL, origins, err := b.imgFactory.New(unprocessedImageURL.URL).URL()
+	unprocessedImageURL := item.UnprocessedImageURL
+
+	if b.state != nil {
+		if img, found := b.state.Find(unprocessedImageURL.URL); found {
+			for _, progress := range b.progress {
+				progress.Started(unprocessedImageURL.URL, "resumed")
+				progress.Finished(unprocessedImageURL.URL, nil)
+			}
+
+			b.outputImagesLock.Lock()
+			b.outputImages.Add(unprocessedImageURL, img)
+			b.outputImagesLock.Unlock()
+			return
+		}
+	}
+
+	imgFactory := b.imgFactory
+
+	if len(b.progress) > 0 {
+		action := b.imgFactory.Action(unprocessedImageURL.URL)
+		for _, progress := range b.progress {
+			progress.Started(unprocessedImageURL.URL, action)
+		}
+		if ctx, found := b.progressContext(unprocessedImageURL.URL); found {
+			imgFactory = imgFactory.WithContext(ctx)
+		}
+	}
+
+	// Image is only created for the first of coalesced images
+	// since creating it may allocate resources (e.g. build timeout context)
+	imgURL, origins, err := b.memo.Do(b.imgFactory.ProcessingKey(unprocessedImageURL.URL),
+		func() (string, []ctlconf.Origin, error) { return imgFactory.New(unprocessedImageURL.URL).URL() })
+
+	for _, progress := range b.progress {
+		progress.Finished(unprocessedImageURL.URL, err)
+	}
+
 	if err != nil {
-		b.outputErrsLock.Lock()
-		b.outputErrs = append(b.outputErrs, fmt.Errorf("Resolving image '%s': %s", unprocessedImageURL.URL, err))
-		b.outputErrsLock.Unlock()
+		b.addErr(item, util.NewCategorizedError(util.ErrorCategoryOf(err),
+			fmt.Errorf("Resolving image '%s': %s", unprocessedImageURL.URL, err)))
 		return
 	}
 
+	img := Image{URL: imgURL, Origins: origins}
+
+	if b.state != nil {
+		err := b.state.Record(unprocessedImageURL.URL, img)
+		if err != nil {
+			b.addErr(item, err)
+		}
+	}
+
 	b.outputImagesLock.Lock()
-	b.outputImages.Add(unprocessedImageURL, Image{URL: imgURL, Origins: origins})
+	b.outputImages.Add(unprocessedImageURL, img)
 	b.outputImagesLock.Unlock()
 }
@@ -0,0 +1,116 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+func TestImageQueueResolveConcurrency(t *testing.T) {
+	configYAML := "apiVersion: kbld.k14s.io/v1alpha1\nkind: Config\noverrides:\n"
+	for i := 0; i < 50; i++ {
+		configYAML += fmt.Sprintf("- image: img%d\n  newImage: registry.example.com/img%d@sha256:%064d\n  preresolved: true\n", i, i, i)
+	}
+	// Building is not allowed hence these images fail
+	configYAML += "sources:\n"
+	for i := 0; i < 5; i++ {
+		configYAML += fmt.Sprintf("- image: failing%d\n  path: .\n", i)
+	}
+
+	rs, err := ctlres.NewResourcesFromBytes([]byte(configYAML))
+	require.NoError(t, err)
+
+	_, conf, err := ctlconf.NewConfFromResources(rs)
+	require.NoError(t, err)
+
+	imgFactory := ctlimg.NewFactory(ctlimg.FactoryOpts{Conf: conf}, ctlreg.Registry{}, ctllog.NewLogger(io.Discard))
+
+	urls := ctlcmd.NewUnprocessedImageURLs()
+	var expectedErrs []string
+	for i := 0; i < 50; i++ {
+		urls.Add(ctlcmd.UnprocessedImageURL{URL: fmt.Sprintf("img%d", i)})
+		if i%10 == 0 {
+			url := fmt.Sprintf("failing%d", i/10)
+			urls.Add(ctlcmd.UnprocessedImageURL{URL: url})
+			expectedErrs = append(expectedErrs, fmt.Sprintf("Resolving image '%s'", url))
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		images, err := ctlcmd.NewImageQueue(imgFactory).WithResolveConcurrency(8).Run(urls, 2)
+		require.Error(t, err)
+
+		// Errors are reported in the order of images
+		var errPositions []int
+		for _, expectedErr := range expectedErrs {
+			errPositions = append(errPositions, strings.Index(err.Error(), expectedErr))
+		}
+		for j := 1; j < len(errPositions); j++ {
+			assert.Less(t, errPositions[j-1], errPositions[j])
+		}
+
+		img, found := images.FindByURL(ctlcmd.UnprocessedImageURL{URL: "img42"})
+		require.True(t, found)
+		assert.Equal(t, fmt.Sprintf("registry.example.com/img42@sha256:%064d", 42), img.URL)
+	}
+}
+
+func TestImageQueueCoalescesIdenticalImages(t *testing.T) {
+	digestRef := fmt.Sprintf("registry.example.com/app@sha256:%064d", 1)
+
+	configYAML := fmt.Sprintf(`
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+overrides:
+- image: app
+  newImage: %[1]s
+- image: registry.example.com/app
+  newImage: %[1]s
+`, digestRef)
+
+	rs, err := ctlres.NewResourcesFromBytes([]byte(configYAML))
+	require.NoError(t, err)
+
+	_, conf, err := ctlconf.NewConfFromResources(rs)
+	require.NoError(t, err)
+
+	imgFactory := ctlimg.NewFactory(ctlimg.FactoryOpts{Conf: conf}, ctlreg.Registry{}, ctllog.NewLogger(io.Discard))
+
+	urls := ctlcmd.NewUnprocessedImageURLs()
+	for i := 0; i < 3; i++ {
+		urls.Add(ctlcmd.UnprocessedImageURL{URL: "app"})
+	}
+	urls.Add(ctlcmd.UnprocessedImageURL{URL: "registry.example.com/app"})
+	urls.Add(ctlcmd.UnprocessedImageURL{URL: digestRef})
+	urls.Add(ctlcmd.UnprocessedImageURL{URL: "other"})
+
+	assert.Equal(t, 6, urls.References())
+	assert.Len(t, urls.All(), 4)
+
+	queue := ctlcmd.NewImageQueue(imgFactory)
+	images, err := queue.Run(urls, 2)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Resolving image 'other'")
+
+	// All references to app are processed by a single resolution
+	assert.Equal(t, 2, queue.Coalesced())
+
+	for _, url := range []string{"app", "registry.example.com/app", digestRef} {
+		img, found := images.FindByURL(ctlcmd.UnprocessedImageURL{URL: url})
+		require.True(t, found)
+		assert.Equal(t, digestRef, img.URL)
+	}
+}
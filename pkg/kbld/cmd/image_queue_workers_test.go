@@ -0,0 +1,87 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package cmd_test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/testutil"
+)
+
+type finishedOrder struct {
+	lock sync.Mutex
+	urls []string
+}
+
+func (o *finishedOrder) Started(string, string) {}
+
+func (o *finishedOrder) Finished(url string, _ error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.urls = append(o.urls, url)
+}
+
+func TestImageQueueResolvesDoNotWaitForQueuedBuilds(t *testing.T) {
+	// Builds hang until they time out
+	testutil.FakeBinaries(t, map[string]string{
+		"docker": "exec sleep 60\n",
+	})
+
+	srcDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "Dockerfile"), []byte("FROM scratch\n"), 0600))
+
+	configYAML := "apiVersion: kbld.k14s.io/v1alpha1\nkind: Config\nsources:\n"
+	for i := 0; i < 3; i++ {
+		configYAML += fmt.Sprintf("- image: built%d\n  path: %s\n  timeout: 300ms\n", i, srcDir)
+	}
+	configYAML += "overrides:\n"
+	for i := 0; i < 5; i++ {
+		configYAML += fmt.Sprintf("- image: resolved%d\n  newImage: registry.example.com/img%d@sha256:%064d\n  preresolved: true\n", i, i, i)
+	}
+
+	rs, err := ctlres.NewResourcesFromBytes([]byte(configYAML))
+	require.NoError(t, err)
+
+	_, conf, err := ctlconf.NewConfFromResources(rs)
+	require.NoError(t, err)
+
+	imgFactory := ctlimg.NewFactory(ctlimg.FactoryOpts{Conf: conf, AllowedToBuild: true},
+		ctlreg.Registry{}, ctllog.NewLogger(io.Discard))
+
+	// Builds are queued first and there are more of them than workers
+	urls := ctlcmd.NewUnprocessedImageURLs()
+	for i := 0; i < 3; i++ {
+		urls.Add(ctlcmd.UnprocessedImageURL{URL: fmt.Sprintf("built%d", i)})
+	}
+	for i := 0; i < 5; i++ {
+		urls.Add(ctlcmd.UnprocessedImageURL{URL: fmt.Sprintf("resolved%d", i)})
+	}
+
+	order := &finishedOrder{}
+
+	_, err = ctlcmd.NewImageQueue(imgFactory).WithProgress(order).WithResolveConcurrency(2).Run(urls, 1)
+	require.Error(t, err)
+
+	require.Len(t, order.urls, 8)
+	for _, url := range order.urls[:5] {
+		assert.True(t, strings.HasPrefix(url, "resolved"), "Expected resolves to finish first: %v", order.urls)
+	}
+}
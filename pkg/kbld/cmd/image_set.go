@@ -7,8 +7,11 @@ import (
 	"fmt"
 
 	regname "github.com/google/go-containerregistry/pkg/name"
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
 	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
 	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/imagedesc"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/imageutils/convert"
 	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
 	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
 	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
@@ -17,6 +20,12 @@ import (
 type ImageSet struct {
 	concurrency int
 	logger      *ctllog.PrefixWriter
+
+	includeNonDistributable bool
+	// converter when set converts layers before import
+	converter *convert.Converter
+	// platforms when set limits images imported from indexes
+	platforms []ctlconf.PlatformSelection
 }
 
 func (o ImageSet) Relocate(foundImages *UnprocessedImageURLs,
@@ -27,7 +36,9 @@ func (o ImageSet) Relocate(foundImages *UnprocessedImageURLs,
 		return nil, err
 	}
 
-	return o.Import(imagedesc.NewDescribedReader(ids, ids).Read(), importRepo, registry)
+	readerOpts := imagedesc.DescribedReaderOpts{IncludeNonDistributable: o.includeNonDistributable}
+
+	return o.Import(imagedesc.NewDescribedReaderWithOpts(ids, ids, readerOpts).Read(), importRepo, registry)
 }
 
 func (o ImageSet) Export(foundImages *UnprocessedImageURLs,
@@ -106,7 +117,12 @@ func (o *ImageSet) importImage(item imagedesc.ImageOrIndex,
 	existingRef regname.Digest, importRepo regname.Repository,
 	registry ctlreg.Registry) (regname.Digest, error) {
 
-	itemDigest, err := item.Digest()
+	convertedItem, err := o.convert(item)
+	if err != nil {
+		return regname.Digest{}, fmt.Errorf("Converting layers: %s", err)
+	}
+
+	itemDigest, err := convertedItem.Digest()
 	if err != nil {
 		return regname.Digest{}, err
 	}
@@ -125,14 +141,14 @@ func (o *ImageSet) importImage(item imagedesc.ImageOrIndex,
 	o.logger.Write([]byte(fmt.Sprintf("importing %s -> %s...\n", existingRef.Name(), importDigestRef.Name())))
 
 	switch {
-	case item.Image != nil:
-		err = registry.WriteImage(uploadTagRef, *item.Image)
+	case convertedItem.Image != nil:
+		err = registry.WriteImage(uploadTagRef, convertedItem.Image)
 		if err != nil {
 			return regname.Digest{}, fmt.Errorf("Importing image as %s: %s", importDigestRef.Name(), err)
 		}
 
-	case item.Index != nil:
-		err = registry.WriteIndex(uploadTagRef, *item.Index)
+	case convertedItem.Index != nil:
+		err = registry.WriteIndex(uploadTagRef, convertedItem.Index)
 		if err != nil {
 			return regname.Digest{}, fmt.Errorf("Importing image index as %s: %s", importDigestRef.Name(), err)
 		}
@@ -153,6 +169,71 @@ func (o *ImageSet) importImage(item imagedesc.ImageOrIndex,
 	return importDigestRef, nil
 }
 
+// convertedItem is image or index to be imported
+type convertedItem struct {
+	Image regv1.Image
+	Index regv1.ImageIndex
+}
+
+func (i convertedItem) Digest() (regv1.Hash, error) {
+	if i.Image != nil {
+		return i.Image.Digest()
+	}
+	return i.Index.Digest()
+}
+
+func (o *ImageSet) convert(item imagedesc.ImageOrIndex) (convertedItem, error) {
+	var result convertedItem
+	var err error
+
+	switch {
+	case item.Image != nil:
+		result.Image = *item.Image
+		if o.converter != nil {
+			result.Image, err = o.converter.Image(result.Image)
+		}
+
+	case item.Index != nil:
+		result.Index = *item.Index
+		if len(o.platforms) > 0 {
+			result.Index, err = convert.FilterIndex(result.Index, o.matchesPlatforms)
+			if err != nil {
+				return result, err
+			}
+		}
+		if o.converter != nil {
+			result.Index, err = o.converter.Index(result.Index)
+		}
+
+	default:
+		panic("Unknown item")
+	}
+
+	return result, err
+}
+
+func (o *ImageSet) matchesPlatforms(platform regv1.Platform) bool {
+	for _, selection := range o.platforms {
+		if ctlimg.MatchesPlatformSelection(platform, selection) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewPlatformSelections parses platforms used to filter image indexes
+func NewPlatformSelections(platforms []string) ([]ctlconf.PlatformSelection, error) {
+	var result []ctlconf.PlatformSelection
+	for _, platform := range platforms {
+		selection, err := NewPlatformSelection(platform)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *selection)
+	}
+	return result, nil
+}
+
 func (o *ImageSet) verifyTagDigest(
 	uploadTagRef regname.Reference, importDigestRef regname.Digest, registry ctlreg.Registry) error {
 
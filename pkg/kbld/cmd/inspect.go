@@ -4,10 +4,15 @@
 package cmd
 
 import (
+	"fmt"
+	"sort"
+
 	"github.com/cppforlife/go-cli-ui/ui"
 	uitable "github.com/cppforlife/go-cli-ui/ui/table"
 	"github.com/spf13/cobra"
 	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
 	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
 	ctlser "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/search"
 )
@@ -17,6 +22,7 @@ type InspectOptions struct {
 
 	FileFlags     FileFlags
 	RegistryFlags RegistryFlags
+	Details       bool
 }
 
 func NewInspectOptions(ui ui.UI) *InspectOptions {
@@ -32,6 +38,7 @@ func NewInspectCmd(o *InspectOptions) *cobra.Command {
 	}
 	o.FileFlags.Set(cmd)
 	o.RegistryFlags.Set(cmd)
+	cmd.Flags().BoolVar(&o.Details, "details", false, "Show image details fetched from registry (platforms, size, layers, labels, signatures)")
 	return cmd
 }
 
@@ -41,9 +48,10 @@ func (o *InspectOptions) Run() error {
 		return err
 	}
 
-	foundImages, err := o.findImages(rs, conf)
-	if err != nil {
-		return err
+	foundImages := findImages(rs, conf)
+
+	if o.Details {
+		return o.printDetails(foundImages)
 	}
 
 	table := uitable.Table{
@@ -84,8 +92,91 @@ func (o *InspectOptions) Run() error {
 	return nil
 }
 
-func (o *InspectOptions) findImages(rs []ctlres.Resource,
-	conf ctlconf.Conf) ([]foundResourceWithImage, error) {
+func (o *InspectOptions) printDetails(foundImages []foundResourceWithImage) error {
+	registryOpts, err := o.RegistryFlags.AsRegistryOpts()
+	if err != nil {
+		return err
+	}
+
+	registry, err := ctlreg.NewRegistry(registryOpts)
+	if err != nil {
+		return err
+	}
+
+	resourcesByURL := map[string][]string{}
+	for _, resWithImg := range foundImages {
+		resourcesByURL[resWithImg.URL] = append(resourcesByURL[resWithImg.URL], resWithImg.Resource.Description())
+	}
+
+	table := uitable.Table{
+		Title:   "Images",
+		Content: "images",
+
+		Header: []uitable.Header{
+			uitable.NewHeader("Image"),
+			uitable.NewHeader("Digest"),
+			uitable.NewHeader("Platforms"),
+			uitable.NewHeader("Compressed size"),
+			uitable.NewHeader("Layers"),
+			uitable.NewHeader("Created"),
+			uitable.NewHeader("Labels"),
+			uitable.NewHeader("Signed"),
+			uitable.NewHeader("Resources"),
+		},
+
+		SortBy: []uitable.ColumnSort{{Column: 0, Asc: true}},
+
+		// Image URLs and other content is too long
+		FillFirstColumn: true,
+		Transpose:       true,
+	}
+
+	fetcher := ctlimg.NewDetailsFetcher(registry)
+
+	for url, resources := range resourcesByURL {
+		details, err := fetcher.Fetch(url)
+		if err != nil {
+			return err
+		}
+
+		var labels []string
+		for k, v := range details.Labels {
+			labels = append(labels, k+"="+v)
+		}
+		sort.Strings(labels)
+
+		table.Rows = append(table.Rows, []uitable.Value{
+			uitable.NewValueString(url),
+			uitable.NewValueString(details.Digest),
+			uitable.NewValueStrings(details.Platforms),
+			uitable.NewValueString(humanBytes(details.CompressedSize)),
+			uitable.NewValueInt(details.Layers),
+			uitable.NewValueTime(details.Created),
+			uitable.NewValueStrings(labels),
+			uitable.NewValueBool(details.Signed),
+			uitable.NewValueStrings(resources),
+		})
+	}
+
+	o.ui.PrintTable(table)
+
+	return nil
+}
+
+func humanBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func findImages(rs []ctlres.Resource, conf ctlconf.Conf) []foundResourceWithImage {
 
 	foundImages := []foundResourceWithImage{}
 
@@ -98,7 +189,7 @@ func (o *InspectOptions) findImages(rs []ctlres.Resource,
 		})
 	}
 
-	return foundImages, nil
+	return foundImages
 }
 
 type foundResourceWithImage struct {
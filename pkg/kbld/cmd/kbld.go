@@ -5,6 +5,7 @@ package cmd
 
 import (
 	"io"
+	"os"
 
 	"github.com/cppforlife/cobrautil"
 	"github.com/cppforlife/go-cli-ui/ui"
@@ -13,8 +14,10 @@ import (
 )
 
 type KbldOptions struct {
-	ui      *ui.ConfUI
-	UIFlags UIFlags
+	ui           *ui.ConfUI
+	UIFlags      UIFlags
+	ProfileFlags ProfileFlags
+	TmpDirFlags  TmpDirFlags
 }
 
 func NewKbldOptions(ui *ui.ConfUI) *KbldOptions {
@@ -45,16 +48,46 @@ func NewKbldCmd(o *KbldOptions) *cobra.Command {
 	cmd.SetOutput(uiBlockWriter{o.ui}) // setting output for cmd.Help()
 
 	o.UIFlags.Set(cmd)
+	o.ProfileFlags.Set(cmd)
+	o.TmpDirFlags.Set(cmd)
 
+	cmd.AddCommand(NewBuildCmd(NewBuildOptions(o.ui)))
 	cmd.AddCommand(NewInspectCmd(NewInspectOptions(o.ui)))
+	cmd.AddCommand(NewVerifyCmd(NewVerifyOptions(o.ui)))
+	cmd.AddCommand(NewDiffCmd(NewDiffOptions(o.ui)))
+	cmd.AddCommand(NewGraphCmd(NewGraphOptions(o.ui)))
 	cmd.AddCommand(NewPackageCmd(NewPackageOptions(o.ui)))
 	cmd.AddCommand(NewUnpackageCmd(NewUnpackageOptions(o.ui)))
 	cmd.AddCommand(NewVersionCmd(NewVersionOptions(o.ui)))
 	cmd.AddCommand(NewRelocateCmd(NewRelocateOptions(o.ui)))
+	cmd.AddCommand(NewGCCmd(NewGCOptions(o.ui)))
+	cmd.AddCommand(NewServeCmd(NewServeOptions(o.ui)))
+	cmd.AddCommand(NewDaemonCmd(NewDaemonOptions(o.ui)))
+	cmd.AddCommand(NewWebhookCmd(NewWebhookOptions(o.ui)))
+	cmd.AddCommand(NewControllerCmd(NewControllerOptions(o.ui)))
+	cmd.AddCommand(NewCmpServerCmd(o.ui))
+	cmd.AddCommand(NewTerraformCmd(NewTerraformOptions(o.ui)))
+	cmd.AddCommand(NewConfigCmd(o.ui))
+	cmd.AddCommand(NewDoctorCmd(NewDoctorOptions(o.ui)))
+	cmd.AddCommand(NewCompletionCmd())
+
+	cobrautil.VisitCommands(cmd, RegisterCompletions)
+
+	// Command answering completion requests is added by cobra right before
+	// execution, hence it does not have RunE wrapped like other commands
+	cmd.PersistentPreRun = func(cmd2 *cobra.Command, _ []string) {
+		if cmd2.Name() == cobra.ShellCompRequestCmd {
+			o.UIFlags.ConfigureUI(o.ui)
+			// Completion script only reads stdout
+			cmd2.Root().SetErr(os.Stderr)
+		}
+	}
 
 	// Last one runs first
 	cobrautil.VisitCommands(cmd, cobrautil.ReconfigureCmdWithSubcmd)
 	cobrautil.VisitCommands(cmd, cobrautil.DisallowExtraArgs)
+	cobrautil.VisitCommands(cmd, o.ProfileFlags.WrapRunE)
+	cobrautil.VisitCommands(cmd, o.TmpDirFlags.WrapRunE)
 
 	cobrautil.VisitCommands(cmd, cobrautil.WrapRunEForCmd(func(*cobra.Command, []string) error {
 		o.UIFlags.ConfigureUI(o.ui)
@@ -0,0 +1,107 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	"sigs.k8s.io/yaml"
+)
+
+// KustomizeImages is a Kustomize component that sets images via images
+// transformer. Its entries could also be used as Flux Kustomization
+// spec.images; this allows to replace Flux image automation markers
+// with images resolved by kbld without changing manifests.
+type KustomizeImages struct {
+	APIVersion string                 `json:"apiVersion"`
+	Kind       string                 `json:"kind"`
+	Images     []KustomizeImagesEntry `json:"images"`
+}
+
+type KustomizeImagesEntry struct {
+	Name    string `json:"name"`
+	NewName string `json:"newName,omitempty"`
+	NewTag  string `json:"newTag,omitempty"`
+	Digest  string `json:"digest,omitempty"`
+}
+
+// NewKustomizeImages converts resolved images into images transformer entries.
+// Entries are matched by Kustomize by image name (without tag or digest),
+// hence all references to the same name are expected to resolve to the same image.
+func NewKustomizeImages(resolvedImages *ProcessedImages) (KustomizeImages, error) {
+	result := KustomizeImages{
+		APIVersion: "kustomize.config.k8s.io/v1alpha1",
+		Kind:       "Component",
+	}
+
+	resolvedURLs := map[string]string{}
+
+	for _, pair := range resolvedImages.All() {
+		name := untaggedImageName(pair.UnprocessedImageURL.URL)
+
+		if prevURL, found := resolvedURLs[name]; found {
+			if prevURL != pair.Image.URL {
+				return KustomizeImages{}, fmt.Errorf("Expected image '%s' to be resolved to a single image "+
+					"for Kustomize images output, but was resolved to '%s' and '%s'", name, prevURL, pair.Image.URL)
+			}
+			continue
+		}
+		resolvedURLs[name] = pair.Image.URL
+
+		entry := KustomizeImagesEntry{Name: name}
+
+		ref, err := regname.ParseReference(pair.Image.URL, regname.WeakValidation)
+		if err != nil {
+			return KustomizeImages{}, fmt.Errorf("Parsing resolved image '%s': %s", pair.Image.URL, err)
+		}
+
+		switch typedRef := ref.(type) {
+		case regname.Digest:
+			entry.NewName = typedRef.Context().Name()
+			entry.Digest = typedRef.DigestStr()
+		case regname.Tag:
+			entry.NewName = typedRef.Context().Name()
+			entry.NewTag = typedRef.TagStr()
+		}
+
+		result.Images = append(result.Images, entry)
+	}
+
+	sort.Slice(result.Images, func(i, j int) bool {
+		return result.Images[i].Name < result.Images[j].Name
+	})
+
+	return result, nil
+}
+
+func (i KustomizeImages) WriteToFile(path string) error {
+	bs, err := yaml.Marshal(i)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(path, append([]byte("---\n"), bs...), 0600)
+	if err != nil {
+		return fmt.Errorf("Writing Kustomize images: %s", err)
+	}
+
+	return nil
+}
+
+// untaggedImageName strips tag and digest from image reference as it
+// was specified in resources (e.g. nginx:1.25 -> nginx), since
+// images transformer matches references by such name
+func untaggedImageName(url string) string {
+	if idx := strings.Index(url, "@"); idx >= 0 {
+		url = url[:idx]
+	}
+	if idx := strings.LastIndex(url, ":"); idx > strings.LastIndex(url, "/") {
+		url = url[:idx]
+	}
+	return url
+}
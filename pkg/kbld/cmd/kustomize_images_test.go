@@ -0,0 +1,103 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestResolveKustomizeImagesOutput(t *testing.T) {
+	resolve := func(t *testing.T, input string) (string, error) {
+		dir := t.TempDir()
+		inputPath := filepath.Join(dir, "input.yml")
+		outputPath := filepath.Join(dir, "images.yml")
+		require.NoError(t, os.WriteFile(inputPath, []byte(input), 0600))
+
+		cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(&bytes.Buffer{}, io.Discard, ui.NewNoopLogger())))
+		cmd.SetArgs([]string{"-f", inputPath, "--kustomize-images-output", outputPath, "--progress=plain"})
+
+		err := cmd.Execute()
+		if err != nil {
+			return "", err
+		}
+
+		bs, err := os.ReadFile(outputPath)
+		require.NoError(t, err)
+
+		return string(bs), nil
+	}
+
+	digest1 := fmt.Sprintf("sha256:%064d", 1)
+	digest2 := fmt.Sprintf("sha256:%064d", 2)
+
+	t.Run("writes component with resolved images", func(t *testing.T) {
+		output, err := resolve(t, fmt.Sprintf(`
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: nginx:1.25
+  - image: nginx:1.25
+  - image: localhost:5000/app
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+overrides:
+- image: nginx:1.25
+  newImage: index.docker.io/library/nginx@%s
+  preresolved: true
+- image: localhost:5000/app
+  newImage: registry.example.com/app:v1
+  preresolved: true
+`, digest1))
+		require.NoError(t, err)
+
+		assert.Equal(t, fmt.Sprintf(`---
+apiVersion: kustomize.config.k8s.io/v1alpha1
+images:
+- name: localhost:5000/app
+  newName: registry.example.com/app
+  newTag: v1
+- digest: %s
+  name: nginx
+  newName: index.docker.io/library/nginx
+kind: Component
+`, digest1), output)
+	})
+
+	t.Run("fails when same name resolves to different images", func(t *testing.T) {
+		_, err := resolve(t, fmt.Sprintf(`
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: nginx:1.24
+  - image: nginx:1.25
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+overrides:
+- image: nginx:1.24
+  newImage: index.docker.io/library/nginx@%s
+  preresolved: true
+- image: nginx:1.25
+  newImage: index.docker.io/library/nginx@%s
+  preresolved: true
+`, digest1, digest2))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Expected image 'nginx' to be resolved to a single image for Kustomize images output")
+	})
+}
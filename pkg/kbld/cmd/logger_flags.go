@@ -0,0 +1,62 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+)
+
+type LoggerFlags struct {
+	Level  string
+	Format string
+	File   string
+}
+
+// UsesTerminal indicates whether logs are written as plain text to stderr
+func (s *LoggerFlags) UsesTerminal() bool {
+	return len(s.File) == 0 && s.Format == string(ctllog.FormatText)
+}
+
+func (s *LoggerFlags) Set(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&s.Level, "log-level", "info", "Set minimum level of log messages (debug, info, warn, error)")
+	cmd.Flags().StringVar(&s.Format, "log-format", "text", "Set log format (text, json)")
+	cmd.Flags().StringVar(&s.File, "log-file", "", "Write logs to file instead of stderr")
+}
+
+// NewLogger returns configured logger and a function to close log file
+func (s *LoggerFlags) NewLogger() (ctllog.Logger, func(), error) {
+	return s.NewLoggerWithStderr(os.Stderr)
+}
+
+// NewLoggerWithStderr uses given writer instead of stderr (unless log file is specified)
+func (s *LoggerFlags) NewLoggerWithStderr(stderr io.Writer) (ctllog.Logger, func(), error) {
+	level, err := ctllog.ParseLevel(s.Level)
+	if err != nil {
+		return ctllog.Logger{}, nil, err
+	}
+
+	format, err := ctllog.ParseFormat(s.Format)
+	if err != nil {
+		return ctllog.Logger{}, nil, err
+	}
+
+	writer := stderr
+	closeFunc := func() {}
+
+	if len(s.File) > 0 {
+		file, err := os.OpenFile(s.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return ctllog.Logger{}, nil, fmt.Errorf("Opening log file: %s", err)
+		}
+		writer = file
+		closeFunc = func() { file.Close() }
+	}
+
+	return ctllog.NewLoggerWithOpts(writer, ctllog.Opts{Level: level, Format: format}), closeFunc, nil
+}
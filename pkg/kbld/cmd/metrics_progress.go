@@ -0,0 +1,54 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"sync"
+	"time"
+
+	ctlmetrics "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/metrics"
+)
+
+// MetricsProgress records duration and result of each processed image
+type MetricsProgress struct {
+	metrics *ctlmetrics.Metrics
+	now     func() time.Time
+
+	started     map[string]metricsProgressItem
+	startedLock sync.Mutex
+}
+
+type metricsProgressItem struct {
+	action  string
+	started time.Time
+}
+
+var _ ImageProgress = &MetricsProgress{}
+
+func NewMetricsProgress(metrics *ctlmetrics.Metrics) *MetricsProgress {
+	return &MetricsProgress{metrics: metrics, now: time.Now, started: map[string]metricsProgressItem{}}
+}
+
+func (p *MetricsProgress) Started(url, action string) {
+	p.startedLock.Lock()
+	defer p.startedLock.Unlock()
+
+	p.started[url] = metricsProgressItem{action, p.now()}
+}
+
+func (p *MetricsProgress) Finished(url string, err error) {
+	p.startedLock.Lock()
+	item, found := p.started[url]
+	delete(p.started, url)
+	p.startedLock.Unlock()
+
+	if !found {
+		return
+	}
+
+	// Preresolved and resumed images did not need to be built or resolved
+	cacheHit := item.action == "preresolved" || item.action == "resumed"
+
+	p.metrics.ObserveImage(item.action, cacheHit, p.now().Sub(item.started), err)
+}
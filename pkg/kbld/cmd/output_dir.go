@@ -0,0 +1,59 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OutputDir writes resources into files mirroring paths of input files
+type OutputDir struct {
+	path string
+}
+
+func NewOutputDir(path string) OutputDir {
+	return OutputDir{path}
+}
+
+// Write expects resBss and relPaths to be of the same length
+// (relPaths[i] is a file path relative to input directory resource i was found in)
+func (d OutputDir) Write(resBss [][]byte, relPaths []string) error {
+	if len(resBss) != len(relPaths) {
+		return fmt.Errorf("Internal inconsistency: expected resources to have corresponding file paths")
+	}
+
+	var orderedPaths []string
+	contents := map[string][]byte{}
+
+	for i, resBs := range resBss {
+		relPath := filepath.Clean(relPaths[i])
+		if len(relPaths[i]) == 0 || filepath.IsAbs(relPath) || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("Expected resource file path '%s' to be relative to output directory", relPaths[i])
+		}
+
+		if _, found := contents[relPath]; !found {
+			orderedPaths = append(orderedPaths, relPath)
+		}
+		contents[relPath] = append(contents[relPath], append([]byte("---\n"), resBs...)...)
+	}
+
+	for _, relPath := range orderedPaths {
+		fullPath := filepath.Join(d.path, relPath)
+
+		err := os.MkdirAll(filepath.Dir(fullPath), 0700)
+		if err != nil {
+			return fmt.Errorf("Creating output directory: %s", err)
+		}
+
+		err = os.WriteFile(fullPath, contents[relPath], 0600)
+		if err != nil {
+			return fmt.Errorf("Writing output file '%s': %s", fullPath, err)
+		}
+	}
+
+	return nil
+}
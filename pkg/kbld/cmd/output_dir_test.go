@@ -0,0 +1,42 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestOutputDirWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	resBss := [][]byte{
+		[]byte("kind: Deployment\n"),
+		[]byte("kind: Service\n"),
+		[]byte("kind: Job\n"),
+	}
+	relPaths := []string{"app/deploy.yml", "app/deploy.yml", "jobs/job.yml"}
+
+	err := ctlcmd.NewOutputDir(dir).Write(resBss, relPaths)
+	require.NoError(t, err)
+
+	bs, err := os.ReadFile(filepath.Join(dir, "app", "deploy.yml"))
+	require.NoError(t, err)
+	assert.Equal(t, "---\nkind: Deployment\n---\nkind: Service\n", string(bs))
+
+	bs, err = os.ReadFile(filepath.Join(dir, "jobs", "job.yml"))
+	require.NoError(t, err)
+	assert.Equal(t, "---\nkind: Job\n", string(bs))
+}
+
+func TestOutputDirWriteRejectsEscapingPaths(t *testing.T) {
+	err := ctlcmd.NewOutputDir(t.TempDir()).Write([][]byte{[]byte("kind: Job\n")}, []string{"../job.yml"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "to be relative to output directory")
+}
@@ -5,7 +5,6 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/cppforlife/go-cli-ui/ui"
 	regname "github.com/google/go-containerregistry/pkg/name"
@@ -23,8 +22,11 @@ type PackageOptions struct {
 
 	FileFlags     FileFlags
 	RegistryFlags RegistryFlags
+	LoggerFlags   LoggerFlags
 	OutputPath    string
 	Concurrency   int
+
+	IncludeNonDistributable bool
 }
 
 var _ imagedesc.Registry = ctlreg.Registry{}
@@ -48,15 +50,23 @@ Package configuration and images into tarball
 	}
 	o.FileFlags.Set(cmd)
 	o.RegistryFlags.Set(cmd)
+	o.LoggerFlags.Set(cmd)
+	o.RegistryFlags.SetBandwidth(cmd)
 	cmd.Flags().StringVarP(&o.OutputPath, "output", "o", "", "Output tarball path")
 	cmd.Flags().IntVar(&o.Concurrency, "concurrency", 5, "Set maximum number of concurrent imports")
+	cmd.Flags().BoolVar(&o.IncludeNonDistributable, "include-non-distributable", false, "Include non-distributable (foreign) layers into tarball (only when licensing allows)")
 	return cmd
 }
 
 func (o *PackageOptions) Run() error {
-	logger := ctllog.NewLogger(os.Stderr)
+	logger, closeLogger, err := o.LoggerFlags.NewLogger()
+	if err != nil {
+		return err
+	}
+	defer closeLogger()
+
 	warningLogger := logger.NewPrefixedWriter("Warning: ")
-	err := warningLogger.WriteStr(`Command "package" is deprecated, please use 'imgpkg push', learn more in https://carvel.dev/imgpkg/docs/latest/commands/#push`)
+	err = warningLogger.WriteLevelStr(ctllog.LevelWarn, `Command "package" is deprecated, please use 'imgpkg push', learn more in https://carvel.dev/imgpkg/docs/latest/commands/#push`)
 	if err != nil {
 		return err
 	}
@@ -65,8 +75,6 @@ func (o *PackageOptions) Run() error {
 		return fmt.Errorf("Expected 'output' flag to be non-empty")
 	}
 
-	prefixedLogger := logger.NewPrefixedWriter("package | ")
-
 	rs, conf, err := o.FileFlags.ResourcesAndConfig()
 	if err != nil {
 		return err
@@ -77,12 +85,24 @@ func (o *PackageOptions) Run() error {
 		return err
 	}
 
-	registry, err := ctlreg.NewRegistry(o.RegistryFlags.AsRegistryOpts())
+	registryOpts, err := o.RegistryFlags.AsRegistryOpts()
 	if err != nil {
 		return err
 	}
 
-	imageSet := TarImageSet{ImageSet{o.Concurrency, prefixedLogger}, o.Concurrency, prefixedLogger}
+	registryOpts.IncludeNonDistributable = o.IncludeNonDistributable
+
+	registry, err := ctlreg.NewRegistry(registryOpts)
+	if err != nil {
+		return err
+	}
+
+	return o.PackageImages(foundImages, registry, logger)
+}
+
+// PackageImages exports images into output tarball
+func (o *PackageOptions) PackageImages(foundImages *UnprocessedImageURLs, registry ctlreg.Registry, logger ctllog.Logger) error {
+	imageSet := NewTarImageSet(o.Concurrency, logger.NewPrefixedWriter("package | "), o.IncludeNonDistributable)
 
 	return imageSet.Export(foundImages, o.OutputPath, registry)
 }
@@ -0,0 +1,55 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlpol "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/policy"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+// CheckPolicies evaluates configured policies against all resolved images
+func CheckPolicies(conf ctlconf.Conf, images *ProcessedImages,
+	registry ctlreg.Registry, logger ctllog.Logger) error {
+
+	policies := conf.Policies()
+	if len(policies) == 0 {
+		return nil
+	}
+
+	input := ctlpol.Input{Images: []ctlpol.InputImage{}}
+
+	for _, item := range images.All() {
+		img, err := ctlpol.NewInputImage(item.UnprocessedImageURL.URL, item.Image.URL, item.Image.Origins, registry)
+		if err != nil {
+			return fmt.Errorf("Collecting policy input: %s", err)
+		}
+		input.Images = append(input.Images, img)
+	}
+
+	evaluator := ctlpol.NewEvaluator(logger)
+
+	var violations []string
+
+	for _, policy := range policies {
+		policyViolations, err := evaluator.Evaluate(policy, input)
+		if err != nil {
+			return err
+		}
+		for _, violation := range policyViolations {
+			violations = append(violations, fmt.Sprintf("%s: %s", policy.Description(), violation))
+		}
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("Expected resolved images to satisfy policies, but found violations:\n- %s",
+			strings.Join(violations, "\n- "))
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	rtpprof "runtime/pprof"
+
+	"github.com/spf13/cobra"
+)
+
+type ProfileFlags struct {
+	CPUFile string
+	MemFile string
+}
+
+func (f *ProfileFlags) Set(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&f.CPUFile, "profile", "", "Write CPU profile to file (inspect with 'go tool pprof')")
+	cmd.PersistentFlags().StringVar(&f.MemFile, "profile-mem", "", "Write memory (heap) profile to file when command completes")
+}
+
+// WrapRunE profiles execution of the command (when requested)
+func (f *ProfileFlags) WrapRunE(cmd *cobra.Command) {
+	origRunE := cmd.RunE
+	if origRunE == nil {
+		return
+	}
+
+	cmd.RunE = func(cmd2 *cobra.Command, args []string) error {
+		stop, err := f.start()
+		if err != nil {
+			return err
+		}
+
+		err = origRunE(cmd2, args)
+
+		stopErr := stop()
+		if err == nil {
+			err = stopErr
+		}
+		return err
+	}
+}
+
+// start begins CPU profiling and returns function that
+// stops it and writes memory profile
+func (f *ProfileFlags) start() (func() error, error) {
+	var cpuFile *os.File
+
+	if len(f.CPUFile) > 0 {
+		var err error
+
+		cpuFile, err = os.Create(f.CPUFile)
+		if err != nil {
+			return nil, fmt.Errorf("Creating CPU profile: %s", err)
+		}
+
+		err = rtpprof.StartCPUProfile(cpuFile)
+		if err != nil {
+			cpuFile.Close()
+			return nil, fmt.Errorf("Starting CPU profile: %s", err)
+		}
+	}
+
+	return func() error {
+		if cpuFile != nil {
+			rtpprof.StopCPUProfile()
+
+			err := cpuFile.Close()
+			if err != nil {
+				return fmt.Errorf("Writing CPU profile: %s", err)
+			}
+		}
+
+		if len(f.MemFile) > 0 {
+			return f.writeMemProfile()
+		}
+		return nil
+	}, nil
+}
+
+func (f *ProfileFlags) writeMemProfile() error {
+	memFile, err := os.Create(f.MemFile)
+	if err != nil {
+		return fmt.Errorf("Creating memory profile: %s", err)
+	}
+	defer memFile.Close()
+
+	// Include all allocations up to this point
+	runtime.GC()
+
+	err = rtpprof.WriteHeapProfile(memFile)
+	if err != nil {
+		return fmt.Errorf("Writing memory profile: %s", err)
+	}
+
+	return nil
+}
+
+// registerPprof exposes runtime profiles at /debug/pprof/
+func registerPprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
@@ -0,0 +1,32 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestProfileFlags(t *testing.T) {
+	dir := t.TempDir()
+	cpuPath := filepath.Join(dir, "cpu.out")
+	memPath := filepath.Join(dir, "mem.out")
+
+	cmd := ctlcmd.NewDefaultKbldCmd(ui.NewConfUI(ui.NewNoopLogger()))
+	cmd.SetArgs([]string{"version", "--profile", cpuPath, "--profile-mem", memPath})
+
+	require.NoError(t, cmd.Execute())
+
+	for _, path := range []string{cpuPath, memPath} {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Greater(t, info.Size(), int64(0), path)
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ImageProgress receives image processing status updates
+type ImageProgress interface {
+	Started(url, action string)
+	Finished(url string, err error)
+}
+
+// ImageContextProgress is optionally implemented by progress receivers
+// that associate state with started images (e.g. tracing span) which
+// should be available while image is processed
+type ImageContextProgress interface {
+	Context(ctx context.Context, url string) context.Context
+}
+
+type progressItem struct {
+	url      string
+	status   string
+	started  time.Time
+	finished time.Time
+}
+
+// TTYProgress shows live status table of images below regular log output.
+// It's expected to be used as the only writer to the terminal (logs are written through it).
+type TTYProgress struct {
+	out     io.Writer
+	style   TerminalStyle
+	now     func() time.Time
+	refresh time.Duration
+
+	items      map[string]*progressItem
+	drawnLines int
+	lock       sync.Mutex
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+var _ ImageProgress = &TTYProgress{}
+var _ io.Writer = &TTYProgress{}
+
+func NewTTYProgress(out io.Writer) *TTYProgress {
+	return &TTYProgress{
+		out:     out,
+		now:     time.Now,
+		refresh: 500 * time.Millisecond,
+		items:   map[string]*progressItem{},
+	}
+}
+
+// WithStyle colors statuses and fits table into terminal width
+// (lines wrapped by terminal would not be cleared on redraw)
+func (p *TTYProgress) WithStyle(style TerminalStyle) *TTYProgress {
+	p.style = style
+	return p
+}
+
+// Start periodically redraws status table to keep timings current
+func (p *TTYProgress) Start() {
+	p.stopCh = make(chan struct{})
+	p.doneCh = make(chan struct{})
+
+	go func() {
+		defer close(p.doneCh)
+
+		ticker := time.NewTicker(p.refresh)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				p.lock.Lock()
+				p.redraw(nil)
+				p.lock.Unlock()
+			case <-p.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop draws final status table and leaves it on the screen
+func (p *TTYProgress) Stop() {
+	if p.stopCh != nil {
+		close(p.stopCh)
+		<-p.doneCh
+		p.stopCh = nil
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.redraw(nil)
+	p.drawnLines = 0
+}
+
+func (p *TTYProgress) Started(url, action string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.items[url] = &progressItem{url: url, status: action, started: p.now()}
+	p.redraw(nil)
+}
+
+func (p *TTYProgress) Finished(url string, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	item, found := p.items[url]
+	if !found {
+		item = &progressItem{url: url, started: p.now()}
+		p.items[url] = item
+	}
+
+	item.finished = p.now()
+	item.status = "done"
+	if err != nil {
+		item.status = "failed"
+	}
+
+	p.redraw(nil)
+}
+
+// Write prints log output above status table
+func (p *TTYProgress) Write(data []byte) (int, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	err := p.redraw(data)
+	if err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// redraw clears previously drawn table, writes data and draws table again
+func (p *TTYProgress) redraw(data []byte) error {
+	var buf bytes.Buffer
+
+	if p.drawnLines > 0 {
+		// Move cursor to the beginning of the table and clear till the end of screen
+		buf.WriteString(fmt.Sprintf("\x1b[%dA\r\x1b[J", p.drawnLines))
+	}
+
+	buf.Write(data)
+
+	table := p.table()
+	buf.WriteString(table)
+	p.drawnLines = strings.Count(table, "\n")
+
+	_, err := p.out.Write(buf.Bytes())
+	return err
+}
+
+func (p *TTYProgress) table() string {
+	if len(p.items) == 0 {
+		return ""
+	}
+
+	const statusWidth = 10
+
+	var urls []string
+	urlWidth := utf8.RuneCountInString("Image")
+
+	for url := range p.items {
+		urls = append(urls, url)
+		if count := utf8.RuneCountInString(url); count > urlWidth {
+			urlWidth = count
+		}
+	}
+	sort.Strings(urls)
+
+	// Leave space for status and time columns (e.g. "  resolving   12.3s")
+	if width := p.style.width(); width > 0 {
+		maxURLWidth := width - len("    ") - statusWidth - len("1m23.4s") - 1
+		if maxURLWidth < len("Image") {
+			maxURLWidth = len("Image")
+		}
+		if urlWidth > maxURLWidth {
+			urlWidth = maxURLWidth
+		}
+	}
+
+	var sb strings.Builder
+	var done int
+
+	for _, url := range urls {
+		item := p.items[url]
+		if !item.finished.IsZero() {
+			done++
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("%s  %-*s  %s\n", padRight("Image", urlWidth), statusWidth, "Status", "Time"))
+
+	for _, url := range urls {
+		item := p.items[url]
+
+		end := item.finished
+		if end.IsZero() {
+			end = p.now()
+		}
+
+		status := fmt.Sprintf("%-*s", statusWidth, item.status)
+		switch item.status {
+		case "done":
+			status = p.style.green(status)
+		case "failed":
+			status = p.style.red(status)
+		}
+
+		sb.WriteString(fmt.Sprintf("%s  %s  %s\n", padRight(p.style.truncate(url, urlWidth), urlWidth), status,
+			end.Sub(item.started).Round(100*time.Millisecond)))
+	}
+
+	sb.WriteString(fmt.Sprintf("%d/%d images processed\n", done, len(urls)))
+
+	return sb.String()
+}
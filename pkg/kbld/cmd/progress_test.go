@@ -0,0 +1,75 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestTTYProgress(t *testing.T) {
+	var buf bytes.Buffer
+
+	progress := ctlcmd.NewTTYProgress(&buf)
+
+	progress.Started("nginx:1.17", "resolving")
+	progress.Started("app", "building")
+
+	buf.Reset()
+	progress.Write([]byte("app | starting build\n"))
+
+	// Previously drawn table (header, two images, summary) is cleared before log output
+	out := buf.String()
+	assert.True(t, strings.HasPrefix(out, fmt.Sprintf("\x1b[%dA\r\x1b[J", 4)+"app | starting build\n"), "output: %q", out)
+	assert.Contains(t, out, "building")
+	assert.Contains(t, out, "0/2 images processed\n")
+
+	progress.Finished("app", nil)
+	progress.Finished("nginx:1.17", fmt.Errorf("not found"))
+
+	buf.Reset()
+	progress.Stop()
+
+	out = buf.String()
+	assert.Regexp(t, `app\s+done`, out)
+	assert.Regexp(t, `nginx:1.17\s+failed`, out)
+	assert.Contains(t, out, "2/2 images processed\n")
+}
+
+func TestTTYProgressWithStyle(t *testing.T) {
+	var buf bytes.Buffer
+
+	style := ctlcmd.TerminalStyle{Color: true, Unicode: true, Width: func() int { return 40 }}
+	progress := ctlcmd.NewTTYProgress(&buf).WithStyle(style)
+
+	progress.Started("registry.example.com/org/very-long-image-name:v1.2.3", "resolving")
+	progress.Finished("registry.example.com/org/very-long-image-name:v1.2.3", nil)
+	progress.Started("app", "building")
+
+	buf.Reset()
+	progress.Stop()
+
+	// Lines fit into 40 columns (url column is truncated to 18 characters)
+	out := strings.TrimPrefix(buf.String(), fmt.Sprintf("\x1b[%dA\r\x1b[J", 4))
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	assert.Equal(t, []string{
+		"Image               Status      Time",
+		"app                 building    0s",
+		"registry.example.…  \x1b[32mdone      \x1b[0m  0s",
+		"1/2 images processed",
+	}, lines)
+}
+
+func TestColorAllowed(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	assert.True(t, ctlcmd.ColorAllowed())
+
+	t.Setenv("NO_COLOR", "1")
+	assert.False(t, ctlcmd.ColorAllowed())
+}
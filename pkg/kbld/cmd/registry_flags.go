@@ -4,27 +4,67 @@
 package cmd
 
 import (
+	"time"
+
 	"github.com/spf13/cobra"
 	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
 )
 
 type RegistryFlags struct {
 	CACertPaths []string
 	VerifyCerts bool
 	Insecure    bool
+	HeadOnly    bool
+
+	CredentialRefreshInterval time.Duration
+
+	MaxBandwidth string
+	AuditLog     string
+
+	// pushManifest is shared by all registries created from flags
+	// (e.g. by relocate for building and copying images)
+	pushManifest *ctlreg.PushManifest
 }
 
 func (s *RegistryFlags) Set(cmd *cobra.Command) {
 	cmd.Flags().StringSliceVar(&s.CACertPaths, "registry-ca-cert-path", nil, "Add CA certificates for registry API (format: /tmp/foo) (can be specified multiple times)")
 	cmd.Flags().BoolVar(&s.VerifyCerts, "registry-verify-certs", true, "Set whether to verify server's certificate chain and host name")
 	cmd.Flags().BoolVar(&s.Insecure, "registry-insecure", false, "Allow the use of http when interacting with registries")
+	cmd.Flags().BoolVar(&s.HeadOnly, "registry-head-only", false, "Resolve tags to digests using only HEAD requests (reduces Docker Hub rate limit usage)")
+	cmd.Flags().DurationVar(&s.CredentialRefreshInterval, "registry-credential-refresh-interval", 5*time.Minute, "Set how long registry credentials (e.g. from credential helpers) are used before being resolved again")
+	cmd.Flags().StringVar(&s.AuditLog, "registry-audit-log", "", "Append record of each push and tag write to file (format: JSON lines)")
 }
 
-func (s *RegistryFlags) AsRegistryOpts() ctlreg.Opts {
-	return ctlreg.Opts{
+// SetBandwidth adds bandwidth limiting flag for commands that transfer image layers
+func (s *RegistryFlags) SetBandwidth(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&s.MaxBandwidth, "max-bandwidth", "", "Limit combined registry transfer rate (format: 50MiB/s, 500KB/s)")
+}
+
+func (s *RegistryFlags) AsRegistryOpts() (ctlreg.Opts, error) {
+	opts := ctlreg.Opts{
 		CACertPaths:   s.CACertPaths,
 		VerifyCerts:   s.VerifyCerts,
 		Insecure:      s.Insecure,
+		HeadOnly:      s.HeadOnly,
 		EnvAuthPrefix: "KBLD_REGISTRY",
+
+		CredentialRefreshInterval: s.CredentialRefreshInterval,
 	}
+
+	if len(s.MaxBandwidth) > 0 {
+		maxBandwidth, err := util.ParseBandwidth(s.MaxBandwidth)
+		if err != nil {
+			return ctlreg.Opts{}, err
+		}
+		opts.MaxBandwidth = maxBandwidth
+	}
+
+	if len(s.AuditLog) > 0 {
+		opts.AuditLog = ctlreg.NewAuditLog(s.AuditLog)
+	}
+
+	opts.PushManifest = s.pushManifest
+
+	return opts, nil
 }
@@ -0,0 +1,32 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestRegistryFlagsCredentialRefreshInterval(t *testing.T) {
+	parse := func(t *testing.T, args ...string) time.Duration {
+		var flags ctlcmd.RegistryFlags
+
+		cmd := &cobra.Command{}
+		flags.Set(cmd)
+		require.NoError(t, cmd.Flags().Parse(args))
+
+		opts, err := flags.AsRegistryOpts()
+		require.NoError(t, err)
+
+		return opts.CredentialRefreshInterval
+	}
+
+	assert.Equal(t, 5*time.Minute, parse(t))
+	assert.Equal(t, 30*time.Second, parse(t, "--registry-credential-refresh-interval=30s"))
+}
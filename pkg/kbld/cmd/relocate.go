@@ -5,12 +5,12 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/cppforlife/go-cli-ui/ui"
 	regname "github.com/google/go-containerregistry/pkg/name"
 	"github.com/spf13/cobra"
 	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/imageutils/convert"
 	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
 	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
 	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
@@ -22,11 +22,18 @@ import (
 type RelocateOptions struct {
 	ui ui.UI
 
-	FileFlags     FileFlags
-	RegistryFlags RegistryFlags
-	Repository    string
-	LockOutput    string
-	Concurrency   int
+	FileFlags      FileFlags
+	RegistryFlags  RegistryFlags
+	LoggerFlags    LoggerFlags
+	Repository     string
+	LockOutput     string
+	Concurrency    int
+	AllowedToBuild bool
+
+	IncludeNonDistributable bool
+	Platforms               []string
+	ConvertLayers           string
+	GCManifestOutput        string
 }
 
 func NewRelocateOptions(ui ui.UI) *RelocateOptions {
@@ -37,29 +44,53 @@ func NewRelocateCmd(o *RelocateOptions) *cobra.Command {
 	cmd := &cobra.Command{
 		Use: "relocate",
 		Long: `
-Command "relocate" is deprecated, please use 'imgpkg copy', learn more in https://carvel.dev/imgpkg/docs/latest/commands/#copy
+Resolve (and build, if configured) images referenced by resources,
+copy them into given repository and update references to copied images
 
-Relocate images between two registries
+(To copy images of imgpkg bundles use 'imgpkg copy', learn more in https://carvel.dev/imgpkg/docs/latest/commands/#copy)
 `,
-		Short:  "Relocate images between two registries",
-		Hidden: true,
-		RunE:   func(_ *cobra.Command, _ []string) error { return o.Run() },
+		Short: "Resolve images and relocate them into given repository",
+		RunE:  func(_ *cobra.Command, _ []string) error { return o.Run() },
 	}
 	o.FileFlags.Set(cmd)
 	o.RegistryFlags.Set(cmd)
+	o.LoggerFlags.Set(cmd)
+	o.RegistryFlags.SetBandwidth(cmd)
 	cmd.Flags().StringVarP(&o.Repository, "repository", "r", "", "Import images into given image repository (e.g. docker.io/dkalinin/my-project)")
 	cmd.Flags().StringVar(&o.LockOutput, "lock-output", "", "File path to emit configuration with resolved image references")
+	cmd.Flags().StringVar(&o.GCManifestOutput, "gc-manifest-output", "", "File path to emit list of pushed images and tags (used by 'kbld gc')")
 	cmd.Flags().IntVar(&o.Concurrency, "concurrency", 5, "Set maximum number of concurrent imports")
+	cmd.Flags().BoolVar(&o.AllowedToBuild, "build", false, "Allow building of images before relocation")
+	cmd.Flags().BoolVar(&o.IncludeNonDistributable, "include-non-distributable", false, "Copy non-distributable (foreign) layers (only when licensing allows)")
+	cmd.Flags().StringVar(&o.ConvertLayers, "convert-layers", "", "Convert layers for lazy pulling while copying (one of: estargz, zstd:chunked)")
+	cmd.Flags().StringSliceVar(&o.Platforms, "platform", nil, "Only import images of given platforms from image indexes (format: os/arch[/variant][:os.version], can be specified multiple times)")
 	return cmd
 }
 
 func (o *RelocateOptions) Run() error {
-	logger := ctllog.NewLogger(os.Stderr)
-	warningLogger := logger.NewPrefixedWriter("Warning: ")
-	err := warningLogger.WriteStr(`Command "relocate" is deprecated, please use 'imgpkg copy', learn more in https://carvel.dev/imgpkg/docs/latest/commands/#copy`)
+	if len(o.GCManifestOutput) == 0 {
+		return o.run()
+	}
+
+	// Record built and copied images even if relocation fails part way
+	o.RegistryFlags.pushManifest = ctlreg.NewPushManifest()
+
+	err := o.run()
+
+	manifestErr := WriteGCManifest(o.GCManifestOutput, o.RegistryFlags.pushManifest)
+	if manifestErr != nil && err == nil {
+		err = manifestErr
+	}
+
+	return err
+}
+
+func (o *RelocateOptions) run() error {
+	logger, closeLogger, err := o.LoggerFlags.NewLogger()
 	if err != nil {
 		return err
 	}
+	defer closeLogger()
 
 	// basic checks
 	if len(o.Repository) == 0 {
@@ -78,6 +109,11 @@ func (o *RelocateOptions) Run() error {
 		return err
 	}
 
+	rs, resolvedImages, err := o.resolveResources(rs, conf, logger)
+	if err != nil {
+		return err
+	}
+
 	foundImages, err := FindImages(rs, conf)
 	if err != nil {
 		return err
@@ -88,19 +124,51 @@ func (o *RelocateOptions) Run() error {
 		return fmt.Errorf("Building import repository ref: %s", err)
 	}
 
-	dstRegistry, err := ctlreg.NewRegistry(o.RegistryFlags.AsRegistryOpts())
+	registryOpts, err := o.RegistryFlags.AsRegistryOpts()
+	if err != nil {
+		return err
+	}
+
+	registryOpts.IncludeNonDistributable = o.IncludeNonDistributable
+
+	dstRegistry, err := ctlreg.NewRegistry(registryOpts)
+	if err != nil {
+		return err
+	}
+
+	var converter *convert.Converter
+
+	if len(o.ConvertLayers) > 0 {
+		format, err := convert.ParseFormat(o.ConvertLayers)
+		if err != nil {
+			return err
+		}
+
+		converter, err = convert.NewConverter(format)
+		if err != nil {
+			return err
+		}
+		defer converter.Cleanup()
+	}
+
+	platforms, err := NewPlatformSelections(o.Platforms)
 	if err != nil {
 		return err
 	}
 
-	imageSet := ImageSet{o.Concurrency, prefixedLogger}
+	imageSet := ImageSet{o.Concurrency, prefixedLogger, o.IncludeNonDistributable, converter, platforms}
 
 	importedImages, err := imageSet.Relocate(foundImages, importRepo, dstRegistry)
 	if err != nil {
 		return err
 	}
 
-	err = o.emitLockOutput(conf, importedImages)
+	importedImages, err = SignImages(conf, importedImages, dstRegistry, logger)
+	if err != nil {
+		return err
+	}
+
+	err = o.emitLockOutput(conf, resolvedImages, importedImages)
 	if err != nil {
 		return err
 	}
@@ -120,6 +188,40 @@ func (o *RelocateOptions) Run() error {
 	return nil
 }
 
+// resolveResources returns resources with image references resolved to digests
+// (building images if configured) so that tagged references could be relocated
+func (o *RelocateOptions) resolveResources(rs []ctlres.Resource, conf ctlconf.Conf,
+	logger ctllog.Logger) ([]ctlres.Resource, *ProcessedImages, error) {
+
+	resolveOpts := &ResolveOptions{
+		ui:                 o.ui,
+		RegistryFlags:      o.RegistryFlags,
+		AllowedToBuild:     o.AllowedToBuild,
+		BuildConcurrency:   1,
+		ResolveConcurrency: o.Concurrency,
+		// Annotations would refer to images before relocation
+		ImagesAnnotation: false,
+	}
+
+	resBss, resolvedImages, err := resolveOpts.resolveConfiguredResources(
+		rs, conf, &logger, logger.NewPrefixedWriter("resolve | "))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Resolving images: %s", err)
+	}
+
+	var resolvedRs []ctlres.Resource
+
+	for _, resBs := range resBss {
+		resRs, err := ctlres.NewResourcesFromBytes(resBs)
+		if err != nil {
+			return nil, nil, err
+		}
+		resolvedRs = append(resolvedRs, resRs...)
+	}
+
+	return resolvedRs, resolvedImages, nil
+}
+
 func (o *RelocateOptions) updateRefsInResources(
 	nonConfigRs []ctlres.Resource, conf ctlconf.Conf,
 	resolvedImages *ProcessedImages) ([][]byte, error) {
@@ -156,7 +258,8 @@ func (o *RelocateOptions) updateRefsInResources(
 	return resBss, nil
 }
 
-func (o *RelocateOptions) emitLockOutput(conf ctlconf.Conf, resolvedImages *ProcessedImages) error {
+// emitLockOutput maps references specified in resources (e.g. tags) to relocated images
+func (o *RelocateOptions) emitLockOutput(conf ctlconf.Conf, resolvedImages, importedImages *ProcessedImages) error {
 	if len(o.LockOutput) == 0 {
 		return nil
 	}
@@ -165,33 +268,59 @@ func (o *RelocateOptions) emitLockOutput(conf ctlconf.Conf, resolvedImages *Proc
 	c.MinimumRequiredVersion = version.Version
 	c.SearchRules = conf.SearchRulesWithoutDefaults()
 
+	// Preresolved images are found by several loops below, hence
+	// only first override for each reference is kept
+	addOverride := func(override ctlconf.ImageOverride) {
+		for _, addedOverride := range c.Overrides {
+			if addedOverride.ImageRef == override.ImageRef {
+				return
+			}
+		}
+		c.Overrides = append(c.Overrides, override)
+	}
+
 	for _, override := range conf.ImageOverrides() {
 		if override.Preresolved {
-			img, found := resolvedImages.FindByURL(UnprocessedImageURL{override.NewImage})
+			img, found := importedImages.FindByURL(UnprocessedImageURL{override.NewImage})
 			if !found {
 				return fmt.Errorf("Expected to find imported image for '%s'", override.NewImage)
 			}
 
-			c.Overrides = append(c.Overrides, ctlconf.ImageOverride{
-				ImageRef:    override.ImageRef,
-				NewImage:    img.URL,
-				Preresolved: true,
+			addOverride(ctlconf.ImageOverride{
+				ImageRef:     override.ImageRef,
+				NewImage:     img.URL,
+				Preresolved:  true,
+				ImageOrigins: signedOrigins(img.Origins),
 			})
 		}
 	}
 
-	// TODO should we dedup overrides?
 	for _, urlImagePair := range resolvedImages.All() {
-		c.Overrides = append(c.Overrides, ctlconf.ImageOverride{
+		img, found := importedImages.FindByURL(UnprocessedImageURL{urlImagePair.Image.URL})
+		if !found {
+			return fmt.Errorf("Expected to find imported image for '%s'", urlImagePair.Image.URL)
+		}
+
+		addOverride(ctlconf.ImageOverride{
 			ImageRef: ctlconf.ImageRef{
 				Image: urlImagePair.UnprocessedImageURL.URL,
 			},
-			NewImage:    urlImagePair.Image.URL,
-			Preresolved: true,
+			NewImage:     img.URL,
+			Preresolved:  true,
+			ImageOrigins: signedOrigins(img.Origins),
 		})
 	}
 
-	c.Overrides = ctlconf.UniqueImageOverrides(c.Overrides)
+	for _, urlImagePair := range importedImages.All() {
+		addOverride(ctlconf.ImageOverride{
+			ImageRef: ctlconf.ImageRef{
+				Image: urlImagePair.UnprocessedImageURL.URL,
+			},
+			NewImage:     urlImagePair.Image.URL,
+			Preresolved:  true,
+			ImageOrigins: signedOrigins(urlImagePair.Image.Origins),
+		})
+	}
 
 	return c.WriteToFile(o.LockOutput)
 }
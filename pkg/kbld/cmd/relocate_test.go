@@ -0,0 +1,162 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	"sigs.k8s.io/yaml"
+)
+
+func TestRelocateResolvesAndRelocates(t *testing.T) {
+	reg := newFakeRegistry(t)
+
+	appDigestRef := reg.PushImage(t, reg.Host+"/src/app:1.0")
+	sidecarDigestRef := reg.PushImage(t, reg.Host+"/src/sidecar:2.0")
+	appDigest := strings.Split(appDigestRef, "@")[1]
+	sidecarDigest := strings.Split(sidecarDigestRef, "@")[1]
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app.yml"), []byte(fmt.Sprintf(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: %s/src/app:1.0
+  - image: %s
+`, reg.Host, sidecarDigestRef)), 0600))
+
+	lockPath := filepath.Join(t.TempDir(), "lock.yml")
+
+	var stdout bytes.Buffer
+
+	cmd := ctlcmd.NewRelocateCmd(ctlcmd.NewRelocateOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+	cmd.SetArgs([]string{"-f", dir, "--repository", reg.Host + "/dst/apps", "--registry-insecure", "--lock-output", lockPath})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	require.NoError(t, cmd.Execute())
+
+	assert.Equal(t, fmt.Sprintf(`---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: %[1]s/dst/apps@%[2]s
+  - image: %[1]s/dst/apps@%[3]s
+`, reg.Host, appDigest, sidecarDigest), stdout.String())
+
+	assert.ElementsMatch(t, []string{appDigest, sidecarDigest}, reg.Digests("dst/apps"))
+
+	lockBs, err := os.ReadFile(lockPath)
+	require.NoError(t, err)
+
+	var lockConfig ctlconf.Config
+	require.NoError(t, yaml.Unmarshal(lockBs, &lockConfig))
+
+	newImages := map[string]string{}
+	for _, override := range lockConfig.Overrides {
+		assert.True(t, override.Preresolved)
+		newImages[override.Image] = override.NewImage
+	}
+
+	// Tagged reference given in resources is locked to relocated image
+	assert.Equal(t, reg.Host+"/dst/apps@"+appDigest, newImages[reg.Host+"/src/app:1.0"])
+	assert.Equal(t, reg.Host+"/dst/apps@"+appDigest, newImages[appDigestRef])
+	assert.Equal(t, reg.Host+"/dst/apps@"+sidecarDigest, newImages[sidecarDigestRef])
+}
+
+func TestRelocateLocksPreresolvedImagesOnce(t *testing.T) {
+	reg := newFakeRegistry(t)
+
+	appDigestRef := reg.PushImage(t, reg.Host+"/src/app:1.0")
+	appDigest := strings.Split(appDigestRef, "@")[1]
+
+	inputPath := filepath.Join(t.TempDir(), "app.yml")
+	require.NoError(t, os.WriteFile(inputPath, []byte(fmt.Sprintf(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: app
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+overrides:
+- image: app
+  newImage: %s
+  preresolved: true
+`, appDigestRef)), 0600))
+
+	lockPath := filepath.Join(t.TempDir(), "lock.yml")
+
+	cmd := ctlcmd.NewRelocateCmd(ctlcmd.NewRelocateOptions(ui.NewWriterUI(io.Discard, io.Discard, ui.NewNoopLogger())))
+	cmd.SetArgs([]string{"-f", inputPath, "--repository", reg.Host + "/dst/apps", "--registry-insecure", "--lock-output", lockPath})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	require.NoError(t, cmd.Execute())
+
+	lockBs, err := os.ReadFile(lockPath)
+	require.NoError(t, err)
+
+	var lockConfig ctlconf.Config
+	require.NoError(t, yaml.Unmarshal(lockBs, &lockConfig))
+
+	var images []string
+	for _, override := range lockConfig.Overrides {
+		assert.Equal(t, reg.Host+"/dst/apps@"+appDigest, override.NewImage)
+		images = append(images, override.Image)
+	}
+
+	// Preresolved image is found via override, resources and imported images
+	assert.Equal(t, []string{"app", appDigestRef}, images)
+}
+
+func TestRelocateDoesNotBuildByDefault(t *testing.T) {
+	reg := newFakeRegistry(t)
+
+	inputPath := filepath.Join(t.TempDir(), "app.yml")
+	require.NoError(t, os.WriteFile(inputPath, []byte(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: app
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+sources:
+- image: app
+  path: .
+`), 0600))
+
+	cmd := ctlcmd.NewRelocateCmd(ctlcmd.NewRelocateOptions(ui.NewWriterUI(io.Discard, io.Discard, ui.NewNoopLogger())))
+	cmd.SetArgs([]string{"-f", inputPath, "--repository", reg.Host + "/dst/apps", "--registry-insecure"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	err := cmd.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Building of images is disallowed (tried to build 'app' because a source was configured for it)")
+}
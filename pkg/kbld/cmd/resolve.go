@@ -4,38 +4,99 @@
 package cmd
 
 import (
+	"crypto"
 	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"carvel.dev/imgpkg/pkg/imgpkg/lockconfig"
 	"github.com/cppforlife/go-cli-ui/ui"
+	uitable "github.com/cppforlife/go-cli-ui/ui/table"
 	"github.com/spf13/cobra"
 	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
 	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
 	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlmetrics "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/metrics"
 	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
 	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+	ctlscan "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/scan"
 	ctlser "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/search"
+	ctlsign "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/signing"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/tracing"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
 	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/version"
+	"golang.org/x/term"
 	"sigs.k8s.io/yaml"
 )
 
 type ResolveOptions struct {
 	ui ui.UI
 
-	FileFlags         FileFlags
-	RegistryFlags     RegistryFlags
-	AllowedToBuild    bool
-	BuildConcurrency  int
-	ImagesAnnotation  bool
-	OriginsAnnotation bool
-	ImageMapFile      string
-	LockOutput        string
-	ImgpkgLockOutput  string
-	UnresolvedInspect bool
-	Platform          string
+	FileFlags          FileFlags
+	CRDFlags           CRDFlags
+	FilterFlags        FilterFlags
+	RegistryFlags      RegistryFlags
+	LoggerFlags        LoggerFlags
+	AllowedToBuild     bool
+	BuildConcurrency   int
+	BuildTimeout       time.Duration
+	ResolveConcurrency int
+	ImagesAnnotation   bool
+	OriginsAnnotation  bool
+	ExpandYAMLAliases  bool
+	ImageMapFile       string
+	LockOutput         string
+	ImgpkgLockOutput   string
+	GCManifestOutput   string
+	UnresolvedInspect  bool
+	Platform           string
+	ClusterProfile     string
+	DigestCache        string
+
+	VerifyTransparencyLog    bool
+	TransparencyLogPublicKey string
+
+	Watch         bool
+	WatchInterval time.Duration
+	WatchOutput   string
+
+	OutputDir string
+	Progress  string
+	DryRun    bool
+	Stream    bool
+
+	ReportPath string
+	StateFile  string
+	Resume     bool
+
+	KustomizeImagesOutput string
+	ValuesOutput          string
+	ValuesFormat          string
+	ValuesKey             string
+
+	CIAnnotations string
+	DaemonSocket  string
+
+	// Strict lists warning kinds that fail resolution
+	Strict []string
+
+	progress      ImageProgress
+	ciAnnotations *GitHubAnnotations
+	tracer        *tracing.Tracer
+	metrics       *ctlmetrics.Metrics
+
+	// resourceFilter selects resources that are processed
+	resourceFilter ResourceFilter
+	// resourcePaths maps resources to files they were read from
+	resourcePaths map[ctlres.Resource]string
+	// companionPaths are file paths of resources that
+	// produced companion ConfigMaps (in output order)
+	companionPaths []string
+	registry       *ctlreg.Registry
+	// sharedDigestCache is used instead of DigestCache (e.g. by daemon)
+	sharedDigestCache *ctlimg.DigestCache
 }
 
 func NewResolveOptions(ui ui.UI) *ResolveOptions {
@@ -49,16 +110,46 @@ func NewResolveCmd(o *ResolveOptions) *cobra.Command {
 		RunE:  func(_ *cobra.Command, _ []string) error { return o.Run() },
 	}
 	o.FileFlags.Set(cmd)
+	o.CRDFlags.Set(cmd)
+	o.FilterFlags.Set(cmd)
 	o.RegistryFlags.Set(cmd)
+	o.RegistryFlags.SetBandwidth(cmd)
+	o.LoggerFlags.Set(cmd)
 	cmd.Flags().BoolVar(&o.AllowedToBuild, "build", true, "Allow building of images")
 	cmd.Flags().IntVar(&o.BuildConcurrency, "build-concurrency", 4, "Set maximum number of concurrent builds")
+	cmd.Flags().DurationVar(&o.BuildTimeout, "build-timeout", 0, "Terminate builds taking longer than duration (e.g. 15m) unless source specifies its own timeout (0 disables)")
+	cmd.Flags().IntVar(&o.ResolveConcurrency, "resolve-concurrency", 10, "Set maximum number of images resolved concurrently (in addition to builds)")
 	cmd.Flags().BoolVar(&o.ImagesAnnotation, "images-annotation", true, "Annotate resources with images annotation")
 	cmd.Flags().BoolVar(&o.OriginsAnnotation, "origins-annotation", true, "Include origins annotation")
+	cmd.Flags().BoolVar(&o.ExpandYAMLAliases, "expand-yaml-aliases", false, "Expand YAML anchors and aliases in updated resources instead of keeping them")
 	cmd.Flags().StringVar(&o.ImageMapFile, "image-map-file", "", "Set image map file (/cnab/app/relocation-mapping.json in CNAB)")
 	cmd.Flags().StringVar(&o.LockOutput, "lock-output", "", "File path to emit configuration with resolved image references")
 	cmd.Flags().StringVar(&o.ImgpkgLockOutput, "imgpkg-lock-output", "", "File path to emit images lockfile with resolved image references")
+	cmd.Flags().StringVar(&o.GCManifestOutput, "gc-manifest-output", "", "File path to emit list of pushed images and tags (used by 'kbld gc')")
+	cmd.Flags().StringVar(&o.KustomizeImagesOutput, "kustomize-images-output", "", "File path to emit Kustomize component setting resolved images (entries are compatible with Flux Kustomization spec.images)")
+	cmd.Flags().StringVar(&o.ValuesOutput, "values-output", "", "File path to emit data values with resolved image references (under 'images' key)")
+	cmd.Flags().StringVar(&o.ValuesFormat, "values-format", ValuesFormatYtt, "Set format of values output (ytt, yaml)")
+	cmd.Flags().StringVar(&o.ValuesKey, "values-key", ValuesKeyURL, "Set how images are keyed in values output (url, name, basename)")
 	cmd.Flags().BoolVar(&o.UnresolvedInspect, "unresolved-inspect", false, "List image references found in inputs")
 	cmd.Flags().StringVar(&o.Platform, "platform", "", "Apply platform selection to image indexes")
+	cmd.Flags().StringVar(&o.ClusterProfile, "cluster-profile", "", "Apply defaults (e.g. platform selection) of cluster profile defined in configuration")
+	cmd.Flags().StringVar(&o.DigestCache, "digest-cache", "", "Set file path to cache platform selections of image indexes across runs (auto uses user cache directory; disabled when empty)")
+	cmd.Flags().BoolVar(&o.VerifyTransparencyLog, "verify-transparency-log", false, "Require preresolved images (e.g. from lock files) to have signatures included in transparency log")
+	cmd.Flags().StringVar(&o.TransparencyLogPublicKey, "transparency-log-public-key", "", "Set file path of PEM encoded transparency log public key used to verify its checkpoints (required with --verify-transparency-log)")
+	cmd.Flags().BoolVar(&o.DryRun, "dry-run", false, "Print which images would be built, pushed and resolved without doing so")
+	cmd.Flags().StringVar(&o.Progress, "progress", "auto", "Show live image status table (auto, tty, plain); auto enables it when stderr is a terminal")
+	cmd.Flags().BoolVar(&o.Stream, "stream", false, "Process inputs one document at a time to keep memory usage bounded for very large inputs (inputs are read several times)")
+	cmd.Flags().StringVar(&o.OutputDir, "output-dir", "", "Directory to write resources to, mirroring input file paths, instead of stdout")
+	cmd.Flags().StringVar(&o.CIAnnotations, "ci-annotations", "", "Emit CI annotations for errors and warnings, and write job summary (github)")
+	cmd.Flags().StringVar(&o.ReportPath, "report-path", "", "File path to write JSON report summarizing image actions, timings and transfers")
+	cmd.Flags().StringVar(&o.StateFile, "state-file", "", "File path to record processed images to (used with --resume)")
+	cmd.Flags().BoolVar(&o.Resume, "resume", false, "Reuse images recorded in state file by previous run (only unchanged images are reused)")
+	cmd.Flags().BoolVar(&o.Watch, "watch", false, "Watch input files and source paths, and resolve again on change")
+	cmd.Flags().DurationVar(&o.WatchInterval, "watch-interval", time.Second, "Set interval for checking watched files for changes")
+	cmd.Flags().StringVar(&o.WatchOutput, "watch-output", "", "File path to write output to on each change in watch mode (stdout when empty)")
+	cmd.Flags().StringSliceVar(&o.Strict, "strict", nil, "Fail when there are warnings of given kinds (all, unmatched-search-rule, unused-override, unused-source, unused-destination, skipped-image, deprecated-config) (can be specified multiple times)")
+	cmd.Flags().Lookup("strict").NoOptDefVal = warningKindsAll
+	cmd.Flags().StringVar(&o.DaemonSocket, "daemon-socket", os.Getenv(daemonSocketEnvVar), "Delegate resolution to 'kbld daemon' listening on unix socket (only inputs and platform are forwarded)")
 	return cmd
 }
 
@@ -66,14 +157,110 @@ func (o *ResolveOptions) Run() error {
 	if o.ImgpkgLockOutput != "" && o.LockOutput != "" {
 		return fmt.Errorf("Can only output one lockfile type, please provide only one of '--lock-output' or '--imgpkg-lock-output'")
 	}
-	logger := ctllog.NewLogger(os.Stderr)
+	if o.Stream && o.Watch {
+		return util.NewCategorizedError(util.ErrorCategoryConfig, fmt.Errorf("Expected '--stream' to not be used with '--watch'"))
+	}
+	if o.VerifyTransparencyLog && len(o.TransparencyLogPublicKey) == 0 {
+		return util.NewCategorizedError(util.ErrorCategoryConfig, fmt.Errorf("Expected '--transparency-log-public-key' to be specified when using '--verify-transparency-log'"))
+	}
+	if o.Resume && len(o.StateFile) == 0 {
+		return util.NewCategorizedError(util.ErrorCategoryConfig, fmt.Errorf("Expected '--state-file' to be specified when using '--resume'"))
+	}
+	if len(o.ValuesOutput) > 0 {
+		err := o.valuesOutput().Validate()
+		if err != nil {
+			return util.NewCategorizedError(util.ErrorCategoryConfig, err)
+		}
+	}
+	if err := ValidateWarningKinds(o.Strict); err != nil {
+		return util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+	resourceFilter, err := o.FilterFlags.ResourceFilter()
+	if err != nil {
+		return util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+	o.resourceFilter = resourceFilter
+	if len(o.DaemonSocket) > 0 {
+		return o.resolveViaDaemon()
+	}
+	if len(o.GCManifestOutput) > 0 {
+		// Collected across watch iterations since each may push images
+		o.RegistryFlags.pushManifest = ctlreg.NewPushManifest()
+	}
+	switch o.CIAnnotations {
+	case "":
+	case CIAnnotationsGitHub:
+		if o.Watch {
+			return util.NewCategorizedError(util.ErrorCategoryConfig, fmt.Errorf("Expected '--ci-annotations' to not be used with '--watch'"))
+		}
+		o.ciAnnotations = NewGitHubAnnotations(os.Stderr, os.Getenv("GITHUB_STEP_SUMMARY"))
+	default:
+		return util.NewCategorizedError(util.ErrorCategoryConfig, fmt.Errorf("Unknown CI annotations '%s' (supported: %s)", o.CIAnnotations, CIAnnotationsGitHub))
+	}
+
+	ttyProgress, err := o.ttyProgress()
+	if err != nil {
+		return err
+	}
+
+	var logger ctllog.Logger
+	var closeLogger func()
+
+	if ttyProgress != nil {
+		ttyProgress.Start()
+		defer ttyProgress.Stop()
+
+		o.progress = ttyProgress
+		logger, closeLogger, err = o.LoggerFlags.NewLoggerWithStderr(ttyProgress)
+	} else if o.ciAnnotations != nil && o.LoggerFlags.UsesTerminal() {
+		logger, closeLogger, err = o.LoggerFlags.NewLoggerWithStderr(o.ciAnnotations.Writer(os.Stderr))
+	} else {
+		logger, closeLogger, err = o.LoggerFlags.NewLogger()
+	}
+	if err != nil {
+		return err
+	}
+	defer closeLogger()
+
 	prefixedLogger := logger.NewPrefixedWriter("resolve | ")
 
-	resBss, err := o.ResolveResources(&logger, prefixedLogger)
+	if o.Watch {
+		return o.runWatch(&logger, prefixedLogger)
+	}
+
+	o.tracer, err = tracing.NewTracerFromEnv("kbld resolve")
+	if err != nil {
+		return util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+
+	var resBss [][]byte
+	var resPaths []string
+
+	if o.Stream {
+		// Resources are printed while being processed
+		err = o.resolveStreaming(&logger, prefixedLogger)
+	} else {
+		resBss, resPaths, err = o.resolveResources(&logger, prefixedLogger)
+	}
+
+	// Failing to export traces should not fail resolution
+	if traceErr := o.tracer.Shutdown(err); traceErr != nil {
+		prefixedLogger.WriteStr("warning: %s\n", traceErr)
+	}
+	if o.ciAnnotations != nil {
+		annErr := o.ciAnnotations.Finish(err, o.FileFlags.Files)
+		if annErr != nil && err == nil {
+			err = annErr
+		}
+	}
 	if err != nil {
 		return err
 	}
 
+	if len(o.OutputDir) > 0 {
+		return NewOutputDir(o.OutputDir).Write(resBss, resPaths)
+	}
+
 	// Print all resources as one YAML stream
 	for _, resBs := range resBss {
 		resBs = append([]byte("---\n"), resBs...)
@@ -83,51 +270,284 @@ func (o *ResolveOptions) Run() error {
 	return nil
 }
 
+func (o *ResolveOptions) ttyProgress() (*TTYProgress, error) {
+	switch o.Progress {
+	case "plain":
+		return nil, nil
+	case "tty":
+		return NewTTYProgress(os.Stderr).WithStyle(NewTerminalStyle(os.Stderr)), nil
+	case "auto":
+		if o.LoggerFlags.UsesTerminal() && term.IsTerminal(int(os.Stderr.Fd())) {
+			return NewTTYProgress(os.Stderr).WithStyle(NewTerminalStyle(os.Stderr)), nil
+		}
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("Expected progress to be one of auto, tty or plain, but was '%s'", o.Progress)
+	}
+}
+
+// runWatch resolves resources every time input files or source paths change.
+// Resolution errors are reported but do not stop watching.
+func (o *ResolveOptions) runWatch(logger *ctllog.Logger, pLogger *ctllog.PrefixWriter) error {
+	for _, file := range o.FileFlags.Files {
+		if file == "-" {
+			return fmt.Errorf("Expected files to not include stdin ('-') in watch mode")
+		}
+	}
+
+	watcher := util.NewWatcher(o.WatchInterval)
+
+	fingerprint, err := watcher.Fingerprint(o.watchedPaths())
+	if err != nil {
+		return err
+	}
+
+	for {
+		resBss, resPaths, err := o.resolveResources(logger, pLogger)
+		if err == nil {
+			if len(o.OutputDir) > 0 {
+				err = NewOutputDir(o.OutputDir).Write(resBss, resPaths)
+			} else {
+				err = o.writeWatchOutput(resBss)
+			}
+		}
+		if err != nil {
+			pLogger.WriteStr("error: %s\n", err)
+		}
+
+		pLogger.WriteStr("watching for changes\n")
+
+		fingerprint, err = watcher.WaitForChange(o.watchedPaths, fingerprint)
+		if err != nil {
+			return err
+		}
+
+		pLogger.WriteStr("detected changes, resolving again\n")
+	}
+}
+
+// watchedPaths returns local input files and configured source paths
+func (o *ResolveOptions) watchedPaths() []string {
+	var paths []string
+
+	for _, file := range o.FileFlags.Files {
+		if !strings.HasPrefix(file, "http://") && !strings.HasPrefix(file, "https://") {
+			paths = append(paths, file)
+		}
+	}
+
+	// Configuration may be invalid while being edited; inputs will still be watched
+	_, conf, err := o.FileFlags.ResourcesAndConfig()
+	if err == nil {
+		for _, src := range conf.Sources() {
+			// Watch common parent of paths that depend on matched image
+			path, _, _ := strings.Cut(src.Path, "$")
+			if len(path) == 0 {
+				path = "."
+			}
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+func (o *ResolveOptions) writeWatchOutput(resBss [][]byte) error {
+	var output []byte
+	for _, resBs := range resBss {
+		output = append(output, append([]byte("---\n"), resBs...)...)
+	}
+
+	if len(o.WatchOutput) == 0 {
+		o.ui.PrintBlock(output)
+		return nil
+	}
+
+	// Write via rename so that readers never observe partial output
+	tmpPath := o.WatchOutput + ".kbld-tmp"
+
+	err := os.WriteFile(tmpPath, output, 0600)
+	if err != nil {
+		return fmt.Errorf("Writing watch output: %s", err)
+	}
+
+	return os.Rename(tmpPath, o.WatchOutput)
+}
+
 func (o *ResolveOptions) ResolveResources(logger *ctllog.Logger, pLogger *ctllog.PrefixWriter) ([][]byte, error) {
-	nonConfigRs, conf, err := o.FileFlags.ResourcesAndConfig()
+	resBss, _, err := o.resolveResources(logger, pLogger)
+	return resBss, err
+}
+
+// resolveResources additionally returns relative input file path for each resource
+func (o *ResolveOptions) resolveResources(logger *ctllog.Logger, pLogger *ctllog.PrefixWriter) ([][]byte, []string, error) {
+	nonConfigRs, conf, paths, err := o.FileFlags.ResourcesAndConfigWithPaths()
 	if err != nil {
-		return nil, err
+		return nil, nil, util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+
+	o.resourcePaths = paths
+
+	resBss, _, err := o.resolveConfiguredResources(nonConfigRs, conf, logger, pLogger)
+	if err != nil || resBss == nil {
+		return nil, nil, err
 	}
 
-	conf, err = o.withImageMapConf(conf)
+	var resPaths []string
+	for _, res := range nonConfigRs {
+		resPaths = append(resPaths, paths[res])
+	}
+	resPaths = append(resPaths, o.companionPaths...)
+
+	return resBss, resPaths, nil
+}
+
+// resolveConfiguredResources returns updated resources in the same order as given ones
+// and resolved images (nothing is returned when only inspecting or planning)
+func (o *ResolveOptions) resolveConfiguredResources(nonConfigRs []ctlres.Resource, conf ctlconf.Conf,
+	logger *ctllog.Logger, pLogger *ctllog.PrefixWriter) ([][]byte, *ProcessedImages, error) {
+
+	conf, resolvedImages, err := o.resolveImagesInResources(
+		newSliceResourceVisitor(nonConfigRs), nonConfigRs, conf, logger, pLogger)
+	if err != nil || resolvedImages == nil {
+		return nil, nil, err
+	}
+
+	resBss, err := o.updateRefsInResources(nonConfigRs, conf, resolvedImages)
 	if err != nil {
-		return nil, err
+		return nil, nil, fmt.Errorf("Updating resource references: %s", err)
 	}
 
-	registry, err := ctlreg.NewRegistry(o.RegistryFlags.AsRegistryOpts())
+	return resBss, resolvedImages, nil
+}
+
+// resourceVisitor calls given function for each non-config input resource
+type resourceVisitor func(func(res ctlres.Resource, path string) error) error
+
+func newSliceResourceVisitor(rs []ctlres.Resource) resourceVisitor {
+	return func(visitFunc func(ctlres.Resource, string) error) error {
+		for _, res := range rs {
+			err := visitFunc(res, "")
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// resolveImagesInResources resolves images referenced by visited resources and
+// returns final configuration (nothing is returned when only inspecting or planning).
+// CRDs are given separately since visited resources may not be kept in memory.
+func (o *ResolveOptions) resolveImagesInResources(visitResources resourceVisitor, crdRs []ctlres.Resource,
+	conf ctlconf.Conf, logger *ctllog.Logger, pLogger *ctllog.PrefixWriter) (ctlconf.Conf, *ProcessedImages, error) {
+
+	// Warnings only refer to configuration found in inputs
+	inputConf := conf
+
+	conf, err := o.withImageMapConf(conf)
 	if err != nil {
-		return nil, err
+		return ctlconf.Conf{}, nil, util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+
+	conf, err = o.CRDFlags.WithSearchRules(conf, crdRs)
+	if err != nil {
+		return ctlconf.Conf{}, nil, util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+
+	var report *RunReport
+	if len(o.ReportPath) > 0 {
+		report = NewRunReport()
+	}
+
+	registry, transferStats, err := o.newRegistry(conf, report != nil)
+	if err != nil {
+		return ctlconf.Conf{}, nil, err
 	}
 
 	opts := ctlimg.FactoryOpts{
 		Conf:           conf,
 		AllowedToBuild: o.AllowedToBuild,
+		BuildTimeout:   o.BuildTimeout,
+		ScanReport:     ctlscan.NewReport(),
+
+		VerifyTransparencyLog: o.VerifyTransparencyLog,
+		ConfigUsage:           ctlimg.NewConfigUsage(),
 	}
-	if len(o.Platform) > 0 {
-		opts.GlobalPlatformSelection, err = NewPlatformSelection(o.Platform)
-		if err != nil {
-			return nil, err
-		}
+	opts.GlobalPlatformSelection, err = o.platformSelection(conf)
+	if err != nil {
+		return ctlconf.Conf{}, nil, util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+	opts.DigestCache, err = o.digestCache()
+	if err != nil {
+		return ctlconf.Conf{}, nil, util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+	opts.TransparencyLogPublicKey, err = o.transparencyLogPublicKey()
+	if err != nil {
+		return ctlconf.Conf{}, nil, util.NewCategorizedError(util.ErrorCategoryConfig, err)
 	}
 	imgFactory := ctlimg.NewFactory(opts, registry, *logger)
 
-	imageURLs, err := o.collectImageReferences(nonConfigRs, conf)
+	imageURLs, ruleUsage, err := o.collectImageReferences(visitResources, conf)
 	if err != nil {
-		return nil, err
+		return ctlconf.Conf{}, nil, err
 	}
 
 	if o.UnresolvedInspect {
 		output, err := imageURLs.Bytes()
 		if err != nil {
-			return nil, err
+			return ctlconf.Conf{}, nil, err
 		}
 		o.ui.PrintBlock(output)
-		return nil, nil
+		return ctlconf.Conf{}, nil, nil
+	}
+
+	if o.DryRun {
+		return ctlconf.Conf{}, nil, o.printPlan(imageURLs, imgFactory)
+	}
+
+	resolvedImages, err := o.resolveImages(imageURLs, imgFactory, report, pLogger)
+
+	warnings := NewWarnings()
+	warnings.AddFromConf(inputConf, ruleUsage, opts.ConfigUsage)
+	warnings.Log(pLogger)
+
+	if err == nil {
+		err = util.NewCategorizedError(util.ErrorCategoryConfig, warnings.StrictErr(o.Strict))
+	}
+
+	// Keep cached selections even if some of the images failed
+	if cacheErr := opts.DigestCache.Save(); cacheErr != nil {
+		pLogger.WriteStr("warning: %s\n", cacheErr)
+	}
+
+	// Write scan report even if some of the images failed scanning
+	if scanConf := conf.VulnerabilityScan(); scanConf != nil && len(scanConf.ReportPath) > 0 {
+		reportErr := opts.ScanReport.WriteToFile(scanConf.ReportPath)
+		if reportErr != nil && err == nil {
+			err = reportErr
+		}
+	}
+	if o.ciAnnotations != nil {
+		o.ciAnnotations.Complete(resolvedImages)
 	}
+	if report != nil {
+		report.Complete(resolvedImages)
+		report.RecordWarnings(warnings.All())
 
-	resolvedImages, err := o.resolveImages(imageURLs, imgFactory)
+		reportErr := report.WriteToFile(o.ReportPath, transferStats, err)
+		if reportErr != nil && err == nil {
+			err = reportErr
+		}
+	}
+	if o.RegistryFlags.pushManifest != nil && len(o.GCManifestOutput) > 0 {
+		manifestErr := WriteGCManifest(o.GCManifestOutput, o.RegistryFlags.pushManifest)
+		if manifestErr != nil && err == nil {
+			err = manifestErr
+		}
+	}
 	if err != nil {
-		return nil, err
+		return ctlconf.Conf{}, nil, err
 	}
 
 	// Record final image transformation
@@ -135,80 +555,236 @@ func (o *ResolveOptions) ResolveResources(logger *ctllog.Logger, pLogger *ctllog
 		pLogger.WriteStr("final: %s -> %s\n", pair.UnprocessedImageURL.URL, pair.Image.URL)
 	}
 
+	err = CheckPolicies(conf, resolvedImages, registry, *logger)
+	if err != nil {
+		return ctlconf.Conf{}, nil, util.NewCategorizedError(util.ErrorCategoryPolicy, err)
+	}
+
 	err = o.emitLockOutput(conf, resolvedImages)
 	if err != nil {
-		return nil, err
+		return ctlconf.Conf{}, nil, err
+	}
+
+	if len(o.KustomizeImagesOutput) > 0 {
+		kustomizeImages, err := NewKustomizeImages(resolvedImages)
+		if err != nil {
+			return ctlconf.Conf{}, nil, err
+		}
+		err = kustomizeImages.WriteToFile(o.KustomizeImagesOutput)
+		if err != nil {
+			return ctlconf.Conf{}, nil, err
+		}
+	}
+
+	if len(o.ValuesOutput) > 0 {
+		err = o.valuesOutput().WriteToFile(o.ValuesOutput, resolvedImages)
+		if err != nil {
+			return ctlconf.Conf{}, nil, err
+		}
 	}
 
-	resBss, err := o.updateRefsInResources(nonConfigRs, conf, resolvedImages, imgFactory)
+	return conf, resolvedImages, nil
+}
+
+// ResolveConfiguredResources resolves images in given resources according to configuration
+func (o *ResolveOptions) ResolveConfiguredResources(nonConfigRs []ctlres.Resource,
+	conf ctlconf.Conf, logger ctllog.Logger) ([][]byte, *ProcessedImages, error) {
+
+	return o.resolveConfiguredResources(nonConfigRs, conf, &logger, logger.NewPrefixedWriter("resolve | "))
+}
+
+// WithRegistry uses given registry instead of one configured via registry flags
+func (o *ResolveOptions) WithRegistry(registry ctlreg.Registry) *ResolveOptions {
+	o.registry = &registry
+	return o
+}
+
+// newRegistry reuses preconfigured registry (e.g. in server mode)
+// so that connections and credentials are kept across resolutions
+// (proxies configured in given configuration only apply to newly created registry)
+func (o *ResolveOptions) newRegistry(conf ctlconf.Conf, withStats bool) (ctlreg.Registry, *ctlreg.TransferStats, error) {
+	if o.registry != nil {
+		return *o.registry, nil, nil
+	}
+
+	registryOpts, err := o.RegistryFlags.AsRegistryOpts()
 	if err != nil {
-		return nil, fmt.Errorf("Updating resource references: %s", err)
+		return ctlreg.Registry{}, nil, util.NewCategorizedError(util.ErrorCategoryConfig, err)
 	}
 
-	return resBss, nil
+	registryOpts.Proxies, err = registryProxyRules(conf)
+	if err != nil {
+		return ctlreg.Registry{}, nil, util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+
+	if withStats {
+		registryOpts.TransferStats = ctlreg.NewTransferStats()
+	}
+	registryOpts.Tracer = o.tracer
+
+	registry, err := ctlreg.NewRegistry(registryOpts)
+	if err != nil {
+		return ctlreg.Registry{}, nil, err
+	}
+
+	return registry, registryOpts.TransferStats, nil
+}
+
+func registryProxyRules(conf ctlconf.Conf) ([]ctlreg.ProxyRule, error) {
+	var rules []ctlreg.ProxyRule
+
+	for _, proxy := range conf.RegistryProxies() {
+		proxyURL, err := proxy.ProxyURL()
+		if err != nil {
+			return nil, fmt.Errorf("Configuring proxy for registry '%s': %s", proxy.Registry, err)
+		}
+		rules = append(rules, ctlreg.ProxyRule{Host: proxy.Registry, URL: proxyURL})
+	}
+
+	return rules, nil
+}
+
+func (o *ResolveOptions) printPlan(imageURLs *UnprocessedImageURLs, imgFactory ctlimg.Factory) error {
+	table := uitable.Table{
+		Title:   "Plan",
+		Content: "images",
+
+		Header: []uitable.Header{
+			uitable.NewHeader("Image"),
+			uitable.NewHeader("Action"),
+			uitable.NewHeader("Details"),
+		},
+
+		SortBy: []uitable.ColumnSort{{Column: 0, Asc: true}},
+
+		// Image URLs and other content is too long
+		FillFirstColumn: true,
+		Transpose:       true,
+	}
+
+	for _, imageURL := range imageURLs.All() {
+		plan, err := imgFactory.Plan(imageURL.URL)
+		if err != nil {
+			return err
+		}
+
+		details, err := yaml.Marshal(plan)
+		if err != nil {
+			return err
+		}
+
+		table.Rows = append(table.Rows, []uitable.Value{
+			uitable.NewValueString(plan.URL),
+			uitable.NewValueString(string(plan.Action)),
+			uitable.NewValueString(strings.TrimSpace(string(details))),
+		})
+	}
+
+	o.ui.PrintTable(table)
+
+	return nil
 }
 
-func (o *ResolveOptions) collectImageReferences(nonConfigRs []ctlres.Resource,
-	conf ctlconf.Conf) (*UnprocessedImageURLs, error) {
+// collectImageReferences additionally records which search rules matched values
+func (o *ResolveOptions) collectImageReferences(visitResources resourceVisitor,
+	conf ctlconf.Conf) (*UnprocessedImageURLs, *ctlser.RuleUsage, error) {
 	imageURLs := NewUnprocessedImageURLs()
+	searchRules := conf.SearchRules()
+	ruleUsage := ctlser.NewRuleUsage(searchRules)
 
-	for _, res := range nonConfigRs {
-		imageRefs := ctlser.NewImageRefs(res.DeepCopyRaw(), conf.SearchRules())
+	err := visitResources(func(res ctlres.Resource, _ string) error {
+		if !o.resourceFilter.Matches(res) {
+			return nil
+		}
+
+		resContents := res.DeepCopyRaw()
+		ruleUsage.Visit(resContents)
+
+		imageRefs := ctlser.NewImageRefs(resContents, searchRules)
 
 		imageRefs.Visit(func(imgURL string) (string, bool) {
 			imageURLs.Add(UnprocessedImageURL{imgURL})
 			return "", false
 		})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return imageURLs, nil
+	return imageURLs, ruleUsage, nil
 }
 
-func (o *ResolveOptions) resolveImages(imageURLs *UnprocessedImageURLs, imgFactory ctlimg.Factory) (*ProcessedImages, error) {
-	queue := NewImageQueue(imgFactory)
+// resolveImages returns successfully processed images even when some of images failed
+func (o *ResolveOptions) resolveImages(imageURLs *UnprocessedImageURLs,
+	imgFactory ctlimg.Factory, report *RunReport, pLogger *ctllog.PrefixWriter) (*ProcessedImages, error) {
+
+	queue := NewImageQueue(imgFactory).WithProgress(o.progress).WithResolveConcurrency(o.ResolveConcurrency)
+	if report != nil {
+		queue.WithProgress(report)
+	}
+	if o.ciAnnotations != nil {
+		queue.WithProgress(o.ciAnnotations)
+	}
+	if o.tracer != nil {
+		queue.WithProgress(NewTracingProgress(o.tracer))
+	}
+	if o.metrics != nil {
+		queue.WithProgress(NewMetricsProgress(o.metrics))
+	}
+	if len(o.StateFile) > 0 {
+		state, err := NewRunState(o.StateFile, o.Resume, imgFactory)
+		if err != nil {
+			return nil, err
+		}
+		queue.WithState(state)
+	}
 
 	resolvedImages, err := queue.Run(imageURLs, o.BuildConcurrency)
-	if err != nil {
-		return nil, err
+
+	// Same image may be referenced by multiple resources
+	// or by different references that are processed identically
+	references := imageURLs.References()
+	processed := len(imageURLs.All()) - queue.Coalesced()
+
+	if report != nil {
+		report.RecordReferences(references, processed)
+	}
+	if references > processed {
+		pLogger.WriteStr("coalesced %d image references into %d images\n", references, processed)
 	}
 
-	return resolvedImages, nil
+	return resolvedImages, err
 }
 
 func (o *ResolveOptions) updateRefsInResources(nonConfigRs []ctlres.Resource,
-	conf ctlconf.Conf, resolvedImages *ProcessedImages,
-	_ ctlimg.Factory) ([][]byte, error) {
+	conf ctlconf.Conf, resolvedImages *ProcessedImages) ([][]byte, error) {
 
 	var errs []error
 	var resBss [][]byte
 
-	for _, res := range nonConfigRs {
-		resContents := res.DeepCopyRaw()
-		images := []Image{}
-		imageRefs := ctlser.NewImageRefs(resContents, conf.SearchRules())
-
-		imageRefs.Visit(func(imgURL string) (string, bool) {
-			img, found := resolvedImages.FindByURL(UnprocessedImageURL{imgURL})
-			if !found {
-				errs = append(errs, fmt.Errorf("Expected to find image for '%s'", imgURL))
-				return "", false
-			}
-
-			if o.ImagesAnnotation {
-				images = append(images, img)
-			}
+	o.companionPaths = nil
 
-			return img.URL, true
-		})
+	var companionBss [][]byte
 
-		resBs, err := NewResourceWithImages(resContents, images).Bytes()
+	for _, res := range nonConfigRs {
+		resBs, companionBs, err := o.updateRefsInResource(res, o.resourcePaths[res], conf, resolvedImages, &errs)
 		if err != nil {
 			return nil, err
 		}
 
 		resBss = append(resBss, resBs)
+
+		if companionBs != nil {
+			companionBss = append(companionBss, companionBs)
+			o.companionPaths = append(o.companionPaths, o.resourcePaths[res])
+		}
 	}
 
+	// Companion ConfigMaps follow all resources so that
+	// resources stay in the same order as given ones
+	resBss = append(resBss, companionBss...)
+
 	err := errFromErrs(errs)
 	if err != nil {
 		return nil, err
@@ -217,6 +793,66 @@ func (o *ResolveOptions) updateRefsInResources(nonConfigRs []ctlres.Resource,
 	return resBss, nil
 }
 
+// updateRefsInResource returns updated resource and its companion ConfigMap (if any).
+// Images that were not resolved are recorded in errs.
+func (o *ResolveOptions) updateRefsInResource(res ctlres.Resource, path string, conf ctlconf.Conf,
+	resolvedImages *ProcessedImages, errs *[]error) ([]byte, []byte, error) {
+
+	if !o.resourceFilter.Matches(res) {
+		resBs, err := unmodifiedResourceBytes(res)
+		return resBs, nil, err
+	}
+
+	annConf := conf.ImagesAnnotation()
+	resContents := res.DeepCopyRaw()
+	images := []Image{}
+	imageRefs := ctlser.NewImageRefs(resContents, conf.SearchRules())
+	annotate := o.ImagesAnnotation && !annConf.Excludes(res)
+	updated := false
+
+	imageRefs.Visit(func(imgURL string) (string, bool) {
+		img, found := resolvedImages.FindByURL(UnprocessedImageURL{imgURL})
+		if !found {
+			*errs = append(*errs, fmt.Errorf("Expected to find image for '%s'", imgURL))
+			return "", false
+		}
+
+		if annotate {
+			img.unprocessedURL = imgURL
+			images = append(images, img)
+		}
+
+		updated = updated || img.URL != imgURL
+
+		return img.URL, true
+	})
+
+	if !updated && len(images) == 0 {
+		resBs, err := unmodifiedResourceBytes(res)
+		return resBs, nil, err
+	}
+
+	resWithImages := NewResourceWithImages(resContents, images).WithAnnotationConf(annConf, path)
+	if !o.ExpandYAMLAliases {
+		resWithImages = resWithImages.WithOriginal(res)
+	}
+
+	return resWithImages.BytesWithCompanion()
+}
+
+// unmodifiedResourceBytes returns resource exactly as it was given
+// since re-serialization may alter content (e.g. anchors, comments, large numbers)
+func unmodifiedResourceBytes(res ctlres.Resource) ([]byte, error) {
+	resBs, found := res.OriginalBytes()
+	if !found {
+		return res.AsYAMLBytes()
+	}
+	if len(resBs) > 0 && resBs[len(resBs)-1] != '\n' {
+		resBs = append(append([]byte{}, resBs...), '\n')
+	}
+	return resBs, nil
+}
+
 func errFromErrs(errs []error) error {
 	if len(errs) == 0 {
 		return nil
@@ -226,7 +862,39 @@ func errFromErrs(errs []error) error {
 	for _, err := range errs {
 		errStrs = append(errStrs, err.Error())
 	}
-	return fmt.Errorf("\n- %s", strings.Join(errStrs, "\n- "))
+	return util.NewCategorizedError(util.ErrorCategoryOfAll(errs),
+		fmt.Errorf("\n- %s", strings.Join(errStrs, "\n- ")))
+}
+
+func (o *ResolveOptions) digestCache() (*ctlimg.DigestCache, error) {
+	if o.sharedDigestCache != nil {
+		return o.sharedDigestCache, nil
+	}
+	switch o.DigestCache {
+	case "":
+		return nil, nil
+	case "auto":
+		path, err := ctlimg.DefaultDigestCachePath()
+		if err != nil {
+			return nil, err
+		}
+		return ctlimg.NewDigestCache(path), nil
+	default:
+		return ctlimg.NewDigestCache(o.DigestCache), nil
+	}
+}
+
+func (o *ResolveOptions) transparencyLogPublicKey() (crypto.PublicKey, error) {
+	if len(o.TransparencyLogPublicKey) == 0 {
+		return nil, nil
+	}
+
+	bs, err := os.ReadFile(o.TransparencyLogPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("Reading transparency log public key: %s", err)
+	}
+
+	return ctlsign.ParseRekorPublicKey(bs)
 }
 
 func (o *ResolveOptions) withImageMapConf(conf ctlconf.Conf) (ctlconf.Conf, error) {
@@ -273,8 +941,9 @@ func (o *ResolveOptions) emitLockOutput(conf ctlconf.Conf, resolvedImages *Proce
 				ImageRef: ctlconf.ImageRef{
 					Image: urlImagePair.UnprocessedImageURL.URL,
 				},
-				NewImage:    urlImagePair.Image.URL,
-				Preresolved: true,
+				NewImage:     urlImagePair.Image.URL,
+				Preresolved:  true,
+				ImageOrigins: lockOrigins(conf, urlImagePair.Image.Origins),
 			})
 		}
 
@@ -298,6 +967,10 @@ func (o *ResolveOptions) emitLockOutput(conf ctlconf.Conf, resolvedImages *Proce
 	}
 }
 
+func (o *ResolveOptions) valuesOutput() ValuesOutput {
+	return ValuesOutput{Format: o.ValuesFormat, Key: o.ValuesKey}
+}
+
 func (o *ResolveOptions) imgpkgLockAnnotations(i ProcessedImageItem) map[string]string {
 	anns := map[string]string{
 		ctlconf.ImagesLockKbldID: i.UnprocessedImageURL.URL,
@@ -313,8 +986,24 @@ func (o *ResolveOptions) imgpkgLockAnnotations(i ProcessedImageItem) map[string]
 	return anns
 }
 
+// platformSelection returns platform selected via flag or cluster profile
+// (flag takes precedence over platform of cluster profile)
+func (o *ResolveOptions) platformSelection(conf ctlconf.Conf) (*ctlconf.PlatformSelection, error) {
+	if len(o.Platform) > 0 {
+		return NewPlatformSelection(o.Platform)
+	}
+	if len(o.ClusterProfile) > 0 {
+		profile, err := conf.ClusterProfile(o.ClusterProfile)
+		if err != nil {
+			return nil, err
+		}
+		return profile.Platform, nil
+	}
+	return nil, nil
+}
+
 // NewPlatformSelection parses platform string into PlatformSelection
-// Examples: linux/386, linux/arm/v7, linux/arm/v6
+// Examples: linux/386, linux/arm/v7, linux/arm/v6, windows/amd64:10.0.17763
 // Taken from https://github.com/google/go-containerregistry/blob/570ba6c88a5041afebd4599981d849af96f5dba9/cmd/crane/cmd/util.go#L45
 func NewPlatformSelection(platform string) (*ctlconf.PlatformSelection, error) {
 	result := &ctlconf.PlatformSelection{}
@@ -0,0 +1,164 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	regname "github.com/google/go-containerregistry/pkg/name"
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestResolveArchiveSources(t *testing.T) {
+	reg := newFakeRegistry(t)
+
+	resolve := func(t *testing.T, archivePath, dstConf string) (string, error) {
+		inputPath := filepath.Join(t.TempDir(), "input.yml")
+		require.NoError(t, os.WriteFile(inputPath, []byte(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: app
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+sources:
+- image: app
+  path: `+filepath.Dir(archivePath)+`
+  archive:
+    file: `+filepath.Base(archivePath)+`
+`+dstConf), 0600))
+
+		var stdout bytes.Buffer
+
+		cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+		cmd.SetArgs([]string{"-f", inputPath, "--registry-insecure", "--progress=plain", "--images-annotation=false"})
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+
+		err := cmd.Execute()
+
+		return stdout.String(), err
+	}
+
+	dstConf := "destinations:\n- image: app\n  newImage: " + reg.Host + "/app\n"
+
+	t.Run("pushes OCI image layout tarball", func(t *testing.T) {
+		archivePath, digest := writeOCIArchive(t, "oci")
+
+		out, err := resolve(t, archivePath, dstConf)
+		require.NoError(t, err)
+		assert.Contains(t, out, "- image: "+reg.Host+"/app@"+digest)
+		assert.Contains(t, reg.Digests("app"), digest)
+	})
+
+	t.Run("pushes docker save tarball", func(t *testing.T) {
+		img := newLabeledImage(t, "docker-save")
+
+		archivePath := filepath.Join(t.TempDir(), "image.tar")
+		require.NoError(t, tarball.WriteToFile(archivePath, regname.MustParseReference("app:latest"), img))
+
+		digest, err := img.Digest()
+		require.NoError(t, err)
+
+		out, err := resolve(t, archivePath, dstConf)
+		require.NoError(t, err)
+		assert.Contains(t, out, "- image: "+reg.Host+"/app@"+digest.String())
+		assert.Contains(t, reg.Digests("app"), digest.String())
+	})
+
+	t.Run("rejects other tarballs", func(t *testing.T) {
+		archivePath := filepath.Join(t.TempDir(), "image.tar")
+		writeTar(t, archivePath, map[string][]byte{"README.md": []byte("not an image")})
+
+		_, err := resolve(t, archivePath, dstConf)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Expected archive to be OCI image layout or docker save tarball")
+	})
+
+	t.Run("requires destination", func(t *testing.T) {
+		archivePath, _ := writeOCIArchive(t, "oci")
+
+		_, err := resolve(t, archivePath, "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Expected image destination to be configured for 'app' to push archive")
+	})
+}
+
+func newLabeledImage(t *testing.T, label string) regv1.Image {
+	img, err := mutate.ConfigFile(empty.Image, &regv1.ConfigFile{
+		Architecture: "amd64",
+		OS:           "linux",
+		Config:       regv1.Config{Labels: map[string]string{"label": label}},
+	})
+	require.NoError(t, err)
+	return img
+}
+
+// writeOCIArchive writes OCI image layout tarball with small unique image
+// and returns its path and digest of image
+func writeOCIArchive(t *testing.T, label string) (string, string) {
+	img := newLabeledImage(t, label)
+
+	layoutDir := t.TempDir()
+
+	layoutPath, err := layout.Write(layoutDir, empty.Index)
+	require.NoError(t, err)
+	require.NoError(t, layoutPath.AppendImage(img))
+
+	files := map[string][]byte{}
+
+	err = filepath.Walk(layoutDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relPath, err := filepath.Rel(layoutDir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(relPath)], err = os.ReadFile(path)
+		return err
+	})
+	require.NoError(t, err)
+
+	archivePath := filepath.Join(t.TempDir(), "image.tar")
+	writeTar(t, archivePath, files)
+
+	digest, err := img.Digest()
+	require.NoError(t, err)
+
+	return archivePath, digest.String()
+}
+
+func writeTar(t *testing.T, path string, files map[string][]byte) {
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	tarWriter := tar.NewWriter(file)
+
+	for name, bs := range files {
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(bs))}))
+		_, err := tarWriter.Write(bs)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, tarWriter.Close())
+}
@@ -0,0 +1,97 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package cmd_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/testutil"
+	"sigs.k8s.io/yaml"
+)
+
+func TestResolveRecordsBuilderInvocation(t *testing.T) {
+	testutil.FakeBinaries(t, map[string]string{
+		"docker": `case "$1" in
+version)
+  echo "27.1.1" ;;
+inspect)
+  echo '[{"Id": "sha256:` + strings.Repeat("a", 64) + `"}]' ;;
+esac
+`,
+	})
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	t.Setenv("REGISTRY_TOKEN", "secret")
+
+	srcDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "Dockerfile"), []byte("FROM scratch\n"), 0600))
+
+	inputPath := filepath.Join(t.TempDir(), "input.yml")
+	require.NoError(t, os.WriteFile(inputPath, []byte(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: app
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+sources:
+- image: app
+  path: `+srcDir+`
+  env:
+    set:
+      BUILD_MODE: release
+  docker:
+    build:
+      pull: true
+`), 0600))
+
+	lockPath := filepath.Join(t.TempDir(), "lock.yml")
+
+	cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(io.Discard, io.Discard, ui.NewNoopLogger())))
+	cmd.SetArgs([]string{"-f", inputPath, "--progress=plain", "--lock-output", lockPath})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	require.NoError(t, cmd.Execute())
+
+	lockBs, err := os.ReadFile(lockPath)
+	require.NoError(t, err)
+
+	var lockConf ctlconf.Config
+	require.NoError(t, yaml.Unmarshal(lockBs, &lockConf))
+	require.Len(t, lockConf.Overrides, 1)
+
+	var built *ctlconf.OriginBuilt
+	for _, origin := range lockConf.Overrides[0].ImageOrigins {
+		if origin.Built != nil {
+			built = origin.Built
+		}
+	}
+	require.NotNil(t, built)
+	require.Len(t, built.Invocations, 1)
+
+	invocation := built.Invocations[0]
+	assert.Equal(t, "docker", invocation.Tool)
+	assert.Equal(t, "27.1.1", invocation.Version)
+	assert.Equal(t, []string{"build", "--pull"}, invocation.Args[:2])
+
+	// Only variables affecting build are recorded
+	assert.Contains(t, invocation.Env, "BUILD_MODE=release")
+	assert.Contains(t, invocation.Env, "SOURCE_DATE_EPOCH=1700000000")
+	assert.NotContains(t, invocation.Env, "REGISTRY_TOKEN=secret")
+}
@@ -0,0 +1,98 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package cmd_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/testutil"
+)
+
+func TestResolveRetriesFailedBuilds(t *testing.T) {
+	buildsLog := filepath.Join(t.TempDir(), "builds.log")
+	imageID := "sha256:" + strings.Repeat("a", 64)
+
+	// Docker fails builds until there are three attempts
+	testutil.FakeBinaries(t, map[string]string{
+		"docker": `case "$1" in
+build)
+  echo "$@" >> ` + buildsLog + `
+  if [ "$(wc -l < ` + buildsLog + `)" -lt 3 ]; then echo "net/http: TLS handshake timeout" >&2; exit 1; fi ;;
+inspect)
+  echo '[{"Id": "` + imageID + `"}]' ;;
+esac
+`,
+	})
+
+	srcDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "Dockerfile"), []byte("FROM scratch\n"), 0600))
+
+	resolve := func(t *testing.T, attempts string) (string, error) {
+		require.NoError(t, os.RemoveAll(buildsLog))
+
+		inputPath := filepath.Join(t.TempDir(), "input.yml")
+		require.NoError(t, os.WriteFile(inputPath, []byte(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: app
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+sources:
+- image: app
+  path: `+srcDir+`
+  retry:
+    attempts: `+attempts+`
+    delay: 10ms
+    noCacheOnLastAttempt: true
+`), 0600))
+
+		var stdout bytes.Buffer
+
+		cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+		cmd.SetArgs([]string{"-f", inputPath, "--progress=plain", "--images-annotation=false"})
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+
+		err := cmd.Execute()
+
+		return stdout.String(), err
+	}
+
+	t.Run("succeeds once attempt succeeds", func(t *testing.T) {
+		out, err := resolve(t, "3")
+		require.NoError(t, err)
+		assert.Contains(t, out, "- image: kbld:app-sha256-"+strings.Repeat("a", 64))
+
+		buildsBs, err := os.ReadFile(buildsLog)
+		require.NoError(t, err)
+
+		builds := strings.Split(strings.TrimSpace(string(buildsBs)), "\n")
+		require.Len(t, builds, 3)
+		assert.NotContains(t, builds[0], "--no-cache")
+		assert.NotContains(t, builds[1], "--no-cache")
+		assert.Contains(t, builds[2], "--no-cache")
+	})
+
+	t.Run("fails once attempts are exhausted", func(t *testing.T) {
+		_, err := resolve(t, "2")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Building failed after 2 attempts: exit status 1")
+	})
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package cmd_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/testutil"
+)
+
+func TestResolveTerminatesBuildsAfterTimeout(t *testing.T) {
+	// Hung build spawns its own subprocess (similarly to docker CLI plugins)
+	testutil.FakeBinaries(t, map[string]string{
+		"docker": "sleep 60\n",
+	})
+
+	srcDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "Dockerfile"), []byte("FROM scratch\n"), 0600))
+
+	resolve := func(t *testing.T, timeoutConf string, args ...string) error {
+		inputPath := filepath.Join(t.TempDir(), "input.yml")
+		require.NoError(t, os.WriteFile(inputPath, []byte(`
+kind: Pod
+spec:
+  containers:
+  - image: app
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+sources:
+- image: app
+  path: `+srcDir+timeoutConf+`
+`), 0600))
+
+		cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(io.Discard, io.Discard, ui.NewNoopLogger())))
+		cmd.SetArgs(append([]string{"-f", inputPath, "--progress=plain"}, args...))
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+
+		return cmd.Execute()
+	}
+
+	t.Run("uses source timeout", func(t *testing.T) {
+		startedAt := time.Now()
+
+		err := resolve(t, "\n  timeout: 500ms", "--build-timeout=1h")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Expected build of image 'app' to finish within 500ms, but it timed out")
+
+		// Whole process group is terminated without waiting for stuck subprocess
+		assert.Less(t, time.Since(startedAt), 5*time.Second)
+	})
+
+	t.Run("falls back on global timeout", func(t *testing.T) {
+		err := resolve(t, "", "--build-timeout=500ms")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Expected build of image 'app' to finish within 500ms, but it timed out")
+	})
+}
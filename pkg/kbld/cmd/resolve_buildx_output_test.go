@@ -0,0 +1,86 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package cmd_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/testutil"
+)
+
+func TestResolveBuildxOCIOutput(t *testing.T) {
+	reg := newFakeRegistry(t)
+
+	archivePath, digest := writeOCIArchive(t, "app")
+
+	// Buildx exports prepared archive to requested destination
+	testutil.FakeBinaries(t, map[string]string{
+		"docker": `for arg in "$@"; do
+  case "$arg" in
+  --output=type=oci,dest=*) cp ` + archivePath + ` "${arg#--output=type=oci,dest=}" ;;
+  esac
+done
+`,
+	})
+
+	srcDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "Dockerfile"), []byte("FROM scratch\n"), 0600))
+
+	resolve := func(t *testing.T, dstConf string) (string, error) {
+		inputPath := filepath.Join(t.TempDir(), "input.yml")
+		require.NoError(t, os.WriteFile(inputPath, []byte(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: app
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+sources:
+- image: app
+  path: `+srcDir+`
+  docker:
+    buildx:
+      output: oci
+`+dstConf), 0600))
+
+		var stdout bytes.Buffer
+
+		cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+		cmd.SetArgs([]string{"-f", inputPath, "--registry-insecure", "--progress=plain", "--images-annotation=false"})
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+
+		err := cmd.Execute()
+
+		return stdout.String(), err
+	}
+
+	t.Run("pushes exported archive", func(t *testing.T) {
+		out, err := resolve(t, "destinations:\n- image: app\n  newImage: "+reg.Host+"/app\n")
+		require.NoError(t, err)
+		assert.Contains(t, out, "- image: "+reg.Host+"/app@"+digest)
+		assert.Equal(t, []string{digest}, reg.Digests("app"))
+	})
+
+	t.Run("requires destination", func(t *testing.T) {
+		_, err := resolve(t, "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Expected image destination to be configured for 'app' "+
+			"to push image built with 'oci' buildx output")
+	})
+}
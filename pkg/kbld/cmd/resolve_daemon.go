@@ -0,0 +1,130 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+// resolveViaDaemon sends inputs to daemon listening on unix socket
+// and prints resolved resources (only inputs and platform are forwarded)
+func (o *ResolveOptions) resolveViaDaemon() error {
+	var unsupportedFlag string
+
+	switch {
+	case len(o.OutputDir) > 0:
+		unsupportedFlag = "--output-dir"
+	case len(o.LockOutput) > 0:
+		unsupportedFlag = "--lock-output"
+	case len(o.ImgpkgLockOutput) > 0:
+		unsupportedFlag = "--imgpkg-lock-output"
+	case len(o.GCManifestOutput) > 0:
+		unsupportedFlag = "--gc-manifest-output"
+	case len(o.KustomizeImagesOutput) > 0:
+		unsupportedFlag = "--kustomize-images-output"
+	case len(o.ValuesOutput) > 0:
+		unsupportedFlag = "--values-output"
+	case len(o.ClusterProfile) > 0:
+		unsupportedFlag = "--cluster-profile"
+	case len(o.Strict) > 0:
+		unsupportedFlag = "--strict"
+	case len(o.FilterFlags.Kinds) > 0:
+		unsupportedFlag = "--filter-kind"
+	case len(o.FilterFlags.Namespaces) > 0:
+		unsupportedFlag = "--filter-namespace"
+	case len(o.FilterFlags.Labels) > 0:
+		unsupportedFlag = "--filter-labels"
+	case o.ExpandYAMLAliases:
+		unsupportedFlag = "--expand-yaml-aliases"
+	case o.Watch:
+		unsupportedFlag = "--watch"
+	case o.Stream:
+		unsupportedFlag = "--stream"
+	case o.DryRun:
+		unsupportedFlag = "--dry-run"
+	case o.UnresolvedInspect:
+		unsupportedFlag = "--unresolved-inspect"
+	}
+	if len(unsupportedFlag) > 0 {
+		return util.NewCategorizedError(util.ErrorCategoryConfig,
+			fmt.Errorf("Expected '--daemon-socket' to not be used with '%s'", unsupportedFlag))
+	}
+
+	var input bytes.Buffer
+
+	for _, file := range o.FileFlags.Files {
+		fileRs, err := ctlres.NewFileResources(file)
+		if err != nil {
+			return util.NewCategorizedError(util.ErrorCategoryConfig, err)
+		}
+
+		for _, fileRes := range fileRs {
+			err := o.appendFileResource(&input, fileRes)
+			if err != nil {
+				return util.NewCategorizedError(util.ErrorCategoryConfig,
+					fmt.Errorf("Reading %s: %s", fileRes.Description(), err))
+			}
+		}
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", o.DaemonSocket)
+			},
+		},
+	}
+
+	query := url.Values{}
+	if len(o.Platform) > 0 {
+		query.Set("platform", o.Platform)
+	}
+
+	// Host is ignored since connection is made to the socket
+	resp, err := client.Post("http://kbld-daemon/v1/resolve?"+query.Encode(), "application/yaml", &input)
+	if err != nil {
+		return fmt.Errorf("Connecting to daemon (is 'kbld daemon' running?): %s", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("Reading daemon response: %s", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var serverErr resolveServerError
+		if json.Unmarshal(body, &serverErr) != nil || len(serverErr.Error) == 0 {
+			return fmt.Errorf("Expected daemon to succeed, but was '%s'", resp.Status)
+		}
+		return util.NewCategorizedError(serverErr.Category, fmt.Errorf("%s", serverErr.Error))
+	}
+
+	o.ui.PrintBlock(body)
+
+	return nil
+}
+
+func (o *ResolveOptions) appendFileResource(input *bytes.Buffer, fileRes ctlres.FileResource) error {
+	reader, err := fileRes.Reader()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	input.WriteString("\n---\n")
+
+	_, err = io.Copy(input, reader)
+	return err
+}
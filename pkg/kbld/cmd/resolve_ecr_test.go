@@ -0,0 +1,93 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/testutil"
+)
+
+func TestResolveFailsEarlyForMissingECRRepositories(t *testing.T) {
+	dockerLog := filepath.Join(t.TempDir(), "docker.log")
+	awsLog := filepath.Join(t.TempDir(), "aws.log")
+
+	testutil.FakeBinaries(t, map[string]string{
+		"aws": `echo "aws $@" >> ` + awsLog + `
+echo "An error occurred (RepositoryNotFoundException) when calling the DescribeRepositories operation" >&2
+exit 254
+`,
+		"docker": `echo "docker $@" >> ` + dockerLog + `
+exit 1
+`,
+	})
+
+	const host = "123456789012.dkr.ecr.us-east-1.amazonaws.com"
+
+	inputPath := filepath.Join(t.TempDir(), "input.yml")
+	require.NoError(t, os.WriteFile(inputPath, []byte(fmt.Sprintf(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: app
+  - image: worker
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+sources:
+- image: app
+  path: %[1]s
+- image: worker
+  path: %[1]s
+destinations:
+- image: app
+  newImage: %[2]s/team/app
+  newImages:
+  - %[2]s/team/app
+  - %[2]s/mirror/app
+  ecr: {}
+- image: worker
+  newImage: %[2]s/team/worker
+  newImages:
+  - %[2]s/team/worker
+  - %[2]s/mirror/app
+  ecr: {}
+`, t.TempDir(), host)), 0600))
+
+	var stdout bytes.Buffer
+
+	cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+	cmd.SetArgs([]string{"-f", inputPath, "--progress=plain"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	err := cmd.Execute()
+	require.Error(t, err)
+	// Missing repositories of all destinations are listed in single error
+	assert.Equal(t, "Expected ECR repositories to exist, but were missing: "+host+"/team/app, "+
+		host+"/mirror/app, "+host+"/team/worker (hint: enable ecr.createRepositories to create them)", err.Error())
+
+	awsBs, err := os.ReadFile(awsLog)
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(awsBs)), "\n"), 3)
+
+	// Image is not built when it could not be pushed
+	_, err = os.Stat(dockerLog)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveImmutableTagsOfAdditionalDestinations(t *testing.T) {
+	dstConf := `
+  newImages: [%[1]s/dst/a, %[1]s/dst/b]
+  tags: [v0, v1]
+  immutableTags: true
+`
+
+	t.Run("writes tags that do not exist", func(t *testing.T) {
+		reg := newFakeRegistry(t)
+		appDigest := strings.Split(reg.PushImage(t, reg.Host+"/src/app:1.0"), "@")[1]
+
+		_, err := resolveWithDestination(t, reg, dstConf)
+		require.NoError(t, err)
+
+		assert.Equal(t, appDigest, reg.tagDigest("dst/b", "v0"))
+		assert.Equal(t, appDigest, reg.tagDigest("dst/b", "v1"))
+	})
+
+	t.Run("keeps tags that already point at image", func(t *testing.T) {
+		reg := newFakeRegistry(t)
+		appDigest := strings.Split(reg.PushImage(t, reg.Host+"/src/app:1.0"), "@")[1]
+
+		_, err := resolveWithDestination(t, reg, dstConf)
+		require.NoError(t, err)
+
+		// Second run finds tags already pointing at the same digest
+		_, err = resolveWithDestination(t, reg, dstConf)
+		require.NoError(t, err)
+
+		assert.Equal(t, appDigest, reg.tagDigest("dst/b", "v0"))
+		assert.Equal(t, appDigest, reg.tagDigest("dst/b", "v1"))
+	})
+
+	t.Run("fails without writing any tags when tag points at other image", func(t *testing.T) {
+		reg := newFakeRegistry(t)
+		reg.PushImage(t, reg.Host+"/src/app:1.0")
+		otherDigest := strings.Split(reg.PushImage(t, reg.Host+"/dst/b:v1"), "@")[1]
+
+		_, err := resolveWithDestination(t, reg, dstConf)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), fmt.Sprintf("Copying image to destination '%s/dst/b': "+
+			"Expected tag '%s/dst/b:v1' to not exist or point at", reg.Host, reg.Host))
+		assert.Contains(t, err.Error(), fmt.Sprintf("but it points at '%s' (tag would be overwritten)", otherDigest))
+
+		assert.Equal(t, otherDigest, reg.tagDigest("dst/b", "v1"))
+		assert.Empty(t, reg.tagDigest("dst/b", "v0"))
+	})
+}
@@ -0,0 +1,102 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestResolveIntermediateTags(t *testing.T) {
+	reg := newFakeRegistry(t)
+
+	unrelatedDigestRef := reg.PushImage(t, reg.Host+"/app:latest")
+	reg.PushImage(t, reg.Host+"/app:build-stale")
+
+	srcDir := t.TempDir()
+
+	resolve := func(t *testing.T, label string) string {
+		archivePath, digest := writeOCIArchive(t, label)
+
+		bs, err := os.ReadFile(archivePath)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, "image.tar"), bs, 0600))
+
+		inputPath := filepath.Join(t.TempDir(), "input.yml")
+		require.NoError(t, os.WriteFile(inputPath, []byte(fmt.Sprintf(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: app
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+sources:
+- image: app
+  path: %s
+  archive:
+    file: image.tar
+destinations:
+- image: app
+  newImage: %s/app
+  tags: [stable]
+  intermediateTag:
+    template: "build-{{.ContentHash}}"
+    garbageCollect: true
+`, srcDir, reg.Host)), 0600))
+
+		var stdout bytes.Buffer
+
+		cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+		cmd.SetArgs([]string{"-f", inputPath, "--registry-insecure", "--progress=plain", "--images-annotation=false"})
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+
+		require.NoError(t, cmd.Execute())
+		assert.Contains(t, stdout.String(), "- image: "+reg.Host+"/app@"+digest)
+
+		return digest
+	}
+
+	buildTags := func() []string {
+		var result []string
+		for _, tag := range reg.Tags("app") {
+			if strings.HasPrefix(tag, "build-") {
+				result = append(result, tag)
+			}
+		}
+		return result
+	}
+
+	firstDigest := resolve(t, "first")
+
+	firstTags := buildTags()
+	require.Len(t, firstTags, 1)
+	assert.Regexp(t, "^build-[0-9a-f]{64}$", firstTags[0])
+
+	secondDigest := resolve(t, "second")
+
+	secondTags := buildTags()
+	require.Len(t, secondTags, 1)
+	assert.NotEqual(t, firstTags, secondTags)
+
+	// Only tags are deleted, images stay available by digest
+	assert.Equal(t, []string{secondTags[0], "latest", "stable"}, reg.Tags("app"))
+	assert.Contains(t, reg.Digests("app"), firstDigest)
+	assert.Contains(t, reg.Digests("app"), secondDigest)
+	assert.Contains(t, reg.Digests("app"), strings.Split(unrelatedDigestRef, "@")[1])
+}
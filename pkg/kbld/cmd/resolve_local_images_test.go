@@ -0,0 +1,125 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package cmd_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/testutil"
+)
+
+func TestResolveLocalImages(t *testing.T) {
+	reg := newFakeRegistry(t)
+	remoteDigestRef := reg.PushImage(t, reg.Host+"/remote:1.0")
+
+	localDigest := "sha256:" + strings.Repeat("b", 64)
+	inspectLog := filepath.Join(t.TempDir(), "inspect.log")
+
+	// Local stores know about images that were never pushed under their tags
+	scripts := map[string]string{}
+	for _, cli := range []string{"docker", "nerdctl"} {
+		scripts[cli] = `echo "` + cli + ` $@" >> ` + inspectLog + `
+for arg in "$@"; do ref="$arg"; done
+case "$ref" in
+*/digested:1.0)
+  echo '[{"Id": "sha256:` + strings.Repeat("a", 64) + `", "RepoDigests": ["other.corp/digested@sha256:` + strings.Repeat("c", 64) + `", "` + reg.Host + `/digested@` + localDigest + `"]}]' ;;
+*/undigested:1.0)
+  echo '[{"Id": "sha256:` + strings.Repeat("a", 64) + `", "RepoDigests": []}]' ;;
+*)
+  echo "Error: No such image: $ref" >&2; exit 1 ;;
+esac
+`
+	}
+	testutil.FakeBinaries(t, scripts)
+
+	resolve := func(t *testing.T, image, localImagesConf string) (string, error) {
+		require.NoError(t, os.RemoveAll(inspectLog))
+
+		inputPath := filepath.Join(t.TempDir(), "input.yml")
+		require.NoError(t, os.WriteFile(inputPath, []byte(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: `+image+`
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+localImages:
+`+localImagesConf), 0600))
+
+		var stdout bytes.Buffer
+
+		cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+		cmd.SetArgs([]string{"-f", inputPath, "--registry-insecure", "--progress=plain", "--images-annotation=false"})
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+
+		err := cmd.Execute()
+
+		return stdout.String(), err
+	}
+
+	inspectCalls := func(t *testing.T) string {
+		bs, err := os.ReadFile(inspectLog)
+		if os.IsNotExist(err) {
+			return ""
+		}
+		require.NoError(t, err)
+		return string(bs)
+	}
+
+	t.Run("uses repo digest of local image", func(t *testing.T) {
+		out, err := resolve(t, reg.Host+"/digested:1.0", "- {}\n")
+		require.NoError(t, err)
+		assert.Contains(t, out, "- image: "+reg.Host+"/digested@"+localDigest)
+		assert.Equal(t, "docker image inspect "+reg.Host+"/digested:1.0\n", inspectCalls(t))
+	})
+
+	t.Run("uses containerd namespace", func(t *testing.T) {
+		out, err := resolve(t, reg.Host+"/digested:1.0", "- store: containerd\n  namespace: builds\n")
+		require.NoError(t, err)
+		assert.Contains(t, out, "- image: "+reg.Host+"/digested@"+localDigest)
+		assert.Equal(t, "nerdctl --namespace builds image inspect "+reg.Host+"/digested:1.0\n", inspectCalls(t))
+	})
+
+	t.Run("prefers remote images", func(t *testing.T) {
+		out, err := resolve(t, reg.Host+"/remote:1.0", "- {}\n")
+		require.NoError(t, err)
+		assert.Contains(t, out, "- image: "+remoteDigestRef)
+		assert.Empty(t, inspectCalls(t))
+	})
+
+	t.Run("requires push of image without repo digest", func(t *testing.T) {
+		_, err := resolve(t, reg.Host+"/undigested:1.0", "- {}\n")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "to have repo digest of repository '"+reg.Host+"/undigested', "+
+			"but did not (hint: enable push of local image)")
+	})
+
+	t.Run("only resolves matching images locally", func(t *testing.T) {
+		_, err := resolve(t, reg.Host+"/digested:1.0", "- imageRepo: "+reg.Host+"/other\n")
+		require.Error(t, err)
+		assert.Empty(t, inspectCalls(t))
+	})
+
+	t.Run("reports missing local image", func(t *testing.T) {
+		_, err := resolve(t, reg.Host+"/missing:1.0", "- {}\n")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "also not found in local docker store")
+	})
+}
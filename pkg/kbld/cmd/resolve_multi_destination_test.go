@@ -0,0 +1,125 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestResolveCopiesToAdditionalDestinations(t *testing.T) {
+	reg := newFakeRegistry(t)
+
+	appDigest := strings.Split(reg.PushImage(t, reg.Host+"/src/app:1.0"), "@")[1]
+
+	stdout, err := resolveWithDestination(t, reg, `
+  newImages: [%[1]s/dst/a, %[1]s/dst/b]
+  outputNewImage: %[1]s/dst/b
+  tags: [v1]
+`)
+	require.NoError(t, err)
+
+	// Output uses selected destination instead of NewImage (first of NewImages)
+	assert.Contains(t, stdout, fmt.Sprintf("- image: %s/dst/b@%s\n", reg.Host, appDigest))
+
+	assert.Equal(t, []string{appDigest}, reg.Digests("dst/a"))
+	assert.Equal(t, []string{appDigest}, reg.Digests("dst/b"))
+
+	// Tags of primary destination are propagated to additional destinations
+	assert.Contains(t, reg.Tags("dst/a"), "v1")
+	assert.Contains(t, reg.Tags("dst/b"), "v1")
+}
+
+func TestResolveRetagModeRequiresSameRepository(t *testing.T) {
+	reg := newFakeRegistry(t)
+	reg.PushImage(t, reg.Host+"/src/app:1.0")
+
+	_, err := resolveWithDestination(t, reg, `
+  newImages: [%[1]s/src/app, %[1]s/other/app]
+  tags: [v1]
+  mode: retag
+`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprintf("Copying image to destination '%[1]s/other/app': "+
+		"Expected destination '%[1]s/other/app' to be the same repository as image '%[1]s/src/app@sha256:", reg.Host))
+	assert.Contains(t, err.Error(), "when using 'retag' mode")
+
+	assert.Empty(t, reg.Digests("other/app"))
+}
+
+func TestResolveRetagModeOnlyWritesTags(t *testing.T) {
+	reg := newFakeRegistry(t)
+
+	appDigest := strings.Split(reg.PushImage(t, reg.Host+"/src/app:1.0"), "@")[1]
+	pushRequests := len(reg.Requests())
+
+	stdout, err := resolveWithDestination(t, reg, `
+  newImage: %[1]s/src/app
+  tags: [v1, stable]
+  mode: retag
+`)
+	require.NoError(t, err)
+
+	assert.Contains(t, stdout, fmt.Sprintf("- image: %s/src/app@%s\n", reg.Host, appDigest))
+
+	assert.Equal(t, []string{appDigest}, reg.Digests("src/app"))
+	assert.Equal(t, appDigest, reg.tagDigest("src/app", "v1"))
+	assert.Equal(t, appDigest, reg.tagDigest("src/app", "stable"))
+
+	// Image is neither pulled nor pushed (e.g. via upload tag in copy mode),
+	// only tags are written (writing tag checks that config blob exists)
+	assert.Equal(t, []string{"1.0", "stable", "v1"}, reg.Tags("src/app"))
+
+	var puts []string
+	for _, req := range reg.Requests()[pushRequests:] {
+		assert.NotContains(t, req, "/blobs/uploads/")
+		assert.False(t, strings.HasPrefix(req, "GET ") && strings.Contains(req, "/blobs/"), req)
+		if strings.HasPrefix(req, "PUT ") {
+			puts = append(puts, req)
+		}
+	}
+	assert.Equal(t, []string{"PUT /v2/src/app/manifests/v1", "PUT /v2/src/app/manifests/stable"}, puts)
+}
+
+// resolveWithDestination resolves src/app:1.0 with destination configured by
+// given YAML (formatted with registry host)
+func resolveWithDestination(t *testing.T, reg *fakeRegistry, dstConf string) (string, error) {
+	inputPath := filepath.Join(t.TempDir(), "input.yml")
+	require.NoError(t, os.WriteFile(inputPath, []byte(fmt.Sprintf(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: %[1]s/src/app:1.0
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+destinations:
+- image: %[1]s/src/app:1.0
+  onlyIfBuilt: false
+`, reg.Host)+fmt.Sprintf(dstConf, reg.Host)), 0600))
+
+	var stdout bytes.Buffer
+
+	cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+	cmd.SetArgs([]string{"-f", inputPath, "--registry-insecure", "--progress=plain", "--images-annotation=false"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	err := cmd.Execute()
+
+	return stdout.String(), err
+}
@@ -0,0 +1,146 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestResolvePassThroughUnmodifiedDocuments(t *testing.T) {
+	digestRef := fmt.Sprintf("registry.example.com/app@sha256:%064d", 1)
+
+	unmodifiedDocs := []string{
+		`# Comments and key order are kept
+kind: ConfigMap
+apiVersion: v1
+metadata:
+  name: anchors
+defaults: &defaults
+  replicas: 3
+  tier: backend
+data:
+  <<: *defaults
+  tier: frontend
+`,
+		`apiVersion: example.com/v1
+kind: Custom
+metadata:
+  name: numbers
+spec:
+  big: 123456789012345678901234567890
+  float: 1.50
+  octal: 0o17
+  quoted: "yes"
+  folded: >
+    long
+    text
+`,
+		`apiVersion: v1
+kind: Secret
+metadata:
+  name: binary
+type: Opaque
+data:
+  blob: !!binary |
+    R0lGODlhIGJpbmFyeSAAAQL/IGNvbnRlbnQ=
+binaryData: {}
+`,
+		`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "json"}, "data": {"b": "2", "a": "1"}}
+`,
+		`apiVersion: v1
+kind: Pod
+metadata:
+  name: digest
+spec:
+  containers:
+  - image: ` + digestRef + `
+`,
+	}
+
+	modifiedDoc := `apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: app
+`
+
+	config := fmt.Sprintf(`apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+overrides:
+- image: app
+  newImage: %s
+  preresolved: true
+`, digestRef)
+
+	var input string
+	for _, doc := range unmodifiedDocs {
+		input += "---\n" + doc
+	}
+	input += "---\n" + modifiedDoc + "---\n" + config
+
+	inputPath := filepath.Join(t.TempDir(), "input.yml")
+	require.NoError(t, os.WriteFile(inputPath, []byte(input), 0600))
+
+	resolve := func(t *testing.T, args ...string) string {
+		var stdout bytes.Buffer
+
+		cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+		cmd.SetArgs(append([]string{"-f", inputPath, "--progress=plain", "--sort=false"}, args...))
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+
+		require.NoError(t, cmd.Execute())
+
+		return stdout.String()
+	}
+
+	expectedModifiedDoc := `apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: ` + digestRef + `
+`
+
+	for _, args := range [][]string{{"--images-annotation=false"}, {"--images-annotation=false", "--stream"}} {
+		t.Run(fmt.Sprintf("%v", args), func(t *testing.T) {
+			var expectedOut string
+			for _, doc := range unmodifiedDocs {
+				expectedOut += "---\n" + doc
+			}
+			expectedOut += "---\n" + expectedModifiedDoc
+
+			assert.Equal(t, expectedOut, resolve(t, args...))
+		})
+	}
+
+	t.Run("keeps document without trailing newline", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "input.yml")
+		require.NoError(t, os.WriteFile(path, []byte("kind: ConfigMap\napiVersion: v1\nmetadata: {name: a}"), 0600))
+
+		var stdout bytes.Buffer
+
+		cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+		cmd.SetArgs([]string{"-f", path, "-f", path, "--progress=plain"})
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+
+		require.NoError(t, cmd.Execute())
+		assert.Equal(t, "---\nkind: ConfigMap\napiVersion: v1\nmetadata: {name: a}\n"+
+			"---\nkind: ConfigMap\napiVersion: v1\nmetadata: {name: a}\n", stdout.String())
+	})
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package cmd_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestResolveRunsPrePushHooks(t *testing.T) {
+	reg := newFakeRegistry(t)
+
+	var lock sync.Mutex
+	var harborRequests []string
+
+	harbor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		lock.Lock()
+		harborRequests = append(harborRequests, req.Method+" "+req.URL.Path)
+		lock.Unlock()
+
+		if req.Method == http.MethodGet {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer harbor.Close()
+
+	hookLog := filepath.Join(t.TempDir(), "hook.log")
+	archivePath, digest := writeOCIArchive(t, "pre-push")
+
+	inputPath := filepath.Join(t.TempDir(), "input.yml")
+	require.NoError(t, os.WriteFile(inputPath, []byte(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: app
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+sources:
+- image: app
+  path: `+filepath.Dir(archivePath)+`
+  archive:
+    file: `+filepath.Base(archivePath)+`
+destinations:
+- image: app
+  newImage: `+reg.Host+`/team/app
+  prePush:
+  - command: [sh, -c, 'echo "$KBLD_PUSH_REPOSITORY" >> `+hookLog+`']
+  - harbor:
+      url: `+harbor.URL+`
+`), 0600))
+
+	var stdout bytes.Buffer
+
+	cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+	cmd.SetArgs([]string{"-f", inputPath, "--registry-insecure", "--progress=plain", "--images-annotation=false"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	err := cmd.Execute()
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "- image: "+reg.Host+"/team/app@"+digest)
+
+	hookLogBs, err := os.ReadFile(hookLog)
+	require.NoError(t, err)
+	assert.Equal(t, reg.Host+"/team/app\n", string(hookLogBs))
+
+	assert.Equal(t, []string{"GET /api/v2.0/projects/team", "POST /api/v2.0/projects"}, harborRequests)
+
+	t.Run("fails before pushing when hook fails", func(t *testing.T) {
+		failingInputPath := filepath.Join(t.TempDir(), "input.yml")
+		require.NoError(t, os.WriteFile(failingInputPath, []byte(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: app
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+sources:
+- image: app
+  path: `+filepath.Dir(archivePath)+`
+  archive:
+    file: `+filepath.Base(archivePath)+`
+destinations:
+- image: app
+  newImage: `+reg.Host+`/other/app
+  prePush:
+  - command: ["false"]
+`), 0600))
+
+		cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(io.Discard, io.Discard, ui.NewNoopLogger())))
+		cmd.SetArgs([]string{"-f", failingInputPath, "--registry-insecure", "--progress=plain"})
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+
+		err := cmd.Execute()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Running pre push hook 0 for '"+reg.Host+"/other/app': exit status 1")
+		assert.Empty(t, reg.Digests("other/app"))
+	})
+}
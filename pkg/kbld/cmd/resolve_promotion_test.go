@@ -0,0 +1,69 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestResolvePromotesExternalImages(t *testing.T) {
+	externalReg := newFakeRegistry(t)
+	internalReg := newFakeRegistry(t)
+
+	appDigestRef := externalReg.PushImage(t, externalReg.Host+"/library/app:1.0")
+	appDigest := strings.Split(appDigestRef, "@")[1]
+	internalDigestRef := internalReg.PushImage(t, internalReg.Host+"/team/internal:1.0")
+
+	inputPath := filepath.Join(t.TempDir(), "input.yml")
+	require.NoError(t, os.WriteFile(inputPath, []byte(fmt.Sprintf(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: %s/library/app:1.0
+  - image: %s/team/internal:1.0
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+promotion:
+  repository: %s/mirror
+`, externalReg.Host, internalReg.Host, internalReg.Host)), 0600))
+
+	var stdout bytes.Buffer
+
+	cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+	cmd.SetArgs([]string{"-f", inputPath, "--registry-insecure", "--progress=plain", "--images-annotation=false"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	require.NoError(t, cmd.Execute())
+
+	// Images already in internal registry are not copied
+	assert.Equal(t, fmt.Sprintf(`---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: %s/mirror/library/app@%s
+  - image: %s
+`, internalReg.Host, appDigest, internalDigestRef), stdout.String())
+
+	assert.Equal(t, []string{appDigest}, internalReg.Digests("mirror/library/app"))
+	assert.Empty(t, internalReg.Digests("mirror/team/internal"))
+}
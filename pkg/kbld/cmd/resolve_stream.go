@@ -0,0 +1,160 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+// resolveStreaming processes inputs document by document so that only
+// configuration, CRDs and image references are kept in memory. Inputs
+// are read several times: first to collect configuration (it may
+// appear anywhere in inputs), then to collect image references and
+// lastly to print updated resources as soon as each one is updated.
+func (o *ResolveOptions) resolveStreaming(logger *ctllog.Logger, pLogger *ctllog.PrefixWriter) error {
+	if len(o.OutputDir) > 0 {
+		return util.NewCategorizedError(util.ErrorCategoryConfig, fmt.Errorf("Expected '--stream' to not be used with '--output-dir'"))
+	}
+
+	tmpDir, err := util.MkdirTemp("kbld-stream")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fileRs, err := o.streamFileResources(tmpDir)
+	if err != nil {
+		return util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+
+	visitResources := func(visitFunc func(ctlres.Resource, string) error) error {
+		for _, fileRes := range fileRs {
+			err := fileRes.VisitResources(func(res ctlres.Resource) error {
+				if !ctlconf.IsNonConfigResource(res) {
+					return nil
+				}
+				return visitFunc(res, fileRes.RelativePath())
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var confRs, crdRs []ctlres.Resource
+
+	for _, fileRes := range fileRs {
+		err := fileRes.VisitResources(func(res ctlres.Resource) error {
+			if ctlconf.IsConfResource(res) {
+				confRs = append(confRs, res)
+			}
+			if o.CRDFlags.FromInputs && ctlconf.IsCRD(res) {
+				crdRs = append(crdRs, res)
+			}
+			return nil
+		})
+		if err != nil {
+			return util.NewCategorizedError(util.ErrorCategoryConfig, err)
+		}
+	}
+
+	_, conf, err := ctlconf.NewConfFromResources(confRs)
+	if err != nil {
+		return util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+
+	conf, resolvedImages, err := o.resolveImagesInResources(visitResources, crdRs, conf, logger, pLogger)
+	if err != nil || resolvedImages == nil {
+		return err
+	}
+
+	var errs []error
+	var companionBss [][]byte
+
+	err = visitResources(func(res ctlres.Resource, path string) error {
+		resBs, companionBs, err := o.updateRefsInResource(res, path, conf, resolvedImages, &errs)
+		if err != nil {
+			return err
+		}
+
+		o.ui.PrintBlock(append([]byte("---\n"), resBs...))
+
+		if companionBs != nil {
+			companionBss = append(companionBss, companionBs)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Updating resource references: %s", err)
+	}
+
+	// Companion ConfigMaps follow all resources (same as without streaming)
+	for _, companionBs := range companionBss {
+		o.ui.PrintBlock(append([]byte("---\n"), companionBs...))
+	}
+
+	err = errFromErrs(errs)
+	if err != nil {
+		return fmt.Errorf("Updating resource references: %s", err)
+	}
+
+	return nil
+}
+
+// streamFileResources returns input files that could be read multiple times
+// (stdin and HTTP inputs are copied into given directory)
+func (o *ResolveOptions) streamFileResources(tmpDir string) ([]ctlres.FileResource, error) {
+	var result []ctlres.FileResource
+
+	for _, file := range o.FileFlags.Files {
+		fileRs, err := ctlres.NewFileResources(file)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, fileRes := range fileRs {
+			if _, isLocal := fileRes.LocalPath(); isLocal {
+				result = append(result, fileRes)
+				continue
+			}
+
+			tmpPath := filepath.Join(tmpDir, fmt.Sprintf("%d-%s", len(result), filepath.Base(fileRes.RelativePath())))
+
+			err := spoolFileResource(fileRes, tmpPath)
+			if err != nil {
+				return nil, fmt.Errorf("Reading %s: %s", fileRes.Description(), err)
+			}
+
+			result = append(result, ctlres.NewFileResource(ctlres.NewLocalFileSource(tmpPath), fileRes.RelativePath()))
+		}
+	}
+
+	return result, nil
+}
+
+func spoolFileResource(fileRes ctlres.FileResource, path string) error {
+	reader, err := fileRes.Reader()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, reader)
+	return err
+}
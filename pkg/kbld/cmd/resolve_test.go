@@ -4,10 +4,16 @@
 package cmd_test
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/cppforlife/go-cli-ui/ui"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
 	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
 )
@@ -72,3 +78,59 @@ func TestNewPlatformSelection(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveStreaming(t *testing.T) {
+	digestRef := fmt.Sprintf("registry.example.com/app@sha256:%064d", 1)
+
+	// Config is at the end; resources are printed in input order
+	input := fmt.Sprintf(`
+kind: Pod
+metadata:
+  name: b
+spec:
+  containers:
+  - image: app
+---
+kind: Pod
+metadata:
+  name: a
+spec:
+  containers:
+  - image: app
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+overrides:
+- image: app
+  newImage: %s
+  preresolved: true
+`, digestRef)
+
+	path := filepath.Join(t.TempDir(), "input.yml")
+	require.NoError(t, os.WriteFile(path, []byte(input), 0600))
+
+	var stdout bytes.Buffer
+
+	cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+	cmd.SetArgs([]string{"-f", path, "--stream", "--images-annotation=false", "--progress=plain"})
+
+	require.NoError(t, cmd.Execute())
+
+	expectedOut := fmt.Sprintf(`---
+kind: Pod
+metadata:
+  name: b
+spec:
+  containers:
+  - image: %[1]s
+---
+kind: Pod
+metadata:
+  name: a
+spec:
+  containers:
+  - image: %[1]s
+`, digestRef)
+
+	assert.Equal(t, expectedOut, stdout.String())
+}
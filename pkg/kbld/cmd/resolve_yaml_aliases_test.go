@@ -0,0 +1,141 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestResolveYAMLAliases(t *testing.T) {
+	appRef := fmt.Sprintf("registry.example.com/app@sha256:%064d", 1)
+	sidecarRef := fmt.Sprintf("registry.example.com/sidecar@sha256:%064d", 2)
+
+	config := fmt.Sprintf(`---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+overrides:
+- image: app
+  newImage: %s
+  preresolved: true
+- image: sidecar
+  newImage: %s
+  preresolved: true
+`, appRef, sidecarRef)
+
+	resolve := func(t *testing.T, input string, args ...string) string {
+		inputPath := filepath.Join(t.TempDir(), "input.yml")
+		require.NoError(t, os.WriteFile(inputPath, []byte(input+config), 0600))
+
+		var stdout bytes.Buffer
+
+		cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+		cmd.SetArgs(append([]string{"-f", inputPath, "--progress=plain", "--images-annotation=false"}, args...))
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+
+		require.NoError(t, cmd.Execute())
+
+		return stdout.String()
+	}
+
+	t.Run("keeps anchors and aliases and merge keys", func(t *testing.T) {
+		out := resolve(t, `apiVersion: example.com/v1
+kind: Custom
+metadata:
+  name: app
+spec:
+  # Shared by all components
+  defaults: &defaults
+    image: app
+    pullPolicy: Always
+  web:
+    <<: *defaults
+    replicas: 2
+  worker:
+    <<: *defaults
+  sidecar: &sidecar
+    image: "sidecar"
+  sidecars:
+  - *sidecar
+  - *sidecar
+`)
+
+		assert.Equal(t, `---
+apiVersion: example.com/v1
+kind: Custom
+metadata:
+  name: app
+spec:
+  # Shared by all components
+  defaults: &defaults
+    image: `+appRef+`
+    pullPolicy: Always
+  web:
+    <<: *defaults
+    replicas: 2
+  worker:
+    <<: *defaults
+  sidecar: &sidecar
+    image: "`+sidecarRef+`"
+  sidecars:
+    - *sidecar
+    - *sidecar
+`, out)
+	})
+
+	t.Run("expands aliases updated differently in different places", func(t *testing.T) {
+		out := resolve(t, `apiVersion: example.com/v1
+kind: Custom
+metadata:
+  name: app
+spec:
+  image: &app app
+  appName: *app
+`)
+
+		assert.Equal(t, `---
+apiVersion: example.com/v1
+kind: Custom
+metadata:
+  name: app
+spec:
+  appName: app
+  image: `+appRef+`
+`, out)
+	})
+
+	t.Run("expands aliases when requested", func(t *testing.T) {
+		out := resolve(t, `apiVersion: example.com/v1
+kind: Custom
+metadata:
+  name: app
+spec:
+  web: &web
+    image: app
+  worker: *web
+`, "--expand-yaml-aliases")
+
+		assert.Equal(t, `---
+apiVersion: example.com/v1
+kind: Custom
+metadata:
+  name: app
+spec:
+  web:
+    image: `+appRef+`
+  worker:
+    image: `+appRef+`
+`, out)
+	})
+}
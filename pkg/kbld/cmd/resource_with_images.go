@@ -4,19 +4,34 @@
 package cmd
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"sort"
+	"strings"
 
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/yaml"
 )
 
 const (
-	ImagesAnnKey = "kbld.k14s.io/images"
+	ImagesAnnKey = ctlconf.DefaultImagesAnnotationKey
+	// ImagesForAnnKey identifies resource (apiVersion/kind/namespace/name)
+	// whose images metadata is carried by companion ConfigMap
+	ImagesForAnnKey = "kbld.k14s.io/images-for"
+
+	maxConfigMapSize = 1024 * 1024
 )
 
 type ResourceWithImages struct {
-	contents map[string]interface{}
-	images   []Image
+	contents   map[string]interface{}
+	images     []Image
+	annConf    ctlconf.ImagesAnnotation
+	originFile string
+	// original resource is used to keep YAML anchors and aliases
+	original ctlres.Resource
 }
 
 func NewResourceWithImages(contents map[string]interface{}, images []Image) ResourceWithImages {
@@ -24,31 +39,171 @@ func NewResourceWithImages(contents map[string]interface{}, images []Image) Reso
 	sort.Slice(images, func(i, j int) bool {
 		return images[i].URL < images[j].URL
 	})
-	return ResourceWithImages{contents, images}
+	return ResourceWithImages{contents: contents, images: images}
+}
+
+// WithAnnotationConf customizes images annotation; originFile
+// is recorded for each image when configured to be included
+func (r ResourceWithImages) WithAnnotationConf(annConf ctlconf.ImagesAnnotation, originFile string) ResourceWithImages {
+	r.annConf = annConf
+	r.originFile = originFile
+	return r
 }
 
+// WithOriginal keeps anchors and aliases of document original
+// resource was parsed from (when updated contents could be expressed with them)
+func (r ResourceWithImages) WithOriginal(original ctlres.Resource) ResourceWithImages {
+	r.original = original
+	return r
+}
+
+// Bytes returns resource with images annotation
+// (companion ConfigMap, if any, is omitted; see BytesWithCompanion)
 func (r ResourceWithImages) Bytes() ([]byte, error) {
+	resBs, _, err := r.BytesWithCompanion()
+	return resBs, err
+}
+
+// BytesWithCompanion returns resource with images annotation and, when
+// annotation overflows into a ConfigMap, companion ConfigMap (nil otherwise)
+func (r ResourceWithImages) BytesWithCompanion() ([]byte, []byte, error) {
+	var companionBs []byte
+
 	if len(r.images) > 0 {
 		resUn := unstructured.Unstructured{r.contents}
 
-		imagesYAML, err := yaml.Marshal(newImageStructs(r.images))
-		if err != nil {
-			return nil, err
-		}
-
 		anns := resUn.GetAnnotations()
 		if anns == nil {
 			anns = map[string]string{}
 		}
 
-		anns[ImagesAnnKey] = string(imagesYAML)
+		annKey := r.annConf.KeyWithDefaults()
+
+		imagesYAML, overflows, err := r.annotationValue()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch {
+		case overflows && r.annConf.OverflowWithDefaults() == ctlconf.ImagesAnnotationOverflowConfigMap:
+			var name string
+			name, companionBs, err = r.companionConfigMap(resUn, imagesYAML)
+			if err != nil {
+				return nil, nil, err
+			}
+			anns[annKey+"-configmap"] = name
+
+		case overflows:
+			imagesYAML, err = r.truncatedAnnotationValue()
+			if err != nil {
+				return nil, nil, err
+			}
+			// Avoid adding an empty annotation when nothing fits
+			if len(imagesYAML) > 0 {
+				anns[annKey] = imagesYAML
+			}
+			anns[annKey+"-truncated"] = "true"
+
+		default:
+			anns[annKey] = imagesYAML
+		}
+
 		resUn.SetAnnotations(anns)
 		r.contents = resUn.Object
 	}
 
+	resBs, err := r.contentsBytes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resBs, companionBs, nil
+}
+
+func (r ResourceWithImages) contentsBytes() ([]byte, error) {
+	if r.original != nil {
+		if docBs, found := r.original.OriginalBytes(); found {
+			resBs, updated, err := ctlres.UpdateYAMLDocWithAliases(docBs, r.original.DeepCopyRaw(), r.contents)
+			if err != nil || updated {
+				return resBs, err
+			}
+		}
+	}
 	return yaml.Marshal(r.contents)
 }
 
+// annotationValue returns full annotation value and
+// whether it exceeds configured maximum size
+func (r ResourceWithImages) annotationValue() (string, bool, error) {
+	imagesYAML, err := yaml.Marshal(newImageStructsWithOpts(r.images, r.annConf.Include, r.originFile))
+	if err != nil {
+		return "", false, err
+	}
+
+	maxSize := r.annConf.MaxSizeWithDefaults()
+
+	return string(imagesYAML), maxSize > 0 && len(imagesYAML) > maxSize, nil
+}
+
+// truncatedAnnotationValue returns annotation value that fits into configured maximum size
+func (r ResourceWithImages) truncatedAnnotationValue() (string, error) {
+	structs := newImageStructsWithOpts(r.images, r.annConf.Include, r.originFile)
+	maxSize := r.annConf.MaxSizeWithDefaults()
+
+	// Omit origins as they are the largest part of the annotation
+	for i := range structs {
+		structs[i].Origins = nil
+	}
+
+	for len(structs) > 0 {
+		imagesYAML, err := yaml.Marshal(structs)
+		if err != nil {
+			return "", err
+		}
+		if len(imagesYAML) <= maxSize {
+			return string(imagesYAML), nil
+		}
+		structs = structs[:len(structs)-1]
+	}
+
+	return "", nil
+}
+
+// companionConfigMap returns ConfigMap carrying full images metadata of the resource.
+// Its name is derived from resource identity so that it is stable across runs.
+func (r ResourceWithImages) companionConfigMap(resUn unstructured.Unstructured, imagesYAML string) (string, []byte, error) {
+	if len(imagesYAML) > maxConfigMapSize {
+		return "", nil, fmt.Errorf("Expected images metadata of %s %s to fit into ConfigMap (%d bytes), but was %d bytes",
+			resUn.GetKind(), resUn.GetName(), maxConfigMapSize, len(imagesYAML))
+	}
+
+	resID := strings.Join([]string{resUn.GetAPIVersion(), resUn.GetKind(), resUn.GetNamespace(), resUn.GetName()}, "/")
+	sum := sha256.Sum256([]byte(resID))
+	name := "kbld-images-" + hex.EncodeToString(sum[:])[:16]
+
+	metadata := map[string]interface{}{
+		"name":        name,
+		"annotations": map[string]interface{}{ImagesForAnnKey: resID},
+	}
+	if len(resUn.GetNamespace()) > 0 {
+		metadata["namespace"] = resUn.GetNamespace()
+	}
+
+	configMap := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   metadata,
+		"data":       map[string]interface{}{"images.yml": imagesYAML},
+	}
+
+	configMapBs, err := yaml.Marshal(configMap)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return name, configMapBs, nil
+}
+
 func (r ResourceWithImages) Images() ([]Image, error) {
 	resUn := unstructured.Unstructured{r.contents}
 
@@ -59,7 +214,7 @@ func (r ResourceWithImages) Images() ([]Image, error) {
 
 	var structs []imageStruct
 
-	err := yaml.Unmarshal([]byte(anns[ImagesAnnKey]), &structs)
+	err := yaml.Unmarshal([]byte(anns[r.annConf.KeyWithDefaults()]), &structs)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,129 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	"sigs.k8s.io/yaml"
+)
+
+func TestResourceWithImagesAnnotationConf(t *testing.T) {
+	newImages := func() []ctlcmd.Image {
+		var images []ctlcmd.Image
+		for i := 1; i <= 3; i++ {
+			images = append(images, ctlcmd.Image{
+				URL: fmt.Sprintf("registry.example.com/app%d@sha256:%064d", i, i),
+				Origins: []ctlconf.Origin{{Resolved: &ctlconf.OriginResolved{
+					URL: fmt.Sprintf("registry.example.com/app%d:latest", i),
+					Tag: "latest",
+				}}},
+			})
+		}
+		return images
+	}
+
+	annotations := func(t *testing.T, resBs []byte) map[string]string {
+		var res struct {
+			Metadata struct {
+				Annotations map[string]string `json:"annotations"`
+			} `json:"metadata"`
+		}
+		require.NoError(t, yaml.Unmarshal(resBs, &res))
+		return res.Metadata.Annotations
+	}
+
+	t.Run("uses custom key and includes origin file", func(t *testing.T) {
+		annConf := ctlconf.ImagesAnnotation{
+			Key:     "example.com/images",
+			Include: ctlconf.ImagesAnnotationInclude{OriginFile: true},
+		}
+
+		resBs, err := ctlcmd.NewResourceWithImages(map[string]interface{}{"kind": "Object"}, newImages()).
+			WithAnnotationConf(annConf, "app.yml").Bytes()
+		require.NoError(t, err)
+
+		anns := annotations(t, resBs)
+		assert.NotContains(t, anns, ctlcmd.ImagesAnnKey)
+		assert.Contains(t, anns["example.com/images"], "originFile: app.yml")
+	})
+
+	t.Run("omits origins and then images when exceeding max size", func(t *testing.T) {
+		withoutOriginsBs, err := yaml.Marshal([]map[string]string{
+			{"url": newImages()[0].URL},
+			{"url": newImages()[1].URL},
+		})
+		require.NoError(t, err)
+
+		annConf := ctlconf.ImagesAnnotation{MaxSize: len(withoutOriginsBs)}
+
+		resBs, err := ctlcmd.NewResourceWithImages(map[string]interface{}{"kind": "Object"}, newImages()).
+			WithAnnotationConf(annConf, "").Bytes()
+		require.NoError(t, err)
+
+		anns := annotations(t, resBs)
+		assert.Equal(t, string(withoutOriginsBs), anns[ctlcmd.ImagesAnnKey])
+		assert.Equal(t, "true", anns[ctlcmd.ImagesAnnKey+"-truncated"])
+	})
+
+	t.Run("keeps full annotation when within max size", func(t *testing.T) {
+		resBs, err := ctlcmd.NewResourceWithImages(map[string]interface{}{"kind": "Object"}, newImages()).
+			WithAnnotationConf(ctlconf.ImagesAnnotation{MaxSize: 100000}, "").Bytes()
+		require.NoError(t, err)
+
+		anns := annotations(t, resBs)
+		assert.Contains(t, anns[ctlcmd.ImagesAnnKey], "origins:")
+		assert.NotContains(t, anns, ctlcmd.ImagesAnnKey+"-truncated")
+	})
+
+	t.Run("moves annotation into companion ConfigMap when exceeding max size", func(t *testing.T) {
+		annConf := ctlconf.ImagesAnnotation{MaxSize: 10, Overflow: ctlconf.ImagesAnnotationOverflowConfigMap}
+		contents := map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "app", "namespace": "ns1"},
+		}
+
+		resBs, companionBs, err := ctlcmd.NewResourceWithImages(contents, newImages()).
+			WithAnnotationConf(annConf, "").BytesWithCompanion()
+		require.NoError(t, err)
+		require.NotNil(t, companionBs)
+
+		anns := annotations(t, resBs)
+		assert.NotContains(t, anns, ctlcmd.ImagesAnnKey)
+		assert.NotContains(t, anns, ctlcmd.ImagesAnnKey+"-truncated")
+
+		var configMap struct {
+			Kind     string
+			Metadata struct {
+				Name        string
+				Namespace   string
+				Annotations map[string]string
+			}
+			Data map[string]string
+		}
+		require.NoError(t, yaml.Unmarshal(companionBs, &configMap))
+
+		assert.Equal(t, "ConfigMap", configMap.Kind)
+		assert.Equal(t, anns[ctlcmd.ImagesAnnKey+"-configmap"], configMap.Metadata.Name)
+		assert.Equal(t, "ns1", configMap.Metadata.Namespace)
+		assert.Equal(t, "apps/v1/Deployment/ns1/app", configMap.Metadata.Annotations[ctlcmd.ImagesForAnnKey])
+		assert.Contains(t, configMap.Data["images.yml"], "origins:")
+	})
+
+	t.Run("does not produce companion ConfigMap when within max size", func(t *testing.T) {
+		annConf := ctlconf.ImagesAnnotation{Overflow: ctlconf.ImagesAnnotationOverflowConfigMap}
+
+		resBs, companionBs, err := ctlcmd.NewResourceWithImages(map[string]interface{}{"kind": "Object"}, newImages()).
+			WithAnnotationConf(annConf, "").BytesWithCompanion()
+		require.NoError(t, err)
+		require.Nil(t, companionBs)
+		assert.Contains(t, annotations(t, resBs)[ctlcmd.ImagesAnnKey], "origins:")
+	})
+}
@@ -0,0 +1,212 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+// RunReport records what happened to each image during resolution
+// so that it could be consumed by pipelines without parsing logs
+type RunReport struct {
+	now     func() time.Time
+	started time.Time
+
+	images     map[string]*RunReportImage
+	imagesLock sync.Mutex
+
+	references int
+	processed  int
+	warnings   []Warning
+}
+
+var _ ImageProgress = &RunReport{}
+
+type RunReportImage struct {
+	URL        string    `json:"url"`
+	Action     string    `json:"action"`
+	StartedAt  time.Time `json:"startedAt"`
+	DurationMs int64     `json:"durationMs"`
+	// FinalURL is digest reference used in the output
+	FinalURL string `json:"finalURL,omitempty"`
+	// CacheHit indicates that image was preresolved (e.g. from a lock file)
+	// or resumed from state file and did not need to be built or resolved against a registry
+	CacheHit      bool               `json:"cacheHit"`
+	Error         string             `json:"error,omitempty"`
+	ErrorCategory util.ErrorCategory `json:"errorCategory,omitempty"`
+}
+
+type RunReportSummary struct {
+	Images    int `json:"images"`
+	Failed    int `json:"failed"`
+	CacheHits int `json:"cacheHits"`
+	// CoalescedReferences is number of image references that
+	// reused result of identical reference processed in the same run
+	CoalescedReferences int   `json:"coalescedReferences"`
+	Requests            int64 `json:"registryRequests"`
+	BytesUploaded       int64 `json:"bytesUploaded"`
+	BytesDownloaded     int64 `json:"bytesDownloaded"`
+}
+
+type runReportFile struct {
+	StartedAt     time.Time          `json:"startedAt"`
+	DurationMs    int64              `json:"durationMs"`
+	Succeeded     bool               `json:"succeeded"`
+	Error         string             `json:"error,omitempty"`
+	ErrorCategory util.ErrorCategory `json:"errorCategory,omitempty"`
+	ExitCode      int                `json:"exitCode"`
+	Summary       RunReportSummary   `json:"summary"`
+	Images        []RunReportImage   `json:"images"`
+	Warnings      []Warning          `json:"warnings,omitempty"`
+}
+
+func NewRunReport() *RunReport {
+	return &RunReport{now: time.Now, started: time.Now(), images: map[string]*RunReportImage{}}
+}
+
+func (r *RunReport) Started(url, action string) {
+	r.imagesLock.Lock()
+	defer r.imagesLock.Unlock()
+
+	r.images[url] = &RunReportImage{URL: url, Action: action, StartedAt: r.now()}
+}
+
+func (r *RunReport) Finished(url string, err error) {
+	r.imagesLock.Lock()
+	defer r.imagesLock.Unlock()
+
+	img, found := r.images[url]
+	if !found {
+		return
+	}
+
+	img.DurationMs = r.now().Sub(img.StartedAt).Milliseconds()
+	if err != nil {
+		img.Error = err.Error()
+		img.ErrorCategory = util.ErrorCategoryOf(err)
+	}
+}
+
+// Complete records final image references
+func (r *RunReport) Complete(resolvedImages *ProcessedImages) {
+	if resolvedImages == nil {
+		return
+	}
+
+	r.imagesLock.Lock()
+	defer r.imagesLock.Unlock()
+
+	for _, pair := range resolvedImages.All() {
+		img, found := r.images[pair.UnprocessedImageURL.URL]
+		if !found {
+			continue
+		}
+		img.FinalURL = pair.Image.URL
+		img.CacheHit = img.Action == "resumed"
+
+		for _, origin := range pair.Image.Origins {
+			if origin.Preresolved != nil {
+				img.CacheHit = true
+			}
+		}
+	}
+}
+
+// RecordReferences records total number of image references
+// found in resources and number of actually processed images
+func (r *RunReport) RecordReferences(references, processed int) {
+	r.imagesLock.Lock()
+	defer r.imagesLock.Unlock()
+
+	r.references = references
+	r.processed = processed
+}
+
+// RecordWarnings records warnings reported at the end of a run
+func (r *RunReport) RecordWarnings(warnings []Warning) {
+	r.imagesLock.Lock()
+	defer r.imagesLock.Unlock()
+
+	r.warnings = warnings
+}
+
+func (r *RunReport) Images() []RunReportImage {
+	r.imagesLock.Lock()
+	defer r.imagesLock.Unlock()
+
+	var result []RunReportImage
+	for _, img := range r.images {
+		result = append(result, *img)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].URL < result[j].URL
+	})
+	return result
+}
+
+func (r *RunReport) Bytes(stats *ctlreg.TransferStats, runErr error) ([]byte, error) {
+	file := runReportFile{
+		StartedAt:  r.started,
+		DurationMs: r.now().Sub(r.started).Milliseconds(),
+		Succeeded:  runErr == nil,
+		Images:     r.Images(),
+	}
+	if runErr != nil {
+		file.Error = runErr.Error()
+		file.ErrorCategory = util.ErrorCategoryOf(runErr)
+		file.ExitCode = file.ErrorCategory.ExitCode()
+	}
+
+	file.Summary.Images = len(file.Images)
+	for _, img := range file.Images {
+		if len(img.Error) > 0 {
+			file.Summary.Failed++
+		}
+		if img.CacheHit {
+			file.Summary.CacheHits++
+		}
+	}
+
+	r.imagesLock.Lock()
+	if r.references > r.processed {
+		file.Summary.CoalescedReferences = r.references - r.processed
+	}
+	file.Warnings = r.warnings
+	r.imagesLock.Unlock()
+
+	if stats != nil {
+		file.Summary.Requests = stats.Requests()
+		file.Summary.BytesUploaded = stats.Uploaded()
+		file.Summary.BytesDownloaded = stats.Downloaded()
+	}
+
+	bs, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(bs, '\n'), nil
+}
+
+func (r *RunReport) WriteToFile(path string, stats *ctlreg.TransferStats, runErr error) error {
+	bs, err := r.Bytes(stats, runErr)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(path, bs, 0600)
+	if err != nil {
+		return fmt.Errorf("Writing run report '%s': %s", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+func TestRunReport(t *testing.T) {
+	report := ctlcmd.NewRunReport()
+
+	report.Started("nginx:1.17", "resolving")
+	report.Started("redis", "preresolved")
+	report.Started("app", "building")
+
+	report.Finished("nginx:1.17", nil)
+	report.Finished("redis", nil)
+	report.Finished("app", util.NewCategorizedError(util.ErrorCategoryBuild, fmt.Errorf("build failed")))
+
+	resolvedImages := ctlcmd.NewProcessedImages()
+	resolvedImages.Add(ctlcmd.UnprocessedImageURL{URL: "nginx:1.17"}, ctlcmd.Image{
+		URL: "index.docker.io/library/nginx@sha256:1",
+	})
+	resolvedImages.Add(ctlcmd.UnprocessedImageURL{URL: "redis"}, ctlcmd.Image{
+		URL:     "index.docker.io/library/redis@sha256:2",
+		Origins: []ctlconf.Origin{{Preresolved: &ctlconf.OriginPreresolved{URL: "redis"}}},
+	})
+
+	report.Complete(resolvedImages)
+
+	runErr := util.NewCategorizedError(util.ErrorCategoryBuild, fmt.Errorf("Resolving image 'app': build failed"))
+
+	bs, err := report.Bytes(ctlreg.NewTransferStats(), runErr)
+	require.NoError(t, err)
+
+	var result struct {
+		Succeeded     bool
+		Error         string
+		ErrorCategory util.ErrorCategory
+		ExitCode      int
+		Summary       ctlcmd.RunReportSummary
+		Images        []ctlcmd.RunReportImage
+	}
+
+	require.NoError(t, json.Unmarshal(bs, &result))
+
+	assert.False(t, result.Succeeded)
+	assert.Equal(t, "Resolving image 'app': build failed", result.Error)
+	assert.Equal(t, util.ErrorCategoryBuild, result.ErrorCategory)
+	assert.Equal(t, 3, result.ExitCode)
+	assert.Equal(t, ctlcmd.RunReportSummary{Images: 3, Failed: 1, CacheHits: 1}, result.Summary)
+
+	require.Len(t, result.Images, 3)
+
+	assert.Equal(t, "app", result.Images[0].URL)
+	assert.Equal(t, "building", result.Images[0].Action)
+	assert.Equal(t, "build failed", result.Images[0].Error)
+	assert.Equal(t, util.ErrorCategoryBuild, result.Images[0].ErrorCategory)
+	assert.Empty(t, result.Images[0].FinalURL)
+
+	assert.Equal(t, "nginx:1.17", result.Images[1].URL)
+	assert.Equal(t, "index.docker.io/library/nginx@sha256:1", result.Images[1].FinalURL)
+	assert.False(t, result.Images[1].CacheHit)
+
+	assert.Equal(t, "redis", result.Images[2].URL)
+	assert.True(t, result.Images[2].CacheHit)
+}
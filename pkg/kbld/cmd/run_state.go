@@ -0,0 +1,143 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+// RunState persists successfully processed images so that
+// a failed run could be resumed without processing them again
+type RunState struct {
+	path       string
+	imgFactory ctlimg.Factory
+
+	images     map[string]RunStateImage
+	imagesLock sync.Mutex
+}
+
+type RunStateImage struct {
+	// Fingerprint covers image processing plan and contents of its source directory;
+	// entry is not reused when fingerprint changes
+	Fingerprint string           `json:"fingerprint"`
+	URL         string           `json:"url"`
+	Origins     []ctlconf.Origin `json:"origins,omitempty"`
+}
+
+type runStateFile struct {
+	Images map[string]RunStateImage `json:"images"`
+}
+
+// NewRunState loads previously recorded images when resuming,
+// otherwise it starts with an empty state file
+func NewRunState(path string, resume bool, imgFactory ctlimg.Factory) (*RunState, error) {
+	state := &RunState{path: path, imgFactory: imgFactory, images: map[string]RunStateImage{}}
+
+	if !resume {
+		return state, state.write()
+	}
+
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, fmt.Errorf("Reading state file '%s': %s", path, err)
+	}
+
+	var file runStateFile
+
+	err = json.Unmarshal(bs, &file)
+	if err != nil {
+		return nil, fmt.Errorf("Unmarshaling state file '%s': %s", path, err)
+	}
+
+	if file.Images != nil {
+		state.images = file.Images
+	}
+
+	return state, nil
+}
+
+// Find returns previously recorded image if it is still up to date
+func (s *RunState) Find(url string) (Image, bool) {
+	s.imagesLock.Lock()
+	img, found := s.images[url]
+	s.imagesLock.Unlock()
+
+	if !found {
+		return Image{}, false
+	}
+
+	fingerprint, err := s.fingerprint(url)
+	if err != nil || fingerprint != img.Fingerprint {
+		return Image{}, false
+	}
+
+	return Image{URL: img.URL, Origins: img.Origins}, true
+}
+
+// Record saves processed image (state file is rewritten after each image
+// so that progress is kept even if process is interrupted)
+func (s *RunState) Record(url string, img Image) error {
+	fingerprint, err := s.fingerprint(url)
+	if err != nil {
+		return fmt.Errorf("Calculating state fingerprint for '%s': %s", url, err)
+	}
+
+	s.imagesLock.Lock()
+	defer s.imagesLock.Unlock()
+
+	s.images[url] = RunStateImage{Fingerprint: fingerprint, URL: img.URL, Origins: img.Origins}
+
+	return s.write()
+}
+
+func (s *RunState) fingerprint(url string) (string, error) {
+	plan, err := s.imgFactory.Plan(url)
+	if err != nil {
+		return "", err
+	}
+
+	planBs, err := json.Marshal(plan)
+	if err != nil {
+		return "", err
+	}
+
+	var srcFingerprint string
+
+	if plan.Action == ctlimg.PlanActionBuild {
+		srcFingerprint, err = util.NewWatcher(0).Fingerprint([]string{plan.BuildPath})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(append(planBs, srcFingerprint...))), nil
+}
+
+func (s *RunState) write() error {
+	bs, err := json.MarshalIndent(runStateFile{s.images}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write via rename so that state file is never partially written
+	tmpPath := s.path + ".kbld-tmp"
+
+	err = os.WriteFile(tmpPath, append(bs, '\n'), 0600)
+	if err != nil {
+		return fmt.Errorf("Writing state file '%s': %s", s.path, err)
+	}
+
+	return os.Rename(tmpPath, s.path)
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+func TestRunStateResume(t *testing.T) {
+	dir := t.TempDir()
+	srcDir := filepath.Join(dir, "src")
+	statePath := filepath.Join(dir, "state.json")
+
+	require.NoError(t, os.Mkdir(srcDir, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "Dockerfile"), []byte("FROM scratch"), 0600))
+
+	configYAML := `
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+sources:
+- image: app
+  path: ` + srcDir + `
+`
+
+	rs, err := ctlres.NewResourcesFromBytes([]byte(configYAML))
+	require.NoError(t, err)
+
+	_, conf, err := ctlconf.NewConfFromResources(rs)
+	require.NoError(t, err)
+
+	factory := ctlimg.NewFactory(ctlimg.FactoryOpts{Conf: conf, AllowedToBuild: true},
+		ctlreg.Registry{}, ctllog.NewLogger(io.Discard))
+
+	state, err := ctlcmd.NewRunState(statePath, false, factory)
+	require.NoError(t, err)
+
+	appImg := ctlcmd.Image{URL: "registry.example.com/app@sha256:1"}
+	nginxImg := ctlcmd.Image{URL: "index.docker.io/library/nginx@sha256:2"}
+
+	require.NoError(t, state.Record("app", appImg))
+	require.NoError(t, state.Record("nginx", nginxImg))
+
+	state, err = ctlcmd.NewRunState(statePath, true, factory)
+	require.NoError(t, err)
+
+	img, found := state.Find("app")
+	require.True(t, found)
+	assert.Equal(t, appImg, img)
+
+	t.Run("source changes invalidate built images", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, "Dockerfile"), []byte("FROM scratch\n# changed"), 0600))
+
+		_, found := state.Find("app")
+		assert.False(t, found)
+
+		img, found := state.Find("nginx")
+		require.True(t, found)
+		assert.Equal(t, nginxImg, img)
+	})
+
+	t.Run("not resuming starts with empty state", func(t *testing.T) {
+		state, err := ctlcmd.NewRunState(statePath, false, factory)
+		require.NoError(t, err)
+
+		_, found := state.Find("nginx")
+		assert.False(t, found)
+	})
+}
@@ -0,0 +1,278 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/spf13/cobra"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlmetrics "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/metrics"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+type ServeOptions struct {
+	ui ui.UI
+
+	RegistryFlags RegistryFlags
+	LoggerFlags   LoggerFlags
+
+	Address          string
+	AllowedToBuild   bool
+	BuildConcurrency int
+	MaxRequestBytes  int64
+	Pprof            bool
+	// AllowRestrictedConfig accepts request configuration that runs commands,
+	// pushes images or accesses local files (see Conf.RestrictedSettings)
+	AllowRestrictedConfig bool
+}
+
+func NewServeOptions(ui ui.UI) *ServeOptions {
+	return &ServeOptions{ui: ui}
+}
+
+func NewServeCmd(o *ServeOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:         "serve",
+		Short:       "Run HTTP server resolving image references in submitted resources",
+		Annotations: map[string]string{handlesSignalsAnnotation: ""},
+		RunE:        func(_ *cobra.Command, _ []string) error { return o.Run() },
+	}
+	o.RegistryFlags.Set(cmd)
+	o.LoggerFlags.Set(cmd)
+	cmd.Flags().StringVar(&o.Address, "address", "127.0.0.1:8080", "Set address to listen on")
+	cmd.Flags().BoolVar(&o.AllowedToBuild, "build", false, "Allow building of images")
+	cmd.Flags().IntVar(&o.BuildConcurrency, "build-concurrency", 4, "Set maximum number of concurrent builds per request")
+	cmd.Flags().Int64Var(&o.MaxRequestBytes, "max-request-bytes", 10*1024*1024, "Set maximum size of request body")
+	cmd.Flags().BoolVar(&o.Pprof, "pprof", false, "Expose runtime profiles at /debug/pprof/")
+	cmd.Flags().BoolVar(&o.AllowRestrictedConfig, "allow-restricted-config", false,
+		"Allow request configuration to run commands, push images (e.g. destinations) and access local files")
+	return cmd
+}
+
+func (o *ServeOptions) Run() error {
+	logger, closeLogger, err := o.LoggerFlags.NewLogger()
+	if err != nil {
+		return err
+	}
+	defer closeLogger()
+
+	registryOpts, err := o.RegistryFlags.AsRegistryOpts()
+	if err != nil {
+		return err
+	}
+
+	// Registry is shared by all requests so that connections
+	// and credential lookups are reused
+	metrics := ctlmetrics.New()
+	registryOpts.Metrics = metrics
+
+	registry, err := ctlreg.NewRegistry(registryOpts)
+	if err != nil {
+		return err
+	}
+
+	resolveServer := NewResolveServer(o.ui, registry, logger, *o).WithMetrics(metrics)
+	if o.Pprof {
+		resolveServer.WithPprof()
+	}
+
+	server := &http.Server{
+		Addr:              o.Address,
+		Handler:           resolveServer,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	prefixedLogger := logger.NewPrefixedWriter("serve | ")
+	prefixedLogger.WriteStr("listening on %s\n", o.Address)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.ListenAndServe() }()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		prefixedLogger.WriteStr("shutting down\n")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		return server.Shutdown(shutdownCtx)
+	}
+}
+
+// ResolveServer resolves images in resources submitted via HTTP.
+// Request body is a YAML stream of resources and kbld configuration.
+type ResolveServer struct {
+	ui       ui.UI
+	registry ctlreg.Registry
+	logger   ctllog.Logger
+	opts     ServeOptions
+	mux      *http.ServeMux
+	metrics  *ctlmetrics.Metrics
+
+	digestCache *ctlimg.DigestCache
+}
+
+var _ http.Handler = &ResolveServer{}
+
+func NewResolveServer(ui ui.UI, registry ctlreg.Registry, logger ctllog.Logger, opts ServeOptions) *ResolveServer {
+	s := &ResolveServer{ui: ui, registry: registry, logger: logger, opts: opts, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/healthz", s.healthz)
+	s.mux.HandleFunc("/v1/resolve", s.resolve)
+	return s
+}
+
+// WithMetrics records resolutions and exposes metrics at /metrics
+func (s *ResolveServer) WithMetrics(metrics *ctlmetrics.Metrics) *ResolveServer {
+	s.metrics = metrics
+	s.mux.Handle("/metrics", metrics.Handler())
+	return s
+}
+
+// WithDigestCache shares given digest cache between requests
+func (s *ResolveServer) WithDigestCache(digestCache *ctlimg.DigestCache) *ResolveServer {
+	s.digestCache = digestCache
+	return s
+}
+
+// WithPprof exposes runtime profiles at /debug/pprof/
+func (s *ResolveServer) WithPprof() *ResolveServer {
+	registerPprof(s.mux)
+	return s
+}
+
+func (s *ResolveServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *ResolveServer) healthz(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok\n"))
+}
+
+type resolveServerError struct {
+	Error    string             `json:"error"`
+	Category util.ErrorCategory `json:"category"`
+}
+
+func (s *ResolveServer) resolve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		s.writeErr(w, http.StatusMethodNotAllowed, fmt.Errorf("Expected POST request"))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.opts.MaxRequestBytes))
+	if err != nil {
+		status := http.StatusBadRequest
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		s.writeErr(w, status, fmt.Errorf("Reading request body: %s", err))
+		return
+	}
+
+	resBss, err := s.resolveBytes(body, r.URL.Query().Get("platform"))
+	if err != nil {
+		s.writeErr(w, s.errStatus(err), err)
+		return
+	}
+
+	var output []byte
+	for _, resBs := range resBss {
+		output = append(output, append([]byte("---\n"), resBs...)...)
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(output)
+}
+
+func (s *ResolveServer) resolveBytes(body []byte, platform string) ([][]byte, error) {
+	started := time.Now()
+
+	resBss, err := s.resolveBytesWithoutMetrics(body, platform)
+	s.metrics.ObserveResolution("serve", time.Since(started), err)
+
+	return resBss, err
+}
+
+func (s *ResolveServer) resolveBytesWithoutMetrics(body []byte, platform string) ([][]byte, error) {
+	rs, err := ctlres.NewFileResource(ctlres.NewBytesSource(body), "request.yml").Resources()
+	if err != nil {
+		return nil, util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+
+	nonConfigRs, conf, err := ctlconf.NewConfFromResources(rs)
+	if err != nil {
+		return nil, util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+
+	if settings := conf.RestrictedSettings(); len(settings) > 0 && !s.opts.AllowRestrictedConfig {
+		return nil, util.NewCategorizedError(util.ErrorCategoryConfig, fmt.Errorf(
+			"Expected configuration to not use restricted settings (allowed with '--allow-restricted-config'), but used: %s",
+			strings.Join(settings, ", ")))
+	}
+
+	resolveOpts := &ResolveOptions{
+		ui:                s.ui,
+		AllowedToBuild:    s.opts.AllowedToBuild,
+		BuildConcurrency:  s.opts.BuildConcurrency,
+		ImagesAnnotation:  true,
+		OriginsAnnotation: true,
+		Platform:          platform,
+		registry:          &s.registry,
+		metrics:           s.metrics,
+		sharedDigestCache: s.digestCache,
+	}
+
+	pLogger := s.logger.NewPrefixedWriter("serve | ")
+
+	resBss, _, err := resolveOpts.resolveConfiguredResources(nonConfigRs, conf, &s.logger, pLogger)
+	return resBss, err
+}
+
+func (s *ResolveServer) errStatus(err error) int {
+	switch util.ErrorCategoryOf(err) {
+	case util.ErrorCategoryConfig:
+		return http.StatusBadRequest
+	case util.ErrorCategoryPolicy:
+		return http.StatusUnprocessableEntity
+	case util.ErrorCategoryResolution, util.ErrorCategoryPush:
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (s *ResolveServer) writeErr(w http.ResponseWriter, status int, err error) {
+	s.logger.NewPrefixedWriter("serve | ").WriteStr("error: %s\n", err)
+
+	body, _ := json.Marshal(resolveServerError{Error: err.Error(), Category: util.ErrorCategoryOf(err)})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
@@ -0,0 +1,190 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlmetrics "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/metrics"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+func TestResolveServer(t *testing.T) {
+	registry, err := ctlreg.NewRegistry(ctlreg.Opts{})
+	require.NoError(t, err)
+
+	metrics := ctlmetrics.New()
+
+	server := httptest.NewServer(ctlcmd.NewResolveServer(ui.NewConfUI(ui.NewNoopLogger()), registry,
+		ctllog.NewLogger(io.Discard), ctlcmd.ServeOptions{BuildConcurrency: 1, MaxRequestBytes: 1024 * 1024}).WithMetrics(metrics))
+	defer server.Close()
+
+	t.Run("resolves submitted resources", func(t *testing.T) {
+		input := `
+kind: Object
+spec:
+- image: app
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+overrides:
+- image: app
+  newImage: registry.example.com/app@sha256:0000000000000000000000000000000000000000000000000000000000000001
+`
+		resp, err := http.Post(server.URL+"/v1/resolve", "application/yaml", strings.NewReader(input))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+		assert.Contains(t, string(body), "- image: registry.example.com/app@sha256:0000000000000000000000000000000000000000000000000000000000000001")
+		assert.NotContains(t, string(body), "kind: Config")
+	})
+
+	t.Run("returns config errors as bad requests", func(t *testing.T) {
+		resp, err := http.Post(server.URL+"/v1/resolve", "application/yaml", strings.NewReader("kind: [unclosed"))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Contains(t, string(body), `"category":"config"`)
+	})
+
+	t.Run("rejects restricted settings in submitted config", func(t *testing.T) {
+		marker := filepath.Join(t.TempDir(), "marker")
+
+		for setting, config := range map[string]string{
+			"destinations": `
+destinations:
+- image: app
+  newImage: registry.example.com/app
+  postPush:
+  - command: [touch, ` + marker + `]`,
+			"promotion": `
+promotion:
+  repository: registry.example.com/mirror`,
+			"vulnerabilityScan.command": `
+vulnerabilityScan:
+  command: [touch, ` + marker + `]`,
+			"sources[].sbom.command": `
+sources:
+- image: app
+  path: .
+  sbom:
+    command: [touch, ` + marker + `]
+    attach: true`,
+			"credentials[].auth.authFile": `
+credentials:
+- image: app
+  auth:
+    authFile: /root/.docker/config.json`,
+		} {
+			input := "kind: Object\nspec:\n- image: app\n---\napiVersion: kbld.k14s.io/v1alpha1\nkind: Config\n" + config
+
+			resp, err := http.Post(server.URL+"/v1/resolve", "application/yaml", strings.NewReader(input))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, setting)
+			assert.Contains(t, string(body), `"category":"config"`, setting)
+			assert.Contains(t, string(body), "Expected configuration to not use restricted settings "+
+				"(allowed with '--allow-restricted-config'), but used: "+setting, setting)
+		}
+
+		_, err := os.Stat(marker)
+		assert.True(t, os.IsNotExist(err), "Expected commands to not run")
+	})
+
+	t.Run("requires POST", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/v1/resolve")
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+
+	t.Run("exposes metrics", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/metrics")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, string(body), `kbld_resolutions_total{mode="serve",result="success"} 1`)
+		assert.Contains(t, string(body), `kbld_image_cache_requests_total{result="miss"} 1`)
+	})
+}
+
+func TestResolveServerPprof(t *testing.T) {
+	registry, err := ctlreg.NewRegistry(ctlreg.Opts{})
+	require.NoError(t, err)
+
+	server := httptest.NewServer(ctlcmd.NewResolveServer(ui.NewConfUI(ui.NewNoopLogger()), registry,
+		ctllog.NewLogger(io.Discard), ctlcmd.ServeOptions{BuildConcurrency: 1}).WithPprof())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/debug/pprof/heap?debug=1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "heap profile")
+}
+
+func TestResolveServerAllowsRestrictedConfig(t *testing.T) {
+	registry, err := ctlreg.NewRegistry(ctlreg.Opts{})
+	require.NoError(t, err)
+
+	server := httptest.NewServer(ctlcmd.NewResolveServer(ui.NewConfUI(ui.NewNoopLogger()), registry,
+		ctllog.NewLogger(io.Discard), ctlcmd.ServeOptions{BuildConcurrency: 1, MaxRequestBytes: 1024 * 1024, AllowRestrictedConfig: true}))
+	defer server.Close()
+
+	// Destination does not apply to overridden image, hence nothing is pushed
+	input := `
+kind: Object
+spec:
+- image: app
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+overrides:
+- image: app
+  newImage: registry.example.com/app@sha256:0000000000000000000000000000000000000000000000000000000000000001
+destinations:
+- image: other
+  newImage: registry.example.com/other
+`
+	resp, err := http.Post(server.URL+"/v1/resolve", "application/yaml", strings.NewReader(input))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+}
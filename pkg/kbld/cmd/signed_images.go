@@ -0,0 +1,72 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	ctlsign "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/signing"
+)
+
+// SignImages signs imported images when signing is configured
+func SignImages(conf ctlconf.Conf, images *ProcessedImages,
+	registry ctlreg.Registry, logger ctllog.Logger) (*ProcessedImages, error) {
+
+	signingConf := conf.Signing()
+	if signingConf == nil {
+		return images, nil
+	}
+
+	signer := ctlsign.NewSigner(*signingConf, registry, logger)
+	signedImages := NewProcessedImages()
+
+	for _, item := range images.All() {
+		sig, err := signer.Sign(item.Image.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		signed := &ctlconf.OriginSigned{}
+		signed.Add(sig.URL, sig.TransparencyLogEntry)
+
+		img := item.Image
+		img.Origins = append(append([]ctlconf.Origin{}, img.Origins...), ctlconf.Origin{Signed: signed})
+
+		signedImages.Add(item.UnprocessedImageURL, img)
+	}
+
+	return signedImages, nil
+}
+
+// signedOrigins returns signature (and rebase) origins to be recorded in lock files
+// with base images built images were built from (as build materials)
+// and builder invocations that built them
+func signedOrigins(origins []ctlconf.Origin) []ctlconf.Origin {
+	var result []ctlconf.Origin
+	for _, origin := range origins {
+		if origin.Signed != nil || origin.Rebased != nil || origin.BaseImages != nil || origin.Built != nil {
+			result = append(result, origin)
+		}
+	}
+	return result
+}
+
+// lockOrigins returns origins to be recorded in lock files. Tag resolution
+// is additionally recorded when freshness checks need to detect moved tags.
+func lockOrigins(conf ctlconf.Conf, origins []ctlconf.Origin) []ctlconf.Origin {
+	freshnessConf := conf.ImageFreshness()
+	if freshnessConf == nil || !freshnessConf.TagMoved {
+		return signedOrigins(origins)
+	}
+
+	var result []ctlconf.Origin
+	for _, origin := range origins {
+		if origin.Signed != nil || origin.Rebased != nil || origin.BaseImages != nil || origin.Built != nil ||
+			origin.Resolved != nil || origin.PlatformSelected != nil {
+			result = append(result, origin)
+		}
+	}
+	return result
+}
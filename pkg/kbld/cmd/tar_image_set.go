@@ -9,6 +9,7 @@ import (
 	"os"
 
 	regname "github.com/google/go-containerregistry/pkg/name"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
 	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/imagetar"
 	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
 	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
@@ -20,6 +21,16 @@ type TarImageSet struct {
 	logger      *ctllog.PrefixWriter
 }
 
+func NewTarImageSet(concurrency int, logger *ctllog.PrefixWriter, includeNonDistributable bool) TarImageSet {
+	return TarImageSet{ImageSet{concurrency, logger, includeNonDistributable, nil, nil}, concurrency, logger}
+}
+
+// WithPlatforms limits images imported from indexes to given platforms
+func (o TarImageSet) WithPlatforms(platforms []ctlconf.PlatformSelection) TarImageSet {
+	o.imageSet.platforms = platforms
+	return o
+}
+
 func (o TarImageSet) Export(foundImages *UnprocessedImageURLs,
 	outputPath string, registry ctlreg.Registry) error {
 
@@ -44,7 +55,10 @@ func (o TarImageSet) Export(foundImages *UnprocessedImageURLs,
 
 	o.logger.WriteStr("writing layers...\n")
 
-	opts := imagetar.TarWriterOpts{Concurrency: o.concurrency}
+	opts := imagetar.TarWriterOpts{
+		Concurrency:             o.concurrency,
+		IncludeNonDistributable: o.imageSet.includeNonDistributable,
+	}
 
 	return imagetar.NewTarWriter(ids, outputFileOpener, opts, o.logger).Write()
 }
@@ -52,7 +66,9 @@ func (o TarImageSet) Export(foundImages *UnprocessedImageURLs,
 func (o *TarImageSet) Import(path string,
 	importRepo regname.Repository, registry ctlreg.Registry) (*ProcessedImages, error) {
 
-	imgOrIndexes, err := imagetar.NewTarReader(path).Read()
+	readerOpts := imagetar.TarReaderOpts{RequireNonDistributable: o.imageSet.includeNonDistributable}
+
+	imgOrIndexes, err := imagetar.NewTarReaderWithOpts(path, readerOpts).Read()
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,109 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+var (
+	// colorDisabled is set from UI flags and applies to output
+	// that is written directly to terminal (e.g. progress table)
+	// instead of going through UI
+	colorDisabled bool
+)
+
+// TerminalStyle describes how human-facing output written
+// directly to terminal should be formatted
+type TerminalStyle struct {
+	Color   bool
+	Unicode bool
+	// Width returns number of columns (0 when unknown)
+	Width func() int
+}
+
+// NewTerminalStyle detects style for given file: color is only used
+// for terminals (and not when NO_COLOR is set or TERM is dumb)
+// and unicode is only used with UTF-8 locale
+func NewTerminalStyle(file *os.File) TerminalStyle {
+	fd := int(file.Fd())
+	isTerminal := term.IsTerminal(fd)
+
+	return TerminalStyle{
+		Color:   isTerminal && ColorAllowed() && os.Getenv("TERM") != "dumb",
+		Unicode: isTerminal && localeUsesUTF8(),
+		Width: func() int {
+			width, _, err := term.GetSize(fd)
+			if err != nil {
+				return 0
+			}
+			return width
+		},
+	}
+}
+
+// ColorAllowed returns false when color was disabled via flags
+// or NO_COLOR env variable (see https://no-color.org)
+func ColorAllowed() bool {
+	return !colorDisabled && len(os.Getenv("NO_COLOR")) == 0
+}
+
+func (s TerminalStyle) width() int {
+	if s.Width == nil {
+		return 0
+	}
+	return s.Width()
+}
+
+func (s TerminalStyle) green(str string) string { return s.colorize("32", str) }
+func (s TerminalStyle) red(str string) string   { return s.colorize("31", str) }
+
+func (s TerminalStyle) colorize(code, str string) string {
+	if !s.Color {
+		return str
+	}
+	return "\x1b[" + code + "m" + str + "\x1b[0m"
+}
+
+// truncate shortens string to given number of characters (not bytes)
+func (s TerminalStyle) truncate(str string, width int) string {
+	if utf8.RuneCountInString(str) <= width {
+		return str
+	}
+
+	ellipsis := "..."
+	if s.Unicode {
+		ellipsis = "…"
+	}
+
+	runes := []rune(str)
+	keep := width - utf8.RuneCountInString(ellipsis)
+	if keep < 0 {
+		keep = 0
+	}
+
+	return string(runes[:keep]) + ellipsis
+}
+
+// padRight pads string with spaces to given number of characters (not bytes)
+func padRight(str string, width int) string {
+	if count := utf8.RuneCountInString(str); count < width {
+		return str + strings.Repeat(" ", width-count)
+	}
+	return str
+}
+
+func localeUsesUTF8() bool {
+	for _, envVar := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if val := os.Getenv(envVar); len(val) > 0 {
+			val = strings.ToLower(val)
+			return strings.Contains(val, "utf-8") || strings.Contains(val, "utf8")
+		}
+	}
+	return false
+}
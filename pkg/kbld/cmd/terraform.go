@@ -0,0 +1,153 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/spf13/cobra"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+	ctlser "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/search"
+	ctltf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/terraform"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type TerraformOptions struct {
+	ui ui.UI
+
+	RegistryFlags RegistryFlags
+	LoggerFlags   LoggerFlags
+
+	File        string
+	ConfigFiles []string
+	LockOutput  string
+	Platform    string
+}
+
+func NewTerraformOptions(ui ui.UI) *TerraformOptions {
+	return &TerraformOptions{ui: ui}
+}
+
+func NewTerraformCmd(o *TerraformOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "terraform",
+		Short: "Resolve images in kubernetes_manifest and helm_release resources of Terraform JSON (configuration or plan)",
+		RunE:  func(_ *cobra.Command, _ []string) error { return o.Run() },
+	}
+	o.RegistryFlags.Set(cmd)
+	o.LoggerFlags.Set(cmd)
+	cmd.Flags().StringVarP(&o.File, "file", "f", "", "Set Terraform JSON file, e.g. main.tf.json or output of 'terraform show -json' (format: /tmp/foo, https://..., -)")
+	cmd.Flags().StringSliceVarP(&o.ConfigFiles, "config", "c", nil, "Set kbld configuration file (can be specified multiple times)")
+	cmd.Flags().StringVar(&o.LockOutput, "lock-output", "", "File path to emit configuration with resolved image references")
+	cmd.Flags().StringVar(&o.Platform, "platform", "", "Apply platform selection to image indexes")
+	return cmd
+}
+
+func (o *TerraformOptions) Run() error {
+	if len(o.File) == 0 {
+		return util.NewCategorizedError(util.ErrorCategoryConfig, fmt.Errorf("Expected '--file' to be specified"))
+	}
+
+	logger, closeLogger, err := o.LoggerFlags.NewLogger()
+	if err != nil {
+		return err
+	}
+	defer closeLogger()
+
+	tfFile, err := o.readFile()
+	if err != nil {
+		return util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+
+	configFlags := FileFlags{Files: o.ConfigFiles}
+
+	_, conf, err := configFlags.ResourcesAndConfig()
+	if err != nil {
+		return util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+
+	docs, err := tfFile.Documents()
+	if err != nil {
+		return util.NewCategorizedError(util.ErrorCategoryConfig, err)
+	}
+
+	var rs []ctlres.Resource
+	for _, doc := range docs {
+		rs = append(rs, ctlres.NewResourceUnstructured(
+			unstructured.Unstructured{Object: doc.Contents}, schema.GroupVersionResource{}))
+	}
+
+	resolveOpts := &ResolveOptions{
+		ui:               o.ui,
+		RegistryFlags:    o.RegistryFlags,
+		BuildConcurrency: 1, // building is not allowed
+		LockOutput:       o.LockOutput,
+		Platform:         o.Platform,
+	}
+
+	conf, resolvedImages, err := resolveOpts.resolveImagesInResources(
+		newSliceResourceVisitor(rs), nil, conf, &logger, logger.NewPrefixedWriter("terraform | "))
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, doc := range docs {
+		ctlser.NewImageRefs(doc.Contents, conf.SearchRules()).Visit(func(imgURL string) (string, bool) {
+			img, found := resolvedImages.FindByURL(UnprocessedImageURL{imgURL})
+			if !found {
+				errs = append(errs, fmt.Errorf("Expected to find image for '%s' (in %s)", imgURL, doc.Address))
+				return "", false
+			}
+			return img.URL, true
+		})
+
+		err := doc.Write()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = errFromErrs(errs)
+	if err != nil {
+		return fmt.Errorf("Updating image references: %s", err)
+	}
+
+	bs, err := tfFile.Bytes()
+	if err != nil {
+		return err
+	}
+
+	o.ui.PrintBlock(bs)
+
+	return nil
+}
+
+func (o *TerraformOptions) readFile() (*ctltf.File, error) {
+	fileRs, err := ctlres.NewFileResources(o.File)
+	if err != nil {
+		return nil, err
+	}
+	if len(fileRs) != 1 {
+		return nil, fmt.Errorf("Expected '--file' to be a single file, but found %d files", len(fileRs))
+	}
+
+	reader, err := fileRs[0].Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	bs, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("Reading %s: %s", fileRs[0].Description(), err)
+	}
+
+	return ctltf.NewFileFromBytes(bs)
+}
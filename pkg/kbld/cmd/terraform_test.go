@@ -0,0 +1,53 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestTerraform(t *testing.T) {
+	dir := t.TempDir()
+	digestRef := fmt.Sprintf("registry.example.com/app@sha256:%064d", 1)
+
+	tfPath := filepath.Join(dir, "main.tf.json")
+	require.NoError(t, os.WriteFile(tfPath, []byte(`{"resource": {"kubernetes_manifest": {"app": {"manifest": {
+  "kind": "Deployment",
+  "spec": {"template": {"spec": {"containers": [{"name": "app", "image": "app"}]}}}
+}}}}}`), 0600))
+
+	configPath := filepath.Join(dir, "kbld.yml")
+	require.NoError(t, os.WriteFile(configPath, []byte(fmt.Sprintf(`
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+overrides:
+- image: app
+  newImage: %s
+  preresolved: true
+`, digestRef)), 0600))
+
+	lockPath := filepath.Join(dir, "lock.yml")
+
+	var stdout bytes.Buffer
+	cmd := ctlcmd.NewTerraformCmd(ctlcmd.NewTerraformOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+	cmd.SetArgs([]string{"-f", tfPath, "-c", configPath, "--lock-output", lockPath, "--log-level", "error"})
+	require.NoError(t, cmd.Execute())
+
+	assert.Contains(t, stdout.String(), fmt.Sprintf(`"image": "%s"`, digestRef))
+	assert.NotContains(t, stdout.String(), "annotations")
+
+	lockBs, err := os.ReadFile(lockPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(lockBs), "newImage: "+digestRef)
+}
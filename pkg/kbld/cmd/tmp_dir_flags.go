@@ -0,0 +1,94 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+// handlesSignalsAnnotation marks long running commands (e.g. serve) that
+// shut down gracefully on signals, hence temporary directories are only
+// removed once command returns (in-flight requests may still use them)
+const handlesSignalsAnnotation = "kbld.k14s.io/handles-signals"
+
+type TmpDirFlags struct {
+	Dir          string
+	MaxDiskUsage string
+}
+
+func (f *TmpDirFlags) Set(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&f.Dir, "tmp-dir", "", "Set directory to stage temporary files (layers, tarballs) in (also used by builders via TMPDIR)")
+	cmd.PersistentFlags().StringVar(&f.MaxDiskUsage, "max-disk-usage", "", "Fail when temporary files use more disk space than given size (e.g. 10GiB)")
+}
+
+// WrapRunE configures temporary directories before running the command
+// and removes them once command completes, fails or is interrupted
+func (f *TmpDirFlags) WrapRunE(cmd *cobra.Command) {
+	origRunE := cmd.RunE
+	if origRunE == nil {
+		return
+	}
+
+	cmd.RunE = func(cmd2 *cobra.Command, args []string) error {
+		var maxBytes int64
+
+		if len(f.MaxDiskUsage) > 0 {
+			var err error
+			maxBytes, err = util.ParseSize(f.MaxDiskUsage)
+			if err != nil {
+				return util.NewCategorizedError(util.ErrorCategoryConfig, err)
+			}
+		}
+
+		err := util.ConfigureTmpDirs(f.Dir, maxBytes)
+		if err != nil {
+			return util.NewCategorizedError(util.ErrorCategoryConfig, err)
+		}
+
+		if len(f.Dir) > 0 {
+			// External tools (docker, pack, etc.) honor TMPDIR
+			os.Setenv("TMPDIR", f.Dir)
+		}
+
+		if _, found := cmd2.Annotations[handlesSignalsAnnotation]; !found {
+			stopCleanup := cleanupTmpDirsOnSignal()
+			defer stopCleanup()
+		}
+		defer util.CleanupTmpDirs()
+
+		return origRunE(cmd2, args)
+	}
+}
+
+// cleanupTmpDirsOnSignal removes temporary directories when process
+// is interrupted (deferred functions do not run in that case) and
+// then delivers signal again so that process still terminates
+func cleanupTmpDirsOnSignal() func() {
+	signalCh := make(chan os.Signal, 1)
+	doneCh := make(chan struct{})
+
+	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-signalCh:
+			util.CleanupTmpDirs()
+			signal.Stop(signalCh)
+			if proc, err := os.FindProcess(os.Getpid()); err == nil {
+				proc.Signal(sig)
+			}
+		case <-doneCh:
+		}
+	}()
+
+	return func() {
+		signal.Stop(signalCh)
+		close(doneCh)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"sync"
+
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/tracing"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+// TracingProgress records span for each processed image
+// (i.e. its build and push, or its resolution)
+type TracingProgress struct {
+	tracer *tracing.Tracer
+
+	spans     map[string]*tracing.Span
+	spansLock sync.Mutex
+}
+
+var _ ImageProgress = &TracingProgress{}
+var _ ImageContextProgress = &TracingProgress{}
+
+func NewTracingProgress(tracer *tracing.Tracer) *TracingProgress {
+	return &TracingProgress{tracer: tracer, spans: map[string]*tracing.Span{}}
+}
+
+func (p *TracingProgress) Started(url, action string) {
+	span := p.tracer.Start(nil, "image "+action, tracing.SpanKindInternal, map[string]interface{}{
+		"kbld.image.url": url,
+		"kbld.action":    action,
+	})
+
+	p.spansLock.Lock()
+	defer p.spansLock.Unlock()
+
+	p.spans[url] = span
+}
+
+// Context makes image span parent of spans started while processing image
+// (e.g. its registry requests)
+func (p *TracingProgress) Context(ctx context.Context, url string) context.Context {
+	p.spansLock.Lock()
+	defer p.spansLock.Unlock()
+
+	return tracing.ContextWithSpan(ctx, p.spans[url])
+}
+
+func (p *TracingProgress) Finished(url string, err error) {
+	p.spansLock.Lock()
+	span, found := p.spans[url]
+	delete(p.spans, url)
+	p.spansLock.Unlock()
+
+	if !found {
+		return
+	}
+	if err != nil {
+		span.SetAttr("kbld.error_category", string(util.ErrorCategoryOf(err)))
+	}
+	span.End(err)
+}
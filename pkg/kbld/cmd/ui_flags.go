@@ -12,6 +12,7 @@ import (
 type UIFlags struct {
 	TTY            bool
 	Color          bool
+	NoColor        bool
 	JSON           bool
 	NonInteractive bool
 	Columns        []string
@@ -20,6 +21,7 @@ type UIFlags struct {
 func (f *UIFlags) Set(cmd *cobra.Command) {
 	cmd.PersistentFlags().BoolVar(&f.TTY, "tty", false, "Force TTY-like output")
 	cmd.PersistentFlags().BoolVar(&f.Color, "color", true, "Set color output")
+	cmd.PersistentFlags().BoolVar(&f.NoColor, "no-color", false, "Disable color output (also disabled when NO_COLOR env variable is set or output is not a terminal)")
 	cmd.PersistentFlags().BoolVar(&f.JSON, "json", false, "Output as JSON")
 	cmd.PersistentFlags().BoolVarP(&f.NonInteractive, "yes", "y", false, "Assume yes for any prompt")
 	cmd.PersistentFlags().StringSliceVar(&f.Columns, "column", nil, "Filter to show only given columns")
@@ -28,7 +30,9 @@ func (f *UIFlags) Set(cmd *cobra.Command) {
 func (f *UIFlags) ConfigureUI(ui *ui.ConfUI) {
 	ui.EnableTTY(f.TTY)
 
-	if f.Color {
+	colorDisabled = !f.Color || f.NoColor
+
+	if ColorAllowed() {
 		ui.EnableColor()
 	}
 
@@ -5,7 +5,6 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/cppforlife/go-cli-ui/ui"
 	regname "github.com/google/go-containerregistry/pkg/name"
@@ -24,10 +23,14 @@ type UnpackageOptions struct {
 
 	FileFlags     FileFlags
 	RegistryFlags RegistryFlags
+	LoggerFlags   LoggerFlags
 	InputPath     string
 	Repository    string
 	LockOutput    string
 	Concurrency   int
+
+	IncludeNonDistributable bool
+	Platforms               []string
 }
 
 func NewUnpackageOptions(ui ui.UI) *UnpackageOptions {
@@ -49,17 +52,26 @@ Unpackage configuration and images from tarball
 	}
 	o.FileFlags.Set(cmd)
 	o.RegistryFlags.Set(cmd)
+	o.LoggerFlags.Set(cmd)
+	o.RegistryFlags.SetBandwidth(cmd)
 	cmd.Flags().StringVarP(&o.InputPath, "input", "i", "", "Input tarball path")
 	cmd.Flags().StringVarP(&o.Repository, "repository", "r", "", "Import images into given image repository (e.g. docker.io/dkalinin/my-project)")
 	cmd.Flags().StringVar(&o.LockOutput, "lock-output", "", "File path to emit configuration with resolved image references")
 	cmd.Flags().IntVar(&o.Concurrency, "concurrency", 5, "Set maximum number of concurrent imports")
+	cmd.Flags().BoolVar(&o.IncludeNonDistributable, "include-non-distributable", false, "Push non-distributable (foreign) layers found in tarball")
+	cmd.Flags().StringSliceVar(&o.Platforms, "platform", nil, "Only import images of given platforms from image indexes (format: os/arch[/variant][:os.version], can be specified multiple times)")
 	return cmd
 }
 
 func (o *UnpackageOptions) Run() error {
-	logger := ctllog.NewLogger(os.Stderr)
+	logger, closeLogger, err := o.LoggerFlags.NewLogger()
+	if err != nil {
+		return err
+	}
+	defer closeLogger()
+
 	warningLogger := logger.NewPrefixedWriter("Warning: ")
-	err := warningLogger.WriteStr(`Command "unpackage" is deprecated, please use 'imgpkg pull', learn more in https://carvel.dev/imgpkg/docs/latest/commands/#pull`)
+	err = warningLogger.WriteLevelStr(ctllog.LevelWarn, `Command "unpackage" is deprecated, please use 'imgpkg pull', learn more in https://carvel.dev/imgpkg/docs/latest/commands/#pull`)
 	if err != nil {
 		return err
 	}
@@ -71,8 +83,6 @@ func (o *UnpackageOptions) Run() error {
 		return fmt.Errorf("Expected 'repository' flag to be non-empty")
 	}
 
-	prefixedLogger := logger.NewPrefixedWriter("unpackage | ")
-
 	nonConfigRs, conf, err := o.FileFlags.ResourcesAndConfig()
 	if err != nil {
 		return err
@@ -83,26 +93,19 @@ func (o *UnpackageOptions) Run() error {
 		return fmt.Errorf("Building import repository ref: %s", err)
 	}
 
-	registry, err := ctlreg.NewRegistry(o.RegistryFlags.AsRegistryOpts())
+	registryOpts, err := o.RegistryFlags.AsRegistryOpts()
 	if err != nil {
 		return err
 	}
 
-	imageSet := TarImageSet{ImageSet{o.Concurrency, prefixedLogger}, o.Concurrency, prefixedLogger}
+	registryOpts.IncludeNonDistributable = o.IncludeNonDistributable
 
-	// Import images used in the manifests
-	importedImages, err := imageSet.Import(o.InputPath, importRepo, registry)
+	registry, err := ctlreg.NewRegistry(registryOpts)
 	if err != nil {
 		return err
 	}
 
-	err = o.emitLockOutput(conf, importedImages)
-	if err != nil {
-		return err
-	}
-
-	// Update previous image references with new references
-	resBss, err := o.updateRefsInResources(nonConfigRs, conf, importedImages)
+	resBss, err := o.UnpackageResources(nonConfigRs, conf, importRepo, registry, logger)
 	if err != nil {
 		return err
 	}
@@ -116,6 +119,40 @@ func (o *UnpackageOptions) Run() error {
 	return nil
 }
 
+// UnpackageResources imports images from input tarball into repository
+// and returns resources updated to refer to imported images
+func (o *UnpackageOptions) UnpackageResources(nonConfigRs []ctlres.Resource, conf ctlconf.Conf,
+	importRepo regname.Repository, registry ctlreg.Registry, logger ctllog.Logger) ([][]byte, error) {
+
+	prefixedLogger := logger.NewPrefixedWriter("unpackage | ")
+
+	platforms, err := NewPlatformSelections(o.Platforms)
+	if err != nil {
+		return nil, err
+	}
+
+	imageSet := NewTarImageSet(o.Concurrency, prefixedLogger, o.IncludeNonDistributable).WithPlatforms(platforms)
+
+	// Import images used in the manifests
+	importedImages, err := imageSet.Import(o.InputPath, importRepo, registry)
+	if err != nil {
+		return nil, err
+	}
+
+	importedImages, err = SignImages(conf, importedImages, registry, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	err = o.emitLockOutput(conf, importedImages)
+	if err != nil {
+		return nil, err
+	}
+
+	// Update previous image references with new references
+	return o.updateRefsInResources(nonConfigRs, conf, importedImages)
+}
+
 func (o *UnpackageOptions) updateRefsInResources(
 	nonConfigRs []ctlres.Resource, conf ctlconf.Conf,
 	resolvedImages *ProcessedImages) ([][]byte, error) {
@@ -169,9 +206,10 @@ func (o *UnpackageOptions) emitLockOutput(conf ctlconf.Conf, resolvedImages *Pro
 			}
 
 			c.Overrides = append(c.Overrides, ctlconf.ImageOverride{
-				ImageRef:    override.ImageRef,
-				NewImage:    img.URL,
-				Preresolved: true,
+				ImageRef:     override.ImageRef,
+				NewImage:     img.URL,
+				Preresolved:  true,
+				ImageOrigins: signedOrigins(img.Origins),
 			})
 		}
 	}
@@ -182,8 +220,9 @@ func (o *UnpackageOptions) emitLockOutput(conf ctlconf.Conf, resolvedImages *Pro
 			ImageRef: ctlconf.ImageRef{
 				Image: urlImagePair.UnprocessedImageURL.URL,
 			},
-			NewImage:    urlImagePair.Image.URL,
-			Preresolved: true,
+			NewImage:     urlImagePair.Image.URL,
+			Preresolved:  true,
+			ImageOrigins: signedOrigins(urlImagePair.Image.Origins),
 		})
 	}
 
@@ -0,0 +1,132 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	regname "github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	regremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	regtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestPackageUnpackageNonDistributableLayers(t *testing.T) {
+	srcReg := newFakeRegistry(t)
+
+	layerPath := filepath.Join(t.TempDir(), "layer.tar")
+	writeTar(t, layerPath, map[string][]byte{"base.txt": []byte("windows-base")})
+
+	foreignLayer, err := tarball.LayerFromFile(layerPath, tarball.WithMediaType(regtypes.DockerForeignLayer))
+	require.NoError(t, err)
+
+	foreignDigest, err := foreignLayer.Digest()
+	require.NoError(t, err)
+
+	img, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer:     foreignLayer,
+		MediaType: regtypes.DockerForeignLayer,
+		URLs:      []string{"https://example.invalid/" + foreignDigest.String()},
+	})
+	require.NoError(t, err)
+
+	appRef, err := regname.ParseReference(srcReg.Host+"/src/app:1.0", regname.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, regremote.Write(appRef, img, regremote.WithNondistributable))
+
+	appDigest, err := img.Digest()
+	require.NoError(t, err)
+
+	inputPath := filepath.Join(t.TempDir(), "input.yml")
+	require.NoError(t, os.WriteFile(inputPath, []byte(fmt.Sprintf(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: %s/src/app@%s
+`, srcReg.Host, appDigest)), 0600))
+
+	t.Run("round trips foreign layers", func(t *testing.T) {
+		tarPath := packageImages(t, inputPath, "--include-non-distributable")
+
+		dstReg := newFakeRegistry(t)
+
+		stdout, err := unpackageImages(t, inputPath, tarPath, dstReg.Host+"/imported", "--include-non-distributable")
+		require.NoError(t, err)
+
+		assert.Contains(t, stdout, fmt.Sprintf("- image: %s/imported@%s\n", dstReg.Host, appDigest))
+		assert.Equal(t, []string{appDigest.String()}, dstReg.Digests("imported"))
+		assert.True(t, dstReg.HasBlob(foreignDigest.String()))
+	})
+
+	t.Run("fails when tarball was created without foreign layers", func(t *testing.T) {
+		tarPath := packageImages(t, inputPath)
+
+		_, err := unpackageImages(t, inputPath, tarPath, newFakeRegistry(t).Host+"/imported", "--include-non-distributable")
+		require.Error(t, err)
+		assert.Equal(t, fmt.Sprintf("Expected tarball '%s' to include non-distributable (foreign) layers, "+
+			"but it was created without them (package images with '--include-non-distributable')", tarPath), err.Error())
+	})
+
+	t.Run("fails when images do not have foreign layers", func(t *testing.T) {
+		otherDigestRef := srcReg.PushImage(t, srcReg.Host+"/src/other:1.0")
+
+		otherInputPath := filepath.Join(t.TempDir(), "input.yml")
+		require.NoError(t, os.WriteFile(otherInputPath, []byte(fmt.Sprintf(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: other
+spec:
+  containers:
+  - image: %s
+`, otherDigestRef)), 0600))
+
+		tarPath := packageImages(t, otherInputPath, "--include-non-distributable")
+
+		_, err := unpackageImages(t, otherInputPath, tarPath, newFakeRegistry(t).Host+"/imported", "--include-non-distributable")
+		require.Error(t, err)
+		assert.Equal(t, fmt.Sprintf("Expected tarball '%s' to include non-distributable (foreign) layers, "+
+			"but its images do not have any (remove '--include-non-distributable')", tarPath), err.Error())
+	})
+}
+
+func packageImages(t *testing.T, inputPath string, args ...string) string {
+	tarPath := filepath.Join(t.TempDir(), "images.tar")
+
+	cmd := ctlcmd.NewPackageCmd(ctlcmd.NewPackageOptions(ui.NewWriterUI(io.Discard, io.Discard, ui.NewNoopLogger())))
+	cmd.SetArgs(append([]string{"-f", inputPath, "-o", tarPath, "--registry-insecure"}, args...))
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	require.NoError(t, cmd.Execute())
+
+	return tarPath
+}
+
+func unpackageImages(t *testing.T, inputPath, tarPath, repo string, args ...string) (string, error) {
+	var stdout bytes.Buffer
+
+	cmd := ctlcmd.NewUnpackageCmd(ctlcmd.NewUnpackageOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+	cmd.SetArgs(append([]string{"-f", inputPath, "-i", tarPath, "-r", repo, "--registry-insecure"}, args...))
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	err := cmd.Execute()
+
+	return stdout.String(), err
+}
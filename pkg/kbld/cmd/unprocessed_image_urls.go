@@ -14,15 +14,24 @@ type UnprocessedImageURL struct {
 }
 
 type UnprocessedImageURLs struct {
-	urls map[UnprocessedImageURL]struct{} `json:"unresolved"`
+	urls map[UnprocessedImageURL]int `json:"unresolved"`
 }
 
 func NewUnprocessedImageURLs() *UnprocessedImageURLs {
-	return &UnprocessedImageURLs{map[UnprocessedImageURL]struct{}{}}
+	return &UnprocessedImageURLs{map[UnprocessedImageURL]int{}}
 }
 
 func (i *UnprocessedImageURLs) Add(url UnprocessedImageURL) {
-	i.urls[url] = struct{}{}
+	i.urls[url]++
+}
+
+// References returns number of added urls including duplicates
+func (i *UnprocessedImageURLs) References() int {
+	var result int
+	for _, count := range i.urls {
+		result += count
+	}
+	return result
 }
 
 func (i *UnprocessedImageURLs) All() []UnprocessedImageURL {
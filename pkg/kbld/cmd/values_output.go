@@ -0,0 +1,99 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	ValuesFormatYtt  = "ytt"
+	ValuesFormatYAML = "yaml"
+
+	// ValuesKeyURL uses image reference as it was specified (e.g. nginx:1.25)
+	ValuesKeyURL = "url"
+	// ValuesKeyName uses image reference without tag or digest (e.g. gcr.io/org/app)
+	ValuesKeyName = "name"
+	// ValuesKeyBasename uses last path segment of image name (e.g. app)
+	ValuesKeyBasename = "basename"
+
+	valuesImagesKey = "images"
+)
+
+// ValuesOutput emits resolved images as data values so that templates
+// could interpolate them instead of having rendered output post-processed
+type ValuesOutput struct {
+	Format string
+	Key    string
+}
+
+func (o ValuesOutput) Validate() error {
+	switch o.Format {
+	case ValuesFormatYtt, ValuesFormatYAML:
+	default:
+		return fmt.Errorf("Unknown values format '%s' (supported: %s, %s)", o.Format, ValuesFormatYtt, ValuesFormatYAML)
+	}
+	switch o.Key {
+	case ValuesKeyURL, ValuesKeyName, ValuesKeyBasename:
+	default:
+		return fmt.Errorf("Unknown values key '%s' (supported: %s, %s, %s)", o.Key, ValuesKeyURL, ValuesKeyName, ValuesKeyBasename)
+	}
+	return nil
+}
+
+// Bytes returns values document with resolved images under 'images' key
+func (o ValuesOutput) Bytes(resolvedImages *ProcessedImages) ([]byte, error) {
+	images := map[string]string{}
+
+	for _, pair := range resolvedImages.All() {
+		key := o.key(pair.UnprocessedImageURL.URL)
+
+		if prevURL, found := images[key]; found && prevURL != pair.Image.URL {
+			return nil, fmt.Errorf("Expected values key '%s' to be used for a single image, "+
+				"but images '%s' and '%s' share it (use different '--values-key')", key, prevURL, pair.Image.URL)
+		}
+		images[key] = pair.Image.URL
+	}
+
+	bs, err := yaml.Marshal(map[string]interface{}{valuesImagesKey: images})
+	if err != nil {
+		return nil, err
+	}
+
+	header := "---\n"
+	if o.Format == ValuesFormatYtt {
+		header = "#@data/values\n" + header
+	}
+
+	return append([]byte(header), bs...), nil
+}
+
+func (o ValuesOutput) WriteToFile(path string, resolvedImages *ProcessedImages) error {
+	bs, err := o.Bytes(resolvedImages)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(path, bs, 0600)
+	if err != nil {
+		return fmt.Errorf("Writing values: %s", err)
+	}
+
+	return nil
+}
+
+func (o ValuesOutput) key(url string) string {
+	switch o.Key {
+	case ValuesKeyName:
+		return untaggedImageName(url)
+	case ValuesKeyBasename:
+		return path.Base(untaggedImageName(url))
+	default:
+		return url
+	}
+}
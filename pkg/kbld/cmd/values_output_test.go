@@ -0,0 +1,86 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestResolveValuesOutput(t *testing.T) {
+	nginxRef := fmt.Sprintf("index.docker.io/library/nginx@sha256:%064d", 1)
+	appRef := fmt.Sprintf("registry.example.com/org/app@sha256:%064d", 2)
+
+	input := fmt.Sprintf(`
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - image: nginx:1.25
+  - image: gcr.io/org/app
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+overrides:
+- image: nginx:1.25
+  newImage: %s
+  preresolved: true
+- image: gcr.io/org/app
+  newImage: %s
+  preresolved: true
+`, nginxRef, appRef)
+
+	resolve := func(t *testing.T, args ...string) (string, error) {
+		dir := t.TempDir()
+		inputPath := filepath.Join(dir, "input.yml")
+		outputPath := filepath.Join(dir, "values.yml")
+		require.NoError(t, os.WriteFile(inputPath, []byte(input), 0600))
+
+		cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(&bytes.Buffer{}, io.Discard, ui.NewNoopLogger())))
+		cmd.SetArgs(append([]string{"-f", inputPath, "--values-output", outputPath, "--progress=plain"}, args...))
+
+		err := cmd.Execute()
+		if err != nil {
+			return "", err
+		}
+
+		bs, err := os.ReadFile(outputPath)
+		require.NoError(t, err)
+
+		return string(bs), nil
+	}
+
+	t.Run("ytt data values keyed by url", func(t *testing.T) {
+		output, err := resolve(t)
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("#@data/values\n---\nimages:\n  gcr.io/org/app: %s\n  nginx:1.25: %s\n", appRef, nginxRef), output)
+	})
+
+	t.Run("plain yaml keyed by name", func(t *testing.T) {
+		output, err := resolve(t, "--values-format", "yaml", "--values-key", "name")
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("---\nimages:\n  gcr.io/org/app: %s\n  nginx: %s\n", appRef, nginxRef), output)
+	})
+
+	t.Run("keyed by basename", func(t *testing.T) {
+		output, err := resolve(t, "--values-key", "basename")
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("#@data/values\n---\nimages:\n  app: %s\n  nginx: %s\n", appRef, nginxRef), output)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		_, err := resolve(t, "--values-key", "digest")
+		require.EqualError(t, err, "Unknown values key 'digest' (supported: url, name, basename)")
+	})
+}
@@ -0,0 +1,174 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	uitable "github.com/cppforlife/go-cli-ui/ui/table"
+	regname "github.com/google/go-containerregistry/pkg/name"
+	"github.com/spf13/cobra"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	ctlsign "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/signing"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+type VerifyOptions struct {
+	ui ui.UI
+
+	FileFlags     FileFlags
+	RegistryFlags RegistryFlags
+
+	Signatures bool
+	Policies   bool
+}
+
+func NewVerifyOptions(ui ui.UI) *VerifyOptions {
+	return &VerifyOptions{ui: ui}
+}
+
+func NewVerifyCmd(o *VerifyOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify that images referenced by rendered resources still exist",
+		RunE:  func(_ *cobra.Command, _ []string) error { return o.Run() },
+	}
+	o.FileFlags.Set(cmd)
+	o.RegistryFlags.Set(cmd)
+	cmd.Flags().BoolVar(&o.Signatures, "signatures", false, "Check images against configured verification policies")
+	cmd.Flags().BoolVar(&o.Policies, "policies", false, "Evaluate configured policies against images")
+	return cmd
+}
+
+func (o *VerifyOptions) Run() error {
+	rs, conf, err := o.FileFlags.ResourcesAndConfig()
+	if err != nil {
+		return err
+	}
+
+	if o.Signatures && len(conf.VerificationPolicies()) == 0 {
+		return util.NewCategorizedError(util.ErrorCategoryConfig,
+			fmt.Errorf("Expected verification policies to be configured when using '--signatures'"))
+	}
+	if o.Policies && len(conf.Policies()) == 0 {
+		return util.NewCategorizedError(util.ErrorCategoryConfig,
+			fmt.Errorf("Expected policies to be configured when using '--policies'"))
+	}
+
+	registryOpts, err := o.RegistryFlags.AsRegistryOpts()
+	if err != nil {
+		return err
+	}
+
+	registry, err := ctlreg.NewRegistry(registryOpts)
+	if err != nil {
+		return err
+	}
+
+	resourcesByURL := map[string][]string{}
+	for _, resWithImg := range findImages(rs, conf) {
+		resourcesByURL[resWithImg.URL] = append(resourcesByURL[resWithImg.URL], resWithImg.Resource.Description())
+	}
+
+	var urls []string
+	for url := range resourcesByURL {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+
+	table := uitable.Table{
+		Title:   "Images",
+		Content: "images",
+
+		Header: []uitable.Header{
+			uitable.NewHeader("Image"),
+			uitable.NewHeader("Status"),
+			uitable.NewHeader("Resources"),
+		},
+
+		SortBy: []uitable.ColumnSort{{Column: 0, Asc: true}},
+
+		// Image URLs and other content is too long
+		FillFirstColumn: true,
+		Transpose:       true,
+	}
+
+	logger := ctllog.NewLogger(os.Stderr)
+	verifiedImages := NewProcessedImages()
+	var failed int
+
+	for _, url := range urls {
+		origins, err := o.verifyImage(url, conf, registry, logger)
+
+		status := "ok"
+		if err != nil {
+			status = err.Error()
+			failed++
+		} else {
+			verifiedImages.Add(UnprocessedImageURL{url}, Image{URL: url, Origins: origins})
+		}
+
+		table.Rows = append(table.Rows, []uitable.Value{
+			uitable.NewValueString(url),
+			uitable.NewValueFmt(uitable.NewValueString(status), err != nil),
+			uitable.NewValueStrings(resourcesByURL[url]),
+		})
+	}
+
+	o.ui.PrintTable(table)
+
+	if failed > 0 {
+		return util.NewCategorizedError(util.ErrorCategoryPolicy,
+			fmt.Errorf("Expected all %d images to be verified, but %d failed", len(urls), failed))
+	}
+
+	if o.Policies {
+		err := CheckPolicies(conf, verifiedImages, registry, logger)
+		if err != nil {
+			return util.NewCategorizedError(util.ErrorCategoryPolicy, err)
+		}
+	}
+
+	return nil
+}
+
+func (o *VerifyOptions) verifyImage(url string, conf ctlconf.Conf,
+	registry ctlreg.Registry, logger ctllog.Logger) ([]ctlconf.Origin, error) {
+
+	digestedImg := ctlimg.MaybeNewDigestedImage(url)
+	if digestedImg == nil {
+		return nil, fmt.Errorf("Expected digest reference")
+	}
+
+	var img ctlimg.Image = *digestedImg
+	if o.Signatures {
+		img = ctlimg.NewVerifiedImage(img, conf.VerificationPolicies(), ctlsign.NewVerifier(logger))
+	}
+
+	ref, err := regname.NewDigest(url, regname.WeakValidation)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = registry.Digest(ref)
+	if err != nil {
+		if ctlreg.IsNotFoundErr(err) {
+			return nil, fmt.Errorf("Image not found in registry")
+		}
+		return nil, fmt.Errorf("Checking image: %s", err)
+	}
+
+	_, origins, err := img.URL()
+	if err != nil {
+		return nil, err
+	}
+
+	return origins, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestVerify(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+
+	manifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`)
+	existingDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+	missingDigest := fmt.Sprintf("sha256:%064d", 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case "/v2/app/manifests/" + existingDigest:
+			w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+			w.Header().Set("Content-Length", fmt.Sprintf("%d", len(manifest)))
+			w.Header().Set("Docker-Content-Digest", existingDigest)
+			if r.Method == http.MethodGet {
+				w.Write(manifest)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	verify := func(t *testing.T, images ...string) (string, error) {
+		var input string
+		for i, img := range images {
+			input += fmt.Sprintf("---\nkind: Pod\nmetadata:\n  name: pod-%d\nspec:\n  containers:\n  - image: %s\n", i, img)
+		}
+
+		path := filepath.Join(t.TempDir(), "resolved.yml")
+		require.NoError(t, os.WriteFile(path, []byte(input), 0600))
+
+		var stdout bytes.Buffer
+
+		cmd := ctlcmd.NewVerifyCmd(ctlcmd.NewVerifyOptions(ui.NewWriterUI(&stdout, io.Discard, ui.NewNoopLogger())))
+		cmd.SetArgs([]string{"-f", path, "--registry-insecure"})
+
+		err := cmd.Execute()
+		return stdout.String(), err
+	}
+
+	t.Run("succeeds when all images exist", func(t *testing.T) {
+		out, err := verify(t, host+"/app@"+existingDigest)
+		require.NoError(t, err)
+		assert.Contains(t, out, "ok")
+	})
+
+	t.Run("fails when images are missing or not digest references", func(t *testing.T) {
+		out, err := verify(t, host+"/app@"+existingDigest, host+"/app@"+missingDigest, host+"/app:latest")
+		require.EqualError(t, err, "Expected all 3 images to be verified, but 2 failed")
+		assert.Contains(t, out, "Image not found in registry")
+		assert.Contains(t, out, "Expected digest reference")
+	})
+
+	t.Run("requires verification policies for signatures", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "resolved.yml")
+		require.NoError(t, os.WriteFile(path, []byte("kind: Pod\n"), 0600))
+
+		cmd := ctlcmd.NewVerifyCmd(ctlcmd.NewVerifyOptions(ui.NewWriterUI(io.Discard, io.Discard, ui.NewNoopLogger())))
+		cmd.SetArgs([]string{"-f", path, "--signatures"})
+
+		require.EqualError(t, cmd.Execute(), "Expected verification policies to be configured when using '--signatures'")
+	})
+}
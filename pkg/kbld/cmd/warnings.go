@@ -0,0 +1,156 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlser "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/search"
+)
+
+type WarningKind string
+
+const (
+	WarningKindUnmatchedSearchRule WarningKind = "unmatched-search-rule"
+	WarningKindUnusedOverride      WarningKind = "unused-override"
+	WarningKindUnusedSource        WarningKind = "unused-source"
+	WarningKindUnusedDestination   WarningKind = "unused-destination"
+	WarningKindSkippedImage        WarningKind = "skipped-image"
+	WarningKindDeprecatedConfig    WarningKind = "deprecated-config"
+
+	// warningKindsAll is used by '--strict' without value
+	warningKindsAll = "all"
+)
+
+var (
+	warningKinds = []WarningKind{
+		WarningKindUnmatchedSearchRule,
+		WarningKindUnusedOverride,
+		WarningKindUnusedSource,
+		WarningKindUnusedDestination,
+		WarningKindSkippedImage,
+		WarningKindDeprecatedConfig,
+	}
+)
+
+type Warning struct {
+	Kind    WarningKind `json:"kind"`
+	Message string      `json:"message"`
+}
+
+// Warnings collects problems that do not fail resolution
+// so that they could be reported together at the end of a run
+type Warnings struct {
+	warnings     []Warning
+	warningsLock sync.Mutex
+}
+
+func NewWarnings() *Warnings {
+	return &Warnings{}
+}
+
+func (w *Warnings) Add(kind WarningKind, msg string, args ...interface{}) {
+	w.warningsLock.Lock()
+	defer w.warningsLock.Unlock()
+
+	w.warnings = append(w.warnings, Warning{Kind: kind, Message: fmt.Sprintf(msg, args...)})
+}
+
+func (w *Warnings) All() []Warning {
+	w.warningsLock.Lock()
+	defer w.warningsLock.Unlock()
+
+	return append([]Warning{}, w.warnings...)
+}
+
+// AddFromConf records deprecations, configured search rules that
+// did not match any value, values skipped by search rules, and
+// overrides, sources and destinations that were not used by factory
+func (w *Warnings) AddFromConf(conf ctlconf.Conf, ruleUsage *ctlser.RuleUsage, configUsage *ctlimg.ConfigUsage) {
+	for _, deprecation := range conf.Deprecations() {
+		w.Add(WarningKindDeprecatedConfig, "%s", deprecation)
+	}
+
+	for _, rule := range ruleUsage.Unmatched(conf.ConfiguredSearchRules()) {
+		ruleBs, err := json.Marshal(rule)
+		if err != nil {
+			ruleBs = []byte(fmt.Sprintf("%#v", rule))
+		}
+		w.Add(WarningKindUnmatchedSearchRule, "Search rule %s did not match any value", ruleBs)
+	}
+
+	for _, val := range ruleUsage.Skipped() {
+		if ctlimg.LooksLikeImageRef(val) {
+			w.Add(WarningKindSkippedImage, "Skipped image '%s' since it matched search rule with update strategy 'none'", val)
+		}
+	}
+
+	for _, override := range configUsage.UnusedOverrides(conf) {
+		// Lock files and image maps usually include more images than given inputs
+		if !override.Preresolved {
+			w.Add(WarningKindUnusedOverride, "Override of '%s' did not match any image reference", override.Image+override.ImageRepo)
+		}
+	}
+
+	for _, src := range configUsage.UnusedSources(conf) {
+		w.Add(WarningKindUnusedSource, "Source of '%s' (path '%s') did not match any image reference",
+			src.Image+src.ImageRepo+src.ImageRegexp+src.ImageGlob, src.Path)
+	}
+
+	for _, dst := range configUsage.UnusedDestinations(conf) {
+		w.Add(WarningKindUnusedDestination, "Destination of '%s' did not apply to any image", dst.Image+dst.ImageRepo)
+	}
+}
+
+// Log writes each warning (usually at the end of a run)
+func (w *Warnings) Log(logger *ctllog.PrefixWriter) {
+	for _, warning := range w.All() {
+		logger.WriteStr("warning: %s (%s)\n", warning.Message, warning.Kind)
+	}
+}
+
+// StrictErr returns error when there are warnings of given kinds
+func (w *Warnings) StrictErr(kinds []string) error {
+	var strictMsgs []string
+
+	for _, warning := range w.All() {
+		for _, kind := range kinds {
+			if kind == warningKindsAll || kind == string(warning.Kind) {
+				strictMsgs = append(strictMsgs, warning.Message)
+				break
+			}
+		}
+	}
+
+	if len(strictMsgs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("Expected no warnings in strict mode, but found:\n- %s", strings.Join(strictMsgs, "\n- "))
+}
+
+// ValidateWarningKinds checks kinds specified via '--strict'
+func ValidateWarningKinds(kinds []string) error {
+	for _, kind := range kinds {
+		var known bool
+		for _, knownKind := range warningKinds {
+			known = known || kind == string(knownKind)
+		}
+		if !known && kind != warningKindsAll {
+			var knownKinds []string
+			for _, knownKind := range warningKinds {
+				knownKinds = append(knownKinds, string(knownKind))
+			}
+			return fmt.Errorf("Expected '--strict' to be one of %s or %s, but was '%s'",
+				warningKindsAll, strings.Join(knownKinds, ", "), kind)
+		}
+	}
+	return nil
+}
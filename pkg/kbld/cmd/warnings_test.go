@@ -0,0 +1,125 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+)
+
+func TestResolveWarnings(t *testing.T) {
+	digestRef := fmt.Sprintf("registry.example.com/app@sha256:%064d", 1)
+
+	input := fmt.Sprintf(`
+kind: Pod
+metadata:
+  name: a
+  annotations:
+    skipped: nginx:1.17
+spec:
+  containers:
+  - image: app
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+overrides:
+- image: app
+  newImage: %[1]s
+  preresolved: true
+- image: lock-only
+  newImage: %[1]s
+  preresolved: true
+sources:
+- image: web
+  path: web
+destinations:
+- image: worker
+  newImage: registry.example.com/worker
+searchRules:
+- keyMatcher:
+    name: skipped
+  updateStrategy:
+    none: {}
+- keyMatcher:
+    name: unused
+---
+apiVersion: kbld.k14s.io/v1alpha1
+kind: ImageOverrides
+overrides:
+- imageRepo: redis
+  newImage: %[1]s
+`, digestRef)
+
+	inputPath := filepath.Join(t.TempDir(), "input.yml")
+	require.NoError(t, os.WriteFile(inputPath, []byte(input), 0600))
+
+	resolve := func(t *testing.T, args ...string) ([]ctlcmd.Warning, error) {
+		reportPath := filepath.Join(t.TempDir(), "report.json")
+
+		cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(io.Discard, io.Discard, ui.NewNoopLogger())))
+		cmd.SetArgs(append([]string{"-f", inputPath, "--progress=plain", "--report-path", reportPath}, args...))
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+
+		err := cmd.Execute()
+
+		reportBs, readErr := os.ReadFile(reportPath)
+		require.NoError(t, readErr)
+
+		var report struct {
+			Warnings []ctlcmd.Warning
+		}
+		require.NoError(t, json.Unmarshal(reportBs, &report))
+
+		return report.Warnings, err
+	}
+
+	t.Run("reports warnings without failing", func(t *testing.T) {
+		warnings, err := resolve(t)
+		require.NoError(t, err)
+
+		assert.Equal(t, []ctlcmd.Warning{
+			{Kind: ctlcmd.WarningKindDeprecatedConfig, Message: "Resource imageoverrides/ (kbld.k14s.io/v1alpha1) cluster uses deprecated kind 'ImageOverrides', use kind 'Config' instead"},
+			{Kind: ctlcmd.WarningKindUnmatchedSearchRule, Message: `Search rule {"keyMatcher":{"name":"unused"}} did not match any value`},
+			{Kind: ctlcmd.WarningKindSkippedImage, Message: "Skipped image 'nginx:1.17' since it matched search rule with update strategy 'none'"},
+			{Kind: ctlcmd.WarningKindUnusedOverride, Message: "Override of 'redis' did not match any image reference"},
+			{Kind: ctlcmd.WarningKindUnusedSource, Message: "Source of 'web' (path 'web') did not match any image reference"},
+			{Kind: ctlcmd.WarningKindUnusedDestination, Message: "Destination of 'worker' did not apply to any image"},
+		}, warnings)
+	})
+
+	t.Run("fails on selected warnings in strict mode", func(t *testing.T) {
+		warnings, err := resolve(t, "--strict=unused-override,skipped-image")
+		require.EqualError(t, err, "Expected no warnings in strict mode, but found:\n"+
+			"- Skipped image 'nginx:1.17' since it matched search rule with update strategy 'none'\n"+
+			"- Override of 'redis' did not match any image reference")
+		assert.Len(t, warnings, 6)
+	})
+
+	t.Run("fails on all warnings in strict mode without value", func(t *testing.T) {
+		_, err := resolve(t, "--strict")
+		require.Error(t, err)
+		assert.Equal(t, 7, len(strings.Split(err.Error(), "\n")))
+	})
+
+	t.Run("rejects unknown warning kinds", func(t *testing.T) {
+		cmd := ctlcmd.NewResolveCmd(ctlcmd.NewResolveOptions(ui.NewWriterUI(io.Discard, io.Discard, ui.NewNoopLogger())))
+		cmd.SetArgs([]string{"-f", inputPath, "--strict=unknown"})
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+
+		require.EqualError(t, cmd.Execute(), "Expected '--strict' to be one of all or unmatched-search-rule, "+
+			"unused-override, unused-source, unused-destination, skipped-image, deprecated-config, but was 'unknown'")
+	})
+}
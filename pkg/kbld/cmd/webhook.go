@@ -0,0 +1,380 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/spf13/cobra"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlmetrics "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/metrics"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	ctlser "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/search"
+)
+
+type WebhookOptions struct {
+	ui ui.UI
+
+	FileFlags     FileFlags
+	RegistryFlags RegistryFlags
+	LoggerFlags   LoggerFlags
+
+	Address     string
+	TLSCertFile string
+	TLSKeyFile  string
+	CacheTTL    time.Duration
+	FailClosed  bool
+}
+
+func NewWebhookOptions(ui ui.UI) *WebhookOptions {
+	return &WebhookOptions{ui: ui}
+}
+
+func NewWebhookCmd(o *WebhookOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:         "webhook",
+		Short:       "Run Kubernetes mutating admission webhook resolving image references to digests",
+		Annotations: map[string]string{handlesSignalsAnnotation: ""},
+		RunE:        func(_ *cobra.Command, _ []string) error { return o.Run() },
+	}
+	o.FileFlags.Set(cmd)
+	o.RegistryFlags.Set(cmd)
+	o.LoggerFlags.Set(cmd)
+	cmd.Flags().StringVar(&o.Address, "address", ":8443", "Set address to listen on")
+	cmd.Flags().StringVar(&o.TLSCertFile, "tls-cert-file", "", "Set TLS certificate file (required by Kubernetes API server)")
+	cmd.Flags().StringVar(&o.TLSKeyFile, "tls-key-file", "", "Set TLS private key file")
+	cmd.Flags().DurationVar(&o.CacheTTL, "cache-ttl", 5*time.Minute, "Set how long resolved digests are reused")
+	cmd.Flags().BoolVar(&o.FailClosed, "fail-closed", false, "Reject objects whose images cannot be resolved (by default objects are admitted unchanged)")
+	return cmd
+}
+
+func (o *WebhookOptions) Run() error {
+	if len(o.TLSCertFile) == 0 || len(o.TLSKeyFile) == 0 {
+		return fmt.Errorf("Expected '--tls-cert-file' and '--tls-key-file' to be specified")
+	}
+
+	logger, closeLogger, err := o.LoggerFlags.NewLogger()
+	if err != nil {
+		return err
+	}
+	defer closeLogger()
+
+	// Only configuration (e.g. lock files, overrides, search rules) is used from files
+	_, conf, err := o.FileFlags.ResourcesAndConfig()
+	if err != nil {
+		return err
+	}
+
+	registryOpts, err := o.RegistryFlags.AsRegistryOpts()
+	if err != nil {
+		return err
+	}
+
+	metrics := ctlmetrics.New()
+	registryOpts.Metrics = metrics
+
+	registry, err := ctlreg.NewRegistry(registryOpts)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:              o.Address,
+		Handler:           NewAdmissionWebhook(conf, registry, logger, o.CacheTTL, o.FailClosed).WithMetrics(metrics),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	prefixedLogger := logger.NewPrefixedWriter("webhook | ")
+	prefixedLogger.WriteStr("listening on %s\n", o.Address)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.ListenAndServeTLS(o.TLSCertFile, o.TLSKeyFile) }()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		prefixedLogger.WriteStr("shutting down\n")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		return server.Shutdown(shutdownCtx)
+	}
+}
+
+// AdmissionReview is a subset of admission.k8s.io/v1 AdmissionReview
+type AdmissionReview struct {
+	APIVersion string             `json:"apiVersion"`
+	Kind       string             `json:"kind"`
+	Request    *AdmissionRequest  `json:"request,omitempty"`
+	Response   *AdmissionResponse `json:"response,omitempty"`
+}
+
+type AdmissionRequest struct {
+	UID       string          `json:"uid"`
+	Namespace string          `json:"namespace,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Operation string          `json:"operation,omitempty"`
+	Object    json.RawMessage `json:"object,omitempty"`
+}
+
+type AdmissionResponse struct {
+	UID       string           `json:"uid"`
+	Allowed   bool             `json:"allowed"`
+	PatchType string           `json:"patchType,omitempty"`
+	Patch     []byte           `json:"patch,omitempty"`
+	Warnings  []string         `json:"warnings,omitempty"`
+	Result    *AdmissionStatus `json:"status,omitempty"`
+}
+
+type AdmissionStatus struct {
+	Message string `json:"message,omitempty"`
+}
+
+type JSONPatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// AdmissionWebhook mutates admitted objects to refer to images by digest
+type AdmissionWebhook struct {
+	conf       ctlconf.Conf
+	imgFactory ctlimg.Factory
+	logger     *ctllog.PrefixWriter
+	failClosed bool
+	mux        *http.ServeMux
+	metrics    *ctlmetrics.Metrics
+
+	now       func() time.Time
+	cacheTTL  time.Duration
+	cache     map[string]webhookCacheEntry
+	cacheLock sync.Mutex
+}
+
+type webhookCacheEntry struct {
+	URL     string
+	Expires time.Time
+}
+
+var _ http.Handler = &AdmissionWebhook{}
+
+func NewAdmissionWebhook(conf ctlconf.Conf, registry ctlreg.Registry, logger ctllog.Logger,
+	cacheTTL time.Duration, failClosed bool) *AdmissionWebhook {
+
+	// Building is never allowed when admitting objects
+	imgFactory := ctlimg.NewFactory(ctlimg.FactoryOpts{Conf: conf}, registry, logger)
+
+	w := &AdmissionWebhook{
+		conf:       conf,
+		imgFactory: imgFactory,
+		logger:     logger.NewPrefixedWriter("webhook | "),
+		failClosed: failClosed,
+		mux:        http.NewServeMux(),
+		now:        time.Now,
+		cacheTTL:   cacheTTL,
+		cache:      map[string]webhookCacheEntry{},
+	}
+	w.mux.HandleFunc("/healthz", func(rw http.ResponseWriter, _ *http.Request) { rw.Write([]byte("ok\n")) })
+	w.mux.HandleFunc("/mutate", w.mutate)
+	return w
+}
+
+// WithMetrics records reviews and exposes metrics at /metrics
+func (w *AdmissionWebhook) WithMetrics(metrics *ctlmetrics.Metrics) *AdmissionWebhook {
+	w.metrics = metrics
+	w.mux.Handle("/metrics", metrics.Handler())
+	return w
+}
+
+func (w *AdmissionWebhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	w.mux.ServeHTTP(rw, r)
+}
+
+func (w *AdmissionWebhook) mutate(rw http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 10*1024*1024))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var review AdmissionReview
+
+	err = json.Unmarshal(body, &review)
+	if err != nil || review.Request == nil {
+		http.Error(rw, fmt.Sprintf("Expected AdmissionReview request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	review.Response = w.Review(*review.Request)
+	review.Request = nil
+
+	respBody, err := json.Marshal(review)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(respBody)
+}
+
+// Review returns response that patches image references of admitted object
+func (w *AdmissionWebhook) Review(req AdmissionRequest) *AdmissionResponse {
+	resp := &AdmissionResponse{UID: req.UID, Allowed: true}
+
+	if len(req.Object) == 0 {
+		return resp
+	}
+
+	started := w.now()
+
+	patch, err := w.patch(req.Object)
+	w.metrics.ObserveResolution("webhook", w.now().Sub(started), err)
+
+	if err != nil {
+		w.logger.WriteStr("error: %s/%s: %s\n", req.Namespace, req.Name, err)
+
+		if w.failClosed {
+			resp.Allowed = false
+			resp.Result = &AdmissionStatus{Message: "kbld: " + err.Error()}
+		} else {
+			resp.Warnings = []string{"kbld: " + err.Error()}
+		}
+		return resp
+	}
+
+	if len(patch) > 0 {
+		patchBs, err := json.Marshal(patch)
+		if err != nil {
+			resp.Warnings = []string{"kbld: " + err.Error()}
+			return resp
+		}
+		resp.PatchType = "JSONPatch"
+		resp.Patch = patchBs
+	}
+
+	return resp
+}
+
+func (w *AdmissionWebhook) patch(objBs []byte) ([]JSONPatchOp, error) {
+	var obj, updatedObj interface{}
+
+	err := json.Unmarshal(objBs, &obj)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(objBs, &updatedObj)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+
+	ctlser.NewImageRefs(updatedObj, w.conf.SearchRules()).Visit(func(url string) (string, bool) {
+		resolvedURL, err := w.resolve(url)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Resolving image '%s': %s", url, err))
+			return "", false
+		}
+		return resolvedURL, true
+	})
+
+	if len(errs) > 0 {
+		return nil, errFromErrs(errs)
+	}
+
+	return NewJSONPatch(obj, updatedObj), nil
+}
+
+func (w *AdmissionWebhook) resolve(url string) (string, error) {
+	w.cacheLock.Lock()
+	entry, found := w.cache[url]
+	w.cacheLock.Unlock()
+
+	if found && w.now().Before(entry.Expires) {
+		w.metrics.ObserveImageCache(true)
+		return entry.URL, nil
+	}
+
+	started := w.now()
+
+	resolvedURL, _, err := w.imgFactory.New(url).URL()
+	w.metrics.ObserveImage("resolving", false, w.now().Sub(started), err)
+
+	if err != nil {
+		return "", err
+	}
+
+	w.cacheLock.Lock()
+	w.cache[url] = webhookCacheEntry{URL: resolvedURL, Expires: w.now().Add(w.cacheTTL)}
+	w.cacheLock.Unlock()
+
+	return resolvedURL, nil
+}
+
+// NewJSONPatch returns replace operations for changed values
+// (objects are expected to only differ in values, not in structure)
+func NewJSONPatch(oldObj, newObj interface{}) []JSONPatchOp {
+	var ops []JSONPatchOp
+	jsonPatchDiff("", oldObj, newObj, &ops)
+	return ops
+}
+
+func jsonPatchDiff(path string, oldVal, newVal interface{}, ops *[]JSONPatchOp) {
+	switch typedOld := oldVal.(type) {
+	case map[string]interface{}:
+		typedNew, ok := newVal.(map[string]interface{})
+		if !ok {
+			break
+		}
+		var keys []string
+		for key := range typedOld {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			jsonPatchDiff(path+"/"+jsonPointerEscape(key), typedOld[key], typedNew[key], ops)
+		}
+		return
+
+	case []interface{}:
+		typedNew, ok := newVal.([]interface{})
+		if !ok || len(typedNew) != len(typedOld) {
+			break
+		}
+		for i := range typedOld {
+			jsonPatchDiff(path+"/"+strconv.Itoa(i), typedOld[i], typedNew[i], ops)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(oldVal, newVal) {
+		*ops = append(*ops, JSONPatchOp{Op: "replace", Path: path, Value: newVal})
+	}
+}
+
+func jsonPointerEscape(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd_test
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlcmd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/cmd"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+func TestAdmissionWebhookReview(t *testing.T) {
+	configYAML := `
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+overrides:
+- image: nginx:1.17
+  newImage: index.docker.io/library/nginx@sha256:0000000000000000000000000000000000000000000000000000000000000001
+  preresolved: true
+`
+
+	rs, err := ctlres.NewResourcesFromBytes([]byte(configYAML))
+	require.NoError(t, err)
+
+	_, conf, err := ctlconf.NewConfFromResources(rs)
+	require.NoError(t, err)
+
+	webhook := ctlcmd.NewAdmissionWebhook(conf, ctlreg.Registry{}, ctllog.NewLogger(io.Discard), time.Minute, true)
+
+	podJSON := `{
+  "apiVersion": "v1",
+  "kind": "Pod",
+  "metadata": {"name": "app", "annotations": {"a/b": "nginx:1.17"}},
+  "spec": {
+    "containers": [
+      {"name": "sidecar", "image": "index.docker.io/library/redis@sha256:0000000000000000000000000000000000000000000000000000000000000002"},
+      {"name": "app", "image": "nginx:1.17"}
+    ]
+  }
+}`
+
+	resp := webhook.Review(ctlcmd.AdmissionRequest{UID: "uid-1", Object: json.RawMessage(podJSON)})
+
+	assert.Equal(t, "uid-1", resp.UID)
+	assert.True(t, resp.Allowed)
+	assert.Equal(t, "JSONPatch", resp.PatchType)
+
+	var patch []ctlcmd.JSONPatchOp
+	require.NoError(t, json.Unmarshal(resp.Patch, &patch))
+
+	assert.Equal(t, []ctlcmd.JSONPatchOp{{
+		Op:    "replace",
+		Path:  "/spec/containers/1/image",
+		Value: "index.docker.io/library/nginx@sha256:0000000000000000000000000000000000000000000000000000000000000001",
+	}}, patch)
+}
+
+func TestNewJSONPatchEscapesKeys(t *testing.T) {
+	oldObj := map[string]interface{}{"a/b": map[string]interface{}{"c~d": "old", "same": "val"}}
+	newObj := map[string]interface{}{"a/b": map[string]interface{}{"c~d": "new", "same": "val"}}
+
+	assert.Equal(t, []ctlcmd.JSONPatchOp{{Op: "replace", Path: "/a~1b/c~0d", Value: "new"}},
+		ctlcmd.NewJSONPatch(oldObj, newObj))
+}
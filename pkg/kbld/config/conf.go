@@ -4,6 +4,7 @@
 package config
 
 import (
+	"path/filepath"
 	"reflect"
 
 	"carvel.dev/imgpkg/pkg/imgpkg/lockconfig"
@@ -11,12 +12,14 @@ import (
 )
 
 type Conf struct {
-	configs []Config
+	configs         []Config
+	registrySecrets []RegistrySecret
 }
 
 func NewConfFromResources(resources []ctlres.Resource) ([]ctlres.Resource, Conf, error) {
 	var rsWithoutConfigs []ctlres.Resource
 	var configs []Config
+	var registrySecrets []RegistrySecret
 
 	for _, res := range resources {
 		switch {
@@ -26,26 +29,72 @@ func NewConfFromResources(resources []ctlres.Resource) ([]ctlres.Resource, Conf,
 				return nil, Conf{}, err
 			}
 			configs = append(configs, config)
-		case res.APIVersion() == lockconfig.ImagesLockAPIVersion && res.Kind() == lockconfig.ImagesLockKind:
+		case isImagesLock(res):
 			config, err := NewConfigFromImagesLock(res)
 			if err != nil {
 				return nil, Conf{}, err
 			}
 			configs = append(configs, config)
+		case isRegistrySecret(res):
+			secret, err := NewRegistrySecretFromResource(res)
+			if err != nil {
+				return nil, Conf{}, err
+			}
+			registrySecrets = append(registrySecrets, secret)
+			// Secrets are regular resources, hence keep them in the output
+			rsWithoutConfigs = append(rsWithoutConfigs, res)
 		default:
 			rsWithoutConfigs = append(rsWithoutConfigs, res)
 		}
 	}
-	return rsWithoutConfigs, Conf{configs}, nil
+	return rsWithoutConfigs, Conf{configs, registrySecrets}, nil
 }
 
 func (c Conf) WithAdditionalConfig(config Config) Conf {
-	newConf := Conf{}
+	newConf := Conf{registrySecrets: c.registrySecrets}
 	newConf.configs = append([]Config{}, c.configs...)
 	newConf.configs = append(newConf.configs, config)
 	return newConf
 }
 
+// WithPrecedingConfig returns conf with given config placed before other configs,
+// hence its list-type settings (e.g. destinations) are matched first
+func (c Conf) WithPrecedingConfig(config Config) Conf {
+	newConf := Conf{registrySecrets: c.registrySecrets}
+	newConf.configs = append([]Config{config}, c.configs...)
+	return newConf
+}
+
+// WithSourcesDir returns conf with source paths placed within given directory
+// (e.g. repository checkout), hence sources cannot point outside of it
+func (c Conf) WithSourcesDir(dir string) Conf {
+	newConf := Conf{registrySecrets: c.registrySecrets}
+	for _, config := range c.configs {
+		config.Sources = append([]Source{}, config.Sources...)
+		for i, src := range config.Sources {
+			config.Sources[i].Path = filepath.Join(dir, filepath.Clean("/"+src.Path))
+		}
+		newConf.configs = append(newConf.configs, config)
+	}
+	return newConf
+}
+
+// IsConfResource returns true when resource is used by NewConfFromResources
+// (registry secrets are in addition kept as regular resources)
+func IsConfResource(res ctlres.Resource) bool {
+	return matchesConfigKind(res) || isImagesLock(res) || isRegistrySecret(res)
+}
+
+// IsNonConfigResource returns true when resource is kept
+// by NewConfFromResources as a regular resource
+func IsNonConfigResource(res ctlres.Resource) bool {
+	return !matchesConfigKind(res) && !isImagesLock(res)
+}
+
+func isImagesLock(res ctlres.Resource) bool {
+	return res.APIVersion() == lockconfig.ImagesLockAPIVersion && res.Kind() == lockconfig.ImagesLockKind
+}
+
 func matchesConfigKind(res ctlres.Resource) bool {
 	for _, configKind := range configKinds {
 		if res.APIVersion() == configKind.APIVersion && res.Kind() == configKind.Kind {
@@ -55,10 +104,17 @@ func matchesConfigKind(res ctlres.Resource) bool {
 	return false
 }
 
+// Sources returns sources with applied defaults of their config
+// (sources with matrix are expanded into a source per variant,
+// and sources with bake targets into a source per target)
 func (c Conf) Sources() []Source {
 	var result []Source
 	for _, config := range c.configs {
-		result = append(result, config.Sources...)
+		for _, src := range config.Sources {
+			for _, variantSrc := range src.withDefaults(config.SourceDefaults).expandMatrix() {
+				result = append(result, variantSrc.expandBakeTargets()...)
+			}
+		}
 	}
 	return result
 }
@@ -79,14 +135,135 @@ func (c Conf) ImageDestinations() []ImageDestination {
 	return result
 }
 
+// Signing returns signing configuration (last specified one wins)
+func (c Conf) Signing() *Signing {
+	var result *Signing
+	for _, config := range c.configs {
+		if config.Signing != nil {
+			result = config.Signing
+		}
+	}
+	return result
+}
+
+func (c Conf) VerificationPolicies() []VerificationPolicy {
+	var result []VerificationPolicy
+	for _, config := range c.configs {
+		result = append(result, config.VerificationPolicies...)
+	}
+	return result
+}
+
+// VulnerabilityScan returns scan configuration (last specified one wins)
+func (c Conf) VulnerabilityScan() *VulnerabilityScan {
+	var result *VulnerabilityScan
+	for _, config := range c.configs {
+		if config.VulnerabilityScan != nil {
+			result = config.VulnerabilityScan
+		}
+	}
+	return result
+}
+
+func (c Conf) Rebases() []ImageRebase {
+	var result []ImageRebase
+	for _, config := range c.configs {
+		result = append(result, config.Rebases...)
+	}
+	return result
+}
+
+func (c Conf) Credentials() []ImageCredential {
+	var result []ImageCredential
+	for _, config := range c.configs {
+		result = append(result, config.Credentials...)
+	}
+	return result
+}
+
+// RegistryProxies returns proxy settings in order of configs (first matching one applies)
+func (c Conf) RegistryProxies() []RegistryProxy {
+	var result []RegistryProxy
+	for _, config := range c.configs {
+		result = append(result, config.RegistryProxies...)
+	}
+	return result
+}
+
+func (c Conf) ClusterProfiles() []ClusterProfile {
+	var result []ClusterProfile
+	for _, config := range c.configs {
+		result = append(result, config.ClusterProfiles...)
+	}
+	return result
+}
+
+func (c Conf) Policies() []Policy {
+	var result []Policy
+	for _, config := range c.configs {
+		result = append(result, config.Policies...)
+	}
+	return result
+}
+
+// ImageFreshness returns freshness configuration (last specified one wins)
+func (c Conf) ImageFreshness() *ImageFreshness {
+	var result *ImageFreshness
+	for _, config := range c.configs {
+		if config.ImageFreshness != nil {
+			result = config.ImageFreshness
+		}
+	}
+	return result
+}
+
+// ImagesAnnotation returns images annotation configuration (last specified one wins)
+func (c Conf) ImagesAnnotation() ImagesAnnotation {
+	var result ImagesAnnotation
+	for _, config := range c.configs {
+		if config.ImagesAnnotation != nil {
+			result = *config.ImagesAnnotation
+		}
+	}
+	return result
+}
+
+// Promotion returns promotion configuration (last specified one wins)
+func (c Conf) Promotion() *Promotion {
+	var result *Promotion
+	for _, config := range c.configs {
+		if config.Promotion != nil {
+			result = config.Promotion
+		}
+	}
+	return result
+}
+
+// LocalImages returns local resolution settings in order of configs (first matching one applies)
+func (c Conf) LocalImages() []LocalImage {
+	var result []LocalImage
+	for _, config := range c.configs {
+		result = append(result, config.LocalImages...)
+	}
+	return result
+}
+
+// RegistrySecret finds secret by name (namespace is only compared when specified)
+func (c Conf) RegistrySecret(ref ImageDestinationAuthSecretRef) (RegistrySecret, bool) {
+	for _, secret := range c.registrySecrets {
+		if secret.Name == ref.Name && (len(ref.Namespace) == 0 || secret.Namespace == ref.Namespace) {
+			return secret, true
+		}
+	}
+	return RegistrySecret{}, false
+}
+
 func (c Conf) SearchRules() []SearchRule {
 	result := append([]SearchRule{}, c.SearchRulesWithoutDefaults()...)
 
-	// Add default image rule at the end so that
-	// there is an opportunity to match image kv with other rules
-	result = append(result, SearchRule{
-		KeyMatcher: &SearchRuleKeyMatcher{Name: "image"},
-	})
+	// Add default rules at the end so that configured
+	// rules have an opportunity to match values first
+	result = append(result, DefaultSearchRules()...)
 
 	return c.dedupSearchRules(result)
 }
@@ -94,18 +271,41 @@ func (c Conf) SearchRules() []SearchRule {
 func (c Conf) SearchRulesWithoutDefaults() []SearchRule {
 	result := []SearchRule{}
 	for _, config := range c.configs {
-		for _, key := range config.Keys {
-			result = append(result, SearchRule{
-				KeyMatcher: &SearchRuleKeyMatcher{Name: key},
-			})
+		result = append(result, config.SearchRules...)
+	}
+	// Packs follow explicitly configured rules so that those take precedence
+	for _, config := range c.configs {
+		for _, pack := range config.searchRulePacks {
+			for _, key := range pack.Keys {
+				result = append(result, SearchRule{
+					KeyMatcher: &SearchRuleKeyMatcher{Name: key},
+				})
+			}
+			result = append(result, pack.SearchRules...)
 		}
 	}
+	return c.dedupSearchRules(result)
+}
+
+// ConfiguredSearchRules returns search rules specified directly
+// in configs, i.e. without default rules and rules of packs
+func (c Conf) ConfiguredSearchRules() []SearchRule {
+	result := []SearchRule{}
 	for _, config := range c.configs {
 		result = append(result, config.SearchRules...)
 	}
 	return c.dedupSearchRules(result)
 }
 
+// Deprecations describes use of deprecated configuration
+func (c Conf) Deprecations() []string {
+	var result []string
+	for _, config := range c.configs {
+		result = append(result, config.deprecations...)
+	}
+	return result
+}
+
 func (c Conf) dedupSearchRules(rules []SearchRule) []SearchRule {
 	var result []SearchRule
 	for _, rule := range rules {
@@ -122,3 +322,34 @@ func (c Conf) dedupSearchRules(rules []SearchRule) []SearchRule {
 	}
 	return result
 }
+
+// EffectiveConfig merges configs into a single config that describes
+// how resolution is configured: sources include their defaults and
+// matrix variants, search rules include rules of packs, and settings
+// of which last specified one wins are taken from the last config
+// (minimum required versions are checked when configs are loaded)
+func (c Conf) EffectiveConfig() Config {
+	result := NewConfig()
+
+	result.Sources = c.Sources()
+	result.Overrides = c.ImageOverrides()
+	result.Destinations = c.ImageDestinations()
+	result.SearchRules = c.SearchRulesWithoutDefaults()
+	result.Signing = c.Signing()
+	result.VerificationPolicies = c.VerificationPolicies()
+	result.VulnerabilityScan = c.VulnerabilityScan()
+	result.Policies = c.Policies()
+	result.ImageFreshness = c.ImageFreshness()
+	result.Rebases = c.Rebases()
+	result.Credentials = c.Credentials()
+	result.RegistryProxies = c.RegistryProxies()
+	result.ClusterProfiles = c.ClusterProfiles()
+	result.Promotion = c.Promotion()
+	result.LocalImages = c.LocalImages()
+
+	if imagesAnnotation := c.ImagesAnnotation(); !reflect.DeepEqual(imagesAnnotation, ImagesAnnotation{}) {
+		result.ImagesAnnotation = &imagesAnnotation
+	}
+
+	return result
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+// RestrictedSettings returns settings that run commands, push images
+// (with credentials of kbld) or access local files and env variables.
+// Such settings should not be accepted from remote clients (e.g. by kbld serve).
+func (c Conf) RestrictedSettings() []string {
+	var result []string
+
+	add := func(setting string) {
+		for _, existing := range result {
+			if existing == setting {
+				return
+			}
+		}
+		result = append(result, setting)
+	}
+
+	if len(c.ImageDestinations()) > 0 {
+		add("destinations")
+	}
+	if c.Promotion() != nil {
+		add("promotion")
+	}
+	if len(c.Rebases()) > 0 {
+		add("rebases")
+	}
+
+	if scan := c.VulnerabilityScan(); scan != nil {
+		if len(scan.Command) > 0 {
+			add("vulnerabilityScan.command")
+		}
+		if len(scan.ReportPath) > 0 {
+			add("vulnerabilityScan.reportPath")
+		}
+	}
+
+	for _, src := range c.Sources() {
+		if src.SBOM != nil && len(src.SBOM.Command) > 0 {
+			add("sources[].sbom.command")
+		}
+	}
+
+	for _, cred := range c.Credentials() {
+		if len(cred.Auth.AuthFile) > 0 {
+			add("credentials[].auth.authFile")
+		}
+		if len(cred.Auth.EnvPrefix) > 0 {
+			add("credentials[].auth.envPrefix")
+		}
+	}
+
+	for _, localImg := range c.LocalImages() {
+		if localImg.Push {
+			add("localImages[].push")
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+func TestConfEffectiveConfig(t *testing.T) {
+	var rs []ctlres.Resource
+
+	for _, doc := range []string{`
+apiVersion: kbld.k14s.io/v1alpha1
+kind: ImageKeys
+keys: [sidecarImage]
+`, `
+apiVersion: kbld.k14s.io/v1alpha2
+kind: Config
+sourceDefaults:
+  docker:
+    build:
+      pull: true
+sources:
+- image: app
+  path: src
+overrides:
+- image: nginx
+  newImage: nginx:1.17
+imagesAnnotation:
+  key: first.example.com/images
+searchRulePacks: [argo]
+`, `
+apiVersion: kbld.k14s.io/v1alpha2
+kind: Config
+overrides:
+- image: redis
+  newImage: redis:7
+imagesAnnotation:
+  key: last.example.com/images
+`} {
+		docRs, err := ctlres.NewResourcesFromBytes([]byte(doc))
+		require.NoError(t, err)
+		rs = append(rs, docRs...)
+	}
+
+	_, conf, err := ctlconf.NewConfFromResources(rs)
+	require.NoError(t, err)
+
+	config := conf.EffectiveConfig()
+
+	assert.Equal(t, "Config", config.Kind)
+
+	// Sources include defaults of their configs
+	require.Len(t, config.Sources, 1)
+	assert.Nil(t, config.SourceDefaults)
+	assert.Equal(t, true, *config.Sources[0].Docker.Build.Pull)
+
+	// Lists are merged in order of configs
+	require.Len(t, config.Overrides, 2)
+	assert.Equal(t, "nginx", config.Overrides[0].Image)
+	assert.Equal(t, "redis", config.Overrides[1].Image)
+
+	// Search rules of packs are expanded
+	assert.Nil(t, config.SearchRulePacks)
+	assert.Equal(t, conf.SearchRulesWithoutDefaults(), config.SearchRules)
+	assert.Equal(t, ctlconf.SearchRule{KeyMatcher: &ctlconf.SearchRuleKeyMatcher{Name: "sidecarImage"}}, config.SearchRules[0])
+	assert.Greater(t, len(config.SearchRules), 1)
+
+	// Last specified images annotation wins
+	require.NotNil(t, config.ImagesAnnotation)
+	assert.Equal(t, "last.example.com/images", config.ImagesAnnotation.Key)
+}
+
+func TestConfWithSourcesDir(t *testing.T) {
+	rs, err := ctlres.NewResourcesFromBytes([]byte(`
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+sources:
+- image: app
+  path: src
+- image: escaping
+  path: ../../etc
+- imageRegexp: svc-(?P<name>.+)
+  path: services/${name}
+`))
+	require.NoError(t, err)
+
+	_, conf, err := ctlconf.NewConfFromResources(rs)
+	require.NoError(t, err)
+
+	srcs := conf.WithSourcesDir("/checkout").Sources()
+	require.Len(t, srcs, 3)
+
+	assert.Equal(t, "/checkout/src", srcs[0].Path)
+	assert.Equal(t, "/checkout/etc", srcs[1].Path)
+	assert.Equal(t, "/checkout/services/api", srcs[2].ForImage("svc-api").Path)
+
+	// Original conf is not modified
+	assert.Equal(t, "src", conf.Sources()[0].Path)
+}
@@ -4,8 +4,13 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
 
 	"carvel.dev/imgpkg/pkg/imgpkg/lockconfig"
 	versions "carvel.dev/vendir/pkg/vendir/versions/v1alpha1"
@@ -16,7 +21,10 @@ import (
 )
 
 const (
-	configAPIVersion      = "kbld.k14s.io/v1alpha1"
+	configAPIVersion = "kbld.k14s.io/v1alpha2"
+	// configAPIVersionV1alpha1 documents are converted to configAPIVersion
+	configAPIVersionV1alpha1 = "kbld.k14s.io/v1alpha1"
+
 	configKind            = "Config"
 	sourcesKind           = "Sources"           // specify list of sources for building images
 	imageOverridesKind    = "ImageOverrides"    // specify alternative image urls
@@ -31,12 +39,14 @@ type Kind struct {
 var (
 	configKinds = []Kind{
 		{configAPIVersion, configKind},
-		{configAPIVersion, configKind},
-		{configAPIVersion, sourcesKind},
-		{configAPIVersion, imageOverridesKind},
-		{configAPIVersion, imageDestinationsKind},
-		{configAPIVersion, imageKeysKind},
+		{configAPIVersionV1alpha1, configKind},
+		{configAPIVersionV1alpha1, sourcesKind},
+		{configAPIVersionV1alpha1, imageOverridesKind},
+		{configAPIVersionV1alpha1, imageDestinationsKind},
+		{configAPIVersionV1alpha1, imageKeysKind},
 	}
+
+	configSchema = ConfigSchema()
 )
 
 type Config struct {
@@ -48,12 +58,37 @@ type Config struct {
 	Sources      []Source           `json:"sources,omitempty"`
 	Overrides    []ImageOverride    `json:"overrides,omitempty"`
 	Destinations []ImageDestination `json:"destinations,omitempty"`
-	Keys         []string           `json:"keys,omitempty"`
 	SearchRules  []SearchRule       `json:"searchRules,omitempty"`
+	// SearchRulePacks refer to built-in packs by name or to local files
+	SearchRulePacks []string `json:"searchRulePacks,omitempty"`
+	Signing         *Signing `json:"signing,omitempty"`
+
+	VerificationPolicies []VerificationPolicy `json:"verificationPolicies,omitempty"`
+	VulnerabilityScan    *VulnerabilityScan   `json:"vulnerabilityScan,omitempty"`
+	Policies             []Policy             `json:"policies,omitempty"`
+	ImageFreshness       *ImageFreshness      `json:"imageFreshness,omitempty"`
+	ImagesAnnotation     *ImagesAnnotation    `json:"imagesAnnotation,omitempty"`
+	Rebases              []ImageRebase        `json:"rebases,omitempty"`
+	SourceDefaults       *SourceDefaults      `json:"sourceDefaults,omitempty"`
+	Credentials          []ImageCredential    `json:"credentials,omitempty"`
+	RegistryProxies      []RegistryProxy      `json:"registryProxies,omitempty"`
+	ClusterProfiles      []ClusterProfile     `json:"clusterProfiles,omitempty"`
+	Promotion            *Promotion           `json:"promotion,omitempty"`
+	LocalImages          []LocalImage         `json:"localImages,omitempty"`
+
+	// searchRulePacks are loaded from SearchRulePacks
+	searchRulePacks []SearchRulePack
+	// deprecations describe deprecated kinds used by resource
+	deprecations []string
 }
 
 type Source struct {
 	ImageRef
+	// ImageRegexp and ImageGlob match set of images (instead of ImageRef).
+	// Path may refer to regexp groups (e.g. services/${name}).
+	ImageRegexp string `json:"imageRegexp,omitempty"`
+	ImageGlob   string `json:"imageGlob,omitempty"`
+
 	Path string
 
 	Docker          *SourceDockerOpts
@@ -61,6 +96,26 @@ type Source struct {
 	KubectlBuildkit *SourceKubectlBuildkitOpts
 	Ko              *SourceKoOpts
 	Bazel           *SourceBazelOpts
+	CloudBuild      *SourceCloudBuildOpts `json:"cloudBuild,omitempty"`
+	CodeBuild       *SourceCodeBuildOpts  `json:"codeBuild,omitempty"`
+	Depot           *SourceDepotOpts      `json:"depot,omitempty"`
+	Archive         *SourceArchiveOpts    `json:"archive,omitempty"`
+	Autodetect      *SourceAutodetectOpts `json:"autodetect,omitempty"`
+
+	Provenance *SourceProvenanceOpts
+	SBOM       *SourceSBOMOpts
+	BaseImages *SourceBaseImagesOpts
+
+	RegistryCache *SourceRegistryCacheOpts `json:"registryCache,omitempty"`
+
+	Env *SourceEnvOpts `json:"env,omitempty"`
+	// Timeout limits duration of build including retries (e.g. 15m);
+	// builder commands are terminated once it passes (overrides --build-timeout)
+	Timeout string           `json:"timeout,omitempty"`
+	Retry   *SourceRetryOpts `json:"retry,omitempty"`
+
+	// Matrix lists additional images built from the same path
+	Matrix []SourceVariant `json:"matrix,omitempty"`
 }
 
 type ImageOverride struct {
@@ -87,6 +142,86 @@ type ImageDestination struct {
 	ImageRef
 	NewImage string   `json:"newImage"`
 	Tags     []string `json:"tags"`
+
+	// NewImages lists all push destinations (NewImage defaults to first one)
+	NewImages []string `json:"newImages,omitempty"`
+	// OutputNewImage selects destination used in the output (defaults to NewImage)
+	OutputNewImage string `json:"outputNewImage,omitempty"`
+
+	// OnlyIfBuilt limits destination to images built by kbld (defaults to true).
+	// When false, resolved images are copied to destination as well.
+	OnlyIfBuilt *bool `json:"onlyIfBuilt,omitempty"`
+	// When limits destination to runs matching all specified conditions
+	When *ImageDestinationConditions `json:"when,omitempty"`
+	// Auth provides credentials used by kbld when writing to this destination
+	// (pushes done by builders, e.g. docker push, use builder's own credentials)
+	Auth *ImageDestinationAuth `json:"auth,omitempty"`
+	// PrePush hooks run before image is built (or resolved) and pushed
+	PrePush []ImageDestinationPrePushHook `json:"prePush,omitempty"`
+	// PostPush hooks run after image is pushed to each destination
+	PostPush []ImageDestinationHook `json:"postPush,omitempty"`
+	// Mode controls how images are transferred to destinations (defaults to copy)
+	Mode ImageDestinationMode `json:"mode,omitempty"`
+	// ImmutableTags fails instead of moving existing tags to a different digest
+	ImmutableTags bool `json:"immutableTags,omitempty"`
+	// SquashLayers flattens image into a single layer before it is tagged,
+	// trading layer reuse for smaller layer count and metadata overhead
+	SquashLayers bool `json:"squashLayers,omitempty"`
+	// SquashBaseImage keeps layers shared with given image
+	// and only squashes layers above them (requires SquashLayers)
+	SquashBaseImage string `json:"squashBaseImage,omitempty"`
+	// IntermediateTag overrides random tag used when pushing built images
+	IntermediateTag *ImageDestinationIntermediateTag `json:"intermediateTag,omitempty"`
+	// ECR ensures destination repositories exist in AWS ECR before pushing
+	ECR *ImageDestinationECR `json:"ecr,omitempty"`
+}
+
+type ImageDestinationMode string
+
+const (
+	// ImageDestinationModeCopy pulls image and pushes it to destination
+	ImageDestinationModeCopy ImageDestinationMode = "copy"
+	// ImageDestinationModeRetag only creates tags pointing at existing digest
+	// (requires destination to be the same repository as image)
+	ImageDestinationModeRetag ImageDestinationMode = "retag"
+)
+
+type ImageDestinationConditions struct {
+	// Env maps environment variable names to glob patterns
+	// (e.g. BRANCH: "release-*"); unset variables are treated as empty
+	Env map[string]string `json:"env,omitempty"`
+	// GitBranch is a glob pattern matched against current branch of source
+	// (or working directory for images that are not built)
+	GitBranch string `json:"gitBranch,omitempty"`
+}
+
+type ImageDestinationAuth struct {
+	// EnvPrefix selects env variables in the same format as KBLD_REGISTRY_*
+	// (e.g. PROD_REGISTRY_HOSTNAME, PROD_REGISTRY_USERNAME, PROD_REGISTRY_PASSWORD)
+	EnvPrefix string `json:"envPrefix,omitempty"`
+	// AuthFile is a path to Docker config.json formatted file
+	AuthFile string `json:"authFile,omitempty"`
+	// SecretRef refers to kubernetes.io/dockerconfigjson Secret included in inputs
+	SecretRef *ImageDestinationAuthSecretRef `json:"secretRef,omitempty"`
+}
+
+type ImageDestinationAuthSecretRef struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+type ImageDestinationHook struct {
+	// Command is executed with KBLD_PUSHED_IMAGE, KBLD_PUSHED_DIGEST,
+	// KBLD_PUSHED_URL and KBLD_PUSHED_TAGS (comma separated) env variables
+	Command []string `json:"command,omitempty"`
+	// Webhook receives JSON body with image, digest, url and tags keys
+	Webhook *ImageDestinationWebhook `json:"webhook,omitempty"`
+}
+
+type ImageDestinationWebhook struct {
+	URL string `json:"url"`
+	// Headers values may refer to env variables (e.g. "Bearer ${TOKEN}")
+	Headers map[string]string `json:"headers,omitempty"`
 }
 
 type SearchRule struct {
@@ -99,12 +234,21 @@ type SearchRule struct {
 type SearchRuleKeyMatcher struct {
 	Name string      `json:"name,omitempty"`
 	Path ctlres.Path `json:"path,omitempty"`
+	// EnvVarNamePrefix matches values of env vars with names
+	// starting with prefix (e.g. RELATED_IMAGE_)
+	EnvVarNamePrefix string `json:"envVarNamePrefix,omitempty"`
+	// EnvVarNameRegexp matches values of env vars with names
+	// entirely matching regexp (e.g. .*_IMAGE)
+	EnvVarNameRegexp string `json:"envVarNameRegexp,omitempty"`
 	// TODO JSONPath string
 }
 
 type SearchRuleValueMatcher struct {
 	Image     string `json:"image,omitempty"`
 	ImageRepo string `json:"imageRepo,omitempty"`
+	// ImageLike matches values that look like image references
+	// (have tag or digest, or start with registry host)
+	ImageLike bool `json:"imageLike,omitempty"`
 	// TODO Regexp    string `json:"regexp,omitempty"`
 }
 
@@ -132,15 +276,35 @@ type ImageRef struct {
 	ImageRepo string `json:"imageRepo,omitempty"`
 }
 
+// NewConfig returns config of older apiVersion so that written
+// configuration (e.g. lock output) could be used by older kbld versions
+// (fields that are converted between apiVersions are not used in such configuration)
 func NewConfig() Config {
 	return Config{
-		APIVersion: configAPIVersion,
+		APIVersion: configAPIVersionV1alpha1,
 		Kind:       configKind,
 	}
 }
 
+// NewConfigFromResource converts resource of older apiVersion (if necessary)
+// and validates it against schema so that unknown fields are not ignored
 func NewConfigFromResource(res ctlres.Resource) (Config, error) {
-	bs, err := res.AsYAMLBytes()
+	doc := res.DeepCopyRaw()
+
+	err := convertConfig(doc)
+	if err != nil {
+		return Config{}, fmt.Errorf("Converting %s: %s", res.Description(), err)
+	}
+
+	if errs := ValidateAgainstSchema(doc, configSchema); len(errs) > 0 {
+		var errStrs []string
+		for _, err := range errs {
+			errStrs = append(errStrs, err.Error())
+		}
+		return Config{}, fmt.Errorf("Validating %s against schema:\n- %s", res.Description(), strings.Join(errStrs, "\n- "))
+	}
+
+	bs, err := json.Marshal(doc)
 	if err != nil {
 		return Config{}, err
 	}
@@ -152,16 +316,33 @@ func NewConfigFromResource(res ctlres.Resource) (Config, error) {
 		return Config{}, fmt.Errorf("Unmarshaling %s: %s", res.Description(), err)
 	}
 
+	for i, imageDst := range config.Destinations {
+		if len(imageDst.NewImage) == 0 {
+			if len(imageDst.NewImages) > 0 {
+				imageDst.NewImage = imageDst.NewImages[0]
+			} else {
+				imageDst.NewImage = imageDst.Image
+			}
+		}
+		config.Destinations[i] = imageDst
+	}
+
 	err = config.Validate()
 	if err != nil {
 		return Config{}, fmt.Errorf("Validating %s: %s", res.Description(), err)
 	}
 
-	for i, imageDst := range config.Destinations {
-		if len(imageDst.NewImage) == 0 {
-			imageDst.NewImage = imageDst.Image
+	if res.Kind() != configKind {
+		config.deprecations = append(config.deprecations, fmt.Sprintf(
+			"Resource %s uses deprecated kind '%s', use kind '%s' instead", res.Description(), res.Kind(), configKind))
+	}
+
+	for i, nameOrPath := range config.SearchRulePacks {
+		pack, err := LoadSearchRulePack(nameOrPath)
+		if err != nil {
+			return Config{}, fmt.Errorf("Validating %s: Validating SearchRulePacks[%d]: %s", res.Description(), i, err)
 		}
-		config.Destinations[i] = imageDst
+		config.searchRulePacks = append(config.searchRulePacks, pack)
 	}
 
 	return config, nil
@@ -247,12 +428,6 @@ func (d Config) Validate() error {
 		}
 	}
 
-	for i, key := range d.Keys {
-		if len(key) == 0 {
-			return fmt.Errorf("Validating Destinations[%d]: Expected to be non-empty", i)
-		}
-	}
-
 	for i, sr := range d.SearchRules {
 		err := sr.Validate()
 		if err != nil {
@@ -260,20 +435,197 @@ func (d Config) Validate() error {
 		}
 	}
 
+	for i, pack := range d.SearchRulePacks {
+		if len(pack) == 0 {
+			return fmt.Errorf("Validating SearchRulePacks[%d]: Expected to be non-empty", i)
+		}
+	}
+
+	if d.Signing != nil {
+		err := d.Signing.Validate()
+		if err != nil {
+			return fmt.Errorf("Validating Signing: %s", err)
+		}
+	}
+
+	for i, policy := range d.VerificationPolicies {
+		err := policy.Validate()
+		if err != nil {
+			return fmt.Errorf("Validating VerificationPolicies[%d]: %s", i, err)
+		}
+	}
+
+	if d.VulnerabilityScan != nil {
+		err := d.VulnerabilityScan.Validate()
+		if err != nil {
+			return fmt.Errorf("Validating VulnerabilityScan: %s", err)
+		}
+	}
+
+	for i, policy := range d.Policies {
+		err := policy.Validate()
+		if err != nil {
+			return fmt.Errorf("Validating Policies[%d]: %s", i, err)
+		}
+	}
+
+	if d.ImageFreshness != nil {
+		err := d.ImageFreshness.Validate()
+		if err != nil {
+			return fmt.Errorf("Validating ImageFreshness: %s", err)
+		}
+	}
+
+	if d.ImagesAnnotation != nil {
+		err := d.ImagesAnnotation.Validate()
+		if err != nil {
+			return fmt.Errorf("Validating ImagesAnnotation: %s", err)
+		}
+	}
+
+	for i, rebase := range d.Rebases {
+		err := rebase.Validate()
+		if err != nil {
+			return fmt.Errorf("Validating Rebases[%d]: %s", i, err)
+		}
+	}
+
+	for i, cred := range d.Credentials {
+		err := cred.Validate()
+		if err != nil {
+			return fmt.Errorf("Validating Credentials[%d]: %s", i, err)
+		}
+	}
+
+	for i, proxy := range d.RegistryProxies {
+		err := proxy.Validate()
+		if err != nil {
+			return fmt.Errorf("Validating RegistryProxies[%d]: %s", i, err)
+		}
+	}
+
+	for i, profile := range d.ClusterProfiles {
+		err := profile.Validate()
+		if err != nil {
+			return fmt.Errorf("Validating ClusterProfiles[%d]: %s", i, err)
+		}
+	}
+
+	if d.Promotion != nil {
+		err := d.Promotion.Validate()
+		if err != nil {
+			return fmt.Errorf("Validating Promotion: %s", err)
+		}
+	}
+
+	for i, localImage := range d.LocalImages {
+		err := localImage.Validate()
+		if err != nil {
+			return fmt.Errorf("Validating LocalImages[%d]: %s", i, err)
+		}
+	}
+
 	return nil
 }
 
 func (d Source) Validate() error {
-	err := d.ImageRef.Validate()
-	if err != nil {
-		return err
+	if d.HasImagePattern() {
+		err := d.validateImagePattern()
+		if err != nil {
+			return err
+		}
+	} else if !d.hasBakeTargets() {
+		err := d.ImageRef.Validate()
+		if err != nil {
+			return err
+		}
 	}
 	if len(d.Path) == 0 {
 		return fmt.Errorf("Expected Path to be non-empty")
 	}
+	if d.SBOM != nil {
+		err := d.SBOM.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if len(d.Timeout) > 0 {
+		_, err := d.TimeoutDuration()
+		if err != nil {
+			return fmt.Errorf("Parsing Timeout: %s", err)
+		}
+	}
+	if d.Retry != nil {
+		err := d.Retry.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if d.Env != nil {
+		err := d.Env.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if d.Docker != nil && d.Docker.Buildx != nil {
+		err := d.Docker.Buildx.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if d.CodeBuild != nil {
+		err := d.CodeBuild.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if d.Depot != nil {
+		err := d.Depot.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if d.Archive != nil {
+		err := d.Archive.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if d.BaseImages != nil && (d.Ko != nil || d.Bazel != nil || d.CloudBuild != nil || d.CodeBuild != nil || d.Archive != nil) {
+		return fmt.Errorf("Expected BaseImages to be used only with Dockerfile or pack based builds")
+	}
+	err := d.validateBake()
+	if err != nil {
+		return err
+	}
+	err = d.validateAutodetect()
+	if err != nil {
+		return err
+	}
+	for i, variant := range d.Matrix {
+		err := variant.Validate(d)
+		if err != nil {
+			return fmt.Errorf("Validating Matrix[%d]: %s", i, err)
+		}
+	}
 	return nil
 }
 
+// TimeoutDuration returns build timeout (zero when not specified)
+func (d Source) TimeoutDuration() (time.Duration, error) {
+	if len(d.Timeout) == 0 {
+		return 0, nil
+	}
+	dur, err := time.ParseDuration(d.Timeout)
+	if err != nil {
+		return 0, err
+	}
+	if dur <= 0 {
+		return 0, fmt.Errorf("Expected '%s' to be a positive duration", d.Timeout)
+	}
+	return dur, nil
+}
+
 func (d ImageOverride) Validate() error {
 	err := d.ImageRef.Validate()
 	if err != nil {
@@ -286,7 +638,129 @@ func (d ImageOverride) Validate() error {
 }
 
 func (d ImageDestination) Validate() error {
-	return d.ImageRef.Validate()
+	err := d.ImageRef.Validate()
+	if err != nil {
+		return err
+	}
+	for i, newImage := range d.NewImages {
+		if len(newImage) == 0 {
+			return fmt.Errorf("Expected NewImages[%d] to be non-empty", i)
+		}
+	}
+	if d.When != nil {
+		for name, pattern := range d.When.Env {
+			if len(name) == 0 {
+				return fmt.Errorf("Expected When.Env keys to be non-empty")
+			}
+			_, err := path.Match(pattern, "")
+			if err != nil {
+				return fmt.Errorf("Parsing When.Env[%s] pattern '%s': %s", name, pattern, err)
+			}
+		}
+		_, err := path.Match(d.When.GitBranch, "")
+		if err != nil {
+			return fmt.Errorf("Parsing When.GitBranch pattern '%s': %s", d.When.GitBranch, err)
+		}
+	}
+	if d.Auth != nil {
+		err := d.Auth.Validate()
+		if err != nil {
+			return fmt.Errorf("Validating Auth: %s", err)
+		}
+	}
+	switch d.Mode {
+	case "", ImageDestinationModeCopy, ImageDestinationModeRetag:
+	default:
+		return fmt.Errorf("Expected Mode to be one of '%s' or '%s', but was '%s'",
+			ImageDestinationModeCopy, ImageDestinationModeRetag, d.Mode)
+	}
+	if d.SquashLayers && d.Mode == ImageDestinationModeRetag {
+		return fmt.Errorf("Expected SquashLayers to not be used with '%s' mode", ImageDestinationModeRetag)
+	}
+	if len(d.SquashBaseImage) > 0 && !d.SquashLayers {
+		return fmt.Errorf("Expected SquashLayers to be enabled when SquashBaseImage is specified")
+	}
+	for i, hook := range d.PrePush {
+		err := hook.Validate()
+		if err != nil {
+			return fmt.Errorf("Validating PrePush[%d]: %s", i, err)
+		}
+	}
+	for i, hook := range d.PostPush {
+		err := hook.Validate()
+		if err != nil {
+			return fmt.Errorf("Validating PostPush[%d]: %s", i, err)
+		}
+	}
+	if d.IntermediateTag != nil {
+		err := d.IntermediateTag.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if d.ECR != nil {
+		err := d.ECR.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if len(d.OutputNewImage) > 0 {
+		var found bool
+		for _, newImage := range append([]string{d.NewImage}, d.NewImages...) {
+			if newImage == d.OutputNewImage {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("Expected OutputNewImage '%s' to be one of destination images", d.OutputNewImage)
+		}
+	}
+	return nil
+}
+
+func (d ImageDestination) OnlyIfBuiltWithDefaults() bool {
+	return d.OnlyIfBuilt == nil || *d.OnlyIfBuilt
+}
+
+// AdditionalNewImages returns destinations other than NewImage
+func (d ImageDestination) AdditionalNewImages() []string {
+	var result []string
+	for _, newImage := range d.NewImages {
+		if newImage != d.NewImage {
+			result = append(result, newImage)
+		}
+	}
+	return result
+}
+
+func (d ImageDestinationAuth) Validate() error {
+	var count int
+	if len(d.EnvPrefix) > 0 {
+		count++
+	}
+	if len(d.AuthFile) > 0 {
+		count++
+	}
+	if d.SecretRef != nil {
+		count++
+		if len(d.SecretRef.Name) == 0 {
+			return fmt.Errorf("Expected SecretRef.Name to be non-empty")
+		}
+	}
+	if count != 1 {
+		return fmt.Errorf("Expected exactly one of EnvPrefix, AuthFile or SecretRef to be specified")
+	}
+	return nil
+}
+
+func (d ImageDestinationHook) Validate() error {
+	if (len(d.Command) > 0) == (d.Webhook != nil) {
+		return fmt.Errorf("Expected exactly one of Command or Webhook to be specified")
+	}
+	if d.Webhook != nil && len(d.Webhook.URL) == 0 {
+		return fmt.Errorf("Expected Webhook.URL to be non-empty")
+	}
+	return nil
 }
 
 func (d SearchRule) Validate() error {
@@ -294,13 +768,20 @@ func (d SearchRule) Validate() error {
 		return fmt.Errorf("Expected KeyMatcher or ValueMatcher to be non-empty")
 	}
 	if d.KeyMatcher != nil {
-		if len(d.KeyMatcher.Name) == 0 && len(d.KeyMatcher.Path) == 0 {
-			return fmt.Errorf("Expected KeyMatcher.Name or KeyMatcher.Path to be non-empty")
+		if len(d.KeyMatcher.Name) == 0 && len(d.KeyMatcher.Path) == 0 &&
+			len(d.KeyMatcher.EnvVarNamePrefix) == 0 && len(d.KeyMatcher.EnvVarNameRegexp) == 0 {
+			return fmt.Errorf("Expected KeyMatcher.Name, KeyMatcher.Path, KeyMatcher.EnvVarNamePrefix or KeyMatcher.EnvVarNameRegexp to be non-empty")
+		}
+		if len(d.KeyMatcher.EnvVarNameRegexp) > 0 {
+			_, err := regexp.Compile(d.KeyMatcher.EnvVarNameRegexp)
+			if err != nil {
+				return fmt.Errorf("Parsing KeyMatcher.EnvVarNameRegexp: %s", err)
+			}
 		}
 	}
 	if d.ValueMatcher != nil {
-		if len(d.ValueMatcher.Image) == 0 && len(d.ValueMatcher.ImageRepo) == 0 {
-			return fmt.Errorf("Expected ValueMatcher.Image or ValueMatcher.ImageRepo to be non-empty")
+		if len(d.ValueMatcher.Image) == 0 && len(d.ValueMatcher.ImageRepo) == 0 && !d.ValueMatcher.ImageLike {
+			return fmt.Errorf("Expected ValueMatcher.Image, ValueMatcher.ImageRepo or ValueMatcher.ImageLike to be non-empty")
 		}
 	}
 	return nil
@@ -0,0 +1,118 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+const (
+	DefaultImagesAnnotationKey = "kbld.k14s.io/images"
+
+	// DefaultImagesAnnotationMaxSize matches total size limit of annotations in Kubernetes
+	DefaultImagesAnnotationMaxSize = 256 * 1024
+
+	ImagesAnnotationOverflowTruncate  = "truncate"
+	ImagesAnnotationOverflowConfigMap = "configMap"
+)
+
+// ImagesAnnotation customizes annotation that records
+// resolved images (and their origins) on each resource
+type ImagesAnnotation struct {
+	// Key replaces default kbld.k14s.io/images annotation key
+	Key string `json:"key,omitempty"`
+	// Include adds extra fields to each image entry
+	Include ImagesAnnotationInclude `json:"include,omitempty"`
+	// MaxSize limits size of annotation value in bytes. When exceeded (with truncate overflow), origins
+	// are omitted first and then images are dropped until value fits
+	// (annotation with "-truncated" suffix is added to indicate that).
+	MaxSize int `json:"maxSize,omitempty"`
+	// Overflow is either truncate (default) or configMap. When set to configMap,
+	// metadata exceeding MaxSize (defaults to 256KiB) is moved into a companion
+	// ConfigMap that is referenced via annotation with "-configmap" suffix.
+	Overflow string `json:"overflow,omitempty"`
+	// Exclude lists resources that should not be annotated
+	Exclude []ImagesAnnotationResourceMatcher `json:"exclude,omitempty"`
+}
+
+type ImagesAnnotationInclude struct {
+	// Tag of the image reference found in the resource
+	Tag bool `json:"tag,omitempty"`
+	// OriginFile is path of the file resource was read from
+	OriginFile bool `json:"originFile,omitempty"`
+}
+
+type ImagesAnnotationResourceMatcher struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+}
+
+func (d ImagesAnnotation) KeyWithDefaults() string {
+	if len(d.Key) == 0 {
+		return DefaultImagesAnnotationKey
+	}
+	return d.Key
+}
+
+// Excludes returns true when resource should not be annotated
+func (d ImagesAnnotation) Excludes(res ctlres.Resource) bool {
+	for _, matcher := range d.Exclude {
+		if matcher.Matches(res) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d ImagesAnnotation) OverflowWithDefaults() string {
+	if len(d.Overflow) == 0 {
+		return ImagesAnnotationOverflowTruncate
+	}
+	return d.Overflow
+}
+
+// MaxSizeWithDefaults returns 0 when size is not limited
+func (d ImagesAnnotation) MaxSizeWithDefaults() int {
+	if d.MaxSize == 0 && d.OverflowWithDefaults() == ImagesAnnotationOverflowConfigMap {
+		return DefaultImagesAnnotationMaxSize
+	}
+	return d.MaxSize
+}
+
+func (d ImagesAnnotation) Validate() error {
+	if len(d.Key) > 0 {
+		pieces := strings.Split(d.Key, "/")
+		if len(pieces) > 2 || len(pieces[len(pieces)-1]) == 0 || strings.ContainsAny(d.Key, " \t\n") {
+			return fmt.Errorf("Expected Key '%s' to be a valid annotation key (format: [prefix/]name)", d.Key)
+		}
+	}
+	if d.MaxSize < 0 {
+		return fmt.Errorf("Expected MaxSize to be non-negative")
+	}
+	switch d.OverflowWithDefaults() {
+	case ImagesAnnotationOverflowTruncate, ImagesAnnotationOverflowConfigMap:
+	default:
+		return fmt.Errorf("Expected Overflow to be one of '%s' or '%s', but was '%s'",
+			ImagesAnnotationOverflowTruncate, ImagesAnnotationOverflowConfigMap, d.Overflow)
+	}
+	for i, matcher := range d.Exclude {
+		if len(matcher.APIVersion) == 0 && len(matcher.Kind) == 0 {
+			return fmt.Errorf("Expected Exclude[%d] to specify at least one of APIVersion or Kind", i)
+		}
+	}
+	return nil
+}
+
+func (m ImagesAnnotationResourceMatcher) Matches(res ctlres.Resource) bool {
+	if len(m.APIVersion) > 0 && m.APIVersion != res.APIVersion() {
+		return false
+	}
+	if len(m.Kind) > 0 && m.Kind != res.Kind() {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,27 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// SourceArchiveOpts uses image tarball produced by another tool
+// (OCI image layout or docker save tarball) instead of building image.
+// Image is pushed by kbld, hence image destination has to be configured.
+type SourceArchiveOpts struct {
+	// File is a path of tarball relative to source path
+	File string `json:"file"`
+}
+
+func (d SourceArchiveOpts) Validate() error {
+	if len(d.File) == 0 {
+		return fmt.Errorf("Expected Archive.File to be non-empty")
+	}
+	if filepath.IsAbs(d.File) {
+		return fmt.Errorf("Expected Archive.File to be relative to source path, but was '%s'", d.File)
+	}
+	return nil
+}
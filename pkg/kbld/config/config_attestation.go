@@ -0,0 +1,67 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+)
+
+// SourceProvenanceOpts enables SLSA provenance generation for built images
+type SourceProvenanceOpts struct {
+	// OutputDir receives in-toto statements named by image digest
+	OutputDir string `json:"outputDir,omitempty"`
+	// Attach attaches provenance as signed attestation (requires signing configuration)
+	Attach bool `json:"attach,omitempty"`
+}
+
+const (
+	SBOMFormatCycloneDX = "cyclonedx"
+	SBOMFormatSPDX      = "spdx"
+)
+
+// SourceSBOMOpts enables SBOM generation for built images
+type SourceSBOMOpts struct {
+	// Format is either cyclonedx (default) or spdx
+	Format string `json:"format,omitempty"`
+	// Command generates SBOM instead of syft. It receives KBLD_SBOM_IMAGE,
+	// KBLD_SBOM_FORMAT and KBLD_SBOM_OUTPUT (path to write SBOM to) env variables
+	Command []string `json:"command,omitempty"`
+	// OutputDir receives SBOMs named by image digest
+	OutputDir string `json:"outputDir,omitempty"`
+	// Attach attaches SBOM as signed attestation (requires signing configuration)
+	Attach bool `json:"attach,omitempty"`
+}
+
+func (d SourceSBOMOpts) FormatWithDefaults() string {
+	if len(d.Format) == 0 {
+		return SBOMFormatCycloneDX
+	}
+	return d.Format
+}
+
+func (d SourceSBOMOpts) Validate() error {
+	switch d.FormatWithDefaults() {
+	case SBOMFormatCycloneDX, SBOMFormatSPDX:
+	default:
+		return fmt.Errorf("Expected SBOM.Format to be one of '%s' or '%s', but was '%s'",
+			SBOMFormatCycloneDX, SBOMFormatSPDX, d.Format)
+	}
+	if len(d.OutputDir) == 0 && !d.Attach {
+		return fmt.Errorf("Expected SBOM.OutputDir or SBOM.Attach to be specified")
+	}
+	return nil
+}
+
+// SourceBaseImagesOpts controls base images (Dockerfile FROM images or pack builder)
+// that are resolved before build
+type SourceBaseImagesOpts struct {
+	// Verify requires base images to satisfy verification policies
+	Verify bool `json:"verify,omitempty"`
+	// PinDigests builds with base images replaced by resolved digest references
+	// (FROM instructions are rewritten, and images specified via global build
+	// arguments, e.g. FROM ${BASE_IMAGE}, are passed as '--build-arg' instead)
+	PinDigests bool `json:"pinDigests,omitempty"`
+	// Digests maps base images to expected digests (e.g. golang:1.21: sha256:...)
+	Digests map[string]string `json:"digests,omitempty"`
+}
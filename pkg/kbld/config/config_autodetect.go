@@ -0,0 +1,33 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+)
+
+// SourceAutodetectOpts picks builder based on contents of source path:
+// Dockerfile is built with Docker, Go module with main package is built
+// with ko, and anything else is built with buildpacks (pack).
+// Options of picked builder are used as is.
+type SourceAutodetectOpts struct {
+	Docker *SourceDockerOpts `json:"docker,omitempty"`
+	Ko     *SourceKoOpts     `json:"ko,omitempty"`
+	// Pack builder defaults to paketobuildpacks/builder-jammy-base
+	Pack *SourcePackOpts `json:"pack,omitempty"`
+}
+
+func (d Source) validateAutodetect() error {
+	if d.Autodetect == nil {
+		return nil
+	}
+	if d.Docker != nil || d.Pack != nil || d.KubectlBuildkit != nil || d.Ko != nil || d.Bazel != nil ||
+		d.CloudBuild != nil || d.CodeBuild != nil || d.Depot != nil || d.Archive != nil {
+		return fmt.Errorf("Expected Autodetect to not be specified together with other builders")
+	}
+	if d.Autodetect.Docker != nil && (d.Autodetect.Docker.Buildx != nil || d.Autodetect.Docker.Bake != nil) {
+		return fmt.Errorf("Expected Autodetect.Docker to only specify Build options")
+	}
+	return nil
+}
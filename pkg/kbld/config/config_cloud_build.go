@@ -0,0 +1,18 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+// SourceCloudBuildOpts builds image remotely with Google Cloud Build
+// (gcloud builds submit). Built image is pushed by Cloud Build itself,
+// hence image destination has to be configured.
+type SourceCloudBuildOpts struct {
+	Project *string `json:"project,omitempty"`
+	Region  *string `json:"region,omitempty"`
+	// Config is a path to build config (e.g. cloudbuild.yaml) relative to source path
+	// (Dockerfile is built when not specified). Image that should be pushed is given
+	// via _KBLD_IMAGE substitution and has to be listed in images of build config.
+	Config        *string           `json:"config,omitempty"`
+	Substitutions map[string]string `json:"substitutions,omitempty"`
+	RawOptions    *[]string         `json:"rawOptions"`
+}
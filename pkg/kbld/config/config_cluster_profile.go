@@ -0,0 +1,52 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ClusterProfile describes defaults used when resolving images
+// for a particular destination cluster (selected via --cluster-profile)
+type ClusterProfile struct {
+	Name string `json:"name"`
+	// Platform is selected from image indexes unless --platform is specified
+	// (e.g. linux/arm/v7 for clusters of edge devices)
+	Platform *PlatformSelection `json:"platform,omitempty"`
+}
+
+func (d ClusterProfile) Validate() error {
+	if len(d.Name) == 0 {
+		return fmt.Errorf("Expected Name to be non-empty")
+	}
+	if d.Platform != nil && (len(d.Platform.OS) == 0 || len(d.Platform.Architecture) == 0) {
+		return fmt.Errorf("Expected Platform to specify OS and Architecture")
+	}
+	return nil
+}
+
+// ClusterProfile returns profile with given name
+// (profile defined later takes precedence)
+func (c Conf) ClusterProfile(name string) (ClusterProfile, error) {
+	var found *ClusterProfile
+	var names []string
+
+	for _, profile := range c.ClusterProfiles() {
+		profile := profile // copy
+		if profile.Name == name {
+			found = &profile
+		}
+		names = append(names, profile.Name)
+	}
+
+	if found == nil {
+		sort.Strings(names)
+		return ClusterProfile{}, fmt.Errorf("Expected cluster profile '%s' to be defined in configuration "+
+			"(defined: %s)", name, strings.Join(names, ", "))
+	}
+
+	return *found, nil
+}
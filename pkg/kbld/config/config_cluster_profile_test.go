@@ -0,0 +1,55 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+func TestConfigClusterProfiles(t *testing.T) {
+	rs, err := ctlres.NewResourcesFromBytes([]byte(`
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+clusterProfiles:
+- name: edge
+  platform:
+    os: linux
+    architecture: arm
+    variant: v7
+- name: cloud
+  platform:
+    os: linux
+    architecture: amd64
+`))
+	require.NoError(t, err)
+
+	_, conf, err := ctlconf.NewConfFromResources(rs)
+	require.NoError(t, err)
+
+	profile, err := conf.ClusterProfile("edge")
+	require.NoError(t, err)
+	assert.Equal(t, &ctlconf.PlatformSelection{OS: "linux", Architecture: "arm", Variant: "v7"}, profile.Platform)
+
+	_, err = conf.ClusterProfile("onprem")
+	require.EqualError(t, err, "Expected cluster profile 'onprem' to be defined in configuration (defined: cloud, edge)")
+
+	rs, err = ctlres.NewResourcesFromBytes([]byte(`
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+clusterProfiles:
+- name: edge
+  platform:
+    variant: v7
+`))
+	require.NoError(t, err)
+
+	_, _, err = ctlconf.NewConfFromResources(rs)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Validating ClusterProfiles[0]: Expected Platform to specify OS and Architecture")
+}
@@ -0,0 +1,35 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+)
+
+// SourceCodeBuildOpts builds image remotely with AWS CodeBuild project.
+// Source directory is uploaded to S3 and build receives image that should
+// be pushed via KBLD_IMAGE env variable. Buildspec has to export digest
+// of pushed image as KBLD_IMAGE_DIGEST (via env.exported-variables).
+type SourceCodeBuildOpts struct {
+	Project string `json:"project"`
+	// SourceBucket is S3 bucket (optionally followed by key prefix, e.g. bucket/kbld)
+	// that source directory is uploaded to
+	SourceBucket string  `json:"sourceBucket"`
+	Region       *string `json:"region,omitempty"`
+	// Buildspec overrides buildspec of the project (path relative to source path or inline YAML)
+	Buildspec *string           `json:"buildspec,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	// RawOptions are passed to 'aws codebuild start-build'
+	RawOptions *[]string `json:"rawOptions"`
+}
+
+func (d SourceCodeBuildOpts) Validate() error {
+	if len(d.Project) == 0 {
+		return fmt.Errorf("Expected CodeBuild.Project to be non-empty")
+	}
+	if len(d.SourceBucket) == 0 {
+		return fmt.Errorf("Expected CodeBuild.SourceBucket to be non-empty")
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+)
+
+// configConversion converts document of one apiVersion to the next one
+type configConversion struct {
+	To      string
+	Convert func(doc map[string]interface{}) error
+}
+
+var (
+	configConversions = map[string]configConversion{
+		configAPIVersionV1alpha1: {To: configAPIVersion, Convert: convertConfigV1alpha1},
+	}
+)
+
+// convertConfig converts document of older apiVersion in place
+// into document of current apiVersion
+func convertConfig(doc map[string]interface{}) error {
+	for {
+		apiVersion, _ := doc["apiVersion"].(string)
+		if apiVersion == configAPIVersion {
+			return nil
+		}
+
+		conversion, found := configConversions[apiVersion]
+		if !found {
+			return fmt.Errorf("Expected apiVersion to be one of %s, but was '%s'", configAPIVersion, apiVersion)
+		}
+
+		err := conversion.Convert(doc)
+		if err != nil {
+			return fmt.Errorf("Converting from %s to %s: %s", apiVersion, conversion.To, err)
+		}
+
+		doc["apiVersion"] = conversion.To
+	}
+}
+
+// convertConfigV1alpha1 merges kinds that only specify part of configuration
+// (Sources, ImageOverrides, ImageDestinations, ImageKeys) into Config kind,
+// and converts keys into search rules matching keys by name
+func convertConfigV1alpha1(doc map[string]interface{}) error {
+	doc["kind"] = configKind
+
+	keysVal, found := doc["keys"]
+	if !found {
+		return nil
+	}
+	delete(doc, "keys")
+
+	keys, ok := keysVal.([]interface{})
+	if !ok && keysVal != nil {
+		return fmt.Errorf("Expected 'keys' to be array, but was %s", schemaTypeOf(keysVal))
+	}
+
+	var rules []interface{}
+
+	for i, key := range keys {
+		keyStr, ok := key.(string)
+		if !ok || len(keyStr) == 0 {
+			return fmt.Errorf("Expected 'keys[%d]' to be non-empty string", i)
+		}
+		rules = append(rules, map[string]interface{}{
+			"keyMatcher": map[string]interface{}{"name": keyStr},
+		})
+	}
+
+	// Keys take precedence over search rules
+	if existingRulesVal, found := doc["searchRules"]; found && existingRulesVal != nil {
+		existingRules, ok := existingRulesVal.([]interface{})
+		if !ok {
+			return fmt.Errorf("Expected 'searchRules' to be array, but was %s", schemaTypeOf(existingRulesVal))
+		}
+		rules = append(rules, existingRules...)
+	}
+	if len(rules) > 0 {
+		doc["searchRules"] = rules
+	}
+
+	return nil
+}
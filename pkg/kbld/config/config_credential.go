@@ -0,0 +1,30 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+)
+
+// ImageCredential provides credentials used by kbld when resolving
+// (and copying from) matching images, so that images could be pulled
+// from several private registries within the same run
+type ImageCredential struct {
+	ImageRef
+	Auth ImageDestinationAuth `json:"auth"`
+}
+
+func (d ImageCredential) Validate() error {
+	err := d.ImageRef.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = d.Auth.Validate()
+	if err != nil {
+		return fmt.Errorf("Validating Auth: %s", err)
+	}
+
+	return nil
+}
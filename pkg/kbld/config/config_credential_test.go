@@ -0,0 +1,50 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+func TestConfigCredentials(t *testing.T) {
+	rs, err := ctlres.NewResourcesFromBytes([]byte(`
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+credentials:
+- imageRepo: registry-a.example.com/app
+  auth:
+    envPrefix: REGISTRY_A
+- image: registry-b.example.com/db:1.0
+  auth:
+    authFile: /tmp/registry-b.json
+`))
+	require.NoError(t, err)
+
+	_, conf, err := ctlconf.NewConfFromResources(rs)
+	require.NoError(t, err)
+
+	creds := conf.Credentials()
+	require.Len(t, creds, 2)
+	assert.Equal(t, "registry-a.example.com/app", creds[0].ImageRepo)
+	assert.Equal(t, "REGISTRY_A", creds[0].Auth.EnvPrefix)
+	assert.Equal(t, "/tmp/registry-b.json", creds[1].Auth.AuthFile)
+
+	rs, err = ctlres.NewResourcesFromBytes([]byte(`
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+credentials:
+- imageRepo: registry-a.example.com/app
+  auth: {}
+`))
+	require.NoError(t, err)
+
+	_, _, err = ctlconf.NewConfFromResources(rs)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Validating Credentials[0]: Validating Auth: Expected exactly one of EnvPrefix, AuthFile or SecretRef to be specified")
+}
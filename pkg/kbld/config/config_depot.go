@@ -0,0 +1,36 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+)
+
+// SourceDepotOpts builds Dockerfile on hosted BuildKit builders
+// of Depot (depot build). Multi-platform images are pushed directly
+// from builders, hence image destination has to be configured for them.
+type SourceDepotOpts struct {
+	// Project is Depot project ID (defaults to project of depot.json in source path)
+	Project *string `json:"project,omitempty"`
+	// TokenEnv is a name of env variable holding API token (defaults to DEPOT_TOKEN)
+	TokenEnv *string `json:"tokenEnv,omitempty"`
+	// Platforms are built into single image index (e.g. linux/amd64, linux/arm64)
+	Platforms  []string  `json:"platforms,omitempty"`
+	Target     *string   `json:"target,omitempty"`
+	NoCache    *bool     `json:"noCache,omitempty"`
+	File       *string   `json:"file,omitempty"`
+	RawOptions *[]string `json:"rawOptions"`
+}
+
+func (d SourceDepotOpts) Validate() error {
+	if d.TokenEnv != nil && len(*d.TokenEnv) == 0 {
+		return fmt.Errorf("Expected Depot.TokenEnv to be non-empty")
+	}
+	for i, platform := range d.Platforms {
+		if len(platform) == 0 {
+			return fmt.Errorf("Expected Depot.Platforms[%d] to be non-empty", i)
+		}
+	}
+	return nil
+}
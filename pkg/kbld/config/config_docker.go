@@ -3,9 +3,14 @@
 
 package config
 
+import (
+	"fmt"
+)
+
 type SourceDockerOpts struct {
 	Build  SourceDockerBuildOpts
 	Buildx *SourceDockerBuildxOpts
+	Bake   *SourceDockerBakeOpts `json:"bake,omitempty"`
 }
 
 type SourceDockerBuildOpts struct {
@@ -17,10 +22,77 @@ type SourceDockerBuildOpts struct {
 	RawOptions *[]string `json:"rawOptions"`
 }
 
+const (
+	// SourceDockerBuildxOutputLoad loads image into Docker daemon (it is pushed with docker push)
+	SourceDockerBuildxOutputLoad = "load"
+	// SourceDockerBuildxOutputPush pushes image by buildx itself
+	SourceDockerBuildxOutputPush = "push"
+	// SourceDockerBuildxOutputOCI exports image as OCI tarball pushed by kbld
+	SourceDockerBuildxOutputOCI = "oci"
+)
+
 type SourceDockerBuildxOpts struct {
 	Target     *string
 	Pull       *bool
 	NoCache    *bool `json:"noCache"`
 	File       *string
 	RawOptions *[]string `json:"rawOptions"`
+	// Output is either load, push or oci (defaults to push
+	// when image destination is configured, otherwise load)
+	Output *string `json:"output,omitempty"`
+}
+
+func (d SourceDockerBuildxOpts) Validate() error {
+	if d.Output == nil {
+		return nil
+	}
+	switch *d.Output {
+	case SourceDockerBuildxOutputLoad, SourceDockerBuildxOutputPush, SourceDockerBuildxOutputOCI:
+		return nil
+	default:
+		return fmt.Errorf("Expected Docker.Buildx.Output to be one of '%s', '%s' or '%s', but was '%s'",
+			SourceDockerBuildxOutputLoad, SourceDockerBuildxOutputPush, SourceDockerBuildxOutputOCI, *d.Output)
+	}
+}
+
+// OutputWithDefaults returns how built image is made available
+func (d SourceDockerBuildxOpts) OutputWithDefaults(hasDestination bool) string {
+	switch {
+	case d.Output != nil:
+		return *d.Output
+	case hasDestination:
+		return SourceDockerBuildxOutputPush
+	default:
+		return SourceDockerBuildxOutputLoad
+	}
+}
+
+// SourceDockerBakeOpts builds image from a target of buildx bake definition
+// (e.g. docker-bake.hcl, docker-bake.json). Tags and outputs of target are
+// replaced so that kbld could push (or load) built image on its own.
+type SourceDockerBakeOpts struct {
+	// Files are bake definitions relative to source path
+	// (bake looks up its default files when empty)
+	Files []string `json:"files,omitempty"`
+	// Target is a name of target built for image
+	Target string `json:"target,omitempty"`
+	// Targets map bake targets onto images so that single source
+	// is expanded into a source per target
+	Targets []SourceDockerBakeTarget `json:"targets,omitempty"`
+	// Set overrides target attributes (e.g. *.platform=linux/amd64)
+	Set        []string  `json:"set,omitempty"`
+	RawOptions *[]string `json:"rawOptions"`
+}
+
+type SourceDockerBakeTarget struct {
+	Target string `json:"target"`
+	// Image is an image name given to built target (defaults to target name)
+	Image string `json:"image,omitempty"`
+}
+
+func (d SourceDockerBakeTarget) ImageWithDefaults() string {
+	if len(d.Image) == 0 {
+		return d.Target
+	}
+	return d.Image
 }
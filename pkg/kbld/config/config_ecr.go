@@ -0,0 +1,45 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+)
+
+const (
+	ImageDestinationECRTagMutabilityMutable   = "MUTABLE"
+	ImageDestinationECRTagMutabilityImmutable = "IMMUTABLE"
+)
+
+// ImageDestinationECR checks that destination repositories exist in AWS ECR
+// before image is built or pushed since ECR rejects pushes to missing repositories
+// (aws CLI is used with credentials and region derived from repository host)
+type ImageDestinationECR struct {
+	// CreateRepositories creates missing repositories
+	// (otherwise kbld fails listing missing repositories)
+	CreateRepositories bool `json:"createRepositories,omitempty"`
+	// Tags are set on created repositories
+	Tags map[string]string `json:"tags,omitempty"`
+	// ScanOnPush enables scanning of images pushed to created repositories
+	ScanOnPush bool `json:"scanOnPush,omitempty"`
+	// ImageTagMutability of created repositories (MUTABLE or IMMUTABLE)
+	ImageTagMutability string `json:"imageTagMutability,omitempty"`
+	// Profile selects aws CLI profile
+	Profile string `json:"profile,omitempty"`
+}
+
+func (d ImageDestinationECR) Validate() error {
+	switch d.ImageTagMutability {
+	case "", ImageDestinationECRTagMutabilityMutable, ImageDestinationECRTagMutabilityImmutable:
+	default:
+		return fmt.Errorf("Expected ECR.ImageTagMutability to be one of '%s' or '%s', but was '%s'",
+			ImageDestinationECRTagMutabilityMutable, ImageDestinationECRTagMutabilityImmutable, d.ImageTagMutability)
+	}
+	for key := range d.Tags {
+		if len(key) == 0 {
+			return fmt.Errorf("Expected ECR.Tags keys to be non-empty")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ImageFreshnessActionWarn = "warn"
+	ImageFreshnessActionFail = "fail"
+)
+
+// ImageFreshness checks that resolved images are rebuilt regularly
+type ImageFreshness struct {
+	// MaxAge is maximum allowed age of image creation timestamp (e.g. 720h, 30d, 2w)
+	MaxAge string `json:"maxAge,omitempty"`
+	// TagMoved checks that tags images were originally resolved from
+	// still point to the same digest (only applies to images from lock files)
+	TagMoved bool `json:"tagMoved,omitempty"`
+	// Action is either warn (default) or fail
+	Action string `json:"action,omitempty"`
+}
+
+func (d ImageFreshness) ActionWithDefaults() string {
+	if len(d.Action) == 0 {
+		return ImageFreshnessActionWarn
+	}
+	return d.Action
+}
+
+func (d ImageFreshness) Validate() error {
+	switch d.ActionWithDefaults() {
+	case ImageFreshnessActionWarn, ImageFreshnessActionFail:
+	default:
+		return fmt.Errorf("Expected Action to be one of '%s' or '%s', but was '%s'",
+			ImageFreshnessActionWarn, ImageFreshnessActionFail, d.Action)
+	}
+	if len(d.MaxAge) == 0 && !d.TagMoved {
+		return fmt.Errorf("Expected at least one of MaxAge or TagMoved to be specified")
+	}
+	if len(d.MaxAge) > 0 {
+		_, err := ParseAge(d.MaxAge)
+		if err != nil {
+			return fmt.Errorf("Parsing MaxAge: %s", err)
+		}
+	}
+	return nil
+}
+
+// ParseAge parses Go duration with additional support for days (d) and weeks (w)
+func ParseAge(str string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if !strings.HasSuffix(str, suffix) {
+			continue
+		}
+		num, err := strconv.ParseFloat(strings.TrimSuffix(str, suffix), 64)
+		if err != nil || num <= 0 {
+			return 0, fmt.Errorf("Expected '%s' to be a positive number of %s", str, suffix)
+		}
+		return time.Duration(num * float64(unit)), nil
+	}
+
+	dur, err := time.ParseDuration(str)
+	if err != nil {
+		return 0, err
+	}
+	if dur <= 0 {
+		return 0, fmt.Errorf("Expected '%s' to be a positive duration", str)
+	}
+	return dur, nil
+}
@@ -0,0 +1,34 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+)
+
+func TestParseAge(t *testing.T) {
+	cases := map[string]time.Duration{
+		"90m":  90 * time.Minute,
+		"720h": 30 * 24 * time.Hour,
+		"30d":  30 * 24 * time.Hour,
+		"1.5d": 36 * time.Hour,
+		"2w":   14 * 24 * time.Hour,
+	}
+
+	for str, expected := range cases {
+		dur, err := ctlconf.ParseAge(str)
+		require.NoError(t, err, "age: %s", str)
+		assert.Equal(t, expected, dur, "age: %s", str)
+	}
+
+	for _, str := range []string{"", "d", "-1d", "0h", "abc", "10y"} {
+		_, err := ctlconf.ParseAge(str)
+		assert.Error(t, err, "age: %s", str)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// ImageDestinationIntermediateTag configures tag used when built image
+// is pushed to destination before it's resolved to digest
+// (by default random tag is used for each push)
+type ImageDestinationIntermediateTag struct {
+	// Template is evaluated with the same data as destination tags
+	// and additionally ContentHash and Image (e.g. "build-{{.GitShortSHA}}-{{.Timestamp}}")
+	Template string `json:"template"`
+	// GarbageCollect deletes tags matching template (other than the one just pushed)
+	// after each push; template values are matched as any valid tag characters,
+	// hence template needs to start with a prefix distinguishing intermediate tags from other tags
+	GarbageCollect bool `json:"garbageCollect,omitempty"`
+}
+
+func (d ImageDestinationIntermediateTag) Validate() error {
+	tpl, err := d.parse()
+	if err != nil {
+		return fmt.Errorf("Parsing IntermediateTag.Template '%s': %s", d.Template, err)
+	}
+
+	if d.GarbageCollect && len(intermediateTagPrefix(tpl)) == 0 {
+		return fmt.Errorf("Expected IntermediateTag.Template to start with a prefix "+
+			"(e.g. 'build-') when GarbageCollect is enabled, but was '%s'", d.Template)
+	}
+
+	return nil
+}
+
+// Pattern returns regexp matching tags produced by the template
+func (d ImageDestinationIntermediateTag) Pattern() (*regexp.Regexp, error) {
+	tpl, err := d.parse()
+	if err != nil {
+		return nil, fmt.Errorf("Parsing IntermediateTag.Template '%s': %s", d.Template, err)
+	}
+
+	pattern := "^"
+
+	for _, node := range tpl.Tree.Root.Nodes {
+		if textNode, ok := node.(*parse.TextNode); ok {
+			pattern += regexp.QuoteMeta(string(textNode.Text))
+		} else {
+			pattern += "[a-zA-Z0-9_.-]*"
+		}
+	}
+
+	return regexp.Compile(pattern + "$")
+}
+
+func (d ImageDestinationIntermediateTag) parse() (*template.Template, error) {
+	if len(strings.TrimSpace(d.Template)) == 0 {
+		return nil, fmt.Errorf("Expected to be non-empty")
+	}
+	return template.New("tag").Option("missingkey=error").Parse(d.Template)
+}
+
+func intermediateTagPrefix(tpl *template.Template) string {
+	if len(tpl.Tree.Root.Nodes) > 0 {
+		if textNode, ok := tpl.Tree.Root.Nodes[0].(*parse.TextNode); ok {
+			return strings.TrimSpace(string(textNode.Text))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,39 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+)
+
+func TestImageDestinationIntermediateTagValidate(t *testing.T) {
+	assert.NoError(t, ctlconf.ImageDestinationIntermediateTag{Template: "{{.GitSHA}}"}.Validate())
+	assert.NoError(t, ctlconf.ImageDestinationIntermediateTag{Template: "build-{{.GitSHA}}", GarbageCollect: true}.Validate())
+
+	err := ctlconf.ImageDestinationIntermediateTag{Template: "{{.GitSHA}}-build", GarbageCollect: true}.Validate()
+	assert.EqualError(t, err, "Expected IntermediateTag.Template to start with a prefix (e.g. 'build-') "+
+		"when GarbageCollect is enabled, but was '{{.GitSHA}}-build'")
+
+	err = ctlconf.ImageDestinationIntermediateTag{}.Validate()
+	assert.EqualError(t, err, "Parsing IntermediateTag.Template '': Expected to be non-empty")
+
+	err = ctlconf.ImageDestinationIntermediateTag{Template: "build-{{.GitSHA"}.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Parsing IntermediateTag.Template 'build-{{.GitSHA'")
+}
+
+func TestImageDestinationIntermediateTagPattern(t *testing.T) {
+	pattern, err := ctlconf.ImageDestinationIntermediateTag{Template: "build.{{.GitShortSHA}}-{{.Timestamp}}"}.Pattern()
+	require.NoError(t, err)
+
+	assert.True(t, pattern.MatchString("build.0123456-20230506070809"))
+	assert.True(t, pattern.MatchString("build.-"))
+	assert.False(t, pattern.MatchString("buildx0123456-20230506070809"))
+	assert.False(t, pattern.MatchString("latest"))
+	assert.False(t, pattern.MatchString("v1-build.0123456-20230506070809"))
+}
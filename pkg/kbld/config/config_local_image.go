@@ -0,0 +1,63 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+)
+
+const (
+	LocalImageStoreDocker     = "docker"
+	LocalImageStoreContainerd = "containerd"
+
+	localImageDefaultNamespace = "k8s.io"
+)
+
+// LocalImage resolves images that are not found in registry via local
+// image store (e.g. images just built by another tool). Repo digest of
+// local image is used when available, otherwise image is pushed if allowed.
+type LocalImage struct {
+	// ImageRef limits local resolution to matching images.
+	// When not specified all images may be resolved locally.
+	ImageRef
+	// Store is either docker (default) or containerd (accessed via nerdctl)
+	Store string `json:"store,omitempty"`
+	// Namespace of containerd images (defaults to k8s.io)
+	Namespace string `json:"namespace,omitempty"`
+	// Push pushes local image to its reference when it has no repo digest
+	Push bool `json:"push,omitempty"`
+}
+
+func (d LocalImage) Validate() error {
+	switch d.StoreWithDefaults() {
+	case LocalImageStoreDocker:
+		if len(d.Namespace) > 0 {
+			return fmt.Errorf("Expected Namespace to be only specified for '%s' store", LocalImageStoreContainerd)
+		}
+	case LocalImageStoreContainerd:
+	default:
+		return fmt.Errorf("Expected Store to be either '%s' or '%s', but was '%s'",
+			LocalImageStoreDocker, LocalImageStoreContainerd, d.Store)
+	}
+	return nil
+}
+
+func (d LocalImage) StoreWithDefaults() string {
+	if len(d.Store) == 0 {
+		return LocalImageStoreDocker
+	}
+	return d.Store
+}
+
+func (d LocalImage) NamespaceWithDefaults() string {
+	if len(d.Namespace) == 0 {
+		return localImageDefaultNamespace
+	}
+	return d.Namespace
+}
+
+// MatchesAll indicates that local resolution is not limited to specific images
+func (d LocalImage) MatchesAll() bool {
+	return len(d.Image) == 0 && len(d.ImageRepo) == 0
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+func TestImageDestinationNewImagesDefaults(t *testing.T) {
+	rs, err := ctlres.NewResourcesFromBytes([]byte(`
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+destinations:
+- image: app
+  newImages: [registry-a.corp/app, registry-b.corp/app]
+- image: other
+  newImage: registry-a.corp/other
+  newImages: [registry-a.corp/other, registry-b.corp/other]
+  outputNewImage: registry-b.corp/other
+`))
+	require.NoError(t, err)
+
+	conf, err := ctlconf.NewConfigFromResource(rs[0])
+	require.NoError(t, err)
+	require.Len(t, conf.Destinations, 2)
+
+	assert.Equal(t, "registry-a.corp/app", conf.Destinations[0].NewImage)
+	assert.Equal(t, []string{"registry-b.corp/app"}, conf.Destinations[0].AdditionalNewImages())
+
+	assert.Equal(t, "registry-a.corp/other", conf.Destinations[1].NewImage)
+	assert.Equal(t, []string{"registry-b.corp/other"}, conf.Destinations[1].AdditionalNewImages())
+	assert.Equal(t, "registry-b.corp/other", conf.Destinations[1].OutputNewImage)
+}
+
+func TestImageDestinationAdditionalNewImages(t *testing.T) {
+	assert.Empty(t, ctlconf.ImageDestination{NewImage: "registry.corp/app"}.AdditionalNewImages())
+
+	dst := ctlconf.ImageDestination{
+		NewImage:  "registry-b.corp/app",
+		NewImages: []string{"registry-a.corp/app", "registry-b.corp/app", "registry-c.corp/app"},
+	}
+	assert.Equal(t, []string{"registry-a.corp/app", "registry-c.corp/app"}, dst.AdditionalNewImages())
+}
+
+func TestImageDestinationMultiDestinationValidate(t *testing.T) {
+	valid := ctlconf.ImageDestination{
+		ImageRef:       ctlconf.ImageRef{Image: "app"},
+		NewImage:       "registry-a.corp/app",
+		NewImages:      []string{"registry-a.corp/app", "registry-b.corp/app"},
+		OutputNewImage: "registry-b.corp/app",
+	}
+	assert.NoError(t, valid.Validate())
+
+	dst := valid
+	dst.NewImages = []string{"registry-a.corp/app", ""}
+	assert.EqualError(t, dst.Validate(), "Expected NewImages[1] to be non-empty")
+
+	dst = valid
+	dst.OutputNewImage = "registry-c.corp/app"
+	assert.EqualError(t, dst.Validate(), "Expected OutputNewImage 'registry-c.corp/app' to be one of destination images")
+
+	dst = valid
+	dst.Mode = "move"
+	assert.EqualError(t, dst.Validate(), "Expected Mode to be one of 'copy' or 'retag', but was 'move'")
+
+	dst = valid
+	dst.Mode = ctlconf.ImageDestinationModeRetag
+	dst.SquashLayers = true
+	assert.EqualError(t, dst.Validate(), "Expected SquashLayers to not be used with 'retag' mode")
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+)
+
+const (
+	DefaultOPAPolicyQuery = "data.kbld.deny"
+)
+
+// Policy is evaluated against full set of resolved images before output is emitted
+type Policy struct {
+	OPA *PolicyOPA `json:"opa,omitempty"`
+	CUE *PolicyCUE `json:"cue,omitempty"`
+}
+
+type PolicyOPA struct {
+	// Path to Rego file or directory
+	Path string `json:"path"`
+	// Query is expected to evaluate to a set or array of violation messages
+	Query string `json:"query,omitempty"`
+}
+
+type PolicyCUE struct {
+	// Path to CUE file that input is unified with (via cue vet)
+	Path string `json:"path"`
+}
+
+func (d PolicyOPA) QueryWithDefaults() string {
+	if len(d.Query) == 0 {
+		return DefaultOPAPolicyQuery
+	}
+	return d.Query
+}
+
+func (d Policy) Validate() error {
+	switch {
+	case d.OPA != nil && d.CUE != nil:
+		return fmt.Errorf("Expected only one of OPA or CUE to be specified")
+	case d.OPA != nil:
+		if len(d.OPA.Path) == 0 {
+			return fmt.Errorf("Expected OPA.Path to be non-empty")
+		}
+	case d.CUE != nil:
+		if len(d.CUE.Path) == 0 {
+			return fmt.Errorf("Expected CUE.Path to be non-empty")
+		}
+	default:
+		return fmt.Errorf("Expected one of OPA or CUE to be specified")
+	}
+	return nil
+}
+
+// Description returns short human readable policy identifier
+func (d Policy) Description() string {
+	switch {
+	case d.OPA != nil:
+		return fmt.Sprintf("opa: %s (%s)", d.OPA.Path, d.OPA.QueryWithDefaults())
+	case d.CUE != nil:
+		return fmt.Sprintf("cue: %s", d.CUE.Path)
+	default:
+		return "unknown"
+	}
+}
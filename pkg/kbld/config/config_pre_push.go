@@ -0,0 +1,96 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+)
+
+const (
+	RepositoryVisibilityPublic  = "public"
+	RepositoryVisibilityPrivate = "private"
+)
+
+// ImageDestinationPrePushHook provisions destination repositories
+// (e.g. creates Harbor projects) before image is built (or resolved) and pushed
+type ImageDestinationPrePushHook struct {
+	// Command is executed for each destination repository
+	// with KBLD_PUSH_REPOSITORY env variable (e.g. registry.corp/team/app)
+	Command []string `json:"command,omitempty"`
+	// Harbor ensures that project of repository exists
+	Harbor *ImageDestinationHarbor `json:"harbor,omitempty"`
+	// Quay ensures that repository exists
+	Quay *ImageDestinationQuay `json:"quay,omitempty"`
+}
+
+type ImageDestinationHarbor struct {
+	// URL of Harbor (defaults to https://<registry host>)
+	URL string `json:"url,omitempty"`
+	// Username and Password may refer to env variables (e.g. "${HARBOR_PASSWORD}")
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Visibility of project (public or private); created projects
+	// are private by default and visibility of existing ones is kept
+	Visibility string `json:"visibility,omitempty"`
+}
+
+type ImageDestinationQuay struct {
+	// URL of Quay (defaults to https://<registry host>)
+	URL string `json:"url,omitempty"`
+	// Token is OAuth access token with repository create permission
+	// and may refer to env variables (e.g. "${QUAY_TOKEN}")
+	Token string `json:"token"`
+	// Visibility of repository (public or private); created repositories
+	// are private by default and visibility of existing ones is kept
+	Visibility string `json:"visibility,omitempty"`
+}
+
+func (d ImageDestinationPrePushHook) Validate() error {
+	var count int
+	if len(d.Command) > 0 {
+		count++
+	}
+	if d.Harbor != nil {
+		count++
+		err := d.Harbor.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if d.Quay != nil {
+		count++
+		err := d.Quay.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if count != 1 {
+		return fmt.Errorf("Expected exactly one of Command, Harbor or Quay to be specified, but %d were", count)
+	}
+	return nil
+}
+
+func (d ImageDestinationHarbor) Validate() error {
+	if (len(d.Username) > 0) != (len(d.Password) > 0) {
+		return fmt.Errorf("Expected Harbor.Username and Harbor.Password to be specified together")
+	}
+	return validateRepositoryVisibility("Harbor.Visibility", d.Visibility)
+}
+
+func (d ImageDestinationQuay) Validate() error {
+	if len(d.Token) == 0 {
+		return fmt.Errorf("Expected Quay.Token to be non-empty")
+	}
+	return validateRepositoryVisibility("Quay.Visibility", d.Visibility)
+}
+
+func validateRepositoryVisibility(field, visibility string) error {
+	switch visibility {
+	case "", RepositoryVisibilityPublic, RepositoryVisibilityPrivate:
+		return nil
+	default:
+		return fmt.Errorf("Expected %s to be one of '%s' or '%s', but was '%s'",
+			field, RepositoryVisibilityPublic, RepositoryVisibilityPrivate, visibility)
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+)
+
+func TestImageDestinationPrePushHookValidate(t *testing.T) {
+	assert.NoError(t, ctlconf.ImageDestinationPrePushHook{Command: []string{"./create-repo.sh"}}.Validate())
+	assert.NoError(t, ctlconf.ImageDestinationPrePushHook{
+		Harbor: &ctlconf.ImageDestinationHarbor{Username: "admin", Password: "${HARBOR_PASSWORD}", Visibility: "public"},
+	}.Validate())
+
+	err := ctlconf.ImageDestinationPrePushHook{}.Validate()
+	assert.EqualError(t, err, "Expected exactly one of Command, Harbor or Quay to be specified, but 0 were")
+
+	err = ctlconf.ImageDestinationPrePushHook{
+		Command: []string{"./create-repo.sh"},
+		Quay:    &ctlconf.ImageDestinationQuay{Token: "${QUAY_TOKEN}"},
+	}.Validate()
+	assert.EqualError(t, err, "Expected exactly one of Command, Harbor or Quay to be specified, but 2 were")
+
+	err = ctlconf.ImageDestinationPrePushHook{Harbor: &ctlconf.ImageDestinationHarbor{Username: "admin"}}.Validate()
+	assert.EqualError(t, err, "Expected Harbor.Username and Harbor.Password to be specified together")
+
+	err = ctlconf.ImageDestinationPrePushHook{Quay: &ctlconf.ImageDestinationQuay{}}.Validate()
+	assert.EqualError(t, err, "Expected Quay.Token to be non-empty")
+
+	err = ctlconf.ImageDestinationPrePushHook{Quay: &ctlconf.ImageDestinationQuay{Token: "t", Visibility: "internal"}}.Validate()
+	assert.EqualError(t, err, "Expected Quay.Visibility to be one of 'public' or 'private', but was 'internal'")
+}
@@ -0,0 +1,35 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Promotion copies every resolved image pulled from external registry
+// into internal registry and references the copy in the output
+// (images matching destinations are copied according to destinations)
+type Promotion struct {
+	// Repository is a prefix of internal repositories (e.g. registry.corp/mirror);
+	// repository path of each image is appended to it (e.g. registry.corp/mirror/library/nginx)
+	Repository string `json:"repository"`
+	// InternalRegistries lists registry hosts images of which are not copied
+	// (registry of Repository is always considered internal)
+	InternalRegistries []string `json:"internalRegistries,omitempty"`
+	// Auth provides credentials used when writing to Repository
+	Auth *ImageDestinationAuth `json:"auth,omitempty"`
+}
+
+func (d Promotion) Validate() error {
+	if len(d.Repository) == 0 {
+		return fmt.Errorf("Expected Repository to be non-empty")
+	}
+	for i, registry := range d.InternalRegistries {
+		if len(registry) == 0 || strings.Contains(registry, "/") {
+			return fmt.Errorf("Expected InternalRegistries[%d] to be a host without repository path, but was '%s'", i, registry)
+		}
+	}
+	return nil
+}
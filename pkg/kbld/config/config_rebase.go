@@ -0,0 +1,42 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ImageRebase replaces base image layers of images (similar to crane rebase)
+// so that images pick up patched base without being rebuilt
+type ImageRebase struct {
+	// ImageRef limits rebase to matching images. When not specified
+	// all images based on OldBase are rebased (others are left as is).
+	ImageRef
+	// OldBase is digest reference of base image that images were built on
+	OldBase string `json:"oldBase"`
+	// NewBase is reference of base image to rebase onto
+	NewBase string `json:"newBase"`
+	// NewImage is repository rebased images are pushed to
+	// (defaults to repository of each image)
+	NewImage string `json:"newImage,omitempty"`
+}
+
+func (d ImageRebase) Validate() error {
+	if len(d.OldBase) == 0 {
+		return fmt.Errorf("Expected OldBase to be non-empty")
+	}
+	if !strings.Contains(d.OldBase, "@") {
+		return fmt.Errorf("Expected OldBase '%s' to be a digest reference", d.OldBase)
+	}
+	if len(d.NewBase) == 0 {
+		return fmt.Errorf("Expected NewBase to be non-empty")
+	}
+	return nil
+}
+
+// MatchesAll indicates that rebase is not limited to specific images
+func (d ImageRebase) MatchesAll() bool {
+	return len(d.Image) == 0 && len(d.ImageRepo) == 0
+}
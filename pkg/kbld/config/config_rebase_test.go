@@ -0,0 +1,29 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+)
+
+func TestImageRebaseValidate(t *testing.T) {
+	const oldBase = "base@sha256:0000000000000000000000000000000000000000000000000000000000000001"
+
+	valid := ctlconf.ImageRebase{OldBase: oldBase, NewBase: "base:patched"}
+	assert.NoError(t, valid.Validate())
+	assert.True(t, valid.MatchesAll())
+
+	limited := ctlconf.ImageRebase{ImageRef: ctlconf.ImageRef{ImageRepo: "app"}, OldBase: oldBase, NewBase: "base:patched"}
+	assert.NoError(t, limited.Validate())
+	assert.False(t, limited.MatchesAll())
+
+	err := ctlconf.ImageRebase{OldBase: "base:1.0", NewBase: "base:patched"}.Validate()
+	assert.EqualError(t, err, "Expected OldBase 'base:1.0' to be a digest reference")
+
+	err = ctlconf.ImageRebase{OldBase: oldBase}.Validate()
+	assert.EqualError(t, err, "Expected NewBase to be non-empty")
+}
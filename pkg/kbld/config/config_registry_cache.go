@@ -0,0 +1,25 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+const (
+	defaultRegistryCacheTagPrefix = "kbld-cache-"
+)
+
+// SourceRegistryCacheOpts skips building and pushing of images when
+// destination already has image tagged with content hash of the source
+// (registry acts as build cache shared between machines).
+// Content hash covers files under Path (except .git) and source
+// configuration; base images referenced by tag are not resolved for it.
+type SourceRegistryCacheOpts struct {
+	// TagPrefix is prepended to content hash (defaults to kbld-cache-)
+	TagPrefix string `json:"tagPrefix,omitempty"`
+}
+
+func (d SourceRegistryCacheOpts) TagPrefixWithDefaults() string {
+	if len(d.TagPrefix) == 0 {
+		return defaultRegistryCacheTagPrefix
+	}
+	return d.TagPrefix
+}
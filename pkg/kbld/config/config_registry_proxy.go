@@ -0,0 +1,67 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// RegistryProxy configures how registry is reached regardless
+// of proxy environment variables (HTTP_PROXY, NO_PROXY, etc.)
+type RegistryProxy struct {
+	// Registry is registry host (e.g. docker.io, registry.example.com:5000)
+	// optionally starting with '*.' to match all subdomains
+	Registry string `json:"registry"`
+	// Proxy is URL of HTTP, HTTPS or SOCKS5 proxy
+	Proxy string `json:"proxy,omitempty"`
+	// Direct connects to registry without a proxy
+	Direct bool `json:"direct,omitempty"`
+}
+
+func (d RegistryProxy) Validate() error {
+	if len(d.Registry) == 0 {
+		return fmt.Errorf("Expected Registry to be non-empty")
+	}
+	if strings.Contains(d.Registry, "/") {
+		return fmt.Errorf("Expected Registry to be a host without repository path, but was '%s'", d.Registry)
+	}
+
+	switch {
+	case len(d.Proxy) > 0 && d.Direct:
+		return fmt.Errorf("Expected only one of Proxy or Direct to be specified")
+	case len(d.Proxy) == 0 && !d.Direct:
+		return fmt.Errorf("Expected Proxy or Direct to be specified")
+	case d.Direct:
+		return nil
+	}
+
+	_, err := d.ProxyURL()
+	return err
+}
+
+// ProxyURL returns parsed proxy URL (nil when connecting directly)
+func (d RegistryProxy) ProxyURL() (*url.URL, error) {
+	if d.Direct {
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(d.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("Parsing Proxy: %s", err)
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("Expected Proxy scheme to be one of http, https, socks5 or socks5h, but was '%s'", proxyURL.Scheme)
+	}
+
+	if len(proxyURL.Host) == 0 {
+		return nil, fmt.Errorf("Expected Proxy '%s' to include host", d.Proxy)
+	}
+
+	return proxyURL, nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+func TestConfigRegistryProxies(t *testing.T) {
+	rs, err := ctlres.NewResourcesFromBytes([]byte(`
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+registryProxies:
+- registry: docker.io
+  proxy: socks5://proxy.example.com:1080
+- registry: "*.internal.example.com"
+  direct: true
+`))
+	require.NoError(t, err)
+
+	_, conf, err := ctlconf.NewConfFromResources(rs)
+	require.NoError(t, err)
+
+	proxies := conf.RegistryProxies()
+	require.Len(t, proxies, 2)
+
+	proxyURL, err := proxies[0].ProxyURL()
+	require.NoError(t, err)
+	assert.Equal(t, "socks5://proxy.example.com:1080", proxyURL.String())
+
+	proxyURL, err = proxies[1].ProxyURL()
+	require.NoError(t, err)
+	assert.Nil(t, proxyURL)
+
+	rs, err = ctlres.NewResourcesFromBytes([]byte(`
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+registryProxies:
+- registry: docker.io
+  proxy: ftp://proxy.example.com
+`))
+	require.NoError(t, err)
+
+	_, _, err = ctlconf.NewConfFromResources(rs)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Validating RegistryProxies[0]: Expected Proxy scheme to be one of http, https, socks5 or socks5h, but was 'ftp'")
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	ScannerTrivy = "trivy"
+	ScannerGrype = "grype"
+)
+
+// VulnerabilitySeverities are ordered from least to most severe
+var VulnerabilitySeverities = []string{"UNKNOWN", "LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
+// VulnerabilityScan configures scanning of resolved and built images
+type VulnerabilityScan struct {
+	// Scanner is either trivy (default) or grype
+	Scanner string `json:"scanner,omitempty"`
+	// Command is used to run scanner instead of default invocation.
+	// It receives KBLD_SCAN_IMAGE and KBLD_SCAN_OUTPUT env variables
+	// and is expected to write scanner's JSON report to KBLD_SCAN_OUTPUT.
+	Command []string `json:"command,omitempty"`
+	// FailOn is a minimum severity that fails the run (e.g. HIGH);
+	// when empty, results are only recorded
+	FailOn string `json:"failOn,omitempty"`
+	// IgnoreUnfixed skips vulnerabilities without available fix
+	IgnoreUnfixed bool `json:"ignoreUnfixed,omitempty"`
+	// ReportPath receives JSON report for all scanned images
+	ReportPath string `json:"reportPath,omitempty"`
+}
+
+func (d VulnerabilityScan) ScannerWithDefaults() string {
+	if len(d.Scanner) == 0 {
+		return ScannerTrivy
+	}
+	return d.Scanner
+}
+
+func (d VulnerabilityScan) Validate() error {
+	switch d.ScannerWithDefaults() {
+	case ScannerTrivy, ScannerGrype:
+	default:
+		return fmt.Errorf("Expected Scanner to be one of '%s' or '%s', but was '%s'", ScannerTrivy, ScannerGrype, d.Scanner)
+	}
+	if len(d.FailOn) > 0 && SeverityLevel(d.FailOn) < 0 {
+		return fmt.Errorf("Expected FailOn to be one of %s, but was '%s'",
+			strings.Join(VulnerabilitySeverities, ", "), d.FailOn)
+	}
+	return nil
+}
+
+// SeverityLevel returns position of severity in VulnerabilitySeverities (-1 if unknown)
+func SeverityLevel(severity string) int {
+	for i, sev := range VulnerabilitySeverities {
+		if strings.EqualFold(sev, severity) {
+			return i
+		}
+	}
+	return -1
+}
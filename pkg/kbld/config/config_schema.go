@@ -0,0 +1,303 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var (
+	// schemaOpenTypes allow unknown fields (e.g. origins written by newer kbld versions)
+	schemaOpenTypes = map[reflect.Type]struct{}{
+		reflect.TypeOf(Origin{}): {},
+	}
+)
+
+// ConfigSchema returns JSON schema of Config documents of current apiVersion
+// (documents of older apiVersions are validated after conversion)
+func ConfigSchema() map[string]interface{} {
+	builder := schemaBuilder{definitions: map[string]interface{}{}, names: map[reflect.Type]string{}}
+
+	schema := builder.structSchema(reflect.TypeOf(Config{}))
+
+	props := schema["properties"].(map[string]interface{})
+	props["apiVersion"] = map[string]interface{}{"type": "string", "enum": []interface{}{configAPIVersion}}
+	props["kind"] = map[string]interface{}{"type": "string", "enum": []interface{}{configKind}}
+	props["metadata"] = map[string]interface{}{"type": "object"}
+
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "kbld Config " + configAPIVersion
+	schema["required"] = []interface{}{"apiVersion", "kind"}
+	schema["definitions"] = builder.definitions
+
+	return schema
+}
+
+// schemaBuilder places struct types into definitions
+// since some of them are recursive (e.g. search rules)
+type schemaBuilder struct {
+	definitions map[string]interface{}
+	names       map[reflect.Type]string
+}
+
+func (b schemaBuilder) schemaOf(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return b.schemaOf(t.Elem())
+
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": b.schemaOf(t.Elem())}
+
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schemaOf(t.Elem())}
+
+	case reflect.Struct:
+		name, found := b.names[t]
+		if !found {
+			name = t.Name()
+			if _, taken := b.definitions[name]; taken {
+				name = path.Base(t.PkgPath()) + "." + name
+			}
+			b.names[t] = name
+			// Reserve name before visiting fields that may refer to this type
+			b.definitions[name] = map[string]interface{}{}
+			b.definitions[name] = b.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/definitions/" + name}
+
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func (b schemaBuilder) structSchema(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	b.addFields(t, props)
+
+	_, open := schemaOpenTypes[t]
+
+	return map[string]interface{}{"type": "object", "properties": props, "additionalProperties": open}
+}
+
+// addFields follows encoding/json rules: embedded structs
+// are inlined and fields without tags use their Go names (only
+// documented with lowercased first letter since matching is case insensitive)
+func (b schemaBuilder) addFields(t reflect.Type, props map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && len(name) == 0 && field.Type.Kind() == reflect.Struct {
+			b.addFields(field.Type, props)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if len(name) == 0 {
+			runes := []rune(field.Name)
+			name = string(unicode.ToLower(runes[0])) + string(runes[1:])
+		}
+
+		props[name] = b.schemaOf(field.Type)
+	}
+}
+
+// ValidateAgainstSchema returns errors for values that do not match
+// given schema (as returned by ConfigSchema) with their locations
+func ValidateAgainstSchema(val interface{}, schema map[string]interface{}) []error {
+	definitions, _ := schema["definitions"].(map[string]interface{})
+	return schemaValidator{definitions}.validate(val, schema, "")
+}
+
+type schemaValidator struct {
+	definitions map[string]interface{}
+}
+
+func (v schemaValidator) validate(val interface{}, schema map[string]interface{}, loc string) []error {
+	if val == nil {
+		// Null values are treated as unset by encoding/json
+		return nil
+	}
+
+	if ref, ok := schema["$ref"].(string); ok {
+		schema, _ = v.definitions[strings.TrimPrefix(ref, "#/definitions/")].(map[string]interface{})
+	}
+
+	expectedType, _ := schema["type"].(string)
+	if len(expectedType) == 0 {
+		return nil
+	}
+
+	if actualType := schemaTypeOf(val); actualType != expectedType &&
+		!(expectedType == "number" && actualType == "integer") {
+		return []error{fmt.Errorf("Expected '%s' to be %s, but was %s", schemaLocation(loc), expectedType, actualType)}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		var found bool
+		for _, enumVal := range enum {
+			found = found || enumVal == val
+		}
+		if !found {
+			return []error{fmt.Errorf("Expected '%s' to be one of %v, but was '%v'", schemaLocation(loc), enum, val)}
+		}
+	}
+
+	var errs []error
+
+	switch typedVal := val.(type) {
+	case []interface{}:
+		itemSchema, _ := schema["items"].(map[string]interface{})
+		for i, item := range typedVal {
+			errs = append(errs, v.validate(item, itemSchema, fmt.Sprintf("%s[%d]", loc, i))...)
+		}
+
+	case map[string]interface{}:
+		props, _ := schema["properties"].(map[string]interface{})
+		additionalProps := schema["additionalProperties"]
+
+		// Keys are sorted to report errors in stable order
+		var keys []string
+		for key := range typedVal {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			keyLoc := key
+			if len(loc) > 0 {
+				keyLoc = loc + "." + key
+			}
+
+			if propSchema, found := schemaProperty(props, key); found {
+				errs = append(errs, v.validate(typedVal[key], propSchema, keyLoc)...)
+				continue
+			}
+
+			switch typedAdditional := additionalProps.(type) {
+			case map[string]interface{}:
+				errs = append(errs, v.validate(typedVal[key], typedAdditional, keyLoc)...)
+			case bool:
+				if !typedAdditional {
+					errs = append(errs, unknownFieldErr(keyLoc, key, props))
+				}
+			}
+		}
+	}
+
+	return errs
+}
+
+// schemaProperty matches property names case insensitively (same as encoding/json)
+func schemaProperty(props map[string]interface{}, key string) (map[string]interface{}, bool) {
+	if propSchema, found := props[key]; found {
+		return propSchema.(map[string]interface{}), true
+	}
+	for name, propSchema := range props {
+		if strings.EqualFold(name, key) {
+			return propSchema.(map[string]interface{}), true
+		}
+	}
+	return nil, false
+}
+
+func unknownFieldErr(loc, key string, props map[string]interface{}) error {
+	var closest string
+	closestDist := len(key)/2 + 1
+
+	for name := range props {
+		if dist := editDistance(strings.ToLower(key), strings.ToLower(name)); dist < closestDist ||
+			(dist == closestDist && len(closest) > 0 && name < closest) {
+			closest = name
+			closestDist = dist
+		}
+	}
+
+	if len(closest) > 0 {
+		return fmt.Errorf("Unknown field '%s' (did you mean '%s'?)", loc, closest)
+	}
+	return fmt.Errorf("Unknown field '%s'", loc)
+}
+
+func schemaTypeOf(val interface{}) string {
+	switch typedVal := val.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int32, int64:
+		return "integer"
+	case float64:
+		if typedVal == math.Trunc(typedVal) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", val)
+	}
+}
+
+func schemaLocation(loc string) string {
+	if len(loc) == 0 {
+		return "(root)"
+	}
+	return loc
+}
+
+// editDistance returns Levenshtein distance between strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr := make([]int, len(b)+1)
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(minInt(prev[j]+1, curr[j-1]+1), prev[j-1]+cost)
+		}
+		prev = curr
+	}
+
+	return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
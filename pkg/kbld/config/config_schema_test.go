@@ -0,0 +1,139 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+func TestConfigSchemaValidation(t *testing.T) {
+	confFromYAML := func(t *testing.T, docs ...string) (ctlconf.Conf, error) {
+		var rs []ctlres.Resource
+		for _, doc := range docs {
+			docRs, err := ctlres.NewResourcesFromBytes([]byte(doc))
+			require.NoError(t, err)
+			rs = append(rs, docRs...)
+		}
+
+		_, conf, err := ctlconf.NewConfFromResources(rs)
+		return conf, err
+	}
+
+	t.Run("reports unknown fields and invalid types with their locations", func(t *testing.T) {
+		_, err := confFromYAML(t, `
+apiVersion: kbld.k14s.io/v1alpha2
+kind: Config
+serachRules:
+- keyMatcher:
+    name: sidecarImage
+sources:
+- image: app
+  path: src
+  docker:
+    build:
+      pull: "yes"
+      targte: app
+overrides:
+- image: nginx
+  newImage: nginx:1.17
+  colour: blue
+`)
+		require.EqualError(t, err, `Validating config/ (kbld.k14s.io/v1alpha2) cluster against schema:
+- Unknown field 'overrides[0].colour'
+- Unknown field 'serachRules' (did you mean 'searchRules'?)
+- Expected 'sources[0].docker.build.pull' to be boolean, but was string
+- Unknown field 'sources[0].docker.build.targte' (did you mean 'target'?)`)
+	})
+
+	t.Run("matches fields case insensitively and allows unknown origins", func(t *testing.T) {
+		conf, err := confFromYAML(t, `
+apiVersion: kbld.k14s.io/v1alpha2
+kind: Config
+metadata:
+  name: app
+sources:
+- image: app
+  Path: src
+overrides:
+- image: nginx
+  newImage: nginx:1.17
+  origins:
+  - fromFutureVersion:
+      url: nginx
+`)
+		require.NoError(t, err)
+		require.Len(t, conf.Sources(), 1)
+		assert.Equal(t, "src", conf.Sources()[0].Path)
+	})
+
+	t.Run("converts v1alpha1 kinds and keys", func(t *testing.T) {
+		conf, err := confFromYAML(t, `
+apiVersion: kbld.k14s.io/v1alpha1
+kind: ImageKeys
+keys:
+- sidecarImage
+searchRules:
+- keyMatcher:
+    name: initImage
+`, `
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Sources
+sources:
+- image: app
+  path: src
+`)
+		require.NoError(t, err)
+		require.Len(t, conf.Sources(), 1)
+		assert.Equal(t, []ctlconf.SearchRule{
+			{KeyMatcher: &ctlconf.SearchRuleKeyMatcher{Name: "sidecarImage"}},
+			{KeyMatcher: &ctlconf.SearchRuleKeyMatcher{Name: "initImage"}},
+		}, conf.ConfiguredSearchRules())
+	})
+
+	t.Run("rejects unknown kinds of v1alpha2", func(t *testing.T) {
+		conf, err := confFromYAML(t, `
+apiVersion: kbld.k14s.io/v1alpha2
+kind: Sources
+sources:
+- image: app
+  path: src
+`)
+		require.NoError(t, err)
+		assert.Len(t, conf.Sources(), 0)
+
+		_, err = confFromYAML(t, `
+apiVersion: kbld.k14s.io/v1alpha2
+kind: Config
+keys: [sidecarImage]
+`)
+		require.EqualError(t, err, `Validating config/ (kbld.k14s.io/v1alpha2) cluster against schema:
+- Unknown field 'keys'`)
+	})
+}
+
+func TestConfigSchema(t *testing.T) {
+	schema := ctlconf.ConfigSchema()
+
+	assert.Equal(t, "http://json-schema.org/draft-07/schema#", schema["$schema"])
+
+	definitions := schema["definitions"].(map[string]interface{})
+
+	// Recursive types are referenced
+	searchRuleJSON := definitions["SearchRuleUpdateStrategyJSON"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"type":  "array",
+		"items": map[string]interface{}{"$ref": "#/definitions/SearchRule"},
+	}, searchRuleJSON["properties"].(map[string]interface{})["searchRules"])
+
+	// Embedded and untagged fields
+	source := definitions["Source"].(map[string]interface{})["properties"].(map[string]interface{})
+	assert.Contains(t, source, "image")
+	assert.Contains(t, source, "path")
+	assert.Contains(t, source, "docker")
+}
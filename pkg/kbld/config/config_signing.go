@@ -0,0 +1,208 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Signing configures signing of pushed images (using cosign unless Notation is specified)
+type Signing struct {
+	// Key is a path to cosign private key (password is read from COSIGN_PASSWORD)
+	Key string `json:"key,omitempty"`
+	// KMS is a key URI (e.g. awskms:///alias/kbld, gcpkms://..., azurekms://..., hashivault://...).
+	// Provider credentials are taken from environment (e.g. AWS_*, GOOGLE_APPLICATION_CREDENTIALS,
+	// AZURE_*, VAULT_ADDR and VAULT_TOKEN) as cosign would.
+	KMS string `json:"kms,omitempty"`
+	// Keyless signs with OIDC identity (Fulcio certificate and Rekor entry)
+	Keyless bool `json:"keyless,omitempty"`
+	// Notation signs using notation CLI instead of cosign
+	Notation *SigningNotation `json:"notation,omitempty"`
+	// TransparencyLog records signatures in Rekor (cosign only)
+	TransparencyLog *SigningTransparencyLog `json:"transparencyLog,omitempty"`
+
+	RawOptions *[]string `json:"rawOptions"`
+}
+
+type SigningNotation struct {
+	// Key is a name of notation signing key (uses notation's default key when empty)
+	Key string `json:"key,omitempty"`
+	// KMS signs with a key held by a notation plugin (e.g. azure-kv, aws-signer)
+	KMS *SigningNotationKMS `json:"kms,omitempty"`
+}
+
+type SigningNotationKMS struct {
+	// Plugin is a name of installed notation plugin
+	Plugin string `json:"plugin"`
+	// KeyID is a plugin specific key identifier (e.g. Key Vault key URL or AWS Signer profile ARN)
+	KeyID string `json:"keyID"`
+	// PluginConfig is passed to plugin as --plugin-config key=value pairs
+	PluginConfig map[string]string `json:"pluginConfig,omitempty"`
+}
+
+// KMSKeyURIPrefixes lists key URI schemes supported by cosign
+var KMSKeyURIPrefixes = []string{"awskms://", "gcpkms://", "azurekms://", "hashivault://"}
+
+// ValidateKMSKeyURI checks that key URI uses one of supported KMS providers
+func ValidateKMSKeyURI(uri string) error {
+	for _, prefix := range KMSKeyURIPrefixes {
+		if strings.HasPrefix(uri, prefix) && len(uri) > len(prefix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Expected KMS key URI '%s' to start with one of %s",
+		uri, strings.Join(KMSKeyURIPrefixes, ", "))
+}
+
+type SigningTransparencyLog struct {
+	// URL of Rekor instance (defaults to public instance)
+	URL string `json:"url,omitempty"`
+}
+
+const (
+	DefaultTransparencyLogURL = "https://rekor.sigstore.dev"
+)
+
+func (d SigningTransparencyLog) URLWithDefaults() string {
+	if len(d.URL) == 0 {
+		return DefaultTransparencyLogURL
+	}
+	return d.URL
+}
+
+func (d Signing) Validate() error {
+	var count int
+	if len(d.Key) > 0 {
+		count++
+	}
+	if len(d.KMS) > 0 {
+		count++
+	}
+	if d.Keyless {
+		count++
+	}
+	if d.Notation != nil {
+		count++
+	}
+	if count != 1 {
+		return fmt.Errorf("Expected exactly one of Key, KMS, Keyless or Notation to be specified")
+	}
+	if d.Notation != nil && d.TransparencyLog != nil {
+		return fmt.Errorf("Expected TransparencyLog to be used only with cosign signing")
+	}
+	if len(d.KMS) > 0 {
+		err := ValidateKMSKeyURI(d.KMS)
+		if err != nil {
+			return fmt.Errorf("Validating KMS: %s", err)
+		}
+	}
+	if d.Notation != nil {
+		return d.Notation.Validate()
+	}
+	return nil
+}
+
+func (d SigningNotation) Validate() error {
+	if d.KMS == nil {
+		return nil
+	}
+	if len(d.Key) > 0 {
+		return fmt.Errorf("Expected only one of Notation.Key or Notation.KMS to be specified")
+	}
+	if len(d.KMS.Plugin) == 0 || len(d.KMS.KeyID) == 0 {
+		return fmt.Errorf("Expected Notation.KMS.Plugin and Notation.KMS.KeyID to be non-empty")
+	}
+	return nil
+}
+
+// VerificationPolicy requires matching images to be signed
+type VerificationPolicy struct {
+	// ImageRef selects images policy applies to (all images when empty)
+	ImageRef
+	Cosign   *VerificationPolicyCosign   `json:"cosign,omitempty"`
+	Notation *VerificationPolicyNotation `json:"notation,omitempty"`
+}
+
+type VerificationPolicyCosign struct {
+	// Key is a path to cosign public key
+	Key string `json:"key,omitempty"`
+	// KMS is a key URI (e.g. awskms:///alias/kbld)
+	KMS string `json:"kms,omitempty"`
+	// Keyless verifies certificate identity issued by Fulcio
+	Keyless *VerificationPolicyKeyless `json:"keyless,omitempty"`
+
+	RawOptions *[]string `json:"rawOptions"`
+}
+
+type VerificationPolicyNotation struct {
+	// TrustPolicy is a path to notation trust policy (trustpolicy.json);
+	// notation's configured trust policy is used when empty
+	TrustPolicy string `json:"trustPolicy,omitempty"`
+	// TrustStore is a path to notation trust store directory
+	// (x509/{ca,signingAuthority}/<name>/*.crt); used with TrustPolicy
+	TrustStore string `json:"trustStore,omitempty"`
+
+	RawOptions *[]string `json:"rawOptions"`
+}
+
+type VerificationPolicyKeyless struct {
+	Identity       string `json:"identity,omitempty"`
+	IdentityRegexp string `json:"identityRegexp,omitempty"`
+	Issuer         string `json:"issuer,omitempty"`
+	IssuerRegexp   string `json:"issuerRegexp,omitempty"`
+}
+
+func (d VerificationPolicy) Validate() error {
+	if len(d.Image) > 0 && len(d.ImageRepo) > 0 {
+		return fmt.Errorf("Expected only one of Image or ImageRepo to be specified")
+	}
+	switch {
+	case d.Cosign != nil && d.Notation != nil:
+		return fmt.Errorf("Expected only one of Cosign or Notation to be specified")
+	case d.Cosign != nil:
+		return d.Cosign.Validate()
+	case d.Notation != nil:
+		if len(d.Notation.TrustStore) > 0 && len(d.Notation.TrustPolicy) == 0 {
+			return fmt.Errorf("Expected Notation.TrustPolicy to be specified with Notation.TrustStore")
+		}
+		return nil
+	default:
+		return fmt.Errorf("Expected Cosign or Notation to be specified")
+	}
+}
+
+func (d VerificationPolicyCosign) Validate() error {
+	var count int
+	if len(d.Key) > 0 {
+		count++
+	}
+	if len(d.KMS) > 0 {
+		count++
+	}
+	if d.Keyless != nil {
+		count++
+		if len(d.Keyless.Identity) == 0 && len(d.Keyless.IdentityRegexp) == 0 {
+			return fmt.Errorf("Expected Cosign.Keyless.Identity or Cosign.Keyless.IdentityRegexp to be non-empty")
+		}
+		if len(d.Keyless.Issuer) == 0 && len(d.Keyless.IssuerRegexp) == 0 {
+			return fmt.Errorf("Expected Cosign.Keyless.Issuer or Cosign.Keyless.IssuerRegexp to be non-empty")
+		}
+	}
+	if count != 1 {
+		return fmt.Errorf("Expected exactly one of Cosign.Key, Cosign.KMS or Cosign.Keyless to be specified")
+	}
+	if len(d.KMS) > 0 {
+		err := ValidateKMSKeyURI(d.KMS)
+		if err != nil {
+			return fmt.Errorf("Validating Cosign.KMS: %s", err)
+		}
+	}
+	return nil
+}
+
+// AppliesToAll returns true when policy does not select specific images
+func (d VerificationPolicy) AppliesToAll() bool {
+	return len(d.Image) == 0 && len(d.ImageRepo) == 0
+}
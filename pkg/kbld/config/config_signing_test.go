@@ -0,0 +1,129 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+func TestValidateKMSKeyURI(t *testing.T) {
+	for _, uri := range []string{
+		"awskms:///alias/kbld",
+		"awskms://localhost:4566/arn:aws:kms:us-east-1:123456789012:alias/kbld",
+		"gcpkms://projects/corp/locations/global/keyRings/kbld/cryptoKeys/signing",
+		"azurekms://corp.vault.azure.net/kbld",
+		"hashivault://kbld",
+	} {
+		assert.NoError(t, ctlconf.ValidateKMSKeyURI(uri), uri)
+	}
+
+	for _, uri := range []string{"", "awskms://", "cosign.key", "file:///tmp/cosign.key", "AWSKMS:///alias/kbld"} {
+		err := ctlconf.ValidateKMSKeyURI(uri)
+		require.Error(t, err, uri)
+		assert.Equal(t, "Expected KMS key URI '"+uri+"' to start with one of awskms://, gcpkms://, azurekms://, hashivault://", err.Error())
+	}
+}
+
+func TestConfigSigningKMS(t *testing.T) {
+	newConf := func(t *testing.T, conf string) (ctlconf.Conf, error) {
+		rs, err := ctlres.NewResourcesFromBytes([]byte("apiVersion: kbld.k14s.io/v1alpha1\nkind: Config\n" + conf))
+		require.NoError(t, err)
+
+		_, parsedConf, err := ctlconf.NewConfFromResources(rs)
+		return parsedConf, err
+	}
+
+	t.Run("accepts cosign and notation KMS keys", func(t *testing.T) {
+		conf, err := newConf(t, `
+signing:
+  kms: awskms:///alias/kbld
+verificationPolicies:
+- cosign:
+    kms: gcpkms://projects/corp/locations/global/keyRings/kbld/cryptoKeys/signing
+`)
+		require.NoError(t, err)
+		assert.Equal(t, "awskms:///alias/kbld", conf.Signing().KMS)
+		assert.Equal(t, "gcpkms://projects/corp/locations/global/keyRings/kbld/cryptoKeys/signing",
+			conf.VerificationPolicies()[0].Cosign.KMS)
+
+		conf, err = newConf(t, `
+signing:
+  notation:
+    kms:
+      plugin: azure-kv
+      keyID: https://corp.vault.azure.net/keys/kbld/1
+      pluginConfig:
+        self_signed: "true"
+`)
+		require.NoError(t, err)
+		assert.Equal(t, &ctlconf.SigningNotationKMS{
+			Plugin:       "azure-kv",
+			KeyID:        "https://corp.vault.azure.net/keys/kbld/1",
+			PluginConfig: map[string]string{"self_signed": "true"},
+		}, conf.Signing().Notation.KMS)
+	})
+
+	t.Run("rejects unsupported key URIs", func(t *testing.T) {
+		_, err := newConf(t, `
+signing:
+  kms: vault:///kbld
+`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Validating Signing: Validating KMS: Expected KMS key URI 'vault:///kbld' to start with one of")
+
+		_, err = newConf(t, `
+verificationPolicies:
+- cosign:
+    kms: cosign.pub
+`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Validating VerificationPolicies[0]: Validating Cosign.KMS: Expected KMS key URI 'cosign.pub'")
+	})
+
+	t.Run("rejects KMS together with key", func(t *testing.T) {
+		_, err := newConf(t, `
+signing:
+  key: cosign.key
+  kms: awskms:///alias/kbld
+`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Expected exactly one of Key, KMS, Keyless or Notation to be specified")
+
+		_, err = newConf(t, `
+verificationPolicies:
+- cosign:
+    key: cosign.pub
+    kms: awskms:///alias/kbld
+`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Expected exactly one of Cosign.Key, Cosign.KMS or Cosign.Keyless to be specified")
+
+		_, err = newConf(t, `
+signing:
+  notation:
+    key: release
+    kms:
+      plugin: azure-kv
+      keyID: https://corp.vault.azure.net/keys/kbld/1
+`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Expected only one of Notation.Key or Notation.KMS to be specified")
+	})
+
+	t.Run("requires notation plugin and key ID", func(t *testing.T) {
+		_, err := newConf(t, `
+signing:
+  notation:
+    kms:
+      plugin: azure-kv
+`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Expected Notation.KMS.Plugin and Notation.KMS.KeyID to be non-empty")
+	})
+}
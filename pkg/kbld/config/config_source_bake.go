@@ -0,0 +1,68 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+)
+
+func (d Source) hasBakeTargets() bool {
+	return d.Docker != nil && d.Docker.Bake != nil && len(d.Docker.Bake.Targets) > 0
+}
+
+func (d Source) validateBake() error {
+	if d.Docker == nil || d.Docker.Bake == nil {
+		return nil
+	}
+
+	bake := d.Docker.Bake
+
+	switch {
+	case d.Docker.Buildx != nil:
+		return fmt.Errorf("Expected only one of Docker.Buildx or Docker.Bake to be specified")
+	case d.BaseImages != nil:
+		return fmt.Errorf("Expected BaseImages to not be used with Docker.Bake")
+	case len(bake.Target) > 0 && len(bake.Targets) > 0:
+		return fmt.Errorf("Expected only one of Docker.Bake.Target or Docker.Bake.Targets to be specified")
+	case len(bake.Target) == 0 && len(bake.Targets) == 0:
+		return fmt.Errorf("Expected Docker.Bake.Target or Docker.Bake.Targets to be non-empty")
+	case len(bake.Targets) > 0 && (len(d.Image) > 0 || len(d.ImageRepo) > 0 || d.HasImagePattern()):
+		return fmt.Errorf("Expected images to be specified via Docker.Bake.Targets only")
+	}
+
+	for i, target := range bake.Targets {
+		if len(target.Target) == 0 {
+			return fmt.Errorf("Expected Docker.Bake.Targets[%d].Target to be non-empty", i)
+		}
+	}
+
+	return nil
+}
+
+// expandBakeTargets returns a source per bake target
+// (sources without targets are returned as is)
+func (d Source) expandBakeTargets() []Source {
+	if !d.hasBakeTargets() {
+		return []Source{d}
+	}
+
+	var result []Source
+
+	for _, target := range d.Docker.Bake.Targets {
+		bakeOpts := *d.Docker.Bake
+		bakeOpts.Target = target.Target
+		bakeOpts.Targets = nil
+
+		dockerOpts := *d.Docker
+		dockerOpts.Bake = &bakeOpts
+
+		targetSrc := d
+		targetSrc.ImageRef = ImageRef{Image: target.ImageWithDefaults()}
+		targetSrc.Docker = &dockerOpts
+
+		result = append(result, targetSrc)
+	}
+
+	return result
+}
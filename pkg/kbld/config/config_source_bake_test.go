@@ -0,0 +1,93 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+func TestSourcesWithBakeTargets(t *testing.T) {
+	rs, err := ctlres.NewResourcesFromBytes([]byte(`
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+sources:
+- path: src
+  docker:
+    bake:
+      files: [docker-bake.hcl]
+      set: ["*.platform=linux/amd64"]
+      targets:
+      - target: api
+      - target: worker
+        image: registry.corp/worker
+`))
+	require.NoError(t, err)
+
+	_, conf, err := ctlconf.NewConfFromResources(rs)
+	require.NoError(t, err)
+
+	srcs := conf.Sources()
+	require.Len(t, srcs, 2)
+
+	assert.Equal(t, "api", srcs[0].Image)
+	assert.Equal(t, "src", srcs[0].Path)
+	assert.Equal(t, "api", srcs[0].Docker.Bake.Target)
+	assert.Nil(t, srcs[0].Docker.Bake.Targets)
+	assert.Equal(t, []string{"docker-bake.hcl"}, srcs[0].Docker.Bake.Files)
+	assert.Equal(t, []string{"*.platform=linux/amd64"}, srcs[0].Docker.Bake.Set)
+
+	assert.Equal(t, "registry.corp/worker", srcs[1].Image)
+	assert.Equal(t, "worker", srcs[1].Docker.Bake.Target)
+}
+
+func TestSourcesWithInvalidBake(t *testing.T) {
+	cases := map[string]string{
+		`
+- image: api
+  path: src
+  docker:
+    bake: {}
+`: "Expected Docker.Bake.Target or Docker.Bake.Targets to be non-empty",
+		`
+- image: api
+  path: src
+  docker:
+    bake:
+      targets:
+      - target: api
+`: "Expected images to be specified via Docker.Bake.Targets only",
+		`
+- image: api
+  path: src
+  docker:
+    buildx: {}
+    bake:
+      target: api
+`: "Expected only one of Docker.Buildx or Docker.Bake to be specified",
+		`
+- path: src
+  docker:
+    bake:
+      targets:
+      - image: api
+`: "Expected Docker.Bake.Targets[0].Target to be non-empty",
+	}
+
+	for srcs, expectedErr := range cases {
+		rs, err := ctlres.NewResourcesFromBytes([]byte(`
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+sources:` + srcs))
+		require.NoError(t, err)
+
+		_, _, err = ctlconf.NewConfFromResources(rs)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), expectedErr)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// SourceDefaults are applied to all sources within the same config.
+// Options set by source take precedence (raw options are appended to defaults).
+type SourceDefaults struct {
+	Docker          *SourceDockerOpts          `json:"docker,omitempty"`
+	Pack            *SourcePackOpts            `json:"pack,omitempty"`
+	KubectlBuildkit *SourceKubectlBuildkitOpts `json:"kubectlBuildkit,omitempty"`
+	Ko              *SourceKoOpts              `json:"ko,omitempty"`
+	Bazel           *SourceBazelOpts           `json:"bazel,omitempty"`
+	CloudBuild      *SourceCloudBuildOpts      `json:"cloudBuild,omitempty"`
+	CodeBuild       *SourceCodeBuildOpts       `json:"codeBuild,omitempty"`
+	Depot           *SourceDepotOpts           `json:"depot,omitempty"`
+	Archive         *SourceArchiveOpts         `json:"archive,omitempty"`
+	Autodetect      *SourceAutodetectOpts      `json:"autodetect,omitempty"`
+
+	Provenance *SourceProvenanceOpts `json:"provenance,omitempty"`
+	SBOM       *SourceSBOMOpts       `json:"sbom,omitempty"`
+	BaseImages *SourceBaseImagesOpts `json:"baseImages,omitempty"`
+
+	RegistryCache *SourceRegistryCacheOpts `json:"registryCache,omitempty"`
+
+	Env     *SourceEnvOpts   `json:"env,omitempty"`
+	Timeout string           `json:"timeout,omitempty"`
+	Retry   *SourceRetryOpts `json:"retry,omitempty"`
+}
+
+func (d Source) hasBuilder() bool {
+	return d.Docker != nil || d.Pack != nil || d.KubectlBuildkit != nil || d.Ko != nil || d.Bazel != nil ||
+		d.CloudBuild != nil || d.CodeBuild != nil || d.Depot != nil || d.Archive != nil || d.Autodetect != nil
+}
+
+func (d Source) withDefaults(defaults *SourceDefaults) Source {
+	if defaults == nil {
+		return d
+	}
+
+	// Only fill in options of the builder that source uses
+	if !d.hasBuilder() {
+		d.Docker = defaults.Docker
+		d.Pack = defaults.Pack
+		d.KubectlBuildkit = defaults.KubectlBuildkit
+		d.Ko = defaults.Ko
+		d.Bazel = defaults.Bazel
+		d.CloudBuild = defaults.CloudBuild
+		d.CodeBuild = defaults.CodeBuild
+		d.Depot = defaults.Depot
+		d.Archive = defaults.Archive
+		d.Autodetect = defaults.Autodetect
+	} else {
+		mergeDefaults(reflect.ValueOf(&d.Docker).Elem(), reflect.ValueOf(defaults.Docker), false)
+		mergeDefaults(reflect.ValueOf(&d.Pack).Elem(), reflect.ValueOf(defaults.Pack), false)
+		mergeDefaults(reflect.ValueOf(&d.KubectlBuildkit).Elem(), reflect.ValueOf(defaults.KubectlBuildkit), false)
+		mergeDefaults(reflect.ValueOf(&d.Ko).Elem(), reflect.ValueOf(defaults.Ko), false)
+		mergeDefaults(reflect.ValueOf(&d.Bazel).Elem(), reflect.ValueOf(defaults.Bazel), false)
+		mergeDefaults(reflect.ValueOf(&d.CloudBuild).Elem(), reflect.ValueOf(defaults.CloudBuild), false)
+		mergeDefaults(reflect.ValueOf(&d.CodeBuild).Elem(), reflect.ValueOf(defaults.CodeBuild), false)
+		mergeDefaults(reflect.ValueOf(&d.Depot).Elem(), reflect.ValueOf(defaults.Depot), false)
+		mergeDefaults(reflect.ValueOf(&d.Archive).Elem(), reflect.ValueOf(defaults.Archive), false)
+		mergeDefaults(reflect.ValueOf(&d.Autodetect).Elem(), reflect.ValueOf(defaults.Autodetect), false)
+	}
+
+	mergeDefaults(reflect.ValueOf(&d.Provenance).Elem(), reflect.ValueOf(defaults.Provenance), true)
+	mergeDefaults(reflect.ValueOf(&d.SBOM).Elem(), reflect.ValueOf(defaults.SBOM), true)
+	mergeDefaults(reflect.ValueOf(&d.BaseImages).Elem(), reflect.ValueOf(defaults.BaseImages), true)
+	mergeDefaults(reflect.ValueOf(&d.RegistryCache).Elem(), reflect.ValueOf(defaults.RegistryCache), true)
+	mergeDefaults(reflect.ValueOf(&d.Env).Elem(), reflect.ValueOf(defaults.Env), true)
+	mergeDefaults(reflect.ValueOf(&d.Retry).Elem(), reflect.ValueOf(defaults.Retry), true)
+
+	if len(d.Timeout) == 0 {
+		d.Timeout = defaults.Timeout
+	}
+
+	return d
+}
+
+// mergeDefaults fills unset pointer fields of val from defVal.
+// Values are copied so that defaults are never modified.
+func mergeDefaults(val, defVal reflect.Value, setWhenNil bool) {
+	switch val.Kind() {
+	case reflect.Ptr:
+		if defVal.IsNil() {
+			return
+		}
+		if val.IsNil() {
+			if setWhenNil {
+				val.Set(defVal)
+			}
+			return
+		}
+
+		if val.Elem().Kind() == reflect.Slice {
+			// Raw options (e.g. --build-arg) are appended to default ones
+			merged := reflect.AppendSlice(reflect.MakeSlice(val.Elem().Type(), 0, 0), defVal.Elem())
+			merged = reflect.AppendSlice(merged, val.Elem())
+			mergedPtr := reflect.New(val.Elem().Type())
+			mergedPtr.Elem().Set(merged)
+			val.Set(mergedPtr)
+			return
+		}
+
+		if val.Elem().Kind() == reflect.Struct {
+			copied := reflect.New(val.Elem().Type())
+			copied.Elem().Set(val.Elem())
+			mergeDefaults(copied.Elem(), defVal.Elem(), true)
+			val.Set(copied)
+		}
+
+	case reflect.Map:
+		if defVal.Len() == 0 {
+			return
+		}
+		// Keys of maps (e.g. env variables) are added unless already present
+		merged := reflect.MakeMap(val.Type())
+		for _, key := range defVal.MapKeys() {
+			merged.SetMapIndex(key, defVal.MapIndex(key))
+		}
+		for _, key := range val.MapKeys() {
+			merged.SetMapIndex(key, val.MapIndex(key))
+		}
+		val.Set(merged)
+
+	case reflect.Struct:
+		for i := 0; i < val.NumField(); i++ {
+			if val.Type().Field(i).IsExported() {
+				mergeDefaults(val.Field(i), defVal.Field(i), true)
+			}
+		}
+	}
+}
+
+// HasImagePattern indicates that source matches images by regexp or glob
+func (d Source) HasImagePattern() bool {
+	return len(d.ImageRegexp) > 0 || len(d.ImageGlob) > 0
+}
+
+// MatchesImagePattern matches image against regexp (entire image has to match) or glob
+func (d Source) MatchesImagePattern(url string) bool {
+	switch {
+	case len(d.ImageRegexp) > 0:
+		return d.imageRegexp().MatchString(url)
+	case len(d.ImageGlob) > 0:
+		matched, _ := path.Match(d.ImageGlob, url)
+		return matched
+	default:
+		return false
+	}
+}
+
+// ForImage returns source with path expanded for matched image
+// (e.g. services/${name} with (?P<name>.+) regexp group)
+func (d Source) ForImage(url string) Source {
+	if len(d.ImageRegexp) == 0 || !strings.Contains(d.Path, "$") {
+		return d
+	}
+
+	re := d.imageRegexp()
+	submatches := re.FindStringSubmatchIndex(url)
+	if submatches == nil {
+		return d
+	}
+
+	d.Path = string(re.ExpandString(nil, d.Path, url, submatches))
+	return d
+}
+
+func (d Source) imageRegexp() *regexp.Regexp {
+	// Validated when config is loaded
+	return regexp.MustCompile(`\A(?:` + d.ImageRegexp + `)\z`)
+}
+
+func (d Source) validateImagePattern() error {
+	if len(d.Image) > 0 || len(d.ImageRepo) > 0 {
+		return fmt.Errorf("Expected ImageRegexp or ImageGlob to not be specified together with Image or ImageRepo")
+	}
+	if len(d.ImageRegexp) > 0 && len(d.ImageGlob) > 0 {
+		return fmt.Errorf("Expected only one of ImageRegexp or ImageGlob to be specified")
+	}
+	if len(d.ImageRegexp) > 0 {
+		_, err := regexp.Compile(d.ImageRegexp)
+		if err != nil {
+			return fmt.Errorf("Parsing ImageRegexp: %s", err)
+		}
+	}
+	if len(d.ImageGlob) > 0 {
+		_, err := path.Match(d.ImageGlob, "")
+		if err != nil {
+			return fmt.Errorf("Parsing ImageGlob: %s", err)
+		}
+	}
+	return nil
+}
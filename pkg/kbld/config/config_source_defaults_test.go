@@ -0,0 +1,121 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+func TestSourcesWithPatternsAndDefaults(t *testing.T) {
+	rs, err := ctlres.NewResourcesFromBytes([]byte(`
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+sourceDefaults:
+  docker:
+    buildx:
+      pull: true
+      rawOptions: ["--build-arg", "GO_VERSION=1.21"]
+sources:
+- imageRegexp: "svc-(?P<name>[a-z]+)"
+  path: services/${name}
+- imageGlob: "tools/*"
+  path: tools
+  docker:
+    buildx:
+      pull: false
+      rawOptions: ["--build-arg", "TOOL=1"]
+- image: app
+  path: .
+  pack:
+    build:
+      builder: paketo
+`))
+	require.NoError(t, err)
+
+	_, conf, err := ctlconf.NewConfFromResources(rs)
+	require.NoError(t, err)
+
+	srcs := conf.Sources()
+	require.Len(t, srcs, 3)
+
+	assert.True(t, srcs[0].MatchesImagePattern("svc-api"))
+	assert.False(t, srcs[0].MatchesImagePattern("svc-api:v1"))
+	assert.Equal(t, "services/api", srcs[0].ForImage("svc-api").Path)
+	assert.Equal(t, true, *srcs[0].Docker.Buildx.Pull)
+	assert.Equal(t, []string{"--build-arg", "GO_VERSION=1.21"}, *srcs[0].Docker.Buildx.RawOptions)
+
+	assert.True(t, srcs[1].MatchesImagePattern("tools/lint"))
+	assert.Equal(t, false, *srcs[1].Docker.Buildx.Pull)
+	assert.Equal(t, []string{"--build-arg", "GO_VERSION=1.21", "--build-arg", "TOOL=1"}, *srcs[1].Docker.Buildx.RawOptions)
+
+	// Source with different builder does not inherit docker defaults
+	assert.Nil(t, srcs[2].Docker)
+	assert.Equal(t, "paketo", *srcs[2].Pack.Build.Builder)
+}
+
+func TestSourcesWithInvalidPattern(t *testing.T) {
+	rs, err := ctlres.NewResourcesFromBytes([]byte(`
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+sources:
+- image: app
+  imageGlob: "app*"
+  path: .
+`))
+	require.NoError(t, err)
+
+	_, _, err = ctlconf.NewConfFromResources(rs)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Expected ImageRegexp or ImageGlob to not be specified together with Image or ImageRepo")
+}
+
+func TestSourcesWithMatrix(t *testing.T) {
+	rs, err := ctlres.NewResourcesFromBytes([]byte(`
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+sources:
+- image: app
+  path: src
+  docker:
+    buildx:
+      rawOptions: ["--build-arg", "BASE=distroless"]
+  matrix:
+  - imageSuffix: -debug
+    docker:
+      buildx:
+        target: debug
+  - image: app-fips
+    docker:
+      buildx:
+        rawOptions: ["--build-arg", "FIPS=1"]
+`))
+	require.NoError(t, err)
+
+	_, conf, err := ctlconf.NewConfFromResources(rs)
+	require.NoError(t, err)
+
+	srcs := conf.Sources()
+	require.Len(t, srcs, 3)
+
+	assert.Equal(t, "app", srcs[0].Image)
+	assert.Nil(t, srcs[0].Matrix)
+
+	assert.Equal(t, "app-debug", srcs[1].Image)
+	assert.Equal(t, "src", srcs[1].Path)
+	assert.Equal(t, "debug", *srcs[1].Docker.Buildx.Target)
+	assert.Equal(t, []string{"--build-arg", "BASE=distroless"}, *srcs[1].Docker.Buildx.RawOptions)
+
+	assert.Equal(t, "app-fips", srcs[2].Image)
+	assert.Nil(t, srcs[2].Docker.Buildx.Target)
+	assert.Equal(t, []string{"--build-arg", "BASE=distroless", "--build-arg", "FIPS=1"}, *srcs[2].Docker.Buildx.RawOptions)
+
+	// Source is not modified by variants
+	assert.Nil(t, srcs[0].Docker.Buildx.Target)
+	assert.Equal(t, []string{"--build-arg", "BASE=distroless"}, *srcs[0].Docker.Buildx.RawOptions)
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// SourceEnvOpts controls environment variables given to builder commands
+// (e.g. docker, pack). Without it builders inherit whole host environment.
+type SourceEnvOpts struct {
+	// Allow lists names or glob patterns (e.g. GOPROXY, NPM_*) of host
+	// variables passed to builders (all are passed when not specified;
+	// PATH and HOME are always passed since builder tools rely on them)
+	Allow *[]string `json:"allow,omitempty"`
+	// Deny lists names or glob patterns of host variables that
+	// are never passed to builders (takes precedence over Allow)
+	Deny *[]string `json:"deny,omitempty"`
+	// Set explicitly sets variables regardless of Allow and Deny
+	Set map[string]string `json:"set,omitempty"`
+}
+
+func (d SourceEnvOpts) Validate() error {
+	if d.Allow != nil {
+		for i, pattern := range *d.Allow {
+			if err := d.validatePattern(pattern); err != nil {
+				return fmt.Errorf("Validating Env.Allow[%d]: %s", i, err)
+			}
+		}
+	}
+	if d.Deny != nil {
+		for i, pattern := range *d.Deny {
+			if err := d.validatePattern(pattern); err != nil {
+				return fmt.Errorf("Validating Env.Deny[%d]: %s", i, err)
+			}
+		}
+	}
+	for name := range d.Set {
+		if len(name) == 0 || strings.Contains(name, "=") {
+			return fmt.Errorf("Expected Env.Set variable name '%s' to be non-empty and not contain '='", name)
+		}
+	}
+	return nil
+}
+
+func (d SourceEnvOpts) validatePattern(pattern string) error {
+	if len(pattern) == 0 {
+		return fmt.Errorf("Expected pattern to be non-empty")
+	}
+	_, err := path.Match(pattern, "")
+	if err != nil {
+		return fmt.Errorf("Expected pattern '%s' to be valid: %s", pattern, err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+)
+
+// SourceVariant produces additional image from the same source
+// (e.g. app-debug, app-fips) with its own builder options.
+// Builder options are merged with options of the source.
+type SourceVariant struct {
+	// Image built by this variant (alternatively ImageSuffix is appended to source Image)
+	Image       string `json:"image,omitempty"`
+	ImageSuffix string `json:"imageSuffix,omitempty"`
+
+	Docker          *SourceDockerOpts          `json:"docker,omitempty"`
+	Pack            *SourcePackOpts            `json:"pack,omitempty"`
+	KubectlBuildkit *SourceKubectlBuildkitOpts `json:"kubectlBuildkit,omitempty"`
+	Ko              *SourceKoOpts              `json:"ko,omitempty"`
+	Bazel           *SourceBazelOpts           `json:"bazel,omitempty"`
+	CloudBuild      *SourceCloudBuildOpts      `json:"cloudBuild,omitempty"`
+	CodeBuild       *SourceCodeBuildOpts       `json:"codeBuild,omitempty"`
+	Depot           *SourceDepotOpts           `json:"depot,omitempty"`
+	Archive         *SourceArchiveOpts         `json:"archive,omitempty"`
+	Autodetect      *SourceAutodetectOpts      `json:"autodetect,omitempty"`
+}
+
+func (d SourceVariant) Validate(src Source) error {
+	switch {
+	case len(d.Image) > 0 && len(d.ImageSuffix) > 0:
+		return fmt.Errorf("Expected only one of Image or ImageSuffix to be specified")
+	case len(d.Image) > 0:
+		return nil
+	case len(d.ImageSuffix) > 0:
+		if len(src.Image) == 0 {
+			return fmt.Errorf("Expected source Image to be specified when ImageSuffix is used")
+		}
+		return nil
+	default:
+		return fmt.Errorf("Expected Image or ImageSuffix to be non-empty")
+	}
+}
+
+// expandMatrix returns source followed by a source for each variant
+func (d Source) expandMatrix() []Source {
+	if len(d.Matrix) == 0 {
+		return []Source{d}
+	}
+
+	srcDefaults := &SourceDefaults{
+		Docker:          d.Docker,
+		Pack:            d.Pack,
+		KubectlBuildkit: d.KubectlBuildkit,
+		Ko:              d.Ko,
+		Bazel:           d.Bazel,
+		CloudBuild:      d.CloudBuild,
+		CodeBuild:       d.CodeBuild,
+		Depot:           d.Depot,
+		Archive:         d.Archive,
+		Autodetect:      d.Autodetect,
+	}
+
+	base := d
+	base.Matrix = nil
+
+	result := []Source{base}
+
+	for _, variant := range d.Matrix {
+		variantSrc := base
+		variantSrc.ImageRef = ImageRef{Image: variant.Image}
+		variantSrc.ImageRegexp = ""
+		variantSrc.ImageGlob = ""
+		if len(variant.ImageSuffix) > 0 {
+			variantSrc.Image = d.Image + variant.ImageSuffix
+		}
+
+		variantSrc.Docker = variant.Docker
+		variantSrc.Pack = variant.Pack
+		variantSrc.KubectlBuildkit = variant.KubectlBuildkit
+		variantSrc.Ko = variant.Ko
+		variantSrc.Bazel = variant.Bazel
+		variantSrc.CloudBuild = variant.CloudBuild
+		variantSrc.CodeBuild = variant.CodeBuild
+		variantSrc.Depot = variant.Depot
+		variantSrc.Archive = variant.Archive
+		variantSrc.Autodetect = variant.Autodetect
+
+		result = append(result, variantSrc.withDefaults(srcDefaults))
+	}
+
+	return result
+}
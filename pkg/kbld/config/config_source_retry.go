@@ -0,0 +1,46 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// SourceRetryOpts builds image again when build fails
+// (e.g. due to network issues while pulling base images)
+type SourceRetryOpts struct {
+	// Attempts is a maximum number of builds (including the first one)
+	Attempts int `json:"attempts"`
+	// Delay between attempts (defaults to 1s)
+	Delay string `json:"delay,omitempty"`
+	// NoCacheOnLastAttempt disables builder cache for the last attempt
+	// in case failures are caused by corrupted cache
+	NoCacheOnLastAttempt bool `json:"noCacheOnLastAttempt,omitempty"`
+}
+
+func (d SourceRetryOpts) Validate() error {
+	if d.Attempts < 1 {
+		return fmt.Errorf("Expected Retry.Attempts to be greater than 0, but was %d", d.Attempts)
+	}
+	_, err := d.DelayWithDefaults()
+	if err != nil {
+		return fmt.Errorf("Parsing Retry.Delay: %s", err)
+	}
+	return nil
+}
+
+func (d SourceRetryOpts) DelayWithDefaults() (time.Duration, error) {
+	if len(d.Delay) == 0 {
+		return time.Second, nil
+	}
+	dur, err := time.ParseDuration(d.Delay)
+	if err != nil {
+		return 0, err
+	}
+	if dur < 0 {
+		return 0, fmt.Errorf("Expected '%s' to not be negative", d.Delay)
+	}
+	return dur, nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	// Descriptions of image fields typically mention one of these
+	// (e.g. "Container image name for Prometheus", "The docker image for the pods")
+	crdImageDescriptionRegexp = regexp.MustCompile(`(?i)\b(container|docker|oci)\s+image\b|\bimage\s+(reference|name|url|to use)\b`)
+	// Field names such as baseImage, initImage, sidecar_image
+	crdImageNameRegexp = regexp.MustCompile(`(Image|[_-]image)$`)
+)
+
+type crdDoc struct {
+	Spec struct {
+		Versions []struct {
+			Schema *struct {
+				OpenAPIV3Schema *crdSchema `json:"openAPIV3Schema"`
+			} `json:"schema"`
+		} `json:"versions"`
+		// Used by apiextensions.k8s.io/v1beta1
+		Validation *struct {
+			OpenAPIV3Schema *crdSchema `json:"openAPIV3Schema"`
+		} `json:"validation"`
+	} `json:"spec"`
+}
+
+type crdSchema struct {
+	Type        string               `json:"type"`
+	Description string               `json:"description"`
+	Properties  map[string]crdSchema `json:"properties"`
+	Items       *crdSchema           `json:"items"`
+}
+
+func IsCRD(res ctlres.Resource) bool {
+	return res.APIGroup() == "apiextensions.k8s.io" && res.Kind() == "CustomResourceDefinition"
+}
+
+// NewSearchRulesFromCRD returns search rules for string fields that
+// CRD schema documents as image references. Fields named 'image'
+// are skipped since they are matched by default search rule.
+func NewSearchRulesFromCRD(res ctlres.Resource) ([]SearchRule, error) {
+	bs, err := res.AsYAMLBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var doc crdDoc
+
+	err = yaml.Unmarshal(bs, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("Unmarshaling %s: %s", res.Description(), err)
+	}
+
+	var schemas []*crdSchema
+	for _, ver := range doc.Spec.Versions {
+		if ver.Schema != nil && ver.Schema.OpenAPIV3Schema != nil {
+			schemas = append(schemas, ver.Schema.OpenAPIV3Schema)
+		}
+	}
+	if doc.Spec.Validation != nil && doc.Spec.Validation.OpenAPIV3Schema != nil {
+		schemas = append(schemas, doc.Spec.Validation.OpenAPIV3Schema)
+	}
+
+	var rules []SearchRule
+	seenPaths := map[string]struct{}{}
+
+	for _, schema := range schemas {
+		schema.imagePaths(ctlres.Path{}, func(path ctlres.Path) {
+			if _, found := seenPaths[path.AsString()]; found {
+				return
+			}
+			seenPaths[path.AsString()] = struct{}{}
+			rules = append(rules, SearchRule{KeyMatcher: &SearchRuleKeyMatcher{Path: path}})
+		})
+	}
+
+	return rules, nil
+}
+
+func (s crdSchema) imagePaths(path ctlres.Path, addFunc func(ctlres.Path)) {
+	for _, name := range sortedKeys(s.Properties) {
+		prop := s.Properties[name]
+		propPath := append(append(ctlres.Path{}, path...), ctlres.NewPathPartFromString(name))
+
+		if prop.Type == "string" {
+			if name != "image" && prop.documentsImage(name) {
+				addFunc(propPath)
+			}
+			continue
+		}
+		prop.imagePaths(propPath, addFunc)
+	}
+
+	if s.Items != nil {
+		itemsPath := append(append(ctlres.Path{}, path...), ctlres.NewPathPartFromIndexAll())
+
+		if s.Items.Type == "string" {
+			// e.g. list of additional images (relatedImages style)
+			if s.Items.documentsImage("") {
+				addFunc(itemsPath)
+			}
+			return
+		}
+		s.Items.imagePaths(itemsPath, addFunc)
+	}
+}
+
+func (s crdSchema) documentsImage(name string) bool {
+	if crdImageDescriptionRegexp.MatchString(s.Description) {
+		return true
+	}
+	return len(name) > 0 && crdImageNameRegexp.MatchString(name) &&
+		strings.Contains(strings.ToLower(s.Description), "image")
+}
+
+func sortedKeys(props map[string]crdSchema) []string {
+	var keys []string
+	for key := range props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+func TestNewSearchRulesFromCRD(t *testing.T) {
+	res := ctlres.MustNewResourceFromBytes([]byte(`
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: prometheuses.monitoring.coreos.com
+spec:
+  versions:
+  - name: v1
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            properties:
+              image:
+                type: string
+                description: Container image name for Prometheus.
+              baseImage:
+                type: string
+                description: Base image to use for a Prometheus deployment.
+              imagePullPolicy:
+                type: string
+                description: Image pull policy for the containers.
+              version:
+                type: string
+                description: Version of Prometheus being deployed.
+              thanos:
+                type: object
+                properties:
+                  sidecarRef:
+                    type: string
+                    description: The container image used for Thanos sidecar.
+              extraImages:
+                type: array
+                items:
+                  type: string
+                  description: OCI image reference to pre-pull.
+  - name: v1beta1
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            properties:
+              baseImage:
+                type: string
+                description: Base image to use for a Prometheus deployment.
+`))
+
+	require.True(t, ctlconf.IsCRD(res))
+
+	rules, err := ctlconf.NewSearchRulesFromCRD(res)
+	require.NoError(t, err)
+
+	var paths []string
+	for _, rule := range rules {
+		require.NotNil(t, rule.KeyMatcher)
+		require.NoError(t, rule.Validate())
+		paths = append(paths, rule.KeyMatcher.Path.AsString())
+	}
+
+	assert.Equal(t, []string{"spec,baseImage", "spec,extraImages,(all)", "spec,thanos,sidecarRef"}, paths)
+}
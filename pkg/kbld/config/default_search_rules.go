@@ -0,0 +1,71 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+var (
+	// podTemplatePaths are locations of pod metadata and spec within
+	// standard Kubernetes resources: Pod itself, resources with pod
+	// template (Deployment, StatefulSet, DaemonSet, ReplicaSet, Job, etc.)
+	// and CronJob (which has pod template within job template)
+	podTemplatePaths = [][]string{
+		{},
+		{"spec", "template"},
+		{"spec", "jobTemplate", "spec", "template"},
+	}
+
+	// sidecarImageAnnotations are pod annotations used by admission
+	// webhooks to configure image of injected sidecar containers
+	sidecarImageAnnotations = []string{
+		"sidecar.istio.io/proxyImage",
+		"vault.hashicorp.com/agent-image",
+	}
+)
+
+// DefaultSearchRules returns search rules that are used in addition to configured ones.
+// Containers, init containers and ephemeral containers in all pod templates are
+// matched by the 'image' key rule.
+func DefaultSearchRules() []SearchRule {
+	result := []SearchRule{{
+		KeyMatcher: &SearchRuleKeyMatcher{Name: "image"},
+	}}
+
+	// OLM operator bundle conventions (relatedImages entries
+	// are already matched by default image rule)
+	result = append(result, SearchRule{
+		KeyMatcher: &SearchRuleKeyMatcher{
+			Path: ctlres.NewPathFromStrings([]string{"metadata", "annotations", "containerImage"}),
+		},
+	}, SearchRule{
+		KeyMatcher: &SearchRuleKeyMatcher{EnvVarNamePrefix: "RELATED_IMAGE_"},
+	})
+
+	for _, prefix := range podTemplatePaths {
+		// Image volumes refer to image via nested reference key
+		volumePath := podTemplatePath(prefix, "spec", "volumes")
+		volumePath = append(volumePath, ctlres.NewPathPartFromIndexAll())
+		volumePath = append(volumePath, ctlres.NewPathFromStrings([]string{"image", "reference"})...)
+
+		result = append(result, SearchRule{
+			KeyMatcher: &SearchRuleKeyMatcher{Path: volumePath},
+		})
+
+		for _, annotation := range sidecarImageAnnotations {
+			result = append(result, SearchRule{
+				KeyMatcher: &SearchRuleKeyMatcher{
+					Path: podTemplatePath(prefix, "metadata", "annotations", annotation),
+				},
+			})
+		}
+	}
+
+	return result
+}
+
+func podTemplatePath(prefix []string, keys ...string) ctlres.Path {
+	return ctlres.NewPathFromStrings(append(append([]string{}, prefix...), keys...))
+}
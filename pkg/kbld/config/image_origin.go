@@ -14,6 +14,18 @@ type Origin struct {
 	Tagged           *OriginTagged           `json:"tagged,omitempty"`
 	Preresolved      *OriginPreresolved      `json:"preresolved,omitempty"`
 	PlatformSelected *OriginPlatformSelected `json:"platformSelected,omitempty"`
+	Destinations     *OriginDestinations     `json:"destinations,omitempty"`
+	Signed           *OriginSigned           `json:"signed,omitempty"`
+	Verified         *OriginVerified         `json:"verified,omitempty"`
+	Provenance       *OriginAttestation      `json:"provenance,omitempty"`
+	SBOM             *OriginAttestation      `json:"sbom,omitempty"`
+	Scanned          *OriginScanned          `json:"scanned,omitempty"`
+	BaseImages       *OriginBaseImages       `json:"baseImages,omitempty"`
+	Squashed         *OriginSquashed         `json:"squashed,omitempty"`
+	Rebased          *OriginRebased          `json:"rebased,omitempty"`
+	RegistryCache    *OriginRegistryCache    `json:"registryCache,omitempty"`
+	Built            *OriginBuilt            `json:"built,omitempty"`
+	LocalStore       *OriginLocalStore       `json:"localStore,omitempty"`
 }
 
 type OriginGit struct {
@@ -47,6 +59,100 @@ type OriginPlatformSelected struct {
 	Variant      string `json:"variant,omitempty"`
 }
 
+type OriginDestinations struct {
+	URLs []string `json:"urls"`
+}
+
+type OriginSigned struct {
+	// Signatures are digest references of signature manifests
+	Signatures []string `json:"signatures"`
+	// TransparencyLogEntries are Rekor entries recorded for signatures
+	TransparencyLogEntries []OriginTransparencyLogEntry `json:"transparencyLogEntries,omitempty"`
+}
+
+func (d *OriginSigned) Add(sigURL string, entry *OriginTransparencyLogEntry) {
+	d.Signatures = append(d.Signatures, sigURL)
+	if entry != nil {
+		d.TransparencyLogEntries = append(d.TransparencyLogEntries, *entry)
+	}
+}
+
+type OriginTransparencyLogEntry struct {
+	URL      string `json:"url"`
+	LogIndex int64  `json:"logIndex"`
+	// Signature is digest reference of signature manifest recorded in this entry
+	Signature string `json:"signature"`
+}
+
+type OriginVerified struct {
+	// Policies describe verification policies image satisfied
+	Policies []string `json:"policies"`
+}
+
+type OriginAttestation struct {
+	Path     string `json:"path,omitempty"`
+	Attached bool   `json:"attached,omitempty"`
+}
+
+type OriginScanned struct {
+	Scanner string `json:"scanner"`
+	// Vulnerabilities counts found vulnerabilities by severity
+	Vulnerabilities map[string]int `json:"vulnerabilities,omitempty"`
+}
+
+type OriginBaseImages struct {
+	Images []OriginBaseImage `json:"images"`
+}
+
+type OriginBaseImage struct {
+	Image string `json:"image"`
+	URL   string `json:"url"`
+	// BuildArg is a name of build argument image was specified with
+	BuildArg string `json:"buildArg,omitempty"`
+}
+
+type OriginSquashed struct {
+	// URL is digest reference of image before it was squashed
+	URL       string `json:"url"`
+	BaseImage string `json:"baseImage,omitempty"`
+}
+
+type OriginRebased struct {
+	// URL is digest reference of image before it was rebased
+	URL     string `json:"url"`
+	OldBase string `json:"oldBase"`
+	// NewBase is digest reference of base image that image was rebased onto
+	NewBase string `json:"newBase"`
+}
+
+type OriginRegistryCache struct {
+	// Tag is content hash tag image is cached under
+	Tag string `json:"tag"`
+	// Hit indicates that image was found in destination (and was not built)
+	Hit bool `json:"hit"`
+}
+
+type OriginBuilt struct {
+	// Invocations are builder commands that built image
+	Invocations []OriginBuiltInvocation `json:"invocations"`
+}
+
+type OriginBuiltInvocation struct {
+	Tool    string   `json:"tool"`
+	Version string   `json:"version,omitempty"`
+	Args    []string `json:"args"`
+	// Env lists variables affecting build (other variables are not recorded)
+	Env []string `json:"env,omitempty"`
+}
+
+type OriginLocalStore struct {
+	Store string `json:"store"`
+	// ID is an image ID within local store
+	ID string `json:"id"`
+	// Pushed indicates that image was pushed since it had no repo digest
+	Pushed bool `json:"pushed,omitempty"`
+}
+
 func NewOriginsFromString(str string) ([]Origin, error) {
 	var origins []Origin
 
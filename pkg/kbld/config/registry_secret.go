@@ -0,0 +1,57 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+const (
+	registrySecretType = "kubernetes.io/dockerconfigjson"
+	registrySecretKey  = ".dockerconfigjson"
+)
+
+// RegistrySecret is a kubernetes.io/dockerconfigjson Secret
+// that could be referenced by destinations for credentials
+type RegistrySecret struct {
+	Name             string
+	Namespace        string
+	DockerConfigJSON []byte
+}
+
+func isRegistrySecret(res ctlres.Resource) bool {
+	if res.APIVersion() != "v1" || res.Kind() != "Secret" {
+		return false
+	}
+	typ, _ := res.DeepCopyRaw()["type"].(string)
+	return typ == registrySecretType
+}
+
+func NewRegistrySecretFromResource(res ctlres.Resource) (RegistrySecret, error) {
+	raw := res.DeepCopyRaw()
+	secret := RegistrySecret{Name: res.Name(), Namespace: res.Namespace()}
+
+	if stringData, ok := raw["stringData"].(map[string]interface{}); ok {
+		if val, ok := stringData[registrySecretKey].(string); ok {
+			secret.DockerConfigJSON = []byte(val)
+			return secret, nil
+		}
+	}
+
+	if data, ok := raw["data"].(map[string]interface{}); ok {
+		if val, ok := data[registrySecretKey].(string); ok {
+			decoded, err := base64.StdEncoding.DecodeString(val)
+			if err != nil {
+				return RegistrySecret{}, fmt.Errorf("Decoding secret '%s' key '%s': %s", res.Description(), registrySecretKey, err)
+			}
+			secret.DockerConfigJSON = decoded
+			return secret, nil
+		}
+	}
+
+	return RegistrySecret{}, fmt.Errorf("Expected secret '%s' to have key '%s'", res.Description(), registrySecretKey)
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+// SearchRulePack bundles search rules and image keys
+// for conventions used by a particular project
+type SearchRulePack struct {
+	Keys        []string
+	SearchRules []SearchRule
+}
+
+var (
+	builtinSearchRulePacks = map[string]SearchRulePack{
+		// Argo CD Application Helm parameters that set images
+		// (e.g. name: image, value: nginx:1.25) and images
+		// in Argo Workflows controller config
+		"argo": {
+			SearchRules: []SearchRule{{
+				KeyMatcher:   &SearchRuleKeyMatcher{EnvVarNameRegexp: `(.+\.)?image`},
+				ValueMatcher: &SearchRuleValueMatcher{ImageLike: true},
+			}, {
+				KeyMatcher: &SearchRuleKeyMatcher{
+					Path: ctlres.NewPathFromStrings([]string{"data", "config"}),
+				},
+				UpdateStrategy: &SearchRuleUpdateStrategy{
+					YAML: &SearchRuleUpdateStrategyYAML{
+						SearchRules: []SearchRule{{KeyMatcher: &SearchRuleKeyMatcher{Name: "image"}}},
+					},
+				},
+			}},
+		},
+		// Istio proxy image overrides and sidecar injector values
+		// (only full image references are matched since images
+		// are otherwise combined with hub and tag values)
+		"istio": {
+			Keys: []string{"proxyImage"},
+			SearchRules: []SearchRule{{
+				KeyMatcher: &SearchRuleKeyMatcher{
+					Path: ctlres.NewPathFromStrings([]string{"data", "values"}),
+				},
+				UpdateStrategy: &SearchRuleUpdateStrategy{
+					JSON: &SearchRuleUpdateStrategyJSON{
+						SearchRules: []SearchRule{{
+							KeyMatcher:   &SearchRuleKeyMatcher{Name: "image"},
+							ValueMatcher: &SearchRuleValueMatcher{ImageLike: true},
+						}},
+					},
+				},
+			}},
+		},
+		// Knative Serving deployment config
+		"knative": {
+			SearchRules: []SearchRule{{
+				KeyMatcher: &SearchRuleKeyMatcher{
+					Path: ctlres.NewPathFromStrings([]string{"data", "queue-sidecar-image"}),
+				},
+			}},
+		},
+	}
+)
+
+// BuiltinSearchRulePackNames returns sorted names of packs included with kbld
+func BuiltinSearchRulePackNames() []string {
+	var result []string
+	for name := range builtinSearchRulePacks {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// LoadSearchRulePack returns built-in pack by name or loads pack from a local
+// file with kbld configuration (only keys and search rules are used)
+func LoadSearchRulePack(nameOrPath string) (SearchRulePack, error) {
+	if pack, found := builtinSearchRulePacks[nameOrPath]; found {
+		return pack, nil
+	}
+
+	if _, err := os.Stat(nameOrPath); err != nil {
+		if os.IsNotExist(err) && !strings.ContainsAny(nameOrPath, `/\.`) {
+			return SearchRulePack{}, fmt.Errorf("Unknown search rule pack '%s' (built-in: %s)",
+				nameOrPath, strings.Join(BuiltinSearchRulePackNames(), ", "))
+		}
+		return SearchRulePack{}, fmt.Errorf("Reading search rule pack: %s", err)
+	}
+
+	rs, err := ctlres.NewFileResource(ctlres.NewLocalFileSource(nameOrPath), nameOrPath).Resources()
+	if err != nil {
+		return SearchRulePack{}, err
+	}
+
+	var pack SearchRulePack
+
+	for _, res := range rs {
+		if !matchesConfigKind(res) {
+			return SearchRulePack{}, fmt.Errorf("Expected search rule pack '%s' to only contain kbld configuration, but found %s",
+				nameOrPath, res.Description())
+		}
+
+		config, err := NewConfigFromResource(res)
+		if err != nil {
+			return SearchRulePack{}, err
+		}
+
+		if len(config.SearchRulePacks) > 0 {
+			return SearchRulePack{}, fmt.Errorf("Expected search rule pack '%s' to not refer to other search rule packs", nameOrPath)
+		}
+
+		// Keys are converted into search rules
+		pack.SearchRules = append(pack.SearchRules, config.SearchRules...)
+	}
+
+	return pack, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+	ctlser "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/search"
+)
+
+func TestSearchRulePacks(t *testing.T) {
+	packPath := filepath.Join(t.TempDir(), "pack.yml")
+	require.NoError(t, os.WriteFile(packPath, []byte(`
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+keys: [exporterImage]
+`), 0600))
+
+	confRs, err := ctlres.NewResourcesFromBytes([]byte(fmt.Sprintf(`
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+searchRulePacks: [knative, %s]
+`, packPath)))
+	require.NoError(t, err)
+
+	_, conf, err := ctlconf.NewConfFromResources(confRs)
+	require.NoError(t, err)
+
+	rs, err := ctlres.NewResourcesFromBytes([]byte(`
+kind: ConfigMap
+metadata:
+  name: config-deployment
+data:
+  queue-sidecar-image: gcr.io/knative-releases/queue:v1.0.0
+  exporterImage: exporter:v1
+  other: not-image:v1
+`))
+	require.NoError(t, err)
+
+	var foundImages []string
+
+	ctlser.NewImageRefs(rs[0].DeepCopyRaw(), conf.SearchRules()).Visit(func(val string) (string, bool) {
+		foundImages = append(foundImages, val)
+		return "", false
+	})
+
+	sort.Strings(foundImages)
+
+	assert.Equal(t, []string{"exporter:v1", "gcr.io/knative-releases/queue:v1.0.0"}, foundImages)
+}
+
+func TestSearchRulePacksUnknown(t *testing.T) {
+	rs, err := ctlres.NewResourcesFromBytes([]byte(`
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+searchRulePacks: [unknown]
+`))
+	require.NoError(t, err)
+
+	_, _, err = ctlconf.NewConfFromResources(rs)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Validating SearchRulePacks[0]: Unknown search rule pack 'unknown' (built-in: argo, istio, knative)")
+}
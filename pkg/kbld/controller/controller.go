@@ -0,0 +1,349 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+// ResolveFunc resolves images in given resources (including kbld configuration);
+// source paths are placed within sourcesDir when it's non-empty
+type ResolveFunc func(rs []ctlres.Resource, sourcesDir string) (ResolveResult, error)
+
+type ResolveResult struct {
+	Manifests []byte
+	Lock      []byte
+}
+
+// Controller periodically reconciles ImageResolution resources
+type Controller struct {
+	kubectl   Kubectl
+	resolve   ResolveFunc
+	namespace string
+	logger    *ctllog.PrefixWriter
+	now       func() time.Time
+}
+
+func NewController(kubectl Kubectl, resolve ResolveFunc, namespace string, logger ctllog.Logger) Controller {
+	return Controller{kubectl, resolve, namespace, logger.NewPrefixedWriter("controller | "), time.Now}
+}
+
+// Run reconciles all resources on every interval until context is done
+func (c Controller) Run(ctx context.Context, interval time.Duration) error {
+	for {
+		err := c.ReconcileAll()
+		if err != nil {
+			c.logger.WriteStr("error: %s\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (c Controller) ReconcileAll() error {
+	args := []string{"get", ImageResolutionResource, "-o", "json"}
+	if len(c.namespace) > 0 {
+		args = append(args, "-n", c.namespace)
+	} else {
+		args = append(args, "--all-namespaces")
+	}
+
+	out, err := c.kubectl.Run(nil, args...)
+	if err != nil {
+		return err
+	}
+
+	var list struct {
+		Items []ImageResolution `json:"items"`
+	}
+
+	err = json.Unmarshal(out, &list)
+	if err != nil {
+		return fmt.Errorf("Unmarshaling image resolutions: %s", err)
+	}
+
+	for _, ir := range list.Items {
+		err := c.Reconcile(ir)
+		if err != nil {
+			c.logger.WriteStr("error: %s/%s: %s\n", ir.Metadata.Namespace, ir.Metadata.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Reconcile resolves image resolution when its spec or inputs changed
+// (failed resolutions are retried on each call)
+func (c Controller) Reconcile(ir ImageResolution) error {
+	err := ir.Validate()
+	if err != nil {
+		return c.updateStatus(ir, ImageResolutionStatus{Message: err.Error()})
+	}
+
+	// Git checkouts are kept until resolution finishes as sources are built from them
+	checkoutsDir, err := util.MkdirTemp("kbld-controller-git")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(checkoutsDir)
+
+	rs, sourcesDir, err := c.inputs(ir, checkoutsDir)
+	if err != nil {
+		return c.updateStatus(ir, ImageResolutionStatus{Message: fmt.Sprintf("Fetching inputs: %s", err)})
+	}
+
+	inputsDigest, err := c.inputsDigest(rs)
+	if err != nil {
+		return err
+	}
+
+	if ir.Status.Succeeded && ir.Status.ObservedGeneration == ir.Metadata.Generation &&
+		ir.Status.InputsDigest == inputsDigest {
+		return nil
+	}
+
+	c.logger.WriteStr("resolving %s/%s\n", ir.Metadata.Namespace, ir.Metadata.Name)
+
+	status := ImageResolutionStatus{InputsDigest: inputsDigest}
+
+	result, err := c.resolve(rs, sourcesDir)
+	if err == nil {
+		err = c.writeOutput(ir, result)
+	}
+	if err != nil {
+		status.Message = err.Error()
+	} else {
+		status.Succeeded = true
+		status.Message = fmt.Sprintf("Resolved into %s %s", ir.Spec.Output.KindWithDefaults(), ir.OutputNameWithDefaults())
+	}
+
+	return c.updateStatus(ir, status)
+}
+
+// inputs returns resources of all sources and directory for source paths
+// (checkout of first git source of config, otherwise of manifests)
+func (c Controller) inputs(ir ImageResolution, checkoutsDir string) ([]ctlres.Resource, string, error) {
+	var rs []ctlres.Resource
+	var manifestsDir, configDir string
+
+	for i, src := range append(append([]ImageResolutionSource{}, ir.Spec.Manifests...), ir.Spec.Config...) {
+		var srcRs []ctlres.Resource
+		var err error
+
+		switch {
+		case src.ConfigMap != nil:
+			srcRs, err = c.configMapResources(ir.Metadata.Namespace, *src.ConfigMap)
+
+		case src.Git != nil:
+			dir := filepath.Join(checkoutsDir, strconv.Itoa(i))
+			srcRs, err = c.gitResources(*src.Git, dir)
+
+			switch {
+			case i < len(ir.Spec.Manifests):
+				if len(manifestsDir) == 0 {
+					manifestsDir = dir
+				}
+			case len(configDir) == 0:
+				configDir = dir
+			}
+		}
+		if err != nil {
+			return nil, "", err
+		}
+
+		rs = append(rs, srcRs...)
+	}
+
+	if len(configDir) > 0 {
+		return rs, configDir, nil
+	}
+	return rs, manifestsDir, nil
+}
+
+func (c Controller) configMapResources(namespace string, src ImageResolutionConfigMapSource) ([]ctlres.Resource, error) {
+	out, err := c.kubectl.Run(nil, "get", "configmap", src.Name, "-n", namespace, "-o", "json")
+	if err != nil {
+		return nil, err
+	}
+
+	var configMap struct {
+		Data map[string]string `json:"data"`
+	}
+
+	err = json.Unmarshal(out, &configMap)
+	if err != nil {
+		return nil, fmt.Errorf("Unmarshaling configmap '%s': %s", src.Name, err)
+	}
+
+	var keys []string
+	if len(src.Key) > 0 {
+		if _, found := configMap.Data[src.Key]; !found {
+			return nil, fmt.Errorf("Expected configmap '%s' to have key '%s'", src.Name, src.Key)
+		}
+		keys = []string{src.Key}
+	} else {
+		for key := range configMap.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+	}
+
+	var rs []ctlres.Resource
+
+	for _, key := range keys {
+		keyRs, err := ctlres.NewFileResource(ctlres.NewBytesSource([]byte(configMap.Data[key])), key).Resources()
+		if err != nil {
+			return nil, fmt.Errorf("Parsing configmap '%s' key '%s': %s", src.Name, key, err)
+		}
+		rs = append(rs, keyRs...)
+	}
+
+	return rs, nil
+}
+
+func (c Controller) gitResources(src ImageResolutionGitSource, dir string) ([]ctlres.Resource, error) {
+	err := os.Mkdir(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	ref := src.Ref
+	if len(ref) == 0 {
+		ref = "HEAD"
+	}
+
+	// Fetching specific ref works for branches, tags and commit SHAs
+	// (values are placed after '--' so that they are never parsed as options)
+	cmds := [][]string{
+		{"init", "-q"},
+		{"remote", "add", "--", "origin", src.URL},
+		{"fetch", "-q", "--depth", "1", "--", "origin", ref},
+		{"checkout", "-q", "FETCH_HEAD"},
+	}
+
+	for _, args := range cmds {
+		var stderrBuf bytes.Buffer
+
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Stderr = &stderrBuf
+
+		err := cmd.Run()
+		if err != nil {
+			return nil, fmt.Errorf("Running 'git %s': %s (stderr: %s)",
+				strings.Join(args, " "), err, strings.TrimSpace(stderrBuf.String()))
+		}
+	}
+
+	fileRs, err := ctlres.NewFileResources(filepath.Join(dir, filepath.Clean("/"+src.Path)))
+	if err != nil {
+		return nil, err
+	}
+
+	var rs []ctlres.Resource
+
+	for _, fileRes := range fileRs {
+		resources, err := fileRes.Resources()
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, resources...)
+	}
+
+	return rs, nil
+}
+
+func (c Controller) inputsDigest(rs []ctlres.Resource) (string, error) {
+	hash := sha256.New()
+	for _, res := range rs {
+		bs, err := res.AsYAMLBytes()
+		if err != nil {
+			return "", err
+		}
+		hash.Write(append(bs, []byte("\n---\n")...))
+	}
+	return fmt.Sprintf("sha256:%x", hash.Sum(nil)), nil
+}
+
+func (c Controller) writeOutput(ir ImageResolution, result ResolveResult) error {
+	data := map[string]string{
+		ImageResolutionOutputManifestsKey: string(result.Manifests),
+		ImageResolutionOutputLockKey:      string(result.Lock),
+	}
+
+	obj := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       ir.Spec.Output.KindWithDefaults(),
+		"metadata": map[string]interface{}{
+			"name":      ir.OutputNameWithDefaults(),
+			"namespace": ir.Metadata.Namespace,
+			"labels": map[string]interface{}{
+				"kbld.k14s.io/image-resolution": ir.Metadata.Name,
+			},
+			"ownerReferences": []interface{}{
+				map[string]interface{}{
+					"apiVersion": ImageResolutionAPIVersion,
+					"kind":       ImageResolutionKind,
+					"name":       ir.Metadata.Name,
+					"uid":        ir.Metadata.UID,
+				},
+			},
+		},
+	}
+
+	if ir.Spec.Output.KindWithDefaults() == ImageResolutionOutputSecret {
+		obj["type"] = "Opaque"
+		obj["stringData"] = data
+	} else {
+		obj["data"] = data
+	}
+
+	bs, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.kubectl.Run(bs, "apply", "-f", "-")
+	return err
+}
+
+func (c Controller) updateStatus(ir ImageResolution, status ImageResolutionStatus) error {
+	status.ObservedGeneration = ir.Metadata.Generation
+	status.LastResolveTime = c.now().UTC().Format(time.RFC3339)
+
+	patch, err := json.Marshal(map[string]interface{}{"status": status})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.kubectl.Run(nil, "patch", ImageResolutionResource, ir.Metadata.Name,
+		"-n", ir.Metadata.Namespace, "--subresource=status", "--type=merge", "-p", string(patch))
+	if err != nil {
+		return fmt.Errorf("Updating status: %s", err)
+	}
+
+	if !status.Succeeded {
+		return fmt.Errorf("%s", status.Message)
+	}
+	return nil
+}
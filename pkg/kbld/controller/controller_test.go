@@ -0,0 +1,202 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package controller_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlctrl "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/controller"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+type fakeKubectl struct {
+	outputs map[string]string
+	calls   []string
+	stdins  []string
+}
+
+func (k *fakeKubectl) Run(stdin []byte, args ...string) ([]byte, error) {
+	call := strings.Join(args, " ")
+	k.calls = append(k.calls, call)
+	if len(stdin) > 0 {
+		k.stdins = append(k.stdins, string(stdin))
+	}
+	for prefix, out := range k.outputs {
+		if strings.HasPrefix(call, prefix) {
+			return []byte(out), nil
+		}
+	}
+	return nil, nil
+}
+
+func TestControllerReconcile(t *testing.T) {
+	kubectl := &fakeKubectl{outputs: map[string]string{
+		"get configmap app-manifests -n ns": `{"data": {"b.yml": "kind: B\nmetadata: {name: b}", "a.yml": "kind: A\nmetadata: {name: a}"}}`,
+	}}
+
+	var resolvedRs []ctlres.Resource
+
+	resolve := func(rs []ctlres.Resource, _ string) (ctlctrl.ResolveResult, error) {
+		resolvedRs = rs
+		return ctlctrl.ResolveResult{Manifests: []byte("manifests"), Lock: []byte("lock")}, nil
+	}
+
+	controller := ctlctrl.NewController(kubectl, resolve, "", ctllog.NewLogger(io.Discard))
+
+	ir := ctlctrl.ImageResolution{
+		Metadata: ctlctrl.ImageResolutionMeta{Name: "app", Namespace: "ns", UID: "uid-1", Generation: 2},
+		Spec: ctlctrl.ImageResolutionSpec{
+			Manifests: []ctlctrl.ImageResolutionSource{{ConfigMap: &ctlctrl.ImageResolutionConfigMapSource{Name: "app-manifests"}}},
+		},
+	}
+
+	require.NoError(t, controller.Reconcile(ir))
+
+	require.Len(t, resolvedRs, 2)
+	assert.Equal(t, "A", resolvedRs[0].Kind())
+	assert.Equal(t, "B", resolvedRs[1].Kind())
+
+	require.Len(t, kubectl.stdins, 1)
+
+	var output map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(kubectl.stdins[0]), &output))
+
+	assert.Equal(t, "ConfigMap", output["kind"])
+	assert.Equal(t, map[string]interface{}{"manifests.yml": "manifests", "lock.yml": "lock"}, output["data"])
+	assert.Equal(t, "app-resolved", output["metadata"].(map[string]interface{})["name"])
+
+	statusCall := kubectl.calls[len(kubectl.calls)-1]
+	assert.Contains(t, statusCall, "patch imageresolutions.kbld.k14s.io app -n ns --subresource=status")
+	assert.Contains(t, statusCall, `"observedGeneration":2`)
+	assert.Contains(t, statusCall, `"succeeded":true`)
+
+	t.Run("skips unchanged resolutions", func(t *testing.T) {
+		var status struct {
+			Status ctlctrl.ImageResolutionStatus
+		}
+		require.NoError(t, json.Unmarshal([]byte(statusCall[strings.Index(statusCall, "{"):]), &status))
+
+		ir.Status = status.Status
+		resolvedRs = nil
+		kubectl.calls = nil
+
+		require.NoError(t, controller.Reconcile(ir))
+		assert.Nil(t, resolvedRs)
+		assert.Equal(t, []string{"get configmap app-manifests -n ns -o json"}, kubectl.calls)
+	})
+
+	t.Run("records resolution failures in status", func(t *testing.T) {
+		failingController := ctlctrl.NewController(kubectl, func([]ctlres.Resource, string) (ctlctrl.ResolveResult, error) {
+			return ctlctrl.ResolveResult{}, fmt.Errorf("registry down")
+		}, "", ctllog.NewLogger(io.Discard))
+
+		ir.Status = ctlctrl.ImageResolutionStatus{}
+		kubectl.calls = nil
+
+		err := failingController.Reconcile(ir)
+		require.EqualError(t, err, "registry down")
+		assert.Contains(t, kubectl.calls[len(kubectl.calls)-1], `"message":"registry down"`)
+	})
+}
+
+func TestControllerReconcileGitSource(t *testing.T) {
+	repoDir := t.TempDir()
+
+	files := map[string]string{
+		"config/app.yml":  "kind: Deployment\nmetadata: {name: app}",
+		"kbld.yml":        "apiVersion: kbld.k14s.io/v1alpha1\nkind: Config\nsources:\n- image: app\n  path: src",
+		"src/Dockerfile":  "FROM scratch",
+		"other/ignore.md": "not a resource",
+	}
+	for path, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Join(repoDir, filepath.Dir(path)), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(repoDir, path), []byte(content), 0600))
+	}
+
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=kbld", "-c", "user.email=kbld@example.com", "commit", "-q", "-m", "initial"},
+		{"tag", "v1"},
+	} {
+		out, err := exec.Command("git", append([]string{"-C", repoDir}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	kubectl := &fakeKubectl{}
+
+	var resolvedRs []ctlres.Resource
+	var resolvedSourcesDir string
+
+	resolve := func(rs []ctlres.Resource, sourcesDir string) (ctlctrl.ResolveResult, error) {
+		resolvedRs = rs
+		resolvedSourcesDir = sourcesDir
+
+		// Checkout is still available while resolving
+		_, err := os.Stat(filepath.Join(sourcesDir, "src", "Dockerfile"))
+		require.NoError(t, err)
+
+		return ctlctrl.ResolveResult{}, nil
+	}
+
+	controller := ctlctrl.NewController(kubectl, resolve, "", ctllog.NewLogger(io.Discard))
+
+	newIR := func(ref string) ctlctrl.ImageResolution {
+		return ctlctrl.ImageResolution{
+			Metadata: ctlctrl.ImageResolutionMeta{Name: "app", Namespace: "ns"},
+			Spec: ctlctrl.ImageResolutionSpec{
+				Manifests: []ctlctrl.ImageResolutionSource{{Git: &ctlctrl.ImageResolutionGitSource{
+					URL: "file://" + repoDir, Ref: ref, Path: "config"}}},
+				Config: []ctlctrl.ImageResolutionSource{{Git: &ctlctrl.ImageResolutionGitSource{
+					URL: "file://" + repoDir, Ref: ref, Path: "kbld.yml"}}},
+			},
+		}
+	}
+
+	t.Run("resolves resources with sources within checkout", func(t *testing.T) {
+		require.NoError(t, controller.Reconcile(newIR("v1")))
+
+		require.Len(t, resolvedRs, 2)
+		assert.Equal(t, "Deployment", resolvedRs[0].Kind())
+		assert.Equal(t, "Config", resolvedRs[1].Kind())
+
+		// Config source is checked out separately from manifests source
+		assert.Equal(t, "1", filepath.Base(resolvedSourcesDir))
+
+		_, err := os.Stat(resolvedSourcesDir)
+		assert.True(t, os.IsNotExist(err), "Expected checkout to be removed after resolution")
+	})
+
+	t.Run("rejects options as url or ref", func(t *testing.T) {
+		marker := filepath.Join(t.TempDir(), "marker")
+		resolvedRs = nil
+
+		for _, ir := range []ctlctrl.ImageResolution{
+			newIR("--upload-pack=touch " + marker + ";git-upload-pack"),
+			{
+				Metadata: ctlctrl.ImageResolutionMeta{Name: "app", Namespace: "ns"},
+				Spec: ctlctrl.ImageResolutionSpec{Manifests: []ctlctrl.ImageResolutionSource{{
+					Git: &ctlctrl.ImageResolutionGitSource{URL: "--upload-pack=touch " + marker}}}},
+			},
+		} {
+			err := controller.Reconcile(ir)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "to not start with '-'")
+		}
+
+		assert.Nil(t, resolvedRs)
+		_, err := os.Stat(marker)
+		assert.True(t, os.IsNotExist(err), "Expected upload pack command to not run")
+	})
+}
@@ -0,0 +1,38 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Kubectl talks to the cluster (kubectl is used since it
+// is already required for in-cluster builds with kubectl-buildkit)
+type Kubectl interface {
+	Run(stdin []byte, args ...string) ([]byte, error)
+}
+
+type ExecKubectl struct{}
+
+var _ Kubectl = ExecKubectl{}
+
+func (ExecKubectl) Run(stdin []byte, args ...string) ([]byte, error) {
+	var stdoutBuf, stderrBuf bytes.Buffer
+
+	cmd := exec.Command("kubectl", args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdoutBuf
+	cmd.Stderr = &stderrBuf
+
+	err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("Running 'kubectl %s': %s (stderr: %s)",
+			strings.Join(args, " "), err, strings.TrimSpace(stderrBuf.String()))
+	}
+
+	return stdoutBuf.Bytes(), nil
+}
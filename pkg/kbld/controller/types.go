@@ -0,0 +1,128 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	ImageResolutionAPIVersion = "kbld.k14s.io/v1alpha1"
+	ImageResolutionKind       = "ImageResolution"
+	ImageResolutionResource   = "imageresolutions.kbld.k14s.io"
+
+	ImageResolutionOutputConfigMap = "ConfigMap"
+	ImageResolutionOutputSecret    = "Secret"
+
+	ImageResolutionOutputManifestsKey = "manifests.yml"
+	ImageResolutionOutputLockKey      = "lock.yml"
+)
+
+// ImageResolution requests resolution of manifests (and optional kbld configuration)
+// with resolved output written to a ConfigMap or Secret in the same namespace
+type ImageResolution struct {
+	APIVersion string                `json:"apiVersion"`
+	Kind       string                `json:"kind"`
+	Metadata   ImageResolutionMeta   `json:"metadata"`
+	Spec       ImageResolutionSpec   `json:"spec"`
+	Status     ImageResolutionStatus `json:"status,omitempty"`
+}
+
+type ImageResolutionMeta struct {
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace"`
+	UID        string `json:"uid"`
+	Generation int64  `json:"generation"`
+}
+
+type ImageResolutionSpec struct {
+	Manifests []ImageResolutionSource `json:"manifests"`
+	Config    []ImageResolutionSource `json:"config,omitempty"`
+	Output    ImageResolutionOutput   `json:"output,omitempty"`
+}
+
+type ImageResolutionSource struct {
+	ConfigMap *ImageResolutionConfigMapSource `json:"configMap,omitempty"`
+	Git       *ImageResolutionGitSource       `json:"git,omitempty"`
+}
+
+type ImageResolutionConfigMapSource struct {
+	Name string `json:"name"`
+	// Key selects single key (all keys are used when empty)
+	Key string `json:"key,omitempty"`
+}
+
+type ImageResolutionGitSource struct {
+	URL string `json:"url"`
+	Ref string `json:"ref,omitempty"`
+	// Path is file or directory within repository (defaults to repository root)
+	Path string `json:"path,omitempty"`
+}
+
+type ImageResolutionOutput struct {
+	// Kind is ConfigMap or Secret (defaults to ConfigMap)
+	Kind string `json:"kind,omitempty"`
+	// Name defaults to ImageResolution name with '-resolved' suffix
+	Name string `json:"name,omitempty"`
+}
+
+type ImageResolutionStatus struct {
+	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+	InputsDigest       string `json:"inputsDigest,omitempty"`
+	LastResolveTime    string `json:"lastResolveTime,omitempty"`
+	Succeeded          bool   `json:"succeeded"`
+	Message            string `json:"message,omitempty"`
+}
+
+func (d ImageResolution) Validate() error {
+	if len(d.Spec.Manifests) == 0 {
+		return fmt.Errorf("Expected spec.manifests to be non-empty")
+	}
+	for i, src := range append(append([]ImageResolutionSource{}, d.Spec.Manifests...), d.Spec.Config...) {
+		if (src.ConfigMap == nil) == (src.Git == nil) {
+			return fmt.Errorf("Expected source %d to specify exactly one of configMap or git", i)
+		}
+		if src.Git != nil {
+			err := src.Git.Validate()
+			if err != nil {
+				return fmt.Errorf("Validating source %d: %s", i, err)
+			}
+		}
+	}
+	switch d.Spec.Output.KindWithDefaults() {
+	case ImageResolutionOutputConfigMap, ImageResolutionOutputSecret:
+	default:
+		return fmt.Errorf("Expected spec.output.kind to be ConfigMap or Secret, but was '%s'", d.Spec.Output.Kind)
+	}
+	return nil
+}
+
+// Validate rejects values that git could interpret as options
+func (d ImageResolutionGitSource) Validate() error {
+	if len(d.URL) == 0 {
+		return fmt.Errorf("Expected git.url to be non-empty")
+	}
+	if strings.HasPrefix(d.URL, "-") {
+		return fmt.Errorf("Expected git.url to not start with '-', but was '%s'", d.URL)
+	}
+	if strings.HasPrefix(d.Ref, "-") {
+		return fmt.Errorf("Expected git.ref to not start with '-', but was '%s'", d.Ref)
+	}
+	return nil
+}
+
+func (d ImageResolution) OutputNameWithDefaults() string {
+	if len(d.Spec.Output.Name) > 0 {
+		return d.Spec.Output.Name
+	}
+	return d.Metadata.Name + "-resolved"
+}
+
+func (d ImageResolutionOutput) KindWithDefaults() string {
+	if len(d.Kind) > 0 {
+		return d.Kind
+	}
+	return ImageResolutionOutputConfigMap
+}
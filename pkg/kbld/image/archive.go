@@ -0,0 +1,191 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	regtarball "github.com/google/go-containerregistry/pkg/v1/tarball"
+	regtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	ctlb "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+// ArchivePusher pushes images stored in archives (e.g. exported by builders)
+// to image destinations and returns digest references of pushed images
+type ArchivePusher struct {
+	registry        ctlreg.Registry
+	intermediateTag *ctlb.IntermediateTag
+	logger          ctllog.Logger
+}
+
+func NewArchivePusher(registry ctlreg.Registry, intermediateTag *ctlb.IntermediateTag, logger ctllog.Logger) ArchivePusher {
+	return ArchivePusher{registry, intermediateTag, logger}
+}
+
+// Push pushes image (or image index) of OCI image layout or docker save tarball
+func (p ArchivePusher) Push(image, archivePath string, imgDst ctlconf.ImageDestination) (string, error) {
+	dstRepo, err := regname.NewRepository(imgDst.NewImage, regname.WeakValidation)
+	if err != nil {
+		return "", fmt.Errorf("Validating destination repository '%s': %s", imgDst.NewImage, err)
+	}
+
+	prefixedLogger := p.logger.NewPrefixedWriter(image + " | ")
+
+	prefixedLogger.WriteStr("starting push (using kbld): %s -> %s\n", archivePath, dstRepo.Name())
+	defer prefixedLogger.WriteStr("finished push (using kbld)\n")
+
+	layoutDir, err := os.MkdirTemp("", "kbld-oci-layout")
+	if err != nil {
+		return "", fmt.Errorf("Creating OCI layout directory: %s", err)
+	}
+	defer os.RemoveAll(layoutDir)
+
+	err = extractTar(archivePath, layoutDir)
+	if err != nil {
+		return "", fmt.Errorf("Extracting archive '%s': %s", archivePath, err)
+	}
+
+	digest, write, err := p.archiveWrite(archivePath, layoutDir)
+	if err != nil {
+		return "", fmt.Errorf("Reading archive '%s': %s", archivePath, err)
+	}
+
+	// Seems like AWS ECR doesnt like using digests for manifest uploads
+	tag := "kbld-" + strings.Replace(digest.String(), ":", "-", 1)
+	if p.intermediateTag != nil && len(p.intermediateTag.Tag()) > 0 {
+		tag = p.intermediateTag.Tag()
+	}
+
+	err = write(dstRepo.Tag(tag))
+	if err != nil {
+		prefixedLogger.WriteStr("push error: %s\n", err)
+		return "", err
+	}
+
+	url, _, err := NewDigestedImageFromParts(dstRepo.Name(), digest.String()).URL()
+	return url, err
+}
+
+// archiveWrite returns digest of image stored in extracted archive and function that pushes it
+func (p ArchivePusher) archiveWrite(archivePath, extractedDir string) (regv1.Hash, func(regname.Tag) error, error) {
+	if _, err := os.Stat(filepath.Join(extractedDir, "index.json")); err == nil {
+		return p.layoutWrite(extractedDir)
+	}
+
+	if _, err := os.Stat(filepath.Join(extractedDir, "manifest.json")); err != nil {
+		return regv1.Hash{}, nil, fmt.Errorf("Expected archive to be OCI image layout or docker save tarball " +
+			"(containing index.json or manifest.json), but was not")
+	}
+
+	// Docker save tarball is expected to contain single image
+	img, err := regtarball.ImageFromPath(archivePath, nil)
+	if err != nil {
+		return regv1.Hash{}, nil, err
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return regv1.Hash{}, nil, err
+	}
+
+	return digest, func(tagRef regname.Tag) error { return p.registry.WriteImage(tagRef, img) }, nil
+}
+
+// layoutWrite returns digest of single image (or image index)
+// referenced by OCI layout and function that pushes it
+func (p ArchivePusher) layoutWrite(layoutDir string) (regv1.Hash, func(regname.Tag) error, error) {
+	idx, err := layout.ImageIndexFromPath(layoutDir)
+	if err != nil {
+		return regv1.Hash{}, nil, err
+	}
+
+	idxManifest, err := idx.IndexManifest()
+	if err != nil {
+		return regv1.Hash{}, nil, err
+	}
+
+	if len(idxManifest.Manifests) != 1 {
+		return regv1.Hash{}, nil, fmt.Errorf("Expected to find exactly one manifest, but found %d", len(idxManifest.Manifests))
+	}
+
+	desc := idxManifest.Manifests[0]
+
+	switch desc.MediaType {
+	case regtypes.OCIImageIndex, regtypes.DockerManifestList:
+		childIdx, err := idx.ImageIndex(desc.Digest)
+		if err != nil {
+			return regv1.Hash{}, nil, err
+		}
+		return desc.Digest, func(tagRef regname.Tag) error { return p.registry.WriteIndex(tagRef, childIdx) }, nil
+
+	default:
+		img, err := idx.Image(desc.Digest)
+		if err != nil {
+			return regv1.Hash{}, nil, err
+		}
+		return desc.Digest, func(tagRef regname.Tag) error { return p.registry.WriteImage(tagRef, img) }, nil
+	}
+}
+
+// extractTar extracts regular files of tarball into directory
+func extractTar(archivePath, dir string) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	tarReader := tar.NewReader(file)
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		path := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(path, filepath.Clean(dir)+string(filepath.Separator)) {
+			return fmt.Errorf("Expected archive entry '%s' to be within archive", header.Name)
+		}
+
+		err = os.MkdirAll(filepath.Dir(path), 0700)
+		if err != nil {
+			return err
+		}
+
+		err = extractTarFile(tarReader, path)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func extractTarFile(r io.Reader, path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, r)
+	return err
+}
@@ -0,0 +1,122 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ctlbdk "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/docker"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+)
+
+const (
+	autodetectDefaultPackBuilder = "paketobuildpacks/builder-jammy-base"
+)
+
+// AutodetectBuilder fills in builder of source configured with Autodetect
+// based on contents of its path (sources with builders are returned as is)
+func AutodetectBuilder(src ctlconf.Source) (ctlconf.Source, error) {
+	if src.Autodetect == nil {
+		return src, nil
+	}
+
+	opts := *src.Autodetect
+	src.Autodetect = nil
+
+	hasDockerfile, err := autodetectDockerfile(src.Path, opts.Docker)
+	if err != nil {
+		return ctlconf.Source{}, err
+	}
+	if hasDockerfile {
+		src.Docker = &ctlconf.SourceDockerOpts{}
+		if opts.Docker != nil {
+			src.Docker = opts.Docker
+		}
+		return src, nil
+	}
+
+	hasGoMain, err := autodetectGoMain(src.Path)
+	if err != nil {
+		return ctlconf.Source{}, err
+	}
+	if hasGoMain {
+		if src.BaseImages != nil {
+			return ctlconf.Source{}, fmt.Errorf("Expected BaseImages to not be used with Go module "+
+				"at path '%s' (autodetected ko builder)", src.Path)
+		}
+		src.Ko = &ctlconf.SourceKoOpts{}
+		if opts.Ko != nil {
+			src.Ko = opts.Ko
+		}
+		return src, nil
+	}
+
+	packOpts := ctlconf.SourcePackOpts{}
+	if opts.Pack != nil {
+		packOpts = *opts.Pack
+	}
+	if packOpts.Build.Builder == nil {
+		builder := autodetectDefaultPackBuilder
+		packOpts.Build.Builder = &builder
+	}
+	src.Pack = &packOpts
+
+	return src, nil
+}
+
+func autodetectDockerfile(directory string, opts *ctlconf.SourceDockerOpts) (bool, error) {
+	var file *string
+	if opts != nil {
+		file = opts.Build.File
+	}
+
+	_, err := os.Stat(ctlbdk.DockerfilePath(directory, file))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("Checking Dockerfile presence: %s", err)
+	}
+
+	return true, nil
+}
+
+// autodetectGoMain checks if directory is a Go module with main package
+// in its root (ko builds package in source path)
+func autodetectGoMain(directory string) (bool, error) {
+	_, err := os.Stat(filepath.Join(directory, "go.mod"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("Checking go.mod presence: %s", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(directory, "*.go"))
+	if err != nil {
+		return false, err
+	}
+
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+
+		parsedFile, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.PackageClauseOnly)
+		if err != nil {
+			return false, fmt.Errorf("Parsing Go file '%s': %s", file, err)
+		}
+
+		if parsedFile.Name.Name == "main" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+)
+
+func TestAutodetectBuilder(t *testing.T) {
+	writeFiles := func(t *testing.T, files map[string]string) string {
+		dir := t.TempDir()
+		for name, content := range files {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+		}
+		return dir
+	}
+
+	t.Run("picks docker when Dockerfile is present", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			"Dockerfile": "FROM scratch\n",
+			"go.mod":     "module example.com/app\n",
+			"main.go":    "package main\n",
+		})
+
+		src, err := ctlimg.AutodetectBuilder(ctlconf.Source{Path: dir, Autodetect: &ctlconf.SourceAutodetectOpts{}})
+		require.NoError(t, err)
+		assert.Equal(t, ctlconf.Source{Path: dir, Docker: &ctlconf.SourceDockerOpts{}}, src)
+	})
+
+	t.Run("picks ko for Go module with main package", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			"go.mod":       "module example.com/app\n",
+			"main.go":      "// Command app\npackage main\n",
+			"main_test.go": "package main_test\n",
+		})
+
+		rawOpts := []string{"--bare"}
+		koOpts := &ctlconf.SourceKoOpts{Build: ctlconf.SourceKoBuildOpts{RawOptions: &rawOpts}}
+
+		src, err := ctlimg.AutodetectBuilder(ctlconf.Source{Path: dir, Autodetect: &ctlconf.SourceAutodetectOpts{Ko: koOpts}})
+		require.NoError(t, err)
+		assert.Equal(t, ctlconf.Source{Path: dir, Ko: koOpts}, src)
+	})
+
+	t.Run("falls back on buildpacks", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			"go.mod": "module example.com/lib\n",
+			"lib.go": "package lib\n",
+		})
+
+		src, err := ctlimg.AutodetectBuilder(ctlconf.Source{Path: dir, Autodetect: &ctlconf.SourceAutodetectOpts{}})
+		require.NoError(t, err)
+		require.NotNil(t, src.Pack)
+		assert.Nil(t, src.Autodetect)
+		assert.Equal(t, "paketobuildpacks/builder-jammy-base", *src.Pack.Build.Builder)
+	})
+
+	t.Run("keeps sources with builders as is", func(t *testing.T) {
+		src := ctlconf.Source{Path: "missing", Bazel: &ctlconf.SourceBazelOpts{}}
+
+		detectedSrc, err := ctlimg.AutodetectBuilder(src)
+		require.NoError(t, err)
+		assert.Equal(t, src, detectedSrc)
+	})
+}
@@ -0,0 +1,323 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	ctlbdk "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/docker"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	ctlsign "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/signing"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+// BaseImages resolves, checks and optionally pins base images before build
+type BaseImages struct {
+	registry ctlreg.Registry
+	policies []ctlconf.VerificationPolicy
+	verifier ctlsign.Verifier
+}
+
+func NewBaseImages(registry ctlreg.Registry, policies []ctlconf.VerificationPolicy, verifier ctlsign.Verifier) BaseImages {
+	return BaseImages{registry, policies, verifier}
+}
+
+// Prepare returns source that should be used for build (with pinned base images)
+// and cleanup function that should be called after build
+func (b BaseImages) Prepare(src ctlconf.Source) (ctlconf.Source, []ctlconf.Origin, func(), error) {
+	noop := func() {}
+
+	opts := src.BaseImages
+	if opts == nil {
+		return src, nil, noop, nil
+	}
+
+	images, dockerfilePath, err := b.images(src)
+	if err != nil {
+		return ctlconf.Source{}, nil, noop, err
+	}
+
+	argImages, err := b.argImages(src, dockerfilePath)
+	if err != nil {
+		return ctlconf.Source{}, nil, noop, err
+	}
+
+	origin := ctlconf.OriginBaseImages{}
+	mapping := map[string]string{}
+	buildArgs := map[string]string{}
+
+	for _, image := range images {
+		url, err := b.prepareImage(image, *opts)
+		if err != nil {
+			return ctlconf.Source{}, nil, noop, err
+		}
+
+		mapping[image] = url
+		origin.Images = append(origin.Images, ctlconf.OriginBaseImage{Image: image, URL: url})
+	}
+
+	var argNames []string
+	for name := range argImages {
+		argNames = append(argNames, name)
+	}
+	sort.Strings(argNames)
+
+	for _, name := range argNames {
+		url, err := b.prepareImage(argImages[name], *opts)
+		if err != nil {
+			return ctlconf.Source{}, nil, noop, err
+		}
+
+		buildArgs[name] = url
+		origin.Images = append(origin.Images, ctlconf.OriginBaseImage{Image: argImages[name], URL: url, BuildArg: name})
+	}
+
+	origins := []ctlconf.Origin{{BaseImages: &origin}}
+
+	if !opts.PinDigests || len(mapping)+len(buildArgs) == 0 {
+		return src, origins, noop, nil
+	}
+
+	if src.Pack != nil {
+		builder := mapping[*src.Pack.Build.Builder]
+		packOpts := *src.Pack
+		packOpts.Build.Builder = &builder
+		src.Pack = &packOpts
+		return src, origins, noop, nil
+	}
+
+	src = b.withBuildArgs(src, argNames, buildArgs)
+
+	if len(mapping) == 0 {
+		return src, origins, noop, nil
+	}
+
+	pinnedBs, err := ctlbdk.RewriteDockerfileFroms(dockerfilePath, mapping)
+	if err != nil {
+		return ctlconf.Source{}, nil, noop, err
+	}
+
+	tmpDir, err := util.MkdirTemp("kbld-base-images")
+	if err != nil {
+		return ctlconf.Source{}, nil, noop, err
+	}
+	cleanup := func() { os.RemoveAll(tmpDir) }
+
+	pinnedPath := filepath.Join(tmpDir, "Dockerfile")
+
+	err = os.WriteFile(pinnedPath, pinnedBs, 0600)
+	if err != nil {
+		cleanup()
+		return ctlconf.Source{}, nil, noop, err
+	}
+
+	return b.withDockerfile(src, pinnedPath), origins, cleanup, nil
+}
+
+// prepareImage resolves image to digest reference and checks it
+func (b BaseImages) prepareImage(image string, opts ctlconf.SourceBaseImagesOpts) (string, error) {
+	url, err := b.resolve(image)
+	if err != nil {
+		return "", fmt.Errorf("Resolving base image '%s': %s", image, err)
+	}
+
+	if expectedDigest, found := opts.Digests[image]; found {
+		digestRef, err := regname.NewDigest(url, regname.WeakValidation)
+		if err != nil {
+			return "", err
+		}
+		if digestRef.DigestStr() != expectedDigest {
+			return "", fmt.Errorf("Expected base image '%s' to have digest '%s', but was '%s'",
+				image, expectedDigest, digestRef.DigestStr())
+		}
+	}
+
+	if opts.Verify {
+		err := b.verify(image, url)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return url, nil
+}
+
+func (b BaseImages) images(src ctlconf.Source) ([]string, string, error) {
+	if src.Pack != nil {
+		if src.Pack.Build.Builder == nil {
+			return nil, "", nil
+		}
+		return []string{*src.Pack.Build.Builder}, "", nil
+	}
+
+	var file *string
+
+	switch {
+	case src.KubectlBuildkit != nil:
+		file = src.KubectlBuildkit.Build.File
+	case src.Depot != nil:
+		file = src.Depot.File
+	case src.Docker != nil && src.Docker.Buildx != nil:
+		file = src.Docker.Buildx.File
+	case src.Docker != nil:
+		file = src.Docker.Build.File
+	}
+
+	path := ctlbdk.DockerfilePath(src.Path, file)
+
+	images, err := ctlbdk.DockerfileBaseImages(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return images, path, nil
+}
+
+// argImages returns images specified via global build arguments keyed by argument name
+func (b BaseImages) argImages(src ctlconf.Source, dockerfilePath string) (map[string]string, error) {
+	if src.Pack != nil {
+		return nil, nil
+	}
+
+	var rawOptions *[]string
+
+	switch {
+	case src.KubectlBuildkit != nil:
+		rawOptions = src.KubectlBuildkit.Build.RawOptions
+	case src.Depot != nil:
+		rawOptions = src.Depot.RawOptions
+	case src.Docker != nil && src.Docker.Buildx != nil:
+		rawOptions = src.Docker.Buildx.RawOptions
+	case src.Docker != nil:
+		rawOptions = src.Docker.Build.RawOptions
+	}
+
+	var buildArgs map[string]string
+	if rawOptions != nil {
+		buildArgs = ctlbdk.DockerBuildArgs(*rawOptions)
+	}
+
+	return ctlbdk.DockerfileArgBaseImages(dockerfilePath, buildArgs)
+}
+
+func (b BaseImages) resolve(image string) (string, error) {
+	if digestRef, err := regname.NewDigest(image, regname.WeakValidation); err == nil {
+		return digestRef.Name(), nil
+	}
+
+	ref, err := regname.ParseReference(image, regname.WeakValidation)
+	if err != nil {
+		return "", err
+	}
+
+	desc, err := b.registry.Generic(ref)
+	if err != nil {
+		return "", err
+	}
+
+	return ref.Context().Digest(desc.Digest.String()).Name(), nil
+}
+
+func (b BaseImages) verify(image, url string) error {
+	imageMatcher := Matcher{image}
+	urlMatcher := Matcher{url}
+
+	for _, policy := range b.policies {
+		if !policy.AppliesToAll() && !imageMatcher.Matches(policy.ImageRef) && !urlMatcher.Matches(policy.ImageRef) {
+			continue
+		}
+
+		_, err := b.verifier.Verify(url, policy)
+		if err != nil {
+			return fmt.Errorf("Expected base image '%s' to satisfy verification policy (%s): %s",
+				image, ctlsign.PolicyDescription(policy), err)
+		}
+	}
+
+	return nil
+}
+
+func (b BaseImages) withDockerfile(src ctlconf.Source, path string) ctlconf.Source {
+	switch {
+	case src.KubectlBuildkit != nil:
+		opts := *src.KubectlBuildkit
+		opts.Build.File = &path
+		src.KubectlBuildkit = &opts
+
+	case src.Depot != nil:
+		opts := *src.Depot
+		opts.File = &path
+		src.Depot = &opts
+
+	case src.Docker != nil && src.Docker.Buildx != nil:
+		buildxOpts := *src.Docker.Buildx
+		buildxOpts.File = &path
+		opts := *src.Docker
+		opts.Buildx = &buildxOpts
+		src.Docker = &opts
+
+	default:
+		opts := ctlconf.SourceDockerOpts{}
+		if src.Docker != nil {
+			opts = *src.Docker
+		}
+		opts.Build.File = &path
+		src.Docker = &opts
+	}
+
+	return src
+}
+
+// withBuildArgs appends build arguments after raw options
+// so that they take precedence over ones specified by user
+func (b BaseImages) withBuildArgs(src ctlconf.Source, names []string, buildArgs map[string]string) ctlconf.Source {
+	if len(names) == 0 {
+		return src
+	}
+
+	appendArgs := func(rawOptions *[]string) *[]string {
+		var result []string
+		if rawOptions != nil {
+			result = append(result, *rawOptions...)
+		}
+		for _, name := range names {
+			result = append(result, "--build-arg", name+"="+buildArgs[name])
+		}
+		return &result
+	}
+
+	switch {
+	case src.KubectlBuildkit != nil:
+		opts := *src.KubectlBuildkit
+		opts.Build.RawOptions = appendArgs(opts.Build.RawOptions)
+		src.KubectlBuildkit = &opts
+
+	case src.Depot != nil:
+		opts := *src.Depot
+		opts.RawOptions = appendArgs(opts.RawOptions)
+		src.Depot = &opts
+
+	case src.Docker != nil && src.Docker.Buildx != nil:
+		buildxOpts := *src.Docker.Buildx
+		buildxOpts.RawOptions = appendArgs(buildxOpts.RawOptions)
+		opts := *src.Docker
+		opts.Buildx = &buildxOpts
+		src.Docker = &opts
+
+	default:
+		opts := ctlconf.SourceDockerOpts{}
+		if src.Docker != nil {
+			opts = *src.Docker
+		}
+		opts.Build.RawOptions = appendArgs(opts.Build.RawOptions)
+		src.Docker = &opts
+	}
+
+	return src
+}
@@ -0,0 +1,47 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+)
+
+// BuildTimeoutImage cancels context of builder commands once wrapped build
+// finishes and reports builds that were cancelled because they took longer
+// than timeout (zero timeout does not limit build duration)
+type BuildTimeoutImage struct {
+	image   Image
+	url     string
+	timeout time.Duration
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+var _ Image = BuildTimeoutImage{}
+
+func NewBuildTimeoutImage(image Image, url string, timeout time.Duration,
+	ctx context.Context, cancel context.CancelFunc) BuildTimeoutImage {
+
+	return BuildTimeoutImage{image, url, timeout, ctx, cancel}
+}
+
+func (i BuildTimeoutImage) URL() (string, []ctlconf.Origin, error) {
+	defer i.cancel()
+
+	url, origins, err := i.image.URL()
+	if err != nil {
+		if errors.Is(i.ctx.Err(), context.DeadlineExceeded) {
+			return "", nil, fmt.Errorf("Expected build of image '%s' to finish within %s, but it timed out "+
+				"(builder was terminated): %s", i.url, i.timeout, err)
+		}
+		return "", nil, err
+	}
+
+	return url, origins, nil
+}
@@ -4,9 +4,15 @@
 package image
 
 import (
+	"os"
 	"path/filepath"
+	"time"
 
+	ctlb "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder"
 	ctlbbz "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/bazel"
+	ctlbcb "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/cloudbuild"
+	ctlbcd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/codebuild"
+	ctlbdp "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/depot"
 	ctlbdk "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/docker"
 	ctlbko "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/ko"
 	ctlbkb "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/kubectlbuildkit"
@@ -21,20 +27,51 @@ type BuiltImage struct {
 
 	docker          ctlbdk.Docker
 	dockerBuildx    ctlbdk.Buildx
+	dockerBake      ctlbdk.Bake
 	pack            ctlbpk.Pack
 	kubectlBuildkit ctlbkb.KubectlBuildkit
 	ko              ctlbko.Ko
 	bazel           ctlbbz.Bazel
+	cloudBuild      ctlbcb.CloudBuild
+	codeBuild       ctlbcd.CodeBuild
+	depot           ctlbdp.Depot
+
+	baseImages      BaseImages
+	archivePusher   ArchivePusher
+	invocations     *ctlb.Invocations
+	intermediateTag *ctlb.IntermediateTag
 }
 
 func NewBuiltImage(url string, buildSource ctlconf.Source, imgDst *ctlconf.ImageDestination,
-	docker ctlbdk.Docker, dockerBuildx ctlbdk.Buildx, pack ctlbpk.Pack,
-	kubectlBuildkit ctlbkb.KubectlBuildkit, ko ctlbko.Ko, bazel ctlbbz.Bazel) BuiltImage {
-
-	return BuiltImage{url, buildSource, imgDst, docker, dockerBuildx, pack, kubectlBuildkit, ko, bazel}
+	docker ctlbdk.Docker, dockerBuildx ctlbdk.Buildx, dockerBake ctlbdk.Bake, pack ctlbpk.Pack,
+	kubectlBuildkit ctlbkb.KubectlBuildkit, ko ctlbko.Ko, bazel ctlbbz.Bazel,
+	cloudBuild ctlbcb.CloudBuild, codeBuild ctlbcd.CodeBuild, depot ctlbdp.Depot,
+	baseImages BaseImages, archivePusher ArchivePusher, invocations *ctlb.Invocations,
+	intermediateTag *ctlb.IntermediateTag) BuiltImage {
+
+	return BuiltImage{url, buildSource, imgDst, docker, dockerBuildx, dockerBake,
+		pack, kubectlBuildkit, ko, bazel, cloudBuild, codeBuild, depot, baseImages,
+		archivePusher, invocations, intermediateTag}
 }
 
 func (i BuiltImage) URL() (string, []ctlconf.Origin, error) {
+	// Builders record invocations into the same recorder
+	// hence previous (e.g. failed) attempts are forgotten
+	i.invocations.Reset()
+
+	url, origins, err := i.build()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if invocations := i.invocations.List(); len(invocations) > 0 {
+		origins = append(origins, ctlconf.Origin{Built: &ctlconf.OriginBuilt{Invocations: invocations}})
+	}
+
+	return url, origins, nil
+}
+
+func (i BuiltImage) build() (string, []ctlconf.Origin, error) {
 	origins, err := i.sources()
 	if err != nil {
 		return "", nil, err
@@ -42,6 +79,23 @@ func (i BuiltImage) URL() (string, []ctlconf.Origin, error) {
 
 	urlRepo, _ := URLRepo(i.url)
 
+	if i.imgDst != nil && i.imgDst.IntermediateTag != nil {
+		tag, err := RenderIntermediateTag(*i.imgDst.IntermediateTag, i.buildSource, urlRepo, origins, time.Now())
+		if err != nil {
+			return "", nil, err
+		}
+		i.intermediateTag.Set(tag)
+	}
+
+	buildSource, baseImageOrigins, cleanup, err := i.baseImages.Prepare(i.buildSource)
+	if err != nil {
+		return "", nil, err
+	}
+	defer cleanup()
+
+	i.buildSource = buildSource
+	origins = append(origins, baseImageOrigins...)
+
 	switch {
 	case i.buildSource.Pack != nil:
 		opts := ctlbpk.PackBuildOpts{
@@ -79,11 +133,59 @@ func (i BuiltImage) URL() (string, []ctlconf.Origin, error) {
 
 		return i.optionalPushWithDocker(dockerTmpRef, origins)
 
-	case i.buildSource.Docker != nil && i.buildSource.Docker.Buildx != nil:
-		url, err := i.dockerBuildx.BuildAndOptionallyPush(
-			urlRepo, i.buildSource.Path, i.imgDst, *i.buildSource.Docker.Buildx)
+	// Remote builders require destination (checked by factory)
+	case i.buildSource.CloudBuild != nil:
+		url, err := i.cloudBuild.BuildAndPush(urlRepo, i.buildSource.Path, *i.imgDst, *i.buildSource.CloudBuild)
+		return url, origins, err
+
+	case i.buildSource.CodeBuild != nil:
+		url, err := i.codeBuild.BuildAndPush(urlRepo, i.buildSource.Path, *i.imgDst, *i.buildSource.CodeBuild)
 		return url, origins, err
 
+	case i.buildSource.Depot != nil:
+		url, err := i.depot.BuildAndOptionallyPush(
+			urlRepo, i.buildSource.Path, i.imgDst, *i.buildSource.Depot)
+		return url, origins, err
+
+	// Archives are pushed and hence require destination (checked by factory)
+	case i.buildSource.Archive != nil:
+		archivePath := filepath.Join(i.buildSource.Path, i.buildSource.Archive.File)
+		url, err := i.archivePusher.Push(urlRepo, archivePath, *i.imgDst)
+		return url, origins, err
+
+	case i.buildSource.Docker != nil && i.buildSource.Docker.Bake != nil:
+		url, err := i.dockerBake.BuildAndOptionallyPush(
+			urlRepo, i.buildSource.Path, i.imgDst, *i.buildSource.Docker.Bake)
+		return url, origins, err
+
+	case i.buildSource.Docker != nil && i.buildSource.Docker.Buildx != nil:
+		buildxOpts := *i.buildSource.Docker.Buildx
+
+		switch buildxOpts.OutputWithDefaults(i.imgDst != nil) {
+		case ctlconf.SourceDockerBuildxOutputLoad:
+			tmpRef, err := i.dockerBuildx.BuildAndOptionallyPush(urlRepo, i.buildSource.Path, nil, buildxOpts)
+			if err != nil {
+				return "", nil, err
+			}
+
+			return i.optionalPushWithDocker(ctlbdk.NewTmpRef(tmpRef), origins)
+
+		// Exported archive requires destination (checked by factory)
+		case ctlconf.SourceDockerBuildxOutputOCI:
+			archivePath, err := i.dockerBuildx.BuildArchive(urlRepo, i.buildSource.Path, buildxOpts)
+			if err != nil {
+				return "", nil, err
+			}
+			defer os.Remove(archivePath)
+
+			url, err := i.archivePusher.Push(urlRepo, archivePath, *i.imgDst)
+			return url, origins, err
+
+		default:
+			url, err := i.dockerBuildx.BuildAndOptionallyPush(urlRepo, i.buildSource.Path, i.imgDst, buildxOpts)
+			return url, origins, err
+		}
+
 	// Fall back on Docker by default
 	default:
 		if i.buildSource.Docker == nil {
@@ -108,6 +210,60 @@ func (i BuiltImage) URL() (string, []ctlconf.Origin, error) {
 	}
 }
 
+// WithoutCache returns image built with builder cache disabled
+// (builders without cache options are left as is)
+func (i BuiltImage) WithoutCache() BuiltImage {
+	src := i.buildSource
+	noCache := true
+
+	switch {
+	case src.Pack != nil:
+		opts := *src.Pack
+		opts.Build.ClearCache = &noCache
+		src.Pack = &opts
+
+	case src.KubectlBuildkit != nil:
+		opts := *src.KubectlBuildkit
+		opts.Build.NoCache = &noCache
+		src.KubectlBuildkit = &opts
+
+	case src.Depot != nil:
+		opts := *src.Depot
+		opts.NoCache = &noCache
+		src.Depot = &opts
+
+	case src.Ko != nil, src.Bazel != nil, src.CloudBuild != nil, src.CodeBuild != nil, src.Archive != nil:
+
+	// Docker is used by default
+	default:
+		var opts ctlconf.SourceDockerOpts
+		if src.Docker != nil {
+			opts = *src.Docker
+		}
+
+		switch {
+		case opts.Bake != nil:
+			bakeOpts := *opts.Bake
+			rawOptions := []string{"--no-cache"}
+			if bakeOpts.RawOptions != nil {
+				rawOptions = append(append([]string{}, *bakeOpts.RawOptions...), rawOptions...)
+			}
+			bakeOpts.RawOptions = &rawOptions
+			opts.Bake = &bakeOpts
+		case opts.Buildx != nil:
+			buildxOpts := *opts.Buildx
+			buildxOpts.NoCache = &noCache
+			opts.Buildx = &buildxOpts
+		default:
+			opts.Build.NoCache = &noCache
+		}
+		src.Docker = &opts
+	}
+
+	i.buildSource = src
+	return i
+}
+
 func (i BuiltImage) optionalPushWithDocker(dockerTmpRef ctlbdk.TmpRef, origins []ctlconf.Origin) (string, []ctlconf.Origin, error) {
 	if i.imgDst != nil {
 		digest, err := i.docker.Push(dockerTmpRef, i.imgDst.NewImage)
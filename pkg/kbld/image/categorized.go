@@ -0,0 +1,34 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+// CategorizedImage assigns category to errors returned by wrapped image
+// (errors categorized by inner images keep their category)
+type CategorizedImage struct {
+	image    Image
+	category util.ErrorCategory
+}
+
+var _ Image = CategorizedImage{}
+
+func NewCategorizedImage(image Image, category util.ErrorCategory) CategorizedImage {
+	return CategorizedImage{image, category}
+}
+
+func (i CategorizedImage) URL() (string, []ctlconf.Origin, error) {
+	url, origins, err := i.image.URL()
+	if err != nil {
+		return "", nil, util.NewCategorizedError(i.category, err)
+	}
+	return url, origins, nil
+}
+
+func newConfigErrImage(err error) ErrImage {
+	return NewErrImage(util.NewCategorizedError(util.ErrorCategoryConfig, err))
+}
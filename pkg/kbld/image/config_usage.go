@@ -0,0 +1,91 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"sync"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+)
+
+// ConfigUsage records which overrides, sources and destinations
+// were matched by factory. Entries are identified by their position
+// in configuration, hence unused entries should be looked up in
+// configuration given to factory (or one that it was derived from
+// by appending configs).
+type ConfigUsage struct {
+	used     map[configUsageEntry]struct{}
+	usedLock sync.Mutex
+}
+
+type configUsageKind int
+
+const (
+	configUsageOverride configUsageKind = iota
+	configUsageSource
+	configUsageDestination
+)
+
+type configUsageEntry struct {
+	Kind  configUsageKind
+	Index int
+}
+
+func NewConfigUsage() *ConfigUsage {
+	return &ConfigUsage{used: map[configUsageEntry]struct{}{}}
+}
+
+// UnusedOverrides returns overrides that did not match any image
+func (u *ConfigUsage) UnusedOverrides(conf ctlconf.Conf) []ctlconf.ImageOverride {
+	var result []ctlconf.ImageOverride
+	for i, override := range conf.ImageOverrides() {
+		if !u.isUsed(configUsageOverride, i) {
+			result = append(result, override)
+		}
+	}
+	return result
+}
+
+// UnusedSources returns sources (expanded per matrix variant) that did not match any image
+func (u *ConfigUsage) UnusedSources(conf ctlconf.Conf) []ctlconf.Source {
+	var result []ctlconf.Source
+	for i, src := range conf.Sources() {
+		if !u.isUsed(configUsageSource, i) {
+			result = append(result, src)
+		}
+	}
+	return result
+}
+
+// UnusedDestinations returns destinations that did not apply to any image
+// (destinations skipped because of their conditions are considered used)
+func (u *ConfigUsage) UnusedDestinations(conf ctlconf.Conf) []ctlconf.ImageDestination {
+	var result []ctlconf.ImageDestination
+	for i, dst := range conf.ImageDestinations() {
+		if !u.isUsed(configUsageDestination, i) {
+			result = append(result, dst)
+		}
+	}
+	return result
+}
+
+// record is a noop when usage is not tracked
+func (u *ConfigUsage) record(kind configUsageKind, idx int) {
+	if u == nil {
+		return
+	}
+
+	u.usedLock.Lock()
+	defer u.usedLock.Unlock()
+
+	u.used[configUsageEntry{kind, idx}] = struct{}{}
+}
+
+func (u *ConfigUsage) isUsed(kind configUsageKind, idx int) bool {
+	u.usedLock.Lock()
+	defer u.usedLock.Unlock()
+
+	_, found := u.used[configUsageEntry{kind, idx}]
+	return found
+}
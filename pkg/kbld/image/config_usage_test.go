@@ -0,0 +1,80 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+func TestConfigUsage(t *testing.T) {
+	configYAML := `
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+overrides:
+- image: app
+  newImage: app-src
+- image: unused
+  newImage: other
+sources:
+- image: app-src
+  path: src/app
+- image: stale
+  path: src/stale
+destinations:
+- image: app-src
+  newImage: registry.example.com/app-prod
+  when:
+    env:
+      KBLD_TEST_CONFIG_USAGE_STAGE: prod
+- image: app-src
+  newImage: registry.example.com/app
+- image: nginx
+  newImage: registry.example.com/nginx
+- image: stale
+  newImage: registry.example.com/stale
+`
+
+	rs, err := ctlres.NewResourcesFromBytes([]byte(configYAML))
+	require.NoError(t, err)
+
+	_, conf, err := ctlconf.NewConfFromResources(rs)
+	require.NoError(t, err)
+
+	t.Setenv("KBLD_TEST_CONFIG_USAGE_STAGE", "dev")
+
+	usage := ctlimg.NewConfigUsage()
+
+	factory := ctlimg.NewFactory(ctlimg.FactoryOpts{Conf: conf, AllowedToBuild: true, ConfigUsage: usage},
+		ctlreg.Registry{}, ctllog.NewLogger(io.Discard))
+
+	for _, url := range []string{"app", "nginx"} {
+		_, err := factory.Plan(url)
+		require.NoError(t, err)
+	}
+
+	unusedOverrides := usage.UnusedOverrides(conf)
+	require.Len(t, unusedOverrides, 1)
+	assert.Equal(t, "unused", unusedOverrides[0].Image)
+
+	unusedSources := usage.UnusedSources(conf)
+	require.Len(t, unusedSources, 1)
+	assert.Equal(t, "stale", unusedSources[0].Image)
+
+	// Destination of nginx only applies to built images by default
+	// and prod destination of app does not match its conditions
+	unusedDsts := usage.UnusedDestinations(conf)
+	require.Len(t, unusedDsts, 3)
+	assert.Equal(t, "registry.example.com/app-prod", unusedDsts[0].NewImage)
+	assert.Equal(t, "nginx", unusedDsts[1].Image)
+	assert.Equal(t, "stale", unusedDsts[2].Image)
+}
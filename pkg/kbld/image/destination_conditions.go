@@ -0,0 +1,69 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+)
+
+// DestinationConditions decides if destination should be used for current run
+type DestinationConditions struct {
+	conds     *ctlconf.ImageDestinationConditions
+	dirPath   string
+	lookupEnv func(string) (string, bool)
+}
+
+func NewDestinationConditions(conds *ctlconf.ImageDestinationConditions, dirPath string) DestinationConditions {
+	return DestinationConditions{conds, dirPath, os.LookupEnv}
+}
+
+func NewDestinationConditionsWithEnv(conds *ctlconf.ImageDestinationConditions,
+	dirPath string, lookupEnv func(string) (string, bool)) DestinationConditions {
+
+	return DestinationConditions{conds, dirPath, lookupEnv}
+}
+
+func (c DestinationConditions) Matches() (bool, error) {
+	if c.conds == nil {
+		return true, nil
+	}
+
+	for name, pattern := range c.conds.Env {
+		val, _ := c.lookupEnv(name)
+
+		matched, err := path.Match(pattern, val)
+		if err != nil {
+			return false, fmt.Errorf("Matching env variable '%s': %s", name, err)
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+
+	if len(c.conds.GitBranch) > 0 {
+		gitRepo := NewGitRepo(c.dirPath)
+		if !gitRepo.IsValid() {
+			return false, nil
+		}
+
+		branch, err := gitRepo.HeadBranch()
+		if err != nil {
+			return false, err
+		}
+
+		matched, err := path.Match(c.conds.GitBranch, branch)
+		if err != nil {
+			return false, fmt.Errorf("Matching git branch: %s", err)
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,39 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+)
+
+func TestDestinationConditionsEnv(t *testing.T) {
+	env := map[string]string{"CI": "true", "STAGE": "prod-eu"}
+	lookupEnv := func(name string) (string, bool) {
+		val, found := env[name]
+		return val, found
+	}
+
+	cases := []struct {
+		Conds    *ctlconf.ImageDestinationConditions
+		Expected bool
+	}{
+		{nil, true},
+		{&ctlconf.ImageDestinationConditions{}, true},
+		{&ctlconf.ImageDestinationConditions{Env: map[string]string{"CI": "true"}}, true},
+		{&ctlconf.ImageDestinationConditions{Env: map[string]string{"STAGE": "prod-*"}}, true},
+		{&ctlconf.ImageDestinationConditions{Env: map[string]string{"CI": "true", "STAGE": "dev"}}, false},
+		{&ctlconf.ImageDestinationConditions{Env: map[string]string{"MISSING": "?*"}}, false},
+	}
+
+	for _, tc := range cases {
+		matched, err := ctlimg.NewDestinationConditionsWithEnv(tc.Conds, ".", lookupEnv).Matches()
+		require.NoError(t, err)
+		assert.Equal(t, tc.Expected, matched, "conditions: %#v", tc.Conds)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"fmt"
+	"time"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	regtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	ctlsign "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/signing"
+)
+
+// Details describes image as found in registry. For image indexes,
+// size and layer count are summed and creation time is the latest
+// across platform images; labels are taken from the first platform image.
+type Details struct {
+	URL            string
+	Digest         string
+	MediaType      string
+	Platforms      []string
+	CompressedSize int64
+	Layers         int
+	Created        time.Time
+	Labels         map[string]string
+	Signed         bool
+}
+
+// DetailsFetcher collects image details from registry
+type DetailsFetcher struct {
+	registry ctlreg.Registry
+}
+
+func NewDetailsFetcher(registry ctlreg.Registry) DetailsFetcher {
+	return DetailsFetcher{registry}
+}
+
+func (f DetailsFetcher) Fetch(url string) (Details, error) {
+	ref, err := regname.ParseReference(url, regname.WeakValidation)
+	if err != nil {
+		return Details{}, err
+	}
+
+	desc, err := f.registry.Generic(ref)
+	if err != nil {
+		return Details{}, fmt.Errorf("Getting image '%s': %s", url, err)
+	}
+
+	digestRef := ref.Context().Digest(desc.Digest.String())
+
+	details := Details{
+		URL:       url,
+		Digest:    desc.Digest.String(),
+		MediaType: string(desc.MediaType),
+		Platforms: []string{},
+		Labels:    map[string]string{},
+	}
+
+	switch desc.MediaType {
+	case regtypes.OCIImageIndex, regtypes.DockerManifestList:
+		idx, err := f.registry.Index(digestRef)
+		if err != nil {
+			return Details{}, err
+		}
+		idxManifest, err := idx.IndexManifest()
+		if err != nil {
+			return Details{}, err
+		}
+
+		for _, manifest := range idxManifest.Manifests {
+			// Skip nested indexes and non-image manifests (e.g. attestations)
+			if !manifest.MediaType.IsImage() || manifest.Platform == nil || manifest.Platform.OS == "unknown" {
+				continue
+			}
+
+			details.Platforms = append(details.Platforms, platformString(*manifest.Platform))
+
+			platformDetails := Details{Labels: map[string]string{}}
+
+			err := f.addImageDetails(ref.Context().Digest(manifest.Digest.String()), &platformDetails)
+			if err != nil {
+				return Details{}, err
+			}
+
+			details.CompressedSize += platformDetails.CompressedSize
+			details.Layers += platformDetails.Layers
+			if platformDetails.Created.After(details.Created) {
+				details.Created = platformDetails.Created
+			}
+			if len(details.Labels) == 0 {
+				details.Labels = platformDetails.Labels
+			}
+		}
+
+	default:
+		err := f.addImageDetails(digestRef, &details)
+		if err != nil {
+			return Details{}, err
+		}
+	}
+
+	details.Signed, err = f.signed(digestRef)
+	if err != nil {
+		return Details{}, err
+	}
+
+	return details, nil
+}
+
+func (f DetailsFetcher) addImageDetails(ref regname.Digest, details *Details) error {
+	img, err := f.registry.Image(ref)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return err
+	}
+
+	details.CompressedSize += manifest.Config.Size
+	for _, layer := range manifest.Layers {
+		details.CompressedSize += layer.Size
+	}
+	details.Layers += len(manifest.Layers)
+
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return err
+	}
+
+	details.Created = cfg.Created.Time
+
+	if platform := cfg.Platform(); platform != nil && len(details.Platforms) == 0 {
+		details.Platforms = append(details.Platforms, platformString(*platform))
+	}
+	for k, v := range cfg.Config.Labels {
+		details.Labels[k] = v
+	}
+
+	return nil
+}
+
+// signed checks for cosign signature tag or notation signature referrers
+func (f DetailsFetcher) signed(ref regname.Digest) (bool, error) {
+	_, err := f.registry.Generic(ctlsign.SignatureTag(ref))
+	if err == nil {
+		return true, nil
+	}
+	if !ctlreg.IsNotFoundErr(err) {
+		return false, fmt.Errorf("Checking signature of '%s': %s", ref.Name(), err)
+	}
+
+	descs, err := f.registry.Referrers(ref)
+	if err != nil {
+		// Not all registries support referrers API
+		return false, nil
+	}
+
+	for _, desc := range descs {
+		if desc.ArtifactType == ctlsign.NotationSignatureArtifactType {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func platformString(platform regv1.Platform) string {
+	result := platform.OS + "/" + platform.Architecture
+	if len(platform.Variant) > 0 {
+		result += "/" + platform.Variant
+	}
+	return result
+}
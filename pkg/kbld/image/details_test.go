@@ -0,0 +1,236 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image_test
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	regtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	ctlsign "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/signing"
+)
+
+// detailsRegistry serves manifests and blobs added by tests of single repository ('app')
+type detailsRegistry struct {
+	manifests map[string]detailsManifest
+	blobs     map[string][]byte
+	referrers map[string][]regv1.Descriptor
+}
+
+type detailsManifest struct {
+	mediaType regtypes.MediaType
+	bytes     []byte
+}
+
+func (r *detailsRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	path := strings.TrimPrefix(req.URL.Path, "/v2/app/")
+
+	switch {
+	case req.URL.Path == "/v2/":
+
+	case strings.HasPrefix(path, "manifests/"):
+		manifest, found := r.manifests[strings.TrimPrefix(path, "manifests/")]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", string(manifest.mediaType))
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(manifest.bytes)))
+		w.Header().Set("Docker-Content-Digest", detailsDigest(manifest.bytes))
+		if req.Method == http.MethodGet {
+			w.Write(manifest.bytes)
+		}
+
+	case strings.HasPrefix(path, "blobs/"):
+		blob, found := r.blobs[strings.TrimPrefix(path, "blobs/")]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(blob)
+
+	case strings.HasPrefix(path, "referrers/"):
+		descs, found := r.referrers[strings.TrimPrefix(path, "referrers/")]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", string(regtypes.OCIImageIndex))
+		json.NewEncoder(w).Encode(regv1.IndexManifest{SchemaVersion: 2, MediaType: regtypes.OCIImageIndex, Manifests: descs})
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// addManifest stores manifest under its digest (and given tags) and returns its descriptor
+func (r *detailsRegistry) addManifest(t *testing.T, mediaType regtypes.MediaType, manifest interface{}, tags ...string) regv1.Descriptor {
+	bs, err := json.Marshal(manifest)
+	require.NoError(t, err)
+
+	digest := detailsDigest(bs)
+	for _, key := range append([]string{digest}, tags...) {
+		r.manifests[key] = detailsManifest{mediaType, bs}
+	}
+
+	return regv1.Descriptor{MediaType: mediaType, Size: int64(len(bs)), Digest: regv1.Hash{Algorithm: "sha256", Hex: strings.TrimPrefix(digest, "sha256:")}}
+}
+
+// addImage stores image manifest with config and layers of given sizes (layer blobs are not served)
+func (r *detailsRegistry) addImage(t *testing.T, created time.Time, labels map[string]string, layerSizes []int64, tags ...string) regv1.Descriptor {
+	configBs, err := json.Marshal(regv1.ConfigFile{
+		Architecture: "amd64",
+		OS:           "linux",
+		Created:      regv1.Time{Time: created},
+		Config:       regv1.Config{Labels: labels},
+	})
+	require.NoError(t, err)
+
+	configDigest := detailsDigest(configBs)
+	r.blobs[configDigest] = configBs
+
+	manifest := regv1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     regtypes.OCIManifestSchema1,
+		Config:        regv1.Descriptor{MediaType: regtypes.OCIConfigJSON, Size: int64(len(configBs)), Digest: detailsHash(configBs)},
+	}
+	for i, size := range layerSizes {
+		manifest.Layers = append(manifest.Layers, regv1.Descriptor{
+			MediaType: regtypes.OCILayer,
+			Size:      size,
+			Digest:    detailsHash([]byte(fmt.Sprintf("%s-layer-%d", configDigest, i))),
+		})
+	}
+
+	return r.addManifest(t, regtypes.OCIManifestSchema1, manifest, tags...)
+}
+
+func (r *detailsRegistry) configSize(t *testing.T, desc regv1.Descriptor) int64 {
+	var manifest regv1.Manifest
+	require.NoError(t, json.Unmarshal(r.manifests[desc.Digest.String()].bytes, &manifest))
+	return manifest.Config.Size
+}
+
+func detailsHash(bs []byte) regv1.Hash {
+	return regv1.Hash{Algorithm: "sha256", Hex: fmt.Sprintf("%x", sha256.Sum256(bs))}
+}
+
+func detailsDigest(bs []byte) string {
+	return detailsHash(bs).String()
+}
+
+func TestDetailsFetcher(t *testing.T) {
+	reg := &detailsRegistry{
+		manifests: map[string]detailsManifest{},
+		blobs:     map[string][]byte{},
+		referrers: map[string][]regv1.Descriptor{},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(reg.serve))
+	defer server.Close()
+
+	registry, err := ctlreg.NewRegistry(ctlreg.Opts{EnvAuthPrefix: "KBLD_TEST_DETAILS", Insecure: true})
+	require.NoError(t, err)
+
+	repo := strings.TrimPrefix(server.URL, "http://") + "/app"
+	fetcher := ctlimg.NewDetailsFetcher(registry)
+
+	jan := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("describes image and detects cosign signature", func(t *testing.T) {
+		imgDesc := reg.addImage(t, jan, map[string]string{"team": "core"}, []int64{100, 200}, "single")
+		reg.addManifest(t, regtypes.OCIManifestSchema1, regv1.Manifest{SchemaVersion: 2},
+			ctlsign.TagForDigest(imgDesc.Digest.String(), "sig"))
+
+		details, err := fetcher.Fetch(repo + ":single")
+		require.NoError(t, err)
+
+		assert.Equal(t, ctlimg.Details{
+			URL:            repo + ":single",
+			Digest:         imgDesc.Digest.String(),
+			MediaType:      string(regtypes.OCIManifestSchema1),
+			Platforms:      []string{"linux/amd64"},
+			CompressedSize: reg.configSize(t, imgDesc) + 300,
+			Layers:         2,
+			Created:        jan,
+			Labels:         map[string]string{"team": "core"},
+			Signed:         true,
+		}, details)
+	})
+
+	t.Run("aggregates platform images of index and skips attestations", func(t *testing.T) {
+		amd64Desc := reg.addImage(t, jan, map[string]string{"team": "core"}, []int64{100, 200})
+		amd64Desc.Platform = &regv1.Platform{OS: "linux", Architecture: "amd64"}
+
+		arm64Desc := reg.addImage(t, mar, map[string]string{"team": "arm"}, []int64{50})
+		arm64Desc.Platform = &regv1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}
+
+		// Attestation manifests (e.g. added by buildx) use unknown platform
+		attDesc := reg.addImage(t, mar.Add(time.Hour), map[string]string{"attestation": "true"}, []int64{1000})
+		attDesc.Platform = &regv1.Platform{OS: "unknown", Architecture: "unknown"}
+
+		idxDesc := reg.addManifest(t, regtypes.OCIImageIndex, regv1.IndexManifest{
+			SchemaVersion: 2,
+			MediaType:     regtypes.OCIImageIndex,
+			Manifests:     []regv1.Descriptor{amd64Desc, arm64Desc, attDesc},
+		}, "multi")
+
+		details, err := fetcher.Fetch(repo + ":multi")
+		require.NoError(t, err)
+
+		assert.Equal(t, ctlimg.Details{
+			URL:            repo + ":multi",
+			Digest:         idxDesc.Digest.String(),
+			MediaType:      string(regtypes.OCIImageIndex),
+			Platforms:      []string{"linux/amd64", "linux/arm64/v8"},
+			CompressedSize: reg.configSize(t, amd64Desc) + 300 + reg.configSize(t, arm64Desc) + 50,
+			Layers:         3,
+			Created:        mar,
+			Labels:         map[string]string{"team": "core"},
+			Signed:         false,
+		}, details)
+	})
+
+	t.Run("detects notation signature referrers", func(t *testing.T) {
+		imgDesc := reg.addImage(t, jan, nil, []int64{100}, "notation")
+
+		details, err := fetcher.Fetch(repo + ":notation")
+		require.NoError(t, err)
+		assert.False(t, details.Signed)
+
+		reg.referrers[imgDesc.Digest.String()] = []regv1.Descriptor{{
+			MediaType: regtypes.OCIManifestSchema1, Size: 100, Digest: detailsHash([]byte("sbom")), ArtifactType: "application/spdx+json",
+		}}
+
+		details, err = fetcher.Fetch(repo + ":notation")
+		require.NoError(t, err)
+		assert.False(t, details.Signed)
+
+		reg.referrers[imgDesc.Digest.String()] = append(reg.referrers[imgDesc.Digest.String()], regv1.Descriptor{
+			MediaType: regtypes.OCIManifestSchema1, Size: 100, Digest: detailsHash([]byte("sig")), ArtifactType: ctlsign.NotationSignatureArtifactType,
+		})
+
+		details, err = fetcher.Fetch(repo + ":notation")
+		require.NoError(t, err)
+		assert.True(t, details.Signed)
+	})
+
+	t.Run("fails for missing image", func(t *testing.T) {
+		_, err := fetcher.Fetch(repo + ":missing")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Getting image '"+repo+":missing'")
+	})
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DigestCache persists results that only depend on content addressed
+// data (e.g. which child manifest of an index matches platform),
+// so that repeated runs do not need to fetch the same manifests again.
+// All methods are safe to call on nil DigestCache (caching is disabled).
+type DigestCache struct {
+	path string
+
+	entries     map[string]string
+	dirty       bool
+	entriesLock sync.Mutex
+}
+
+type digestCacheFile struct {
+	Entries map[string]string `json:"entries"`
+}
+
+// DefaultDigestCachePath returns path within user's cache directory
+func DefaultDigestCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("Determining cache directory: %s", err)
+	}
+	return filepath.Join(dir, "kbld", "digests.json"), nil
+}
+
+// NewDigestCache loads cache from given path (missing or corrupted file results in an empty cache)
+func NewDigestCache(path string) *DigestCache {
+	cache := &DigestCache{path: path, entries: map[string]string{}}
+
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return cache
+	}
+
+	var file digestCacheFile
+
+	err = json.Unmarshal(bs, &file)
+	if err == nil && file.Entries != nil {
+		cache.entries = file.Entries
+	}
+
+	return cache
+}
+
+func (c *DigestCache) Get(key string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+
+	c.entriesLock.Lock()
+	defer c.entriesLock.Unlock()
+
+	val, found := c.entries[key]
+	return val, found
+}
+
+func (c *DigestCache) Set(key, val string) {
+	if c == nil {
+		return
+	}
+
+	c.entriesLock.Lock()
+	defer c.entriesLock.Unlock()
+
+	if existingVal, found := c.entries[key]; !found || existingVal != val {
+		c.entries[key] = val
+		c.dirty = true
+	}
+}
+
+// Save writes cache if any entries were added
+func (c *DigestCache) Save() error {
+	if c == nil {
+		return nil
+	}
+
+	c.entriesLock.Lock()
+	defer c.entriesLock.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	bs, err := json.Marshal(digestCacheFile{c.entries})
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(c.path), 0700)
+	if err != nil {
+		return fmt.Errorf("Creating digest cache directory: %s", err)
+	}
+
+	// Write via rename so that concurrent runs never observe partial file
+	tmpPath := fmt.Sprintf("%s.%d.kbld-tmp", c.path, os.Getpid())
+
+	err = os.WriteFile(tmpPath, bs, 0600)
+	if err != nil {
+		return fmt.Errorf("Writing digest cache '%s': %s", c.path, err)
+	}
+
+	err = os.Rename(tmpPath, c.path)
+	if err != nil {
+		return fmt.Errorf("Writing digest cache '%s': %s", c.path, err)
+	}
+
+	c.dirty = false
+
+	return nil
+}
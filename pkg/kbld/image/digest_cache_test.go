@@ -0,0 +1,73 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+func TestPlatformSelectedImageUsesDigestCache(t *testing.T) {
+	const childDigest = "sha256:0000000000000000000000000000000000000000000000000000000000000002"
+
+	indexBs := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[` +
+		`{"mediaType":"application/vnd.oci.image.manifest.v1+json","size":100,"digest":"` + childDigest + `",` +
+		`"platform":{"os":"linux","architecture":"amd64"}}]}`)
+	indexDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(indexBs))
+
+	var requests int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/v2/app/manifests/"+indexDigest {
+			w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+			w.Header().Set("Docker-Content-Digest", indexDigest)
+			w.Write(indexBs)
+			return
+		}
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	registry, err := ctlreg.NewRegistry(ctlreg.Opts{EnvAuthPrefix: "KBLD_TEST_DIGEST_CACHE", Insecure: true})
+	require.NoError(t, err)
+
+	repo := strings.TrimPrefix(server.URL, "http://") + "/app"
+	selection := &ctlconf.PlatformSelection{OS: "linux", Architecture: "amd64"}
+	cachePath := filepath.Join(t.TempDir(), "kbld", "digests.json")
+
+	resolve := func(cache *ctlimg.DigestCache) string {
+		img := ctlimg.NewPlatformSelectedImage(ctlimg.NewDigestedImageFromParts(repo, indexDigest),
+			selection, registry, cache)
+		url, origins, err := img.URL()
+		require.NoError(t, err)
+		require.Len(t, origins, 1)
+		assert.Equal(t, repo+"@"+indexDigest, origins[0].PlatformSelected.Index)
+		return url
+	}
+
+	cache := ctlimg.NewDigestCache(cachePath)
+	assert.Equal(t, repo+"@"+childDigest, resolve(cache))
+	assert.NotZero(t, requests)
+	require.NoError(t, cache.Save())
+
+	requests = 0
+
+	assert.Equal(t, repo+"@"+childDigest, resolve(ctlimg.NewDigestCache(cachePath)))
+	assert.Zero(t, requests)
+}
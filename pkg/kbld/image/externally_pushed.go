@@ -0,0 +1,65 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+// ExternallyPushedImage records push performed by a builder
+// (e.g. docker push) since it does not go through the registry
+type ExternallyPushedImage struct {
+	image    Image
+	tool     string
+	registry ctlreg.Registry
+}
+
+func NewExternallyPushedImage(image Image, tool string, registry ctlreg.Registry) ExternallyPushedImage {
+	return ExternallyPushedImage{image, tool, registry}
+}
+
+func (i ExternallyPushedImage) URL() (string, []ctlconf.Origin, error) {
+	url, origins, err := i.image.URL()
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = i.registry.RecordExternalPush(url, i.tool)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return url, origins, nil
+}
+
+// externalPushTool returns name of the tool that pushes images built from given source
+// (empty when images are pushed by kbld through the registry)
+func externalPushTool(srcConf ctlconf.Source) string {
+	switch {
+	case srcConf.KubectlBuildkit != nil:
+		return "kubectl-buildkit"
+	case srcConf.CloudBuild != nil:
+		return "cloud-build"
+	case srcConf.CodeBuild != nil:
+		return "codebuild"
+	case srcConf.Depot != nil:
+		return "depot"
+	case srcConf.Archive != nil:
+		return ""
+	case srcConf.Docker != nil && srcConf.Docker.Bake != nil:
+		return "docker-buildx-bake"
+	case srcConf.Docker != nil && srcConf.Docker.Buildx != nil:
+		switch srcConf.Docker.Buildx.OutputWithDefaults(true) {
+		case ctlconf.SourceDockerBuildxOutputLoad:
+			return "docker"
+		case ctlconf.SourceDockerBuildxOutputOCI:
+			return ""
+		default:
+			return "docker-buildx"
+		}
+	default:
+		return "docker"
+	}
+}
@@ -4,16 +4,31 @@
 package image
 
 import (
+	"context"
+	"crypto"
 	"fmt"
+	"os"
+	"time"
 
+	regname "github.com/google/go-containerregistry/pkg/name"
+
+	ctlatt "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/attestation"
+	ctlb "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder"
 	ctlbbz "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/bazel"
+	ctlbcb "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/cloudbuild"
+	ctlbcd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/codebuild"
+	ctlbdp "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/depot"
 	ctlbdk "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/docker"
 	ctlbko "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/ko"
 	ctlbkb "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/kubectlbuildkit"
 	ctlbpk "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/pack"
 	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
 	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlprov "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/provision"
 	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	ctlscan "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/scan"
+	ctlsign "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/signing"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
 )
 
 type Image interface {
@@ -24,19 +39,83 @@ type Factory struct {
 	opts     FactoryOpts
 	registry ctlreg.Registry
 	logger   ctllog.Logger
+	rebaser  *Rebaser
+	ecr      ctlprov.ECR
+	harbor   ctlprov.Harbor
+	quay     ctlprov.Quay
 }
 
 type FactoryOpts struct {
 	Conf                    ctlconf.Conf
 	AllowedToBuild          bool
 	GlobalPlatformSelection *ctlconf.PlatformSelection
+	// ScanReport optionally collects vulnerability scan results
+	ScanReport *ctlscan.Report
+	// VerifyTransparencyLog requires preresolved images to have
+	// their signatures included in transparency log
+	VerifyTransparencyLog bool
+	// TransparencyLogPublicKey verifies checkpoints signed by transparency log
+	TransparencyLogPublicKey crypto.PublicKey
+	// DigestCache optionally persists platform selections of image indexes
+	DigestCache *DigestCache
+	// ConfigUsage optionally records which configuration entries were matched
+	ConfigUsage *ConfigUsage
+	// BuildTimeout limits duration of builds of sources without their own timeout
+	BuildTimeout time.Duration
 }
 
 func NewFactory(opts FactoryOpts, registry ctlreg.Registry, logger ctllog.Logger) Factory {
-	return Factory{opts, registry, logger}
+	return Factory{opts, registry, logger, NewRebaser(opts.Conf.Rebases(), registry),
+		ctlprov.NewECR(logger), ctlprov.NewHarbor(logger), ctlprov.NewQuay(logger)}
+}
+
+// WithContext returns factory whose images make registry requests with given context
+func (f Factory) WithContext(ctx context.Context) Factory {
+	result := f
+	result.registry = f.registry.WithContext(ctx)
+	return result
 }
 
 func (f Factory) New(url string) Image {
+	img := Image(NewCategorizedImage(f.newImage(url), util.ErrorCategoryResolution))
+
+	if len(f.rebaser.rebases) > 0 {
+		img = NewCategorizedImage(NewRebasedImage(img, url, f.rebaser, f.registry), util.ErrorCategoryPush)
+	}
+
+	if policies := f.opts.Conf.VerificationPolicies(); len(policies) > 0 {
+		img = NewVerifiedImage(img, policies, ctlsign.NewVerifier(f.logger))
+	}
+
+	if freshnessConf := f.opts.Conf.ImageFreshness(); freshnessConf != nil {
+		img = NewFreshnessCheckedImage(img, *freshnessConf, f.registry, f.logger)
+	}
+
+	if scanConf := f.opts.Conf.VulnerabilityScan(); scanConf != nil {
+		img = NewScannedImage(img, ctlscan.NewScanner(*scanConf, f.logger), *scanConf, f.opts.ScanReport)
+	}
+
+	// Remaining failures come from verification, freshness and scan checks
+	return NewCategorizedImage(img, util.ErrorCategoryPolicy)
+}
+
+// Action returns short description of how image will be processed
+func (f Factory) Action(url string) string {
+	plan, err := f.Plan(url)
+	if err != nil {
+		return "resolving"
+	}
+	switch plan.Action {
+	case PlanActionBuild:
+		return "building"
+	case PlanActionPreresolved:
+		return "preresolved"
+	default:
+		return "resolving"
+	}
+}
+
+func (f Factory) newImage(url string) Image {
 	platformSelection := f.opts.GlobalPlatformSelection
 
 	if overrideConf, found := f.shouldOverride(url); found {
@@ -50,73 +129,364 @@ func (f Factory) New(url string) Image {
 
 		if overrideConf.Preresolved {
 			// Do not support platform selection against explicitly configured image
-			return NewPreresolvedImage(url, overrideConf.ImageOrigins)
+			var preresolvedImg Image = NewPreresolvedImage(url, overrideConf.ImageOrigins)
+			if f.opts.VerifyTransparencyLog {
+				preresolvedImg = NewCategorizedImage(NewTransparencyLogVerifiedImage(
+					preresolvedImg, ctlsign.NewRekor(f.registry, f.opts.TransparencyLogPublicKey)), util.ErrorCategoryPolicy)
+			}
+			return preresolvedImg
 		}
 		if overrideConf.TagSelection != nil {
-			tagSelected := NewTagSelectedImage(url, overrideConf.TagSelection, f.registry)
-			return NewPlatformSelectedImage(tagSelected, platformSelection, f.registry)
+			srcRegistry, err := f.SourceRegistry(url)
+			if err != nil {
+				return newConfigErrImage(err)
+			}
+			tagSelected := NewTagSelectedImage(url, overrideConf.TagSelection, srcRegistry)
+			return NewPlatformSelectedImage(tagSelected, platformSelection, srcRegistry, f.opts.DigestCache)
 		}
 		// Continue on with potentially changed url or platform selection
 	}
 
 	if srcConf, found := f.shouldBuild(url); found {
 		if !f.opts.AllowedToBuild {
-			return NewErrImage(fmt.Errorf("Building of images is disallowed (tried to build '%s' because a source was configured for it)", url))
+			return newConfigErrImage(fmt.Errorf("Building of images is disallowed (tried to build '%s' because a source was configured for it)", url))
+		}
+
+		srcConf, err := AutodetectBuilder(srcConf)
+		if err != nil {
+			return newConfigErrImage(err)
+		}
+
+		imgDstConf, err := f.optionalPushConf(url, srcConf.Path, true)
+		if err != nil {
+			return newConfigErrImage(err)
 		}
 
-		imgDstConf := f.optionalPushConf(url)
+		var dstRegistry ctlreg.Registry
+
+		if imgDstConf != nil {
+			dstRegistry, err = f.DestinationRegistry(*imgDstConf)
+			if err != nil {
+				return newConfigErrImage(err)
+			}
+		} else if srcConf.CloudBuild != nil || srcConf.CodeBuild != nil {
+			return newConfigErrImage(fmt.Errorf("Expected image destination to be configured for '%s' "+
+				"to build with remote builder", url))
+		} else if srcConf.Archive != nil {
+			return newConfigErrImage(fmt.Errorf("Expected image destination to be configured for '%s' "+
+				"to push archive", url))
+		} else if srcConf.Provenance != nil || srcConf.SBOM != nil {
+			return newConfigErrImage(fmt.Errorf("Expected image destination to be configured for '%s' "+
+				"to generate provenance or SBOM", url))
+		} else if srcConf.RegistryCache != nil {
+			return newConfigErrImage(fmt.Errorf("Expected image destination to be configured for '%s' "+
+				"to use registry cache", url))
+		} else if srcConf.Docker != nil && srcConf.Docker.Buildx != nil &&
+			srcConf.Docker.Buildx.OutputWithDefaults(false) != ctlconf.SourceDockerBuildxOutputLoad {
+			return newConfigErrImage(fmt.Errorf("Expected image destination to be configured for '%s' "+
+				"to push image built with '%s' buildx output", url, *srcConf.Docker.Buildx.Output))
+		}
 
-		docker := ctlbdk.New(f.logger)
+		buildTimeout, err := f.buildTimeout(srcConf)
+		if err != nil {
+			return newConfigErrImage(err)
+		}
+
+		// Configuration errors are returned above so that
+		// built image is always responsible for cancelling build
+		buildCtx, cancelBuild := f.buildContext(buildTimeout)
+
+		invocations := ctlb.NewInvocations()
+		intermediateTag := ctlb.NewIntermediateTag()
+		env := ctlb.NewEnv(srcConf.Env, os.Environ()).WithContext(buildCtx).
+			WithInvocations(invocations).WithIntermediateTag(intermediateTag)
+
+		docker := ctlbdk.New(env, f.logger)
 		dockerBuildx := ctlbdk.NewBuildx(docker, f.logger)
+		dockerBake := ctlbdk.NewBake(docker, f.logger)
 		pack := ctlbpk.NewPack(docker, f.logger)
-		kubectlBuildkit := ctlbkb.NewKubectlBuildkit(f.logger)
-		ko := ctlbko.NewKo(f.logger)
+		kubectlBuildkit := ctlbkb.NewKubectlBuildkit(env, f.logger)
+		ko := ctlbko.NewKo(env, f.logger)
 		bazel := ctlbbz.NewBazel(docker, f.logger)
+		cloudBuild := ctlbcb.NewCloudBuild(env, f.logger)
+		codeBuild := ctlbcd.NewCodeBuild(env, f.logger)
+		depot := ctlbdp.NewDepot(docker, f.logger)
+
+		baseImages := NewBaseImages(f.registry, f.opts.Conf.VerificationPolicies(), ctlsign.NewVerifier(f.logger))
+
+		builtImage := NewBuiltImage(url, srcConf, imgDstConf,
+			docker, dockerBuildx, dockerBake, pack, kubectlBuildkit, ko, bazel, cloudBuild, codeBuild, depot, baseImages,
+			NewArchivePusher(dstRegistry, intermediateTag, f.logger), invocations, intermediateTag)
 
-		var builtImg Image = NewBuiltImage(url, srcConf, imgDstConf,
-			docker, dockerBuildx, pack, kubectlBuildkit, ko, bazel)
+		var builtImg Image = builtImage
+		if srcConf.Retry != nil {
+			builtImg = NewRetriedBuildImage(builtImage, url, *srcConf.Retry, buildCtx, f.logger)
+		}
+		builtImg = NewBuildTimeoutImage(builtImg, url, buildTimeout, buildCtx, cancelBuild)
+		builtImg = NewCategorizedImage(builtImg, util.ErrorCategoryBuild)
 
 		if imgDstConf != nil {
-			builtImg = NewTaggedImage(builtImg, *imgDstConf, f.registry)
+			if tool := externalPushTool(srcConf); len(tool) > 0 {
+				builtImg = NewExternallyPushedImage(builtImg, tool, dstRegistry)
+			}
+			if imgDstConf.SquashLayers {
+				builtImg = NewSquashedImage(builtImg, *imgDstConf, dstRegistry)
+			}
+			if srcConf.RegistryCache != nil {
+				builtImg = NewRegistryCachedImage(builtImg, builtImage, *imgDstConf, dstRegistry, f.logger)
+			}
+			if imgDstConf.IntermediateTag != nil && imgDstConf.IntermediateTag.GarbageCollect {
+				builtImg = NewIntermediateTagsCollectedImage(builtImg, *imgDstConf, intermediateTag, dstRegistry, f.logger)
+			}
+			builtImg = NewTaggedImage(builtImg, *imgDstConf, dstRegistry)
+			builtImg = NewMultiDestinationImage(builtImg, *imgDstConf, dstRegistry)
+			builtImg = f.optionallySigned(builtImg, dstRegistry)
+			if srcConf.Provenance != nil {
+				builtImg = NewProvenanceImage(builtImg, srcConf, dstRegistry, f.optionalSigner(dstRegistry))
+			}
+			if srcConf.SBOM != nil {
+				builtImg = NewSBOMImage(builtImg, *srcConf.SBOM,
+					ctlatt.NewSBOMGenerator(f.logger), f.optionalSigner(dstRegistry))
+			}
+			builtImg = NewPostPushImage(builtImg, *imgDstConf, f.logger)
+			if imgDstConf.ECR != nil || len(imgDstConf.PrePush) > 0 {
+				builtImg = NewProvisionedImage(builtImg, *imgDstConf, f.ecr, f.harbor, f.quay, f.logger)
+			}
+			builtImg = NewCategorizedImage(builtImg, util.ErrorCategoryPush)
 		}
-		return NewPlatformSelectedImage(builtImg, platformSelection, f.registry)
+		return NewPlatformSelectedImage(builtImg, platformSelection, f.registry, f.opts.DigestCache)
+	}
+
+	srcRegistry, err := f.SourceRegistry(url)
+	if err != nil {
+		return newConfigErrImage(err)
 	}
 
 	var resolvedImg Image
 	if digestedImage := MaybeNewDigestedImage(url); digestedImage != nil {
 		resolvedImg = digestedImage
 	} else {
-		resolvedImg = NewResolvedImage(url, f.registry)
+		resolvedImg = NewResolvedImage(url, srcRegistry)
+		if localConf, found := f.shouldResolveLocally(url); found {
+			resolvedImg = NewLocalStoreImage(resolvedImg, url, localConf, ctlb.Env{}, f.logger)
+		}
+	}
+	resolvedImg = NewCategorizedImage(NewPlatformSelectedImage(resolvedImg, platformSelection, srcRegistry, f.opts.DigestCache),
+		util.ErrorCategoryResolution)
+
+	imgDstConf, err := f.optionalPushConf(url, ".", false)
+	if err != nil {
+		return newConfigErrImage(err)
 	}
-	return NewPlatformSelectedImage(resolvedImg, platformSelection, f.registry)
+	if imgDstConf == nil {
+		imgDstConf, err = f.optionalPromotionConf(url)
+		if err != nil {
+			return newConfigErrImage(err)
+		}
+	}
+
+	if imgDstConf != nil {
+		// Destination credentials are preferred, though
+		// images are still pulled using source credentials
+		dstRegistry, err := f.withAuth(srcRegistry, imgDstConf.Auth, "destination auth")
+		if err != nil {
+			return newConfigErrImage(err)
+		}
+		resolvedImg = NewPromotedImage(resolvedImg, *imgDstConf, dstRegistry)
+		if imgDstConf.SquashLayers {
+			resolvedImg = NewSquashedImage(resolvedImg, *imgDstConf, dstRegistry)
+		}
+		resolvedImg = NewTaggedImage(resolvedImg, *imgDstConf, dstRegistry)
+		resolvedImg = NewMultiDestinationImage(resolvedImg, *imgDstConf, dstRegistry)
+		resolvedImg = f.optionallySigned(resolvedImg, dstRegistry)
+		resolvedImg = NewPostPushImage(resolvedImg, *imgDstConf, f.logger)
+		if imgDstConf.ECR != nil || len(imgDstConf.PrePush) > 0 {
+			resolvedImg = NewProvisionedImage(resolvedImg, *imgDstConf, f.ecr, f.harbor, f.quay, f.logger)
+		}
+		resolvedImg = NewCategorizedImage(resolvedImg, util.ErrorCategoryPush)
+	}
+
+	return resolvedImg
 }
 
 func (f Factory) shouldOverride(url string) (ctlconf.ImageOverride, bool) {
 	urlMatcher := Matcher{url}
-	for _, override := range f.opts.Conf.ImageOverrides() {
+	for i, override := range f.opts.Conf.ImageOverrides() {
 		if urlMatcher.Matches(override.ImageRef) {
+			f.opts.ConfigUsage.record(configUsageOverride, i)
 			return override, true
 		}
 	}
 	return ctlconf.ImageOverride{}, false
 }
 
+func (f Factory) shouldResolveLocally(url string) (ctlconf.LocalImage, bool) {
+	urlMatcher := Matcher{url}
+	for _, localImage := range f.opts.Conf.LocalImages() {
+		if localImage.MatchesAll() || urlMatcher.Matches(localImage.ImageRef) {
+			return localImage, true
+		}
+	}
+	return ctlconf.LocalImage{}, false
+}
+
+// buildTimeout returns timeout of source falling back on global one
+func (f Factory) buildTimeout(srcConf ctlconf.Source) (time.Duration, error) {
+	timeout, err := srcConf.TimeoutDuration()
+	if err != nil {
+		return 0, fmt.Errorf("Parsing source timeout: %s", err)
+	}
+	if timeout > 0 {
+		return timeout, nil
+	}
+	return f.opts.BuildTimeout, nil
+}
+
+// buildContext returns context of build cancelled after timeout (if positive)
+func (f Factory) buildContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(context.Background(), timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
 func (f Factory) shouldBuild(url string) (ctlconf.Source, bool) {
 	urlMatcher := Matcher{url}
-	for _, src := range f.opts.Conf.Sources() {
-		if urlMatcher.Matches(src.ImageRef) {
-			return src, true
+	for i, src := range f.opts.Conf.Sources() {
+		if urlMatcher.MatchesSource(src) {
+			f.opts.ConfigUsage.record(configUsageSource, i)
+			return src.ForImage(url), true
 		}
 	}
 	return ctlconf.Source{}, false
 }
 
-func (f Factory) optionalPushConf(url string) *ctlconf.ImageDestination {
+// optionalPushConf finds first destination matching url and current run
+// (built images are pushed from source directory dirPath)
+func (f Factory) optionalPushConf(url, dirPath string, built bool) (*ctlconf.ImageDestination, error) {
+	urlMatcher := Matcher{url}
+	for i, dst := range f.opts.Conf.ImageDestinations() {
+		if !urlMatcher.Matches(dst.ImageRef) {
+			continue
+		}
+		if !built && dst.OnlyIfBuiltWithDefaults() {
+			continue
+		}
+		matched, err := NewDestinationConditions(dst.When, dirPath).Matches()
+		if err != nil {
+			return nil, fmt.Errorf("Evaluating destination conditions for '%s': %s", url, err)
+		}
+		if matched {
+			f.opts.ConfigUsage.record(configUsageDestination, i)
+			dst := dst // copy
+			return &dst, nil
+		}
+	}
+	return nil, nil
+}
+
+// optionalPromotionConf returns destination of internal copy of image
+// when promotion is configured and image comes from external registry
+func (f Factory) optionalPromotionConf(url string) (*ctlconf.ImageDestination, error) {
+	promotionConf := f.opts.Conf.Promotion()
+	if promotionConf == nil {
+		return nil, nil
+	}
+
+	ref, err := regname.ParseReference(url, regname.WeakValidation)
+	if err != nil {
+		return nil, fmt.Errorf("Parsing image '%s' for promotion: %s", url, err)
+	}
+
+	internalRepo, err := regname.NewRepository(promotionConf.Repository, regname.WeakValidation)
+	if err != nil {
+		return nil, fmt.Errorf("Parsing promotion repository: %s", err)
+	}
+
+	registry := ref.Context().RegistryStr()
+	if registry == internalRepo.RegistryStr() {
+		return nil, nil
+	}
+	for _, internalRegistry := range promotionConf.InternalRegistries {
+		internalReg, err := regname.NewRegistry(internalRegistry, regname.WeakValidation)
+		if err != nil {
+			return nil, fmt.Errorf("Parsing promotion internal registry: %s", err)
+		}
+		if registry == internalReg.RegistryStr() {
+			return nil, nil
+		}
+	}
+
+	return &ctlconf.ImageDestination{
+		ImageRef: ctlconf.ImageRef{Image: url},
+		NewImage: internalRepo.Name() + "/" + ref.Context().RepositoryStr(),
+		Auth:     promotionConf.Auth,
+	}, nil
+}
+
+func (f Factory) optionallySigned(img Image, registry ctlreg.Registry) Image {
+	signer := f.optionalSigner(registry)
+	if signer == nil {
+		return img
+	}
+	return NewSignedImage(img, *signer)
+}
+
+func (f Factory) optionalSigner(registry ctlreg.Registry) *ctlsign.Signer {
+	signingConf := f.opts.Conf.Signing()
+	if signingConf == nil {
+		return nil
+	}
+	signer := ctlsign.NewSigner(*signingConf, registry, f.logger)
+	return &signer
+}
+
+// DestinationRegistry returns registry configured with destination specific credentials
+func (f Factory) DestinationRegistry(imgDst ctlconf.ImageDestination) (ctlreg.Registry, error) {
+	return f.withAuth(f.registry, imgDst.Auth, "destination auth")
+}
+
+// SourceRegistry returns registry configured with credentials of first matching image credential
+func (f Factory) SourceRegistry(url string) (ctlreg.Registry, error) {
 	urlMatcher := Matcher{url}
-	for _, dst := range f.opts.Conf.ImageDestinations() {
-		if urlMatcher.Matches(dst.ImageRef) {
-			return &dst
+	for _, cred := range f.opts.Conf.Credentials() {
+		if urlMatcher.Matches(cred.ImageRef) {
+			cred := cred // copy
+			return f.withAuth(f.registry, &cred.Auth, "image credential")
+		}
+	}
+	return f.registry, nil
+}
+
+func (f Factory) withAuth(registry ctlreg.Registry, auth *ctlconf.ImageDestinationAuth, desc string) (ctlreg.Registry, error) {
+	if auth == nil {
+		return registry, nil
+	}
+
+	switch {
+	case len(auth.EnvPrefix) > 0:
+		return registry.WithKeychain(ctlreg.NewEnvKeychain(auth.EnvPrefix)), nil
+
+	case len(auth.AuthFile) > 0:
+		keychain, err := ctlreg.NewConfigFileKeychainFromPath(auth.AuthFile)
+		if err != nil {
+			return ctlreg.Registry{}, err
+		}
+		return registry.WithKeychain(keychain), nil
+
+	case auth.SecretRef != nil:
+		secret, found := f.opts.Conf.RegistrySecret(*auth.SecretRef)
+		if !found {
+			return ctlreg.Registry{}, fmt.Errorf("Expected to find secret '%s' (type %s) referenced by %s",
+				auth.SecretRef.Name, "kubernetes.io/dockerconfigjson", desc)
+		}
+		keychain, err := ctlreg.NewConfigFileKeychain(secret.DockerConfigJSON)
+		if err != nil {
+			return ctlreg.Registry{}, fmt.Errorf("Loading credentials from secret '%s': %s", secret.Name, err)
 		}
+		return registry.WithKeychain(keychain), nil
+
+	default:
+		return registry, nil
 	}
-	return nil
 }
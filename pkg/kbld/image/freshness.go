@@ -0,0 +1,200 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"fmt"
+	"time"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	regtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+// FreshnessCheckedImage warns (or fails) when resolved image is too old
+// or when tag it was resolved from has since moved
+type FreshnessCheckedImage struct {
+	image    Image
+	opts     ctlconf.ImageFreshness
+	registry ctlreg.Registry
+	logger   ctllog.Logger
+	now      func() time.Time
+}
+
+func NewFreshnessCheckedImage(image Image, opts ctlconf.ImageFreshness,
+	registry ctlreg.Registry, logger ctllog.Logger) FreshnessCheckedImage {
+
+	return FreshnessCheckedImage{image, opts, registry, logger, time.Now}
+}
+
+func (i FreshnessCheckedImage) URL() (string, []ctlconf.Origin, error) {
+	url, origins, err := i.image.URL()
+	if err != nil {
+		return "", nil, err
+	}
+
+	ref, err := regname.NewDigest(url, regname.WeakValidation)
+	if err != nil {
+		return "", nil, fmt.Errorf("Expected image '%s' to be a digest reference: %s", url, err)
+	}
+
+	var problems []string
+
+	if len(i.opts.MaxAge) > 0 {
+		problem, err := i.checkAge(ref)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(problem) > 0 {
+			problems = append(problems, problem)
+		}
+	}
+
+	if i.opts.TagMoved {
+		problem, err := i.checkTagMoved(ref, origins)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(problem) > 0 {
+			problems = append(problems, problem)
+		}
+	}
+
+	prefixedLogger := i.logger.NewPrefixedWriter(url + " | ")
+
+	for _, problem := range problems {
+		if i.opts.ActionWithDefaults() == ctlconf.ImageFreshnessActionFail {
+			return "", nil, fmt.Errorf("Expected image '%s' to be fresh: %s", url, problem)
+		}
+		prefixedLogger.WriteStr("warning: %s\n", problem)
+	}
+
+	return url, origins, nil
+}
+
+func (i FreshnessCheckedImage) checkAge(ref regname.Digest) (string, error) {
+	maxAge, err := ctlconf.ParseAge(i.opts.MaxAge)
+	if err != nil {
+		return "", err
+	}
+
+	created, err := i.created(ref)
+	if err != nil {
+		return "", fmt.Errorf("Determining creation time of '%s': %s", ref.Name(), err)
+	}
+
+	// Images built reproducibly may have zero (or epoch) timestamps
+	if created.IsZero() || created.Unix() <= 0 {
+		return fmt.Sprintf("image creation time is unknown (max age %s)", i.opts.MaxAge), nil
+	}
+
+	age := i.now().Sub(created)
+	if age > maxAge {
+		return fmt.Sprintf("image was created %s (%s ago) which is older than max age %s",
+			created.UTC().Format(time.RFC3339), age.Round(time.Hour), i.opts.MaxAge), nil
+	}
+
+	return "", nil
+}
+
+// created returns image creation time (oldest of platform images for image indexes)
+func (i FreshnessCheckedImage) created(ref regname.Digest) (time.Time, error) {
+	desc, err := i.registry.Generic(ref)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	switch desc.MediaType {
+	case regtypes.OCIImageIndex, regtypes.DockerManifestList:
+		idx, err := i.registry.Index(ref)
+		if err != nil {
+			return time.Time{}, err
+		}
+		idxManifest, err := idx.IndexManifest()
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		var oldest time.Time
+
+		for _, manifest := range idxManifest.Manifests {
+			// Skip nested indexes and non-image manifests (e.g. attestations)
+			if !manifest.MediaType.IsImage() || manifest.Platform == nil || manifest.Platform.OS == "unknown" {
+				continue
+			}
+			created, err := i.created(ref.Context().Digest(manifest.Digest.String()))
+			if err != nil {
+				return time.Time{}, err
+			}
+			if oldest.IsZero() || created.Before(oldest) {
+				oldest = created
+			}
+		}
+
+		return oldest, nil
+
+	default:
+		img, err := i.registry.Image(ref)
+		if err != nil {
+			return time.Time{}, err
+		}
+		cfg, err := img.ConfigFile()
+		if err != nil {
+			return time.Time{}, err
+		}
+		return cfg.Created.Time, nil
+	}
+}
+
+// checkTagMoved compares digest tag currently points to against digest recorded in origins
+func (i FreshnessCheckedImage) checkTagMoved(ref regname.Digest, origins []ctlconf.Origin) (string, error) {
+	var resolved *ctlconf.OriginResolved
+	var preresolved bool
+
+	expectedDigest := ref.DigestStr()
+
+	for _, origin := range origins {
+		switch {
+		case origin.Resolved != nil:
+			resolved = origin.Resolved
+		case origin.Preresolved != nil:
+			preresolved = true
+		case origin.PlatformSelected != nil && len(origin.PlatformSelected.Index) > 0:
+			// Tag points at index that platform specific image was selected from
+			idxRef, err := regname.NewDigest(origin.PlatformSelected.Index, regname.WeakValidation)
+			if err != nil {
+				return "", fmt.Errorf("Expected platform selected index '%s' to be a digest reference: %s",
+					origin.PlatformSelected.Index, err)
+			}
+			expectedDigest = idxRef.DigestStr()
+		}
+	}
+
+	// Tag could have only moved since image reference was locked
+	if !preresolved || resolved == nil {
+		return "", nil
+	}
+
+	tagRef, err := regname.NewTag(resolved.URL, regname.WeakValidation)
+	if err != nil {
+		return "", fmt.Errorf("Expected resolved origin '%s' to be a tag reference: %s", resolved.URL, err)
+	}
+
+	desc, err := i.registry.Generic(tagRef)
+	if err != nil {
+		if ctlreg.IsNotFoundErr(err) {
+			return fmt.Sprintf("tag '%s' no longer exists", tagRef.Name()), nil
+		}
+		return "", fmt.Errorf("Resolving tag '%s': %s", tagRef.Name(), err)
+	}
+
+	if desc.Digest.String() != expectedDigest {
+		return fmt.Sprintf("tag '%s' moved from '%s' to '%s' since image was locked",
+			tagRef.Name(), expectedDigest, desc.Digest), nil
+	}
+
+	return "", nil
+}
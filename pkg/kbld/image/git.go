@@ -50,6 +50,16 @@ func (r GitRepo) HeadSHA() (string, error) {
 	return strings.TrimSpace(stdout), nil
 }
 
+// HeadBranch returns current branch name ("HEAD" when detached)
+func (r GitRepo) HeadBranch() (string, error) {
+	stdout, stderr, err := r.runCmd([]string{"rev-parse", "--abbrev-ref", "HEAD"})
+	if err != nil {
+		return "", r.error("Checking HEAD branch: %s (stderr '%s')", err, stderr)
+	}
+
+	return strings.TrimSpace(stdout), nil
+}
+
 func (r GitRepo) HeadTags() ([]string, error) {
 	stdout, stderr, err := r.runCmd([]string{"describe", "--tags", "--exact-match", "HEAD"})
 	if err != nil {
@@ -0,0 +1,148 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	ctlb "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+// IntermediateTagTemplateData is available to intermediate tag templates
+// (e.g. `template: "build-{{.ContentHash}}"`)
+type IntermediateTagTemplateData struct {
+	TagTemplateData
+
+	// ContentHash is hex encoded sha256 of source files and configuration
+	ContentHash string
+	// Image is image name usable in a tag (e.g. app for docker.io/org/app)
+	Image string
+}
+
+// RenderIntermediateTag evaluates intermediate tag template for built image. Content hash
+// is only calculated when template refers to it since it requires reading all source files.
+func RenderIntermediateTag(intermediateTag ctlconf.ImageDestinationIntermediateTag,
+	buildSource ctlconf.Source, image string, origins []ctlconf.Origin, now time.Time) (string, error) {
+
+	tb := ctlb.TagBuilder{}
+
+	data := IntermediateTagTemplateData{
+		TagTemplateData: NewTagTemplateData(origins, now),
+		Image:           tb.TrimStr(tb.CleanStr(image[strings.LastIndex(image, "/")+1:]), 50),
+	}
+
+	if strings.Contains(intermediateTag.Template, ".ContentHash") {
+		var err error
+
+		data.ContentHash, err = SourceContentHash(buildSource)
+		if err != nil {
+			return "", fmt.Errorf("Calculating source content hash: %s", err)
+		}
+	}
+
+	tpl, err := template.New("tag").Option("missingkey=error").Parse(intermediateTag.Template)
+	if err != nil {
+		return "", fmt.Errorf("Parsing intermediate tag template '%s': %s", intermediateTag.Template, err)
+	}
+
+	var buf bytes.Buffer
+
+	err = tpl.Execute(&buf, data)
+	if err != nil {
+		return "", fmt.Errorf("Evaluating intermediate tag template '%s': %s", intermediateTag.Template, err)
+	}
+
+	tag := strings.TrimSpace(buf.String())
+
+	// "A tag ... may contain a maximum of 128 characters."
+	_, err = regname.NewTag("kbld:"+tag, regname.WeakValidation)
+	if err != nil || len(tag) == 0 || len(tag) > 128 {
+		return "", fmt.Errorf("Expected intermediate tag template '%s' to evaluate to valid tag, "+
+			"but was '%s'", intermediateTag.Template, tag)
+	}
+
+	return tag, nil
+}
+
+// IntermediateTagsCollectedImage deletes intermediate tags
+// pushed by previous builds once image is built and pushed
+type IntermediateTagsCollectedImage struct {
+	image           Image
+	imgDst          ctlconf.ImageDestination
+	intermediateTag *ctlb.IntermediateTag
+	registry        ctlreg.Registry
+	logger          ctllog.Logger
+}
+
+func NewIntermediateTagsCollectedImage(image Image, imgDst ctlconf.ImageDestination,
+	intermediateTag *ctlb.IntermediateTag, registry ctlreg.Registry, logger ctllog.Logger) IntermediateTagsCollectedImage {
+
+	return IntermediateTagsCollectedImage{image, imgDst, intermediateTag, registry, logger}
+}
+
+func (i IntermediateTagsCollectedImage) URL() (string, []ctlconf.Origin, error) {
+	url, origins, err := i.image.URL()
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Tag is not set when nothing was built (e.g. found in registry cache)
+	currentTag := i.intermediateTag.Tag()
+	if len(currentTag) == 0 {
+		return url, origins, nil
+	}
+
+	err = i.collect(currentTag)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return url, origins, nil
+}
+
+// collect deletes tags matching intermediate tag template other than current one.
+// Deletion failures are not fatal since not all registries support deleting tags.
+func (i IntermediateTagsCollectedImage) collect(currentTag string) error {
+	pattern, err := i.imgDst.IntermediateTag.Pattern()
+	if err != nil {
+		return err
+	}
+
+	repo, err := regname.NewRepository(i.imgDst.NewImage, regname.WeakValidation)
+	if err != nil {
+		return fmt.Errorf("Validating destination repository '%s': %s", i.imgDst.NewImage, err)
+	}
+
+	prefixedLogger := i.logger.NewPrefixedWriter(repo.Name() + " | ")
+
+	tags, err := i.registry.ListTags(repo)
+	if err != nil {
+		prefixedLogger.WriteStr("skipping garbage collection of intermediate tags: listing tags: %s\n", err)
+		return nil
+	}
+
+	for _, tag := range tags {
+		if tag == currentTag || !pattern.MatchString(tag) {
+			continue
+		}
+
+		err := i.registry.DeleteTag(repo.Tag(tag))
+		if err != nil {
+			prefixedLogger.WriteStr("garbage collection of intermediate tag '%s' error: %s\n", tag, err)
+			continue
+		}
+
+		prefixedLogger.WriteStr("deleted superseded intermediate tag '%s'\n", tag)
+	}
+
+	return nil
+}
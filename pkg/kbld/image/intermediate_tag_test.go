@@ -0,0 +1,51 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+)
+
+func TestRenderIntermediateTag(t *testing.T) {
+	srcDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "Dockerfile"), []byte("FROM scratch\n"), 0600))
+
+	srcConf := ctlconf.Source{Path: srcDir}
+	origins := []ctlconf.Origin{{Git: &ctlconf.OriginGit{SHA: "0123456789abcdef0123456789abcdef01234567"}}}
+	now := time.Date(2023, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	hash, err := ctlimg.SourceContentHash(srcConf)
+	require.NoError(t, err)
+
+	tag, err := ctlimg.RenderIntermediateTag(ctlconf.ImageDestinationIntermediateTag{
+		Template: "build-{{.Image}}-{{.GitShortSHA}}-{{.Timestamp}}",
+	}, srcConf, "docker.io/org/app", origins, now)
+	require.NoError(t, err)
+	assert.Equal(t, "build-app-0123456-20230506070809", tag)
+
+	tag, err = ctlimg.RenderIntermediateTag(ctlconf.ImageDestinationIntermediateTag{
+		Template: "build-{{.ContentHash}}",
+	}, srcConf, "app", origins, now)
+	require.NoError(t, err)
+	assert.Equal(t, "build-"+hash, tag)
+
+	_, err = ctlimg.RenderIntermediateTag(ctlconf.ImageDestinationIntermediateTag{
+		Template: "{{.GitTag}}",
+	}, srcConf, "app", origins, now)
+	assert.EqualError(t, err, "Expected intermediate tag template '{{.GitTag}}' to evaluate to valid tag, but was ''")
+
+	_, err = ctlimg.RenderIntermediateTag(ctlconf.ImageDestinationIntermediateTag{
+		Template: "build/{{.GitSHA}}",
+	}, srcConf, "app", origins, now)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "to evaluate to valid tag")
+}
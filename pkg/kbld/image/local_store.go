@@ -0,0 +1,178 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	ctlb "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+/*
+
+Example (nerdctl output matches docker output):
+
+$ docker image inspect registry.corp/app:dev
+[
+  {
+    "Id": "sha256:e3bdd21522d99c37f355c70bef342c99cb1e19ee4cf8014001d750a73a5b8d42",
+    "RepoTags": ["registry.corp/app:dev"],
+    "RepoDigests": ["registry.corp/app@sha256:cd6d662fcf06854810c83e4bff197ef52e65b39c13baa143107ebd32cc6f8636"],
+    ...
+  }
+]
+
+*/
+
+// LocalStoreImage resolves image via local image store (Docker daemon or containerd)
+// when image is not found in registry. Image is pushed (if allowed) when
+// it does not have repo digest of its repository.
+type LocalStoreImage struct {
+	image  Image
+	url    string
+	conf   ctlconf.LocalImage
+	env    ctlb.Env
+	logger ctllog.Logger
+}
+
+var _ Image = LocalStoreImage{}
+
+func NewLocalStoreImage(image Image, url string, conf ctlconf.LocalImage,
+	env ctlb.Env, logger ctllog.Logger) LocalStoreImage {
+
+	return LocalStoreImage{image, url, conf, env, logger}
+}
+
+func (i LocalStoreImage) URL() (string, []ctlconf.Origin, error) {
+	url, origins, err := i.image.URL()
+	if err == nil || !ctlreg.IsNotFoundErr(err) {
+		return url, origins, err
+	}
+
+	tag, tagErr := regname.NewTag(i.url, regname.WeakValidation)
+	if tagErr != nil {
+		return "", nil, tagErr
+	}
+
+	inspectData, inspectErr := i.inspect(tag.Name())
+	if inspectErr != nil {
+		return "", nil, fmt.Errorf("%s (also not found in local %s store: %s)", err, i.conf.StoreWithDefaults(), inspectErr)
+	}
+
+	origin := ctlconf.OriginLocalStore{Store: i.conf.StoreWithDefaults(), ID: inspectData.ID}
+
+	if digest, found := inspectData.RepoDigest(tag.Repository); found {
+		url, origins, err := NewDigestedImageFromParts(tag.Repository.String(), digest).URL()
+		if err != nil {
+			return "", nil, err
+		}
+
+		origins = append(origins,
+			ctlconf.Origin{Resolved: &ctlconf.OriginResolved{URL: i.url, Tag: tag.TagStr()}},
+			ctlconf.Origin{LocalStore: &origin})
+
+		return url, origins, nil
+	}
+
+	if !i.conf.Push {
+		return "", nil, fmt.Errorf("Expected local image '%s' (%s) to have repo digest of repository '%s', "+
+			"but did not (hint: enable push of local image)", i.url, inspectData.ID, tag.Repository.String())
+	}
+
+	err = i.push(tag.Name())
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Resolve pushed image same way as any other image
+	url, origins, err = i.image.URL()
+	if err != nil {
+		return "", nil, err
+	}
+
+	origin.Pushed = true
+	origins = append(origins, ctlconf.Origin{LocalStore: &origin})
+
+	return url, origins, nil
+}
+
+type localStoreInspectData struct {
+	ID          string
+	RepoDigests []string
+}
+
+// RepoDigest returns digest of image within given repository
+func (d localStoreInspectData) RepoDigest(repo regname.Repository) (string, bool) {
+	for _, repoDigest := range d.RepoDigests {
+		digestRef, err := regname.NewDigest(repoDigest, regname.WeakValidation)
+		if err != nil {
+			continue
+		}
+		if digestRef.Context().String() == repo.String() {
+			return digestRef.DigestStr(), true
+		}
+	}
+	return "", false
+}
+
+func (i LocalStoreImage) inspect(ref string) (localStoreInspectData, error) {
+	var stdoutBuf, stderrBuf bytes.Buffer
+
+	cmd := i.command("image", "inspect", ref)
+	cmd.Stdout = &stdoutBuf
+	cmd.Stderr = &stderrBuf
+
+	err := cmd.Run()
+	if err != nil {
+		return localStoreInspectData{}, fmt.Errorf("Inspecting image: %s (stderr: %s)", err, bytes.TrimSpace(stderrBuf.Bytes()))
+	}
+
+	var data []localStoreInspectData
+
+	err = json.Unmarshal(stdoutBuf.Bytes(), &data)
+	if err != nil {
+		return localStoreInspectData{}, fmt.Errorf("Unmarshaling image inspect output: %s", err)
+	}
+	if len(data) != 1 {
+		return localStoreInspectData{}, fmt.Errorf("Expected to find exactly one image, but found %d", len(data))
+	}
+
+	return data[0], nil
+}
+
+func (i LocalStoreImage) push(ref string) error {
+	urlRepo, _ := URLRepo(i.url)
+	prefixedLogger := i.logger.NewPrefixedWriter(urlRepo + " | ")
+
+	prefixedLogger.WriteStr("starting push (using local %s store): %s\n", i.conf.StoreWithDefaults(), ref)
+	defer prefixedLogger.WriteStr("finished push (using local %s store)\n", i.conf.StoreWithDefaults())
+
+	cmd := i.command("push", ref)
+	cmd.Stdout = prefixedLogger
+	cmd.Stderr = prefixedLogger
+
+	err := cmd.Run()
+	if err != nil {
+		prefixedLogger.WriteStr("push error: %s\n", err)
+		return fmt.Errorf("Pushing local image '%s': %s", ref, err)
+	}
+
+	return nil
+}
+
+// command returns docker (or nerdctl) command since nerdctl
+// is a Docker-compatible CLI for containerd
+func (i LocalStoreImage) command(args ...string) *exec.Cmd {
+	if i.conf.StoreWithDefaults() == ctlconf.LocalImageStoreContainerd {
+		return i.env.Command("nerdctl", append([]string{"--namespace", i.conf.NamespaceWithDefaults()}, args...)...)
+	}
+	return i.env.Command("docker", args...)
+}
@@ -6,7 +6,10 @@ package image
 import (
 	"fmt"
 	"regexp"
+	"strconv"
+	"strings"
 
+	regname "github.com/google/go-containerregistry/pkg/name"
 	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
 )
 
@@ -30,6 +33,14 @@ func (m Matcher) Matches(ref ctlconf.ImageRef) bool {
 	}
 }
 
+// MatchesSource matches by source's image pattern when specified
+func (m Matcher) MatchesSource(src ctlconf.Source) bool {
+	if src.HasImagePattern() {
+		return src.MatchesImagePattern(m.url)
+	}
+	return m.Matches(src.ImageRef)
+}
+
 var (
 	approximateRefRegexp = regexp.MustCompile(`\A(.+?)(:[A-Za-z0-9_\-\.]+)?(@.+:.+)?\z`)
 )
@@ -45,3 +56,31 @@ func URLRepo(url string) (string, bool) {
 	}
 	return url, false
 }
+
+// LooksLikeImageRef reports whether arbitrary value (e.g. env var value)
+// is likely to be an image reference. Value has to specify tag or digest,
+// or start with registry host, to not confuse plain words with images.
+func LooksLikeImageRef(val string) bool {
+	if len(val) == 0 || strings.ContainsAny(val, " \t\n") || strings.Contains(val, "://") {
+		return false
+	}
+
+	ref, err := regname.ParseReference(val, regname.WeakValidation)
+	if err != nil {
+		return false
+	}
+
+	switch typedRef := ref.(type) {
+	case regname.Digest:
+		return true
+	case regname.Tag:
+		if strings.HasSuffix(val, ":"+typedRef.TagStr()) {
+			// Avoid host:port values (e.g. localhost:8080)
+			_, err := strconv.Atoi(typedRef.TagStr())
+			return err != nil
+		}
+	}
+
+	pieces := strings.SplitN(val, "/", 2)
+	return len(pieces) == 2 && (strings.ContainsAny(pieces[0], ".:") || pieces[0] == "localhost")
+}
@@ -98,3 +98,26 @@ func TestMatcherMatches(t *testing.T) {
 		}
 	}
 }
+
+func TestLooksLikeImageRef(t *testing.T) {
+	exs := map[string]bool{
+		"nginx:1.25":                    true,
+		"gcr.io/proxy":                  true,
+		"localhost/app":                 true,
+		"registry.example.com:5000/app": true,
+		"app@sha256:f7988fb6c02e0ce69257d9bd9cf37ae20a60f1df7563c3a2a6abe24160306b8d": true,
+		"nginx":           false,
+		"info/debug":      false,
+		"localhost:8080":  false,
+		"/var/log":        false,
+		"http://host/app": false,
+		"two words":       false,
+		"":                false,
+	}
+
+	for val, expected := range exs {
+		if ctlimg.LooksLikeImageRef(val) != expected {
+			t.Fatalf("Expected '%s' to be image-like: %t", val, expected)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"fmt"
+	"strings"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	regtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+// MultiDestinationImage copies pushed image to additional destinations
+// and selects one of destinations to be used in the output
+type MultiDestinationImage struct {
+	image    Image
+	imgDst   ctlconf.ImageDestination
+	registry ctlreg.Registry
+}
+
+func NewMultiDestinationImage(image Image, imgDst ctlconf.ImageDestination, registry ctlreg.Registry) MultiDestinationImage {
+	return MultiDestinationImage{image, imgDst, registry}
+}
+
+func (i MultiDestinationImage) URL() (string, []ctlconf.Origin, error) {
+	url, origins, err := i.image.URL()
+	if err != nil {
+		return "", nil, err
+	}
+
+	additionalNewImages := i.imgDst.AdditionalNewImages()
+	if len(additionalNewImages) == 0 {
+		return url, origins, nil
+	}
+
+	srcRef, err := regname.NewDigest(url, regname.WeakValidation)
+	if err != nil {
+		return "", nil, fmt.Errorf("Expected pushed image '%s' to be a digest reference: %s", url, err)
+	}
+
+	outputURL := url
+	allURLs := []string{url}
+
+	for _, newImage := range additionalNewImages {
+		dstURL, err := i.copyTo(srcRef, newImage, i.taggedTags(origins))
+		if err != nil {
+			return "", nil, fmt.Errorf("Copying image to destination '%s': %s", newImage, err)
+		}
+
+		allURLs = append(allURLs, dstURL)
+
+		if newImage == i.imgDst.OutputNewImage {
+			outputURL = dstURL
+		}
+	}
+
+	origins = append(origins, ctlconf.Origin{Destinations: &ctlconf.OriginDestinations{URLs: allURLs}})
+
+	return outputURL, origins, nil
+}
+
+// taggedTags returns tags that were applied to primary destination
+func (i MultiDestinationImage) taggedTags(origins []ctlconf.Origin) []string {
+	for _, origin := range origins {
+		if origin.Tagged != nil {
+			return origin.Tagged.Tags
+		}
+	}
+	return nil
+}
+
+func (i MultiDestinationImage) copyTo(srcRef regname.Digest, newImage string, tags []string) (string, error) {
+	dstURL, err := transferImageToRepo(i.registry, srcRef, newImage, i.imgDst.Mode)
+	if err != nil {
+		return "", err
+	}
+
+	// Additional destinations receive same tags as primary destination
+	err = writeTags(i.registry, dstURL, tags, i.imgDst.ImmutableTags)
+	if err != nil {
+		return "", err
+	}
+
+	return dstURL, nil
+}
+
+// transferImageToRepo makes image available in given repository according to mode.
+// In retag mode image is expected to already be in that repository (only tags will be written).
+func transferImageToRepo(registry ctlreg.Registry, srcRef regname.Digest,
+	newImage string, mode ctlconf.ImageDestinationMode) (string, error) {
+
+	if mode != ctlconf.ImageDestinationModeRetag {
+		return copyImageToRepo(registry, srcRef, newImage)
+	}
+
+	dstRepo, err := regname.NewRepository(newImage, regname.WeakValidation)
+	if err != nil {
+		return "", err
+	}
+
+	if dstRepo.Name() != srcRef.Context().Name() {
+		return "", fmt.Errorf("Expected destination '%s' to be the same repository as image '%s' "+
+			"when using '%s' mode", dstRepo.Name(), srcRef.Name(), mode)
+	}
+
+	// Make sure manifest exists before tagging it
+	_, err = registry.Generic(srcRef)
+	if err != nil {
+		return "", err
+	}
+
+	dstURL, _, err := NewDigestedImageFromParts(dstRepo.Name(), srcRef.DigestStr()).URL()
+	return dstURL, err
+}
+
+// copyImageToRepo copies image or image index (by digest) into given repository
+func copyImageToRepo(registry ctlreg.Registry, srcRef regname.Digest, newImage string) (string, error) {
+	dstRepo, err := regname.NewRepository(newImage, regname.WeakValidation)
+	if err != nil {
+		return "", err
+	}
+
+	// Seems like AWS ECR doesnt like using digests for manifest uploads
+	uploadTagRef := dstRepo.Tag("kbld-" + strings.Replace(srcRef.DigestStr(), ":", "-", 1))
+
+	desc, err := registry.Generic(srcRef)
+	if err != nil {
+		return "", err
+	}
+
+	switch desc.MediaType {
+	case regtypes.OCIImageIndex, regtypes.DockerManifestList:
+		idx, err := registry.Index(srcRef)
+		if err != nil {
+			return "", err
+		}
+		err = registry.WriteIndex(uploadTagRef, idx)
+		if err != nil {
+			return "", err
+		}
+
+	default:
+		img, err := registry.Image(srcRef)
+		if err != nil {
+			return "", err
+		}
+		err = registry.WriteImage(uploadTagRef, img)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	dstURL, _, err := NewDigestedImageFromParts(dstRepo.Name(), srcRef.DigestStr()).URL()
+	return dstURL, err
+}
+
+// writeTags points tags at given digest url. When immutable is set,
+// all tags are checked (before any are written) to not point at a different digest.
+func writeTags(registry ctlreg.Registry, url string, tags []string, immutable bool) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	dstRef, err := regname.NewDigest(url, regname.WeakValidation)
+	if err != nil {
+		return err
+	}
+
+	if immutable {
+		for _, tag := range tags {
+			tagRef := dstRef.Context().Tag(tag)
+
+			desc, err := registry.Generic(tagRef)
+			if err != nil {
+				if ctlreg.IsNotFoundErr(err) {
+					continue
+				}
+				return fmt.Errorf("Checking existing tag '%s': %s", tagRef.Name(), err)
+			}
+
+			if desc.Digest.String() != dstRef.DigestStr() {
+				return fmt.Errorf("Expected tag '%s' to not exist or point at '%s', "+
+					"but it points at '%s' (tag would be overwritten)", tagRef.Name(), dstRef.DigestStr(), desc.Digest)
+			}
+		}
+	}
+
+	// Keep this ref separate to avoid any kind of modification
+	// when changing tag on the dst ref
+	srcRef, err := regname.NewDigest(url, regname.WeakValidation)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		err := registry.WriteTag(dstRef.Context().Tag(tag), srcRef)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
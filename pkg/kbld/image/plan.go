@@ -0,0 +1,182 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"encoding/json"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+)
+
+type PlanAction string
+
+const (
+	PlanActionBuild        PlanAction = "build"
+	PlanActionResolve      PlanAction = "resolve"
+	PlanActionPreresolved  PlanAction = "preresolved"
+	PlanActionTagSelection PlanAction = "tagSelection"
+)
+
+// Plan describes how image would be processed without
+// performing any builds or registry operations
+type Plan struct {
+	URL    string     `json:"url"`
+	Action PlanAction `json:"action"`
+	// Image is a reference after applying overrides
+	Image string `json:"image"`
+
+	Builder      string      `json:"builder,omitempty"`
+	BuildPath    string      `json:"buildPath,omitempty"`
+	BuildOptions interface{} `json:"buildOptions,omitempty"`
+
+	TagSelection      interface{}                `json:"tagSelection,omitempty"`
+	PlatformSelection *ctlconf.PlatformSelection `json:"platformSelection,omitempty"`
+
+	Destinations          []string `json:"destinations,omitempty"`
+	OutputDestination     string   `json:"outputDestination,omitempty"`
+	Tags                  []string `json:"tags,omitempty"`
+	DestinationMode       string   `json:"destinationMode,omitempty"`
+	PrePushHooks          int      `json:"prePushHooks,omitempty"`
+	PostPushHooks         int      `json:"postPushHooks,omitempty"`
+	Signed                bool     `json:"signed,omitempty"`
+	VerificationPolicies  int      `json:"verificationPolicies,omitempty"`
+	VulnerabilityScan     bool     `json:"vulnerabilityScan,omitempty"`
+	TransparencyLogVerify bool     `json:"transparencyLogVerify,omitempty"`
+}
+
+// Plan mirrors decisions made when constructing image in New
+func (f Factory) Plan(url string) (Plan, error) {
+	plan, _, err := f.plan(url)
+	return plan, err
+}
+
+// plan additionally returns destination image would be pushed to (if any)
+func (f Factory) plan(url string) (Plan, *ctlconf.ImageDestination, error) {
+	plan := Plan{
+		URL:                  url,
+		Action:               PlanActionResolve,
+		PlatformSelection:    f.opts.GlobalPlatformSelection,
+		VerificationPolicies: len(f.opts.Conf.VerificationPolicies()),
+		VulnerabilityScan:    f.opts.Conf.VulnerabilityScan() != nil,
+	}
+
+	if overrideConf, found := f.shouldOverride(url); found {
+		if len(overrideConf.NewImage) > 0 {
+			url = overrideConf.NewImage
+		}
+		if overrideConf.PlatformSelection != nil {
+			plan.PlatformSelection = overrideConf.PlatformSelection
+		}
+		if overrideConf.Preresolved {
+			plan.Action = PlanActionPreresolved
+			plan.Image = url
+			plan.PlatformSelection = nil
+			plan.TransparencyLogVerify = f.opts.VerifyTransparencyLog
+			return plan, nil, nil
+		}
+		if overrideConf.TagSelection != nil {
+			plan.Action = PlanActionTagSelection
+			plan.Image = url
+			plan.TagSelection = overrideConf.TagSelection
+			return plan, nil, nil
+		}
+	}
+
+	plan.Image = url
+
+	dirPath := "."
+	built := false
+
+	if srcConf, found := f.shouldBuild(url); found {
+		srcConf, err := AutodetectBuilder(srcConf)
+		if err != nil {
+			return Plan{}, nil, err
+		}
+
+		plan.Action = PlanActionBuild
+		plan.BuildPath = srcConf.Path
+		plan.Builder, plan.BuildOptions = planBuilder(srcConf)
+
+		dirPath = srcConf.Path
+		built = true
+	}
+
+	imgDstConf, err := f.optionalPushConf(url, dirPath, built)
+	if err != nil {
+		return Plan{}, nil, err
+	}
+	if imgDstConf == nil && !built {
+		imgDstConf, err = f.optionalPromotionConf(url)
+		if err != nil {
+			return Plan{}, nil, err
+		}
+	}
+
+	if imgDstConf != nil {
+		plan.Destinations = append([]string{imgDstConf.NewImage}, imgDstConf.AdditionalNewImages()...)
+		plan.OutputDestination = imgDstConf.NewImage
+		if len(imgDstConf.OutputNewImage) > 0 {
+			plan.OutputDestination = imgDstConf.OutputNewImage
+		}
+		plan.Tags = imgDstConf.Tags
+		plan.DestinationMode = string(imgDstConf.Mode)
+		plan.PrePushHooks = len(imgDstConf.PrePush)
+		plan.PostPushHooks = len(imgDstConf.PostPush)
+		plan.Signed = f.opts.Conf.Signing() != nil
+	}
+
+	return plan, imgDstConf, nil
+}
+
+func planBuilder(srcConf ctlconf.Source) (string, interface{}) {
+	switch {
+	case srcConf.Pack != nil:
+		return "pack", srcConf.Pack
+	case srcConf.KubectlBuildkit != nil:
+		return "kubectl-buildkit", srcConf.KubectlBuildkit
+	case srcConf.Ko != nil:
+		return "ko", srcConf.Ko
+	case srcConf.Bazel != nil:
+		return "bazel", srcConf.Bazel
+	case srcConf.CloudBuild != nil:
+		return "cloud-build", srcConf.CloudBuild
+	case srcConf.CodeBuild != nil:
+		return "codebuild", srcConf.CodeBuild
+	case srcConf.Depot != nil:
+		return "depot", srcConf.Depot
+	case srcConf.Archive != nil:
+		return "archive", srcConf.Archive
+	case srcConf.Docker != nil && srcConf.Docker.Bake != nil:
+		return "docker-buildx-bake", srcConf.Docker.Bake
+	case srcConf.Docker != nil && srcConf.Docker.Buildx != nil:
+		return "docker-buildx", srcConf.Docker.Buildx
+	case srcConf.Docker != nil:
+		return "docker", srcConf.Docker.Build
+	default:
+		return "docker", nil
+	}
+}
+
+// ProcessingKey returns identifier shared by urls that would be processed
+// identically (e.g. different references overridden to the same image)
+// so that such images could be resolved, built and pushed only once
+func (f Factory) ProcessingKey(url string) string {
+	plan, err := f.Plan(url)
+	if err != nil {
+		return url
+	}
+	// Preresolved images carry origins of their override and rebases
+	// are matched against original url, hence plan does not fully describe them
+	if plan.Action == PlanActionPreresolved || len(f.rebaser.rebases) > 0 {
+		return url
+	}
+
+	plan.URL = ""
+
+	bs, err := json.Marshal(plan)
+	if err != nil {
+		return url
+	}
+	return string(bs)
+}
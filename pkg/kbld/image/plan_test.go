@@ -0,0 +1,66 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+func TestFactoryPlan(t *testing.T) {
+	configYAML := `
+apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+sources:
+- image: app
+  path: src/app
+  docker:
+    build:
+      target: prod
+destinations:
+- image: app
+  newImage: registry.example.com/app
+  tags: [latest]
+overrides:
+- image: redis
+  newImage: index.docker.io/library/redis@sha256:0000000000000000000000000000000000000000000000000000000000000001
+  preresolved: true
+`
+
+	rs, err := ctlres.NewResourcesFromBytes([]byte(configYAML))
+	require.NoError(t, err)
+
+	_, conf, err := ctlconf.NewConfFromResources(rs)
+	require.NoError(t, err)
+
+	factory := ctlimg.NewFactory(ctlimg.FactoryOpts{Conf: conf, AllowedToBuild: true},
+		ctlreg.Registry{}, ctllog.NewLogger(io.Discard))
+
+	plan, err := factory.Plan("app")
+	require.NoError(t, err)
+	assert.Equal(t, ctlimg.PlanActionBuild, plan.Action)
+	assert.Equal(t, "docker", plan.Builder)
+	assert.Equal(t, "src/app", plan.BuildPath)
+	assert.Equal(t, []string{"registry.example.com/app"}, plan.Destinations)
+	assert.Equal(t, []string{"latest"}, plan.Tags)
+	assert.Equal(t, "building", factory.Action("app"))
+
+	plan, err = factory.Plan("redis")
+	require.NoError(t, err)
+	assert.Equal(t, ctlimg.PlanActionPreresolved, plan.Action)
+	assert.Equal(t, "index.docker.io/library/redis@sha256:0000000000000000000000000000000000000000000000000000000000000001", plan.Image)
+
+	plan, err = factory.Plan("nginx:1.17")
+	require.NoError(t, err)
+	assert.Equal(t, ctlimg.PlanActionResolve, plan.Action)
+	assert.Empty(t, plan.Destinations)
+}
@@ -4,7 +4,10 @@
 package image
 
 import (
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 
 	regname "github.com/google/go-containerregistry/pkg/name"
 	regv1 "github.com/google/go-containerregistry/pkg/v1"
@@ -13,17 +16,22 @@ import (
 	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
 )
 
+const (
+	windowsOS = "windows"
+)
+
 // PlatformSelectedImage selects specific image matching arch/platform
 type PlatformSelectedImage struct {
 	image     Image
 	selection *ctlconf.PlatformSelection
 	registry  ctlreg.Registry
+	cache     *DigestCache
 }
 
-func NewPlatformSelectedImage(image Image,
-	selection *ctlconf.PlatformSelection, registry ctlreg.Registry) PlatformSelectedImage {
+func NewPlatformSelectedImage(image Image, selection *ctlconf.PlatformSelection,
+	registry ctlreg.Registry, cache *DigestCache) PlatformSelectedImage {
 
-	return PlatformSelectedImage{image, selection, registry}
+	return PlatformSelectedImage{image, selection, registry, cache}
 }
 
 func (i PlatformSelectedImage) URL() (string, []ctlconf.Origin, error) {
@@ -42,11 +50,31 @@ func (i PlatformSelectedImage) URL() (string, []ctlconf.Origin, error) {
 		return "", nil, err
 	}
 
+	selectionBs, err := json.Marshal(i.selection)
+	if err != nil {
+		return "", nil, err
+	}
+
+	cacheKey := func(digest string) string {
+		return "platform-selection:" + digest + ":" + string(selectionBs)
+	}
+
+	// Digest references do not need to be fetched when selection was cached
+	if digestRef, ok := ref.(regname.Digest); ok {
+		if childDigest, found := i.cache.Get(cacheKey(digestRef.DigestStr())); found {
+			return i.selectedURL(ref, url, childDigest, origins)
+		}
+	}
+
 	desc, err := i.registry.Generic(ref)
 	if err != nil {
 		return "", nil, err
 	}
 
+	if childDigest, found := i.cache.Get(cacheKey(desc.Digest.String())); found {
+		return i.selectedURL(ref, url, childDigest, origins)
+	}
+
 	switch desc.MediaType {
 	case regtypes.OCIImageIndex, regtypes.DockerManifestList:
 		imgIndex, err := i.registry.Index(ref)
@@ -58,71 +86,230 @@ func (i PlatformSelectedImage) URL() (string, []ctlconf.Origin, error) {
 			return "", nil, err
 		}
 
-		var matchedMan *regv1.Descriptor
+		var matchedMans []regv1.Descriptor
+		var matchedRank int
 
+		// Only keep most preferred matches (e.g. arm/v7 over arm/v6 for arm/v7 selection)
 		for _, man := range imgIndexManifest.Manifests {
-			if man.Platform != nil && MatchesPlatformSelection(*man.Platform, *i.selection) {
-				if matchedMan != nil {
-					return "", nil, fmt.Errorf("Expected to find only one image under index '%s' with matching platform, but found more than one", url)
-				}
-				man := man // copy
-				matchedMan = &man
+			if man.Platform == nil {
+				continue
+			}
+			rank, matched := platformMatchRank(*man.Platform, *i.selection)
+			switch {
+			case !matched:
+			case len(matchedMans) == 0 || rank < matchedRank:
+				matchedMans = []regv1.Descriptor{man}
+				matchedRank = rank
+			case rank == matchedRank:
+				matchedMans = append(matchedMans, man)
 			}
 		}
 
+		matchedMan := latestWindowsRevision(matchedMans)
+		if matchedMan == nil && len(matchedMans) > 1 {
+			return "", nil, fmt.Errorf("Expected to find only one image under index '%s' with matching platform, but found more than one", url)
+		}
+		if matchedMan == nil && len(matchedMans) == 1 {
+			matchedMan = &matchedMans[0]
+		}
+
 		if matchedMan != nil {
-			newURL, newOrigins, err := NewDigestedImageFromParts(ref.Context().Name(), matchedMan.Digest.String()).URL()
-			if err != nil {
-				return "", nil, err
-			}
-			newOrigins = append(newOrigins, ctlconf.Origin{
-				PlatformSelected: &ctlconf.OriginPlatformSelected{
-					Index:        url,
-					OS:           i.selection.OS,
-					Architecture: i.selection.Architecture,
-					Variant:      i.selection.Variant,
-				},
-			})
-			return newURL, append(origins, newOrigins...), nil
+			i.cache.Set(cacheKey(desc.Digest.String()), matchedMan.Digest.String())
+			return i.selectedURL(ref, url, matchedMan.Digest.String(), origins)
 		}
 
 		return "", nil, fmt.Errorf("Expected to find one image under index '%s' with matching platform, but found none", url)
 
 	// Assume that if it's not an index, then image is all right to use
 	default:
+		// Empty value indicates that digest is not an index
+		i.cache.Set(cacheKey(desc.Digest.String()), "")
 		return url, origins, nil
 	}
 }
 
+func (i PlatformSelectedImage) selectedURL(ref regname.Reference, url, childDigest string,
+	origins []ctlconf.Origin) (string, []ctlconf.Origin, error) {
+
+	if len(childDigest) == 0 {
+		return url, origins, nil
+	}
+
+	newURL, newOrigins, err := NewDigestedImageFromParts(ref.Context().Name(), childDigest).URL()
+	if err != nil {
+		return "", nil, err
+	}
+	newOrigins = append(newOrigins, ctlconf.Origin{
+		PlatformSelected: &ctlconf.OriginPlatformSelected{
+			Index:        url,
+			OS:           i.selection.OS,
+			Architecture: i.selection.Architecture,
+			Variant:      i.selection.Variant,
+		},
+	})
+	return newURL, append(origins, newOrigins...), nil
+}
+
 // MatchesPlatformSelection checks if the given platform matches the required platforms.
 // The given platform matches the required platform if
-// - architecture and OS are identical.
-// - OS version and variant are identical if provided.
-// - features and OS features of the required platform are subsets of those of the given platform.
+//   - OS is identical and architecture is identical (after normalizing aliases, e.g. aarch64 is arm64).
+//   - variant is compatible if provided (e.g. arm/v7 runs arm/v7, arm/v6 and arm/v5 images;
+//     arm defaults to v7, arm64 to v8 and amd64 to v1 when variant is not specified).
+//   - OS version is identical if provided (Windows build number matches any of its revisions).
+//   - features and OS features of the required platform are subsets of those of the given platform.
+//
 // Adapted from https://github.com/google/go-containerregistry/blob/570ba6c88a5041afebd4599981d849af96f5dba9/pkg/v1/remote/index.go#L263
 func MatchesPlatformSelection(given regv1.Platform, required ctlconf.PlatformSelection) bool {
+	_, matched := platformMatchRank(given, required)
+	return matched
+}
+
+// platformMatchRank returns whether given platform matches required platform
+// and how preferred it is among other matches (lower is more preferred)
+func platformMatchRank(given regv1.Platform, required ctlconf.PlatformSelection) (int, bool) {
+	givenArch, givenVariant := normalizeArchVariant(given.Architecture, given.Variant)
+	requiredArch, requiredVariant := normalizeArchVariant(required.Architecture, required.Variant)
+
 	// Required fields that must be identical.
-	if given.Architecture != required.Architecture || given.OS != required.OS {
-		return false
+	if givenArch != requiredArch || given.OS != required.OS {
+		return 0, false
 	}
 
 	// Optional fields that may be empty, but must be identical if provided.
-	if required.OSVersion != "" && given.OSVersion != required.OSVersion {
-		return false
-	}
-	if required.Variant != "" && given.Variant != required.Variant {
-		return false
+	if required.OSVersion != "" && !matchesOSVersion(given, required.OSVersion) {
+		return 0, false
 	}
 
 	// Verify required platform's features are a subset of given platform's features.
 	if !isSubset(given.OSFeatures, required.OSFeatures) {
-		return false
+		return 0, false
 	}
 	if !isSubset(given.Features, required.Features) {
-		return false
+		return 0, false
 	}
 
-	return true
+	variants := compatibleVariants(requiredArch, requiredVariant)
+	for rank, variant := range variants {
+		if variant == givenVariant {
+			return rank, true
+		}
+	}
+
+	// Any variant is acceptable when it's not specified,
+	// though default and compatible variants are preferred
+	if required.Variant == "" {
+		return len(variants), true
+	}
+
+	return 0, false
+}
+
+// normalizeArchVariant maps architecture aliases (e.g. aarch64, x86_64) to names
+// used in image indexes and fills in default variant for the architecture
+func normalizeArchVariant(arch, variant string) (string, string) {
+	switch arch {
+	case "i386":
+		return "386", variant
+	case "x86_64", "x86-64", "amd64":
+		if variant == "" {
+			variant = "v1"
+		}
+		return "amd64", variant
+	case "aarch64", "arm64":
+		if variant == "" || variant == "8" {
+			variant = "v8"
+		}
+		return "arm64", variant
+	case "armhf":
+		return "arm", "v7"
+	case "armel":
+		return "arm", "v6"
+	case "arm":
+		switch variant {
+		case "":
+			variant = "v7"
+		case "5", "6", "7", "8":
+			variant = "v" + variant
+		}
+		return "arm", variant
+	default:
+		return arch, variant
+	}
+}
+
+// compatibleVariants returns variants of images that are able to run
+// on given (normalized) variant, from most to least preferred
+func compatibleVariants(arch, variant string) []string {
+	var ordered []string
+
+	switch arch {
+	case "arm":
+		ordered = []string{"v8", "v7", "v6", "v5"}
+	case "amd64":
+		ordered = []string{"v4", "v3", "v2", "v1"}
+	}
+
+	for i, orderedVariant := range ordered {
+		if orderedVariant == variant {
+			return ordered[i:]
+		}
+	}
+
+	return []string{variant}
+}
+
+// matchesOSVersion checks OS version of given platform. Windows images
+// are compatible with hosts of the same build (major.minor.build),
+// hence required version with three components (e.g. 10.0.17763)
+// matches any revision of that build (e.g. 10.0.17763.5576).
+func matchesOSVersion(given regv1.Platform, requiredOSVersion string) bool {
+	if given.OS == windowsOS && len(strings.Split(requiredOSVersion, ".")) == 3 {
+		return strings.HasPrefix(given.OSVersion+".", requiredOSVersion+".")
+	}
+	return given.OSVersion == requiredOSVersion
+}
+
+// latestWindowsRevision returns Windows image with the highest
+// OS version revision when all given images are Windows images of the
+// same build that only differ in revision; otherwise returns nil
+func latestWindowsRevision(mans []regv1.Descriptor) *regv1.Descriptor {
+	if len(mans) < 2 {
+		return nil
+	}
+
+	var latest *regv1.Descriptor
+	var latestBuild string
+	var latestRevision int
+
+	for i, man := range mans {
+		if man.Platform.OS != windowsOS {
+			return nil
+		}
+
+		parts := strings.Split(man.Platform.OSVersion, ".")
+		if len(parts) != 4 {
+			return nil
+		}
+		build := strings.Join(parts[:3], ".")
+		revision, err := strconv.Atoi(parts[3])
+		if err != nil {
+			return nil
+		}
+
+		switch {
+		case latest == nil:
+			latestBuild = build
+		case build != latestBuild || revision == latestRevision:
+			return nil
+		case revision < latestRevision:
+			continue
+		}
+
+		latest = &mans[i]
+		latestRevision = revision
+	}
+
+	return latest
 }
 
 // isSubset checks if the required array of strings is a subset of the given lst.
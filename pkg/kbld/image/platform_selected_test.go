@@ -4,11 +4,19 @@
 package image_test
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
 	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
 )
 
 // TestMatchesPlatform runs test cases on the matchesPlatform function which verifies
@@ -256,6 +264,73 @@ func TestMatchesPlatformSelection(t *testing.T) {
 			},
 			want: true,
 		},
+		{ // Windows build number (without revision) matches any revision of that build.
+			// matchesPlatform expected to return true.
+			given: v1.Platform{
+				Architecture: "amd64",
+				OS:           "windows",
+				OSVersion:    "10.0.17763.5576",
+			},
+			required: ctlconf.PlatformSelection{
+				Architecture: "amd64",
+				OS:           "windows",
+				OSVersion:    "10.0.17763",
+			},
+			want: true,
+		},
+		{ // Windows build number must match build of the image.
+			// matchesPlatform expected to return false.
+			given: v1.Platform{
+				Architecture: "amd64",
+				OS:           "windows",
+				OSVersion:    "10.0.20348.2340",
+			},
+			required: ctlconf.PlatformSelection{
+				Architecture: "amd64",
+				OS:           "windows",
+				OSVersion:    "10.0.17763",
+			},
+			want: false,
+		},
+		{ // Windows OS version with revision must be identical.
+			// matchesPlatform expected to return false.
+			given: v1.Platform{
+				Architecture: "amd64",
+				OS:           "windows",
+				OSVersion:    "10.0.17763.5576",
+			},
+			required: ctlconf.PlatformSelection{
+				Architecture: "amd64",
+				OS:           "windows",
+				OSVersion:    "10.0.17763.5122",
+			},
+			want: false,
+		},
+		{ // Older arm variants run on newer ones. matchesPlatform expected to return true.
+			given:    v1.Platform{Architecture: "arm", OS: "linux", Variant: "v6"},
+			required: ctlconf.PlatformSelection{Architecture: "arm", OS: "linux", Variant: "v7"},
+			want:     true,
+		},
+		{ // Newer arm variants do not run on older ones. matchesPlatform expected to return false.
+			given:    v1.Platform{Architecture: "arm", OS: "linux", Variant: "v7"},
+			required: ctlconf.PlatformSelection{Architecture: "arm", OS: "linux", Variant: "v6"},
+			want:     false,
+		},
+		{ // arm64 defaults to v8 and aarch64 is an alias. matchesPlatform expected to return true.
+			given:    v1.Platform{Architecture: "arm64", OS: "linux"},
+			required: ctlconf.PlatformSelection{Architecture: "aarch64", OS: "linux", Variant: "v8"},
+			want:     true,
+		},
+		{ // arm64 is not compatible with arm. matchesPlatform expected to return false.
+			given:    v1.Platform{Architecture: "arm64", OS: "linux", Variant: "v8"},
+			required: ctlconf.PlatformSelection{Architecture: "arm", OS: "linux", Variant: "v8"},
+			want:     false,
+		},
+		{ // Older amd64 microarchitecture levels run on newer ones. matchesPlatform expected to return true.
+			given:    v1.Platform{Architecture: "amd64", OS: "linux"},
+			required: ctlconf.PlatformSelection{Architecture: "x86_64", OS: "linux", Variant: "v3"},
+			want:     true,
+		},
 	}
 
 	for _, test := range tests {
@@ -265,3 +340,140 @@ func TestMatchesPlatformSelection(t *testing.T) {
 		}
 	}
 }
+
+func TestPlatformSelectedImageWindows(t *testing.T) {
+	manifest := func(digest int, os, osVersion string) string {
+		return fmt.Sprintf(`{"mediaType":"application/vnd.docker.distribution.manifest.v2+json","size":100,`+
+			`"digest":"sha256:%064d","platform":{"os":"%s","architecture":"amd64","os.version":"%s"}}`, digest, os, osVersion)
+	}
+
+	indexBs := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.list.v2+json","manifests":[` +
+		manifest(1, "linux", "") + "," +
+		manifest(2, "windows", "10.0.17763.5122") + "," +
+		manifest(3, "windows", "10.0.17763.5576") + "," +
+		manifest(4, "windows", "10.0.20348.2340") + `]}`)
+	indexDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(indexBs))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/app/manifests/"+indexDigest {
+			w.Header().Set("Content-Type", "application/vnd.docker.distribution.manifest.list.v2+json")
+			w.Header().Set("Docker-Content-Digest", indexDigest)
+			w.Write(indexBs)
+			return
+		}
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	registry, err := ctlreg.NewRegistry(ctlreg.Opts{EnvAuthPrefix: "KBLD_TEST_PLATFORM_WINDOWS", Insecure: true})
+	require.NoError(t, err)
+
+	repo := strings.TrimPrefix(server.URL, "http://") + "/app"
+
+	selectImage := func(osVersion string) (string, error) {
+		selection := &ctlconf.PlatformSelection{OS: "windows", Architecture: "amd64", OSVersion: osVersion}
+		img := ctlimg.NewPlatformSelectedImage(ctlimg.NewDigestedImageFromParts(repo, indexDigest),
+			selection, registry, ctlimg.NewDigestCache(""))
+		url, _, err := img.URL()
+		return url, err
+	}
+
+	t.Run("selects latest revision of host build", func(t *testing.T) {
+		url, err := selectImage("10.0.17763")
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("%s@sha256:%064d", repo, 3), url)
+	})
+
+	t.Run("selects exact revision", func(t *testing.T) {
+		url, err := selectImage("10.0.17763.5122")
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("%s@sha256:%064d", repo, 2), url)
+	})
+
+	t.Run("fails when images of different builds match", func(t *testing.T) {
+		_, err := selectImage("")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "found more than one")
+	})
+}
+
+func TestPlatformSelectedImageVariants(t *testing.T) {
+	manifest := func(digest int, arch, variant string) string {
+		return fmt.Sprintf(`{"mediaType":"application/vnd.oci.image.manifest.v1+json","size":100,`+
+			`"digest":"sha256:%064d","platform":{"os":"linux","architecture":"%s","variant":"%s"}}`, digest, arch, variant)
+	}
+
+	newIndex := func(manifests ...string) []byte {
+		return []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[` +
+			strings.Join(manifests, ",") + `]}`)
+	}
+
+	indexes := map[string][]byte{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if indexBs, found := indexes[strings.TrimPrefix(r.URL.Path, "/v2/app/manifests/")]; found {
+			w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+			w.Header().Set("Docker-Content-Digest", fmt.Sprintf("sha256:%x", sha256.Sum256(indexBs)))
+			w.Write(indexBs)
+			return
+		}
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	registry, err := ctlreg.NewRegistry(ctlreg.Opts{EnvAuthPrefix: "KBLD_TEST_PLATFORM_VARIANTS", Insecure: true})
+	require.NoError(t, err)
+
+	repo := strings.TrimPrefix(server.URL, "http://") + "/app"
+
+	selectImage := func(indexBs []byte, selection ctlconf.PlatformSelection) (string, error) {
+		indexDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(indexBs))
+		indexes[indexDigest] = indexBs
+
+		img := ctlimg.NewPlatformSelectedImage(ctlimg.NewDigestedImageFromParts(repo, indexDigest),
+			&selection, registry, ctlimg.NewDigestCache(""))
+		url, _, err := img.URL()
+		return url, err
+	}
+
+	armIndex := newIndex(manifest(1, "arm", "v6"), manifest(2, "arm", "v7"), manifest(3, "arm64", "v8"))
+
+	t.Run("prefers exact variant", func(t *testing.T) {
+		url, err := selectImage(armIndex, ctlconf.PlatformSelection{OS: "linux", Architecture: "arm", Variant: "v7"})
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("%s@sha256:%064d", repo, 2), url)
+	})
+
+	t.Run("prefers default variant when not specified", func(t *testing.T) {
+		url, err := selectImage(armIndex, ctlconf.PlatformSelection{OS: "linux", Architecture: "arm"})
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("%s@sha256:%064d", repo, 2), url)
+	})
+
+	t.Run("falls back to compatible variant", func(t *testing.T) {
+		url, err := selectImage(newIndex(manifest(1, "arm", "v6"), manifest(3, "arm64", "v8")),
+			ctlconf.PlatformSelection{OS: "linux", Architecture: "arm", Variant: "v7"})
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("%s@sha256:%064d", repo, 1), url)
+	})
+
+	t.Run("does not fall back to incompatible variant", func(t *testing.T) {
+		_, err := selectImage(newIndex(manifest(2, "arm", "v7")),
+			ctlconf.PlatformSelection{OS: "linux", Architecture: "arm", Variant: "v6"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "found none")
+	})
+
+	t.Run("matches arm64 without variant", func(t *testing.T) {
+		url, err := selectImage(newIndex(manifest(1, "arm", "v7"), manifest(3, "arm64", "")),
+			ctlconf.PlatformSelection{OS: "linux", Architecture: "arm64", Variant: "v8"})
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("%s@sha256:%064d", repo, 3), url)
+	})
+}
@@ -0,0 +1,149 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+)
+
+// PostPushImage runs destination hooks once image is pushed
+type PostPushImage struct {
+	image  Image
+	imgDst ctlconf.ImageDestination
+	logger ctllog.Logger
+}
+
+func NewPostPushImage(image Image, imgDst ctlconf.ImageDestination, logger ctllog.Logger) PostPushImage {
+	return PostPushImage{image, imgDst, logger}
+}
+
+type postPushInfo struct {
+	Image  string   `json:"image"`
+	Digest string   `json:"digest"`
+	URL    string   `json:"url"`
+	Tags   []string `json:"tags"`
+}
+
+func (i PostPushImage) URL() (string, []ctlconf.Origin, error) {
+	url, origins, err := i.image.URL()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(i.imgDst.PostPush) == 0 {
+		return url, origins, nil
+	}
+
+	pushedURLs := []string{url}
+	var tags []string
+
+	for _, origin := range origins {
+		if origin.Destinations != nil {
+			pushedURLs = origin.Destinations.URLs
+		}
+		if origin.Tagged != nil {
+			tags = origin.Tagged.Tags
+		}
+	}
+
+	for _, pushedURL := range pushedURLs {
+		ref, err := regname.NewDigest(pushedURL, regname.WeakValidation)
+		if err != nil {
+			return "", nil, fmt.Errorf("Expected pushed image '%s' to be a digest reference: %s", pushedURL, err)
+		}
+
+		info := postPushInfo{
+			Image:  ref.Context().Name(),
+			Digest: ref.DigestStr(),
+			URL:    pushedURL,
+			Tags:   append([]string{}, tags...),
+		}
+
+		for j, hook := range i.imgDst.PostPush {
+			err := i.runHook(hook, info)
+			if err != nil {
+				return "", nil, fmt.Errorf("Running post push hook %d for '%s': %s", j, pushedURL, err)
+			}
+		}
+	}
+
+	return url, origins, nil
+}
+
+func (i PostPushImage) runHook(hook ctlconf.ImageDestinationHook, info postPushInfo) error {
+	prefixedLogger := i.logger.NewPrefixedWriter(info.Image + " | ")
+
+	switch {
+	case len(hook.Command) > 0:
+		prefixedLogger.WriteStr("running post push command: %s\n", strings.Join(hook.Command, " "))
+
+		cmd := exec.Command(hook.Command[0], hook.Command[1:]...)
+		cmd.Env = append(os.Environ(),
+			"KBLD_PUSHED_IMAGE="+info.Image,
+			"KBLD_PUSHED_DIGEST="+info.Digest,
+			"KBLD_PUSHED_URL="+info.URL,
+			"KBLD_PUSHED_TAGS="+strings.Join(info.Tags, ","),
+		)
+		cmd.Stdout = prefixedLogger
+		cmd.Stderr = prefixedLogger
+
+		err := cmd.Run()
+		if err != nil {
+			prefixedLogger.WriteStr("error: %s\n", err)
+			return err
+		}
+		return nil
+
+	case hook.Webhook != nil:
+		prefixedLogger.WriteStr("sending post push webhook: %s\n", hook.Webhook.URL)
+		return i.sendWebhook(*hook.Webhook, info)
+
+	default:
+		return fmt.Errorf("Unknown post push hook")
+	}
+}
+
+func (i PostPushImage) sendWebhook(webhook ctlconf.ImageDestinationWebhook, info postPushInfo) error {
+	body, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for name, val := range webhook.Headers {
+		req.Header.Set(name, os.ExpandEnv(val))
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Expected webhook to succeed, but got status '%s': %s", resp.Status, respBody)
+	}
+
+	return nil
+}
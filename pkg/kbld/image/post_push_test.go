@@ -0,0 +1,78 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+)
+
+func TestPostPushImageWebhook(t *testing.T) {
+	const (
+		digest = "sha256:0000000000000000000000000000000000000000000000000000000000000001"
+		url    = "registry.example.com/app@" + digest
+	)
+
+	var received []map[string]interface{}
+	var authHeaders []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		received = append(received, body)
+		authHeaders = append(authHeaders, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	t.Setenv("KBLD_TEST_WEBHOOK_TOKEN", "secret")
+
+	imgDst := ctlconf.ImageDestination{
+		PostPush: []ctlconf.ImageDestinationHook{{
+			Webhook: &ctlconf.ImageDestinationWebhook{
+				URL:     server.URL,
+				Headers: map[string]string{"Authorization": "Bearer ${KBLD_TEST_WEBHOOK_TOKEN}"},
+			},
+		}},
+	}
+
+	img := ctlimg.NewPreresolvedImage(url, []ctlconf.Origin{{Tagged: &ctlconf.OriginTagged{Tags: []string{"v1"}}}})
+
+	resultURL, _, err := ctlimg.NewPostPushImage(img, imgDst, ctllog.NewLogger(io.Discard)).URL()
+	require.NoError(t, err)
+	assert.Equal(t, url, resultURL)
+
+	assert.Equal(t, []map[string]interface{}{{
+		"image":  "registry.example.com/app",
+		"digest": digest,
+		"url":    url,
+		"tags":   []interface{}{"v1"},
+	}}, received)
+	assert.Equal(t, []string{"Bearer secret"}, authHeaders)
+}
+
+func TestPostPushImageWebhookFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	imgDst := ctlconf.ImageDestination{
+		PostPush: []ctlconf.ImageDestinationHook{{Webhook: &ctlconf.ImageDestinationWebhook{URL: server.URL}}},
+	}
+
+	img := ctlimg.NewPreresolvedImage("registry.example.com/app@sha256:0000000000000000000000000000000000000000000000000000000000000001", nil)
+
+	_, _, err := ctlimg.NewPostPushImage(img, imgDst, ctllog.NewLogger(io.Discard)).URL()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Expected webhook to succeed, but got status '500 Internal Server Error'")
+}
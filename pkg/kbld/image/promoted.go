@@ -0,0 +1,42 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"fmt"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+// PromotedImage copies resolved (not built) image to its destination
+type PromotedImage struct {
+	image    Image
+	imgDst   ctlconf.ImageDestination
+	registry ctlreg.Registry
+}
+
+func NewPromotedImage(image Image, imgDst ctlconf.ImageDestination, registry ctlreg.Registry) PromotedImage {
+	return PromotedImage{image, imgDst, registry}
+}
+
+func (i PromotedImage) URL() (string, []ctlconf.Origin, error) {
+	url, origins, err := i.image.URL()
+	if err != nil {
+		return "", nil, err
+	}
+
+	srcRef, err := regname.NewDigest(url, regname.WeakValidation)
+	if err != nil {
+		return "", nil, fmt.Errorf("Expected resolved image '%s' to be a digest reference: %s", url, err)
+	}
+
+	dstURL, err := transferImageToRepo(i.registry, srcRef, i.imgDst.NewImage, i.imgDst.Mode)
+	if err != nil {
+		return "", nil, fmt.Errorf("Copying image to destination '%s': %s", i.imgDst.NewImage, err)
+	}
+
+	return dstURL, origins, nil
+}
@@ -0,0 +1,207 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	ctlatt "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/attestation"
+	ctlbdk "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/docker"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	ctlsign "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/signing"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+// ProvenanceImage generates SLSA provenance for built and pushed image
+type ProvenanceImage struct {
+	image    Image
+	source   ctlconf.Source
+	registry ctlreg.Registry
+	signer   *ctlsign.Signer
+}
+
+func NewProvenanceImage(image Image, source ctlconf.Source,
+	registry ctlreg.Registry, signer *ctlsign.Signer) ProvenanceImage {
+
+	return ProvenanceImage{image, source, registry, signer}
+}
+
+func (i ProvenanceImage) URL() (string, []ctlconf.Origin, error) {
+	startedOn := time.Now()
+
+	url, origins, err := i.image.URL()
+	if err != nil {
+		return "", nil, err
+	}
+
+	finishedOn := time.Now()
+
+	builder, builderOpts := sourceBuilder(i.source)
+
+	provenance := ctlatt.NewProvenance(ctlatt.ProvenanceOpts{
+		Builder:      builder,
+		BuilderOpts:  builderOpts,
+		SourcePath:   i.source.Path,
+		Dependencies: i.dependencies(origins),
+		StartedOn:    startedOn,
+		FinishedOn:   finishedOn,
+	})
+
+	statement, err := ctlatt.NewStatement(url, ctlatt.ProvenancePredicateType, provenance)
+	if err != nil {
+		return "", nil, err
+	}
+
+	opts := i.source.Provenance
+	origin := ctlconf.OriginAttestation{}
+
+	if len(opts.OutputDir) > 0 {
+		fileName, err := ctlatt.FileName(url, "provenance.json")
+		if err != nil {
+			return "", nil, err
+		}
+
+		origin.Path, err = ctlatt.WriteJSON(opts.OutputDir, fileName, statement)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	if opts.Attach {
+		if i.signer == nil {
+			return "", nil, fmt.Errorf("Expected signing configuration to attach provenance to '%s'", url)
+		}
+
+		tmpDir, err := util.MkdirTemp("kbld-provenance")
+		if err != nil {
+			return "", nil, err
+		}
+		defer os.RemoveAll(tmpDir)
+
+		// cosign wraps predicate into a statement itself
+		predicatePath, err := ctlatt.WriteJSON(tmpDir, "predicate.json", provenance)
+		if err != nil {
+			return "", nil, err
+		}
+
+		err = i.signer.Attest(url, predicatePath, ctlatt.ProvenancePredicateType)
+		if err != nil {
+			return "", nil, err
+		}
+
+		origin.Attached = true
+	}
+
+	return url, append(origins, ctlconf.Origin{Provenance: &origin}), nil
+}
+
+func (i ProvenanceImage) dependencies(origins []ctlconf.Origin) []ctlatt.ResourceDescriptor {
+	var result []ctlatt.ResourceDescriptor
+
+	for _, origin := range origins {
+		if origin.Git != nil && origin.Git.SHA != GitRepoHeadSHANoCommits {
+			result = append(result, ctlatt.ResourceDescriptor{
+				URI:    "git+" + origin.Git.RemoteURL,
+				Digest: map[string]string{"sha1": origin.Git.SHA},
+			})
+		}
+	}
+
+	for _, baseImage := range i.baseImages() {
+		result = append(result, i.imageDependency(baseImage))
+	}
+
+	return result
+}
+
+// baseImages returns images used by Dockerfile based builds
+func (i ProvenanceImage) baseImages() []string {
+	var file *string
+
+	switch {
+	case i.source.Pack != nil || i.source.Ko != nil || i.source.Bazel != nil ||
+		i.source.CloudBuild != nil || i.source.CodeBuild != nil || i.source.Archive != nil:
+		return nil
+	case i.source.Docker != nil && i.source.Docker.Bake != nil:
+		// Dockerfiles are selected by bake definition
+		return nil
+	case i.source.KubectlBuildkit != nil:
+		file = i.source.KubectlBuildkit.Build.File
+	case i.source.Depot != nil:
+		file = i.source.Depot.File
+	case i.source.Docker != nil && i.source.Docker.Buildx != nil:
+		file = i.source.Docker.Buildx.File
+	case i.source.Docker != nil:
+		file = i.source.Docker.Build.File
+	}
+
+	// Provenance is only informational about base images hence ignore parse errors
+	images, _ := ctlbdk.DockerfileBaseImages(ctlbdk.DockerfilePath(i.source.Path, file))
+	return images
+}
+
+func (i ProvenanceImage) imageDependency(image string) ctlatt.ResourceDescriptor {
+	result := ctlatt.ResourceDescriptor{URI: "docker://" + image, Name: image}
+
+	if digestRef, err := regname.NewDigest(image, regname.WeakValidation); err == nil {
+		result.Digest = digestMap(digestRef.DigestStr())
+		return result
+	}
+
+	ref, err := regname.ParseReference(image, regname.WeakValidation)
+	if err != nil {
+		return result
+	}
+
+	desc, err := i.registry.Generic(ref)
+	if err != nil {
+		return result
+	}
+
+	result.Digest = digestMap(desc.Digest.String())
+	return result
+}
+
+func digestMap(digest string) map[string]string {
+	pieces := strings.SplitN(digest, ":", 2)
+	if len(pieces) != 2 {
+		return nil
+	}
+	return map[string]string{pieces[0]: pieces[1]}
+}
+
+// sourceBuilder returns builder name and its configuration
+func sourceBuilder(src ctlconf.Source) (string, interface{}) {
+	switch {
+	case src.Pack != nil:
+		return "pack", src.Pack
+	case src.KubectlBuildkit != nil:
+		return "kubectl-buildkit", src.KubectlBuildkit
+	case src.Ko != nil:
+		return "ko", src.Ko
+	case src.Bazel != nil:
+		return "bazel", src.Bazel
+	case src.CloudBuild != nil:
+		return "cloud-build", src.CloudBuild
+	case src.CodeBuild != nil:
+		return "codebuild", src.CodeBuild
+	case src.Depot != nil:
+		return "depot", src.Depot
+	case src.Archive != nil:
+		return "archive", src.Archive
+	case src.Docker != nil && src.Docker.Bake != nil:
+		return "docker-buildx-bake", src.Docker.Bake
+	case src.Docker != nil && src.Docker.Buildx != nil:
+		return "docker-buildx", src.Docker.Buildx
+	case src.Docker != nil:
+		return "docker", src.Docker.Build
+	default:
+		return "docker", nil
+	}
+}
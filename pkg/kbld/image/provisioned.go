@@ -0,0 +1,115 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlprov "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/provision"
+)
+
+// EnsureECRRepositories checks ECR repositories of destinations of given images
+// before any of them are processed so that all missing repositories are reported
+// at once (images still ensure them, though existing repositories are not checked again)
+func (f Factory) EnsureECRRepositories(urls []string) error {
+	var dsts []ctlprov.ECRDestination
+	seen := map[string]struct{}{}
+
+	for _, url := range urls {
+		_, imgDstConf, err := f.plan(url)
+		// Configuration errors are reported when image is processed
+		if err != nil || imgDstConf == nil || imgDstConf.ECR == nil {
+			continue
+		}
+
+		repos := append([]string{imgDstConf.NewImage}, imgDstConf.AdditionalNewImages()...)
+
+		key := strings.Join(repos, " ")
+		if _, found := seen[key]; found {
+			continue
+		}
+		seen[key] = struct{}{}
+
+		dsts = append(dsts, ctlprov.ECRDestination{Repos: repos, Opts: *imgDstConf.ECR})
+	}
+
+	if len(dsts) == 0 {
+		return nil
+	}
+
+	return f.ecr.EnsureAll(dsts)
+}
+
+// ProvisionedImage ensures that destination repositories exist
+// (ECR repositories and pre push hooks) before image is built (or resolved) and pushed
+type ProvisionedImage struct {
+	image  Image
+	imgDst ctlconf.ImageDestination
+	ecr    ctlprov.ECR
+	harbor ctlprov.Harbor
+	quay   ctlprov.Quay
+	logger ctllog.Logger
+}
+
+func NewProvisionedImage(image Image, imgDst ctlconf.ImageDestination, ecr ctlprov.ECR,
+	harbor ctlprov.Harbor, quay ctlprov.Quay, logger ctllog.Logger) ProvisionedImage {
+
+	return ProvisionedImage{image, imgDst, ecr, harbor, quay, logger}
+}
+
+func (i ProvisionedImage) URL() (string, []ctlconf.Origin, error) {
+	repos := append([]string{i.imgDst.NewImage}, i.imgDst.AdditionalNewImages()...)
+
+	if i.imgDst.ECR != nil {
+		err := i.ecr.Ensure(repos, *i.imgDst.ECR)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	for _, repo := range repos {
+		for j, hook := range i.imgDst.PrePush {
+			err := i.runHook(hook, repo)
+			if err != nil {
+				return "", nil, fmt.Errorf("Running pre push hook %d for '%s': %s", j, repo, err)
+			}
+		}
+	}
+
+	return i.image.URL()
+}
+
+func (i ProvisionedImage) runHook(hook ctlconf.ImageDestinationPrePushHook, repo string) error {
+	switch {
+	case len(hook.Command) > 0:
+		prefixedLogger := i.logger.NewPrefixedWriter(repo + " | ")
+		prefixedLogger.WriteStr("running pre push command: %s\n", strings.Join(hook.Command, " "))
+
+		cmd := exec.Command(hook.Command[0], hook.Command[1:]...)
+		cmd.Env = append(os.Environ(), "KBLD_PUSH_REPOSITORY="+repo)
+		cmd.Stdout = prefixedLogger
+		cmd.Stderr = prefixedLogger
+
+		err := cmd.Run()
+		if err != nil {
+			prefixedLogger.WriteStr("error: %s\n", err)
+			return err
+		}
+		return nil
+
+	case hook.Harbor != nil:
+		return i.harbor.Ensure(repo, *hook.Harbor)
+
+	case hook.Quay != nil:
+		return i.quay.Ensure(repo, *hook.Quay)
+
+	default:
+		return fmt.Errorf("Unknown pre push hook")
+	}
+}
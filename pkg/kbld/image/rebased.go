@@ -0,0 +1,285 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	regtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/imageutils/convert"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+// Rebaser rebases images onto new base images. Base images
+// are fetched once and shared by all images of a run.
+type Rebaser struct {
+	rebases  []ctlconf.ImageRebase
+	registry ctlreg.Registry
+
+	bases     map[string]*rebaseBase
+	basesLock sync.Mutex
+}
+
+type rebaseBase struct {
+	url string
+	idx regv1.ImageIndex
+	img regv1.Image
+	err error
+}
+
+func NewRebaser(rebases []ctlconf.ImageRebase, registry ctlreg.Registry) *Rebaser {
+	return &Rebaser{rebases: rebases, registry: registry, bases: map[string]*rebaseBase{}}
+}
+
+// RebasedImage replaces its old base image layers with layers of new base image
+type RebasedImage struct {
+	image    Image
+	url      string
+	rebaser  *Rebaser
+	registry ctlreg.Registry
+}
+
+func NewRebasedImage(image Image, url string, rebaser *Rebaser, registry ctlreg.Registry) RebasedImage {
+	return RebasedImage{image, url, rebaser, registry}
+}
+
+func (i RebasedImage) URL() (string, []ctlconf.Origin, error) {
+	url, origins, err := i.image.URL()
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, rebase := range i.rebaser.rebases {
+		if !rebase.MatchesAll() && !NewMatcher(i.url).Matches(rebase.ImageRef) {
+			continue
+		}
+
+		rebasedURL, newBaseURL, err := i.rebase(url, rebase)
+		if err != nil {
+			return "", nil, fmt.Errorf("Rebasing image '%s' onto '%s': %s", url, rebase.NewBase, err)
+		}
+		if len(rebasedURL) == 0 {
+			continue
+		}
+
+		origins = append(origins, ctlconf.Origin{Rebased: &ctlconf.OriginRebased{
+			URL:     url,
+			OldBase: rebase.OldBase,
+			NewBase: newBaseURL,
+		}})
+
+		return rebasedURL, origins, nil
+	}
+
+	return url, origins, nil
+}
+
+// rebase returns empty URL when image is not based on old base
+// (and rebase applies to all images)
+func (i RebasedImage) rebase(url string, rebase ctlconf.ImageRebase) (string, string, error) {
+	srcRef, err := regname.NewDigest(url, regname.WeakValidation)
+	if err != nil {
+		return "", "", fmt.Errorf("Expected image to be a digest reference: %s", err)
+	}
+
+	oldBase, err := i.rebaser.base(rebase.OldBase)
+	if err != nil {
+		return "", "", fmt.Errorf("Fetching old base '%s': %s", rebase.OldBase, err)
+	}
+
+	newBase, err := i.rebaser.base(rebase.NewBase)
+	if err != nil {
+		return "", "", fmt.Errorf("Fetching new base '%s': %s", rebase.NewBase, err)
+	}
+
+	var based bool
+
+	rebaseImage := func(img regv1.Image) (regv1.Image, error) {
+		configFile, err := img.ConfigFile()
+		if err != nil {
+			return nil, err
+		}
+
+		oldBaseImg, err := oldBase.platformImage(configFile.Platform())
+		if err != nil {
+			return nil, fmt.Errorf("Selecting old base: %s", err)
+		}
+
+		isBased, err := basedOn(img, oldBaseImg)
+		if err != nil {
+			return nil, err
+		}
+		if !isBased {
+			// Only some images of an index (e.g. attestations) may be based on old base
+			return img, nil
+		}
+		based = true
+
+		newBaseImg, err := newBase.platformImage(configFile.Platform())
+		if err != nil {
+			return nil, fmt.Errorf("Selecting new base: %s", err)
+		}
+
+		return mutate.Rebase(img, oldBaseImg, newBaseImg)
+	}
+
+	desc, err := i.registry.Generic(srcRef)
+	if err != nil {
+		return "", "", err
+	}
+
+	var digest regv1.Hash
+	var write func(regname.Tag) error
+
+	switch desc.MediaType {
+	case regtypes.OCIImageIndex, regtypes.DockerManifestList:
+		idx, err := i.registry.Index(srcRef)
+		if err != nil {
+			return "", "", err
+		}
+		rebasedIdx, err := convert.MapIndex(idx, "", rebaseImage)
+		if err != nil {
+			return "", "", err
+		}
+		digest, err = rebasedIdx.Digest()
+		if err != nil {
+			return "", "", err
+		}
+		write = func(tagRef regname.Tag) error { return i.registry.WriteIndex(tagRef, rebasedIdx) }
+
+	default:
+		img, err := i.registry.Image(srcRef)
+		if err != nil {
+			return "", "", err
+		}
+		rebasedImg, err := rebaseImage(img)
+		if err != nil {
+			return "", "", err
+		}
+		digest, err = rebasedImg.Digest()
+		if err != nil {
+			return "", "", err
+		}
+		write = func(tagRef regname.Tag) error { return i.registry.WriteImage(tagRef, rebasedImg) }
+	}
+
+	if !based {
+		if rebase.MatchesAll() {
+			return "", "", nil
+		}
+		return "", "", fmt.Errorf("Expected image to be based on '%s'", rebase.OldBase)
+	}
+
+	dstRepo := srcRef.Context()
+	if len(rebase.NewImage) > 0 {
+		dstRepo, err = regname.NewRepository(rebase.NewImage, regname.WeakValidation)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	// Seems like AWS ECR doesnt like using digests for manifest uploads
+	err = write(dstRepo.Tag("kbld-" + strings.Replace(digest.String(), ":", "-", 1)))
+	if err != nil {
+		return "", "", err
+	}
+
+	rebasedURL, _, err := NewDigestedImageFromParts(dstRepo.Name(), digest.String()).URL()
+	if err != nil {
+		return "", "", err
+	}
+
+	return rebasedURL, newBase.url, nil
+}
+
+// base returns (cached) base image or index by reference
+func (r *Rebaser) base(url string) (*rebaseBase, error) {
+	r.basesLock.Lock()
+	defer r.basesLock.Unlock()
+
+	if base, found := r.bases[url]; found {
+		return base, base.err
+	}
+
+	base := &rebaseBase{}
+	base.err = r.fetchBase(url, base)
+	r.bases[url] = base
+
+	return base, base.err
+}
+
+func (r *Rebaser) fetchBase(url string, base *rebaseBase) error {
+	ref, err := regname.ParseReference(url, regname.WeakValidation)
+	if err != nil {
+		return err
+	}
+
+	desc, err := r.registry.Generic(ref)
+	if err != nil {
+		return err
+	}
+
+	base.url, _, err = NewDigestedImageFromParts(ref.Context().Name(), desc.Digest.String()).URL()
+	if err != nil {
+		return err
+	}
+
+	switch desc.MediaType {
+	case regtypes.OCIImageIndex, regtypes.DockerManifestList:
+		base.idx, err = r.registry.Index(ref)
+	default:
+		base.img, err = r.registry.Image(ref)
+	}
+	return err
+}
+
+func (b *rebaseBase) platformImage(platform *regv1.Platform) (regv1.Image, error) {
+	if b.img != nil {
+		return b.img, nil
+	}
+
+	idxManifest, err := b.idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, desc := range idxManifest.Manifests {
+		if platform != nil && desc.Platform != nil && desc.Platform.Satisfies(*platform) {
+			return b.idx.Image(desc.Digest)
+		}
+	}
+
+	return nil, fmt.Errorf("Expected to find image for platform '%s'", platform)
+}
+
+// basedOn checks that image starts with all layers of base image
+func basedOn(img, base regv1.Image) (bool, error) {
+	manifest, err := img.Manifest()
+	if err != nil {
+		return false, err
+	}
+
+	baseManifest, err := base.Manifest()
+	if err != nil {
+		return false, err
+	}
+
+	if len(baseManifest.Layers) > len(manifest.Layers) {
+		return false, nil
+	}
+
+	for i, layer := range baseManifest.Layers {
+		if layer.Digest != manifest.Layers[i].Digest {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
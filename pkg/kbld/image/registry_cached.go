@@ -0,0 +1,172 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+// RegistryCachedImage looks up image tagged with content hash of the source
+// in destination and only builds (and pushes) image when it's not found.
+// Built image gets tagged with content hash so that subsequent builds
+// (possibly on other machines) can reuse it.
+type RegistryCachedImage struct {
+	image    Image
+	built    BuiltImage
+	imgDst   ctlconf.ImageDestination
+	registry ctlreg.Registry
+	logger   ctllog.Logger
+}
+
+func NewRegistryCachedImage(image Image, built BuiltImage, imgDst ctlconf.ImageDestination,
+	registry ctlreg.Registry, logger ctllog.Logger) RegistryCachedImage {
+
+	return RegistryCachedImage{image, built, imgDst, registry, logger}
+}
+
+func (i RegistryCachedImage) URL() (string, []ctlconf.Origin, error) {
+	tag, err := i.tag()
+	if err != nil {
+		return "", nil, fmt.Errorf("Calculating content hash of '%s': %s", i.built.buildSource.Path, err)
+	}
+
+	dstRef, err := regname.ParseReference(i.imgDst.NewImage, regname.WeakValidation)
+	if err != nil {
+		return "", nil, err
+	}
+
+	tagRef := dstRef.Context().Tag(tag)
+
+	desc, err := i.registry.Digest(tagRef)
+	switch {
+	case err == nil:
+		origins, err := i.built.sources()
+		if err != nil {
+			return "", nil, err
+		}
+
+		i.logger.NewPrefixedWriter(i.built.url+" | ").WriteStr(
+			"skipping build: found '%s' in registry cache\n", tagRef.Name())
+
+		url, _, err := NewDigestedImageFromParts(dstRef.Context().Name(), desc.Digest.String()).URL()
+		if err != nil {
+			return "", nil, err
+		}
+
+		origins = append(origins, ctlconf.Origin{RegistryCache: &ctlconf.OriginRegistryCache{Tag: tag, Hit: true}})
+
+		return url, origins, nil
+
+	case !ctlreg.IsNotFoundErr(err):
+		return "", nil, fmt.Errorf("Checking registry cache '%s': %s", tagRef.Name(), err)
+	}
+
+	url, origins, err := i.image.URL()
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = writeTags(i.registry, url, []string{tag}, false)
+	if err != nil {
+		return "", nil, fmt.Errorf("Writing registry cache tag '%s': %s", tagRef.Name(), err)
+	}
+
+	origins = append(origins, ctlconf.Origin{RegistryCache: &ctlconf.OriginRegistryCache{Tag: tag, Hit: false}})
+
+	return url, origins, nil
+}
+
+// tag returns content hash tag based on source files and build configuration
+func (i RegistryCachedImage) tag() (string, error) {
+	hash, err := SourceContentHash(i.built.buildSource)
+	if err != nil {
+		return "", err
+	}
+
+	var prefix string
+	if i.built.buildSource.RegistryCache != nil {
+		prefix = i.built.buildSource.RegistryCache.TagPrefixWithDefaults()
+	}
+
+	return prefix + hash, nil
+}
+
+// SourceContentHash returns hex encoded sha256 of files under source path
+// (.git directories are skipped) and source configuration (excluding path
+// since it's typically different between machines)
+func SourceContentHash(srcConf ctlconf.Source) (string, error) {
+	hash := sha256.New()
+
+	confWithoutPath := srcConf
+	confWithoutPath.Path = ""
+
+	confBs, err := json.Marshal(confWithoutPath)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Fprintf(hash, "config %d\n", len(confBs))
+	hash.Write(confBs)
+
+	err = filepath.Walk(srcConf.Path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(srcConf.Path, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		switch {
+		case info.IsDir():
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			fmt.Fprintf(hash, "dir %s\n", relPath)
+
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(hash, "symlink %s %s\n", relPath, filepath.ToSlash(target))
+
+		case info.Mode().IsRegular():
+			// Only executable bit is included since other
+			// permission bits commonly differ between checkouts
+			fmt.Fprintf(hash, "file %s %t %d\n", relPath, info.Mode()&0111 != 0, info.Size())
+
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			_, err = io.Copy(hash, file)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlb "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder"
+	ctlbbz "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/bazel"
+	ctlbcb "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/cloudbuild"
+	ctlbcd "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/codebuild"
+	ctlbdp "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/depot"
+	ctlbdk "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/docker"
+	ctlbko "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/ko"
+	ctlbkb "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/kubectlbuildkit"
+	ctlbpk "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/builder/pack"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+type fakeBuiltImage struct {
+	url    string
+	builds int
+}
+
+func (i *fakeBuiltImage) URL() (string, []ctlconf.Origin, error) {
+	i.builds++
+	return i.url, nil, nil
+}
+
+func TestRegistryCachedImage(t *testing.T) {
+	manifestBs := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`)
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifestBs))
+
+	var mu sync.Mutex
+	tags := map[string]bool{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		ref := strings.TrimPrefix(r.URL.Path, "/v2/app/manifests/")
+		switch {
+		case r.URL.Path == "/v2/":
+			return
+		case r.Method == http.MethodPut && ref != r.URL.Path:
+			tags[ref] = true
+			w.WriteHeader(http.StatusCreated)
+			return
+		case ref == digest || tags[ref]:
+			w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+			w.Header().Set("Docker-Content-Digest", digest)
+			w.Header().Set("Content-Length", fmt.Sprintf("%d", len(manifestBs)))
+			if r.Method == http.MethodGet {
+				w.Write(manifestBs)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	registry, err := ctlreg.NewRegistry(ctlreg.Opts{EnvAuthPrefix: "KBLD_TEST_REGISTRY_CACHE", Insecure: true})
+	require.NoError(t, err)
+
+	repo := strings.TrimPrefix(server.URL, "http://") + "/app"
+
+	srcDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "Dockerfile"), []byte("FROM scratch\n"), 0600))
+
+	srcConf := ctlconf.Source{
+		ImageRef:      ctlconf.ImageRef{Image: "app"},
+		Path:          srcDir,
+		RegistryCache: &ctlconf.SourceRegistryCacheOpts{},
+	}
+	imgDst := ctlconf.ImageDestination{ImageRef: ctlconf.ImageRef{Image: "app"}, NewImage: repo}
+
+	hash, err := ctlimg.SourceContentHash(srcConf)
+	require.NoError(t, err)
+	cacheTag := "kbld-cache-" + hash
+
+	resolve := func(built *fakeBuiltImage) (string, []ctlconf.Origin) {
+		builtImage := ctlimg.NewBuiltImage("app", srcConf, &imgDst, ctlbdk.Docker{}, ctlbdk.Buildx{},
+			ctlbdk.Bake{}, ctlbpk.Pack{}, ctlbkb.KubectlBuildkit{}, ctlbko.Ko{}, ctlbbz.Bazel{},
+			ctlbcb.CloudBuild{}, ctlbcd.CodeBuild{}, ctlbdp.Depot{}, ctlimg.BaseImages{}, ctlimg.ArchivePusher{}, ctlb.NewInvocations(), ctlb.NewIntermediateTag())
+		img := ctlimg.NewRegistryCachedImage(built, builtImage, imgDst, registry, ctllog.NewLogger(io.Discard))
+		url, origins, err := img.URL()
+		require.NoError(t, err)
+		return url, origins
+	}
+
+	built := &fakeBuiltImage{url: repo + "@" + digest}
+
+	url, origins := resolve(built)
+	assert.Equal(t, repo+"@"+digest, url)
+	assert.Equal(t, 1, built.builds)
+	assert.True(t, tags[cacheTag])
+	require.Len(t, origins, 1)
+	assert.Equal(t, &ctlconf.OriginRegistryCache{Tag: cacheTag, Hit: false}, origins[0].RegistryCache)
+
+	otherMachineBuilt := &fakeBuiltImage{url: repo + "@" + digest}
+
+	url, origins = resolve(otherMachineBuilt)
+	assert.Equal(t, repo+"@"+digest, url)
+	assert.Equal(t, 0, otherMachineBuilt.builds)
+	require.NotEmpty(t, origins)
+	assert.Equal(t, &ctlconf.OriginRegistryCache{Tag: cacheTag, Hit: true}, origins[len(origins)-1].RegistryCache)
+}
+
+func TestSourceContentHash(t *testing.T) {
+	hashOf := func(srcConf ctlconf.Source) string {
+		hash, err := ctlimg.SourceContentHash(srcConf)
+		require.NoError(t, err)
+		return hash
+	}
+
+	writeSource := func(contents string) string {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte(contents), 0600))
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, ".git"), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte(dir), 0600))
+		return dir
+	}
+
+	srcConf := ctlconf.Source{ImageRef: ctlconf.ImageRef{Image: "app"}, Path: writeSource("FROM scratch\n")}
+	hash := hashOf(srcConf)
+
+	t.Run("same contents at different path (.git is ignored)", func(t *testing.T) {
+		otherConf := srcConf
+		otherConf.Path = writeSource("FROM scratch\n")
+		assert.Equal(t, hash, hashOf(otherConf))
+	})
+
+	t.Run("different contents", func(t *testing.T) {
+		otherConf := srcConf
+		otherConf.Path = writeSource("FROM busybox\n")
+		assert.NotEqual(t, hash, hashOf(otherConf))
+	})
+
+	t.Run("different build configuration", func(t *testing.T) {
+		otherConf := srcConf
+		otherConf.Docker = &ctlconf.SourceDockerOpts{}
+		assert.NotEqual(t, hash, hashOf(otherConf))
+	})
+}
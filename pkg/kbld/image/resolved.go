@@ -27,7 +27,7 @@ func (i ResolvedImage) URL() (string, []ctlconf.Origin, error) {
 		return "", nil, err
 	}
 
-	imgDescriptor, err := i.registry.Generic(tag)
+	imgDescriptor, err := i.registry.Digest(tag)
 	if err != nil {
 		return "", nil, err
 	}
@@ -35,7 +35,7 @@ func (i ResolvedImage) URL() (string, []ctlconf.Origin, error) {
 	// Resolve image second time because some older registry can
 	// return "random" digests that change for every request.
 	// See https://github.com/vmware-tanzu/carvel-kbld/issues/21 for details.
-	imgDescriptor2, err := i.registry.Generic(tag)
+	imgDescriptor2, err := i.registry.Digest(tag)
 	if err != nil {
 		return "", nil, err
 	}
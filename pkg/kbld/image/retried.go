@@ -0,0 +1,71 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+)
+
+// RetriedBuildImage builds image again when build fails
+// (builds cancelled by timeout are not retried)
+type RetriedBuildImage struct {
+	image  BuiltImage
+	url    string
+	opts   ctlconf.SourceRetryOpts
+	ctx    context.Context
+	logger ctllog.Logger
+}
+
+var _ Image = RetriedBuildImage{}
+
+func NewRetriedBuildImage(image BuiltImage, url string, opts ctlconf.SourceRetryOpts,
+	ctx context.Context, logger ctllog.Logger) RetriedBuildImage {
+
+	return RetriedBuildImage{image, url, opts, ctx, logger}
+}
+
+func (i RetriedBuildImage) URL() (string, []ctlconf.Origin, error) {
+	delay, err := i.opts.DelayWithDefaults()
+	if err != nil {
+		return "", nil, err
+	}
+
+	urlRepo, _ := URLRepo(i.url)
+	prefixedLogger := i.logger.NewPrefixedWriter(urlRepo + " | ")
+
+	for attempt := 1; ; attempt++ {
+		img := i.image
+		if attempt > 1 && attempt == i.opts.Attempts && i.opts.NoCacheOnLastAttempt {
+			img = img.WithoutCache()
+		}
+
+		url, origins, err := img.URL()
+		if err == nil {
+			return url, origins, nil
+		}
+		if i.ctx.Err() != nil {
+			return "", nil, err
+		}
+		if attempt >= i.opts.Attempts {
+			if attempt > 1 {
+				return "", nil, fmt.Errorf("Building failed after %d attempts: %s", attempt, err)
+			}
+			return "", nil, err
+		}
+
+		prefixedLogger.WriteStr("build attempt %d of %d failed (retrying in %s): %s\n",
+			attempt, i.opts.Attempts, delay, err)
+
+		select {
+		case <-time.After(delay):
+		case <-i.ctx.Done():
+			return "", nil, err
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	ctlatt "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/attestation"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlsign "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/signing"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+// SBOMImage generates SBOM for built and pushed image
+type SBOMImage struct {
+	image     Image
+	opts      ctlconf.SourceSBOMOpts
+	generator ctlatt.SBOMGenerator
+	signer    *ctlsign.Signer
+}
+
+func NewSBOMImage(image Image, opts ctlconf.SourceSBOMOpts,
+	generator ctlatt.SBOMGenerator, signer *ctlsign.Signer) SBOMImage {
+
+	return SBOMImage{image, opts, generator, signer}
+}
+
+func (i SBOMImage) URL() (string, []ctlconf.Origin, error) {
+	url, origins, err := i.image.URL()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if i.opts.Attach && i.signer == nil {
+		return "", nil, fmt.Errorf("Expected signing configuration to attach SBOM to '%s'", url)
+	}
+
+	format := i.opts.FormatWithDefaults()
+
+	fileName, err := ctlatt.FileName(url, ctlatt.SBOMFileSuffix(format))
+	if err != nil {
+		return "", nil, err
+	}
+
+	outputDir := i.opts.OutputDir
+
+	if len(outputDir) == 0 {
+		outputDir, err = util.MkdirTemp("kbld-sbom")
+		if err != nil {
+			return "", nil, err
+		}
+		defer os.RemoveAll(outputDir)
+	} else {
+		err = os.MkdirAll(outputDir, 0700)
+		if err != nil {
+			return "", nil, fmt.Errorf("Creating directory '%s': %s", outputDir, err)
+		}
+	}
+
+	outputPath := filepath.Join(outputDir, fileName)
+
+	err = i.generator.Generate(url, i.opts, outputPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	origin := ctlconf.OriginAttestation{}
+	if len(i.opts.OutputDir) > 0 {
+		origin.Path = outputPath
+	}
+
+	if i.opts.Attach {
+		err = i.signer.Attest(url, outputPath, ctlatt.SBOMPredicateType(format))
+		if err != nil {
+			return "", nil, err
+		}
+		origin.Attached = true
+	}
+
+	return url, append(origins, ctlconf.Origin{SBOM: &origin}), nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlatt "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/attestation"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+)
+
+func TestSBOMImageWithCommand(t *testing.T) {
+	const url = "registry.example.com/app@sha256:0000000000000000000000000000000000000000000000000000000000000001"
+
+	outputDir := t.TempDir()
+
+	opts := ctlconf.SourceSBOMOpts{
+		Format:    ctlconf.SBOMFormatSPDX,
+		Command:   []string{"sh", "-c", `echo "$KBLD_SBOM_FORMAT $KBLD_SBOM_IMAGE" > "$KBLD_SBOM_OUTPUT"`},
+		OutputDir: outputDir,
+	}
+
+	img := ctlimg.NewPreresolvedImage(url, nil)
+	generator := ctlatt.NewSBOMGenerator(ctllog.NewLogger(io.Discard))
+
+	resultURL, origins, err := ctlimg.NewSBOMImage(img, opts, generator, nil).URL()
+	require.NoError(t, err)
+	assert.Equal(t, url, resultURL)
+
+	expectedPath := filepath.Join(outputDir,
+		"sha256-0000000000000000000000000000000000000000000000000000000000000001.sbom.spdx.json")
+
+	contents, err := os.ReadFile(expectedPath)
+	require.NoError(t, err)
+	assert.Equal(t, "spdx "+url+"\n", string(contents))
+
+	require.Len(t, origins, 2)
+	assert.Equal(t, &ctlconf.OriginAttestation{Path: expectedPath}, origins[1].SBOM)
+}
+
+func TestSBOMImageAttachRequiresSigning(t *testing.T) {
+	img := ctlimg.NewPreresolvedImage("registry.example.com/app@sha256:0000000000000000000000000000000000000000000000000000000000000001", nil)
+	generator := ctlatt.NewSBOMGenerator(ctllog.NewLogger(io.Discard))
+
+	_, _, err := ctlimg.NewSBOMImage(img, ctlconf.SourceSBOMOpts{Attach: true}, generator, nil).URL()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Expected signing configuration to attach SBOM")
+}
@@ -0,0 +1,59 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"fmt"
+	"strings"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlscan "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/scan"
+)
+
+// ScannedImage scans resolved image for vulnerabilities
+// and fails if they exceed configured severity threshold
+type ScannedImage struct {
+	image   Image
+	scanner ctlscan.Scanner
+	opts    ctlconf.VulnerabilityScan
+	report  *ctlscan.Report
+}
+
+func NewScannedImage(image Image, scanner ctlscan.Scanner,
+	opts ctlconf.VulnerabilityScan, report *ctlscan.Report) ScannedImage {
+
+	return ScannedImage{image, scanner, opts, report}
+}
+
+func (i ScannedImage) URL() (string, []ctlconf.Origin, error) {
+	url, origins, err := i.image.URL()
+	if err != nil {
+		return "", nil, err
+	}
+
+	result, err := i.scanner.Scan(url)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if i.report != nil {
+		i.report.Add(result)
+	}
+
+	if result.Failed {
+		var ids []string
+		for _, vuln := range result.Exceeds(i.opts.FailOn) {
+			ids = append(ids, fmt.Sprintf("%s (%s)", vuln.ID, vuln.Severity))
+		}
+		return "", nil, fmt.Errorf("Expected image '%s' to not have vulnerabilities with severity %s or higher, but found: %s",
+			url, strings.ToUpper(i.opts.FailOn), strings.Join(ids, ", "))
+	}
+
+	origins = append(origins, ctlconf.Origin{Scanned: &ctlconf.OriginScanned{
+		Scanner:         result.Scanner,
+		Vulnerabilities: result.Counts(),
+	}})
+
+	return url, origins, nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlsign "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/signing"
+)
+
+// SignedImage signs image (and its additional destinations) once it is pushed
+type SignedImage struct {
+	image  Image
+	signer ctlsign.Signer
+}
+
+func NewSignedImage(image Image, signer ctlsign.Signer) SignedImage {
+	return SignedImage{image, signer}
+}
+
+func (i SignedImage) URL() (string, []ctlconf.Origin, error) {
+	url, origins, err := i.image.URL()
+	if err != nil {
+		return "", nil, err
+	}
+
+	urls := []string{url}
+	for _, origin := range origins {
+		if origin.Destinations != nil {
+			urls = origin.Destinations.URLs
+		}
+	}
+
+	signed := &ctlconf.OriginSigned{}
+
+	for _, url := range urls {
+		sig, err := i.signer.Sign(url)
+		if err != nil {
+			return "", nil, err
+		}
+		signed.Add(sig.URL, sig.TransparencyLogEntry)
+	}
+
+	origins = append(origins, ctlconf.Origin{Signed: signed})
+
+	return url, origins, nil
+}
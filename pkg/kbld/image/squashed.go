@@ -0,0 +1,194 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	regtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/imageutils/convert"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+// SquashedImage pushes squashed version of image into the same
+// repository and uses it instead of original image
+type SquashedImage struct {
+	image    Image
+	imgDst   ctlconf.ImageDestination
+	registry ctlreg.Registry
+}
+
+func NewSquashedImage(image Image, imgDst ctlconf.ImageDestination, registry ctlreg.Registry) SquashedImage {
+	return SquashedImage{image, imgDst, registry}
+}
+
+func (i SquashedImage) URL() (string, []ctlconf.Origin, error) {
+	url, origins, err := i.image.URL()
+	if err != nil {
+		return "", nil, err
+	}
+
+	srcRef, err := regname.NewDigest(url, regname.WeakValidation)
+	if err != nil {
+		return "", nil, fmt.Errorf("Expected pushed image '%s' to be a digest reference: %s", url, err)
+	}
+
+	squashedURL, err := i.squash(srcRef)
+	if err != nil {
+		return "", nil, fmt.Errorf("Squashing image '%s': %s", url, err)
+	}
+
+	origins = append(origins, ctlconf.Origin{Squashed: &ctlconf.OriginSquashed{
+		URL:       url,
+		BaseImage: i.imgDst.SquashBaseImage,
+	}})
+
+	return squashedURL, origins, nil
+}
+
+func (i SquashedImage) squash(srcRef regname.Digest) (string, error) {
+	tmpDir, err := util.MkdirTemp("kbld-squash-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	squashImage := func(img regv1.Image) (regv1.Image, error) {
+		keepLayers, err := i.baseLayers(img)
+		if err != nil {
+			return nil, err
+		}
+		return convert.Squash(img, keepLayers, tmpDir)
+	}
+
+	desc, err := i.registry.Generic(srcRef)
+	if err != nil {
+		return "", err
+	}
+
+	var digest regv1.Hash
+	var write func(regname.Tag) error
+
+	switch desc.MediaType {
+	case regtypes.OCIImageIndex, regtypes.DockerManifestList:
+		idx, err := i.registry.Index(srcRef)
+		if err != nil {
+			return "", err
+		}
+		squashedIdx, err := convert.MapIndex(idx, "", squashImage)
+		if err != nil {
+			return "", err
+		}
+		digest, err = squashedIdx.Digest()
+		if err != nil {
+			return "", err
+		}
+		write = func(tagRef regname.Tag) error { return i.registry.WriteIndex(tagRef, squashedIdx) }
+
+	default:
+		img, err := i.registry.Image(srcRef)
+		if err != nil {
+			return "", err
+		}
+		squashedImg, err := squashImage(img)
+		if err != nil {
+			return "", err
+		}
+		digest, err = squashedImg.Digest()
+		if err != nil {
+			return "", err
+		}
+		write = func(tagRef regname.Tag) error { return i.registry.WriteImage(tagRef, squashedImg) }
+	}
+
+	if digest.String() != srcRef.DigestStr() {
+		// Seems like AWS ECR doesnt like using digests for manifest uploads
+		err = write(srcRef.Context().Tag("kbld-" + strings.Replace(digest.String(), ":", "-", 1)))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	squashedURL, _, err := NewDigestedImageFromParts(srcRef.Context().Name(), digest.String()).URL()
+	return squashedURL, err
+}
+
+// baseLayers returns number of bottom layers shared with configured base image
+func (i SquashedImage) baseLayers(img regv1.Image) (int, error) {
+	if len(i.imgDst.SquashBaseImage) == 0 {
+		return 0, nil
+	}
+
+	configFile, err := img.ConfigFile()
+	if err != nil {
+		return 0, err
+	}
+
+	baseImg, err := i.baseImage(configFile.Platform())
+	if err != nil {
+		return 0, fmt.Errorf("Fetching base image '%s': %s", i.imgDst.SquashBaseImage, err)
+	}
+
+	baseConfigFile, err := baseImg.ConfigFile()
+	if err != nil {
+		return 0, err
+	}
+
+	baseDiffIDs := baseConfigFile.RootFS.DiffIDs
+	diffIDs := configFile.RootFS.DiffIDs
+
+	var shared int
+	for shared < len(baseDiffIDs) && shared < len(diffIDs) && baseDiffIDs[shared] == diffIDs[shared] {
+		shared++
+	}
+
+	if shared != len(baseDiffIDs) {
+		return 0, fmt.Errorf("Expected image to be based on '%s', but only %d of its %d layers are shared",
+			i.imgDst.SquashBaseImage, shared, len(baseDiffIDs))
+	}
+
+	return shared, nil
+}
+
+func (i SquashedImage) baseImage(platform *regv1.Platform) (regv1.Image, error) {
+	ref, err := regname.ParseReference(i.imgDst.SquashBaseImage, regname.WeakValidation)
+	if err != nil {
+		return nil, err
+	}
+
+	desc, err := i.registry.Generic(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	switch desc.MediaType {
+	case regtypes.OCIImageIndex, regtypes.DockerManifestList:
+		idx, err := i.registry.Index(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		idxManifest, err := idx.IndexManifest()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, manifestDesc := range idxManifest.Manifests {
+			if platform != nil && manifestDesc.Platform != nil && manifestDesc.Platform.Satisfies(*platform) {
+				return idx.Image(manifestDesc.Digest)
+			}
+		}
+
+		return nil, fmt.Errorf("Expected to find image for platform '%s'", platform)
+
+	default:
+		return i.registry.Image(ref)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	semver "github.com/hashicorp/go-version"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+)
+
+// TagTemplateData is available to destination tag templates
+// (e.g. `tags: ["{{.GitSHA}}", "latest-{{.Date}}"]`)
+type TagTemplateData struct {
+	GitSHA      string
+	GitShortSHA string
+	GitTag      string
+	GitDirty    bool
+
+	Semver      string
+	SemverMajor string
+	SemverMinor string
+
+	Date      string
+	Timestamp string
+}
+
+func NewTagTemplateData(origins []ctlconf.Origin, now time.Time) TagTemplateData {
+	now = now.UTC()
+
+	data := TagTemplateData{
+		Date:      now.Format("20060102"),
+		Timestamp: now.Format("20060102150405"),
+	}
+
+	for _, origin := range origins {
+		if origin.Git == nil {
+			continue
+		}
+		if origin.Git.SHA != GitRepoHeadSHANoCommits {
+			data.GitSHA = origin.Git.SHA
+			data.GitShortSHA = origin.Git.SHA
+			if len(data.GitShortSHA) > 7 {
+				data.GitShortSHA = data.GitShortSHA[:7]
+			}
+		}
+		data.GitDirty = origin.Git.Dirty
+		if len(origin.Git.Tags) > 0 {
+			data.GitTag = origin.Git.Tags[0]
+		}
+	}
+
+	for _, origin := range origins {
+		if origin.Git == nil {
+			continue
+		}
+		for _, tag := range origin.Git.Tags {
+			ver, err := semver.NewSemver(tag)
+			if err != nil {
+				continue
+			}
+			segments := ver.Segments()
+			data.Semver = strings.TrimPrefix(tag, "v")
+			data.SemverMajor = fmt.Sprintf("%d", segments[0])
+			data.SemverMinor = fmt.Sprintf("%d.%d", segments[0], segments[1])
+			break
+		}
+	}
+
+	return data
+}
+
+// RenderTags evaluates tag templates. Tags that render
+// to an empty string (e.g. GitTag when HEAD is not tagged) are skipped.
+func RenderTags(tags []string, data TagTemplateData) ([]string, error) {
+	var result []string
+
+	for _, tag := range tags {
+		if !strings.Contains(tag, "{{") {
+			result = append(result, tag)
+			continue
+		}
+
+		tpl, err := template.New("tag").Option("missingkey=error").Parse(tag)
+		if err != nil {
+			return nil, fmt.Errorf("Parsing tag template '%s': %s", tag, err)
+		}
+
+		var buf bytes.Buffer
+
+		err = tpl.Execute(&buf, data)
+		if err != nil {
+			return nil, fmt.Errorf("Evaluating tag template '%s': %s", tag, err)
+		}
+
+		renderedTag := strings.TrimSpace(buf.String())
+		if len(renderedTag) > 0 {
+			result = append(result, renderedTag)
+		}
+	}
+
+	return result, nil
+}
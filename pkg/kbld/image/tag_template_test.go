@@ -0,0 +1,68 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+)
+
+func TestRenderTags(t *testing.T) {
+	origins := []ctlconf.Origin{
+		{Local: &ctlconf.OriginLocal{Path: "/tmp/app"}},
+		{Git: &ctlconf.OriginGit{
+			SHA:  "0123456789abcdef0123456789abcdef01234567",
+			Tags: []string{"v1.2.3"},
+		}},
+	}
+	now := time.Date(2023, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	data := ctlimg.NewTagTemplateData(origins, now)
+
+	tags, err := ctlimg.RenderTags([]string{
+		"latest",
+		"{{.GitSHA}}",
+		"sha-{{.GitShortSHA}}",
+		"{{.GitTag}}",
+		"{{.Semver}}",
+		"{{.SemverMajor}}",
+		"{{.SemverMinor}}",
+		"latest-{{.Date}}",
+		"build-{{.Timestamp}}",
+	}, data)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"latest",
+		"0123456789abcdef0123456789abcdef01234567",
+		"sha-0123456",
+		"v1.2.3",
+		"1.2.3",
+		"1",
+		"1.2",
+		"latest-20230506",
+		"build-20230506070809",
+	}, tags)
+}
+
+func TestRenderTagsSkipsEmpty(t *testing.T) {
+	data := ctlimg.NewTagTemplateData([]ctlconf.Origin{
+		{Git: &ctlconf.OriginGit{SHA: "abc"}},
+	}, time.Now())
+
+	tags, err := ctlimg.RenderTags([]string{"{{.GitTag}}", "{{.Semver}}", "{{.GitShortSHA}}"}, data)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc"}, tags)
+}
+
+func TestRenderTagsUnknownVariable(t *testing.T) {
+	_, err := ctlimg.RenderTags([]string{"{{.Unknown}}"}, ctlimg.TagTemplateData{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Evaluating tag template '{{.Unknown}}'")
+}
@@ -4,7 +4,8 @@
 package image
 
 import (
-	regname "github.com/google/go-containerregistry/pkg/name"
+	"time"
+
 	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
 	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
 )
@@ -26,27 +27,18 @@ func (i TaggedImage) URL() (string, []ctlconf.Origin, error) {
 		return "", nil, err
 	}
 
-	if len(i.imgDst.Tags) > 0 {
-		dstRef, err := regname.NewDigest(url, regname.WeakValidation)
-		if err != nil {
-			return "", nil, err
-		}
+	tags, err := RenderTags(i.imgDst.Tags, NewTagTemplateData(origins, time.Now()))
+	if err != nil {
+		return "", nil, err
+	}
 
-		// Keep this ref separate to avoid any kind of modification
-		// when changing tag on the dst ref
-		srcRef, err := regname.NewDigest(url, regname.WeakValidation)
+	if len(tags) > 0 {
+		err := writeTags(i.registry, url, tags, i.imgDst.ImmutableTags)
 		if err != nil {
 			return "", nil, err
 		}
 
-		for _, tag := range i.imgDst.Tags {
-			err := i.registry.WriteTag(dstRef.Context().Tag(tag), srcRef)
-			if err != nil {
-				return "", nil, err
-			}
-		}
-
-		origins = append(origins, ctlconf.Origin{Tagged: &ctlconf.OriginTagged{Tags: i.imgDst.Tags}})
+		origins = append(origins, ctlconf.Origin{Tagged: &ctlconf.OriginTagged{Tags: tags}})
 	}
 
 	return url, origins, err
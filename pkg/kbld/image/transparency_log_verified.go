@@ -0,0 +1,50 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"fmt"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlsign "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/signing"
+)
+
+// TransparencyLogVerifiedImage requires image (typically coming from a lock file)
+// to have its recorded signatures included in transparency log
+type TransparencyLogVerifiedImage struct {
+	image Image
+	rekor ctlsign.Rekor
+}
+
+func NewTransparencyLogVerifiedImage(image Image, rekor ctlsign.Rekor) TransparencyLogVerifiedImage {
+	return TransparencyLogVerifiedImage{image, rekor}
+}
+
+func (i TransparencyLogVerifiedImage) URL() (string, []ctlconf.Origin, error) {
+	url, origins, err := i.image.URL()
+	if err != nil {
+		return "", nil, err
+	}
+
+	var entries []ctlconf.OriginTransparencyLogEntry
+
+	for _, origin := range origins {
+		if origin.Signed != nil {
+			entries = append(entries, origin.Signed.TransparencyLogEntries...)
+		}
+	}
+
+	if len(entries) == 0 {
+		return "", nil, fmt.Errorf("Expected image '%s' to have transparency log entries recorded in its origins", url)
+	}
+
+	for _, entry := range entries {
+		err := i.rekor.Verify(url, entry)
+		if err != nil {
+			return "", nil, fmt.Errorf("Expected image '%s' signature to be in transparency log '%s': %s", url, entry.URL, err)
+		}
+	}
+
+	return url, origins, nil
+}
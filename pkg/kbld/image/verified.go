@@ -0,0 +1,55 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"fmt"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlsign "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/signing"
+)
+
+// VerifiedImage requires resolved image to satisfy all applicable verification policies
+type VerifiedImage struct {
+	image    Image
+	policies []ctlconf.VerificationPolicy
+	verifier ctlsign.Verifier
+}
+
+func NewVerifiedImage(image Image, policies []ctlconf.VerificationPolicy, verifier ctlsign.Verifier) VerifiedImage {
+	return VerifiedImage{image, policies, verifier}
+}
+
+func (i VerifiedImage) URL() (string, []ctlconf.Origin, error) {
+	url, origins, err := i.image.URL()
+	if err != nil {
+		return "", nil, err
+	}
+
+	var satisfied []string
+
+	// Policies are matched against resolved url so that
+	// overrides cannot be used to avoid verification
+	urlMatcher := Matcher{url}
+
+	for _, policy := range i.policies {
+		if !policy.AppliesToAll() && !urlMatcher.Matches(policy.ImageRef) {
+			continue
+		}
+
+		desc, err := i.verifier.Verify(url, policy)
+		if err != nil {
+			return "", nil, fmt.Errorf("Expected image to satisfy verification policy (%s): %s",
+				ctlsign.PolicyDescription(policy), err)
+		}
+
+		satisfied = append(satisfied, desc)
+	}
+
+	if len(satisfied) > 0 {
+		origins = append(origins, ctlconf.Origin{Verified: &ctlconf.OriginVerified{Policies: satisfied}})
+	}
+
+	return url, origins, nil
+}
@@ -14,12 +14,19 @@ import (
 type DescribedImage struct {
 	desc          ImageDescriptor
 	layerProvider LayerProvider
+	opts          DescribedReaderOpts
 }
 
 var _ regv1.Image = DescribedImage{}
 
 func NewDescribedImage(desc ImageDescriptor, layerProvider LayerProvider) DescribedImage {
-	return DescribedImage{desc, layerProvider}
+	return DescribedImage{desc, layerProvider, DescribedReaderOpts{}}
+}
+
+func NewDescribedImageWithOpts(desc ImageDescriptor,
+	layerProvider LayerProvider, opts DescribedReaderOpts) DescribedImage {
+
+	return DescribedImage{desc, layerProvider, opts}
 }
 
 func (i DescribedImage) Ref() string { return i.desc.Refs[0] }
@@ -30,7 +37,7 @@ func (i DescribedImage) Layers() ([]regv1.Layer, error) {
 	var layers []regv1.Layer
 	for _, layerTD := range i.desc.Layers {
 		var layer regv1.Layer
-		if layerTD.IsDistributable() {
+		if layerTD.IsDistributable() || i.opts.IncludeNonDistributable {
 			layerFile, err := i.layerProvider.FindLayer(layerTD)
 			if err != nil {
 				return nil, err
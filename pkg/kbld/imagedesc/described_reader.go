@@ -10,10 +10,23 @@ import (
 type DescribedReader struct {
 	ids           *ImageRefDescriptors
 	layerProvider LayerProvider
+	opts          DescribedReaderOpts
+}
+
+type DescribedReaderOpts struct {
+	// IncludeNonDistributable makes non-distributable (foreign) layers
+	// available from layer provider instead of being only referenced
+	IncludeNonDistributable bool
 }
 
 func NewDescribedReader(ids *ImageRefDescriptors, layerProvider LayerProvider) DescribedReader {
-	return DescribedReader{ids, layerProvider}
+	return DescribedReader{ids, layerProvider, DescribedReaderOpts{}}
+}
+
+func NewDescribedReaderWithOpts(ids *ImageRefDescriptors,
+	layerProvider LayerProvider, opts DescribedReaderOpts) DescribedReader {
+
+	return DescribedReader{ids, layerProvider, opts}
 }
 
 func (r DescribedReader) Read() []ImageOrIndex {
@@ -22,7 +35,7 @@ func (r DescribedReader) Read() []ImageOrIndex {
 	for _, td := range r.ids.Descriptors() {
 		switch {
 		case td.Image != nil:
-			var img ImageWithRef = NewDescribedImage(*td.Image, r.layerProvider)
+			var img ImageWithRef = NewDescribedImageWithOpts(*td.Image, r.layerProvider, r.opts)
 			result = append(result, ImageOrIndex{Image: &img})
 
 		case td.ImageIndex != nil:
@@ -42,7 +55,7 @@ func (r DescribedReader) buildIndex(iitd ImageIndexDescriptor) ImageIndexWithRef
 	var indexes []regv1.ImageIndex
 
 	for _, imgTD := range iitd.Images {
-		images = append(images, NewDescribedImage(imgTD, r.layerProvider))
+		images = append(images, NewDescribedImageWithOpts(imgTD, r.layerProvider, r.opts))
 	}
 	for _, indexTD := range iitd.Indexes {
 		indexes = append(indexes, r.buildIndex(indexTD))
@@ -4,6 +4,7 @@
 package imagedesc
 
 import (
+	"fmt"
 	"io"
 
 	regv1 "github.com/google/go-containerregistry/pkg/v1"
@@ -24,11 +25,18 @@ func (l ForeignDescribedLayer) Digest() (regv1.Hash, error) { return regv1.NewHa
 func (l ForeignDescribedLayer) DiffID() (regv1.Hash, error) { return regv1.NewHash(l.desc.DiffID) }
 
 func (l ForeignDescribedLayer) Compressed() (io.ReadCloser, error) {
-	panic("ForeignDescribedLayer.Compressed: not implemented")
+	return nil, l.contentsErr()
 }
 
 func (l ForeignDescribedLayer) Uncompressed() (io.ReadCloser, error) {
-	panic("ForeignDescribedLayer.Uncompressed: not implemented")
+	return nil, l.contentsErr()
+}
+
+// contentsErr is returned when operation (e.g. squashing) needs contents
+// of a layer that was not included (e.g. Windows base layer)
+func (l ForeignDescribedLayer) contentsErr() error {
+	return fmt.Errorf("Expected contents of non-distributable layer '%s' to be available "+
+		"(include non-distributable layers to use them)", l.desc.Digest)
 }
 
 func (l ForeignDescribedLayer) Size() (int64, error) { return l.desc.Size, nil }
@@ -38,11 +38,42 @@ func (f tarFile) Chunk(path string) tarFileChunk {
 }
 
 func (f tarFile) FindLayer(layerTD imagedesc.ImageLayerDescriptor) (imagedesc.LayerContents, error) {
+	path, err := f.layerChunkPath(layerTD)
+	if err != nil {
+		return nil, err
+	}
+	return tarFileChunk{f, path}, nil
+}
+
+func (f tarFile) layerChunkPath(layerTD imagedesc.ImageLayerDescriptor) (string, error) {
 	digest, err := regv1.NewHash(layerTD.Digest)
+	if err != nil {
+		return "", err
+	}
+	return digest.Algorithm + "-" + digest.Hex + ".tar.gz", nil
+}
+
+// ChunkPaths returns names of all entries stored in the tar file
+func (f tarFile) ChunkPaths() (map[string]struct{}, error) {
+	file, err := os.Open(f.path)
 	if err != nil {
 		return nil, err
 	}
-	return tarFileChunk{f, digest.Algorithm + "-" + digest.Hex + ".tar.gz"}, nil
+	defer file.Close()
+
+	result := map[string]struct{}{}
+	tf := tar.NewReader(file)
+	for {
+		hdr, err := tf.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		result[hdr.Name] = struct{}{}
+	}
+	return result, nil
 }
 
 func (f tarFileChunk) Open() (io.ReadCloser, error) {
@@ -4,17 +4,29 @@
 package imagetar
 
 import (
+	"fmt"
 	"io"
 
 	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/imagedesc"
 )
 
+type TarReaderOpts struct {
+	// RequireNonDistributable fails unless tarball includes foreign layers
+	// (i.e. it was created with non-distributable layers)
+	RequireNonDistributable bool
+}
+
 type TarReader struct {
 	path string
+	opts TarReaderOpts
 }
 
 func NewTarReader(path string) TarReader {
-	return TarReader{path}
+	return TarReader{path: path}
+}
+
+func NewTarReaderWithOpts(path string, opts TarReaderOpts) TarReader {
+	return TarReader{path, opts}
 }
 
 func (r TarReader) Read() ([]imagedesc.ImageOrIndex, error) {
@@ -35,5 +47,90 @@ func (r TarReader) Read() ([]imagedesc.ImageOrIndex, error) {
 		return nil, err
 	}
 
-	return imagedesc.NewDescribedReader(ids, file).Read(), nil
+	includeNonDistributable, err := r.includesNonDistributable(file, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.opts.RequireNonDistributable && !includeNonDistributable {
+		return nil, r.nonDistributableErr(ids)
+	}
+
+	opts := imagedesc.DescribedReaderOpts{IncludeNonDistributable: includeNonDistributable}
+
+	return imagedesc.NewDescribedReaderWithOpts(ids, file, opts).Read(), nil
+}
+
+// includesNonDistributable checks if tarball was created with
+// non-distributable layers (all such layers have to be present)
+func (r TarReader) includesNonDistributable(file tarFile, ids *imagedesc.ImageRefDescriptors) (bool, error) {
+	var foreignLayers []imagedesc.ImageLayerDescriptor
+
+	for _, td := range ids.Descriptors() {
+		foreignLayers = append(foreignLayers, r.foreignLayers(td)...)
+	}
+
+	if len(foreignLayers) == 0 {
+		return false, nil
+	}
+
+	chunkPaths, err := file.ChunkPaths()
+	if err != nil {
+		return false, err
+	}
+
+	for _, layerTD := range foreignLayers {
+		path, err := file.layerChunkPath(layerTD)
+		if err != nil {
+			return false, err
+		}
+		if _, found := chunkPaths[path]; !found {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (r TarReader) nonDistributableErr(ids *imagedesc.ImageRefDescriptors) error {
+	for _, td := range ids.Descriptors() {
+		if len(r.foreignLayers(td)) > 0 {
+			return fmt.Errorf("Expected tarball '%s' to include non-distributable (foreign) layers, "+
+				"but it was created without them (package images with '--include-non-distributable')", r.path)
+		}
+	}
+	return fmt.Errorf("Expected tarball '%s' to include non-distributable (foreign) layers, "+
+		"but its images do not have any (remove '--include-non-distributable')", r.path)
+}
+
+func (r TarReader) foreignLayers(td imagedesc.ImageOrImageIndexDescriptor) []imagedesc.ImageLayerDescriptor {
+	var result []imagedesc.ImageLayerDescriptor
+
+	var fromImage func(imagedesc.ImageDescriptor)
+	var fromIndex func(imagedesc.ImageIndexDescriptor)
+
+	fromImage = func(img imagedesc.ImageDescriptor) {
+		for _, layerTD := range img.Layers {
+			if !layerTD.IsDistributable() {
+				result = append(result, layerTD)
+			}
+		}
+	}
+	fromIndex = func(idx imagedesc.ImageIndexDescriptor) {
+		for _, img := range idx.Images {
+			fromImage(img)
+		}
+		for _, nestedIdx := range idx.Indexes {
+			fromIndex(nestedIdx)
+		}
+	}
+
+	switch {
+	case td.Image != nil:
+		fromImage(*td.Image)
+	case td.ImageIndex != nil:
+		fromIndex(*td.ImageIndex)
+	}
+
+	return result
 }
@@ -23,6 +23,8 @@ type Logger interface {
 
 type TarWriterOpts struct {
 	Concurrency int
+	// IncludeNonDistributable includes foreign layers into the tarball
+	IncludeNonDistributable bool
 }
 
 type TarWriter struct {
@@ -107,10 +109,10 @@ func (w *TarWriter) writeImageIndex(td imagedesc.ImageIndexDescriptor) error {
 
 func (w *TarWriter) writeImage(td imagedesc.ImageDescriptor) error {
 	for _, imgLayer := range td.Layers {
-		// TODO anything else we can do to deal with this?
-		// Do not include foreign layers since we cannot
-		// import without changing image digest.
-		if imgLayer.IsDistributable() {
+		// Do not include foreign layers by default since their
+		// licensing may not allow redistribution. Image digest remains
+		// the same either way since layer descriptors are not modified.
+		if imgLayer.IsDistributable() || w.opts.IncludeNonDistributable {
 			w.layersToWrite = append(w.layersToWrite, imgLayer)
 		}
 	}
@@ -0,0 +1,283 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package convert
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/containerd/stargz-snapshotter/estargz"
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	regtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+type Format string
+
+const (
+	FormatEstargz     Format = "estargz"
+	FormatZstdChunked Format = "zstd:chunked"
+)
+
+var (
+	Formats = []Format{FormatEstargz, FormatZstdChunked}
+)
+
+func ParseFormat(str string) (Format, error) {
+	for _, format := range Formats {
+		if string(format) == str {
+			return format, nil
+		}
+	}
+	var formats []string
+	for _, format := range Formats {
+		formats = append(formats, string(format))
+	}
+	return "", fmt.Errorf("Expected layer format '%s' to be one of: %s", str, strings.Join(formats, ", "))
+}
+
+// Converter converts image layers into formats suitable
+// for lazy pulling (e.g. stargz or nydus snapshotters).
+// Converted layers are kept in temporary files until Cleanup is called.
+type Converter struct {
+	format Format
+	tmpDir string
+}
+
+func NewConverter(format Format) (*Converter, error) {
+	tmpDir, err := util.MkdirTemp("kbld-convert-")
+	if err != nil {
+		return nil, fmt.Errorf("Creating layer conversion directory: %s", err)
+	}
+	return &Converter{format, tmpDir}, nil
+}
+
+func (c *Converter) Cleanup() error { return os.RemoveAll(c.tmpDir) }
+
+// Image returns image with converted layers. Layers that are not plain
+// filesystem layers (e.g. attestations) or already converted are kept as is.
+func (c *Converter) Image(img regv1.Image) (regv1.Image, error) {
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	configFile, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+
+	manifestMediaType, err := img.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	configMediaType := manifest.Config.MediaType
+
+	if c.format == FormatZstdChunked {
+		// zstd compressed layers are only defined for OCI images
+		manifestMediaType = regtypes.OCIManifestSchema1
+		if configMediaType == regtypes.DockerConfigJSON {
+			configMediaType = regtypes.OCIConfigJSON
+		}
+	}
+
+	var adds []mutate.Addendum
+	var diffIDs []regv1.Hash
+
+	for i, layer := range layers {
+		layerDesc := manifest.Layers[i]
+
+		add := mutate.Addendum{
+			Layer:       layer,
+			URLs:        layerDesc.URLs,
+			Annotations: layerDesc.Annotations,
+			MediaType:   layerDesc.MediaType,
+		}
+
+		// Foreign (e.g. Windows base) layers are kept but need
+		// OCI equivalent media type when manifest is converted to OCI
+		if layerDesc.MediaType == regtypes.DockerForeignLayer && manifestMediaType == regtypes.OCIManifestSchema1 {
+			add.MediaType = regtypes.OCIRestrictedLayer
+		}
+
+		if c.convertible(layerDesc) {
+			convertedLayer, annotations, err := c.layer(layer, manifestMediaType)
+			if err != nil {
+				return nil, fmt.Errorf("Converting layer '%s' to %s: %s", layerDesc.Digest, c.format, err)
+			}
+
+			allAnnotations := map[string]string{}
+			for k, v := range layerDesc.Annotations {
+				allAnnotations[k] = v
+			}
+			for k, v := range annotations {
+				allAnnotations[k] = v
+			}
+
+			add = mutate.Addendum{
+				Layer:       convertedLayer,
+				Annotations: allAnnotations,
+				MediaType:   convertedLayer.mediaType,
+			}
+		}
+
+		diffID, err := add.Layer.DiffID()
+		if err != nil {
+			return nil, err
+		}
+
+		adds = append(adds, add)
+		diffIDs = append(diffIDs, diffID)
+	}
+
+	result, err := mutate.Append(mutate.MediaType(empty.Image, manifestMediaType), adds...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep original config (including history) but refer to converted layers
+	configFile = configFile.DeepCopy()
+	configFile.RootFS.DiffIDs = diffIDs
+
+	result, err = mutate.ConfigFile(result, configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	result = mutate.ConfigMediaType(result, configMediaType)
+
+	if len(manifest.Annotations) > 0 {
+		result = mutate.Annotations(result, manifest.Annotations).(regv1.Image)
+	}
+
+	return result, nil
+}
+
+// Index returns index with converted images (nested indexes are converted as well)
+func (c *Converter) Index(idx regv1.ImageIndex) (regv1.ImageIndex, error) {
+	var mediaType regtypes.MediaType
+	if c.format == FormatZstdChunked {
+		mediaType = regtypes.OCIImageIndex
+	}
+	return MapIndex(idx, mediaType, c.Image)
+}
+
+func (c *Converter) convertible(desc regv1.Descriptor) bool {
+	switch desc.MediaType {
+	case regtypes.DockerLayer, regtypes.DockerUncompressedLayer,
+		regtypes.OCILayer, regtypes.OCIUncompressedLayer, regtypes.OCILayerZStd:
+	default:
+		return false
+	}
+
+	switch c.format {
+	case FormatEstargz:
+		_, found := desc.Annotations[estargz.TOCJSONDigestAnnotation]
+		return !found
+	case FormatZstdChunked:
+		_, found := desc.Annotations[zstdChunkedManifestChecksumAnnotation]
+		return !found
+	default:
+		panic(fmt.Sprintf("Unknown layer format '%s'", c.format))
+	}
+}
+
+func (c *Converter) layer(layer regv1.Layer, manifestMediaType regtypes.MediaType) (*convertedLayer, map[string]string, error) {
+	tarFile, err := os.CreateTemp(c.tmpDir, "layer-*.tar")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.Remove(tarFile.Name())
+	defer tarFile.Close()
+
+	uncompressed, err := layer.Uncompressed()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tarSize, err := io.Copy(tarFile, uncompressed)
+	uncompressed.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = util.CheckTmpDiskUsage()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	annotations := map[string]string{}
+	result := &convertedLayer{}
+
+	var opts []estargz.Option
+
+	switch c.format {
+	case FormatEstargz:
+		opts = append(opts, estargz.WithCompression(newEstargzCompression(gzip.BestCompression)))
+		result.decompress = func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) }
+		result.mediaType = regtypes.OCILayer
+		if manifestMediaType == regtypes.DockerManifestSchema2 {
+			result.mediaType = regtypes.DockerLayer
+		}
+
+	case FormatZstdChunked:
+		compression := &zstdChunkedCompression{metadata: annotations}
+		opts = append(opts, estargz.WithCompression(compression))
+		result.decompress = compression.Reader
+		result.mediaType = regtypes.OCILayerZStd
+	}
+
+	blob, err := estargz.Build(io.NewSectionReader(tarFile, 0, tarSize), opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer blob.Close()
+
+	blobFile, err := os.CreateTemp(c.tmpDir, "layer-*.blob")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer blobFile.Close()
+
+	hasher := sha256.New()
+
+	result.size, err = io.Copy(io.MultiWriter(blobFile, hasher), blob)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = util.CheckTmpDiskUsage()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// DiffID is only available once blob is closed
+	err = blob.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result.path = blobFile.Name()
+	result.digest = regv1.Hash{Algorithm: "sha256", Hex: fmt.Sprintf("%x", hasher.Sum(nil))}
+
+	result.diffID, err = regv1.NewHash(blob.DiffID().String())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	annotations[estargz.TOCJSONDigestAnnotation] = blob.TOCDigest().String()
+
+	return result, annotations, nil
+}
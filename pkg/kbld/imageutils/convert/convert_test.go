@@ -0,0 +1,205 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package convert_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/containerd/stargz-snapshotter/estargz"
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	regtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/imageutils/convert"
+)
+
+func TestConverterImage(t *testing.T) {
+	var tarBuf bytes.Buffer
+	tarWriter := tar.NewWriter(&tarBuf)
+	contents := []byte("hello")
+	require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: "hello.txt", Mode: 0644, Size: int64(len(contents))}))
+	_, err := tarWriter.Write(contents)
+	require.NoError(t, err)
+	require.NoError(t, tarWriter.Close())
+
+	layer, err := tarball.LayerFromReader(bytes.NewReader(tarBuf.Bytes()))
+	require.NoError(t, err)
+
+	img, err := mutate.AppendLayers(mutate.MediaType(empty.Image, regtypes.DockerManifestSchema2), layer)
+	require.NoError(t, err)
+
+	idx := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{
+		Add:        img,
+		Descriptor: regv1.Descriptor{Platform: &regv1.Platform{OS: "linux", Architecture: "amd64"}},
+	})
+
+	readFile := func(t *testing.T, layer regv1.Layer) string {
+		rc, err := layer.Uncompressed()
+		require.NoError(t, err)
+		defer rc.Close()
+
+		tarReader := tar.NewReader(rc)
+		for {
+			header, err := tarReader.Next()
+			require.NoError(t, err)
+			if header.Name == "hello.txt" {
+				bs, err := io.ReadAll(tarReader)
+				require.NoError(t, err)
+				return string(bs)
+			}
+		}
+	}
+
+	t.Run("converts layers to estargz", func(t *testing.T) {
+		converter, err := convert.NewConverter(convert.FormatEstargz)
+		require.NoError(t, err)
+		defer converter.Cleanup()
+
+		convertedImg, err := converter.Image(img)
+		require.NoError(t, err)
+
+		manifest, err := convertedImg.Manifest()
+		require.NoError(t, err)
+		require.Len(t, manifest.Layers, 1)
+
+		assert.Equal(t, regtypes.DockerManifestSchema2, manifest.MediaType)
+		assert.Equal(t, regtypes.DockerLayer, manifest.Layers[0].MediaType)
+		assert.Contains(t, manifest.Layers[0].Annotations, estargz.TOCJSONDigestAnnotation)
+
+		layers, err := convertedImg.Layers()
+		require.NoError(t, err)
+
+		compressed, err := layers[0].Compressed()
+		require.NoError(t, err)
+		blob, err := io.ReadAll(compressed)
+		require.NoError(t, err)
+		compressed.Close()
+
+		reader, err := estargz.Open(io.NewSectionReader(bytes.NewReader(blob), 0, int64(len(blob))))
+		require.NoError(t, err)
+		_, found := reader.Lookup("hello.txt")
+		assert.True(t, found)
+
+		assert.Equal(t, "hello", readFile(t, layers[0]))
+
+		// Digests of converted image must be consistent with its contents
+		configFile, err := convertedImg.ConfigFile()
+		require.NoError(t, err)
+		diffID, err := layers[0].DiffID()
+		require.NoError(t, err)
+		assert.Equal(t, []regv1.Hash{diffID}, configFile.RootFS.DiffIDs)
+	})
+
+	t.Run("converts layers within index to zstd:chunked", func(t *testing.T) {
+		converter, err := convert.NewConverter(convert.FormatZstdChunked)
+		require.NoError(t, err)
+		defer converter.Cleanup()
+
+		convertedIdx, err := converter.Index(idx)
+		require.NoError(t, err)
+
+		idxManifest, err := convertedIdx.IndexManifest()
+		require.NoError(t, err)
+		require.Len(t, idxManifest.Manifests, 1)
+
+		assert.Equal(t, regtypes.OCIImageIndex, idxManifest.MediaType)
+		assert.Equal(t, "amd64", idxManifest.Manifests[0].Platform.Architecture)
+
+		convertedImg, err := convertedIdx.Image(idxManifest.Manifests[0].Digest)
+		require.NoError(t, err)
+
+		manifest, err := convertedImg.Manifest()
+		require.NoError(t, err)
+
+		assert.Equal(t, regtypes.OCIManifestSchema1, manifest.MediaType)
+		assert.Equal(t, regtypes.OCIConfigJSON, manifest.Config.MediaType)
+		assert.Equal(t, regtypes.OCILayerZStd, manifest.Layers[0].MediaType)
+		assert.Contains(t, manifest.Layers[0].Annotations, "io.containers.zstd-chunked.manifest-checksum")
+		assert.Contains(t, manifest.Layers[0].Annotations, "io.containers.zstd-chunked.manifest-position")
+
+		layers, err := convertedImg.Layers()
+		require.NoError(t, err)
+		assert.Equal(t, "hello", readFile(t, layers[0]))
+	})
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := convert.ParseFormat("zstd:chunked")
+	require.NoError(t, err)
+	assert.Equal(t, convert.FormatZstdChunked, format)
+
+	_, err = convert.ParseFormat("gzip")
+	require.EqualError(t, err, "Expected layer format 'gzip' to be one of: estargz, zstd:chunked")
+}
+
+func TestFilterIndex(t *testing.T) {
+	newImage := func(t *testing.T, contents string) regv1.Image {
+		layer, err := tarball.LayerFromReader(bytes.NewReader([]byte(contents)))
+		require.NoError(t, err)
+		img, err := mutate.AppendLayers(empty.Image, layer)
+		require.NoError(t, err)
+		return img
+	}
+
+	linuxImg := newImage(t, "linux")
+	windowsImg := newImage(t, "windows")
+	windowsAttestation := newImage(t, "attestation")
+
+	windowsDigest, err := windowsImg.Digest()
+	require.NoError(t, err)
+
+	idx := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{
+		Add:        linuxImg,
+		Descriptor: regv1.Descriptor{Platform: &regv1.Platform{OS: "linux", Architecture: "amd64"}},
+	}, mutate.IndexAddendum{
+		Add:        windowsImg,
+		Descriptor: regv1.Descriptor{Platform: &regv1.Platform{OS: "windows", Architecture: "amd64"}},
+	}, mutate.IndexAddendum{
+		Add: windowsAttestation,
+		Descriptor: regv1.Descriptor{
+			Platform:    &regv1.Platform{OS: "unknown", Architecture: "unknown"},
+			Annotations: map[string]string{"vnd.docker.reference.digest": windowsDigest.String()},
+		},
+	})
+
+	digests := func(t *testing.T, idx regv1.ImageIndex) []regv1.Hash {
+		idxManifest, err := idx.IndexManifest()
+		require.NoError(t, err)
+
+		var result []regv1.Hash
+		for _, desc := range idxManifest.Manifests {
+			result = append(result, desc.Digest)
+		}
+		return result
+	}
+
+	t.Run("removes images of other platforms and their attestations", func(t *testing.T) {
+		filteredIdx, err := convert.FilterIndex(idx, func(platform regv1.Platform) bool { return platform.OS == "linux" })
+		require.NoError(t, err)
+
+		linuxDigest, err := linuxImg.Digest()
+		require.NoError(t, err)
+		assert.Equal(t, []regv1.Hash{linuxDigest}, digests(t, filteredIdx))
+	})
+
+	t.Run("keeps attestations of kept images", func(t *testing.T) {
+		filteredIdx, err := convert.FilterIndex(idx, func(platform regv1.Platform) bool { return platform.OS == "windows" })
+		require.NoError(t, err)
+
+		attestationDigest, err := windowsAttestation.Digest()
+		require.NoError(t, err)
+		assert.Equal(t, []regv1.Hash{windowsDigest, attestationDigest}, digests(t, filteredIdx))
+	})
+
+	t.Run("fails when no images match", func(t *testing.T) {
+		_, err := convert.FilterIndex(idx, func(platform regv1.Platform) bool { return false })
+		require.EqualError(t, err, "Expected index to include at least one manifest with matching platform, but found none")
+	})
+}
@@ -0,0 +1,52 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package convert
+
+import (
+	"io"
+	"os"
+
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	regtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/imageutils/and"
+)
+
+// convertedLayer is backed by a file with compressed layer contents
+type convertedLayer struct {
+	path       string
+	digest     regv1.Hash
+	diffID     regv1.Hash
+	size       int64
+	mediaType  regtypes.MediaType
+	decompress func(io.Reader) (io.ReadCloser, error)
+}
+
+var _ regv1.Layer = &convertedLayer{}
+
+func (l *convertedLayer) Digest() (regv1.Hash, error)            { return l.digest, nil }
+func (l *convertedLayer) DiffID() (regv1.Hash, error)            { return l.diffID, nil }
+func (l *convertedLayer) Size() (int64, error)                   { return l.size, nil }
+func (l *convertedLayer) MediaType() (regtypes.MediaType, error) { return l.mediaType, nil }
+func (l *convertedLayer) Compressed() (io.ReadCloser, error)     { return os.Open(l.path) }
+
+func (l *convertedLayer) Uncompressed() (io.ReadCloser, error) {
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := l.decompress(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &and.ReadCloser{
+		Reader: rc,
+		CloseFunc: func() error {
+			rc.Close()
+			return file.Close()
+		},
+	}, nil
+}
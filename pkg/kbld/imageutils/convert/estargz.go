@@ -0,0 +1,104 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package convert
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/containerd/stargz-snapshotter/estargz"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// estargzCompression implements estargz.Compression producing
+// gzip based eStargz, same as default estargz compression,
+// except that footer is assembled by hand: its size must be exactly
+// estargz.FooterSize bytes, which is not guaranteed by all versions
+// of compress/gzip when encoding empty stream.
+type estargzCompression struct {
+	*estargz.GzipCompressor
+	*estargz.GzipDecompressor
+	level int
+}
+
+var _ estargz.Compression = &estargzCompression{}
+
+func newEstargzCompression(level int) *estargzCompression {
+	return &estargzCompression{estargz.NewGzipCompressorWithLevel(level), &estargz.GzipDecompressor{}, level}
+}
+
+func (c *estargzCompression) WriteTOCAndFooter(w io.Writer, off int64,
+	toc *estargz.JTOC, diffHash hash.Hash) (digest.Digest, error) {
+
+	tocJSON, err := json.MarshalIndent(toc, "", "\t")
+	if err != nil {
+		return "", err
+	}
+
+	gz, err := gzip.NewWriterLevel(w, c.level)
+	if err != nil {
+		return "", err
+	}
+
+	gw := io.Writer(gz)
+	if diffHash != nil {
+		gw = io.MultiWriter(gz, diffHash)
+	}
+
+	tw := tar.NewWriter(gw)
+
+	err = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: estargz.TOCTarName, Size: int64(len(tocJSON))})
+	if err != nil {
+		return "", err
+	}
+	_, err = tw.Write(tocJSON)
+	if err != nil {
+		return "", err
+	}
+	err = tw.Close()
+	if err != nil {
+		return "", err
+	}
+	err = gz.Close()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = w.Write(estargzFooter(off))
+	if err != nil {
+		return "", err
+	}
+
+	return digest.FromBytes(tocJSON), nil
+}
+
+// estargzFooter returns empty gzip member carrying TOC offset
+// in extra header field (https://tools.ietf.org/html/rfc1952#section-2.3.1.1)
+func estargzFooter(tocOff int64) []byte {
+	subfield := fmt.Sprintf("%016xSTARGZ", tocOff)
+
+	extra := []byte{'S', 'G', 0, 0}
+	binary.LittleEndian.PutUint16(extra[2:4], uint16(len(subfield)))
+	extra = append(extra, subfield...)
+
+	// ID1, ID2, CM (deflate), FLG (FEXTRA), MTIME, XFL, OS (unknown)
+	footer := []byte{0x1f, 0x8b, 8, 4, 0, 0, 0, 0, 0, 0xff}
+	footer = binary.LittleEndian.AppendUint16(footer, uint16(len(extra)))
+	footer = append(footer, extra...)
+	// Final stored deflate block with no data
+	footer = append(footer, 0x01, 0x00, 0x00, 0xff, 0xff)
+	// CRC32 and size of empty data
+	footer = append(footer, 0, 0, 0, 0, 0, 0, 0, 0)
+
+	if len(footer) != estargz.FooterSize {
+		panic(fmt.Sprintf("Expected eStargz footer to be %d bytes, but was %d", estargz.FooterSize, len(footer)))
+	}
+
+	return footer
+}
@@ -0,0 +1,156 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package convert
+
+import (
+	"fmt"
+
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	regtypes "github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const (
+	// referenceDigestAnnotation is used by BuildKit attestation manifests
+	// to point to the image they describe
+	referenceDigestAnnotation = "vnd.docker.reference.digest"
+)
+
+// MapIndex returns index with each image (including images of nested indexes)
+// replaced by result of mapImage. Index media type is changed to given one (if any).
+func MapIndex(idx regv1.ImageIndex, mediaType regtypes.MediaType,
+	mapImage func(regv1.Image) (regv1.Image, error)) (regv1.ImageIndex, error) {
+
+	idxManifest, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(mediaType) == 0 {
+		mediaType, err = idx.MediaType()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var adds []mutate.IndexAddendum
+	convertedDigests := map[string]string{}
+
+	for _, desc := range idxManifest.Manifests {
+		var add mutate.Appendable
+
+		switch {
+		case desc.MediaType.IsIndex():
+			childIdx, err := idx.ImageIndex(desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			convertedIdx, err := MapIndex(childIdx, mediaType, mapImage)
+			if err != nil {
+				return nil, err
+			}
+			add = convertedIdx
+
+		case desc.MediaType.IsImage():
+			childImg, err := idx.Image(desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			convertedImg, err := mapImage(childImg)
+			if err != nil {
+				return nil, err
+			}
+			add = convertedImg
+
+		default:
+			return nil, fmt.Errorf("Expected index '%s' to only contain images or indexes, but found '%s'",
+				desc.Digest, desc.MediaType)
+		}
+
+		convertedDigest, err := add.Digest()
+		if err != nil {
+			return nil, err
+		}
+		convertedMediaType, err := add.MediaType()
+		if err != nil {
+			return nil, err
+		}
+
+		convertedDigests[desc.Digest.String()] = convertedDigest.String()
+
+		adds = append(adds, mutate.IndexAddendum{
+			Add: add,
+			Descriptor: regv1.Descriptor{
+				MediaType:   convertedMediaType,
+				Platform:    desc.Platform,
+				Annotations: desc.Annotations,
+			},
+		})
+	}
+
+	// Point attestations to mapped images
+	for i, add := range adds {
+		refDigest, found := add.Descriptor.Annotations[referenceDigestAnnotation]
+		if !found {
+			continue
+		}
+		if convertedDigest, found := convertedDigests[refDigest]; found {
+			annotations := map[string]string{}
+			for k, v := range add.Descriptor.Annotations {
+				annotations[k] = v
+			}
+			annotations[referenceDigestAnnotation] = convertedDigest
+			adds[i].Descriptor.Annotations = annotations
+		}
+	}
+
+	result := mutate.AppendManifests(mutate.IndexMediaType(empty.Index, mediaType), adds...)
+
+	if len(idxManifest.Annotations) > 0 {
+		result = mutate.Annotations(result, idxManifest.Annotations).(regv1.ImageIndex)
+	}
+
+	return result, nil
+}
+
+// FilterIndex returns index that only includes manifests with platform
+// accepted by keep (manifests without platform are kept). Attestation
+// manifests are kept only when images they describe are kept.
+// Platforms within nested indexes are not filtered.
+func FilterIndex(idx regv1.ImageIndex, keep func(regv1.Platform) bool) (regv1.ImageIndex, error) {
+	idxManifest, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	removed := map[regv1.Hash]bool{}
+
+	for _, desc := range idxManifest.Manifests {
+		if _, found := desc.Annotations[referenceDigestAnnotation]; found {
+			continue
+		}
+		if desc.Platform != nil && !keep(*desc.Platform) {
+			removed[desc.Digest] = true
+		}
+	}
+
+	for _, desc := range idxManifest.Manifests {
+		if refDigest, found := desc.Annotations[referenceDigestAnnotation]; found {
+			hash, err := regv1.NewHash(refDigest)
+			if err == nil && removed[hash] {
+				removed[desc.Digest] = true
+			}
+		}
+	}
+
+	if len(removed) == 0 {
+		return idx, nil
+	}
+	if len(removed) == len(idxManifest.Manifests) {
+		return nil, fmt.Errorf("Expected index to include at least one manifest with matching platform, but found none")
+	}
+
+	return mutate.RemoveManifests(idx, func(desc regv1.Descriptor) bool { return removed[desc.Digest] }), nil
+}
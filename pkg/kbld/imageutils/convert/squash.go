@@ -0,0 +1,282 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package convert
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	regtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	opaqueWhiteout = ".wh..wh..opq"
+)
+
+// Squash returns image with all layers above first keepLayers merged
+// into a single layer. Merged layer is stored in a file within given directory.
+// Images with layers that are not filesystem layers (e.g. attestations)
+// or with nothing to merge are returned as is.
+func Squash(img regv1.Image, keepLayers int, dir string) (regv1.Image, error) {
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	if keepLayers < 0 || keepLayers > len(manifest.Layers) {
+		return nil, fmt.Errorf("Expected number of kept layers to be between 0 and %d, but was %d",
+			len(manifest.Layers), keepLayers)
+	}
+	if len(manifest.Layers)-keepLayers < 2 {
+		return img, nil
+	}
+
+	for _, layerDesc := range manifest.Layers[keepLayers:] {
+		switch layerDesc.MediaType {
+		case regtypes.DockerLayer, regtypes.DockerUncompressedLayer,
+			regtypes.OCILayer, regtypes.OCIUncompressedLayer, regtypes.OCILayerZStd:
+		default:
+			return img, nil
+		}
+	}
+
+	configFile, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, err := img.MediaType()
+	if err != nil {
+		return nil, err
+	}
+
+	squashedFile, err := os.CreateTemp(dir, "squashed-*.tar")
+	if err != nil {
+		return nil, err
+	}
+
+	// Whiteouts are only meaningful when there are layers below
+	err = squashLayers(layers[keepLayers:], squashedFile, keepLayers > 0)
+	squashedFile.Close()
+	if err != nil {
+		return nil, fmt.Errorf("Squashing layers: %s", err)
+	}
+
+	err = util.CheckTmpDiskUsage()
+	if err != nil {
+		return nil, err
+	}
+
+	layerMediaType := regtypes.OCILayer
+	if mediaType == regtypes.DockerManifestSchema2 {
+		layerMediaType = regtypes.DockerLayer
+	}
+
+	squashedLayer, err := tarball.LayerFromFile(squashedFile.Name(), tarball.WithMediaType(layerMediaType))
+	if err != nil {
+		return nil, err
+	}
+
+	var adds []mutate.Addendum
+	var diffIDs []regv1.Hash
+
+	for i, layer := range layers[:keepLayers] {
+		layerDesc := manifest.Layers[i]
+		adds = append(adds, mutate.Addendum{
+			Layer:       layer,
+			URLs:        layerDesc.URLs,
+			Annotations: layerDesc.Annotations,
+			MediaType:   layerDesc.MediaType,
+		})
+		diffIDs = append(diffIDs, configFile.RootFS.DiffIDs[i])
+	}
+
+	squashedDiffID, err := squashedLayer.DiffID()
+	if err != nil {
+		return nil, err
+	}
+
+	adds = append(adds, mutate.Addendum{Layer: squashedLayer, MediaType: layerMediaType})
+	diffIDs = append(diffIDs, squashedDiffID)
+
+	result, err := mutate.Append(mutate.MediaType(empty.Image, mediaType), adds...)
+	if err != nil {
+		return nil, err
+	}
+
+	configFile = configFile.DeepCopy()
+	configFile.RootFS.DiffIDs = diffIDs
+	configFile.History = squashedHistory(configFile.History, keepLayers, len(layers)-keepLayers)
+
+	result, err = mutate.ConfigFile(result, configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	result = mutate.ConfigMediaType(result, manifest.Config.MediaType)
+
+	if len(manifest.Annotations) > 0 {
+		result = mutate.Annotations(result, manifest.Annotations).(regv1.Image)
+	}
+
+	return result, nil
+}
+
+// squashedHistory keeps history of first keepLayers layers
+// and replaces the rest with a single entry
+func squashedHistory(history []regv1.History, keepLayers, squashedLayers int) []regv1.History {
+	if len(history) == 0 {
+		return nil
+	}
+
+	var result []regv1.History
+	var layerIdx int
+	var last regv1.History
+
+	for _, entry := range history {
+		if layerIdx >= keepLayers {
+			last = entry
+			continue
+		}
+		result = append(result, entry)
+		if !entry.EmptyLayer {
+			layerIdx++
+		}
+	}
+
+	return append(result, regv1.History{
+		Created:   last.Created,
+		CreatedBy: "kbld squash",
+		Comment:   fmt.Sprintf("squashed %d layers", squashedLayers),
+	})
+}
+
+// squashLayers writes filesystem resulting from applying layers (bottom first)
+// as a single tar. Files are taken from the top-most layer that has them;
+// whiteouts hide files of layers below them.
+func squashLayers(layers []regv1.Layer, w io.Writer, keepWhiteouts bool) error {
+	tw := tar.NewWriter(w)
+
+	seen := map[string]bool{}
+	// deleted paths hide themselves and their children in lower layers
+	deleted := map[string]bool{}
+	// opaque directories hide their children in lower layers
+	opaque := map[string]bool{}
+
+	// Hard links are written last so that their targets are already present
+	var links []*tar.Header
+
+	hidden := func(name string) bool {
+		if deleted[name] {
+			return true
+		}
+		for dir := path.Dir(name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if deleted[dir] || opaque[dir] {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := len(layers) - 1; i >= 0; i-- {
+		// Whiteouts only apply to layers below
+		newDeleted := map[string]bool{}
+		newOpaque := map[string]bool{}
+
+		err := forEachTarEntry(layers[i], func(header *tar.Header, tr *tar.Reader) error {
+			name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+			dir, base := path.Split(name)
+			dir = path.Clean(dir)
+
+			if seen[name] || hidden(name) {
+				return nil
+			}
+			seen[name] = true
+
+			switch {
+			case base == opaqueWhiteout:
+				newOpaque[dir] = true
+				if !keepWhiteouts {
+					return nil
+				}
+
+			case strings.HasPrefix(base, whiteoutPrefix):
+				newDeleted[path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix))] = true
+				if !keepWhiteouts {
+					return nil
+				}
+
+			case header.Typeflag == tar.TypeLink:
+				links = append(links, header)
+				return nil
+			}
+
+			err := tw.WriteHeader(header)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(tw, tr)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		for name := range newDeleted {
+			deleted[name] = true
+		}
+		for name := range newOpaque {
+			opaque[name] = true
+		}
+	}
+
+	for _, header := range links {
+		err := tw.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tw.Close()
+}
+
+func forEachTarEntry(layer regv1.Layer, entryFunc func(*tar.Header, *tar.Reader) error) error {
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = entryFunc(header, tr)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package convert_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"testing"
+
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/imageutils/convert"
+)
+
+func TestSquash(t *testing.T) {
+	newLayer := func(t *testing.T, files map[string]string) regv1.Layer {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for name, contents := range files {
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents))}))
+			_, err := tw.Write([]byte(contents))
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+
+		layer, err := tarball.LayerFromReader(bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+		return layer
+	}
+
+	layerFiles := func(t *testing.T, layer regv1.Layer) map[string]string {
+		rc, err := layer.Uncompressed()
+		require.NoError(t, err)
+		defer rc.Close()
+
+		files := map[string]string{}
+		tr := tar.NewReader(rc)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				return files
+			}
+			require.NoError(t, err)
+			bs, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			files[header.Name] = string(bs)
+		}
+	}
+
+	baseLayer := newLayer(t, map[string]string{"a.txt": "a1", "dir/b.txt": "b1", "dir/c.txt": "c1", "other/d.txt": "d1"})
+
+	img, err := mutate.Append(empty.Image,
+		mutate.Addendum{Layer: baseLayer, History: regv1.History{CreatedBy: "base"}},
+		mutate.Addendum{Layer: newLayer(t, map[string]string{"a.txt": "a2", "dir/.wh.b.txt": ""}), History: regv1.History{CreatedBy: "step1"}},
+		mutate.Addendum{Layer: newLayer(t, map[string]string{"new.txt": "n1", "other/.wh..wh..opq": ""}), History: regv1.History{CreatedBy: "step2"}},
+	)
+	require.NoError(t, err)
+
+	t.Run("squashes all layers", func(t *testing.T) {
+		squashedImg, err := convert.Squash(img, 0, t.TempDir())
+		require.NoError(t, err)
+
+		layers, err := squashedImg.Layers()
+		require.NoError(t, err)
+		require.Len(t, layers, 1)
+
+		assert.Equal(t, map[string]string{"a.txt": "a2", "dir/c.txt": "c1", "new.txt": "n1"}, layerFiles(t, layers[0]))
+
+		configFile, err := squashedImg.ConfigFile()
+		require.NoError(t, err)
+		require.Len(t, configFile.History, 1)
+		assert.Equal(t, "kbld squash", configFile.History[0].CreatedBy)
+
+		diffID, err := layers[0].DiffID()
+		require.NoError(t, err)
+		assert.Equal(t, []regv1.Hash{diffID}, configFile.RootFS.DiffIDs)
+	})
+
+	t.Run("squashes layers above base", func(t *testing.T) {
+		squashedImg, err := convert.Squash(img, 1, t.TempDir())
+		require.NoError(t, err)
+
+		layers, err := squashedImg.Layers()
+		require.NoError(t, err)
+		require.Len(t, layers, 2)
+
+		baseDigest, err := baseLayer.Digest()
+		require.NoError(t, err)
+		keptDigest, err := layers[0].Digest()
+		require.NoError(t, err)
+		assert.Equal(t, baseDigest, keptDigest)
+
+		assert.Equal(t, map[string]string{
+			"a.txt":              "a2",
+			"dir/.wh.b.txt":      "",
+			"new.txt":            "n1",
+			"other/.wh..wh..opq": "",
+		}, layerFiles(t, layers[1]))
+
+		configFile, err := squashedImg.ConfigFile()
+		require.NoError(t, err)
+		require.Len(t, configFile.History, 2)
+		assert.Equal(t, "base", configFile.History[0].CreatedBy)
+		assert.Equal(t, "squashed 2 layers", configFile.History[1].Comment)
+	})
+
+	t.Run("returns image as is when there is nothing to squash", func(t *testing.T) {
+		squashedImg, err := convert.Squash(img, 2, t.TempDir())
+		require.NoError(t, err)
+		assert.Equal(t, img, squashedImg)
+	})
+}
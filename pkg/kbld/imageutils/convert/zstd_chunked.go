@@ -0,0 +1,152 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package convert
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/containerd/stargz-snapshotter/estargz"
+	"github.com/klauspost/compress/zstd"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// Layout follows zstd:chunked format used by containers/storage
+// (https://github.com/containers/storage/blob/main/pkg/chunked/compressor):
+// each file is stored in its own zstd frame and TOC and footer
+// are stored in skippable frames at the end of the blob.
+const (
+	zstdChunkedManifestChecksumAnnotation = "io.containers.zstd-chunked.manifest-checksum"
+	zstdChunkedManifestInfoAnnotation     = "io.containers.zstd-chunked.manifest-position"
+
+	zstdChunkedFooterSize       = 40
+	zstdChunkedManifestTypeCRFS = 1
+
+	// Size of skippable frame header (magic and frame size)
+	zstdSkippableFrameHeaderSize = 8
+)
+
+var (
+	zstdSkippableFrameMagic = []byte{0x50, 0x2a, 0x4d, 0x18}
+	zstdChunkedFrameMagic   = []byte{0x47, 0x6e, 0x55, 0x6c, 0x49, 0x6e, 0x55, 0x78}
+)
+
+// zstdChunkedCompression implements estargz.Compression.
+// Annotations describing TOC position are recorded into metadata.
+type zstdChunkedCompression struct {
+	metadata map[string]string
+}
+
+var _ estargz.Compression = &zstdChunkedCompression{}
+
+func (c *zstdChunkedCompression) Writer(w io.Writer) (estargz.WriteFlushCloser, error) {
+	return zstd.NewWriter(w)
+}
+
+func (c *zstdChunkedCompression) WriteTOCAndFooter(w io.Writer, off int64,
+	toc *estargz.JTOC, _ hash.Hash) (digest.Digest, error) {
+
+	tocJSON, err := json.MarshalIndent(toc, "", "\t")
+	if err != nil {
+		return "", err
+	}
+
+	var compressedTOC bytes.Buffer
+
+	encoder, err := zstd.NewWriter(&compressedTOC)
+	if err != nil {
+		return "", err
+	}
+	_, err = encoder.Write(tocJSON)
+	if err != nil {
+		return "", err
+	}
+	err = encoder.Close()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = w.Write(zstdSkippableFrame(compressedTOC.Bytes()))
+	if err != nil {
+		return "", err
+	}
+
+	tocOff := uint64(off) + zstdSkippableFrameHeaderSize
+
+	footer := make([]byte, zstdChunkedFooterSize)
+	binary.LittleEndian.PutUint64(footer, tocOff)
+	binary.LittleEndian.PutUint64(footer[8:], uint64(compressedTOC.Len()))
+	binary.LittleEndian.PutUint64(footer[16:], uint64(len(tocJSON)))
+	binary.LittleEndian.PutUint64(footer[24:], zstdChunkedManifestTypeCRFS)
+	copy(footer[32:], zstdChunkedFrameMagic)
+
+	_, err = w.Write(zstdSkippableFrame(footer))
+	if err != nil {
+		return "", err
+	}
+
+	c.metadata[zstdChunkedManifestInfoAnnotation] = fmt.Sprintf("%d:%d:%d:%d",
+		tocOff, compressedTOC.Len(), len(tocJSON), zstdChunkedManifestTypeCRFS)
+	c.metadata[zstdChunkedManifestChecksumAnnotation] = digest.FromBytes(compressedTOC.Bytes()).String()
+
+	return digest.FromBytes(tocJSON), nil
+}
+
+func (c *zstdChunkedCompression) Reader(r io.Reader) (io.ReadCloser, error) {
+	decoder, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return decoder.IOReadCloser(), nil
+}
+
+func (c *zstdChunkedCompression) FooterSize() int64 { return zstdChunkedFooterSize }
+
+func (c *zstdChunkedCompression) ParseFooter(p []byte) (int64, int64, int64, error) {
+	if len(p) != zstdChunkedFooterSize {
+		return 0, 0, 0, fmt.Errorf("Expected zstd:chunked footer to be %d bytes, but was %d", zstdChunkedFooterSize, len(p))
+	}
+	if !bytes.Equal(zstdChunkedFrameMagic, p[32:]) {
+		return 0, 0, 0, fmt.Errorf("Expected zstd:chunked footer to have valid magic number")
+	}
+
+	tocOff := binary.LittleEndian.Uint64(p[0:8])
+	tocSize := binary.LittleEndian.Uint64(p[8:16])
+
+	return int64(tocOff - zstdSkippableFrameHeaderSize), int64(tocOff), int64(tocSize), nil
+}
+
+func (c *zstdChunkedCompression) ParseTOC(r io.Reader) (*estargz.JTOC, digest.Digest, error) {
+	decoder, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, "", err
+	}
+	defer decoder.Close()
+
+	tocJSON, err := io.ReadAll(decoder)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var toc *estargz.JTOC
+	err = json.Unmarshal(tocJSON, &toc)
+	if err != nil {
+		return nil, "", fmt.Errorf("Unmarshaling zstd:chunked TOC: %s", err)
+	}
+
+	return toc, digest.FromBytes(tocJSON), nil
+}
+
+func zstdSkippableFrame(payload []byte) []byte {
+	size := make([]byte, 4)
+	binary.LittleEndian.PutUint32(size, uint32(len(payload)))
+
+	frame := append([]byte{}, zstdSkippableFrameMagic...)
+	frame = append(frame, size...)
+	return append(frame, payload...)
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package logger
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// CommandOutputWriter writes output of external command (e.g. builder)
+// with level of command result (info when successful, error otherwise)
+// instead of guessing level of each line. Output is streamed while command
+// runs only when it is shown as plain text at info level; otherwise it is
+// held until command finishes (so that failed builds are logged as errors).
+type CommandOutputWriter struct {
+	writer *PrefixWriter
+
+	heldLock sync.Mutex
+	held     bytes.Buffer
+}
+
+var _ io.Writer = &CommandOutputWriter{}
+
+// NewCommandOutputWriter returns writer for output of single command run
+// (Finish has to be called with command result once command exits)
+func (w *PrefixWriter) NewCommandOutputWriter() *CommandOutputWriter {
+	return &CommandOutputWriter{writer: w}
+}
+
+func (w *CommandOutputWriter) Write(data []byte) (int, error) {
+	if w.streamed() {
+		level := LevelInfo
+		err := w.writer.write(data, &level)
+		if err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+
+	// Stdout and stderr of command are copied concurrently
+	w.heldLock.Lock()
+	defer w.heldLock.Unlock()
+
+	return w.held.Write(data)
+}
+
+// Finish writes held output with level of given command result
+func (w *CommandOutputWriter) Finish(cmdErr error) error {
+	w.heldLock.Lock()
+	defer w.heldLock.Unlock()
+
+	if w.held.Len() == 0 {
+		return nil
+	}
+
+	level := LevelInfo
+	if cmdErr != nil {
+		level = LevelError
+	}
+
+	defer w.held.Reset()
+
+	return w.writer.write(w.held.Bytes(), &level)
+}
+
+func (w *CommandOutputWriter) streamed() bool {
+	return w.writer.opts.Format != FormatJSON && w.writer.opts.Level <= LevelInfo
+}
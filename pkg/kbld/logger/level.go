@@ -0,0 +1,74 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package logger
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+func (l Level) String() string {
+	if name, found := levelNames[l]; found {
+		return name
+	}
+	return "unknown"
+}
+
+func ParseLevel(str string) (Level, error) {
+	for level, name := range levelNames {
+		if strings.EqualFold(name, str) {
+			return level, nil
+		}
+	}
+	if strings.EqualFold(str, "warning") {
+		return LevelWarn, nil
+	}
+	return LevelInfo, fmt.Errorf("Expected log level to be one of debug, info, warn or error, but was '%s'", str)
+}
+
+// LevelFromMessage determines level based on conventional
+// message prefixes (e.g. "error: ...", "warning: ...")
+func LevelFromMessage(msg string) Level {
+	lowerMsg := strings.ToLower(strings.TrimSpace(msg))
+	switch {
+	case strings.HasPrefix(lowerMsg, "error:"):
+		return LevelError
+	case strings.HasPrefix(lowerMsg, "warning:"):
+		return LevelWarn
+	default:
+		return LevelInfo
+	}
+}
+
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+)
+
+func ParseFormat(str string) (Format, error) {
+	switch Format(str) {
+	case FormatText, FormatJSON:
+		return Format(str), nil
+	default:
+		return FormatText, fmt.Errorf("Expected log format to be one of text or json, but was '%s'", str)
+	}
+}
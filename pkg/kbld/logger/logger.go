@@ -5,31 +5,67 @@ package logger
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
+	"strings"
 	"sync"
+	"time"
 )
 
 type Logger struct {
 	writer     io.Writer
 	writerLock *sync.Mutex
+	opts       Opts
+}
+
+type Opts struct {
+	// Level is minimum level of messages that are written
+	Level Level
+	// Format is either text (default) or json
+	Format Format
 }
 
 func NewLogger(writer io.Writer) Logger {
-	return Logger{writer: writer, writerLock: &sync.Mutex{}}
+	return NewLoggerWithOpts(writer, Opts{Level: LevelInfo, Format: FormatText})
+}
+
+func NewLoggerWithOpts(writer io.Writer, opts Opts) Logger {
+	return Logger{writer: writer, writerLock: &sync.Mutex{}, opts: opts}
 }
 
 func (l Logger) NewPrefixedWriter(prefix string) *PrefixWriter {
-	return &PrefixWriter{prefix, l.writer, l.writerLock}
+	return &PrefixWriter{prefix, l.writer, l.writerLock, l.opts}
 }
 
 type PrefixWriter struct {
 	prefix     string
 	writer     io.Writer
 	writerLock *sync.Mutex
+	opts       Opts
 }
 
 func (w *PrefixWriter) Write(data []byte) (int, error) {
+	err := w.write(data, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	// return original data length
+	return len(data), nil
+}
+
+func (w *PrefixWriter) WriteStr(str string, args ...interface{}) error {
+	_, err := w.Write([]byte(fmt.Sprintf(str, args...)))
+	return err
+}
+
+// WriteLevelStr writes message with explicitly specified level
+func (w *PrefixWriter) WriteLevelStr(level Level, str string, args ...interface{}) error {
+	return w.write([]byte(fmt.Sprintf(str, args...)), &level)
+}
+
+func (w *PrefixWriter) write(data []byte, level *Level) error {
 	newData := make([]byte, len(data))
 	copy(newData, data)
 
@@ -37,24 +73,59 @@ func (w *PrefixWriter) Write(data []byte) (int, error) {
 	if endsWithNl {
 		newData = newData[0 : len(newData)-1]
 	}
-	newData = bytes.Replace(newData, []byte("\n"), []byte("\n"+w.prefix), -1)
-	newData = append(newData, []byte("\n")...)
-	newData = append([]byte(w.prefix), newData...)
+
+	var output []byte
+
+	for _, line := range strings.Split(string(newData), "\n") {
+		lineLevel := LevelFromMessage(line)
+		if level != nil {
+			lineLevel = *level
+		}
+		if lineLevel < w.opts.Level {
+			continue
+		}
+
+		switch w.opts.Format {
+		case FormatJSON:
+			lineBs, err := json.Marshal(jsonLine{
+				Time:   time.Now().UTC().Format(time.RFC3339Nano),
+				Level:  lineLevel.String(),
+				Source: w.source(),
+				Msg:    line,
+			})
+			if err != nil {
+				return err
+			}
+			output = append(output, append(lineBs, '\n')...)
+		default:
+			output = append(output, []byte(w.prefix+line+"\n")...)
+		}
+	}
+
+	if len(output) == 0 {
+		return nil
+	}
 
 	w.writerLock.Lock()
 	defer w.writerLock.Unlock()
 
 	// TODO does not deal with races of multitple writers
-	_, err := w.writer.Write(newData)
+	_, err := w.writer.Write(output)
 	if err != nil {
-		return 0, fmt.Errorf("write err: %s", err)
+		return fmt.Errorf("write err: %s", err)
 	}
 
-	// return original data length
-	return len(data), nil
+	return nil
 }
 
-func (w *PrefixWriter) WriteStr(str string, args ...interface{}) error {
-	_, err := w.Write([]byte(fmt.Sprintf(str, args...)))
-	return err
+// source returns prefix without decoration (e.g. "resolve | " -> "resolve")
+func (w *PrefixWriter) source() string {
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(strings.TrimSpace(w.prefix), "|"), ":"))
+}
+
+type jsonLine struct {
+	Time   string `json:"time"`
+	Level  string `json:"level"`
+	Source string `json:"source,omitempty"`
+	Msg    string `json:"msg"`
 }
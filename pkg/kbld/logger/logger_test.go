@@ -5,6 +5,9 @@ package logger_test
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
 
 	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
@@ -41,3 +44,87 @@ prefix:
 		t.Fatalf("Expected >>>%s<<< to match >>>%s<<<", out, expectedOut)
 	}
 }
+
+func TestLoggerLevels(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := ctllog.NewLoggerWithOpts(&buf, ctllog.Opts{Level: ctllog.LevelWarn, Format: ctllog.FormatText})
+	prefLogger := logger.NewPrefixedWriter("prefix | ")
+
+	prefLogger.Write([]byte("content1\nwarning: content2\nerror: content3\n"))
+	prefLogger.WriteLevelStr(ctllog.LevelDebug, "content4\n")
+	prefLogger.WriteLevelStr(ctllog.LevelError, "content5\n")
+
+	expectedOut := "prefix | warning: content2\nprefix | error: content3\nprefix | content5\n"
+
+	if out := buf.String(); out != expectedOut {
+		t.Fatalf("Expected >>>%s<<< to match >>>%s<<<", out, expectedOut)
+	}
+}
+
+func TestLoggerJSON(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := ctllog.NewLoggerWithOpts(&buf, ctllog.Opts{Level: ctllog.LevelInfo, Format: ctllog.FormatJSON})
+	prefLogger := logger.NewPrefixedWriter("resolve | ")
+
+	prefLogger.Write([]byte("content1\nerror: content2\n"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected two lines, but was: %s", buf.String())
+	}
+
+	var line map[string]string
+
+	err := json.Unmarshal([]byte(lines[1]), &line)
+	if err != nil {
+		t.Fatalf("Expected valid JSON: %s", err)
+	}
+
+	if line["level"] != "error" || line["source"] != "resolve" || line["msg"] != "error: content2" || line["time"] == "" {
+		t.Fatalf("Expected JSON line to have level, source, msg and time, but was: %#v", line)
+	}
+}
+
+func TestLoggerCommandOutput(t *testing.T) {
+	run := func(opts ctllog.Opts, cmdErr error) string {
+		var buf bytes.Buffer
+
+		prefLogger := ctllog.NewLoggerWithOpts(&buf, opts).NewPrefixedWriter("prefix | ")
+
+		output := prefLogger.NewCommandOutputWriter()
+		output.Write([]byte("#1 building\nERROR [3/5] RUN make\n"))
+		output.Write([]byte("error: failed to solve"))
+		output.Finish(cmdErr)
+
+		return buf.String()
+	}
+
+	expectedOut := "prefix | #1 building\nprefix | ERROR [3/5] RUN make\nprefix | error: failed to solve\n"
+	buildErr := fmt.Errorf("exit status 1")
+
+	// Command output does not use message prefixes to determine level
+	if out := run(ctllog.Opts{Level: ctllog.LevelError}, nil); out != "" {
+		t.Fatalf("Expected successful command output to be hidden, but was >>>%s<<<", out)
+	}
+	if out := run(ctllog.Opts{Level: ctllog.LevelError}, buildErr); out != expectedOut {
+		t.Fatalf("Expected >>>%s<<< to match >>>%s<<<", out, expectedOut)
+	}
+	if out := run(ctllog.Opts{Level: ctllog.LevelInfo}, nil); out != expectedOut {
+		t.Fatalf("Expected >>>%s<<< to match >>>%s<<<", out, expectedOut)
+	}
+
+	out := run(ctllog.Opts{Level: ctllog.LevelInfo, Format: ctllog.FormatJSON}, buildErr)
+	for _, lineStr := range strings.Split(strings.TrimSpace(out), "\n") {
+		var line map[string]string
+
+		err := json.Unmarshal([]byte(lineStr), &line)
+		if err != nil {
+			t.Fatalf("Expected valid JSON: %s", err)
+		}
+		if line["level"] != "error" {
+			t.Fatalf("Expected output of failed command to have error level, but was: %#v", line)
+		}
+	}
+}
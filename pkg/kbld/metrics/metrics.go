@@ -0,0 +1,119 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	durationBuckets = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600}
+)
+
+// Metrics describes operation of kbld when running as a service
+// (serve, webhook, controller). All methods are safe to call on nil Metrics.
+type Metrics struct {
+	registry *Registry
+
+	Resolutions        *Counter
+	ResolutionDuration *Histogram
+	ImageCacheRequests *Counter
+	ImagesProcessed    *Counter
+	BuildDuration      *Histogram
+	RegistryErrors     *Counter
+	PushedBytes        *Counter
+}
+
+func New() *Metrics {
+	r := NewRegistry()
+
+	return &Metrics{
+		registry: r,
+
+		Resolutions: r.NewCounter("kbld_resolutions_total",
+			"Number of completed resolutions", "mode", "result"),
+		ResolutionDuration: r.NewHistogram("kbld_resolution_duration_seconds",
+			"Duration of resolutions", durationBuckets, "mode"),
+		ImageCacheRequests: r.NewCounter("kbld_image_cache_requests_total",
+			"Number of images that were (hit) or were not (miss) already resolved", "result"),
+		ImagesProcessed: r.NewCounter("kbld_images_processed_total",
+			"Number of processed images", "action", "result"),
+		BuildDuration: r.NewHistogram("kbld_build_duration_seconds",
+			"Duration of image builds (including push)", durationBuckets),
+		RegistryErrors: r.NewCounter("kbld_registry_errors_total",
+			"Number of failed registry requests by status code ('error' when no response was received)", "code"),
+		PushedBytes: r.NewCounter("kbld_registry_pushed_bytes_total",
+			"Number of bytes sent to registries"),
+	}
+}
+
+func (m *Metrics) Handler() http.Handler {
+	if m == nil {
+		return http.NotFoundHandler()
+	}
+	return m.registry.Handler()
+}
+
+// ObserveResolution records resolution of a set of resources (e.g. one request)
+func (m *Metrics) ObserveResolution(mode string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.Resolutions.Inc(mode, resultLabel(err))
+	m.ResolutionDuration.Observe(duration.Seconds(), mode)
+}
+
+// ObserveImage records processing of single image
+func (m *Metrics) ObserveImage(action string, cacheHit bool, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.ImagesProcessed.Inc(action, resultLabel(err))
+	m.ObserveImageCache(cacheHit)
+
+	if action == "building" {
+		m.BuildDuration.Observe(duration.Seconds())
+	}
+}
+
+func (m *Metrics) ObserveImageCache(hit bool) {
+	if m == nil {
+		return
+	}
+	if hit {
+		m.ImageCacheRequests.Inc("hit")
+	} else {
+		m.ImageCacheRequests.Inc("miss")
+	}
+}
+
+// ObserveRegistryResponse records failed registry requests
+// (statusCode is 0 when no response was received)
+func (m *Metrics) ObserveRegistryResponse(statusCode int) {
+	if m == nil {
+		return
+	}
+	switch {
+	case statusCode == 0:
+		m.RegistryErrors.Inc("error")
+	case statusCode >= 400:
+		m.RegistryErrors.Inc(strconv.Itoa(statusCode))
+	}
+}
+
+func (m *Metrics) AddPushedBytes(n int64) {
+	if m == nil {
+		return
+	}
+	m.PushedBytes.Add(float64(n))
+}
+
+func resultLabel(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
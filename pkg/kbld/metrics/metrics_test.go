@@ -0,0 +1,97 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/metrics"
+)
+
+func TestRegistryWriteText(t *testing.T) {
+	registry := metrics.NewRegistry()
+
+	counter := registry.NewCounter("test_requests_total", "Number of requests", "code")
+	counter.Inc("404")
+	counter.Add(2, "500")
+	counter.Inc("404")
+
+	histogram := registry.NewHistogram("test_duration_seconds", "Duration", []float64{1, 0.5})
+	histogram.Observe(0.2)
+	histogram.Observe(0.7)
+	histogram.Observe(3)
+
+	var buf bytes.Buffer
+	registry.WriteText(&buf)
+
+	expected := `# HELP test_requests_total Number of requests
+# TYPE test_requests_total counter
+test_requests_total{code="404"} 2
+test_requests_total{code="500"} 2
+# HELP test_duration_seconds Duration
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="0.5"} 1
+test_duration_seconds_bucket{le="1"} 2
+test_duration_seconds_bucket{le="+Inf"} 3
+test_duration_seconds_sum 3.9
+test_duration_seconds_count 3
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestRegistryEscapesLabelValues(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.NewCounter("test_total", "Test", "val").Inc("a\"b\\c\nd")
+
+	var buf bytes.Buffer
+	registry.WriteText(&buf)
+
+	assert.Contains(t, buf.String(), `test_total{val="a\"b\\c\nd"} 1`)
+}
+
+func TestMetrics(t *testing.T) {
+	m := metrics.New()
+
+	m.ObserveResolution("serve", time.Second, nil)
+	m.ObserveResolution("serve", time.Second, fmt.Errorf("failed"))
+	m.ObserveImage("building", false, 2*time.Second, nil)
+	m.ObserveImage("preresolved", true, 0, nil)
+	m.ObserveRegistryResponse(200)
+	m.ObserveRegistryResponse(429)
+	m.ObserveRegistryResponse(0)
+	m.AddPushedBytes(100)
+
+	assert.Equal(t, float64(1), m.Resolutions.Value("serve", "success"))
+	assert.Equal(t, float64(1), m.Resolutions.Value("serve", "failure"))
+	assert.Equal(t, float64(1), m.ImageCacheRequests.Value("hit"))
+	assert.Equal(t, float64(1), m.ImageCacheRequests.Value("miss"))
+	assert.Equal(t, uint64(1), m.BuildDuration.Count())
+	assert.Equal(t, float64(0), m.RegistryErrors.Value("200"))
+	assert.Equal(t, float64(1), m.RegistryErrors.Value("429"))
+	assert.Equal(t, float64(1), m.RegistryErrors.Value("error"))
+	assert.Equal(t, float64(100), m.PushedBytes.Value())
+
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	require.Equal(t, 200, recorder.Code)
+	assert.Equal(t, "text/plain; version=0.0.4", recorder.Header().Get("Content-Type"))
+	assert.Contains(t, recorder.Body.String(), `kbld_resolutions_total{mode="serve",result="success"} 1`)
+	assert.Contains(t, recorder.Body.String(), "kbld_registry_pushed_bytes_total 100")
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *metrics.Metrics
+
+	m.ObserveResolution("serve", time.Second, nil)
+	m.ObserveImage("building", false, time.Second, nil)
+	m.ObserveRegistryResponse(500)
+	m.AddPushedBytes(1)
+}
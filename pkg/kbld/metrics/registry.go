@@ -0,0 +1,239 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds metrics and exposes them in Prometheus text format
+// (https://prometheus.io/docs/instrumenting/exposition_formats/#text-based-format)
+type Registry struct {
+	metrics     []metric
+	metricsLock sync.Mutex
+}
+
+type metric interface {
+	write(w io.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(m metric) {
+	r.metricsLock.Lock()
+	defer r.metricsLock.Unlock()
+
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteText writes all metrics in Prometheus text format
+func (r *Registry) WriteText(w io.Writer) {
+	r.metricsLock.Lock()
+	metrics := append([]metric{}, r.metrics...)
+	r.metricsLock.Unlock()
+
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		var buf bytes.Buffer
+		r.WriteText(&buf)
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write(buf.Bytes())
+	})
+}
+
+// Counter is a monotonically increasing value partitioned by labels
+type Counter struct {
+	name       string
+	help       string
+	labelNames []string
+
+	values     map[string]float64
+	valuesLock sync.Mutex
+}
+
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{name: name, help: help, labelNames: labelNames, values: map[string]float64{}}
+	r.register(c)
+	return c
+}
+
+func (c *Counter) Inc(labelValues ...string) { c.Add(1, labelValues...) }
+
+func (c *Counter) Add(val float64, labelValues ...string) {
+	key := seriesKey(c.labelNames, labelValues)
+
+	c.valuesLock.Lock()
+	defer c.valuesLock.Unlock()
+
+	c.values[key] += val
+}
+
+// Value returns current value for given labels
+func (c *Counter) Value(labelValues ...string) float64 {
+	key := seriesKey(c.labelNames, labelValues)
+
+	c.valuesLock.Lock()
+	defer c.valuesLock.Unlock()
+
+	return c.values[key]
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.valuesLock.Lock()
+	defer c.valuesLock.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, labelsStr(key, ""), formatFloat(c.values[key]))
+	}
+}
+
+// Histogram counts observed values in configured buckets partitioned by labels
+type Histogram struct {
+	name       string
+	help       string
+	labelNames []string
+	buckets    []float64
+
+	series     map[string]*histogramSeries
+	seriesLock sync.Mutex
+}
+
+type histogramSeries struct {
+	bucketCounts []uint64
+	count        uint64
+	sum          float64
+}
+
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	sortedBuckets := append([]float64{}, buckets...)
+	sort.Float64s(sortedBuckets)
+
+	h := &Histogram{name: name, help: help, labelNames: labelNames,
+		buckets: sortedBuckets, series: map[string]*histogramSeries{}}
+	r.register(h)
+	return h
+}
+
+func (h *Histogram) Observe(val float64, labelValues ...string) {
+	key := seriesKey(h.labelNames, labelValues)
+
+	h.seriesLock.Lock()
+	defer h.seriesLock.Unlock()
+
+	series, found := h.series[key]
+	if !found {
+		series = &histogramSeries{bucketCounts: make([]uint64, len(h.buckets))}
+		h.series[key] = series
+	}
+
+	for i, upperBound := range h.buckets {
+		if val <= upperBound {
+			series.bucketCounts[i]++
+		}
+	}
+	series.count++
+	series.sum += val
+}
+
+// Count returns number of observations for given labels
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := seriesKey(h.labelNames, labelValues)
+
+	h.seriesLock.Lock()
+	defer h.seriesLock.Unlock()
+
+	if series, found := h.series[key]; found {
+		return series.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.seriesLock.Lock()
+	defer h.seriesLock.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+
+	var keys []string
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		series := h.series[key]
+
+		for i, upperBound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name,
+				labelsStr(key, `le="`+formatFloat(upperBound)+`"`), series.bucketCounts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelsStr(key, `le="+Inf"`), series.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labelsStr(key, ""), formatFloat(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labelsStr(key, ""), series.count)
+	}
+}
+
+// seriesKey returns formatted label pairs (e.g. `code="404",host="index.docker.io"`)
+func seriesKey(labelNames, labelValues []string) string {
+	if len(labelNames) != len(labelValues) {
+		panic(fmt.Sprintf("Expected %d label values, but got %d", len(labelNames), len(labelValues)))
+	}
+
+	var pairs []string
+	for i, name := range labelNames {
+		pairs = append(pairs, name+`="`+escapeLabelValue(labelValues[i])+`"`)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func labelsStr(key, extra string) string {
+	switch {
+	case len(key) > 0 && len(extra) > 0:
+		return "{" + key + "," + extra + "}"
+	case len(key) > 0:
+		return "{" + key + "}"
+	case len(extra) > 0:
+		return "{" + extra + "}"
+	default:
+		return ""
+	}
+}
+
+func escapeLabelValue(val string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(val)
+}
+
+func formatFloat(val float64) string {
+	if math.IsInf(val, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(val, 'g', -1, 64)
+}
+
+func sortedKeys(values map[string]float64) []string {
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
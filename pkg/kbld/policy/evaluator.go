@@ -0,0 +1,154 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+// Evaluator runs opa or cue against policy input and returns found violations
+type Evaluator struct {
+	logger ctllog.Logger
+}
+
+func NewEvaluator(logger ctllog.Logger) Evaluator {
+	return Evaluator{logger}
+}
+
+func (e Evaluator) Evaluate(policy ctlconf.Policy, input Input) ([]string, error) {
+	prefixedLogger := e.logger.NewPrefixedWriter("policy | ")
+
+	prefixedLogger.WriteStr("evaluating %s\n", policy.Description())
+
+	tmpDir, err := util.MkdirTemp("kbld-policy")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "input.json")
+
+	inputBs, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.WriteFile(inputPath, inputBs, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case policy.OPA != nil:
+		return e.evaluateOPA(*policy.OPA, inputPath)
+	case policy.CUE != nil:
+		return e.evaluateCUE(*policy.CUE, inputPath)
+	default:
+		return nil, fmt.Errorf("Unknown policy")
+	}
+}
+
+func (e Evaluator) evaluateOPA(opts ctlconf.PolicyOPA, inputPath string) ([]string, error) {
+	var stdoutBuf, stderrBuf bytes.Buffer
+
+	cmd := exec.Command("opa", "eval", "--format", "json",
+		"--data", opts.Path, "--input", inputPath, opts.QueryWithDefaults())
+	cmd.Stdout = &stdoutBuf
+	cmd.Stderr = &stderrBuf
+
+	err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("Evaluating OPA policy '%s': %s (output: %s)",
+			opts.Path, err, bytes.TrimSpace(append(stdoutBuf.Bytes(), stderrBuf.Bytes()...)))
+	}
+
+	return ParseOPAViolations(stdoutBuf.Bytes())
+}
+
+func (e Evaluator) evaluateCUE(opts ctlconf.PolicyCUE, inputPath string) ([]string, error) {
+	var outputBuf bytes.Buffer
+
+	cmd := exec.Command("cue", "vet", "--concrete", opts.Path, inputPath)
+	cmd.Stdout = &outputBuf
+	cmd.Stderr = &outputBuf
+
+	err := cmd.Run()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return nil, fmt.Errorf("Evaluating CUE policy '%s': %s", opts.Path, err)
+		}
+		// Unification errors are reported as a failed vet, one per line
+		var violations []string
+		for _, line := range strings.Split(strings.TrimSpace(outputBuf.String()), "\n") {
+			if line = strings.TrimSpace(line); len(line) > 0 {
+				violations = append(violations, line)
+			}
+		}
+		return violations, nil
+	}
+
+	return nil, nil
+}
+
+type opaEvalOutput struct {
+	Result []struct {
+		Expressions []struct {
+			Value interface{} `json:"value"`
+		} `json:"expressions"`
+	} `json:"result"`
+}
+
+// ParseOPAViolations extracts violation messages from 'opa eval --format json' output.
+// Query result is expected to be a set (array) of messages; non-string items are JSON encoded.
+func ParseOPAViolations(bs []byte) ([]string, error) {
+	var output opaEvalOutput
+
+	err := json.Unmarshal(bs, &output)
+	if err != nil {
+		return nil, fmt.Errorf("Unmarshaling OPA output: %s", err)
+	}
+
+	var violations []string
+
+	for _, result := range output.Result {
+		for _, expr := range result.Expressions {
+			switch typedVal := expr.Value.(type) {
+			case nil:
+			case []interface{}:
+				for _, item := range typedVal {
+					violations = append(violations, violationString(item))
+				}
+			case bool:
+				if !typedVal {
+					violations = append(violations, "policy query evaluated to false")
+				}
+			default:
+				return nil, fmt.Errorf("Expected OPA query to evaluate to a set of messages, but was '%T'", typedVal)
+			}
+		}
+	}
+
+	return violations, nil
+}
+
+func violationString(val interface{}) string {
+	if str, ok := val.(string); ok {
+		return str
+	}
+	bs, err := json.Marshal(val)
+	if err != nil {
+		return fmt.Sprintf("%v", val)
+	}
+	return string(bs)
+}
@@ -0,0 +1,38 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package policy_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlpol "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/policy"
+)
+
+func TestParseOPAViolations(t *testing.T) {
+	output := `{"result":[{"expressions":[{"value":["image 'nginx' is not signed",{"msg":"bad registry"}],"text":"data.kbld.deny","location":{"row":1,"col":1}}]}]}`
+
+	violations, err := ctlpol.ParseOPAViolations([]byte(output))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"image 'nginx' is not signed", `{"msg":"bad registry"}`}, violations)
+}
+
+func TestParseOPAViolationsEmpty(t *testing.T) {
+	for _, output := range []string{`{}`, `{"result":[{"expressions":[{"value":[]}]}]}`, `{"result":[{"expressions":[{"value":true}]}]}`} {
+		violations, err := ctlpol.ParseOPAViolations([]byte(output))
+		require.NoError(t, err)
+		assert.Empty(t, violations, "output: %s", output)
+	}
+}
+
+func TestParseOPAViolationsUnexpectedValue(t *testing.T) {
+	_, err := ctlpol.ParseOPAViolations([]byte(`{"result":[{"expressions":[{"value":"str"}]}]}`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Expected OPA query to evaluate to a set of messages")
+
+	violations, err := ctlpol.ParseOPAViolations([]byte(`{"result":[{"expressions":[{"value":false}]}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"policy query evaluated to false"}, violations)
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package policy
+
+import (
+	"fmt"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+// Input is a document policies are evaluated against
+type Input struct {
+	Images []InputImage `json:"images"`
+}
+
+type InputImage struct {
+	// UnprocessedURL is image reference as found in inputs
+	UnprocessedURL string `json:"unprocessedURL"`
+	URL            string `json:"url"`
+	Registry       string `json:"registry"`
+	Repository     string `json:"repository"`
+	Digest         string `json:"digest"`
+
+	Platforms []string          `json:"platforms"`
+	Labels    map[string]string `json:"labels"`
+
+	Signed   bool `json:"signed"`
+	Verified bool `json:"verified"`
+
+	Origins []ctlconf.Origin `json:"origins"`
+}
+
+// NewInputImage collects image metadata (labels, platforms) from registry
+func NewInputImage(unprocessedURL, url string, origins []ctlconf.Origin, registry ctlreg.Registry) (InputImage, error) {
+	ref, err := regname.NewDigest(url, regname.WeakValidation)
+	if err != nil {
+		return InputImage{}, fmt.Errorf("Expected image '%s' to be a digest reference: %s", url, err)
+	}
+
+	img := InputImage{
+		UnprocessedURL: unprocessedURL,
+		URL:            url,
+		Registry:       ref.Context().RegistryStr(),
+		Repository:     ref.Context().RepositoryStr(),
+		Digest:         ref.DigestStr(),
+		Origins:        origins,
+	}
+
+	for _, origin := range origins {
+		if origin.Signed != nil {
+			img.Signed = true
+		}
+		if origin.Verified != nil {
+			img.Verified = true
+		}
+	}
+
+	details, err := ctlimg.NewDetailsFetcher(registry).Fetch(url)
+	if err != nil {
+		return InputImage{}, err
+	}
+
+	img.Platforms = details.Platforms
+	img.Labels = details.Labels
+	img.Signed = img.Signed || details.Signed
+
+	return img, nil
+}
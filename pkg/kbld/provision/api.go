@@ -0,0 +1,138 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provision
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+)
+
+// apiClient sends JSON requests to registry management APIs (e.g. Harbor, Quay)
+type apiClient struct {
+	baseURL string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newAPIClient(url, registryHost string, headers map[string]string) apiClient {
+	if len(url) == 0 {
+		url = "https://" + registryHost
+	}
+	return apiClient{strings.TrimSuffix(url, "/"), headers, &http.Client{Timeout: 30 * time.Second}}
+}
+
+// do returns response status code and unmarshals successful response into out (if given)
+func (c apiClient) do(method, path string, in, out interface{}) (int, error) {
+	var body io.Reader
+
+	if in != nil {
+		bs, err := json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewReader(bs)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, val := range c.headers {
+		req.Header.Set(name, val)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("Expected %s %s to succeed, but got status '%s': %s",
+			method, path, resp.Status, bytes.TrimSpace(respBody[:min(len(respBody), 1024)]))
+	}
+
+	if out != nil {
+		err := json.Unmarshal(respBody, out)
+		if err != nil {
+			return resp.StatusCode, fmt.Errorf("Unmarshaling response of %s %s: %s", method, path, err)
+		}
+	}
+
+	return resp.StatusCode, nil
+}
+
+// splitRepository returns registry host, namespace (first path segment) and rest of repository path
+func splitRepository(repo string) (string, string, string, error) {
+	parsedRepo, err := regname.NewRepository(repo, regname.WeakValidation)
+	if err != nil {
+		return "", "", "", fmt.Errorf("Parsing repository '%s': %s", repo, err)
+	}
+
+	pieces := strings.SplitN(parsedRepo.RepositoryStr(), "/", 2)
+	if len(pieces) != 2 {
+		return "", "", "", fmt.Errorf("Expected repository '%s' to include namespace (e.g. registry.corp/team/app)", repo)
+	}
+
+	return parsedRepo.RegistryStr(), pieces[0], pieces[1], nil
+}
+
+// ensuredSet remembers what was already ensured so that each repository (or project)
+// is only checked once per run even when pushed to by several images
+type ensuredSet struct {
+	lock sync.Mutex
+	keys map[string]*ensuredKey
+}
+
+type ensuredKey struct {
+	lock    sync.Mutex
+	ensured bool
+}
+
+func newEnsuredSet() *ensuredSet {
+	return &ensuredSet{keys: map[string]*ensuredKey{}}
+}
+
+// Do calls ensureFunc unless given key was already ensured. Concurrent callers
+// with the same key wait for the first one; failures are retried by later callers.
+func (s *ensuredSet) Do(key string, ensureFunc func() error) error {
+	s.lock.Lock()
+	ensured, found := s.keys[key]
+	if !found {
+		ensured = &ensuredKey{}
+		s.keys[key] = ensured
+	}
+	s.lock.Unlock()
+
+	ensured.lock.Lock()
+	defer ensured.lock.Unlock()
+
+	if ensured.ensured {
+		return nil
+	}
+
+	err := ensureFunc()
+	if err == nil {
+		ensured.ensured = true
+	}
+	return err
+}
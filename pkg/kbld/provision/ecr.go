@@ -0,0 +1,288 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provision
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+)
+
+/*
+
+Example:
+
+$ aws ecr describe-repositories --repository-names team/app \
+    --registry-id 123456789012 --region us-east-1 --output json
+An error occurred (RepositoryNotFoundException) when calling the DescribeRepositories operation: ...
+
+$ aws ecr create-repository --repository-name team/app \
+    --registry-id 123456789012 --region us-east-1 --output json \
+    --image-scanning-configuration scanOnPush=true --tags Key=team,Value=platform
+
+*/
+
+const (
+	ecrRepositoryNotFoundErr      = "RepositoryNotFoundException"
+	ecrRepositoryAlreadyExistsErr = "RepositoryAlreadyExistsException"
+)
+
+var (
+	// e.g. 123456789012.dkr.ecr.us-east-1.amazonaws.com or 123456789012.dkr.ecr-fips.us-gov-west-1.amazonaws.com
+	ecrHostRegexp = regexp.MustCompile(`^(\d{12})\.dkr\.ecr(?:-fips)?\.([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?$`)
+)
+
+// ECRRepository identifies repository in AWS ECR
+type ECRRepository struct {
+	Host       string
+	RegistryID string
+	Region     string
+	Name       string
+}
+
+// NewECRRepository parses repository (e.g. 123456789012.dkr.ecr.us-east-1.amazonaws.com/app).
+// Second return value is false for repositories that are not in ECR.
+func NewECRRepository(repo string) (ECRRepository, bool, error) {
+	parsedRepo, err := regname.NewRepository(repo, regname.WeakValidation)
+	if err != nil {
+		return ECRRepository{}, false, fmt.Errorf("Parsing repository '%s': %s", repo, err)
+	}
+
+	matches := ecrHostRegexp.FindStringSubmatch(parsedRepo.RegistryStr())
+	if len(matches) != 3 {
+		return ECRRepository{}, false, nil
+	}
+
+	ecrRepo := ECRRepository{
+		Host:       matches[0],
+		RegistryID: matches[1],
+		Region:     matches[2],
+		Name:       parsedRepo.RepositoryStr(),
+	}
+
+	return ecrRepo, true, nil
+}
+
+func (r ECRRepository) String() string {
+	return r.Host + "/" + r.Name
+}
+
+// ECR ensures that repositories exist in AWS ECR (using aws CLI).
+// Repositories known to exist are remembered so that
+// images pushed to the same repository do not check it again.
+type ECR struct {
+	existing *ecrExisting
+	logger   ctllog.Logger
+}
+
+type ecrExisting struct {
+	lock  sync.Mutex
+	repos map[string]struct{}
+}
+
+func NewECR(logger ctllog.Logger) ECR {
+	return ECR{&ecrExisting{repos: map[string]struct{}{}}, logger}
+}
+
+// ECRDestination lists repositories of a destination together with its ECR options
+type ECRDestination struct {
+	Repos []string
+	Opts  ctlconf.ImageDestinationECR
+}
+
+// Ensure checks that ECR repositories among given repositories exist
+// and either creates missing ones or fails listing them
+func (e ECR) Ensure(repos []string, opts ctlconf.ImageDestinationECR) error {
+	return e.EnsureAll([]ECRDestination{{repos, opts}})
+}
+
+// EnsureAll checks repositories of all given destinations (e.g. before any image is built)
+// so that single error lists missing repositories across destinations
+func (e ECR) EnsureAll(dsts []ECRDestination) error {
+	var missing []ECRRepository
+	// Repositories shared by destinations are only checked once
+	seenMissing := map[string]struct{}{}
+
+	for _, dst := range dsts {
+		dstMissing, err := e.missing(dst.Repos, dst.Opts, seenMissing)
+		if err != nil {
+			return err
+		}
+
+		if dst.Opts.CreateRepositories {
+			for _, ecrRepo := range dstMissing {
+				err := e.create(ecrRepo, dst.Opts)
+				if err != nil {
+					return err
+				}
+				e.existing.add(ecrRepo)
+			}
+			continue
+		}
+
+		for _, ecrRepo := range dstMissing {
+			if _, found := seenMissing[ecrRepo.String()]; !found {
+				seenMissing[ecrRepo.String()] = struct{}{}
+				missing = append(missing, ecrRepo)
+			}
+		}
+	}
+
+	var missingStrs []string
+	for _, ecrRepo := range missing {
+		// Repository may have been created for another destination
+		if !e.existing.has(ecrRepo) {
+			missingStrs = append(missingStrs, ecrRepo.String())
+		}
+	}
+
+	if len(missingStrs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("Expected ECR repositories to exist, but were missing: %s "+
+		"(hint: enable ecr.createRepositories to create them)", strings.Join(missingStrs, ", "))
+}
+
+// missing returns ECR repositories among given repositories that do not exist
+// (repositories already known to be missing are not checked again)
+func (e ECR) missing(repos []string, opts ctlconf.ImageDestinationECR,
+	knownMissing map[string]struct{}) ([]ECRRepository, error) {
+
+	var ecrRepos []ECRRepository
+
+	for _, repo := range repos {
+		ecrRepo, found, err := NewECRRepository(repo)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			ecrRepos = append(ecrRepos, ecrRepo)
+		}
+	}
+
+	if len(ecrRepos) == 0 {
+		return nil, fmt.Errorf("Expected at least one of destinations '%s' to be ECR repository "+
+			"(e.g. 123456789012.dkr.ecr.us-east-1.amazonaws.com/app), but none were", strings.Join(repos, "', '"))
+	}
+
+	var missing []ECRRepository
+
+	for _, ecrRepo := range ecrRepos {
+		if e.existing.has(ecrRepo) {
+			continue
+		}
+		if _, found := knownMissing[ecrRepo.String()]; found {
+			missing = append(missing, ecrRepo)
+			continue
+		}
+
+		exists, err := e.exists(ecrRepo, opts)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			e.existing.add(ecrRepo)
+		} else {
+			missing = append(missing, ecrRepo)
+		}
+	}
+
+	return missing, nil
+}
+
+func (e ECR) exists(repo ECRRepository, opts ctlconf.ImageDestinationECR) (bool, error) {
+	stderr, err := e.aws(repo, opts, io.Discard, "describe-repositories", "--repository-names", repo.Name)
+	if err != nil {
+		if strings.Contains(stderr, ecrRepositoryNotFoundErr) {
+			return false, nil
+		}
+		return false, fmt.Errorf("Describing ECR repository '%s': %s (stderr: %s)", repo, err, strings.TrimSpace(stderr))
+	}
+	return true, nil
+}
+
+func (e ECR) create(repo ECRRepository, opts ctlconf.ImageDestinationECR) error {
+	prefixedLogger := e.logger.NewPrefixedWriter(repo.String() + " | ")
+
+	prefixedLogger.WriteStr("creating ECR repository\n")
+
+	cmdArgs := []string{"create-repository", "--repository-name", repo.Name}
+
+	if opts.ScanOnPush {
+		cmdArgs = append(cmdArgs, "--image-scanning-configuration", "scanOnPush=true")
+	}
+	if len(opts.ImageTagMutability) > 0 {
+		cmdArgs = append(cmdArgs, "--image-tag-mutability", opts.ImageTagMutability)
+	}
+
+	if len(opts.Tags) > 0 {
+		var keys []string
+		for key := range opts.Tags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		cmdArgs = append(cmdArgs, "--tags")
+		for _, key := range keys {
+			cmdArgs = append(cmdArgs, fmt.Sprintf("Key=%s,Value=%s", key, opts.Tags[key]))
+		}
+	}
+
+	stderr, err := e.aws(repo, opts, prefixedLogger, cmdArgs...)
+	if err != nil {
+		// Repository may have been created concurrently (e.g. by another kbld run)
+		if strings.Contains(stderr, ecrRepositoryAlreadyExistsErr) {
+			return nil
+		}
+		prefixedLogger.WriteStr("error: %s\n", err)
+		return fmt.Errorf("Creating ECR repository '%s': %s", repo, err)
+	}
+
+	return nil
+}
+
+func (e ECR) aws(repo ECRRepository, opts ctlconf.ImageDestinationECR,
+	logger io.Writer, args ...string) (string, error) {
+
+	args = append([]string{"ecr"}, args...)
+	args = append(args, "--registry-id", repo.RegistryID, "--region", repo.Region, "--output", "json")
+
+	if len(opts.Profile) > 0 {
+		args = append(args, "--profile", opts.Profile)
+	}
+
+	var stderrBuf bytes.Buffer
+
+	cmd := exec.Command("aws", args...)
+	cmd.Stderr = io.MultiWriter(&stderrBuf, logger)
+
+	err := cmd.Run()
+
+	return stderrBuf.String(), err
+}
+
+func (r *ecrExisting) has(repo ECRRepository) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	_, found := r.repos[repo.String()]
+	return found
+}
+
+func (r *ecrExisting) add(repo ECRRepository) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.repos[repo.String()] = struct{}{}
+}
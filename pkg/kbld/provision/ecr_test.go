@@ -0,0 +1,136 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package provision_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlprov "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/provision"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/testutil"
+)
+
+func TestNewECRRepository(t *testing.T) {
+	repo, found, err := ctlprov.NewECRRepository("123456789012.dkr.ecr.us-east-1.amazonaws.com/team/app")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, ctlprov.ECRRepository{
+		Host:       "123456789012.dkr.ecr.us-east-1.amazonaws.com",
+		RegistryID: "123456789012",
+		Region:     "us-east-1",
+		Name:       "team/app",
+	}, repo)
+
+	repo, found, err = ctlprov.NewECRRepository("123456789012.dkr.ecr-fips.us-gov-west-1.amazonaws.com/app")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, "us-gov-west-1", repo.Region)
+
+	_, found, err = ctlprov.NewECRRepository("registry.corp/team/app")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestECREnsure(t *testing.T) {
+	awsLog := filepath.Join(t.TempDir(), "aws.log")
+
+	testutil.FakeBinaries(t, map[string]string{
+		"aws": `echo "aws $@" >> ` + awsLog + `
+case "$2 $4" in
+"describe-repositories existing")
+  echo '{}' ;;
+"describe-repositories "*)
+  echo "An error occurred (RepositoryNotFoundException) when calling the DescribeRepositories operation" >&2; exit 254 ;;
+"create-repository "*)
+  echo '{}' ;;
+esac
+`,
+	})
+
+	const host = "123456789012.dkr.ecr.us-east-1.amazonaws.com"
+
+	readLog := func() []string {
+		bs, err := os.ReadFile(awsLog)
+		require.NoError(t, err)
+		require.NoError(t, os.Remove(awsLog))
+		return strings.Split(strings.TrimSpace(string(bs)), "\n")
+	}
+
+	t.Run("fails listing missing repositories", func(t *testing.T) {
+		ecr := ctlprov.NewECR(ctllog.NewLogger(&strings.Builder{}))
+
+		err := ecr.Ensure([]string{host + "/existing", host + "/missing1", "registry.corp/other", host + "/missing2"},
+			ctlconf.ImageDestinationECR{})
+		require.Error(t, err)
+		assert.Equal(t, "Expected ECR repositories to exist, but were missing: "+host+"/missing1, "+host+"/missing2 "+
+			"(hint: enable ecr.createRepositories to create them)", err.Error())
+
+		assert.Len(t, readLog(), 3)
+	})
+
+	t.Run("creates missing repositories", func(t *testing.T) {
+		ecr := ctlprov.NewECR(ctllog.NewLogger(&strings.Builder{}))
+
+		opts := ctlconf.ImageDestinationECR{
+			CreateRepositories: true,
+			Tags:               map[string]string{"team": "platform", "env": "prod"},
+			ScanOnPush:         true,
+			ImageTagMutability: ctlconf.ImageDestinationECRTagMutabilityImmutable,
+			Profile:            "ci",
+		}
+
+		require.NoError(t, ecr.Ensure([]string{host + "/existing", host + "/missing"}, opts))
+
+		assert.Equal(t, []string{
+			"aws ecr describe-repositories --repository-names existing --registry-id 123456789012 --region us-east-1 --output json --profile ci",
+			"aws ecr describe-repositories --repository-names missing --registry-id 123456789012 --region us-east-1 --output json --profile ci",
+			"aws ecr create-repository --repository-name missing --image-scanning-configuration scanOnPush=true " +
+				"--image-tag-mutability IMMUTABLE --tags Key=env,Value=prod Key=team,Value=platform " +
+				"--registry-id 123456789012 --region us-east-1 --output json --profile ci",
+		}, readLog())
+
+		// Repositories are only checked once
+		require.NoError(t, ecr.Ensure([]string{host + "/existing", host + "/missing"}, opts))
+		_, err := os.Stat(awsLog)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("fails listing missing repositories of all destinations", func(t *testing.T) {
+		ecr := ctlprov.NewECR(ctllog.NewLogger(&strings.Builder{}))
+
+		err := ecr.EnsureAll([]ctlprov.ECRDestination{
+			{Repos: []string{host + "/existing", host + "/missing1"}},
+			{Repos: []string{host + "/missing2", host + "/missing1"}},
+			{Repos: []string{host + "/created"}, Opts: ctlconf.ImageDestinationECR{CreateRepositories: true}},
+		})
+		require.Error(t, err)
+		assert.Equal(t, "Expected ECR repositories to exist, but were missing: "+host+"/missing1, "+host+"/missing2 "+
+			"(hint: enable ecr.createRepositories to create them)", err.Error())
+
+		// Repositories shared by destinations are checked once
+		assert.Equal(t, []string{
+			"aws ecr describe-repositories --repository-names existing --registry-id 123456789012 --region us-east-1 --output json",
+			"aws ecr describe-repositories --repository-names missing1 --registry-id 123456789012 --region us-east-1 --output json",
+			"aws ecr describe-repositories --repository-names missing2 --registry-id 123456789012 --region us-east-1 --output json",
+			"aws ecr describe-repositories --repository-names created --registry-id 123456789012 --region us-east-1 --output json",
+			"aws ecr create-repository --repository-name created --registry-id 123456789012 --region us-east-1 --output json",
+		}, readLog())
+	})
+
+	t.Run("requires ECR destination", func(t *testing.T) {
+		ecr := ctlprov.NewECR(ctllog.NewLogger(&strings.Builder{}))
+
+		err := ecr.Ensure([]string{"registry.corp/app"}, ctlconf.ImageDestinationECR{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Expected at least one of destinations 'registry.corp/app' to be ECR repository")
+	})
+}
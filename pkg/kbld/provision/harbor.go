@@ -0,0 +1,113 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provision
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+)
+
+/*
+
+Example:
+
+$ curl -u user:pass -H 'X-Is-Resource-Name: true' https://harbor.corp/api/v2.0/projects/team
+{"name": "team", "metadata": {"public": "false"}, ...}
+
+$ curl -u user:pass -X POST https://harbor.corp/api/v2.0/projects \
+    -d '{"project_name": "team", "metadata": {"public": "false"}}'
+
+*/
+
+// Harbor ensures that Harbor projects of repositories exist
+// (Harbor creates repositories within projects on first push)
+type Harbor struct {
+	ensured *ensuredSet
+	logger  ctllog.Logger
+}
+
+func NewHarbor(logger ctllog.Logger) Harbor {
+	return Harbor{newEnsuredSet(), logger}
+}
+
+type harborProject struct {
+	Name     string                `json:"project_name,omitempty"`
+	Metadata harborProjectMetadata `json:"metadata"`
+}
+
+type harborProjectMetadata struct {
+	// Harbor represents booleans as strings in project metadata
+	Public string `json:"public,omitempty"`
+}
+
+// Ensure creates project of repository (or changes its visibility) unless it was already ensured
+func (h Harbor) Ensure(repo string, opts ctlconf.ImageDestinationHarbor) error {
+	host, project, _, err := splitRepository(repo)
+	if err != nil {
+		return err
+	}
+
+	// Repositories within the same project share provisioning
+	return h.ensured.Do(opts.URL+" "+host+"/"+project+" "+opts.Visibility,
+		func() error { return h.ensure(repo, host, project, opts) })
+}
+
+func (h Harbor) ensure(repo, host, project string, opts ctlconf.ImageDestinationHarbor) error {
+	headers := map[string]string{"X-Is-Resource-Name": "true"}
+	if len(opts.Username) > 0 {
+		creds := os.ExpandEnv(opts.Username) + ":" + os.ExpandEnv(opts.Password)
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(creds))
+	}
+
+	client := newAPIClient(opts.URL, host, headers)
+	projectPath := "/api/v2.0/projects/" + url.PathEscape(project)
+
+	prefixedLogger := h.logger.NewPrefixedWriter(repo + " | ")
+
+	var existing harborProject
+
+	status, err := client.do(http.MethodGet, projectPath, nil, &existing)
+	switch {
+	case status == http.StatusNotFound:
+		prefixedLogger.WriteStr("creating Harbor project '%s'\n", project)
+
+		newProject := harborProject{Name: project, Metadata: harborProjectMetadata{Public: h.public(opts.Visibility)}}
+
+		status, err := client.do(http.MethodPost, "/api/v2.0/projects", newProject, nil)
+		// Project may have been created concurrently (e.g. for another image)
+		if err != nil && status != http.StatusConflict {
+			return fmt.Errorf("Creating Harbor project '%s': %s", project, err)
+		}
+		return nil
+
+	case err != nil:
+		return fmt.Errorf("Getting Harbor project '%s': %s", project, err)
+	}
+
+	if len(opts.Visibility) > 0 && existing.Metadata.Public != h.public(opts.Visibility) {
+		prefixedLogger.WriteStr("changing visibility of Harbor project '%s' to %s\n", project, opts.Visibility)
+
+		updatedProject := harborProject{Metadata: harborProjectMetadata{Public: h.public(opts.Visibility)}}
+
+		_, err := client.do(http.MethodPut, projectPath, updatedProject, nil)
+		if err != nil {
+			return fmt.Errorf("Changing visibility of Harbor project '%s': %s", project, err)
+		}
+	}
+
+	return nil
+}
+
+func (Harbor) public(visibility string) string {
+	if visibility == ctlconf.RepositoryVisibilityPublic {
+		return "true"
+	}
+	return "false"
+}
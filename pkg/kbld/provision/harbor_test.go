@@ -0,0 +1,121 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provision_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlprov "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/provision"
+)
+
+// fakeAPI records requests and responds with projects or repositories it knows about
+type fakeAPI struct {
+	URL string
+
+	lock     sync.Mutex
+	requests []string
+	objects  map[string]string
+}
+
+func newFakeAPI(t *testing.T, objects map[string]string) *fakeAPI {
+	api := &fakeAPI{objects: objects}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		api.lock.Lock()
+		defer api.lock.Unlock()
+
+		body, _ := io.ReadAll(req.Body)
+		api.requests = append(api.requests, strings.TrimSpace(req.Method+" "+req.URL.Path+" "+req.Header.Get("Authorization")+" "+string(body)))
+
+		if req.Method != http.MethodGet {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		object, found := api.objects[req.URL.Path]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(object))
+	}))
+	t.Cleanup(server.Close)
+
+	api.URL = server.URL
+
+	return api
+}
+
+func (a *fakeAPI) Requests() []string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	result := a.requests
+	a.requests = nil
+	return result
+}
+
+func TestHarborEnsure(t *testing.T) {
+	api := newFakeAPI(t, map[string]string{
+		"/api/v2.0/projects/existing": `{"name": "existing", "metadata": {"public": "false"}}`,
+	})
+	t.Setenv("HARBOR_PASSWORD", "pass")
+
+	harbor := ctlprov.NewHarbor(ctllog.NewLogger(io.Discard))
+	opts := ctlconf.ImageDestinationHarbor{URL: api.URL, Username: "user", Password: "${HARBOR_PASSWORD}"}
+
+	require.NoError(t, harbor.Ensure("harbor.corp/existing/app", opts))
+	assert.Equal(t, []string{"GET /api/v2.0/projects/existing Basic dXNlcjpwYXNz"}, api.Requests())
+
+	require.NoError(t, harbor.Ensure("harbor.corp/missing/app", opts))
+
+	requests := api.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "POST /api/v2.0/projects Basic dXNlcjpwYXNz", requests[1][:strings.Index(requests[1], " {")])
+	assertJSONSuffix(t, `{"project_name": "missing", "metadata": {"public": "false"}}`, requests[1])
+
+	opts.Visibility = ctlconf.RepositoryVisibilityPublic
+
+	require.NoError(t, harbor.Ensure("harbor.corp/existing/app", opts))
+
+	requests = api.Requests()
+	require.Len(t, requests, 2)
+	assert.True(t, strings.HasPrefix(requests[1], "PUT /api/v2.0/projects/existing "))
+	assertJSONSuffix(t, `{"metadata": {"public": "true"}}`, requests[1])
+
+	err := harbor.Ensure("harbor.corp/app", opts)
+	require.Error(t, err)
+	assert.Equal(t, "Expected repository 'harbor.corp/app' to include namespace (e.g. registry.corp/team/app)", err.Error())
+}
+
+func TestHarborEnsureOnlyOncePerProject(t *testing.T) {
+	api := newFakeAPI(t, map[string]string{
+		"/api/v2.0/projects/team": `{"name": "team", "metadata": {"public": "false"}}`,
+	})
+
+	harbor := ctlprov.NewHarbor(ctllog.NewLogger(io.Discard))
+	opts := ctlconf.ImageDestinationHarbor{URL: api.URL}
+
+	require.NoError(t, harbor.Ensure("harbor.corp/team/app", opts))
+	require.NoError(t, harbor.Ensure("harbor.corp/team/app", opts))
+	require.NoError(t, harbor.Ensure("harbor.corp/team/other-app", opts))
+
+	assert.Equal(t, []string{"GET /api/v2.0/projects/team"}, api.Requests())
+}
+
+func assertJSONSuffix(t *testing.T, expected, request string) {
+	var expectedObj, actualObj interface{}
+	require.NoError(t, json.Unmarshal([]byte(expected), &expectedObj))
+	require.NoError(t, json.Unmarshal([]byte(request[strings.Index(request, " {")+1:]), &actualObj))
+	assert.Equal(t, expectedObj, actualObj)
+}
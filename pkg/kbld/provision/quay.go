@@ -0,0 +1,111 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provision
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+)
+
+/*
+
+Example:
+
+$ curl -H 'Authorization: Bearer token' https://quay.io/api/v1/repository/team/app
+{"namespace": "team", "name": "app", "is_public": false, ...}
+
+$ curl -H 'Authorization: Bearer token' -X POST https://quay.io/api/v1/repository \
+    -d '{"namespace": "team", "repository": "app", "visibility": "private", "description": "", "repo_kind": "image"}'
+
+*/
+
+// Quay ensures that Quay repositories exist
+// (pushes using robot accounts typically are not allowed to create them)
+type Quay struct {
+	ensured *ensuredSet
+	logger  ctllog.Logger
+}
+
+func NewQuay(logger ctllog.Logger) Quay {
+	return Quay{newEnsuredSet(), logger}
+}
+
+type quayRepository struct {
+	IsPublic bool `json:"is_public"`
+}
+
+type quayNewRepository struct {
+	Namespace   string `json:"namespace"`
+	Repository  string `json:"repository"`
+	Visibility  string `json:"visibility"`
+	Description string `json:"description"`
+	RepoKind    string `json:"repo_kind"`
+}
+
+type quayVisibility struct {
+	Visibility string `json:"visibility"`
+}
+
+// Ensure creates repository (or changes its visibility) unless it was already ensured
+func (q Quay) Ensure(repo string, opts ctlconf.ImageDestinationQuay) error {
+	return q.ensured.Do(opts.URL+" "+repo+" "+opts.Visibility, func() error { return q.ensure(repo, opts) })
+}
+
+func (q Quay) ensure(repo string, opts ctlconf.ImageDestinationQuay) error {
+	host, namespace, name, err := splitRepository(repo)
+	if err != nil {
+		return err
+	}
+
+	client := newAPIClient(opts.URL, host, map[string]string{"Authorization": "Bearer " + os.ExpandEnv(opts.Token)})
+	repoPath := "/api/v1/repository/" + namespace + "/" + name
+
+	prefixedLogger := q.logger.NewPrefixedWriter(repo + " | ")
+
+	var existing quayRepository
+
+	status, err := client.do(http.MethodGet, repoPath, nil, &existing)
+	switch {
+	case status == http.StatusNotFound:
+		prefixedLogger.WriteStr("creating Quay repository\n")
+
+		visibility := opts.Visibility
+		if len(visibility) == 0 {
+			visibility = ctlconf.RepositoryVisibilityPrivate
+		}
+
+		newRepo := quayNewRepository{Namespace: namespace, Repository: name, Visibility: visibility, RepoKind: "image"}
+
+		status, err := client.do(http.MethodPost, "/api/v1/repository", newRepo, nil)
+		if err != nil && !q.alreadyExists(status, err) {
+			return fmt.Errorf("Creating Quay repository '%s/%s': %s", namespace, name, err)
+		}
+		return nil
+
+	case err != nil:
+		return fmt.Errorf("Getting Quay repository '%s/%s': %s", namespace, name, err)
+	}
+
+	if len(opts.Visibility) > 0 && existing.IsPublic != (opts.Visibility == ctlconf.RepositoryVisibilityPublic) {
+		prefixedLogger.WriteStr("changing visibility of Quay repository to %s\n", opts.Visibility)
+
+		_, err := client.do(http.MethodPost, repoPath+"/changevisibility", quayVisibility{opts.Visibility}, nil)
+		if err != nil {
+			return fmt.Errorf("Changing visibility of Quay repository '%s/%s': %s", namespace, name, err)
+		}
+	}
+
+	return nil
+}
+
+// alreadyExists returns true when repository was created concurrently
+// (e.g. by another kbld run), which Quay reports as bad request
+func (Quay) alreadyExists(status int, err error) bool {
+	return status == http.StatusBadRequest && strings.Contains(strings.ToLower(err.Error()), "already exists")
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provision_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlprov "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/provision"
+)
+
+func TestQuayEnsure(t *testing.T) {
+	api := newFakeAPI(t, map[string]string{
+		"/api/v1/repository/team/existing": `{"namespace": "team", "name": "existing", "is_public": true}`,
+	})
+
+	quay := ctlprov.NewQuay(ctllog.NewLogger(io.Discard))
+	opts := ctlconf.ImageDestinationQuay{URL: api.URL, Token: "token"}
+
+	require.NoError(t, quay.Ensure("quay.io/team/existing", opts))
+	assert.Equal(t, []string{"GET /api/v1/repository/team/existing Bearer token"}, api.Requests())
+
+	require.NoError(t, quay.Ensure("quay.io/team/missing", opts))
+
+	requests := api.Requests()
+	require.Len(t, requests, 2)
+	assert.True(t, strings.HasPrefix(requests[1], "POST /api/v1/repository Bearer token "))
+	assertJSONSuffix(t, `{"namespace": "team", "repository": "missing", "visibility": "private",
+		"description": "", "repo_kind": "image"}`, requests[1])
+
+	opts.Visibility = ctlconf.RepositoryVisibilityPrivate
+
+	require.NoError(t, quay.Ensure("quay.io/team/existing", opts))
+
+	requests = api.Requests()
+	require.Len(t, requests, 2)
+	assert.True(t, strings.HasPrefix(requests[1], "POST /api/v1/repository/team/existing/changevisibility "))
+	assertJSONSuffix(t, `{"visibility": "private"}`, requests[1])
+}
+
+func TestQuayEnsureOnlyOncePerRepository(t *testing.T) {
+	api := newFakeAPI(t, map[string]string{})
+
+	quay := ctlprov.NewQuay(ctllog.NewLogger(io.Discard))
+	opts := ctlconf.ImageDestinationQuay{URL: api.URL, Token: "token"}
+
+	require.NoError(t, quay.Ensure("quay.io/team/app", opts))
+	require.Len(t, api.Requests(), 2)
+
+	// Copies of provisioner share ensured repositories
+	quayCopy := quay
+	require.NoError(t, quayCopy.Ensure("quay.io/team/app", opts))
+	assert.Empty(t, api.Requests())
+
+	require.NoError(t, quay.Ensure("quay.io/team/other", opts))
+	assert.Len(t, api.Requests(), 2)
+}
+
+func TestQuayEnsureToleratesConcurrentlyCreatedRepository(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodGet {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error_message": "Repository already exists", "error_type": "invalid_request"}`))
+	}))
+	defer server.Close()
+
+	quay := ctlprov.NewQuay(ctllog.NewLogger(io.Discard))
+
+	require.NoError(t, quay.Ensure("quay.io/team/app", ctlconf.ImageDestinationQuay{URL: server.URL}))
+}
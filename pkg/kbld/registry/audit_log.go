@@ -0,0 +1,112 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+
+	regauthn "github.com/google/go-containerregistry/pkg/authn"
+	regname "github.com/google/go-containerregistry/pkg/name"
+)
+
+type AuditOperation string
+
+const (
+	AuditOperationPush   AuditOperation = "push"
+	AuditOperationTag    AuditOperation = "tag"
+	AuditOperationDelete AuditOperation = "delete"
+)
+
+// AuditLog appends record of each mutating registry operation
+// to a JSON lines file. File is opened for each record in append mode
+// so that multiple kbld processes could share the same log.
+type AuditLog struct {
+	path string
+	now  func() time.Time
+	user string
+
+	writeLock sync.Mutex
+}
+
+type AuditEntry struct {
+	Time      time.Time      `json:"time"`
+	Operation AuditOperation `json:"operation"`
+	// Destination is repository (with tag when applicable) that was modified
+	Destination string `json:"destination"`
+	Digest      string `json:"digest,omitempty"`
+	// Identity is registry username used for the operation
+	Identity string `json:"identity"`
+	// User is local user that ran kbld
+	User string `json:"user,omitempty"`
+	// Tool is set when operation was performed by external tool (e.g. docker)
+	Tool  string `json:"tool,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+func NewAuditLog(path string) *AuditLog {
+	localUser := os.Getenv("USER")
+	if currUser, err := user.Current(); err == nil {
+		localUser = currUser.Username
+	}
+	return &AuditLog{path: path, now: time.Now, user: localUser}
+}
+
+func (l *AuditLog) Record(entry AuditEntry) error {
+	entry.Time = l.now().UTC()
+	entry.User = l.user
+
+	bs, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.writeLock.Lock()
+	defer l.writeLock.Unlock()
+
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("Opening audit log '%s': %s", l.path, err)
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(bs, '\n'))
+	if err != nil {
+		return fmt.Errorf("Writing audit log '%s': %s", l.path, err)
+	}
+
+	return nil
+}
+
+// auditIdentity returns username that keychain provides for the repository
+// (secrets are never recorded)
+func auditIdentity(keychain regauthn.Keychain, repo regname.Repository) string {
+	auth, err := keychain.Resolve(repo)
+	if err != nil {
+		return "unknown"
+	}
+	if auth == regauthn.Anonymous {
+		return "anonymous"
+	}
+
+	authConf, err := auth.Authorization()
+	if err != nil {
+		return "unknown"
+	}
+
+	switch {
+	case len(authConf.Username) > 0:
+		return authConf.Username
+	case len(authConf.IdentityToken) > 0:
+		return "identity-token"
+	case len(authConf.RegistryToken) > 0:
+		return "registry-token"
+	default:
+		return "anonymous"
+	}
+}
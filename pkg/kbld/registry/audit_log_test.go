@@ -0,0 +1,72 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+func TestAuditLogRecordsExternalPush(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	t.Setenv("KBLD_TEST_AUDIT_HOSTNAME", "registry.example.com")
+	t.Setenv("KBLD_TEST_AUDIT_USERNAME", "ci-bot")
+	t.Setenv("KBLD_TEST_AUDIT_PASSWORD", "secret-password")
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	registry, err := ctlreg.NewRegistry(ctlreg.Opts{
+		EnvAuthPrefix: "KBLD_TEST_AUDIT",
+		AuditLog:      ctlreg.NewAuditLog(path),
+	})
+	require.NoError(t, err)
+
+	digest := "sha256:0000000000000000000000000000000000000000000000000000000000000001"
+
+	err = registry.RecordExternalPush("registry.example.com/app@"+digest, "docker")
+	require.NoError(t, err)
+	err = registry.RecordExternalPush("other.example.com/app@"+digest, "docker-buildx")
+	require.NoError(t, err)
+
+	bs, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	assert.NotContains(t, string(bs), "secret-password")
+
+	lines := strings.Split(strings.TrimSpace(string(bs)), "\n")
+	require.Len(t, lines, 2)
+
+	var entries []ctlreg.AuditEntry
+	for _, line := range lines {
+		var entry ctlreg.AuditEntry
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+
+	assert.Equal(t, ctlreg.AuditOperationPush, entries[0].Operation)
+	assert.Equal(t, "registry.example.com/app", entries[0].Destination)
+	assert.Equal(t, digest, entries[0].Digest)
+	assert.Equal(t, "ci-bot", entries[0].Identity)
+	assert.Equal(t, "docker", entries[0].Tool)
+	assert.False(t, entries[0].Time.IsZero())
+
+	assert.Equal(t, "other.example.com/app", entries[1].Destination)
+	assert.Equal(t, "anonymous", entries[1].Identity)
+	assert.Equal(t, "docker-buildx", entries[1].Tool)
+}
+
+func TestAuditLogNotConfigured(t *testing.T) {
+	registry, err := ctlreg.NewRegistry(ctlreg.Opts{})
+	require.NoError(t, err)
+
+	err = registry.RecordExternalPush("registry.example.com/app@sha256:0000000000000000000000000000000000000000000000000000000000000001", "docker")
+	require.NoError(t, err)
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	dockerconfig "github.com/docker/cli/cli/config"
+	"github.com/docker/cli/cli/config/configfile"
+	regauthn "github.com/google/go-containerregistry/pkg/authn"
+	regname "github.com/google/go-containerregistry/pkg/name"
+)
+
+// ConfigFileKeychain provides credentials from Docker config.json
+// formatted contents (also used by kubernetes.io/dockerconfigjson Secrets)
+type ConfigFileKeychain struct {
+	cf *configfile.ConfigFile
+}
+
+var _ regauthn.Keychain = ConfigFileKeychain{}
+
+func NewConfigFileKeychain(data []byte) (ConfigFileKeychain, error) {
+	cf, err := dockerconfig.LoadFromReader(bytes.NewReader(data))
+	if err != nil {
+		return ConfigFileKeychain{}, fmt.Errorf("Parsing auth config: %s", err)
+	}
+	return ConfigFileKeychain{cf}, nil
+}
+
+func NewConfigFileKeychainFromPath(path string) (ConfigFileKeychain, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ConfigFileKeychain{}, fmt.Errorf("Reading auth file '%s': %s", path, err)
+	}
+	return NewConfigFileKeychain(data)
+}
+
+func (k ConfigFileKeychain) Resolve(target regauthn.Resource) (regauthn.Authenticator, error) {
+	key := target.RegistryStr()
+	if key == regname.DefaultRegistry {
+		key = regauthn.DefaultAuthKey
+	}
+
+	cfg, err := k.cf.GetAuthConfig(key)
+	if err != nil {
+		return nil, err
+	}
+
+	authCfg := regauthn.AuthConfig{
+		Username:      cfg.Username,
+		Password:      cfg.Password,
+		Auth:          cfg.Auth,
+		IdentityToken: cfg.IdentityToken,
+		RegistryToken: cfg.RegistryToken,
+	}
+	if authCfg == (regauthn.AuthConfig{}) {
+		return regauthn.Anonymous, nil
+	}
+
+	return regauthn.FromConfig(authCfg), nil
+}
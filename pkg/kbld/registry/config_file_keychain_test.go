@@ -0,0 +1,40 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry_test
+
+import (
+	"testing"
+
+	regauthn "github.com/google/go-containerregistry/pkg/authn"
+	regname "github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+func TestConfigFileKeychain(t *testing.T) {
+	keychain, err := ctlreg.NewConfigFileKeychain([]byte(`{
+  "auths": {
+    "registry.example.com": {"auth": "dXNlcjpwYXNz"},
+    "https://index.docker.io/v1/": {"username": "hub-user", "password": "hub-pass"}
+  }
+}`))
+	require.NoError(t, err)
+
+	check := func(registry string, expected regauthn.AuthConfig) {
+		reg, err := regname.NewRegistry(registry)
+		require.NoError(t, err)
+
+		auth, err := keychain.Resolve(reg)
+		require.NoError(t, err)
+
+		cfg, err := auth.Authorization()
+		require.NoError(t, err)
+		assert.Equal(t, expected, *cfg, "registry: %s", registry)
+	}
+
+	check("registry.example.com", regauthn.AuthConfig{Username: "user", Password: "pass"})
+	check("index.docker.io", regauthn.AuthConfig{Username: "hub-user", Password: "hub-pass"})
+	check("other.example.com", regauthn.AuthConfig{})
+}
@@ -0,0 +1,121 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	regtypes "github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// digestVerifyingTransport verifies that pulled manifests and blobs
+// hash to their declared digests (requested digest or, for manifests
+// requested by tag, Docker-Content-Digest header). Mismatches are reported
+// as read errors so that misbehaving proxies and corrupted pull-through
+// caches do not go unnoticed.
+type digestVerifyingTransport struct {
+	delegate http.RoundTripper
+}
+
+var _ http.RoundTripper = digestVerifyingTransport{}
+
+func newDigestVerifyingTransport(delegate http.RoundTripper) http.RoundTripper {
+	return digestVerifyingTransport{delegate}
+}
+
+func (t digestVerifyingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.delegate.RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	// Blobs are commonly served via redirect to storage backend,
+	// hence expected digest comes from originally requested URL
+	origURL := req.URL
+	for prevResp := req.Response; prevResp != nil && prevResp.Request != nil; prevResp = prevResp.Request.Response {
+		origURL = prevResp.Request.URL
+	}
+
+	expectedDigest, found := expectedContentDigest(origURL, resp)
+	if !found {
+		return resp, nil
+	}
+
+	hasher, err := regv1.Hasher(expectedDigest.Algorithm)
+	if err != nil {
+		// Unsupported algorithms are left to be handled by callers
+		return resp, nil
+	}
+
+	resp.Body = &digestVerifyingReadCloser{
+		rc:       resp.Body,
+		url:      origURL.String(),
+		expected: expectedDigest,
+		hasher:   hasher,
+	}
+
+	return resp, nil
+}
+
+func expectedContentDigest(u *url.URL, resp *http.Response) (regv1.Hash, bool) {
+	for _, kind := range []string{"manifests", "blobs"} {
+		idx := strings.LastIndex(u.Path, "/"+kind+"/")
+		if idx == -1 {
+			continue
+		}
+
+		ref := u.Path[idx+len(kind)+2:]
+
+		if digest, err := regv1.NewHash(ref); err == nil {
+			return digest, true
+		}
+
+		if kind == "manifests" {
+			// Signed schema 1 manifests are known to have different
+			// digest from what registries report
+			if regtypes.MediaType(resp.Header.Get("Content-Type")) == regtypes.DockerManifestSchema1Signed {
+				return regv1.Hash{}, false
+			}
+			if digest, err := regv1.NewHash(resp.Header.Get("Docker-Content-Digest")); err == nil {
+				return digest, true
+			}
+		}
+
+		return regv1.Hash{}, false
+	}
+
+	return regv1.Hash{}, false
+}
+
+type digestVerifyingReadCloser struct {
+	rc       io.ReadCloser
+	url      string
+	expected regv1.Hash
+	hasher   hash.Hash
+}
+
+func (r *digestVerifyingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.rc.Read(p)
+	if n > 0 {
+		r.hasher.Write(p[:n])
+	}
+
+	if err == io.EOF {
+		actual := regv1.Hash{Algorithm: r.expected.Algorithm, Hex: fmt.Sprintf("%x", r.hasher.Sum(nil))}
+		if actual != r.expected {
+			return n, fmt.Errorf("Expected content of '%s' to match digest '%s', but was '%s' "+
+				"(registry or proxy returned corrupted content)", r.url, r.expected, actual)
+		}
+	}
+
+	return n, err
+}
+
+func (r *digestVerifyingReadCloser) Close() error { return r.rc.Close() }
@@ -0,0 +1,79 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+func TestRegistryVerifiesManifestDigest(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+
+	manifest := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",` +
+		`"config":{"mediaType":"application/vnd.oci.image.config.v1+json","size":2,` +
+		`"digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"},"layers":[]}`
+	manifestDigest := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(manifest)))
+
+	var reportedDigest string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, "/v2/app/manifests/") {
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			w.Header().Set("Docker-Content-Digest", reportedDigest)
+			w.Write([]byte(manifest))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	registry, err := ctlreg.NewRegistry(ctlreg.Opts{EnvAuthPrefix: "KBLD_TEST_DIGEST", Insecure: true})
+	require.NoError(t, err)
+
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	parseRef := func(t *testing.T, ref string) regname.Reference {
+		parsedRef, err := regname.ParseReference(ref)
+		require.NoError(t, err)
+		return parsedRef
+	}
+
+	t.Run("succeeds when content matches reported digest", func(t *testing.T) {
+		reportedDigest = manifestDigest
+
+		desc, err := registry.Generic(parseRef(t, host+"/app:latest"))
+		require.NoError(t, err)
+		assert.Equal(t, manifestDigest, desc.Digest.String())
+	})
+
+	t.Run("fails when content does not match reported digest", func(t *testing.T) {
+		reportedDigest = "sha256:0000000000000000000000000000000000000000000000000000000000000001"
+
+		_, err := registry.Generic(parseRef(t, host+"/app:latest"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Expected content of 'http://"+host+"/v2/app/manifests/latest' to match digest '"+reportedDigest+"'")
+	})
+
+	t.Run("fails when content does not match requested digest", func(t *testing.T) {
+		requestedDigest := "sha256:0000000000000000000000000000000000000000000000000000000000000002"
+		reportedDigest = requestedDigest
+
+		_, err := registry.Generic(parseRef(t, host+"/app@"+requestedDigest))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "to match digest '"+requestedDigest+"', but was '"+manifestDigest+"'")
+	})
+}
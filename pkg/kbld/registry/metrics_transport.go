@@ -0,0 +1,71 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/metrics"
+)
+
+// metricsTransport records failed requests and bytes sent to registries
+type metricsTransport struct {
+	delegate http.RoundTripper
+	metrics  *metrics.Metrics
+}
+
+var _ http.RoundTripper = metricsTransport{}
+
+func newMetricsTransport(delegate http.RoundTripper, metrics *metrics.Metrics) http.RoundTripper {
+	return metricsTransport{delegate, metrics}
+}
+
+func (t metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(req.Context())
+		req.Body = metricsReadCloser{req.Body, t.metrics}
+	}
+
+	resp, err := t.delegate.RoundTrip(req)
+	if err != nil {
+		t.metrics.ObserveRegistryResponse(0)
+		return nil, err
+	}
+
+	if !expectedRegistryResponse(req, resp) {
+		t.metrics.ObserveRegistryResponse(resp.StatusCode)
+	}
+
+	return resp, nil
+}
+
+// expectedRegistryResponse returns true for error responses that are part of normal
+// operation: auth challenges (401 to requests without credentials, which are retried
+// with credentials) and HEAD 404s (e.g. checking whether blob or tag exists)
+func expectedRegistryResponse(req *http.Request, resp *http.Response) bool {
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return len(req.Header.Get("Authorization")) == 0
+	case resp.StatusCode == http.StatusNotFound:
+		return req.Method == http.MethodHead
+	default:
+		return false
+	}
+}
+
+type metricsReadCloser struct {
+	rc      io.ReadCloser
+	metrics *metrics.Metrics
+}
+
+func (r metricsReadCloser) Read(p []byte) (int, error) {
+	n, err := r.rc.Read(p)
+	if n > 0 {
+		r.metrics.AddPushedBytes(int64(n))
+	}
+	return n, err
+}
+
+func (r metricsReadCloser) Close() error { return r.rc.Close() }
@@ -0,0 +1,69 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/metrics"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+func TestRegistryMetricsOnlyCountFailedRequests(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			w.Write([]byte(`{"token":"token"}`))
+		case len(r.Header.Get("Authorization")) == 0:
+			// Challenge is expected to be followed by request with credentials
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case strings.HasPrefix(r.URL.Path, "/v2/denied/"):
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	m := metrics.New()
+
+	registry, err := ctlreg.NewRegistry(ctlreg.Opts{
+		EnvAuthPrefix: "KBLD_TEST_METRICS",
+		Insecure:      true,
+		HeadOnly:      true,
+		Metrics:       m,
+	})
+	require.NoError(t, err)
+
+	missingRef, err := regname.ParseReference(host+"/missing:1.0.0", regname.Insecure)
+	require.NoError(t, err)
+
+	_, err = registry.Digest(missingRef)
+	require.Error(t, err)
+	assert.True(t, ctlreg.IsNotFoundErr(err))
+
+	deniedRef, err := regname.ParseReference(host+"/denied:1.0.0", regname.Insecure)
+	require.NoError(t, err)
+
+	_, err = registry.Generic(deniedRef)
+	require.Error(t, err)
+
+	assert.Equal(t, float64(0), m.RegistryErrors.Value("401"))
+	assert.Equal(t, float64(0), m.RegistryErrors.Value("404"))
+	assert.Equal(t, float64(1), m.RegistryErrors.Value("403"))
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var (
+	// Docker Hub is referred to as docker.io, but is served
+	// (and authenticated) from several other hosts
+	dockerHubHosts = []string{"docker.io", "index.docker.io", "registry-1.docker.io", "auth.docker.io"}
+)
+
+// ProxyRule selects proxy for requests to matching registry host
+type ProxyRule struct {
+	// Host is registry host optionally starting with '*.' to match subdomains
+	Host string
+	// URL is HTTP, HTTPS or SOCKS5 proxy (nil means direct connection)
+	URL *url.URL
+}
+
+func (r ProxyRule) matches(host string) bool {
+	host = strings.ToLower(host)
+	ruleHost := strings.ToLower(r.Host)
+
+	if ruleHost == "docker.io" || ruleHost == "index.docker.io" {
+		for _, hubHost := range dockerHubHosts {
+			if host == hubHost {
+				return true
+			}
+		}
+		return false
+	}
+
+	if strings.HasPrefix(ruleHost, "*.") {
+		return strings.HasSuffix(host, ruleHost[1:]) || stripDefaultPort(host) == ruleHost[2:]
+	}
+
+	return host == ruleHost || stripDefaultPort(host) == ruleHost
+}
+
+// newProxyFunc returns proxy for request according to rules; requests
+// that are not matched use proxy environment variables. Redirects
+// (e.g. blob downloads from storage buckets) use the same proxy
+// as the request that was redirected.
+func newProxyFunc(rules []ProxyRule) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		for currReq := req; currReq != nil; {
+			for _, rule := range rules {
+				if rule.matches(currReq.URL.Host) {
+					return rule.URL, nil
+				}
+			}
+			if currReq.Response == nil {
+				break
+			}
+			currReq = currReq.Response.Request
+		}
+		return http.ProxyFromEnvironment(req)
+	}
+}
+
+func stripDefaultPort(host string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(host, ":443"), ":80")
+}
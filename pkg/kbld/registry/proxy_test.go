@@ -0,0 +1,61 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+func TestRegistryProxies(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+
+	var proxiedHosts []string
+	var proxiedLock sync.Mutex
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedLock.Lock()
+		proxiedHosts = append(proxiedHosts, r.URL.Host)
+		proxiedLock.Unlock()
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(t, err)
+
+	registry, err := ctlreg.NewRegistry(ctlreg.Opts{
+		EnvAuthPrefix: "KBLD_TEST_PROXY",
+		Insecure:      true,
+		Proxies: []ctlreg.ProxyRule{
+			{Host: "direct.example.com", URL: nil},
+			{Host: "*.example.com", URL: proxyURL},
+		},
+	})
+	require.NoError(t, err)
+
+	ref, err := regname.ParseReference("registry.example.com/app:1.0.0", regname.Insecure)
+	require.NoError(t, err)
+
+	_, err = registry.Generic(ref)
+	require.Error(t, err)
+
+	proxiedLock.Lock()
+	defer proxiedLock.Unlock()
+
+	require.NotEmpty(t, proxiedHosts)
+	for _, host := range proxiedHosts {
+		// CONNECT is used for HTTPS requests
+		assert.Regexp(t, `\Aregistry\.example\.com(:443)?\z`, host)
+	}
+}
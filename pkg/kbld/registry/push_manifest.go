@@ -0,0 +1,135 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+)
+
+// PushManifest collects everything pushed to registries (images and tags)
+// so that content that is no longer needed could be garbage collected later.
+// Tags written to images that were not pushed (e.g. retagged existing images)
+// are kept separately since only tags, and not images, belong to kbld.
+type PushManifest struct {
+	lock   sync.Mutex
+	pushed map[string]*PushedImage
+	tagged map[string]*PushedImage
+}
+
+// PushedImage is a manifest pushed to repository with tags pointing to it
+type PushedImage struct {
+	Repository string   `json:"repository"`
+	Digest     string   `json:"digest"`
+	Tags       []string `json:"tags,omitempty"`
+}
+
+func (i PushedImage) URL() string {
+	return i.Repository + "@" + i.Digest
+}
+
+func NewPushManifest() *PushManifest {
+	return &PushManifest{pushed: map[string]*PushedImage{}, tagged: map[string]*PushedImage{}}
+}
+
+// Record adds pushed manifest (dst is either tag or digest reference)
+func (m *PushManifest) Record(dst string, repo regname.Repository, digest string) {
+	m.record(m.pushed, dst, repo, digest)
+}
+
+// RecordTag adds tag written to existing manifest (dst is tag reference)
+func (m *PushManifest) RecordTag(dst string, repo regname.Repository, digest string) {
+	m.record(m.tagged, dst, repo, digest)
+}
+
+func (m *PushManifest) record(imgs map[string]*PushedImage, dst string, repo regname.Repository, digest string) {
+	if len(digest) == 0 {
+		return
+	}
+
+	img := PushedImage{Repository: repo.Name(), Digest: digest}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if pushedImg, found := imgs[img.URL()]; found {
+		img = *pushedImg
+	}
+
+	// Tag defaults to latest when reference does not include one
+	tag, err := regname.NewTag(dst, regname.WeakValidation)
+	if err == nil && strings.HasSuffix(dst, ":"+tag.TagStr()) {
+		if !containsStr(img.Tags, tag.TagStr()) {
+			img.Tags = append(img.Tags, tag.TagStr())
+			sort.Strings(img.Tags)
+		}
+	}
+
+	imgs[img.URL()] = &img
+}
+
+// All returns pushed images sorted by repository and digest
+// (including tags written to them after push)
+func (m *PushManifest) All() []PushedImage {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var result []PushedImage
+	for url, img := range m.pushed {
+		img := *img
+		if taggedImg, found := m.tagged[url]; found {
+			img.Tags = uniqueStrs(append(append([]string{}, img.Tags...), taggedImg.Tags...))
+		}
+		result = append(result, img)
+	}
+
+	sortPushedImages(result)
+
+	return result
+}
+
+// Tagged returns images that were only tagged (not pushed)
+// sorted by repository and digest
+func (m *PushManifest) Tagged() []PushedImage {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var result []PushedImage
+	for url, img := range m.tagged {
+		if _, found := m.pushed[url]; !found {
+			result = append(result, *img)
+		}
+	}
+
+	sortPushedImages(result)
+
+	return result
+}
+
+func sortPushedImages(imgs []PushedImage) {
+	sort.Slice(imgs, func(i, j int) bool { return imgs[i].URL() < imgs[j].URL() })
+}
+
+func uniqueStrs(strs []string) []string {
+	var result []string
+	for _, str := range strs {
+		if !containsStr(result, str) {
+			result = append(result, str)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func containsStr(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry_test
+
+import (
+	"testing"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+func TestPushManifestRecordsExternalPushesWithoutTags(t *testing.T) {
+	pushManifest := ctlreg.NewPushManifest()
+
+	registry, err := ctlreg.NewRegistry(ctlreg.Opts{PushManifest: pushManifest})
+	require.NoError(t, err)
+
+	const digest = "sha256:4c2e9fe4a3ca5ea0ee3ad1a8ef2dc78b2765e1d2dbbd0a7d1c0e9dcd2ae2b8a9"
+
+	require.NoError(t, registry.RecordExternalPush("registry.example.com/app@"+digest, "docker"))
+	require.NoError(t, registry.RecordExternalPush("registry.example.com/app@"+digest, "docker"))
+	require.NoError(t, registry.RecordExternalPush("registry.example.com/other@"+digest, "docker"))
+
+	assert.Equal(t, []ctlreg.PushedImage{
+		{Repository: "registry.example.com/app", Digest: digest},
+		{Repository: "registry.example.com/other", Digest: digest},
+	}, pushManifest.All())
+}
+
+func TestPushManifestRecordsTagsSeparately(t *testing.T) {
+	pushManifest := ctlreg.NewPushManifest()
+
+	repo, err := regname.NewRepository("registry.example.com/app")
+	require.NoError(t, err)
+
+	const pushedDigest = "sha256:4c2e9fe4a3ca5ea0ee3ad1a8ef2dc78b2765e1d2dbbd0a7d1c0e9dcd2ae2b8a9"
+	const existingDigest = "sha256:0000000000000000000000000000000000000000000000000000000000000001"
+
+	pushManifest.Record(repo.Name()+":v1", repo, pushedDigest)
+	pushManifest.RecordTag(repo.Name()+":latest", repo, pushedDigest)
+	pushManifest.RecordTag(repo.Name()+":release", repo, existingDigest)
+
+	// Tags of pushed images are kept together with them
+	assert.Equal(t, []ctlreg.PushedImage{
+		{Repository: repo.Name(), Digest: pushedDigest, Tags: []string{"latest", "v1"}},
+	}, pushManifest.All())
+
+	assert.Equal(t, []ctlreg.PushedImage{
+		{Repository: repo.Name(), Digest: existingDigest, Tags: []string{"release"}},
+	}, pushManifest.Tagged())
+}
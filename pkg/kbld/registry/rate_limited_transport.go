@@ -0,0 +1,41 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"net/http"
+
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+// rateLimitedTransport limits combined upload and download
+// throughput of all requests going through it
+type rateLimitedTransport struct {
+	delegate http.RoundTripper
+	limiter  *util.RateLimiter
+}
+
+var _ http.RoundTripper = rateLimitedTransport{}
+
+func newRateLimitedTransport(delegate http.RoundTripper, bytesPerSec int64) http.RoundTripper {
+	return rateLimitedTransport{delegate, util.NewRateLimiter(bytesPerSec)}
+}
+
+func (t rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(req.Context())
+		req.Body = util.NewRateLimitedReadCloser(req.Body, t.limiter)
+	}
+
+	resp, err := t.delegate.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Body != nil {
+		resp.Body = util.NewRateLimitedReadCloser(resp.Body, t.limiter)
+	}
+
+	return resp, nil
+}
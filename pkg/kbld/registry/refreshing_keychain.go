@@ -0,0 +1,74 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"sync"
+	"time"
+
+	regauthn "github.com/google/go-containerregistry/pkg/authn"
+)
+
+const (
+	defaultCredentialRefreshInterval = 5 * time.Minute
+)
+
+// refreshingKeychain returns authenticators that resolve credentials again
+// (e.g. via credential helpers for cloud registries) once they are older than
+// given interval. Registry tokens are refreshed using these credentials, hence
+// long running uploads and processes (e.g. controller) pick up renewed credentials.
+type refreshingKeychain struct {
+	keychain        regauthn.Keychain
+	refreshInterval time.Duration
+	now             func() time.Time
+}
+
+var _ regauthn.Keychain = refreshingKeychain{}
+
+func newRefreshingKeychain(keychain regauthn.Keychain, refreshInterval time.Duration) regauthn.Keychain {
+	if refreshInterval <= 0 {
+		refreshInterval = defaultCredentialRefreshInterval
+	}
+	return refreshingKeychain{keychain, refreshInterval, time.Now}
+}
+
+func (k refreshingKeychain) Resolve(res regauthn.Resource) (regauthn.Authenticator, error) {
+	auth, err := k.keychain.Resolve(res)
+	if err != nil {
+		return nil, err
+	}
+	// Anonymous access is detected by comparison, hence it's not wrapped
+	if auth == regauthn.Anonymous {
+		return auth, nil
+	}
+	return &refreshingAuthenticator{keychain: k, res: res, auth: auth, resolvedAt: k.now()}, nil
+}
+
+type refreshingAuthenticator struct {
+	keychain refreshingKeychain
+	res      regauthn.Resource
+
+	auth       regauthn.Authenticator
+	resolvedAt time.Time
+	lock       sync.Mutex
+}
+
+var _ regauthn.Authenticator = &refreshingAuthenticator{}
+
+func (a *refreshingAuthenticator) Authorization() (*regauthn.AuthConfig, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	now := a.keychain.now()
+
+	if now.Sub(a.resolvedAt) >= a.keychain.refreshInterval {
+		// Keep using previous credentials if they could not be resolved
+		if auth, err := a.keychain.keychain.Resolve(a.res); err == nil {
+			a.auth = auth
+			a.resolvedAt = now
+		}
+	}
+
+	return a.auth.Authorization()
+}
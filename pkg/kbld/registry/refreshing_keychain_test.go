@@ -0,0 +1,153 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	regauthn "github.com/google/go-containerregistry/pkg/authn"
+	regname "github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+type rotatingKeychain struct {
+	password string
+	lock     sync.Mutex
+}
+
+func (k *rotatingKeychain) Resolve(regauthn.Resource) (regauthn.Authenticator, error) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	return &regauthn.Basic{Username: "user", Password: k.password}, nil
+}
+
+func (k *rotatingKeychain) Rotate(password string) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	k.password = password
+}
+
+func TestRegistryRefreshesCredentialsDuringPush(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+
+	// Index without children is pushed as a single manifest
+	manifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`)
+	manifestDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+
+	keychain := &rotatingKeychain{password: "password-1"}
+
+	var (
+		password     = "password-1"
+		validTokens  = map[string]bool{}
+		rotated      bool
+		fetches      int
+		pushedBodies [][]byte
+		lock         sync.Mutex
+	)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		challenge := func() {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+
+		if r.URL.Path == "/token" {
+			_, reqPassword, _ := r.BasicAuth()
+			if reqPassword != password {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			token := fmt.Sprintf("token-%d", len(validTokens))
+			validTokens[token] = true
+			fmt.Fprintf(w, `{"token":"%s"}`, token)
+			return
+		}
+
+		if !validTokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")] {
+			challenge()
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+
+		case (r.Method == http.MethodGet || r.Method == http.MethodHead) && r.URL.Path == "/v2/app/manifests/"+manifestDigest:
+			w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+			w.Header().Set("Docker-Content-Digest", manifestDigest)
+			w.Header().Set("Content-Length", fmt.Sprintf("%d", len(manifest)))
+			if r.Method == http.MethodGet {
+				fetches++
+				w.Write(manifest)
+			}
+
+		case r.Method == http.MethodPut && r.URL.Path == "/v2/app/manifests/latest":
+			body, _ := io.ReadAll(r.Body)
+			pushedBodies = append(pushedBodies, body)
+
+			if !rotated {
+				// Simulate expiration of token and rotation of credentials mid-push
+				rotated = true
+				password = "password-2"
+				validTokens = map[string]bool{}
+				keychain.Rotate(password)
+				challenge()
+				return
+			}
+
+			w.Header().Set("Docker-Content-Digest", manifestDigest)
+			w.WriteHeader(http.StatusCreated)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	registry, err := ctlreg.NewRegistry(ctlreg.Opts{
+		EnvAuthPrefix:             "KBLD_TEST_REFRESH",
+		Insecure:                  true,
+		CredentialRefreshInterval: time.Nanosecond,
+	})
+	require.NoError(t, err)
+
+	registry = registry.WithKeychain(keychain)
+
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	dstRef, err := regname.NewTag(host+"/app:latest", regname.Insecure)
+	require.NoError(t, err)
+
+	srcRef, err := regname.NewDigest(host+"/app@"+manifestDigest, regname.Insecure)
+	require.NoError(t, err)
+
+	err = registry.WriteTag(dstRef, srcRef)
+	require.NoError(t, err)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Push was not retried as a whole
+	assert.Equal(t, 1, fetches)
+
+	require.Len(t, pushedBodies, 2)
+	assert.Equal(t, string(manifest), string(pushedBodies[0]))
+	assert.Equal(t, string(manifest), string(pushedBodies[1]))
+}
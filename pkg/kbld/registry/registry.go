@@ -4,8 +4,10 @@
 package registry
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -16,6 +18,9 @@ import (
 	regname "github.com/google/go-containerregistry/pkg/name"
 	regv1 "github.com/google/go-containerregistry/pkg/v1"
 	regremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	regtransport "github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/metrics"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/tracing"
 )
 
 type Opts struct {
@@ -23,15 +28,46 @@ type Opts struct {
 	VerifyCerts   bool
 	Insecure      bool
 	EnvAuthPrefix string
+	// MaxBandwidth limits combined transfer rate in bytes per second (0 means unlimited)
+	MaxBandwidth int64
+	// IncludeNonDistributable pushes non-distributable (foreign) layers
+	IncludeNonDistributable bool
+	// TransferStats when set collects number of transferred bytes
+	TransferStats *TransferStats
+	// Tracer when set records span for each registry request
+	Tracer *tracing.Tracer
+	// Metrics when set records failed requests and pushed bytes
+	Metrics *metrics.Metrics
+	// AuditLog when set records pushes and tag writes
+	AuditLog *AuditLog
+	// PushManifest when set collects pushed images and tags
+	PushManifest *PushManifest
+	// Proxies override proxy environment variables for matching registries
+	Proxies []ProxyRule
+	// CredentialRefreshInterval is how long resolved credentials are used
+	// before being resolved again (5 minutes when not set)
+	CredentialRefreshInterval time.Duration
+	// HeadOnly resolves digests via HEAD requests without fetching manifests
+	HeadOnly bool
 }
 
 type Registry struct {
-	opts    []regremote.Option
-	refOpts []regname.Option
+	opts         []regremote.Option
+	refOpts      []regname.Option
+	keychain     regauthn.Keychain
+	transport    http.RoundTripper
+	auditLog     *AuditLog
+	pushManifest *PushManifest
+
+	// rawKeychain is keychain before it's wrapped to refresh credentials
+	rawKeychain               regauthn.Keychain
+	credentialRefreshInterval time.Duration
+	headOnly                  bool
 }
 
 func NewRegistry(opts Opts) (Registry, error) {
-	keychain := regauthn.NewMultiKeychain(NewEnvKeychain(opts.EnvAuthPrefix), regauthn.DefaultKeychain)
+	rawKeychain := regauthn.NewMultiKeychain(NewEnvKeychain(opts.EnvAuthPrefix), regauthn.DefaultKeychain)
+	keychain := newRefreshingKeychain(rawKeychain, opts.CredentialRefreshInterval)
 	transport, err := newHTTPTransport(opts)
 	if err != nil {
 		return Registry{}, err
@@ -42,15 +78,67 @@ func NewRegistry(opts Opts) (Registry, error) {
 		refOpts = append(refOpts, regname.Insecure)
 	}
 
+	var roundTripper http.RoundTripper = newDigestVerifyingTransport(transport)
+	if opts.MaxBandwidth > 0 {
+		roundTripper = newRateLimitedTransport(roundTripper, opts.MaxBandwidth)
+	}
+	if opts.TransferStats != nil {
+		roundTripper = newCountingTransport(roundTripper, opts.TransferStats)
+	}
+	if opts.Metrics != nil {
+		roundTripper = newMetricsTransport(roundTripper, opts.Metrics)
+	}
+	if opts.Tracer != nil {
+		roundTripper = newTracingTransport(roundTripper, opts.Tracer)
+	}
+
+	remoteOpts := []regremote.Option{
+		regremote.WithTransport(roundTripper),
+		regremote.WithAuthFromKeychain(keychain),
+	}
+	if opts.IncludeNonDistributable {
+		remoteOpts = append(remoteOpts, regremote.WithNondistributable)
+	}
+
 	return Registry{
-		opts: []regremote.Option{
-			regremote.WithTransport(transport),
-			regremote.WithAuthFromKeychain(keychain),
-		},
-		refOpts: refOpts,
+		opts:         remoteOpts,
+		refOpts:      refOpts,
+		keychain:     keychain,
+		transport:    roundTripper,
+		auditLog:     opts.AuditLog,
+		pushManifest: opts.PushManifest,
+
+		rawKeychain:               rawKeychain,
+		credentialRefreshInterval: opts.CredentialRefreshInterval,
+		headOnly:                  opts.HeadOnly,
 	}, nil
 }
 
+// WithKeychain returns registry that prefers credentials from given keychain
+// and falls back to originally configured credentials
+func (i Registry) WithKeychain(keychain regauthn.Keychain) Registry {
+	rawKeychain := regauthn.NewMultiKeychain(keychain, i.rawKeychain)
+	keychain = newRefreshingKeychain(rawKeychain, i.credentialRefreshInterval)
+
+	opts := append([]regremote.Option{}, i.opts...)
+	// Later auth option takes precedence over earlier one
+	opts = append(opts, regremote.WithAuthFromKeychain(keychain))
+
+	result := i
+	result.opts = opts
+	result.keychain = keychain
+	result.rawKeychain = rawKeychain
+	return result
+}
+
+// WithContext returns registry that makes requests with given context
+// (e.g. to associate them with tracing span of an image)
+func (i Registry) WithContext(ctx context.Context) Registry {
+	result := i
+	result.opts = append(append([]regremote.Option{}, i.opts...), regremote.WithContext(ctx))
+	return result
+}
+
 func (i Registry) Generic(ref regname.Reference) (regv1.Descriptor, error) {
 	ref, err := regname.ParseReference(ref.String(), i.refOpts...)
 	if err != nil {
@@ -65,6 +153,31 @@ func (i Registry) Generic(ref regname.Reference) (regv1.Descriptor, error) {
 	return desc.Descriptor, nil
 }
 
+// Digest returns descriptor of manifest referenced by given ref. In HEAD-only mode
+// only HEAD request is made (it's not counted against Docker Hub pull rate limits),
+// hence descriptor may not include platform and other manifest details.
+// Manifest is fetched when registry does not respond to HEAD requests with digest.
+func (i Registry) Digest(ref regname.Reference) (regv1.Descriptor, error) {
+	if !i.headOnly {
+		return i.Generic(ref)
+	}
+
+	ref, err := regname.ParseReference(ref.String(), i.refOpts...)
+	if err != nil {
+		return regv1.Descriptor{}, err
+	}
+
+	desc, err := regremote.Head(ref, i.opts...)
+	if err != nil {
+		if IsNotFoundErr(err) {
+			return regv1.Descriptor{}, err
+		}
+		return i.Generic(ref)
+	}
+
+	return *desc, nil
+}
+
 func (i Registry) Image(ref regname.Reference) (regv1.Image, error) {
 	ref, err := regname.ParseReference(ref.String(), i.refOpts...)
 	if err != nil {
@@ -84,10 +197,15 @@ func (i Registry) WriteImage(ref regname.Reference, img regv1.Image) error {
 		return regremote.Write(ref, img, i.opts...)
 	})
 	if err != nil {
-		return fmt.Errorf("Writing image: %s", err)
+		err = fmt.Errorf("Writing image: %s", err)
 	}
 
-	return nil
+	var digestStr string
+	if digest, digestErr := img.Digest(); digestErr == nil {
+		digestStr = digest.String()
+	}
+
+	return i.audit(AuditOperationPush, ref.Name(), ref.Context(), digestStr, "", err)
 }
 
 func (i Registry) Index(ref regname.Reference) (regv1.ImageIndex, error) {
@@ -109,10 +227,15 @@ func (i Registry) WriteIndex(ref regname.Reference, idx regv1.ImageIndex) error
 		return regremote.WriteIndex(ref, idx, i.opts...)
 	})
 	if err != nil {
-		return fmt.Errorf("Writing image index: %s", err)
+		err = fmt.Errorf("Writing image index: %s", err)
+	}
+
+	var digestStr string
+	if digest, digestErr := idx.Digest(); digestErr == nil {
+		digestStr = digest.String()
 	}
 
-	return nil
+	return i.audit(AuditOperationPush, ref.Name(), ref.Context(), digestStr, "", err)
 }
 
 func (i Registry) WriteTag(dstRef regname.Tag, srcRef regname.Digest) error {
@@ -135,10 +258,120 @@ func (i Registry) WriteTag(dstRef regname.Tag, srcRef regname.Digest) error {
 		return regremote.Tag(dstRef, desc, i.opts...)
 	})
 	if err != nil {
-		return fmt.Errorf("Writing image tag: %s", err)
+		err = fmt.Errorf("Writing image tag: %s", err)
+	}
+
+	return i.audit(AuditOperationTag, dstRef.Name(), dstRef.Context(), srcRef.DigestStr(), "", err)
+}
+
+// DeleteTag deletes tag without deleting image it points to
+// (registry needs to support tag deletion via manifests endpoint)
+func (i Registry) DeleteTag(ref regname.Tag) error {
+	ref, err := regname.NewTag(ref.String(), i.refOpts...)
+	if err != nil {
+		return err
+	}
+
+	err = regremote.Delete(ref, i.opts...)
+	if err != nil {
+		err = fmt.Errorf("Deleting image tag: %s", err)
+	}
+
+	return i.audit(AuditOperationDelete, ref.Name(), ref.Context(), "", "", err)
+}
+
+// DeleteImage deletes manifest (most registries also delete tags pointing to it).
+// Manifest that does not exist is considered to be already deleted.
+func (i Registry) DeleteImage(ref regname.Digest) error {
+	ref, err := regname.NewDigest(ref.String(), i.refOpts...)
+	if err != nil {
+		return err
+	}
+
+	err = regremote.Delete(ref, i.opts...)
+	if err != nil {
+		if IsNotFoundErr(err) {
+			return nil
+		}
+		err = fmt.Errorf("Deleting image: %s", err)
+	}
+
+	return i.audit(AuditOperationDelete, ref.Name(), ref.Context(), ref.DigestStr(), "", err)
+}
+
+// RecordExternalPush records push of image (given as digest reference)
+// performed by an external tool (e.g. docker push) in the audit log
+func (i Registry) RecordExternalPush(url, tool string) error {
+	if i.auditLog == nil && i.pushManifest == nil {
+		return nil
+	}
+
+	ref, err := regname.NewDigest(url, i.refOpts...)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return i.audit(AuditOperationPush, ref.Context().Name(), ref.Context(), ref.DigestStr(), tool, nil)
+}
+
+// audit records operation (successful or not) and returns operation error.
+// Operation is considered failed if it could not be recorded.
+func (i Registry) audit(op AuditOperation, dst string, repo regname.Repository,
+	digest, tool string, opErr error) error {
+
+	if i.pushManifest != nil && opErr == nil {
+		switch op {
+		case AuditOperationPush:
+			i.pushManifest.Record(dst, repo, digest)
+		case AuditOperationTag:
+			i.pushManifest.RecordTag(dst, repo, digest)
+		}
+	}
+
+	if i.auditLog == nil {
+		return opErr
+	}
+
+	entry := AuditEntry{
+		Operation:   op,
+		Destination: dst,
+		Digest:      digest,
+		Identity:    auditIdentity(i.keychain, repo),
+		Tool:        tool,
+	}
+	if opErr != nil {
+		entry.Error = opErr.Error()
+	}
+
+	err := i.auditLog.Record(entry)
+	if err != nil {
+		if opErr != nil {
+			return fmt.Errorf("%s (additionally failed recording audit log: %s)", opErr, err)
+		}
+		return err
+	}
+
+	return opErr
+}
+
+// Referrers returns descriptors of manifests referring to given digest
+func (i Registry) Referrers(ref regname.Digest) ([]regv1.Descriptor, error) {
+	ref, err := regname.NewDigest(ref.String(), i.refOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := regremote.Referrers(ref, i.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	idxManifest, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	return idxManifest.Manifests, nil
 }
 
 func (i Registry) ListTags(repo regname.Repository) ([]string, error) {
@@ -150,6 +383,27 @@ func (i Registry) ListTags(repo regname.Repository) ([]string, error) {
 	return regremote.List(repo, i.opts...)
 }
 
+// CheckPushAccess checks that configured credentials allow pushing
+// to given repository (blob upload is started, but nothing is uploaded)
+func (i Registry) CheckPushAccess(repo regname.Repository) error {
+	repo, err := regname.NewRepository(repo.Name(), i.refOpts...)
+	if err != nil {
+		return err
+	}
+
+	return regremote.CheckPushPermission(repo.Tag("latest"), i.keychain, i.transport)
+}
+
+// HasCredentials returns true when credentials (other than anonymous)
+// are configured for registry of given repository
+func (i Registry) HasCredentials(repo regname.Repository) (bool, error) {
+	auth, err := i.keychain.Resolve(repo)
+	if err != nil {
+		return false, err
+	}
+	return auth != regauthn.Anonymous, nil
+}
+
 func newHTTPTransport(opts Opts) (*http.Transport, error) {
 	pool, err := x509.SystemCertPool()
 	if err != nil {
@@ -169,8 +423,13 @@ func newHTTPTransport(opts Opts) (*http.Transport, error) {
 	// Copied from https://github.com/golang/go/blob/release-branch.go1.12/src/net/http/transport.go#L42-L53
 	// We want to use the DefaultTransport but change its TLSClientConfig. There
 	// isn't a clean way to do this yet: https://github.com/golang/go/issues/26013
+	proxyFunc := http.ProxyFromEnvironment
+	if len(opts.Proxies) > 0 {
+		proxyFunc = newProxyFunc(opts.Proxies)
+	}
+
 	return &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
+		Proxy: proxyFunc,
 		DialContext: (&net.Dialer{
 			Timeout:   30 * time.Second,
 			KeepAlive: 30 * time.Second,
@@ -200,3 +459,20 @@ func (i Registry) retry(doFunc func() error) error {
 	}
 	return fmt.Errorf("Retried 5 times: %s", lastErr)
 }
+
+// IsNotFoundErr returns true when error indicates that manifest does not exist
+func IsNotFoundErr(err error) bool {
+	var transportErr *regtransport.Error
+	if !errors.As(err, &transportErr) {
+		return false
+	}
+	if transportErr.StatusCode == http.StatusNotFound {
+		return true
+	}
+	for _, diagErr := range transportErr.Errors {
+		if diagErr.Code == regtransport.ManifestUnknownErrorCode {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+func TestRegistryDigestHeadOnly(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+
+	manifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`)
+	manifestDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+
+	type serverOpts struct {
+		omitDigestOnHead bool
+	}
+
+	newServer := func(opts serverOpts, requests *[]string, lock *sync.Mutex) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			*requests = append(*requests, r.Method+" "+r.URL.Path)
+			lock.Unlock()
+
+			switch r.URL.Path {
+			case "/v2/":
+				w.WriteHeader(http.StatusOK)
+			case "/v2/app/manifests/1.0.0":
+				w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+				w.Header().Set("Content-Length", fmt.Sprintf("%d", len(manifest)))
+				if r.Method == http.MethodGet || !opts.omitDigestOnHead {
+					w.Header().Set("Docker-Content-Digest", manifestDigest)
+				}
+				if r.Method == http.MethodGet {
+					w.Write(manifest)
+				}
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	}
+
+	manifestRequests := func(requests []string) []string {
+		var result []string
+		for _, req := range requests {
+			if strings.Contains(req, "/manifests/") {
+				result = append(result, req)
+			}
+		}
+		return result
+	}
+
+	for _, tc := range []struct {
+		desc     string
+		headOnly bool
+		server   serverOpts
+		expected []string
+	}{
+		{"uses GET by default", false, serverOpts{}, []string{"GET /v2/app/manifests/1.0.0"}},
+		{"uses only HEAD", true, serverOpts{}, []string{"HEAD /v2/app/manifests/1.0.0"}},
+		{"falls back to GET when digest is missing", true, serverOpts{omitDigestOnHead: true},
+			[]string{"HEAD /v2/app/manifests/1.0.0", "GET /v2/app/manifests/1.0.0"}},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			var requests []string
+			var lock sync.Mutex
+
+			server := newServer(tc.server, &requests, &lock)
+			defer server.Close()
+
+			registry, err := ctlreg.NewRegistry(ctlreg.Opts{
+				EnvAuthPrefix: "KBLD_TEST_HEAD_ONLY",
+				Insecure:      true,
+				HeadOnly:      tc.headOnly,
+			})
+			require.NoError(t, err)
+
+			ref, err := regname.ParseReference(strings.TrimPrefix(server.URL, "http://")+"/app:1.0.0", regname.Insecure)
+			require.NoError(t, err)
+
+			desc, err := registry.Digest(ref)
+			require.NoError(t, err)
+			assert.Equal(t, manifestDigest, desc.Digest.String())
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			assert.Equal(t, tc.expected, manifestRequests(requests))
+		})
+	}
+}
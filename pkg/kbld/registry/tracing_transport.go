@@ -0,0 +1,48 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/tracing"
+)
+
+// tracingTransport records span for each registry request
+// (parented under span recorded in request context, if any)
+type tracingTransport struct {
+	delegate http.RoundTripper
+	tracer   *tracing.Tracer
+}
+
+var _ http.RoundTripper = tracingTransport{}
+
+func newTracingTransport(delegate http.RoundTripper, tracer *tracing.Tracer) http.RoundTripper {
+	return tracingTransport{delegate, tracer}
+}
+
+func (t tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	span := t.tracer.StartFromContext(req.Context(), "registry "+req.Method, tracing.SpanKindClient, map[string]interface{}{
+		"http.request.method": req.Method,
+		"server.address":      req.URL.Host,
+		"url.path":            req.URL.Path,
+	})
+
+	resp, err := t.delegate.RoundTrip(req)
+	if err != nil {
+		span.End(err)
+		return nil, err
+	}
+
+	span.SetAttr("http.response.status_code", resp.StatusCode)
+
+	if resp.StatusCode >= 400 {
+		span.End(fmt.Errorf("Registry responded with status '%s'", resp.Status))
+	} else {
+		span.End(nil)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/tracing"
+)
+
+func TestRegistryTracesRequestsUnderContextSpan(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+
+	type span struct {
+		SpanID       string `json:"spanId"`
+		ParentSpanID string `json:"parentSpanId"`
+		Name         string `json:"name"`
+	}
+
+	var spans []span
+	var spansLock sync.Mutex
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []span `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		spansLock.Lock()
+		spans = append(spans, req.ResourceSpans[0].ScopeSpans[0].Spans...)
+		spansLock.Unlock()
+	}))
+	defer collector.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	tracer, err := tracing.NewTracer(collector.URL, nil, "kbld", "kbld resolve", "")
+	require.NoError(t, err)
+
+	registry, err := ctlreg.NewRegistry(ctlreg.Opts{EnvAuthPrefix: "KBLD_TEST_TRACING", Insecure: true, Tracer: tracer})
+	require.NoError(t, err)
+
+	imgSpan := tracer.Start(nil, "image resolving", tracing.SpanKindInternal, nil)
+
+	ref, err := regname.ParseReference(strings.TrimPrefix(server.URL, "http://")+"/app:1.0.0", regname.Insecure)
+	require.NoError(t, err)
+
+	_, err = registry.WithContext(tracing.ContextWithSpan(context.Background(), imgSpan)).Generic(ref)
+	require.Error(t, err)
+
+	imgSpan.End(nil)
+	require.NoError(t, tracer.Shutdown(nil))
+
+	var imgSpanID string
+	for _, span := range spans {
+		if span.Name == "image resolving" {
+			imgSpanID = span.SpanID
+		}
+	}
+	require.NotEmpty(t, imgSpanID)
+
+	var registrySpans int
+	for _, span := range spans {
+		if strings.HasPrefix(span.Name, "registry ") {
+			assert.Equal(t, imgSpanID, span.ParentSpanID)
+			registrySpans++
+		}
+	}
+	assert.NotZero(t, registrySpans)
+}
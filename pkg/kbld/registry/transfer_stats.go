@@ -0,0 +1,71 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// TransferStats counts bytes sent to and received from registries
+type TransferStats struct {
+	uploaded   int64
+	downloaded int64
+	requests   int64
+}
+
+func NewTransferStats() *TransferStats {
+	return &TransferStats{}
+}
+
+func (s *TransferStats) Uploaded() int64   { return atomic.LoadInt64(&s.uploaded) }
+func (s *TransferStats) Downloaded() int64 { return atomic.LoadInt64(&s.downloaded) }
+func (s *TransferStats) Requests() int64   { return atomic.LoadInt64(&s.requests) }
+
+type countingTransport struct {
+	delegate http.RoundTripper
+	stats    *TransferStats
+}
+
+var _ http.RoundTripper = countingTransport{}
+
+func newCountingTransport(delegate http.RoundTripper, stats *TransferStats) http.RoundTripper {
+	return countingTransport{delegate, stats}
+}
+
+func (t countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&t.stats.requests, 1)
+
+	if req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(req.Context())
+		req.Body = countingReadCloser{req.Body, &t.stats.uploaded}
+	}
+
+	resp, err := t.delegate.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Body != nil {
+		resp.Body = countingReadCloser{resp.Body, &t.stats.downloaded}
+	}
+
+	return resp, nil
+}
+
+type countingReadCloser struct {
+	rc    io.ReadCloser
+	count *int64
+}
+
+func (r countingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.rc.Read(p)
+	if n > 0 {
+		atomic.AddInt64(r.count, int64(n))
+	}
+	return n, err
+}
+
+func (r countingReadCloser) Close() error { return r.rc.Close() }
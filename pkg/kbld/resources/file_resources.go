@@ -5,7 +5,10 @@ package resources
 
 import (
 	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -17,6 +20,12 @@ var (
 
 type FileResource struct {
 	fileSrc FileSource
+	// relPath is file path relative to specified directory (or file name)
+	relPath string
+}
+
+func NewFileResource(fileSrc FileSource, relPath string) FileResource {
+	return FileResource{fileSrc, relPath}
 }
 
 func NewFileResources(file string) ([]FileResource, error) {
@@ -24,10 +33,14 @@ func NewFileResources(file string) ([]FileResource, error) {
 
 	switch {
 	case file == "-":
-		fileRs = append(fileRs, FileResource{NewStdinSource()})
+		fileRs = append(fileRs, FileResource{NewStdinSource(), "stdin.yml"})
 
 	case strings.HasPrefix(file, "http://") || strings.HasPrefix(file, "https://"):
-		fileRs = append(fileRs, FileResource{NewHTTPFileSource(file)})
+		relPath := "http.yml"
+		if parsedURL, err := url.Parse(file); err == nil && path.Base(parsedURL.Path) != "/" && path.Base(parsedURL.Path) != "." {
+			relPath = path.Base(parsedURL.Path)
+		}
+		fileRs = append(fileRs, FileResource{NewHTTPFileSource(file), relPath})
 
 	default:
 		fileInfo, err := os.Stat(file)
@@ -57,10 +70,14 @@ func NewFileResources(file string) ([]FileResource, error) {
 			sort.Strings(paths)
 
 			for _, path := range paths {
-				fileRs = append(fileRs, FileResource{NewLocalFileSource(path)})
+				relPath, err := filepath.Rel(file, path)
+				if err != nil {
+					return nil, err
+				}
+				fileRs = append(fileRs, FileResource{NewLocalFileSource(path), relPath})
 			}
 		} else {
-			fileRs = append(fileRs, FileResource{NewLocalFileSource(file)})
+			fileRs = append(fileRs, FileResource{NewLocalFileSource(file), filepath.Base(file)})
 		}
 	}
 
@@ -69,6 +86,20 @@ func NewFileResources(file string) ([]FileResource, error) {
 
 func (r FileResource) Description() string { return r.fileSrc.Description() }
 
+// RelativePath returns path of the file relative to directory
+// it was found in (or just file name when file was specified directly)
+func (r FileResource) RelativePath() string { return r.relPath }
+
+// LocalPath returns path of the file on local file system (if it is a local file)
+func (r FileResource) LocalPath() (string, bool) {
+	if src, ok := r.fileSrc.(LocalFileSource); ok {
+		return src.path, true
+	}
+	return "", false
+}
+
+func (r FileResource) Reader() (io.ReadCloser, error) { return r.fileSrc.Reader() }
+
 func (r FileResource) Resources() ([]Resource, error) {
 	docs, err := NewYAMLFile(r.fileSrc).Docs()
 	if err != nil {
@@ -88,3 +119,33 @@ func (r FileResource) Resources() ([]Resource, error) {
 
 	return resources, nil
 }
+
+// VisitResources parses and visits resources one document at a time
+func (r FileResource) VisitResources(visitFunc func(Resource) error) error {
+	var docNum int
+	// Errors returned by visit func or for a particular doc are returned as is
+	var docErr error
+
+	err := NewYAMLFile(r.fileSrc).VisitDocs(func(doc []byte) error {
+		docNum++
+
+		rs, err := NewResourcesFromBytes(doc)
+		if err != nil {
+			docErr = fmt.Errorf("Parsing %s doc %d: %s", r.Description(), docNum, err)
+			return docErr
+		}
+
+		for _, res := range rs {
+			docErr = visitFunc(res)
+			if docErr != nil {
+				return docErr
+			}
+		}
+		return nil
+	})
+	if err != nil && docErr == nil {
+		return fmt.Errorf("Parsing %s: %s", r.Description(), err)
+	}
+
+	return err
+}
@@ -4,6 +4,7 @@
 package resources
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,6 +14,8 @@ import (
 type FileSource interface {
 	Description() string
 	Bytes() ([]byte, error)
+	// Reader allows to consume contents without loading them all in memory
+	Reader() (io.ReadCloser, error)
 }
 
 type StdinSource struct{}
@@ -27,6 +30,10 @@ func (s StdinSource) Bytes() ([]byte, error) {
 	return io.ReadAll(os.Stdin)
 }
 
+func (s StdinSource) Reader() (io.ReadCloser, error) {
+	return io.NopCloser(os.Stdin), nil
+}
+
 type LocalFileSource struct {
 	path string
 }
@@ -43,6 +50,10 @@ func (s LocalFileSource) Bytes() ([]byte, error) {
 	return os.ReadFile(s.path)
 }
 
+func (s LocalFileSource) Reader() (io.ReadCloser, error) {
+	return os.Open(s.path)
+}
+
 type HTTPFileSource struct {
 	url string
 }
@@ -71,6 +82,14 @@ func (s HTTPFileSource) Bytes() ([]byte, error) {
 	return result, nil
 }
 
+func (s HTTPFileSource) Reader() (io.ReadCloser, error) {
+	resp, err := http.Get(s.url)
+	if err != nil {
+		return nil, fmt.Errorf("Requesting URL '%s': %s", s.url, err)
+	}
+	return resp.Body, nil
+}
+
 type BytesSource struct {
 	bs []byte
 }
@@ -81,3 +100,7 @@ func NewBytesSource(bs []byte) BytesSource { return BytesSource{bs} }
 
 func (s BytesSource) Description() string    { return "bytes" }
 func (s BytesSource) Bytes() ([]byte, error) { return s.bs, nil }
+
+func (s BytesSource) Reader() (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(s.bs)), nil
+}
@@ -4,6 +4,7 @@
 package resources
 
 import (
+	"bytes"
 	"fmt"
 	"reflect"
 	"strings"
@@ -19,6 +20,7 @@ type Resource interface {
 	APIVersion() string
 	APIGroup() string
 
+	Namespace() string
 	Name() string
 	Description() string
 
@@ -29,6 +31,7 @@ type Resource interface {
 	DeepCopy() Resource
 	DeepCopyRaw() map[string]interface{}
 	AsYAMLBytes() ([]byte, error)
+	OriginalBytes() ([]byte, bool)
 
 	unstructured() unstructured.Unstructured     // private
 	unstructuredPtr() *unstructured.Unstructured // private
@@ -38,6 +41,9 @@ type ResourceImpl struct {
 	un        unstructured.Unstructured
 	gvr       schema.GroupVersionResource
 	transient bool
+	// docBytes is document resource was parsed from
+	// (not set for items of lists)
+	docBytes []byte
 }
 
 var _ Resource = &ResourceImpl{}
@@ -58,7 +64,7 @@ func NewResourceFromBytes(data []byte) (*ResourceImpl, error) {
 		return nil, nil
 	}
 
-	return &ResourceImpl{un: unstructured.Unstructured{content}}, nil
+	return &ResourceImpl{un: unstructured.Unstructured{content}, docBytes: withoutDocSeparator(data)}, nil
 }
 
 func MustNewResourceFromBytes(data []byte) *ResourceImpl {
@@ -97,12 +103,43 @@ func NewResourcesFromBytes(data []byte) ([]Resource, error) {
 			rs = append(rs, &ResourceImpl{un: itemUn})
 		}
 	} else {
-		rs = append(rs, &ResourceImpl{un: un})
+		rs = append(rs, &ResourceImpl{un: un, docBytes: withoutDocSeparator(data)})
 	}
 
 	return rs, nil
 }
 
+// withoutDocSeparator removes separator line that starts first document of a file
+// (YAML reader keeps it) since separators are added when printing resources.
+// Separator may be followed by a comment or a tag (e.g. '--- # app', '--- !!map');
+// documents starting on separator line are not kept as given (nil is returned).
+func withoutDocSeparator(data []byte) []byte {
+	if !bytes.HasPrefix(data, []byte("---")) {
+		return data
+	}
+
+	line, rest, found := bytes.Cut(data, []byte("\n"))
+
+	suffix := line[len("---"):]
+	if len(suffix) > 0 && suffix[0] != ' ' && suffix[0] != '\t' && suffix[0] != '\r' {
+		return data // e.g. '----' is not a separator
+	}
+
+	if !found {
+		return nil
+	}
+
+	fields := strings.Fields(string(suffix))
+	switch {
+	case len(fields) == 0 || strings.HasPrefix(fields[0], "#"):
+		return rest
+	case strings.HasPrefix(fields[0], "!") && (len(fields) == 1 || strings.HasPrefix(fields[1], "#")):
+		return rest
+	default:
+		return nil
+	}
+}
+
 func (r *ResourceImpl) GroupVersionResource() schema.GroupVersionResource { return r.gvr }
 
 func (r *ResourceImpl) Kind() string       { return r.un.GetKind() }
@@ -157,7 +194,7 @@ func (r *ResourceImpl) Equal(res Resource) bool {
 }
 
 func (r *ResourceImpl) DeepCopy() Resource {
-	return &ResourceImpl{*r.un.DeepCopy(), r.gvr, r.transient}
+	return &ResourceImpl{*r.un.DeepCopy(), r.gvr, r.transient, r.docBytes}
 }
 
 func (r *ResourceImpl) DeepCopyRaw() map[string]interface{} {
@@ -168,5 +205,15 @@ func (r *ResourceImpl) AsYAMLBytes() ([]byte, error) {
 	return yaml.Marshal(r.un.Object)
 }
 
+// OriginalBytes returns document resource was parsed from as is
+// (e.g. with comments, anchors and numbers that do not survive
+// re-serialization) so that unmodified resources could be output exactly
+func (r *ResourceImpl) OriginalBytes() ([]byte, bool) {
+	if r.docBytes == nil {
+		return nil, false
+	}
+	return r.docBytes, true
+}
+
 func (r *ResourceImpl) unstructured() unstructured.Unstructured     { return r.un }
 func (r *ResourceImpl) unstructuredPtr() *unstructured.Unstructured { return &r.un }
@@ -0,0 +1,54 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package resources_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+func TestResourceOriginalBytesWithoutDocSeparator(t *testing.T) {
+	const doc = "kind: ConfigMap\napiVersion: v1\nmetadata: {name: app} # keep\n"
+
+	for _, sep := range []string{"", "---\n", "---\r\n", "--- \n", "--- # app config\n", "---\t# app\n", "--- !!map\n", "--- !!map # app\n"} {
+		res, err := ctlres.NewResourceFromBytes([]byte(sep + doc))
+		require.NoError(t, err, sep)
+
+		bs, found := res.OriginalBytes()
+		require.True(t, found, sep)
+		assert.Equal(t, doc, string(bs), sep)
+	}
+
+	t.Run("does not keep documents starting on separator line", func(t *testing.T) {
+		res, err := ctlres.NewResourceFromBytes([]byte("--- {kind: ConfigMap, apiVersion: v1,\n  metadata: {name: app}}\n"))
+		require.NoError(t, err)
+
+		_, found := res.OriginalBytes()
+		assert.False(t, found)
+
+		res, err = ctlres.NewResourceFromBytes([]byte("--- !!map\n  {kind: ConfigMap, apiVersion: v1, metadata: {name: app}}"))
+		require.NoError(t, err)
+
+		bs, found := res.OriginalBytes()
+		require.True(t, found)
+		assert.Equal(t, "  {kind: ConfigMap, apiVersion: v1, metadata: {name: app}}", string(bs))
+	})
+
+	t.Run("keeps files read with separators followed by comments", func(t *testing.T) {
+		fileRes := ctlres.NewFileResource(ctlres.NewBytesSource([]byte("--- # first\n"+doc+"--- # second\n"+doc)), "")
+
+		rs, err := fileRes.Resources()
+		require.NoError(t, err)
+		require.Len(t, rs, 2)
+
+		for _, res := range rs {
+			bs, found := res.(*ctlres.ResourceImpl).OriginalBytes()
+			require.True(t, found)
+			assert.Equal(t, doc, string(bs))
+		}
+	})
+}
@@ -0,0 +1,272 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package resources
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+
+	yamlv3 "gopkg.in/yaml.v3"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	yamlMergeTag = "!!merge"
+)
+
+// UpdateYAMLDocWithAliases returns document updated to match new contents
+// while keeping its anchors, aliases and merge keys. It returns false when
+// document does not use them or when new contents could not be expressed
+// via original structure (e.g. aliased value is updated differently in
+// different places), in which case contents should be serialized as is.
+func UpdateYAMLDocWithAliases(docBs []byte, oldContents, newContents map[string]interface{}) ([]byte, bool, error) {
+	var doc yamlv3.Node
+
+	err := yamlv3.Unmarshal(docBs, &doc)
+	if err != nil || doc.Kind != yamlv3.DocumentNode || len(doc.Content) != 1 {
+		return nil, false, nil
+	}
+
+	if !yamlNodeUsesAliases(&doc) {
+		return nil, false, nil
+	}
+
+	err = yamlAliasPatch{}.patch(doc.Content[0], oldContents, newContents)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	yamlWithImplicitMergeTags(&doc)
+
+	var buf bytes.Buffer
+
+	enc := yamlv3.NewEncoder(&buf)
+	enc.SetIndent(2)
+
+	err = enc.Encode(&doc)
+	if err != nil {
+		return nil, false, fmt.Errorf("Encoding document: %s", err)
+	}
+
+	err = enc.Close()
+	if err != nil {
+		return nil, false, fmt.Errorf("Encoding document: %s", err)
+	}
+
+	// Updated anchor may be referenced from places that were expected
+	// to keep previous value, hence verify that result matches exactly
+	var result map[string]interface{}
+
+	err = yaml.Unmarshal(buf.Bytes(), &result)
+	if err != nil || !reflect.DeepEqual(result, newContents) {
+		return nil, false, nil
+	}
+
+	return buf.Bytes(), true, nil
+}
+
+func yamlNodeUsesAliases(node *yamlv3.Node) bool {
+	if node.Kind == yamlv3.AliasNode || len(node.Anchor) > 0 {
+		return true
+	}
+	for _, childNode := range node.Content {
+		if yamlNodeUsesAliases(childNode) {
+			return true
+		}
+	}
+	return false
+}
+
+// yamlWithImplicitMergeTags avoids encoding merge keys as '!!merge <<'
+func yamlWithImplicitMergeTags(node *yamlv3.Node) {
+	if node.Kind == yamlv3.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Tag == yamlMergeTag {
+				node.Content[i].Tag = ""
+			}
+		}
+	}
+	for _, childNode := range node.Content {
+		yamlWithImplicitMergeTags(childNode)
+	}
+}
+
+type yamlAliasPatch struct{}
+
+func (p yamlAliasPatch) patch(node *yamlv3.Node, oldVal, newVal interface{}) error {
+	if node.Kind == yamlv3.AliasNode {
+		return p.patch(node.Alias, oldVal, newVal)
+	}
+
+	if reflect.DeepEqual(oldVal, newVal) {
+		return nil
+	}
+
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		oldMap, oldOk := oldVal.(map[string]interface{})
+		newMap, newOk := newVal.(map[string]interface{})
+		if oldOk && newOk {
+			return p.patchMapping(node, oldMap, newMap)
+		}
+
+	case yamlv3.SequenceNode:
+		oldSlice, oldOk := oldVal.([]interface{})
+		newSlice, newOk := newVal.([]interface{})
+		if oldOk && newOk && len(oldSlice) == len(node.Content) && len(newSlice) == len(node.Content) {
+			for i, itemNode := range node.Content {
+				err := p.patch(itemNode, oldSlice[i], newSlice[i])
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	return p.replace(node, newVal)
+}
+
+func (p yamlAliasPatch) patchMapping(node *yamlv3.Node, oldMap, newMap map[string]interface{}) error {
+	var content []*yamlv3.Node
+	var mergedNodes []*yamlv3.Node
+
+	explicitKeys := map[string]struct{}{}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valNode := node.Content[i], node.Content[i+1]
+
+		if keyNode.Tag == yamlMergeTag {
+			mergedNodes = append(mergedNodes, yamlMergedNodes(valNode)...)
+			content = append(content, keyNode, valNode)
+			continue
+		}
+
+		explicitKeys[keyNode.Value] = struct{}{}
+
+		newItem, found := newMap[keyNode.Value]
+		if !found {
+			continue
+		}
+
+		err := p.patch(valNode, oldMap[keyNode.Value], newItem)
+		if err != nil {
+			return err
+		}
+
+		content = append(content, keyNode, valNode)
+	}
+
+	for key := range oldMap {
+		if _, found := newMap[key]; !found {
+			if _, explicit := explicitKeys[key]; !explicit {
+				return fmt.Errorf("Expected merged key '%s' to be kept", key)
+			}
+		}
+	}
+
+	var otherKeys []string
+	for key := range newMap {
+		if _, explicit := explicitKeys[key]; !explicit {
+			otherKeys = append(otherKeys, key)
+		}
+	}
+	sort.Strings(otherKeys)
+
+	for _, key := range otherKeys {
+		if oldItem, found := oldMap[key]; found {
+			valNode := yamlMergedValue(mergedNodes, key)
+			if valNode == nil {
+				return fmt.Errorf("Expected to find merged key '%s'", key)
+			}
+			err := p.patch(valNode, oldItem, newMap[key])
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		valNode := &yamlv3.Node{}
+
+		err := valNode.Encode(newMap[key])
+		if err != nil {
+			return err
+		}
+
+		content = append(content, &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: key}, valNode)
+	}
+
+	node.Content = content
+
+	return nil
+}
+
+// replace keeps anchor, comments and quoting style of original node
+func (yamlAliasPatch) replace(node *yamlv3.Node, newVal interface{}) error {
+	var newNode yamlv3.Node
+
+	err := newNode.Encode(newVal)
+	if err != nil {
+		return err
+	}
+
+	if node.Kind == yamlv3.ScalarNode && newNode.Kind == yamlv3.ScalarNode &&
+		node.Tag == newNode.Tag && newNode.Style == 0 {
+		newNode.Style = node.Style
+	}
+
+	newNode.Anchor = node.Anchor
+	newNode.HeadComment = node.HeadComment
+	newNode.LineComment = node.LineComment
+	newNode.FootComment = node.FootComment
+
+	*node = newNode
+
+	return nil
+}
+
+// yamlMergedNodes returns mappings referenced by merge key value
+// (either single alias or sequence of aliases)
+func yamlMergedNodes(node *yamlv3.Node) []*yamlv3.Node {
+	switch node.Kind {
+	case yamlv3.AliasNode:
+		return yamlMergedNodes(node.Alias)
+	case yamlv3.MappingNode:
+		return []*yamlv3.Node{node}
+	case yamlv3.SequenceNode:
+		var result []*yamlv3.Node
+		for _, itemNode := range node.Content {
+			result = append(result, yamlMergedNodes(itemNode)...)
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+// yamlMergedValue finds value of merged key (mappings
+// specified earlier take precedence over later ones)
+func yamlMergedValue(mappingNodes []*yamlv3.Node, key string) *yamlv3.Node {
+	for _, mappingNode := range mappingNodes {
+		var nestedNodes []*yamlv3.Node
+
+		for i := 0; i+1 < len(mappingNode.Content); i += 2 {
+			keyNode, valNode := mappingNode.Content[i], mappingNode.Content[i+1]
+			if keyNode.Tag == yamlMergeTag {
+				nestedNodes = append(nestedNodes, yamlMergedNodes(valNode)...)
+				continue
+			}
+			if keyNode.Value == key {
+				return valNode
+			}
+		}
+
+		if valNode := yamlMergedValue(nestedNodes, key); valNode != nil {
+			return valNode
+		}
+	}
+	return nil
+}
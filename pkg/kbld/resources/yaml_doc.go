@@ -42,3 +42,29 @@ func (f YAMLFile) Docs() ([][]byte, error) {
 
 	return docs, nil
 }
+
+// VisitDocs reads one document at a time so that
+// large files do not have to be kept in memory
+func (f YAMLFile) VisitDocs(visitFunc func([]byte) error) error {
+	fileReader, err := f.fileSrc.Reader()
+	if err != nil {
+		return err
+	}
+	defer fileReader.Close()
+
+	reader := kyaml.NewYAMLReader(bufio.NewReaderSize(fileReader, 4096))
+
+	for {
+		docBytes, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = visitFunc(docBytes)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package scan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Report collects scan results of all images
+type Report struct {
+	results     map[string]Result
+	resultsLock sync.Mutex
+}
+
+func NewReport() *Report {
+	return &Report{results: map[string]Result{}}
+}
+
+func (r *Report) Add(result Result) {
+	r.resultsLock.Lock()
+	defer r.resultsLock.Unlock()
+
+	r.results[result.URL] = result
+}
+
+func (r *Report) All() []Result {
+	r.resultsLock.Lock()
+	defer r.resultsLock.Unlock()
+
+	var result []Result
+	for _, res := range r.results {
+		result = append(result, res)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].URL < result[j].URL
+	})
+	return result
+}
+
+func (r *Report) WriteToFile(path string) error {
+	bs, err := json.MarshalIndent(struct {
+		Images []Result `json:"images"`
+	}{r.All()}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(path, append(bs, '\n'), 0600)
+	if err != nil {
+		return fmt.Errorf("Writing scan report '%s': %s", path, err)
+	}
+
+	return nil
+}
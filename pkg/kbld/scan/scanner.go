@@ -0,0 +1,194 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package scan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+type Vulnerability struct {
+	ID           string `json:"id"`
+	Severity     string `json:"severity"`
+	Package      string `json:"package,omitempty"`
+	FixedVersion string `json:"fixedVersion,omitempty"`
+}
+
+type Result struct {
+	URL             string          `json:"url"`
+	Scanner         string          `json:"scanner"`
+	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+	// Failed indicates that vulnerabilities exceeded configured threshold
+	Failed bool `json:"failed"`
+}
+
+// Counts returns number of vulnerabilities by severity
+func (r Result) Counts() map[string]int {
+	result := map[string]int{}
+	for _, vuln := range r.Vulnerabilities {
+		result[vuln.Severity]++
+	}
+	return result
+}
+
+// Exceeds returns vulnerabilities at or above given severity
+func (r Result) Exceeds(severity string) []Vulnerability {
+	minLevel := ctlconf.SeverityLevel(severity)
+
+	var result []Vulnerability
+	for _, vuln := range r.Vulnerabilities {
+		if ctlconf.SeverityLevel(vuln.Severity) >= minLevel {
+			result = append(result, vuln)
+		}
+	}
+	return result
+}
+
+// Scanner runs trivy, grype or custom command against images in registry
+type Scanner struct {
+	opts   ctlconf.VulnerabilityScan
+	logger ctllog.Logger
+}
+
+func NewScanner(opts ctlconf.VulnerabilityScan, logger ctllog.Logger) Scanner {
+	return Scanner{opts, logger}
+}
+
+func (s Scanner) Scan(url string) (Result, error) {
+	prefixedLogger := s.logger.NewPrefixedWriter(url + " | ")
+
+	scanner := s.opts.ScannerWithDefaults()
+
+	prefixedLogger.Write([]byte(fmt.Sprintf("starting vulnerability scan (using %s)\n", scanner)))
+	defer prefixedLogger.Write([]byte("finished vulnerability scan\n"))
+
+	tmpDir, err := util.MkdirTemp("kbld-scan")
+	if err != nil {
+		return Result{}, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	outputPath := filepath.Join(tmpDir, "report.json")
+
+	var cmd *exec.Cmd
+
+	switch {
+	case len(s.opts.Command) > 0:
+		cmd = exec.Command(s.opts.Command[0], s.opts.Command[1:]...)
+	case scanner == ctlconf.ScannerGrype:
+		cmd = exec.Command("grype", "registry:"+url, "--output", "json", "--file", outputPath)
+	default:
+		cmd = exec.Command("trivy", "image", "--quiet", "--format", "json", "--output", outputPath, url)
+	}
+
+	cmd.Env = append(os.Environ(), "KBLD_SCAN_IMAGE="+url, "KBLD_SCAN_OUTPUT="+outputPath)
+	cmd.Stdout = prefixedLogger
+	cmd.Stderr = prefixedLogger
+
+	err = cmd.Run()
+	if err != nil {
+		prefixedLogger.Write([]byte(fmt.Sprintf("error: %s\n", err)))
+		return Result{}, fmt.Errorf("Scanning image '%s': %s", url, err)
+	}
+
+	bs, err := os.ReadFile(outputPath)
+	if err != nil {
+		return Result{}, fmt.Errorf("Reading scan report for '%s': %s", url, err)
+	}
+
+	vulns, err := ParseReport(scanner, bs)
+	if err != nil {
+		return Result{}, fmt.Errorf("Parsing scan report for '%s': %s", url, err)
+	}
+
+	result := Result{URL: url, Scanner: scanner}
+
+	for _, vuln := range vulns {
+		if s.opts.IgnoreUnfixed && len(vuln.FixedVersion) == 0 {
+			continue
+		}
+		result.Vulnerabilities = append(result.Vulnerabilities, vuln)
+	}
+
+	if len(s.opts.FailOn) > 0 {
+		result.Failed = len(result.Exceeds(s.opts.FailOn)) > 0
+	}
+
+	return result, nil
+}
+
+// ParseReport extracts vulnerabilities from trivy or grype JSON report
+func ParseReport(scanner string, bs []byte) ([]Vulnerability, error) {
+	var result []Vulnerability
+
+	switch scanner {
+	case ctlconf.ScannerGrype:
+		var report struct {
+			Matches []struct {
+				Vulnerability struct {
+					ID       string `json:"id"`
+					Severity string `json:"severity"`
+					Fix      struct {
+						Versions []string `json:"versions"`
+					} `json:"fix"`
+				} `json:"vulnerability"`
+				Artifact struct {
+					Name string `json:"name"`
+				} `json:"artifact"`
+			} `json:"matches"`
+		}
+
+		err := json.Unmarshal(bs, &report)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, match := range report.Matches {
+			result = append(result, Vulnerability{
+				ID:           match.Vulnerability.ID,
+				Severity:     strings.ToUpper(match.Vulnerability.Severity),
+				Package:      match.Artifact.Name,
+				FixedVersion: strings.Join(match.Vulnerability.Fix.Versions, ","),
+			})
+		}
+
+	default:
+		var report struct {
+			Results []struct {
+				Vulnerabilities []struct {
+					VulnerabilityID string
+					PkgName         string
+					FixedVersion    string
+					Severity        string
+				}
+			}
+		}
+
+		err := json.Unmarshal(bs, &report)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, res := range report.Results {
+			for _, vuln := range res.Vulnerabilities {
+				result = append(result, Vulnerability{
+					ID:           vuln.VulnerabilityID,
+					Severity:     strings.ToUpper(vuln.Severity),
+					Package:      vuln.PkgName,
+					FixedVersion: vuln.FixedVersion,
+				})
+			}
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package scan_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlscan "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/scan"
+)
+
+func TestParseReportTrivy(t *testing.T) {
+	report := `{
+  "Results": [
+    {"Target": "app", "Vulnerabilities": [
+      {"VulnerabilityID": "CVE-1", "PkgName": "openssl", "FixedVersion": "3.0.1", "Severity": "CRITICAL"},
+      {"VulnerabilityID": "CVE-2", "PkgName": "zlib", "Severity": "low"}
+    ]},
+    {"Target": "empty"}
+  ]
+}`
+
+	vulns, err := ctlscan.ParseReport(ctlconf.ScannerTrivy, []byte(report))
+	require.NoError(t, err)
+
+	assert.Equal(t, []ctlscan.Vulnerability{
+		{ID: "CVE-1", Severity: "CRITICAL", Package: "openssl", FixedVersion: "3.0.1"},
+		{ID: "CVE-2", Severity: "LOW", Package: "zlib"},
+	}, vulns)
+}
+
+func TestParseReportGrype(t *testing.T) {
+	report := `{
+  "matches": [
+    {"vulnerability": {"id": "CVE-3", "severity": "High", "fix": {"versions": ["1.2.3"]}}, "artifact": {"name": "libc"}}
+  ]
+}`
+
+	vulns, err := ctlscan.ParseReport(ctlconf.ScannerGrype, []byte(report))
+	require.NoError(t, err)
+
+	assert.Equal(t, []ctlscan.Vulnerability{
+		{ID: "CVE-3", Severity: "HIGH", Package: "libc", FixedVersion: "1.2.3"},
+	}, vulns)
+}
+
+func TestResultExceeds(t *testing.T) {
+	result := ctlscan.Result{Vulnerabilities: []ctlscan.Vulnerability{
+		{ID: "CVE-1", Severity: "CRITICAL"},
+		{ID: "CVE-2", Severity: "MEDIUM"},
+		{ID: "CVE-3", Severity: "HIGH"},
+	}}
+
+	assert.Equal(t, []ctlscan.Vulnerability{
+		{ID: "CVE-1", Severity: "CRITICAL"},
+		{ID: "CVE-3", Severity: "HIGH"},
+	}, result.Exceeds("high"))
+
+	assert.Equal(t, map[string]int{"CRITICAL": 1, "MEDIUM": 1, "HIGH": 1}, result.Counts())
+}
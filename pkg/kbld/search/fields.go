@@ -30,7 +30,7 @@ func (f Fields) visit(keyPath ctlres.Path, res interface{}, visitorFunc FieldsVi
 			k := k // copy
 			newKeyPath := append(f.newPath(keyPath), &ctlres.PathPart{MapKey: &k})
 
-			if matched, ext := f.matcher.Matches(newKeyPath, v); matched {
+			if matched, ext := f.matcher.Matches(newKeyPath, v, typedObj); matched {
 				if newVal, update := visitorFunc(v, ext); update {
 					typedObj[k] = newVal
 				} else {
@@ -46,7 +46,7 @@ func (f Fields) visit(keyPath ctlres.Path, res interface{}, visitorFunc FieldsVi
 			k := k // copy
 			newKeyPath := append(f.newPath(keyPath), &ctlres.PathPart{MapKey: &k})
 
-			if matched, ext := f.matcher.Matches(newKeyPath, v); matched {
+			if matched, ext := f.matcher.Matches(newKeyPath, v, nil); matched {
 				if newVal, update := visitorFunc(v, ext); update {
 					typedObj[k] = newVal.(string)
 				} else {
@@ -64,7 +64,7 @@ func (f Fields) visit(keyPath ctlres.Path, res interface{}, visitorFunc FieldsVi
 				ArrayIndex: &ctlres.PathPartArrayIndex{Index: &i},
 			})
 
-			if matched, ext := f.matcher.Matches(newKeyPath, o); matched {
+			if matched, ext := f.matcher.Matches(newKeyPath, o, nil); matched {
 				if newVal, update := visitorFunc(o, ext); update {
 					typedObj[i] = newVal
 				} else {
@@ -7,9 +7,13 @@ import (
 	"encoding/json"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
 	ctlser "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/search"
 )
 
@@ -288,6 +292,53 @@ path:
 			},
 			OutputImages: []string{"nginx1", "nginx2", "nginx3"},
 		},
+		// By env var name prefix
+		{
+			InputResource: map[string]interface{}{
+				"env": []interface{}{
+					map[string]interface{}{"name": "RELATED_IMAGE_AGENT", "value": "agent:1.0"},
+					map[string]interface{}{"name": "LOG_LEVEL", "value": "debug"},
+				},
+			},
+			OutputResource: map[string]interface{}{
+				"env": []interface{}{
+					map[string]interface{}{"name": "RELATED_IMAGE_AGENT", "value": "found:agent:1.0"},
+					map[string]interface{}{"name": "LOG_LEVEL", "value": "debug"},
+				},
+			},
+			SearchRules: []ctlconf.SearchRule{{
+				KeyMatcher: &ctlconf.SearchRuleKeyMatcher{
+					EnvVarNamePrefix: "RELATED_IMAGE_",
+				},
+			}},
+			OutputImages: []string{"agent:1.0"},
+		},
+		// By env var name regexp or image-like value
+		{
+			InputResource: map[string]interface{}{
+				"env": []interface{}{
+					map[string]interface{}{"name": "SIDECAR_IMAGE", "value": "sidecar"},
+					map[string]interface{}{"name": "SIDECAR_IMAGE_PULL_POLICY", "value": "Always"},
+					map[string]interface{}{"name": "PROXY", "value": "gcr.io/proxy:v1"},
+					map[string]interface{}{"name": "ADDR", "value": "localhost:8080"},
+				},
+			},
+			OutputResource: map[string]interface{}{
+				"env": []interface{}{
+					map[string]interface{}{"name": "SIDECAR_IMAGE", "value": "found:sidecar"},
+					map[string]interface{}{"name": "SIDECAR_IMAGE_PULL_POLICY", "value": "Always"},
+					map[string]interface{}{"name": "PROXY", "value": "found:gcr.io/proxy:v1"},
+					map[string]interface{}{"name": "ADDR", "value": "localhost:8080"},
+				},
+			},
+			SearchRules: []ctlconf.SearchRule{{
+				KeyMatcher: &ctlconf.SearchRuleKeyMatcher{EnvVarNameRegexp: ".*_IMAGE"},
+			}, {
+				KeyMatcher:   &ctlconf.SearchRuleKeyMatcher{EnvVarNameRegexp: ".*"},
+				ValueMatcher: &ctlconf.SearchRuleValueMatcher{ImageLike: true},
+			}},
+			OutputImages: []string{"gcr.io/proxy:v1", "sidecar"},
+		},
 	}
 
 	for _, ex := range exs {
@@ -311,3 +362,82 @@ path:
 		}
 	}
 }
+
+func TestImageRefsDefaultSearchRules(t *testing.T) {
+	resourcesYAML := `
+kind: Pod
+metadata:
+  annotations:
+    sidecar.istio.io/proxyImage: pod-proxy
+spec:
+  containers:
+  - image: pod-container
+  ephemeralContainers:
+  - image: pod-debug
+  volumes:
+  - name: data
+    image:
+      reference: pod-volume
+---
+kind: StatefulSet
+spec:
+  template:
+    metadata:
+      annotations:
+        vault.hashicorp.com/agent-image: sts-vault-agent
+    spec:
+      initContainers:
+      - image: sts-init
+      containers:
+      - image: sts-container
+---
+kind: DaemonSet
+spec:
+  template:
+    spec:
+      containers:
+      - image: ds-container
+      volumes:
+      - name: data
+        image:
+          reference: ds-volume
+---
+kind: CronJob
+spec:
+  jobTemplate:
+    spec:
+      template:
+        metadata:
+          annotations:
+            sidecar.istio.io/proxyImage: cronjob-proxy
+        spec:
+          containers:
+          - image: cronjob-container
+          volumes:
+          - name: data
+            image:
+              reference: cronjob-volume
+`
+
+	foundImages := []string{}
+
+	for _, docYAML := range strings.Split(resourcesYAML, "---\n") {
+		rs, err := ctlres.NewResourcesFromBytes([]byte(docYAML))
+		require.NoError(t, err)
+		require.Len(t, rs, 1)
+
+		ctlser.NewImageRefs(rs[0].DeepCopyRaw(), ctlconf.DefaultSearchRules()).Visit(func(val string) (string, bool) {
+			foundImages = append(foundImages, val)
+			return "", false
+		})
+	}
+
+	sort.Strings(foundImages)
+
+	assert.Equal(t, []string{
+		"cronjob-container", "cronjob-proxy", "cronjob-volume",
+		"ds-container", "ds-volume",
+		"pod-container", "pod-debug", "pod-proxy", "pod-volume",
+		"sts-container", "sts-init", "sts-vault-agent",
+	}, foundImages)
+}
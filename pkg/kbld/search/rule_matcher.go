@@ -5,6 +5,9 @@ package search
 
 import (
 	"reflect"
+	"regexp"
+	"strings"
+	"sync"
 
 	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
 	ctlimg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/image"
@@ -12,7 +15,8 @@ import (
 )
 
 type Matcher interface {
-	Matches(keyPath ctlres.Path, value interface{}) (bool, ctlconf.SearchRuleUpdateStrategy)
+	// Matches is given map that contains the value (nil when value is not within map[string]interface{})
+	Matches(keyPath ctlres.Path, value interface{}, parent map[string]interface{}) (bool, ctlconf.SearchRuleUpdateStrategy)
 }
 
 type RuleMatcher struct {
@@ -21,7 +25,7 @@ type RuleMatcher struct {
 
 var _ Matcher = RuleMatcher{}
 
-func (m RuleMatcher) Matches(keyPath ctlres.Path, value interface{}) (bool, ctlconf.SearchRuleUpdateStrategy) {
+func (m RuleMatcher) Matches(keyPath ctlres.Path, value interface{}, parent map[string]interface{}) (bool, ctlconf.SearchRuleUpdateStrategy) {
 	var keyMatched, valueMatched bool
 
 	if m.rule.KeyMatcher != nil {
@@ -33,6 +37,21 @@ func (m RuleMatcher) Matches(keyPath ctlres.Path, value interface{}) (bool, ctlc
 		case len(m.rule.KeyMatcher.Path) > 0:
 			keyMatched = m.rule.KeyMatcher.Path.Matches(keyPath)
 
+		case len(m.rule.KeyMatcher.EnvVarNamePrefix) > 0:
+			// Env vars are specified as list of {name: ..., value: ...}
+			value := "value"
+			if keyPath.HasMatchingSuffix(ctlres.Path{{MapKey: &value}}) && parent != nil {
+				name, _ := parent["name"].(string)
+				keyMatched = strings.HasPrefix(name, m.rule.KeyMatcher.EnvVarNamePrefix)
+			}
+
+		case len(m.rule.KeyMatcher.EnvVarNameRegexp) > 0:
+			value := "value"
+			if keyPath.HasMatchingSuffix(ctlres.Path{{MapKey: &value}}) && parent != nil {
+				name, _ := parent["name"].(string)
+				keyMatched = envVarNameRegexp(m.rule.KeyMatcher.EnvVarNameRegexp).MatchString(name)
+			}
+
 		default:
 			panic("Unknown search rule key matcher")
 		}
@@ -47,6 +66,11 @@ func (m RuleMatcher) Matches(keyPath ctlres.Path, value interface{}) (bool, ctlc
 				valueMatched = true
 			}
 
+		case m.rule.ValueMatcher.ImageLike:
+			if valueStr, ok := value.(string); ok {
+				valueMatched = ctlimg.LooksLikeImageRef(valueStr)
+			}
+
 		case len(m.rule.ValueMatcher.ImageRepo) > 0:
 			if valueStr, ok := value.(string); ok {
 				repo, matchesImg := ctlimg.URLRepo(valueStr)
@@ -64,3 +88,22 @@ func (m RuleMatcher) Matches(keyPath ctlres.Path, value interface{}) (bool, ctlc
 
 	return keyMatched && valueMatched, m.rule.UpdateStrategyWithDefaults()
 }
+
+var (
+	envVarNameRegexps     = map[string]*regexp.Regexp{}
+	envVarNameRegexpsLock sync.Mutex
+)
+
+// envVarNameRegexp returns compiled regexp that matches entire name
+// (search rules are validated hence regexp is known to be valid)
+func envVarNameRegexp(str string) *regexp.Regexp {
+	envVarNameRegexpsLock.Lock()
+	defer envVarNameRegexpsLock.Unlock()
+
+	if re, found := envVarNameRegexps[str]; found {
+		return re
+	}
+	re := regexp.MustCompile(`\A(?:` + str + `)\z`)
+	envVarNameRegexps[str] = re
+	return re
+}
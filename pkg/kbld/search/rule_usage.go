@@ -0,0 +1,82 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package search
+
+import (
+	"reflect"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlres "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/resources"
+)
+
+// RuleUsage records which search rules matched values in visited resources
+// and which values were skipped (matched by rules with 'none' update strategy)
+type RuleUsage struct {
+	rules   []ctlconf.SearchRule
+	matched []bool
+	skipped []string
+}
+
+func NewRuleUsage(rules []ctlconf.SearchRule) *RuleUsage {
+	return &RuleUsage{rules: rules, matched: make([]bool, len(rules))}
+}
+
+// Visit does not modify given resource
+func (u *RuleUsage) Visit(res interface{}) {
+	NewFields(res, ruleUsageMatcher{u}).Visit(func(val interface{}, ext ctlconf.SearchRuleUpdateStrategy) (interface{}, bool) {
+		if valStr, ok := val.(string); ok && ext.None != nil {
+			u.skipped = append(u.skipped, valStr)
+		}
+		return val, false
+	})
+}
+
+// Unmatched returns given rules that did not match any value
+func (u *RuleUsage) Unmatched(rules []ctlconf.SearchRule) []ctlconf.SearchRule {
+	var result []ctlconf.SearchRule
+	for _, rule := range rules {
+		var matched bool
+		for i, usedRule := range u.rules {
+			if u.matched[i] && reflect.DeepEqual(rule, usedRule) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			result = append(result, rule)
+		}
+	}
+	return result
+}
+
+// Skipped returns string values that were not updated because of 'none' update strategy
+func (u *RuleUsage) Skipped() []string {
+	return u.skipped
+}
+
+// ruleUsageMatcher behaves like RulesMatcher (first matching rule wins)
+// but records all rules that match value
+type ruleUsageMatcher struct {
+	usage *RuleUsage
+}
+
+var _ Matcher = ruleUsageMatcher{}
+
+func (m ruleUsageMatcher) Matches(keyPath ctlres.Path, value interface{}, parent map[string]interface{}) (bool, ctlconf.SearchRuleUpdateStrategy) {
+	var firstMatched bool
+	var firstExtraction ctlconf.SearchRuleUpdateStrategy
+
+	for i, rule := range m.usage.rules {
+		matches, extraction := (RuleMatcher{rule}).Matches(keyPath, value, parent)
+		if matches {
+			m.usage.matched[i] = true
+			if !firstMatched {
+				firstMatched = true
+				firstExtraction = extraction
+			}
+		}
+	}
+
+	return firstMatched, firstExtraction
+}
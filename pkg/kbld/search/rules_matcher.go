@@ -18,9 +18,9 @@ func NewRulesMatcher(rules []ctlconf.SearchRule) RulesMatcher {
 
 var _ Matcher = RuleMatcher{}
 
-func (m RulesMatcher) Matches(keyPath ctlres.Path, value interface{}) (bool, ctlconf.SearchRuleUpdateStrategy) {
+func (m RulesMatcher) Matches(keyPath ctlres.Path, value interface{}, parent map[string]interface{}) (bool, ctlconf.SearchRuleUpdateStrategy) {
 	for _, rule := range m.rules {
-		matches, extraction := (RuleMatcher{rule}).Matches(keyPath, value)
+		matches, extraction := (RuleMatcher{rule}).Matches(keyPath, value, parent)
 		if matches {
 			return true, extraction
 		}
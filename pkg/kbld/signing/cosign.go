@@ -0,0 +1,158 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package signing
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+)
+
+type Cosign struct {
+	logger ctllog.Logger
+}
+
+func NewCosign(logger ctllog.Logger) Cosign {
+	return Cosign{logger}
+}
+
+var (
+	cosignTlogIndexRegexp = regexp.MustCompile(`tlog entry created with index: (\d+)`)
+)
+
+// Sign signs image and returns transparency log index when entry was recorded (-1 otherwise)
+func (c Cosign) Sign(url string, opts ctlconf.Signing) (int64, error) {
+	prefixedLogger := c.logger.NewPrefixedWriter(url + " | ")
+
+	prefixedLogger.Write([]byte("starting signing (using cosign)\n"))
+	defer prefixedLogger.Write([]byte("finished signing (using cosign)\n"))
+
+	cmdArgs := []string{"sign", "--yes"}
+
+	switch {
+	case len(opts.Key) > 0:
+		cmdArgs = append(cmdArgs, "--key", opts.Key)
+	case len(opts.KMS) > 0:
+		cmdArgs = append(cmdArgs, "--key", opts.KMS)
+	}
+
+	if opts.TransparencyLog != nil {
+		cmdArgs = append(cmdArgs, "--tlog-upload=true", "--rekor-url", opts.TransparencyLog.URLWithDefaults())
+	}
+
+	if opts.RawOptions != nil {
+		cmdArgs = append(cmdArgs, *opts.RawOptions...)
+	}
+
+	cmdArgs = append(cmdArgs, url)
+
+	var outputBuf bytes.Buffer
+
+	cmd := exec.Command("cosign", cmdArgs...)
+	cmd.Stdout = io.MultiWriter(&outputBuf, prefixedLogger)
+	cmd.Stderr = io.MultiWriter(&outputBuf, prefixedLogger)
+
+	err := cmd.Run()
+	if err != nil {
+		prefixedLogger.Write([]byte(fmt.Sprintf("error: %s\n", err)))
+		return 0, fmt.Errorf("Signing image '%s': %s", url, err)
+	}
+
+	matches := cosignTlogIndexRegexp.FindSubmatch(outputBuf.Bytes())
+	if len(matches) != 2 {
+		return -1, nil
+	}
+
+	logIndex, err := strconv.ParseInt(string(matches[1]), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Parsing transparency log index: %s", err)
+	}
+
+	return logIndex, nil
+}
+
+func (c Cosign) Attest(url, predicatePath, predicateType string, opts ctlconf.Signing) error {
+	prefixedLogger := c.logger.NewPrefixedWriter(url + " | ")
+
+	prefixedLogger.Write([]byte(fmt.Sprintf("starting attestation (using cosign): %s\n", predicateType)))
+	defer prefixedLogger.Write([]byte("finished attestation (using cosign)\n"))
+
+	cmdArgs := []string{"attest", "--yes", "--predicate", predicatePath, "--type", predicateType}
+
+	switch {
+	case len(opts.Key) > 0:
+		cmdArgs = append(cmdArgs, "--key", opts.Key)
+	case len(opts.KMS) > 0:
+		cmdArgs = append(cmdArgs, "--key", opts.KMS)
+	}
+
+	if opts.RawOptions != nil {
+		cmdArgs = append(cmdArgs, *opts.RawOptions...)
+	}
+
+	cmdArgs = append(cmdArgs, url)
+
+	cmd := exec.Command("cosign", cmdArgs...)
+	cmd.Stdout = prefixedLogger
+	cmd.Stderr = prefixedLogger
+
+	err := cmd.Run()
+	if err != nil {
+		prefixedLogger.Write([]byte(fmt.Sprintf("error: %s\n", err)))
+		return fmt.Errorf("Attesting image '%s': %s", url, err)
+	}
+
+	return nil
+}
+
+func (c Cosign) Verify(url string, opts ctlconf.VerificationPolicyCosign) error {
+	cmdArgs := []string{"verify"}
+
+	switch {
+	case len(opts.Key) > 0:
+		cmdArgs = append(cmdArgs, "--key", opts.Key)
+	case len(opts.KMS) > 0:
+		cmdArgs = append(cmdArgs, "--key", opts.KMS)
+	case opts.Keyless != nil:
+		if len(opts.Keyless.Identity) > 0 {
+			cmdArgs = append(cmdArgs, "--certificate-identity", opts.Keyless.Identity)
+		}
+		if len(opts.Keyless.IdentityRegexp) > 0 {
+			cmdArgs = append(cmdArgs, "--certificate-identity-regexp", opts.Keyless.IdentityRegexp)
+		}
+		if len(opts.Keyless.Issuer) > 0 {
+			cmdArgs = append(cmdArgs, "--certificate-oidc-issuer", opts.Keyless.Issuer)
+		}
+		if len(opts.Keyless.IssuerRegexp) > 0 {
+			cmdArgs = append(cmdArgs, "--certificate-oidc-issuer-regexp", opts.Keyless.IssuerRegexp)
+		}
+	}
+
+	if opts.RawOptions != nil {
+		cmdArgs = append(cmdArgs, *opts.RawOptions...)
+	}
+
+	cmdArgs = append(cmdArgs, url)
+
+	// Verification output includes signature payloads, hence only show it on failure
+	var outputBuf bytes.Buffer
+
+	cmd := exec.Command("cosign", cmdArgs...)
+	cmd.Stdout = &outputBuf
+	cmd.Stderr = &outputBuf
+
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("Verifying signature of image '%s': %s (output: %s)",
+			url, err, bytes.TrimSpace(outputBuf.Bytes()))
+	}
+
+	return nil
+}
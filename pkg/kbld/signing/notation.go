@@ -0,0 +1,151 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package signing
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+const (
+	NotationSignatureArtifactType = "application/vnd.cncf.notary.signature"
+)
+
+type Notation struct {
+	logger ctllog.Logger
+}
+
+func NewNotation(logger ctllog.Logger) Notation {
+	return Notation{logger}
+}
+
+func (n Notation) Sign(url string, opts ctlconf.SigningNotation, rawOptions *[]string) error {
+	prefixedLogger := n.logger.NewPrefixedWriter(url + " | ")
+
+	prefixedLogger.Write([]byte("starting signing (using notation)\n"))
+	defer prefixedLogger.Write([]byte("finished signing (using notation)\n"))
+
+	cmdArgs := []string{"sign"}
+
+	switch {
+	case len(opts.Key) > 0:
+		cmdArgs = append(cmdArgs, "--key", opts.Key)
+	case opts.KMS != nil:
+		cmdArgs = append(cmdArgs, "--plugin", opts.KMS.Plugin, "--id", opts.KMS.KeyID)
+
+		var configKeys []string
+		for k := range opts.KMS.PluginConfig {
+			configKeys = append(configKeys, k)
+		}
+		sort.Strings(configKeys)
+
+		for _, k := range configKeys {
+			cmdArgs = append(cmdArgs, "--plugin-config", k+"="+opts.KMS.PluginConfig[k])
+		}
+	}
+	if rawOptions != nil {
+		cmdArgs = append(cmdArgs, *rawOptions...)
+	}
+
+	cmdArgs = append(cmdArgs, url)
+
+	cmd := exec.Command("notation", cmdArgs...)
+	cmd.Stdout = prefixedLogger
+	cmd.Stderr = prefixedLogger
+
+	err := cmd.Run()
+	if err != nil {
+		prefixedLogger.Write([]byte(fmt.Sprintf("error: %s\n", err)))
+		return fmt.Errorf("Signing image '%s': %s", url, err)
+	}
+
+	return nil
+}
+
+func (n Notation) Verify(url string, opts ctlconf.VerificationPolicyNotation) error {
+	cmdArgs := []string{"verify"}
+
+	if opts.RawOptions != nil {
+		cmdArgs = append(cmdArgs, *opts.RawOptions...)
+	}
+
+	cmdArgs = append(cmdArgs, url)
+
+	var outputBuf bytes.Buffer
+
+	cmd := exec.Command("notation", cmdArgs...)
+	cmd.Stdout = &outputBuf
+	cmd.Stderr = &outputBuf
+
+	if len(opts.TrustPolicy) > 0 {
+		configHome, err := n.configHome(opts)
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(configHome)
+
+		// notation finds its configuration within user config directory
+		// (XDG_CONFIG_HOME on Linux)
+		cmd.Env = append(os.Environ(), "XDG_CONFIG_HOME="+configHome)
+	}
+
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("Verifying signature of image '%s': %s (output: %s)",
+			url, err, bytes.TrimSpace(outputBuf.Bytes()))
+	}
+
+	return nil
+}
+
+// configHome prepares notation configuration directory with given trust policy and store
+func (n Notation) configHome(opts ctlconf.VerificationPolicyNotation) (string, error) {
+	policyBs, err := os.ReadFile(opts.TrustPolicy)
+	if err != nil {
+		return "", fmt.Errorf("Reading notation trust policy '%s': %s", opts.TrustPolicy, err)
+	}
+
+	configHome, err := util.MkdirTemp("kbld-notation")
+	if err != nil {
+		return "", err
+	}
+
+	notationDir := filepath.Join(configHome, "notation")
+
+	err = os.MkdirAll(notationDir, 0700)
+	if err != nil {
+		os.RemoveAll(configHome)
+		return "", err
+	}
+
+	err = os.WriteFile(filepath.Join(notationDir, "trustpolicy.json"), policyBs, 0600)
+	if err != nil {
+		os.RemoveAll(configHome)
+		return "", err
+	}
+
+	if len(opts.TrustStore) > 0 {
+		trustStore, err := filepath.Abs(opts.TrustStore)
+		if err != nil {
+			os.RemoveAll(configHome)
+			return "", err
+		}
+
+		err = os.Symlink(trustStore, filepath.Join(notationDir, "truststore"))
+		if err != nil {
+			os.RemoveAll(configHome)
+			return "", err
+		}
+	}
+
+	return configHome, nil
+}
@@ -0,0 +1,278 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package signing
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+// Rekor checks that signatures recorded in lock files
+// are included in transparency log
+type Rekor struct {
+	registry  ctlreg.Registry
+	publicKey crypto.PublicKey
+	client    *http.Client
+}
+
+// NewRekor verifies entries against given transparency log public key
+func NewRekor(registry ctlreg.Registry, publicKey crypto.PublicKey) Rekor {
+	return Rekor{registry, publicKey, &http.Client{Timeout: 30 * time.Second}}
+}
+
+type RekorEntry struct {
+	Body           string             `json:"body"`
+	IntegratedTime int64              `json:"integratedTime"`
+	LogIndex       int64              `json:"logIndex"`
+	Verification   *RekorVerification `json:"verification,omitempty"`
+}
+
+type RekorVerification struct {
+	InclusionProof *InclusionProof `json:"inclusionProof,omitempty"`
+}
+
+type InclusionProof struct {
+	// Hashes are hex encoded sibling hashes from leaf to root
+	Hashes []string `json:"hashes"`
+	// LogIndex is index of leaf within tree (may differ from global log index)
+	LogIndex int64  `json:"logIndex"`
+	RootHash string `json:"rootHash"`
+	TreeSize int64  `json:"treeSize"`
+	// Checkpoint is signed note committing to tree size and root hash
+	Checkpoint string `json:"checkpoint"`
+}
+
+type rekorHashedRekord struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+	} `json:"spec"`
+}
+
+type cosignPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// Verify checks that transparency log entry includes signature of given image.
+// Inclusion proof is checked against root hash of checkpoint signed by the log.
+func (r Rekor) Verify(url string, entry ctlconf.OriginTransparencyLogEntry) error {
+	ref, err := regname.NewDigest(url, regname.WeakValidation)
+	if err != nil {
+		return fmt.Errorf("Expected image '%s' to be a digest reference: %s", url, err)
+	}
+
+	if r.publicKey == nil {
+		return fmt.Errorf("Expected transparency log public key to be configured")
+	}
+
+	logEntry, err := r.Entry(entry.URL, entry.LogIndex)
+	if err != nil {
+		return err
+	}
+
+	if logEntry.Verification == nil || logEntry.Verification.InclusionProof == nil {
+		return fmt.Errorf("Expected transparency log entry %d to include inclusion proof", entry.LogIndex)
+	}
+
+	body, err := base64.StdEncoding.DecodeString(logEntry.Body)
+	if err != nil {
+		return fmt.Errorf("Decoding transparency log entry %d: %s", entry.LogIndex, err)
+	}
+
+	err = VerifyCheckpoint(*logEntry.Verification.InclusionProof, r.publicKey)
+	if err != nil {
+		return fmt.Errorf("Verifying checkpoint of transparency log entry %d: %s", entry.LogIndex, err)
+	}
+
+	err = VerifyInclusionProof(body, *logEntry.Verification.InclusionProof)
+	if err != nil {
+		return fmt.Errorf("Verifying inclusion of transparency log entry %d: %s", entry.LogIndex, err)
+	}
+
+	var rekord rekorHashedRekord
+
+	err = json.Unmarshal(body, &rekord)
+	if err != nil {
+		return fmt.Errorf("Unmarshaling transparency log entry %d: %s", entry.LogIndex, err)
+	}
+
+	if rekord.Kind != "hashedrekord" || rekord.Spec.Data.Hash.Algorithm != "sha256" {
+		return fmt.Errorf("Expected transparency log entry %d to be sha256 hashedrekord, but was '%s'",
+			entry.LogIndex, rekord.Kind)
+	}
+
+	return r.verifySignaturePayload(ref, entry.Signature, "sha256:"+rekord.Spec.Data.Hash.Value)
+}
+
+// verifySignaturePayload checks that signature manifest contains recorded payload
+// and that payload refers to given image digest
+func (r Rekor) verifySignaturePayload(ref regname.Digest, sigURL, payloadDigest string) error {
+	sigRef, err := regname.NewDigest(sigURL, regname.WeakValidation)
+	if err != nil {
+		return fmt.Errorf("Expected signature '%s' to be a digest reference: %s", sigURL, err)
+	}
+
+	sigImg, err := r.registry.Image(sigRef)
+	if err != nil {
+		return fmt.Errorf("Getting signature '%s': %s", sigURL, err)
+	}
+
+	layers, err := sigImg.Layers()
+	if err != nil {
+		return err
+	}
+
+	for _, layer := range layers {
+		digest, err := layer.Digest()
+		if err != nil {
+			return err
+		}
+		if digest.String() != payloadDigest {
+			continue
+		}
+
+		rc, err := layer.Compressed()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+
+		var payload cosignPayload
+
+		err = json.NewDecoder(rc).Decode(&payload)
+		if err != nil {
+			return fmt.Errorf("Unmarshaling signature payload of '%s': %s", sigURL, err)
+		}
+
+		if payload.Critical.Image.DockerManifestDigest != ref.DigestStr() {
+			return fmt.Errorf("Expected signature payload to refer to '%s', but was '%s'",
+				ref.DigestStr(), payload.Critical.Image.DockerManifestDigest)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("Expected signature '%s' to contain payload '%s' recorded in transparency log",
+		sigURL, payloadDigest)
+}
+
+// Entry fetches transparency log entry by its log index
+func (r Rekor) Entry(url string, logIndex int64) (RekorEntry, error) {
+	entryURL := fmt.Sprintf("%s/api/v1/log/entries?logIndex=%d", strings.TrimSuffix(url, "/"), logIndex)
+
+	resp, err := r.client.Get(entryURL)
+	if err != nil {
+		return RekorEntry{}, fmt.Errorf("Fetching transparency log entry %d: %s", logIndex, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return RekorEntry{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return RekorEntry{}, fmt.Errorf("Expected fetching transparency log entry %d to succeed, "+
+			"but got status '%s': %s", logIndex, resp.Status, bytes.TrimSpace(respBody))
+	}
+
+	// Response is keyed by entry UUID
+	var entries map[string]RekorEntry
+
+	err = json.Unmarshal(respBody, &entries)
+	if err != nil {
+		return RekorEntry{}, fmt.Errorf("Unmarshaling transparency log entry %d: %s", logIndex, err)
+	}
+
+	for _, entry := range entries {
+		if entry.LogIndex == logIndex {
+			return entry, nil
+		}
+	}
+
+	return RekorEntry{}, fmt.Errorf("Expected to find transparency log entry %d", logIndex)
+}
+
+// VerifyInclusionProof checks Merkle tree inclusion proof
+// for given leaf data (as described in RFC 9162 section 2.1.3.2)
+func VerifyInclusionProof(leaf []byte, proof InclusionProof) error {
+	if proof.LogIndex < 0 || proof.LogIndex >= proof.TreeSize {
+		return fmt.Errorf("Expected leaf index %d to be within tree size %d", proof.LogIndex, proof.TreeSize)
+	}
+
+	rootHash, err := hex.DecodeString(proof.RootHash)
+	if err != nil {
+		return fmt.Errorf("Decoding root hash: %s", err)
+	}
+
+	fn := proof.LogIndex
+	sn := proof.TreeSize - 1
+	result := MerkleLeafHash(leaf)
+
+	for _, hashStr := range proof.Hashes {
+		hash, err := hex.DecodeString(hashStr)
+		if err != nil {
+			return fmt.Errorf("Decoding proof hash: %s", err)
+		}
+
+		if sn == 0 {
+			return fmt.Errorf("Expected inclusion proof to have fewer hashes")
+		}
+
+		if fn%2 == 1 || fn == sn {
+			result = MerkleNodeHash(hash, result)
+			for fn%2 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			result = MerkleNodeHash(result, hash)
+		}
+
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 {
+		return fmt.Errorf("Expected inclusion proof to have more hashes")
+	}
+	if !bytes.Equal(result, rootHash) {
+		return fmt.Errorf("Expected calculated root hash '%x' to match '%x'", result, rootHash)
+	}
+
+	return nil
+}
+
+func MerkleLeafHash(leaf []byte) []byte {
+	sum := sha256.Sum256(append([]byte{0x00}, leaf...))
+	return sum[:]
+}
+
+func MerkleNodeHash(left, right []byte) []byte {
+	data := append([]byte{0x01}, left...)
+	sum := sha256.Sum256(append(data, right...))
+	return sum[:]
+}
@@ -0,0 +1,156 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package signing
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+
+Example checkpoint (signed note):
+
+rekor.sigstore.dev - 2605736670972794746
+21428036
+rs1YPY0ydZ1JJSjh1mXc2xms6q5NBvXLdqd6Jz1HQeA=
+
+— rekor.sigstore.dev wNI9ajBFAiEA...
+
+Signature is base64 encoded 4 byte key hint (prefix of sha256 of DER encoded
+public key) followed by signature of note text (everything before blank line).
+
+*/
+
+const (
+	checkpointSigPrefix = "— "
+)
+
+// ParseRekorPublicKey parses PEM encoded public key of transparency log (ECDSA or Ed25519)
+func ParseRekorPublicKey(pemBs []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(pemBs)
+	if block == nil {
+		return nil, fmt.Errorf("Expected transparency log public key to be PEM encoded")
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Parsing transparency log public key: %s", err)
+	}
+
+	switch publicKey.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey:
+		return publicKey, nil
+	default:
+		return nil, fmt.Errorf("Expected transparency log public key to be ECDSA or Ed25519 key, but was %T", publicKey)
+	}
+}
+
+// VerifyCheckpoint checks that checkpoint is signed by transparency log
+// and that it commits to tree size and root hash of inclusion proof
+// (otherwise root hash could be chosen by anyone returning log entries)
+func VerifyCheckpoint(proof InclusionProof, publicKey crypto.PublicKey) error {
+	text, sigLines, found := strings.Cut(proof.Checkpoint, "\n\n")
+	if !found {
+		return fmt.Errorf("Expected checkpoint to include signatures")
+	}
+
+	// Signed text includes final new line
+	text += "\n"
+
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if len(lines) < 3 {
+		return fmt.Errorf("Expected checkpoint to include origin, tree size and root hash")
+	}
+
+	err := verifyCheckpointSignatures([]byte(text), sigLines, publicKey)
+	if err != nil {
+		return err
+	}
+
+	treeSize, err := strconv.ParseInt(lines[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("Parsing checkpoint tree size: %s", err)
+	}
+
+	rootHash, err := base64.StdEncoding.DecodeString(lines[2])
+	if err != nil {
+		return fmt.Errorf("Decoding checkpoint root hash: %s", err)
+	}
+
+	proofRootHash, err := hex.DecodeString(proof.RootHash)
+	if err != nil {
+		return fmt.Errorf("Decoding root hash: %s", err)
+	}
+
+	if treeSize != proof.TreeSize || !bytes.Equal(rootHash, proofRootHash) {
+		return fmt.Errorf("Expected checkpoint (tree size %d, root hash '%x') to match inclusion proof "+
+			"(tree size %d, root hash '%x')", treeSize, rootHash, proof.TreeSize, proofRootHash)
+	}
+
+	return nil
+}
+
+func verifyCheckpointSignatures(text []byte, sigLines string, publicKey crypto.PublicKey) error {
+	keyHint, err := checkpointKeyHint(publicKey)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Split(sigLines, "\n") {
+		if !strings.HasPrefix(line, checkpointSigPrefix) {
+			continue
+		}
+
+		// Line consists of signer name and signature
+		pieces := strings.Fields(strings.TrimPrefix(line, checkpointSigPrefix))
+		if len(pieces) != 2 {
+			continue
+		}
+
+		sig, err := base64.StdEncoding.DecodeString(pieces[1])
+		if err != nil || len(sig) <= len(keyHint) || !bytes.Equal(sig[:len(keyHint)], keyHint) {
+			continue
+		}
+
+		if verifyCheckpointSignature(text, sig[len(keyHint):], publicKey) {
+			return nil
+		}
+
+		return fmt.Errorf("Expected checkpoint signature by '%s' to be valid", pieces[0])
+	}
+
+	return fmt.Errorf("Expected checkpoint to be signed by configured transparency log public key")
+}
+
+func verifyCheckpointSignature(text, sig []byte, publicKey crypto.PublicKey) bool {
+	switch typedKey := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(text)
+		return ecdsa.VerifyASN1(typedKey, digest[:], sig)
+	case ed25519.PublicKey:
+		return ed25519.Verify(typedKey, text, sig)
+	default:
+		return false
+	}
+}
+
+func checkpointKeyHint(publicKey crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("Marshaling transparency log public key: %s", err)
+	}
+	sum := sha256.Sum256(der)
+	return sum[:4], nil
+}
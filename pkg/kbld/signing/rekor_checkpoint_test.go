@@ -0,0 +1,183 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package signing_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	ctlsign "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/signing"
+)
+
+func TestVerifyCheckpoint(t *testing.T) {
+	logKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	leaves := testLeaves(5)
+
+	proof := ctlsign.InclusionProof{
+		Hashes:   testProof(2, leaves),
+		LogIndex: 2,
+		RootHash: hex.EncodeToString(testTreeHash(leaves)),
+		TreeSize: 5,
+	}
+	proof.Checkpoint = testCheckpoint(t, logKey, proof.TreeSize, proof.RootHash)
+
+	require.NoError(t, ctlsign.VerifyCheckpoint(proof, logKey.Public()))
+
+	t.Run("rejects root hash that was not signed", func(t *testing.T) {
+		tamperedLeaves := append(testLeaves(4), []byte("tampered"))
+
+		tamperedProof := proof
+		tamperedProof.Hashes = testProof(2, tamperedLeaves)
+		tamperedProof.RootHash = hex.EncodeToString(testTreeHash(tamperedLeaves))
+
+		// Proof itself is consistent with tampered root hash
+		require.NoError(t, ctlsign.VerifyInclusionProof(tamperedLeaves[2], tamperedProof))
+
+		err := ctlsign.VerifyCheckpoint(tamperedProof, logKey.Public())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Expected checkpoint (tree size 5, root hash")
+		assert.Contains(t, err.Error(), "to match inclusion proof")
+	})
+
+	t.Run("rejects tampered checkpoint text", func(t *testing.T) {
+		tamperedProof := proof
+		tamperedProof.TreeSize = 6
+		tamperedProof.Checkpoint = "rekor.example.com - 1\n6" + proof.Checkpoint[len("rekor.example.com - 1\n5"):]
+
+		err := ctlsign.VerifyCheckpoint(tamperedProof, logKey.Public())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Expected checkpoint signature by 'rekor.example.com' to be valid")
+	})
+
+	t.Run("rejects checkpoint signed by other key", func(t *testing.T) {
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		otherProof := proof
+		otherProof.Checkpoint = testCheckpoint(t, otherKey, proof.TreeSize, proof.RootHash)
+
+		err = ctlsign.VerifyCheckpoint(otherProof, logKey.Public())
+		require.Error(t, err)
+		assert.Equal(t, "Expected checkpoint to be signed by configured transparency log public key", err.Error())
+	})
+
+	t.Run("rejects unsigned checkpoint", func(t *testing.T) {
+		unsignedProof := proof
+		unsignedProof.Checkpoint = ""
+
+		err := ctlsign.VerifyCheckpoint(unsignedProof, logKey.Public())
+		require.Error(t, err)
+		assert.Equal(t, "Expected checkpoint to include signatures", err.Error())
+	})
+
+	t.Run("supports Ed25519 keys", func(t *testing.T) {
+		_, edKey, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+
+		edProof := proof
+		edProof.Checkpoint = testCheckpoint(t, edKey, proof.TreeSize, proof.RootHash)
+
+		require.NoError(t, ctlsign.VerifyCheckpoint(edProof, edKey.Public()))
+	})
+}
+
+func TestParseRekorPublicKey(t *testing.T) {
+	logKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(logKey.Public())
+	require.NoError(t, err)
+
+	publicKey, err := ctlsign.ParseRekorPublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	require.NoError(t, err)
+	assert.True(t, logKey.PublicKey.Equal(publicKey))
+
+	_, err = ctlsign.ParseRekorPublicKey([]byte("not a key"))
+	require.Error(t, err)
+	assert.Equal(t, "Expected transparency log public key to be PEM encoded", err.Error())
+}
+
+func TestRekorVerifyRejectsEntriesWithForgedCheckpoint(t *testing.T) {
+	logKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	// Malicious log (or proxy) returns self consistent proof signed by its own key
+	attackerKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	body := []byte(`{"kind":"hashedrekord","spec":{"data":{"hash":{"algorithm":"sha256","value":"00"}}}}`)
+	leaves := [][]byte{[]byte("leaf-0"), body}
+
+	proof := ctlsign.InclusionProof{
+		Hashes:   testProof(1, leaves),
+		LogIndex: 1,
+		RootHash: hex.EncodeToString(testTreeHash(leaves)),
+		TreeSize: 2,
+	}
+	proof.Checkpoint = testCheckpoint(t, attackerKey, proof.TreeSize, proof.RootHash)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		entry := ctlsign.RekorEntry{
+			Body:         base64.StdEncoding.EncodeToString(body),
+			LogIndex:     7,
+			Verification: &ctlsign.RekorVerification{InclusionProof: &proof},
+		}
+		json.NewEncoder(w).Encode(map[string]ctlsign.RekorEntry{"uuid": entry})
+	}))
+	defer server.Close()
+
+	url := fmt.Sprintf("registry.example.com/app@sha256:%064d", 1)
+	entry := ctlconf.OriginTransparencyLogEntry{URL: server.URL, LogIndex: 7,
+		Signature: fmt.Sprintf("registry.example.com/app@sha256:%064d", 2)}
+
+	err = ctlsign.NewRekor(ctlreg.Registry{}, logKey.Public()).Verify(url, entry)
+	require.Error(t, err)
+	assert.Equal(t, "Verifying checkpoint of transparency log entry 7: "+
+		"Expected checkpoint to be signed by configured transparency log public key", err.Error())
+
+	err = ctlsign.NewRekor(ctlreg.Registry{}, nil).Verify(url, entry)
+	require.Error(t, err)
+	assert.Equal(t, "Expected transparency log public key to be configured", err.Error())
+}
+
+func testCheckpoint(t *testing.T, signer crypto.Signer, treeSize int64, rootHashHex string) string {
+	rootHash, err := hex.DecodeString(rootHashHex)
+	require.NoError(t, err)
+
+	text := fmt.Sprintf("rekor.example.com - 1\n%d\n%s\n", treeSize, base64.StdEncoding.EncodeToString(rootHash))
+
+	var sig []byte
+	if _, ok := signer.(ed25519.PrivateKey); ok {
+		sig, err = signer.Sign(rand.Reader, []byte(text), crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256([]byte(text))
+		sig, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	require.NoError(t, err)
+	keyHint := sha256.Sum256(der)
+
+	return text + "\n— rekor.example.com " + base64.StdEncoding.EncodeToString(append(keyHint[:4], sig...)) + "\n"
+}
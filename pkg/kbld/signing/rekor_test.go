@@ -0,0 +1,107 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package signing_test
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlsign "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/signing"
+)
+
+func TestVerifyInclusionProof(t *testing.T) {
+	for size := 1; size <= 9; size++ {
+		leaves := testLeaves(size)
+		root := hex.EncodeToString(testTreeHash(leaves))
+
+		for idx := 0; idx < size; idx++ {
+			proof := ctlsign.InclusionProof{
+				Hashes:   testProof(idx, leaves),
+				LogIndex: int64(idx),
+				RootHash: root,
+				TreeSize: int64(size),
+			}
+
+			err := ctlsign.VerifyInclusionProof(leaves[idx], proof)
+			require.NoError(t, err, "size %d, index %d", size, idx)
+		}
+	}
+}
+
+func TestVerifyInclusionProofRejectsInvalidProofs(t *testing.T) {
+	leaves := testLeaves(7)
+	root := hex.EncodeToString(testTreeHash(leaves))
+
+	proof := ctlsign.InclusionProof{
+		Hashes:   testProof(3, leaves),
+		LogIndex: 3,
+		RootHash: root,
+		TreeSize: 7,
+	}
+
+	err := ctlsign.VerifyInclusionProof([]byte("tampered"), proof)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Expected calculated root hash")
+
+	wrongIdxProof := proof
+	wrongIdxProof.LogIndex = 4
+
+	err = ctlsign.VerifyInclusionProof(leaves[3], wrongIdxProof)
+	require.Error(t, err)
+
+	shortProof := proof
+	shortProof.Hashes = proof.Hashes[:1]
+
+	err = ctlsign.VerifyInclusionProof(leaves[3], shortProof)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Expected inclusion proof to have more hashes")
+
+	outOfRangeProof := proof
+	outOfRangeProof.LogIndex = 7
+
+	err = ctlsign.VerifyInclusionProof(leaves[3], outOfRangeProof)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "to be within tree size")
+}
+
+func testLeaves(size int) [][]byte {
+	var leaves [][]byte
+	for i := 0; i < size; i++ {
+		leaves = append(leaves, []byte(fmt.Sprintf("leaf-%d", i)))
+	}
+	return leaves
+}
+
+// testTreeHash implements MTH from RFC 9162 section 2.1.1
+func testTreeHash(leaves [][]byte) []byte {
+	if len(leaves) == 1 {
+		return ctlsign.MerkleLeafHash(leaves[0])
+	}
+	k := testSplit(len(leaves))
+	return ctlsign.MerkleNodeHash(testTreeHash(leaves[:k]), testTreeHash(leaves[k:]))
+}
+
+// testProof implements PATH from RFC 9162 section 2.1.3.1
+func testProof(idx int, leaves [][]byte) []string {
+	if len(leaves) == 1 {
+		return nil
+	}
+	k := testSplit(len(leaves))
+	if idx < k {
+		return append(testProof(idx, leaves[:k]), hex.EncodeToString(testTreeHash(leaves[k:])))
+	}
+	return append(testProof(idx-k, leaves[k:]), hex.EncodeToString(testTreeHash(leaves[:k])))
+}
+
+// testSplit returns largest power of two smaller than n
+func testSplit(n int) int {
+	k := 1
+	for k*2 < n {
+		k *= 2
+	}
+	return k
+}
@@ -0,0 +1,132 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package signing
+
+import (
+	"fmt"
+	"strings"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+)
+
+// Signer signs images and finds resulting signature manifests
+type Signer struct {
+	opts     ctlconf.Signing
+	cosign   Cosign
+	notation Notation
+	registry ctlreg.Registry
+}
+
+func NewSigner(opts ctlconf.Signing, registry ctlreg.Registry, logger ctllog.Logger) Signer {
+	return Signer{opts, NewCosign(logger), NewNotation(logger), registry}
+}
+
+// Signature describes signature manifest (and optionally its transparency log entry)
+type Signature struct {
+	URL                  string
+	TransparencyLogEntry *ctlconf.OriginTransparencyLogEntry
+}
+
+// Sign returns digest reference of signature manifest for given digest url
+func (s Signer) Sign(url string) (Signature, error) {
+	ref, err := regname.NewDigest(url, regname.WeakValidation)
+	if err != nil {
+		return Signature{}, fmt.Errorf("Expected image '%s' to be a digest reference: %s", url, err)
+	}
+
+	if s.opts.Notation != nil {
+		sigURL, err := s.signWithNotation(ref)
+		return Signature{URL: sigURL}, err
+	}
+
+	logIndex, err := s.cosign.Sign(url, s.opts)
+	if err != nil {
+		return Signature{}, err
+	}
+
+	sigRef := SignatureTag(ref)
+
+	desc, err := s.registry.Generic(sigRef)
+	if err != nil {
+		return Signature{}, fmt.Errorf("Getting signature '%s': %s", sigRef.Name(), err)
+	}
+
+	sig := Signature{URL: ref.Context().Digest(desc.Digest.String()).Name()}
+
+	if s.opts.TransparencyLog != nil {
+		if logIndex < 0 {
+			return Signature{}, fmt.Errorf("Expected cosign to record signature of '%s' in transparency log", url)
+		}
+		sig.TransparencyLogEntry = &ctlconf.OriginTransparencyLogEntry{
+			URL:       s.opts.TransparencyLog.URLWithDefaults(),
+			LogIndex:  logIndex,
+			Signature: sig.URL,
+		}
+	}
+
+	return sig, nil
+}
+
+// signWithNotation finds signature as a new referrer of signed image
+func (s Signer) signWithNotation(ref regname.Digest) (string, error) {
+	existingSigs, err := s.notationSignatures(ref)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.notation.Sign(ref.Name(), *s.opts.Notation, s.opts.RawOptions)
+	if err != nil {
+		return "", err
+	}
+
+	sigs, err := s.notationSignatures(ref)
+	if err != nil {
+		return "", err
+	}
+
+	for sig := range sigs {
+		if _, found := existingSigs[sig]; !found {
+			return ref.Context().Digest(sig).Name(), nil
+		}
+	}
+
+	return "", fmt.Errorf("Expected to find new notation signature for '%s'", ref.Name())
+}
+
+func (s Signer) notationSignatures(ref regname.Digest) (map[string]struct{}, error) {
+	descs, err := s.registry.Referrers(ref)
+	if err != nil {
+		return nil, fmt.Errorf("Listing referrers of '%s': %s", ref.Name(), err)
+	}
+
+	result := map[string]struct{}{}
+	for _, desc := range descs {
+		if desc.ArtifactType == NotationSignatureArtifactType {
+			result[desc.Digest.String()] = struct{}{}
+		}
+	}
+	return result, nil
+}
+
+// Attest attaches signed attestation with predicate read from given file
+func (s Signer) Attest(url, predicatePath, predicateType string) error {
+	if s.opts.Notation != nil {
+		return fmt.Errorf("Attaching attestations requires cosign signing configuration")
+	}
+	return s.cosign.Attest(url, predicatePath, predicateType, s.opts)
+}
+
+// SignatureTag returns tag used by cosign to store image signatures
+// (e.g. repo:sha256-<hex>.sig)
+func SignatureTag(ref regname.Digest) regname.Tag {
+	return ref.Context().Tag(TagForDigest(ref.DigestStr(), "sig"))
+}
+
+// TagForDigest returns cosign style tag (e.g. sha256-<hex>.sig)
+func TagForDigest(digest, suffix string) string {
+	return strings.Replace(digest, ":", "-", 1) + "." + suffix
+}
@@ -0,0 +1,312 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package signing_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlreg "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/registry"
+	ctlsign "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/signing"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/testutil"
+)
+
+const (
+	testImageDigest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	testSigManifest = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",` +
+		`"config":{"mediaType":"application/vnd.oci.image.config.v1+json","size":2,` +
+		`"digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"},"layers":[]}`
+)
+
+// newSignatureRegistry serves cosign signature manifest under signature tag of test image
+// (signature exists only after signing, i.e. once signedPath is created by fake CLI)
+func newSignatureRegistry(t *testing.T, signedPath string) (string, ctlreg.Registry) {
+	sigTag := strings.Replace(testImageDigest, ":", "-", 1) + ".sig"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/app/manifests/"+sigTag {
+			if _, err := os.Stat(signedPath); err == nil {
+				w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+				w.Header().Set("Docker-Content-Digest", testDigestOf(testSigManifest))
+				w.Header().Set("Content-Length", fmt.Sprintf("%d", len(testSigManifest)))
+				if r.Method == http.MethodGet {
+					w.Write([]byte(testSigManifest))
+				}
+				return
+			}
+		}
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+
+	registry, err := ctlreg.NewRegistry(ctlreg.Opts{EnvAuthPrefix: "KBLD_TEST_SIGNER", Insecure: true})
+	require.NoError(t, err)
+
+	return strings.TrimPrefix(server.URL, "http://") + "/app", registry
+}
+
+func TestSignerSignsWithCosign(t *testing.T) {
+	argsLog := filepath.Join(t.TempDir(), "args.log")
+
+	testutil.FakeBinaries(t, map[string]string{
+		"cosign": `echo "$@" >> ` + argsLog + `
+echo "tlog entry created with index: 42" >&2
+`,
+	})
+
+	repo, registry := newSignatureRegistry(t, argsLog)
+	url := repo + "@" + testImageDigest
+
+	readArgs := func() string {
+		bs, err := os.ReadFile(argsLog)
+		require.NoError(t, err)
+		require.NoError(t, os.Remove(argsLog))
+		return strings.TrimSpace(string(bs))
+	}
+
+	t.Run("signs with key and returns signature manifest", func(t *testing.T) {
+		rawOpts := []string{"--recursive"}
+		signer := ctlsign.NewSigner(ctlconf.Signing{Key: "cosign.key", RawOptions: &rawOpts},
+			registry, ctllog.NewLogger(&strings.Builder{}))
+
+		sig, err := signer.Sign(url)
+		require.NoError(t, err)
+
+		assert.Equal(t, ctlsign.Signature{URL: repo + "@" + testDigestOf(testSigManifest)}, sig)
+		assert.Equal(t, "sign --yes --key cosign.key --recursive "+url, readArgs())
+	})
+
+	t.Run("records transparency log entry", func(t *testing.T) {
+		signer := ctlsign.NewSigner(ctlconf.Signing{
+			Keyless:         true,
+			TransparencyLog: &ctlconf.SigningTransparencyLog{URL: "https://rekor.corp"},
+		}, registry, ctllog.NewLogger(&strings.Builder{}))
+
+		sig, err := signer.Sign(url)
+		require.NoError(t, err)
+
+		assert.Equal(t, "sign --yes --tlog-upload=true --rekor-url https://rekor.corp "+url, readArgs())
+		assert.Equal(t, &ctlconf.OriginTransparencyLogEntry{
+			URL:       "https://rekor.corp",
+			LogIndex:  42,
+			Signature: repo + "@" + testDigestOf(testSigManifest),
+		}, sig.TransparencyLogEntry)
+	})
+
+	t.Run("attests with predicate", func(t *testing.T) {
+		signer := ctlsign.NewSigner(ctlconf.Signing{Key: "cosign.key"}, registry, ctllog.NewLogger(&strings.Builder{}))
+
+		err := signer.Attest(url, "/tmp/provenance.json", "slsaprovenance")
+		require.NoError(t, err)
+
+		assert.Equal(t, "attest --yes --predicate /tmp/provenance.json --type slsaprovenance --key cosign.key "+url, readArgs())
+	})
+
+	t.Run("fails for tag references", func(t *testing.T) {
+		signer := ctlsign.NewSigner(ctlconf.Signing{Key: "cosign.key"}, registry, ctllog.NewLogger(&strings.Builder{}))
+
+		_, err := signer.Sign(repo + ":latest")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "to be a digest reference")
+	})
+}
+
+func TestSignerFailsWithoutCosignSignature(t *testing.T) {
+	testutil.FakeBinaries(t, map[string]string{
+		"cosign": "exit 0\n",
+	})
+
+	repo, registry := newSignatureRegistry(t, filepath.Join(t.TempDir(), "never-signed"))
+	url := repo + "@" + testImageDigest
+
+	t.Run("fails when signature manifest is missing", func(t *testing.T) {
+		signer := ctlsign.NewSigner(ctlconf.Signing{Key: "cosign.key"}, registry, ctllog.NewLogger(&strings.Builder{}))
+
+		_, err := signer.Sign(url)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Getting signature '"+repo+":sha256-")
+	})
+
+	t.Run("fails when transparency log index is not reported", func(t *testing.T) {
+		signedPath := filepath.Join(t.TempDir(), "signed")
+		require.NoError(t, os.WriteFile(signedPath, nil, 0600))
+
+		repo, registry := newSignatureRegistry(t, signedPath)
+
+		signer := ctlsign.NewSigner(ctlconf.Signing{
+			Keyless:         true,
+			TransparencyLog: &ctlconf.SigningTransparencyLog{},
+		}, registry, ctllog.NewLogger(&strings.Builder{}))
+
+		_, err := signer.Sign(repo + "@" + testImageDigest)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Expected cosign to record signature")
+	})
+}
+
+func TestSignerFailsWhenCosignFails(t *testing.T) {
+	testutil.FakeBinaries(t, map[string]string{
+		"cosign": "echo 'signing failed' >&2\nexit 1\n",
+	})
+
+	repo, registry := newSignatureRegistry(t, filepath.Join(t.TempDir(), "never-signed"))
+	url := repo + "@" + testImageDigest
+
+	var logs strings.Builder
+
+	signer := ctlsign.NewSigner(ctlconf.Signing{Key: "cosign.key"}, registry, ctllog.NewLogger(&logs))
+
+	_, err := signer.Sign(url)
+	require.Error(t, err)
+	assert.Equal(t, fmt.Sprintf("Signing image '%s': exit status 1", url), err.Error())
+	assert.Contains(t, logs.String(), "signing failed")
+}
+
+func TestSignerSignsWithNotation(t *testing.T) {
+	argsLog := filepath.Join(t.TempDir(), "args.log")
+
+	testutil.FakeBinaries(t, map[string]string{
+		"notation": `echo "$@" >> ` + argsLog + "\n",
+	})
+
+	referrer := func(digest int, artifactType string) string {
+		return fmt.Sprintf(`{"mediaType":"application/vnd.oci.image.manifest.v1+json","size":100,`+
+			`"digest":"sha256:%064d","artifactType":"%s"}`, digest, artifactType)
+	}
+
+	// Signing adds referrer next to existing signature and attestation
+	existingReferrers := []string{referrer(1, ctlsign.NotationSignatureArtifactType), referrer(2, "application/vnd.in-toto+json")}
+	newReferrers := []string{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/app/referrers/"+testImageDigest {
+			referrers := existingReferrers
+			if _, err := os.Stat(argsLog); err == nil {
+				referrers = append(append([]string{}, newReferrers...), existingReferrers...)
+			}
+			w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+			w.Write([]byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[` +
+				strings.Join(referrers, ",") + `]}`))
+			return
+		}
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	registry, err := ctlreg.NewRegistry(ctlreg.Opts{EnvAuthPrefix: "KBLD_TEST_SIGNER_NOTATION", Insecure: true})
+	require.NoError(t, err)
+
+	repo := strings.TrimPrefix(server.URL, "http://") + "/app"
+	url := repo + "@" + testImageDigest
+
+	readArgs := func() string {
+		bs, err := os.ReadFile(argsLog)
+		require.NoError(t, err)
+		require.NoError(t, os.Remove(argsLog))
+		return strings.TrimSpace(string(bs))
+	}
+
+	t.Run("returns new signature referrer", func(t *testing.T) {
+		newReferrers = []string{referrer(3, "application/vnd.cncf.notary.other"), referrer(4, ctlsign.NotationSignatureArtifactType)}
+
+		rawOpts := []string{"--signature-format", "cose"}
+		signer := ctlsign.NewSigner(ctlconf.Signing{
+			Notation:   &ctlconf.SigningNotation{Key: "release"},
+			RawOptions: &rawOpts,
+		}, registry, ctllog.NewLogger(&strings.Builder{}))
+
+		sig, err := signer.Sign(url)
+		require.NoError(t, err)
+
+		assert.Equal(t, ctlsign.Signature{URL: fmt.Sprintf("%s@sha256:%064d", repo, 4)}, sig)
+		assert.Equal(t, "sign --key release --signature-format cose "+url, readArgs())
+	})
+
+	t.Run("fails when signing does not add signature referrer", func(t *testing.T) {
+		newReferrers = []string{referrer(3, "application/vnd.cncf.notary.other")}
+
+		signer := ctlsign.NewSigner(ctlconf.Signing{Notation: &ctlconf.SigningNotation{}},
+			registry, ctllog.NewLogger(&strings.Builder{}))
+
+		_, err := signer.Sign(url)
+		require.Error(t, err)
+		assert.Equal(t, "Expected to find new notation signature for '"+url+"'", err.Error())
+		assert.Equal(t, "sign "+url, readArgs())
+	})
+
+	t.Run("does not attest", func(t *testing.T) {
+		signer := ctlsign.NewSigner(ctlconf.Signing{Notation: &ctlconf.SigningNotation{}},
+			registry, ctllog.NewLogger(&strings.Builder{}))
+
+		err := signer.Attest(url, "/tmp/provenance.json", "slsaprovenance")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "requires cosign signing configuration")
+	})
+}
+
+func TestSignerSignsWithKMSKeys(t *testing.T) {
+	argsLog := filepath.Join(t.TempDir(), "args.log")
+
+	testutil.FakeBinaries(t, map[string]string{
+		"cosign":   `echo "$@" >> ` + argsLog + "\n",
+		"notation": `echo "$@" >> ` + argsLog + "\n",
+	})
+
+	repo, registry := newSignatureRegistry(t, argsLog)
+	url := repo + "@" + testImageDigest
+
+	readArgs := func() string {
+		bs, err := os.ReadFile(argsLog)
+		require.NoError(t, err)
+		require.NoError(t, os.Remove(argsLog))
+		return strings.TrimSpace(string(bs))
+	}
+
+	t.Run("passes cosign key URI", func(t *testing.T) {
+		signer := ctlsign.NewSigner(ctlconf.Signing{KMS: "awskms:///alias/kbld"}, registry, ctllog.NewLogger(&strings.Builder{}))
+
+		_, err := signer.Sign(url)
+		require.NoError(t, err)
+		assert.Equal(t, "sign --yes --key awskms:///alias/kbld "+url, readArgs())
+
+		err = signer.Attest(url, "/tmp/sbom.json", "spdxjson")
+		require.NoError(t, err)
+		assert.Equal(t, "attest --yes --predicate /tmp/sbom.json --type spdxjson --key awskms:///alias/kbld "+url, readArgs())
+	})
+
+	t.Run("passes notation plugin with sorted plugin config", func(t *testing.T) {
+		notation := ctlsign.NewNotation(ctllog.NewLogger(&strings.Builder{}))
+
+		err := notation.Sign(url, ctlconf.SigningNotation{KMS: &ctlconf.SigningNotationKMS{
+			Plugin:       "azure-kv",
+			KeyID:        "https://corp.vault.azure.net/keys/kbld/1",
+			PluginConfig: map[string]string{"self_signed": "true", "ca_certs": "/tmp/ca.pem"},
+		}}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "sign --plugin azure-kv --id https://corp.vault.azure.net/keys/kbld/1 "+
+			"--plugin-config ca_certs=/tmp/ca.pem --plugin-config self_signed=true "+url, readArgs())
+	})
+}
+
+func testDigestOf(content string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(content)))
+}
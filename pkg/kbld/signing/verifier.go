@@ -0,0 +1,70 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package signing
+
+import (
+	"fmt"
+
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+)
+
+// Verifier checks that images satisfy verification policies
+type Verifier struct {
+	cosign   Cosign
+	notation Notation
+}
+
+func NewVerifier(logger ctllog.Logger) Verifier {
+	return Verifier{NewCosign(logger), NewNotation(logger)}
+}
+
+// Verify returns description of satisfied policy
+func (v Verifier) Verify(url string, policy ctlconf.VerificationPolicy) (string, error) {
+	switch {
+	case policy.Cosign != nil:
+		err := v.cosign.Verify(url, *policy.Cosign)
+		if err != nil {
+			return "", err
+		}
+		return PolicyDescription(policy), nil
+
+	case policy.Notation != nil:
+		err := v.notation.Verify(url, *policy.Notation)
+		if err != nil {
+			return "", err
+		}
+		return PolicyDescription(policy), nil
+
+	default:
+		return "", fmt.Errorf("Unknown verification policy")
+	}
+}
+
+func PolicyDescription(policy ctlconf.VerificationPolicy) string {
+	switch {
+	case policy.Cosign != nil:
+		opts := policy.Cosign
+		switch {
+		case len(opts.Key) > 0:
+			return fmt.Sprintf("cosign key %s", opts.Key)
+		case len(opts.KMS) > 0:
+			return fmt.Sprintf("cosign key %s", opts.KMS)
+		case opts.Keyless != nil:
+			identity := opts.Keyless.Identity + opts.Keyless.IdentityRegexp
+			issuer := opts.Keyless.Issuer + opts.Keyless.IssuerRegexp
+			return fmt.Sprintf("cosign keyless identity %s issuer %s", identity, issuer)
+		}
+		return "cosign"
+
+	case policy.Notation != nil:
+		if len(policy.Notation.TrustPolicy) > 0 {
+			return fmt.Sprintf("notation trust policy %s", policy.Notation.TrustPolicy)
+		}
+		return "notation"
+
+	default:
+		return "unknown"
+	}
+}
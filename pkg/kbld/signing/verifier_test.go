@@ -0,0 +1,162 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package signing_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctlconf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/config"
+	ctllog "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/logger"
+	ctlsign "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/signing"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/testutil"
+)
+
+func TestVerifierVerifiesWithCosign(t *testing.T) {
+	argsLog := filepath.Join(t.TempDir(), "args.log")
+
+	// Only images in trusted repository are signed
+	testutil.FakeBinaries(t, map[string]string{
+		"cosign": `echo "$@" >> ` + argsLog + `
+for arg in "$@"; do url="$arg"; done
+case "$url" in
+trusted/*) echo '[{"critical":{}}]' ;;
+*) echo "Error: no matching signatures" >&2; exit 1 ;;
+esac
+`,
+	})
+
+	readArgs := func() string {
+		bs, err := os.ReadFile(argsLog)
+		require.NoError(t, err)
+		require.NoError(t, os.Remove(argsLog))
+		return strings.TrimSpace(string(bs))
+	}
+
+	verifier := ctlsign.NewVerifier(ctllog.NewLogger(&strings.Builder{}))
+	url := "trusted/app@" + testImageDigest
+
+	t.Run("verifies with key", func(t *testing.T) {
+		rawOpts := []string{"--insecure-ignore-tlog"}
+		policy := ctlconf.VerificationPolicy{Cosign: &ctlconf.VerificationPolicyCosign{
+			Key: "cosign.pub", RawOptions: &rawOpts}}
+
+		desc, err := verifier.Verify(url, policy)
+		require.NoError(t, err)
+
+		assert.Equal(t, "cosign key cosign.pub", desc)
+		assert.Equal(t, "verify --key cosign.pub --insecure-ignore-tlog "+url, readArgs())
+	})
+
+	t.Run("verifies keyless identity", func(t *testing.T) {
+		policy := ctlconf.VerificationPolicy{Cosign: &ctlconf.VerificationPolicyCosign{
+			Keyless: &ctlconf.VerificationPolicyKeyless{
+				IdentityRegexp: "^https://github.com/corp/",
+				Issuer:         "https://token.actions.githubusercontent.com",
+			},
+		}}
+
+		desc, err := verifier.Verify(url, policy)
+		require.NoError(t, err)
+
+		assert.Equal(t, "cosign keyless identity ^https://github.com/corp/ issuer https://token.actions.githubusercontent.com", desc)
+		assert.Equal(t, "verify --certificate-identity-regexp ^https://github.com/corp/ "+
+			"--certificate-oidc-issuer https://token.actions.githubusercontent.com "+url, readArgs())
+	})
+
+	t.Run("verifies with KMS key", func(t *testing.T) {
+		policy := ctlconf.VerificationPolicy{Cosign: &ctlconf.VerificationPolicyCosign{KMS: "hashivault://kbld"}}
+
+		desc, err := verifier.Verify(url, policy)
+		require.NoError(t, err)
+
+		assert.Equal(t, "cosign key hashivault://kbld", desc)
+		assert.Equal(t, "verify --key hashivault://kbld "+url, readArgs())
+	})
+
+	t.Run("fails with cosign output", func(t *testing.T) {
+		policy := ctlconf.VerificationPolicy{Cosign: &ctlconf.VerificationPolicyCosign{Key: "cosign.pub"}}
+
+		_, err := verifier.Verify("untrusted/app@"+testImageDigest, policy)
+		require.Error(t, err)
+		assert.Equal(t, "Verifying signature of image 'untrusted/app@"+testImageDigest+
+			"': exit status 1 (output: Error: no matching signatures)", err.Error())
+	})
+
+	t.Run("fails for unknown policy", func(t *testing.T) {
+		_, err := verifier.Verify(url, ctlconf.VerificationPolicy{})
+		require.Error(t, err)
+		assert.Equal(t, "Unknown verification policy", err.Error())
+	})
+}
+
+func TestVerifierVerifiesWithNotation(t *testing.T) {
+	argsLog := filepath.Join(t.TempDir(), "args.log")
+
+	// Configuration directory is recorded to check prepared trust policy and store
+	testutil.FakeBinaries(t, map[string]string{
+		"notation": `echo "$@" >> ` + argsLog + `
+if [ -n "$XDG_CONFIG_HOME" ]; then
+  cat "$XDG_CONFIG_HOME/notation/trustpolicy.json" >> ` + argsLog + `
+  echo >> ` + argsLog + `
+  ls "$XDG_CONFIG_HOME/notation/truststore/x509/ca/corp" >> ` + argsLog + `
+fi
+`,
+	})
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	readArgs := func() string {
+		bs, err := os.ReadFile(argsLog)
+		require.NoError(t, err)
+		require.NoError(t, os.Remove(argsLog))
+		return strings.TrimSpace(string(bs))
+	}
+
+	verifier := ctlsign.NewVerifier(ctllog.NewLogger(&strings.Builder{}))
+	url := "trusted/app@" + testImageDigest
+
+	t.Run("uses notation configuration by default", func(t *testing.T) {
+		rawOpts := []string{"--allow-referrers-api"}
+		policy := ctlconf.VerificationPolicy{Notation: &ctlconf.VerificationPolicyNotation{RawOptions: &rawOpts}}
+
+		desc, err := verifier.Verify(url, policy)
+		require.NoError(t, err)
+
+		assert.Equal(t, "notation", desc)
+		assert.Equal(t, "verify --allow-referrers-api "+url, readArgs())
+	})
+
+	t.Run("uses given trust policy and store", func(t *testing.T) {
+		trustPolicy := filepath.Join(t.TempDir(), "trustpolicy.json")
+		require.NoError(t, os.WriteFile(trustPolicy, []byte(`{"version":"1.0"}`), 0600))
+
+		trustStore := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(trustStore, "x509", "ca", "corp"), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(trustStore, "x509", "ca", "corp", "root.crt"), nil, 0600))
+
+		policy := ctlconf.VerificationPolicy{Notation: &ctlconf.VerificationPolicyNotation{
+			TrustPolicy: trustPolicy, TrustStore: trustStore}}
+
+		desc, err := verifier.Verify(url, policy)
+		require.NoError(t, err)
+
+		assert.Equal(t, "notation trust policy "+trustPolicy, desc)
+		assert.Equal(t, "verify "+url+"\n"+`{"version":"1.0"}`+"\nroot.crt", readArgs())
+	})
+
+	t.Run("fails when trust policy is missing", func(t *testing.T) {
+		policy := ctlconf.VerificationPolicy{Notation: &ctlconf.VerificationPolicyNotation{
+			TrustPolicy: filepath.Join(t.TempDir(), "missing.json")}}
+
+		_, err := verifier.Verify(url, policy)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Reading notation trust policy")
+	})
+}
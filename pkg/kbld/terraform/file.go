@@ -0,0 +1,244 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package terraform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	kubernetesManifestType = "kubernetes_manifest"
+	helmReleaseType        = "helm_release"
+)
+
+// File is Terraform JSON: either configuration (.tf.json)
+// or plan (output of 'terraform show -json')
+type File struct {
+	contents map[string]interface{}
+}
+
+// Document is part of Terraform JSON that may contain image references:
+// manifest of kubernetes_manifest, helm_release values document or
+// helm_release 'set' value for key ending with 'image' (as {image: value})
+type Document struct {
+	// Address identifies resource that document belongs to (e.g. kubernetes_manifest.app)
+	Address  string
+	Contents map[string]interface{}
+
+	write func() error
+}
+
+func NewFileFromBytes(bs []byte) (*File, error) {
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	// Keep numbers as they were specified
+	dec.UseNumber()
+
+	var contents map[string]interface{}
+
+	err := dec.Decode(&contents)
+	if err != nil {
+		return nil, fmt.Errorf("Parsing Terraform JSON: %s", err)
+	}
+
+	return &File{contents}, nil
+}
+
+// Documents returns documents found in resources of configuration
+// and in planned values and resource changes of plan
+func (f *File) Documents() ([]Document, error) {
+	var docs []Document
+
+	// Configuration: {"resource": {"<type>": {"<name>": {...}}}}
+	// (blocks may also be specified as lists of objects)
+	for _, typesObj := range objectsOf(f.contents["resource"]) {
+		for resType, namesVal := range typesObj {
+			for _, namesObj := range objectsOf(namesVal) {
+				for name, attrsVal := range namesObj {
+					for _, attrs := range objectsOf(attrsVal) {
+						resDocs, err := documentsOf(resType+"."+name, resType, attrs)
+						if err != nil {
+							return nil, err
+						}
+						docs = append(docs, resDocs...)
+					}
+				}
+			}
+		}
+	}
+
+	// Plan: {"planned_values": {"root_module": {"resources": [...], "child_modules": [...]}}}
+	if plannedVals, ok := f.contents["planned_values"].(map[string]interface{}); ok {
+		moduleDocs, err := moduleDocuments(plannedVals["root_module"])
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, moduleDocs...)
+	}
+
+	// Plan: {"resource_changes": [{"address": ..., "type": ..., "change": {"after": {...}}}]}
+	for _, change := range objectsOf(f.contents["resource_changes"]) {
+		changeObj, ok := change["change"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		after, ok := changeObj["after"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		address, _ := change["address"].(string)
+		resType, _ := change["type"].(string)
+
+		resDocs, err := documentsOf(address, resType, after)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, resDocs...)
+	}
+
+	return docs, nil
+}
+
+// Bytes returns indented JSON including updates written by documents
+func (f *File) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+
+	err := enc.Encode(f.contents)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Write stores updated contents back into Terraform JSON
+func (d Document) Write() error { return d.write() }
+
+func moduleDocuments(moduleVal interface{}) ([]Document, error) {
+	module, ok := moduleVal.(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	var docs []Document
+
+	for _, res := range objectsOf(module["resources"]) {
+		values, ok := res["values"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		address, _ := res["address"].(string)
+		resType, _ := res["type"].(string)
+
+		resDocs, err := documentsOf(address, resType, values)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, resDocs...)
+	}
+
+	for _, childModule := range objectsOf(module["child_modules"]) {
+		childDocs, err := moduleDocuments(childModule)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, childDocs...)
+	}
+
+	return docs, nil
+}
+
+func documentsOf(address, resType string, attrs map[string]interface{}) ([]Document, error) {
+	switch resType {
+	case kubernetesManifestType:
+		manifest, ok := attrs["manifest"].(map[string]interface{})
+		if !ok {
+			// Manifest may be unknown in plan or an expression in configuration
+			return nil, nil
+		}
+		return []Document{{Address: address, Contents: manifest, write: func() error { return nil }}}, nil
+
+	case helmReleaseType:
+		var docs []Document
+
+		valuesList, _ := attrs["values"].([]interface{})
+		for i, valuesVal := range valuesList {
+			valuesStr, ok := valuesVal.(string)
+			if !ok {
+				continue
+			}
+			// Values may contain interpolations that are not YAML
+			var values map[string]interface{}
+			if err := yaml.Unmarshal([]byte(valuesStr), &values); err != nil || values == nil {
+				continue
+			}
+
+			i := i
+			docs = append(docs, Document{
+				Address:  address,
+				Contents: values,
+				write: func() error {
+					bs, err := yaml.Marshal(values)
+					if err != nil {
+						return fmt.Errorf("Encoding values of %s: %s", address, err)
+					}
+					valuesList[i] = string(bs)
+					return nil
+				},
+			})
+		}
+
+		for _, set := range objectsOf(attrs["set"]) {
+			name, _ := set["name"].(string)
+			value, ok := set["value"].(string)
+			if !ok || !strings.EqualFold(path.Ext("."+name), ".image") {
+				continue
+			}
+
+			set := set
+			contents := map[string]interface{}{"image": value}
+
+			docs = append(docs, Document{
+				Address:  address,
+				Contents: contents,
+				write: func() error {
+					set["value"] = contents["image"]
+					return nil
+				},
+			})
+		}
+
+		return docs, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+// objectsOf returns given object or objects within given list
+func objectsOf(val interface{}) []map[string]interface{} {
+	switch typedVal := val.(type) {
+	case map[string]interface{}:
+		return []map[string]interface{}{typedVal}
+	case []interface{}:
+		var result []map[string]interface{}
+		for _, item := range typedVal {
+			if obj, ok := item.(map[string]interface{}); ok {
+				result = append(result, obj)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
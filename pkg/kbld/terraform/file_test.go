@@ -0,0 +1,154 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package terraform_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctltf "github.com/vmware-tanzu/carvel-kbld/pkg/kbld/terraform"
+)
+
+func TestFileDocumentsConfiguration(t *testing.T) {
+	tfFile, err := ctltf.NewFileFromBytes([]byte(`{
+  "resource": {
+    "kubernetes_manifest": {
+      "app": {
+        "manifest": {"kind": "Pod", "spec": {"containers": [{"image": "nginx:1.25"}]}}
+      },
+      "dynamic": {
+        "manifest": "${yamldecode(file(\"app.yml\"))}"
+      }
+    },
+    "helm_release": [{
+      "redis": {
+        "replicas": 3,
+        "values": ["image: redis:7\n", "${var.values}"],
+        "set": [
+          {"name": "sidecar.image", "value": "busybox:1"},
+          {"name": "replicas", "value": "2"}
+        ]
+      }
+    }],
+    "null_resource": {"other": {"image": "ignored:1"}}
+  }
+}`))
+	require.NoError(t, err)
+
+	docs, err := tfFile.Documents()
+	require.NoError(t, err)
+	require.Len(t, docs, 3)
+
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Address < docs[j].Address })
+
+	assert.Equal(t, "helm_release.redis", docs[0].Address)
+	assert.Equal(t, "helm_release.redis", docs[1].Address)
+	assert.Equal(t, "kubernetes_manifest.app", docs[2].Address)
+
+	for _, doc := range docs {
+		switch {
+		case doc.Contents["image"] == "redis:7":
+			doc.Contents["image"] = "redis@sha256:1"
+		case doc.Contents["image"] == "busybox:1":
+			doc.Contents["image"] = "busybox@sha256:2"
+		default:
+			doc.Contents["spec"].(map[string]interface{})["containers"].([]interface{})[0].(map[string]interface{})["image"] = "nginx@sha256:3"
+		}
+		require.NoError(t, doc.Write())
+	}
+
+	bs, err := tfFile.Bytes()
+	require.NoError(t, err)
+
+	assert.Equal(t, `{
+  "resource": {
+    "helm_release": [
+      {
+        "redis": {
+          "replicas": 3,
+          "set": [
+            {
+              "name": "sidecar.image",
+              "value": "busybox@sha256:2"
+            },
+            {
+              "name": "replicas",
+              "value": "2"
+            }
+          ],
+          "values": [
+            "image: redis@sha256:1\n",
+            "${var.values}"
+          ]
+        }
+      }
+    ],
+    "kubernetes_manifest": {
+      "app": {
+        "manifest": {
+          "kind": "Pod",
+          "spec": {
+            "containers": [
+              {
+                "image": "nginx@sha256:3"
+              }
+            ]
+          }
+        }
+      },
+      "dynamic": {
+        "manifest": "${yamldecode(file(\"app.yml\"))}"
+      }
+    },
+    "null_resource": {
+      "other": {
+        "image": "ignored:1"
+      }
+    }
+  }
+}
+`, string(bs))
+}
+
+func TestFileDocumentsPlan(t *testing.T) {
+	tfFile, err := ctltf.NewFileFromBytes([]byte(`{
+  "format_version": "1.2",
+  "planned_values": {
+    "root_module": {
+      "resources": [
+        {"address": "kubernetes_manifest.app", "type": "kubernetes_manifest", "values": {"manifest": {"kind": "Pod"}}}
+      ],
+      "child_modules": [{
+        "resources": [
+          {"address": "module.db.helm_release.pg", "type": "helm_release", "values": {"values": ["image: postgres:16\n"]}}
+        ]
+      }]
+    }
+  },
+  "resource_changes": [
+    {"address": "kubernetes_manifest.app", "type": "kubernetes_manifest", "change": {"after": {"manifest": {"kind": "Pod"}}}},
+    {"address": "kubernetes_manifest.gone", "type": "kubernetes_manifest", "change": {"after": null}}
+  ]
+}`))
+	require.NoError(t, err)
+
+	docs, err := tfFile.Documents()
+	require.NoError(t, err)
+
+	var addresses []string
+	for _, doc := range docs {
+		addresses = append(addresses, doc.Address)
+	}
+
+	assert.Equal(t, []string{"kubernetes_manifest.app", "module.db.helm_release.pg", "kubernetes_manifest.app"}, addresses)
+	assert.Equal(t, "postgres:16", docs[1].Contents["image"])
+}
+
+func TestNewFileFromBytesInvalid(t *testing.T) {
+	_, err := ctltf.NewFileFromBytes([]byte(`resource "kubernetes_manifest" "app" {}`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Parsing Terraform JSON: ")
+}
@@ -0,0 +1,31 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package testutil provides helpers shared by tests of several packages
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// FakeBinaries writes given shell scripts (keyed by binary name) into temporary
+// directory and prepends it to PATH for the duration of the test.
+// Scripts are run by /bin/sh hence they should not include shebang line.
+func FakeBinaries(t *testing.T, scripts map[string]string) string {
+	t.Helper()
+
+	binDir := t.TempDir()
+
+	for name, script := range scripts {
+		err := os.WriteFile(filepath.Join(binDir, name), []byte("#!/bin/sh\n"+script), 0700)
+		if err != nil {
+			t.Fatalf("Writing fake binary '%s': %s", name, err)
+		}
+	}
+
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	return binDir
+}
@@ -0,0 +1,437 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Spans are exported in batches of this size while run is in progress
+	exportBatchSize = 512
+)
+
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindClient   SpanKind = 3
+)
+
+// Tracer records spans and exports them via OTLP/HTTP (JSON encoding).
+// All methods are safe to call on nil Tracer (tracing is disabled).
+type Tracer struct {
+	endpoint    string
+	headers     map[string]string
+	serviceName string
+	client      *http.Client
+	now         func() time.Time
+
+	root *Span
+
+	ended     []*Span
+	endedLock sync.Mutex
+
+	// exportWg tracks batches exported in background
+	exportWg sync.WaitGroup
+}
+
+// Span represents single timed operation. All methods
+// are safe to call on nil Span.
+type Span struct {
+	tracer   *Tracer
+	kind     SpanKind
+	name     string
+	traceID  string
+	spanID   string
+	parentID string
+	start    time.Time
+	end      time.Time
+	attrs    map[string]interface{}
+	err      error
+	attrLock sync.Mutex
+}
+
+// NewTracerFromEnv configures tracer based on standard OpenTelemetry
+// environment variables (OTEL_EXPORTER_OTLP_ENDPOINT, OTEL_EXPORTER_OTLP_TRACES_ENDPOINT,
+// OTEL_EXPORTER_OTLP_HEADERS, OTEL_SERVICE_NAME, OTEL_SDK_DISABLED).
+// Returns nil when exporter is not configured. Root span joins
+// trace specified via TRACEPARENT (if any).
+func NewTracerFromEnv(rootName string) (*Tracer, error) {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return nil, nil
+	}
+
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if len(endpoint) == 0 {
+		baseEndpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if len(baseEndpoint) == 0 {
+			return nil, nil
+		}
+		endpoint = strings.TrimSuffix(baseEndpoint, "/") + "/v1/traces"
+	}
+
+	headers, err := parseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	if err != nil {
+		return nil, fmt.Errorf("Parsing OTEL_EXPORTER_OTLP_HEADERS: %s", err)
+	}
+
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if len(serviceName) == 0 {
+		serviceName = "kbld"
+	}
+
+	return NewTracer(endpoint, headers, serviceName, rootName, os.Getenv("TRACEPARENT"))
+}
+
+// NewTracer starts root span with given name. When traceParent
+// (W3C trace context format) is given, root span becomes its child.
+func NewTracer(endpoint string, headers map[string]string,
+	serviceName, rootName, traceParent string) (*Tracer, error) {
+
+	tracer := &Tracer{
+		endpoint:    endpoint,
+		headers:     headers,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		now:         time.Now,
+	}
+
+	traceID, parentID := newID(16), ""
+
+	if len(traceParent) > 0 {
+		parts := strings.Split(traceParent, "-")
+		if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+			return nil, fmt.Errorf("Expected TRACEPARENT '%s' to be in format 'version-traceid-parentid-flags'", traceParent)
+		}
+		traceID, parentID = parts[1], parts[2]
+	}
+
+	tracer.root = tracer.newSpan(rootName, SpanKindInternal, traceID, parentID, nil)
+
+	return tracer, nil
+}
+
+// Start creates child span of given parent (or root span when parent is nil)
+func (t *Tracer) Start(parent *Span, name string, kind SpanKind, attrs map[string]interface{}) *Span {
+	if t == nil {
+		return nil
+	}
+	if parent == nil {
+		parent = t.root
+	}
+	return t.newSpan(name, kind, parent.traceID, parent.spanID, attrs)
+}
+
+// Root returns span representing whole run
+func (t *Tracer) Root() *Span {
+	if t == nil {
+		return nil
+	}
+	return t.root
+}
+
+// Shutdown ends root span and exports all remaining spans
+// (waits for batches that are being exported in background)
+func (t *Tracer) Shutdown(err error) error {
+	if t == nil {
+		return nil
+	}
+	t.root.End(err)
+	t.exportWg.Wait()
+	return t.export(t.takeEnded())
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpan returns context that makes span parent of spans
+// started via StartFromContext (e.g. for registry requests of an image)
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	if span == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// StartFromContext creates child span of span recorded in context (or root span)
+func (t *Tracer) StartFromContext(ctx context.Context, name string, kind SpanKind, attrs map[string]interface{}) *Span {
+	parent, _ := ctx.Value(spanContextKey{}).(*Span)
+	return t.Start(parent, name, kind, attrs)
+}
+
+func (t *Tracer) newSpan(name string, kind SpanKind, traceID, parentID string, attrs map[string]interface{}) *Span {
+	allAttrs := map[string]interface{}{}
+	for k, v := range attrs {
+		allAttrs[k] = v
+	}
+	return &Span{
+		tracer:   t,
+		kind:     kind,
+		name:     name,
+		traceID:  traceID,
+		spanID:   newID(8),
+		parentID: parentID,
+		start:    t.now(),
+		attrs:    allAttrs,
+	}
+}
+
+func (t *Tracer) spanEnded(span *Span) {
+	t.endedLock.Lock()
+	t.ended = append(t.ended, span)
+	var spans []*Span
+	if len(t.ended) >= exportBatchSize {
+		spans = t.ended
+		t.ended = nil
+	}
+	t.endedLock.Unlock()
+
+	if len(spans) > 0 {
+		// Export in background so that ending span does not wait
+		// for exporter. Intermediate export failures are not fatal;
+		// unexported spans are dropped
+		t.exportWg.Add(1)
+		go func() {
+			defer t.exportWg.Done()
+			_ = t.export(spans)
+		}()
+	}
+}
+
+func (t *Tracer) takeEnded() []*Span {
+	t.endedLock.Lock()
+	defer t.endedLock.Unlock()
+
+	spans := t.ended
+	t.ended = nil
+	return spans
+}
+
+func (t *Tracer) export(spans []*Span) error {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(t.request(spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Exporting traces: %s", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for name, val := range t.headers {
+		req.Header.Set(name, val)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Exporting traces: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Expected exporting traces to succeed, but got status '%s': %s", resp.Status, respBody)
+	}
+
+	return nil
+}
+
+// SetAttr records additional attribute on the span
+func (s *Span) SetAttr(key string, val interface{}) {
+	if s == nil {
+		return
+	}
+	s.attrLock.Lock()
+	defer s.attrLock.Unlock()
+
+	s.attrs[key] = val
+}
+
+// End marks span as finished (and failed when error is given)
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.attrLock.Lock()
+	if !s.end.IsZero() {
+		s.attrLock.Unlock()
+		return
+	}
+	s.end = s.tracer.now()
+	s.err = err
+	s.attrLock.Unlock()
+
+	s.tracer.spanEnded(s)
+}
+
+func parseHeaders(str string) (map[string]string, error) {
+	headers := map[string]string{}
+
+	for _, pair := range strings.Split(str, ",") {
+		if len(strings.TrimSpace(pair)) == 0 {
+			continue
+		}
+		pieces := strings.SplitN(pair, "=", 2)
+		if len(pieces) != 2 {
+			return nil, fmt.Errorf("Expected header '%s' to be in format 'key=value'", pair)
+		}
+		val, err := url.QueryUnescape(strings.TrimSpace(pieces[1]))
+		if err != nil {
+			return nil, err
+		}
+		headers[strings.TrimSpace(pieces[0])] = val
+	}
+
+	return headers, nil
+}
+
+func newID(size int) string {
+	bs := make([]byte, size)
+	_, err := rand.Read(bs)
+	if err != nil {
+		panic(fmt.Sprintf("Generating trace id: %s", err))
+	}
+	return hex.EncodeToString(bs)
+}
+
+// OTLP JSON encoding (https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding)
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+func (t *Tracer) request(spans []*Span) otlpRequest {
+	var otlpSpans []otlpSpan
+
+	for _, span := range spans {
+		span.attrLock.Lock()
+
+		otlpSpan := otlpSpan{
+			TraceID:           span.traceID,
+			SpanID:            span.spanID,
+			ParentSpanID:      span.parentID,
+			Name:              span.name,
+			Kind:              span.kind,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.attrs),
+			Status:            otlpStatus{Code: 1},
+		}
+		if span.err != nil {
+			otlpSpan.Status = otlpStatus{Code: 2, Message: span.err.Error()}
+		}
+
+		span.attrLock.Unlock()
+
+		otlpSpans = append(otlpSpans, otlpSpan)
+	}
+
+	return otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: otlpAttributes(map[string]interface{}{"service.name": t.serviceName}),
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "github.com/vmware-tanzu/carvel-kbld"},
+				Spans: otlpSpans,
+			}},
+		}},
+	}
+}
+
+func otlpAttributes(attrs map[string]interface{}) []otlpKeyValue {
+	var result []otlpKeyValue
+
+	for key, val := range attrs {
+		var otlpVal otlpValue
+
+		switch typedVal := val.(type) {
+		case int:
+			str := strconv.Itoa(typedVal)
+			otlpVal.IntValue = &str
+		case int64:
+			str := strconv.FormatInt(typedVal, 10)
+			otlpVal.IntValue = &str
+		case bool:
+			otlpVal.BoolValue = &typedVal
+		default:
+			str := fmt.Sprintf("%v", typedVal)
+			otlpVal.StringValue = &str
+		}
+
+		result = append(result, otlpKeyValue{Key: key, Value: otlpVal})
+	}
+
+	// Keep attributes in a stable order
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+
+	return result
+}
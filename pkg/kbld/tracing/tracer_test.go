@@ -0,0 +1,174 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package tracing_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/tracing"
+)
+
+type exportedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Kind         int    `json:"kind"`
+	Attributes   []struct {
+		Key   string `json:"key"`
+		Value struct {
+			StringValue string `json:"stringValue"`
+			IntValue    string `json:"intValue"`
+		} `json:"value"`
+	} `json:"attributes"`
+	Status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+}
+
+func TestTracerExportsSpans(t *testing.T) {
+	var received []exportedSpan
+	var authHeader string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		authHeader = r.Header.Get("Authorization")
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []exportedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		require.NoError(t, json.Unmarshal(body, &req))
+
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				received = append(received, ss.Spans...)
+			}
+		}
+	}))
+	defer server.Close()
+
+	traceParent := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+	tracer, err := tracing.NewTracer(server.URL+"/v1/traces",
+		map[string]string{"Authorization": "Bearer token"}, "kbld", "kbld resolve", traceParent)
+	require.NoError(t, err)
+
+	imgSpan := tracer.Start(nil, "image resolving", tracing.SpanKindInternal,
+		map[string]interface{}{"kbld.image.url": "nginx"})
+	reqSpan := tracer.Start(imgSpan, "registry GET", tracing.SpanKindClient, nil)
+	reqSpan.SetAttr("http.response.status_code", 404)
+	reqSpan.End(fmt.Errorf("not found"))
+	imgSpan.End(nil)
+
+	require.NoError(t, tracer.Shutdown(nil))
+
+	assert.Equal(t, "Bearer token", authHeader)
+	require.Len(t, received, 3)
+
+	reqExported, imgExported, rootExported := received[0], received[1], received[2]
+
+	assert.Equal(t, "kbld resolve", rootExported.Name)
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", rootExported.TraceID)
+	assert.Equal(t, "b7ad6b7169203331", rootExported.ParentSpanID)
+	assert.Equal(t, 1, rootExported.Status.Code)
+
+	assert.Equal(t, "image resolving", imgExported.Name)
+	assert.Equal(t, rootExported.SpanID, imgExported.ParentSpanID)
+	assert.Equal(t, "kbld.image.url", imgExported.Attributes[0].Key)
+	assert.Equal(t, "nginx", imgExported.Attributes[0].Value.StringValue)
+
+	assert.Equal(t, "registry GET", reqExported.Name)
+	assert.Equal(t, imgExported.SpanID, reqExported.ParentSpanID)
+	assert.Equal(t, rootExported.TraceID, reqExported.TraceID)
+	assert.Equal(t, 3, reqExported.Kind)
+	assert.Equal(t, "404", reqExported.Attributes[0].Value.IntValue)
+	assert.Equal(t, 2, reqExported.Status.Code)
+	assert.Equal(t, "not found", reqExported.Status.Message)
+}
+
+func TestTracerExportsFullBatchesInBackground(t *testing.T) {
+	var exportedBatches []int
+	var exportedLock sync.Mutex
+	releaseCh := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-releaseCh
+
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []exportedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		exportedLock.Lock()
+		exportedBatches = append(exportedBatches, len(req.ResourceSpans[0].ScopeSpans[0].Spans))
+		exportedLock.Unlock()
+	}))
+	defer server.Close()
+
+	tracer, err := tracing.NewTracer(server.URL, nil, "kbld", "kbld resolve", "")
+	require.NoError(t, err)
+
+	endedCh := make(chan struct{})
+	go func() {
+		for i := 0; i < 600; i++ {
+			tracer.Start(nil, "registry GET", tracing.SpanKindClient, nil).End(nil)
+		}
+		close(endedCh)
+	}()
+
+	// Ending spans does not wait for exporter
+	select {
+	case <-endedCh:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Expected ending spans not to wait for export")
+	}
+
+	close(releaseCh)
+
+	require.NoError(t, tracer.Shutdown(nil))
+	assert.Equal(t, []int{512, 89}, exportedBatches)
+}
+
+func TestTracerDisabled(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+
+	tracer, err := tracing.NewTracerFromEnv("kbld resolve")
+	require.NoError(t, err)
+	require.Nil(t, tracer)
+
+	// Nil tracer and spans are no-ops
+	span := tracer.Start(nil, "image resolving", tracing.SpanKindInternal, nil)
+	span.SetAttr("key", "val")
+	span.End(nil)
+	require.NoError(t, tracer.Shutdown(nil))
+}
+
+func TestTracerInvalidTraceParent(t *testing.T) {
+	_, err := tracing.NewTracer("http://localhost", nil, "kbld", "kbld resolve", "invalid")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Expected TRACEPARENT 'invalid' to be in format")
+}
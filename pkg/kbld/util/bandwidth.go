@@ -0,0 +1,129 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package util
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	bandwidthRegexp = regexp.MustCompile(`\A([0-9]+(?:\.[0-9]+)?)\s*([a-zA-Z]*)(?:/s)?\z`)
+
+	bandwidthUnits = map[string]float64{
+		"":    1,
+		"b":   1,
+		"kb":  1000,
+		"kib": 1024,
+		"mb":  1000 * 1000,
+		"mib": 1024 * 1024,
+		"gb":  1000 * 1000 * 1000,
+		"gib": 1024 * 1024 * 1024,
+	}
+)
+
+// ParseBandwidth parses bandwidth strings such as "50MiB/s", "500KB" or "1024"
+// into number of bytes per second.
+func ParseBandwidth(str string) (int64, error) {
+	matches := bandwidthRegexp.FindStringSubmatch(strings.TrimSpace(str))
+	if len(matches) != 3 {
+		return 0, fmt.Errorf("Parsing bandwidth '%s': expected format <number>[B|KB|KiB|MB|MiB|GB|GiB][/s]", str)
+	}
+
+	num, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("Parsing bandwidth '%s': %s", str, err)
+	}
+
+	multiplier, found := bandwidthUnits[strings.ToLower(matches[2])]
+	if !found {
+		return 0, fmt.Errorf("Parsing bandwidth '%s': unknown unit '%s'", str, matches[2])
+	}
+
+	result := int64(num * multiplier)
+	if result < 1 {
+		return 0, fmt.Errorf("Parsing bandwidth '%s': expected to be at least 1 byte per second", str)
+	}
+
+	return result, nil
+}
+
+// RateLimiter is a token bucket shared by all readers
+// that need to stay under a combined number of bytes per second.
+type RateLimiter struct {
+	bytesPerSec int64
+
+	lock      sync.Mutex
+	available float64
+	last      time.Time
+}
+
+func NewRateLimiter(bytesPerSec int64) *RateLimiter {
+	if bytesPerSec < 1 {
+		panic(fmt.Sprintf("Expected bytes per second to be >= 1, but was %d", bytesPerSec))
+	}
+	return &RateLimiter{bytesPerSec: bytesPerSec, available: float64(bytesPerSec), last: time.Now()}
+}
+
+// MaxChunk returns largest number of bytes that should be requested at once
+func (l *RateLimiter) MaxChunk() int {
+	// Allow ~100ms worth of data per chunk so that transfers stay smooth
+	chunk := l.bytesPerSec / 10
+	if chunk < 1 {
+		return 1
+	}
+	return int(chunk)
+}
+
+// WaitN blocks until n bytes may be transferred
+func (l *RateLimiter) WaitN(n int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	l.available += now.Sub(l.last).Seconds() * float64(l.bytesPerSec)
+	if l.available > float64(l.bytesPerSec) {
+		l.available = float64(l.bytesPerSec)
+	}
+	l.last = now
+
+	l.available -= float64(n)
+
+	if l.available < 0 {
+		// Holding the lock while sleeping makes other readers wait their turn
+		wait := time.Duration(-l.available / float64(l.bytesPerSec) * float64(time.Second))
+		time.Sleep(wait)
+		l.last = time.Now()
+		l.available = 0
+	}
+}
+
+type rateLimitedReadCloser struct {
+	rc      io.ReadCloser
+	limiter *RateLimiter
+}
+
+var _ io.ReadCloser = rateLimitedReadCloser{}
+
+func NewRateLimitedReadCloser(rc io.ReadCloser, limiter *RateLimiter) io.ReadCloser {
+	return rateLimitedReadCloser{rc, limiter}
+}
+
+func (r rateLimitedReadCloser) Read(p []byte) (int, error) {
+	if max := r.limiter.MaxChunk(); len(p) > max {
+		p = p[:max]
+	}
+	n, err := r.rc.Read(p)
+	if n > 0 {
+		r.limiter.WaitN(n)
+	}
+	return n, err
+}
+
+func (r rateLimitedReadCloser) Close() error { return r.rc.Close() }
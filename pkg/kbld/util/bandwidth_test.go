@@ -0,0 +1,102 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package util_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+func TestParseBandwidth(t *testing.T) {
+	type test struct {
+		Input  string
+		Output int64
+		Error  error
+	}
+
+	exs := []test{
+		{Input: "1024", Output: 1024},
+		{Input: "10B/s", Output: 10},
+		{Input: "50MiB/s", Output: 50 * 1024 * 1024},
+		{Input: "50MB/s", Output: 50 * 1000 * 1000},
+		{Input: "1.5KiB", Output: 1536},
+		{Input: "2gib/s", Output: 2 * 1024 * 1024 * 1024},
+		{Input: "", Error: fmt.Errorf("Parsing bandwidth '': expected format <number>[B|KB|KiB|MB|MiB|GB|GiB][/s]")},
+		{Input: "10XB/s", Error: fmt.Errorf("Parsing bandwidth '10XB/s': unknown unit 'XB'")},
+		{Input: "0", Error: fmt.Errorf("Parsing bandwidth '0': expected to be at least 1 byte per second")},
+	}
+
+	for _, ex := range exs {
+		t.Run(fmt.Sprintf("parsing '%s'", ex.Input), func(t *testing.T) {
+			out, err := util.ParseBandwidth(ex.Input)
+			assert.Equal(t, ex.Error, err)
+			assert.Equal(t, ex.Output, out)
+		})
+	}
+}
+
+func TestRateLimitedReadCloserThroughput(t *testing.T) {
+	const bytesPerSec = 1024 * 1024
+
+	limiter := util.NewRateLimiter(bytesPerSec)
+
+	// Bucket starts full hence first second worth of data is not delayed
+	// and remaining data is expected to take at least half a second
+	data := bytes.Repeat([]byte("a"), bytesPerSec*3/2)
+
+	started := time.Now()
+
+	read, err := io.ReadAll(util.NewRateLimitedReadCloser(io.NopCloser(bytes.NewReader(data)), limiter))
+	require.NoError(t, err)
+
+	elapsed := time.Since(started)
+
+	assert.Equal(t, len(data), len(read))
+	assert.GreaterOrEqual(t, elapsed, 450*time.Millisecond)
+	assert.Less(t, elapsed, 2*time.Second)
+}
+
+func TestRateLimitedReadCloserSharesLimitBetweenReaders(t *testing.T) {
+	const bytesPerSec = 1024 * 1024
+
+	limiter := util.NewRateLimiter(bytesPerSec)
+	data := bytes.Repeat([]byte("a"), bytesPerSec*3/4)
+
+	started := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rc := util.NewRateLimitedReadCloser(io.NopCloser(bytes.NewReader(data)), limiter)
+			read, err := io.ReadAll(rc)
+			assert.NoError(t, err)
+			assert.Equal(t, len(data), len(read))
+		}()
+	}
+	wg.Wait()
+
+	// Combined transfer is limited (separate limits would not delay either reader)
+	assert.GreaterOrEqual(t, time.Since(started), 450*time.Millisecond)
+}
+
+func TestRateLimitedReadCloserReadsInChunks(t *testing.T) {
+	limiter := util.NewRateLimiter(1000)
+	assert.Equal(t, 100, limiter.MaxChunk())
+
+	rc := util.NewRateLimitedReadCloser(io.NopCloser(bytes.NewReader(make([]byte, 500))), limiter)
+
+	n, err := rc.Read(make([]byte, 500))
+	require.NoError(t, err)
+	assert.Equal(t, 100, n)
+}
@@ -0,0 +1,91 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package util
+
+import (
+	"errors"
+)
+
+// ErrorCategory classifies failures so that callers (e.g. CI pipelines)
+// can distinguish misconfiguration from infrastructure problems
+type ErrorCategory string
+
+const (
+	ErrorCategoryUnknown    ErrorCategory = "unknown"
+	ErrorCategoryConfig     ErrorCategory = "config"
+	ErrorCategoryBuild      ErrorCategory = "build"
+	ErrorCategoryPush       ErrorCategory = "push"
+	ErrorCategoryResolution ErrorCategory = "resolution"
+	ErrorCategoryPolicy     ErrorCategory = "policy"
+)
+
+// errorCategoryPrecedence orders categories by processing stage;
+// when multiple errors are combined earliest stage wins
+var errorCategoryPrecedence = []ErrorCategory{
+	ErrorCategoryConfig,
+	ErrorCategoryBuild,
+	ErrorCategoryPush,
+	ErrorCategoryResolution,
+	ErrorCategoryPolicy,
+}
+
+// ExitCode returns process exit code for a category
+func (c ErrorCategory) ExitCode() int {
+	switch c {
+	case ErrorCategoryConfig:
+		return 2
+	case ErrorCategoryBuild:
+		return 3
+	case ErrorCategoryPush:
+		return 4
+	case ErrorCategoryResolution:
+		return 5
+	case ErrorCategoryPolicy:
+		return 6
+	default:
+		return 1
+	}
+}
+
+type CategorizedError struct {
+	Category ErrorCategory
+	Err      error
+}
+
+func (e CategorizedError) Error() string { return e.Err.Error() }
+func (e CategorizedError) Unwrap() error { return e.Err }
+
+// NewCategorizedError assigns category to an error unless
+// it was already categorized by more specific code
+func NewCategorizedError(category ErrorCategory, err error) error {
+	if err == nil || category == ErrorCategoryUnknown {
+		return err
+	}
+	if ErrorCategoryOf(err) != ErrorCategoryUnknown {
+		return err
+	}
+	return CategorizedError{category, err}
+}
+
+func ErrorCategoryOf(err error) ErrorCategory {
+	var catErr CategorizedError
+	if errors.As(err, &catErr) {
+		return catErr.Category
+	}
+	return ErrorCategoryUnknown
+}
+
+// ErrorCategoryOfAll returns category of earliest failed stage
+func ErrorCategoryOfAll(errs []error) ErrorCategory {
+	found := map[ErrorCategory]bool{}
+	for _, err := range errs {
+		found[ErrorCategoryOf(err)] = true
+	}
+	for _, category := range errorCategoryPrecedence {
+		if found[category] {
+			return category
+		}
+	}
+	return ErrorCategoryUnknown
+}
@@ -0,0 +1,46 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package util_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+func TestNewCategorizedErrorKeepsInnerCategory(t *testing.T) {
+	buildErr := util.NewCategorizedError(util.ErrorCategoryBuild, fmt.Errorf("docker build failed"))
+	assert.Equal(t, util.ErrorCategoryBuild, util.ErrorCategoryOf(buildErr))
+
+	err := util.NewCategorizedError(util.ErrorCategoryPush, buildErr)
+	assert.Equal(t, util.ErrorCategoryBuild, util.ErrorCategoryOf(err))
+	assert.Equal(t, "docker build failed", err.Error())
+
+	wrappedErr := fmt.Errorf("Resolving image: %w", buildErr)
+	assert.Equal(t, util.ErrorCategoryBuild, util.ErrorCategoryOf(wrappedErr))
+
+	assert.Equal(t, util.ErrorCategoryUnknown, util.ErrorCategoryOf(fmt.Errorf("plain")))
+	assert.Nil(t, util.NewCategorizedError(util.ErrorCategoryConfig, nil))
+}
+
+func TestErrorCategoryOfAll(t *testing.T) {
+	errs := []error{
+		util.NewCategorizedError(util.ErrorCategoryPolicy, fmt.Errorf("policy")),
+		util.NewCategorizedError(util.ErrorCategoryResolution, fmt.Errorf("registry down")),
+		fmt.Errorf("plain"),
+	}
+	assert.Equal(t, util.ErrorCategoryResolution, util.ErrorCategoryOfAll(errs))
+	assert.Equal(t, util.ErrorCategoryUnknown, util.ErrorCategoryOfAll([]error{fmt.Errorf("plain")}))
+}
+
+func TestErrorCategoryExitCode(t *testing.T) {
+	assert.Equal(t, 1, util.ErrorCategoryUnknown.ExitCode())
+	assert.Equal(t, 2, util.ErrorCategoryConfig.ExitCode())
+	assert.Equal(t, 3, util.ErrorCategoryBuild.ExitCode())
+	assert.Equal(t, 4, util.ErrorCategoryPush.ExitCode())
+	assert.Equal(t, 5, util.ErrorCategoryResolution.ExitCode())
+	assert.Equal(t, 6, util.ErrorCategoryPolicy.ExitCode())
+}
@@ -0,0 +1,166 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package util
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	sizeRegexp = regexp.MustCompile(`\A([0-9]+(?:\.[0-9]+)?)\s*([a-zA-Z]*)\z`)
+
+	// tmpDirs is shared by all commands within a process
+	// since temporary directories are created in many places
+	tmpDirs = &TmpDirs{}
+)
+
+// ParseSize parses size strings such as "10GiB", "500MB" or "1024" into number of bytes
+func ParseSize(str string) (int64, error) {
+	matches := sizeRegexp.FindStringSubmatch(strings.TrimSpace(str))
+	if len(matches) != 3 {
+		return 0, fmt.Errorf("Parsing size '%s': expected format <number>[B|KB|KiB|MB|MiB|GB|GiB]", str)
+	}
+
+	num, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("Parsing size '%s': %s", str, err)
+	}
+
+	multiplier, found := bandwidthUnits[strings.ToLower(matches[2])]
+	if !found {
+		return 0, fmt.Errorf("Parsing size '%s': unknown unit '%s'", str, matches[2])
+	}
+
+	return int64(num * multiplier), nil
+}
+
+// TmpDirs keeps track of temporary directories created by kbld
+// so that they are placed in configured parent directory, do not
+// exceed configured disk usage and are removed even on failure
+type TmpDirs struct {
+	parent   string
+	maxBytes int64
+
+	dirs     map[string]struct{}
+	dirsLock sync.Mutex
+}
+
+// ConfigureTmpDirs sets parent directory (system default when empty)
+// and maximum combined size of temporary directories (unlimited when 0)
+func ConfigureTmpDirs(parent string, maxBytes int64) error {
+	if len(parent) > 0 {
+		info, err := os.Stat(parent)
+		if err != nil {
+			return fmt.Errorf("Checking temporary directory '%s': %s", parent, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("Expected temporary directory '%s' to be a directory", parent)
+		}
+	}
+
+	tmpDirs.dirsLock.Lock()
+	defer tmpDirs.dirsLock.Unlock()
+
+	tmpDirs.parent = parent
+	tmpDirs.maxBytes = maxBytes
+	return nil
+}
+
+// TmpDir returns configured parent directory for temporary files
+// (empty string means system default, same as for os.MkdirTemp)
+func TmpDir() string {
+	tmpDirs.dirsLock.Lock()
+	defer tmpDirs.dirsLock.Unlock()
+
+	return tmpDirs.parent
+}
+
+// MkdirTemp creates temporary directory in configured parent directory.
+// Directory should still be removed by the caller once it's not needed.
+func MkdirTemp(pattern string) (string, error) {
+	err := CheckTmpDiskUsage()
+	if err != nil {
+		return "", err
+	}
+
+	tmpDirs.dirsLock.Lock()
+	defer tmpDirs.dirsLock.Unlock()
+
+	dir, err := os.MkdirTemp(tmpDirs.parent, pattern)
+	if err != nil {
+		return "", err
+	}
+
+	if tmpDirs.dirs == nil {
+		tmpDirs.dirs = map[string]struct{}{}
+	}
+	tmpDirs.dirs[dir] = struct{}{}
+
+	return dir, nil
+}
+
+// CheckTmpDiskUsage returns error when temporary directories
+// that still exist use more than configured maximum
+func CheckTmpDiskUsage() error {
+	tmpDirs.dirsLock.Lock()
+	maxBytes := tmpDirs.maxBytes
+	var dirs []string
+	for dir := range tmpDirs.dirs {
+		dirs = append(dirs, dir)
+	}
+	tmpDirs.dirsLock.Unlock()
+
+	var usedBytes int64
+	var removedDirs []string
+
+	for _, dir := range dirs {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			// Directories removed by their owners are no longer tracked
+			removedDirs = append(removedDirs, dir)
+			continue
+		}
+		if maxBytes <= 0 {
+			continue
+		}
+		_ = filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return nil
+			}
+			if info, err := entry.Info(); err == nil {
+				usedBytes += info.Size()
+			}
+			return nil
+		})
+	}
+
+	tmpDirs.dirsLock.Lock()
+	for _, dir := range removedDirs {
+		delete(tmpDirs.dirs, dir)
+	}
+	tmpDirs.dirsLock.Unlock()
+
+	if maxBytes > 0 && usedBytes > maxBytes {
+		return fmt.Errorf("Expected temporary files to use at most %d bytes of disk, but used %d bytes "+
+			"(increase '--max-disk-usage' or use '--tmp-dir' with more space)", maxBytes, usedBytes)
+	}
+	return nil
+}
+
+// CleanupTmpDirs removes all temporary directories that still exist
+func CleanupTmpDirs() {
+	tmpDirs.dirsLock.Lock()
+	defer tmpDirs.dirsLock.Unlock()
+
+	for dir := range tmpDirs.dirs {
+		os.RemoveAll(dir)
+	}
+	tmpDirs.dirs = nil
+}
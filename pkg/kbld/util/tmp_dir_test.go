@@ -0,0 +1,58 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package util_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+func TestParseSize(t *testing.T) {
+	size, err := util.ParseSize("10GiB")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10*1024*1024*1024), size)
+
+	_, err = util.ParseSize("10GB/s")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Parsing size '10GB/s': expected format")
+}
+
+func TestTmpDirs(t *testing.T) {
+	parent := t.TempDir()
+	defer util.ConfigureTmpDirs("", 0)
+
+	require.NoError(t, util.ConfigureTmpDirs(parent, 1024))
+
+	dir, err := util.MkdirTemp("kbld-test")
+	require.NoError(t, err)
+	assert.Equal(t, parent, filepath.Dir(dir))
+	assert.Equal(t, parent, util.TmpDir())
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "small"), make([]byte, 512), 0600))
+	require.NoError(t, util.CheckTmpDiskUsage())
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "large"), make([]byte, 1024), 0600))
+
+	err = util.CheckTmpDiskUsage()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Expected temporary files to use at most 1024 bytes of disk, but used 1536 bytes")
+
+	_, err = util.MkdirTemp("kbld-test")
+	require.Error(t, err)
+
+	util.CleanupTmpDirs()
+
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+	require.NoError(t, util.CheckTmpDiskUsage())
+
+	err = util.ConfigureTmpDirs(filepath.Join(parent, "missing"), 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Checking temporary directory")
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package util
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Watcher polls files and directories for changes
+// based on their names, sizes and modification times
+type Watcher struct {
+	interval time.Duration
+}
+
+func NewWatcher(interval time.Duration) Watcher {
+	return Watcher{interval}
+}
+
+// Fingerprint returns a value that changes when any of (recursively) watched files change.
+// Missing paths are included as such so that their creation is detected.
+func (w Watcher) Fingerprint(paths []string) (string, error) {
+	var entries []string
+
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(walkPath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					entries = append(entries, walkPath+" missing")
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() {
+				if d.Name() == ".git" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			entries = append(entries, fmt.Sprintf("%s %d %d", walkPath, info.Size(), info.ModTime().UnixNano()))
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	sort.Strings(entries)
+
+	hash := sha256.New()
+	for _, entry := range entries {
+		hash.Write([]byte(entry + "\n"))
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// WaitForChange blocks until fingerprint of given paths differs from previous one
+func (w Watcher) WaitForChange(pathsFunc func() []string, previous string) (string, error) {
+	for {
+		time.Sleep(w.interval)
+
+		current, err := w.Fingerprint(pathsFunc())
+		if err != nil {
+			return "", err
+		}
+		if current != previous {
+			return current, nil
+		}
+	}
+}
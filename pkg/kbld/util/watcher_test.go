@@ -0,0 +1,54 @@
+// Copyright 2024 VMware, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package util_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-tanzu/carvel-kbld/pkg/kbld/util"
+)
+
+func TestWatcherFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app", "Dockerfile")
+	missing := filepath.Join(dir, "config.yml")
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(file), 0700))
+	require.NoError(t, os.WriteFile(file, []byte("FROM scratch\n"), 0600))
+
+	watcher := util.NewWatcher(time.Millisecond)
+	paths := []string{dir, missing}
+
+	fp1, err := watcher.Fingerprint(paths)
+	require.NoError(t, err)
+
+	fp2, err := watcher.Fingerprint(paths)
+	require.NoError(t, err)
+	assert.Equal(t, fp1, fp2)
+
+	require.NoError(t, os.WriteFile(file, []byte("FROM busybox\n"), 0600))
+
+	fp3, err := watcher.Fingerprint(paths)
+	require.NoError(t, err)
+	assert.NotEqual(t, fp1, fp3)
+
+	require.NoError(t, os.WriteFile(missing, []byte("---\n"), 0600))
+
+	fp4, err := watcher.Fingerprint(paths)
+	require.NoError(t, err)
+	assert.NotEqual(t, fp3, fp4)
+
+	// Changes in .git directory are ignored
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".git"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref"), 0600))
+
+	fp5, err := watcher.Fingerprint(paths)
+	require.NoError(t, err)
+	assert.Equal(t, fp4, fp5)
+}